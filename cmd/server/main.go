@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
 	"syscall"
 	"time"
@@ -13,12 +14,18 @@ import (
 	"LLM_Chat/internal/api/handlers"
 	"LLM_Chat/internal/api/routes"
 	"LLM_Chat/internal/config"
+	"LLM_Chat/internal/events"
+	"LLM_Chat/internal/language"
+	"LLM_Chat/internal/metrics"
+	"LLM_Chat/internal/service/audit"
 	"LLM_Chat/internal/service/chat"
 	contextmgr "LLM_Chat/internal/service/context"
 	"LLM_Chat/internal/service/summary"
 	"LLM_Chat/internal/storage/postgres"
 	"LLM_Chat/pkg/llm"
 	"LLM_Chat/pkg/llm/providers"
+	"LLM_Chat/pkg/redact"
+	"LLM_Chat/pkg/tokens"
 
 	"go.uber.org/zap"
 )
@@ -42,6 +49,7 @@ func main() {
 		zap.Int("port", cfg.Server.Port),
 		zap.String("llm_provider", cfg.LLM.Provider),
 		zap.String("llm_model", cfg.LLM.Model),
+		zap.String("llm_shrink_model", cfg.ToShrinkProviderConfig().Model),
 		zap.String("mcp_server", cfg.MCP.ServerURL),
 		zap.String("database_url", maskDatabaseURL(cfg.Database.URL)),
 		zap.Int("context_window_size", cfg.Chat.ContextWindowSize),
@@ -50,6 +58,14 @@ func main() {
 		zap.Bool("auto_migrate", cfg.Database.AutoMigrate),
 	)
 
+	// Ответ без потока отправляется только после завершения запроса к модели
+	if cfg.Server.WriteTimeout > 0 && cfg.LLM.Timeout > cfg.Server.WriteTimeout {
+		logger.Warn("LLM timeout is longer than server write timeout, long responses will be cut off",
+			zap.Duration("llm_timeout", cfg.LLM.Timeout),
+			zap.Duration("write_timeout", cfg.Server.WriteTimeout),
+		)
+	}
+
 	// Валидация конфигурации LLM
 	if cfg.LLM.APIKey == "" {
 		envVars := config.GetGeminiEnvVars()
@@ -60,8 +76,8 @@ func main() {
 		)
 	}
 
-	// Проверяем поддержку провайдера (теперь только Gemini)
-	if cfg.LLM.Provider != "gemini" {
+	// Проверяем поддержку провайдера
+	if err := llm.ValidateProvider(cfg.LLM.Provider, logger); err != nil {
 		supportedProviders := llm.GetSupportedProviders(logger)
 		logger.Fatal("Unsupported LLM provider",
 			zap.String("provider", cfg.LLM.Provider),
@@ -113,10 +129,20 @@ func main() {
 		logger.Fatal("Failed to initialize shrink LLM client", zap.Error(err))
 	}
 
+	// Модель вне списка провайдера может быть новой или доступной через прокси, поэтому не фатальна
+	shrinkModel := cfg.ToShrinkProviderConfig().Model
+	if supported := shrinkLLMClient.GetSupportedModels(); !slices.Contains(supported, shrinkModel) {
+		logger.Warn("Shrink model is not in the provider's supported list",
+			zap.String("shrink_model", shrinkModel),
+			zap.Strings("supported_models", supported),
+		)
+	}
+
 	logger.Info("MCP LLM clients initialized successfully",
 		zap.String("main_provider", mainLLMClient.GetProviderName()),
 		zap.String("main_model", cfg.LLM.Model),
 		zap.String("shrink_provider", shrinkLLMClient.GetProviderName()),
+		zap.String("shrink_model", shrinkModel),
 		zap.String("mcp_server", cfg.MCP.ServerURL),
 	)
 
@@ -126,13 +152,28 @@ func main() {
 		zap.Strings("models", supportedModels),
 	)
 
+	// Шина системных событий сессий (сжатие, резюме) для /chat/:session_id/events
+	eventBus := events.NewBus(cfg.Events.SubscriberBuffer, logger)
+
+	// Язык ответов и резюме: настройка сессии или chat.language
+	languagePolicy := language.NewPolicy(storage, cfg.Chat.Language)
+
 	// Инициализация Summary Service с поддержкой многоуровневого сжатия
 	summaryConfig := summary.DefaultConfig()
 	summaryConfig.ContextWindowSize = cfg.Chat.ContextWindowSize
+	summaryConfig.Language = cfg.Summary.Language
+	summaryConfig.ChunkTokenBudget = cfg.Summary.ChunkTokens
+	// Шаблоны проверены при загрузке конфигурации
+	summaryConfig.Prompts, err = summary.LoadPrompts(cfg.SummaryPromptFiles())
+	if err != nil {
+		logger.Fatal("Failed to load summary prompts", zap.Error(err))
+	}
 
 	summaryService := summary.NewService(
 		storage, // ExtendedMessageStore (SummaryStore)
 		shrinkLLMClient,
+		eventBus,
+		languagePolicy,
 		summaryConfig,
 		logger,
 	)
@@ -141,6 +182,8 @@ func main() {
 		zap.Int("anchors_count", summaryConfig.AnchorsCount),
 		zap.Int("summary_max_length", summaryConfig.SummaryMaxLength),
 		zap.Int("min_messages_for_summary", summaryConfig.MinMessagesForSummary),
+		zap.String("language", summaryConfig.Language),
+		zap.Int("chunk_token_budget", summaryConfig.ChunkTokenBudget),
 	)
 
 	// Инициализация Context Manager с многоуровневым сжатием
@@ -150,10 +193,41 @@ func main() {
 	contextConfig.MessageCompressionRatio = cfg.Chat.MessageCompressionRatio
 	contextConfig.SummaryCompressionRatio = cfg.Chat.SummaryCompressionRatio
 	contextConfig.MinMessagesInWindow = cfg.Chat.MinMessagesInWindow
+	contextConfig.MaxActiveAnchors = cfg.Chat.MaxActiveAnchors
+	contextConfig.MaxContextTokens = cfg.Chat.MaxContextTokens
+	contextConfig.MessageTokenRatio = cfg.Chat.MessageTokenRatio
+	contextConfig.SummaryTokenRatio = cfg.Chat.SummaryTokenRatio
+	contextConfig.MaxBulkSummaries = cfg.Chat.MaxBulkSummaries
+	contextConfig.BulkTokenRatio = cfg.Chat.BulkTokenRatio
+	contextConfig.SummaryRole = cfg.Chat.SummaryRole
+	contextConfig.SummaryTemplate = cfg.Chat.SummaryTemplate
+	contextConfig.ExcludeToolMessages = cfg.Chat.ExcludeToolMessages
+	contextConfig.Recall = contextmgr.RecallConfig{
+		Enabled:        cfg.Chat.Recall.Enabled,
+		EmbeddingModel: cfg.Chat.Recall.EmbeddingModel,
+		TopK:           cfg.Chat.Recall.TopK,
+		MinSimilarity:  cfg.Chat.Recall.MinSimilarity,
+		MaxTokens:      cfg.Chat.Recall.MaxTokens,
+	}
+
+	// Эмбеддинги для поиска по сжатой истории считает основной клиент
+	var embedder contextmgr.Embedder
+	if cfg.Chat.Recall.Enabled {
+		embedder = mainLLMClient
+	}
+
+	// Токены контекста считает CountTokens основного клиента с кэшем по содержимому сообщений;
+	// при ошибке API используется эвристическая оценка
+	tokenCounter := tokens.NewCachedCounter(func(ctx context.Context, text string) (int, error) {
+		return mainLLMClient.CountTokens(ctx, []llm.Message{{Role: "user", Content: text}})
+	}, tokens.DefaultCacheSize, tokens.NewHeuristic())
 
 	contextManager := contextmgr.NewManager(
 		storage, // ExtendedMessageStore
 		summaryService,
+		embedder, // nil - поиск по сжатой истории выключен
+		tokenCounter,
+		eventBus,
 		contextConfig,
 		logger,
 	)
@@ -163,34 +237,124 @@ func main() {
 		zap.Float64("message_compression_ratio", contextConfig.MessageCompressionRatio),
 		zap.Float64("summary_compression_ratio", contextConfig.SummaryCompressionRatio),
 		zap.Int("min_messages_in_window", contextConfig.MinMessagesInWindow),
+		zap.Bool("recall_enabled", contextConfig.Recall.Enabled),
+		zap.Int("max_context_tokens", contextConfig.MaxContextTokens),
 	)
 
+	// Уведомления о сжатиях для внешнего мониторинга (chat.compression_webhook_url)
+	var compressionWebhook *contextmgr.WebhookNotifier
+	if cfg.Chat.CompressionWebhookURL != "" {
+		compressionWebhook = contextmgr.NewWebhookNotifier(cfg.Chat.CompressionWebhookURL, cfg.Chat.CompressionWebhookTimeout, logger)
+		contextManager.SetCompressionNotifier(compressionWebhook)
+		logger.Info("Compression webhook enabled",
+			zap.Duration("timeout", cfg.Chat.CompressionWebhookTimeout))
+	}
+
+	// Маскирование персональных данных перед сохранением и записью в логи; правила проверены при загрузке конфигурации
+	var redactor *redact.Redactor
+	if cfg.Redaction.Enabled {
+		redactor, err = redact.New(cfg.ToRedactConfig())
+		if err != nil {
+			logger.Fatal("Failed to initialize PII redaction", zap.Error(err))
+		}
+		mainLLMClient.SetRedactor(redactor)
+		shrinkLLMClient.SetRedactor(redactor)
+		logger.Info("PII redaction enabled",
+			zap.Strings("detectors", cfg.Redaction.Detectors),
+			zap.Int("custom_patterns", len(cfg.Redaction.Patterns)))
+	}
+
+	// Выбор модели запроса: явный выбор -> настройка пользователя -> llm.model
+	modelResolver := chat.NewModelResolver(storage, cfg.LLM.Model, supportedModels, logger)
+
 	// Инициализация Chat Service с PostgreSQL и Context Manager
 	chatService := chat.NewService(
 		storage,        // ExtendedMessageStore (MessageStore)
 		storage,        // ExtendedMessageStore (SessionStore)
 		contextManager, // ContextManager с многоуровневым сжатием
 		mainLLMClient,  // Main LLM
+		modelResolver,  // Модель по умолчанию с учётом настроек пользователя
+		languagePolicy, // Язык ответов сессии
+		eventBus,       // Системные события сессий
+		redactor,       // Маскирование персональных данных (nil - выключено)
 		&cfg.Chat,
 		logger,
 	)
 	logger.Info("Chat service with PostgreSQL and multi-level compression initialized")
 
+	// Журнал аудита вызовов MCP инструментов, не зависит от хранения сообщений
+	toolRecorder := audit.NewToolRecorder(storage, logger)
+	mainLLMClient.SetToolInvocationRecorder(toolRecorder)
+	shrinkLLMClient.SetToolInvocationRecorder(toolRecorder)
+
+	// Фоновое сжатие сессий, в которых давно не было сообщений (chat.idle_compression)
+	idleCompression := chat.NewIdleCompressionJob(storage, contextManager, cfg.Chat.IdleCompression, logger)
+
+	// Инициализация метрик
+	// Счётчики провайдеров общие для всех клиентов, доступны и без Prometheus
+	llmStats := llm.NewStats()
+	mainLLMClient.SetStats(llmStats)
+	shrinkLLMClient.SetStats(llmStats)
+
+	var appMetrics *metrics.Metrics
+	if cfg.Metrics.Enabled {
+		appMetrics = metrics.New()
+
+		mainLLMClient.SetUsageObserver(appMetrics.LLMObserver("main"))
+		shrinkLLMClient.SetUsageObserver(appMetrics.LLMObserver("shrink"))
+		mainLLMClient.SetToolCallObserver(appMetrics.ToolCallObserver())
+		shrinkLLMClient.SetToolCallObserver(appMetrics.ToolCallObserver())
+
+		if err := appMetrics.RegisterLLMStats(llmStats); err != nil {
+			logger.Fatal("Failed to register LLM provider metrics", zap.Error(err))
+		}
+		if err := appMetrics.RegisterLLMConcurrency(map[string]metrics.LLMConcurrencySource{
+			"main":   mainLLMClient,
+			"shrink": shrinkLLMClient,
+		}); err != nil {
+			logger.Fatal("Failed to register LLM concurrency metrics", zap.Error(err))
+		}
+
+		if err := appMetrics.RegisterChatStats(chatService.Metrics()); err != nil {
+			logger.Fatal("Failed to register chat metrics", zap.Error(err))
+		}
+		if err := appMetrics.RegisterSummaryStats(summaryService.Metrics()); err != nil {
+			logger.Fatal("Failed to register summary metrics", zap.Error(err))
+		}
+		if err := appMetrics.RegisterIdleCompressionStats(idleCompression); err != nil {
+			logger.Fatal("Failed to register idle compression metrics", zap.Error(err))
+		}
+
+		logger.Info("Prometheus metrics enabled",
+			zap.String("path", cfg.Metrics.Path),
+			zap.Bool("public", cfg.Metrics.Public))
+	}
+
 	// Инициализация handlers
-	chatHandler := handlers.NewChatHandler(chatService, storage, logger)
+	chatHandler := handlers.NewChatHandler(chatService, chatService.Streams(), storage, logger)
 	summaryHandler := handlers.NewSummaryHandler(summaryService, logger)
-	healthHandler := handlers.NewHealthHandler()
 	modelsHandler := handlers.NewModelsHandler(logger)
+	mcpHandler := handlers.NewMCPHandler(mainLLMClient, cfg.MCP, logger)
+	eventsHandler := handlers.NewEventsHandler(eventBus, cfg.Events, logger)
+	usersHandler := handlers.NewUsersHandler(storage, modelResolver, logger)
+	healthHandler := handlers.NewHealthHandler(storage, mcpHandler, mainLLMClient, cfg.Health, logger)
+	adminHandler := handlers.NewAdminHandler(map[string]llm.Reinitializer{
+		"main":   mainLLMClient,
+		"shrink": shrinkLLMClient,
+	}, mcpHandler, storage, storage, cfg.Admin, logger)
 
 	// Настройка роутов
-	router := routes.SetupRoutes(cfg, logger, chatHandler, summaryHandler, healthHandler, modelsHandler)
+	router := routes.SetupRoutes(cfg, logger, chatHandler, summaryHandler, healthHandler, modelsHandler, mcpHandler, adminHandler, eventsHandler, usersHandler, appMetrics)
 
 	// Настройка HTTP сервера
 	server := &http.Server{
 		Addr:         fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
 		Handler:      router,
-		ReadTimeout:  cfg.Server.ReadTimeout,
 		WriteTimeout: cfg.Server.WriteTimeout,
+		// ReadTimeout не используется: по его истечении net/http отменяет контекст
+		// долгих запросов и стримов. Тело запроса ограничивается LimitsMiddleware.
+		ReadHeaderTimeout: cfg.Server.ReadTimeout,
+		MaxHeaderBytes:    cfg.Server.Limits.MaxHeaderBytes,
 	}
 
 	// Запуск сервера в отдельной горутине
@@ -204,6 +368,13 @@ func main() {
 	// Логируем информацию о конфигурации
 	logConfigInfo(cfg, logger)
 
+	// Фоновая очистка: зависшие после перезапуска pending сообщения переводятся в failed,
+	// при chat.purge_compressed_after удаляются давно сжатые сообщения
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	chat.NewCleanupJob(storage, eventBus, cfg.Chat.Cleanup, cfg.Chat.PurgeCompressedAfter, logger).Start(jobsCtx)
+	idleCompression.Start(jobsCtx)
+
 	// Проверяем подключение к базе данных
 	if err := testDatabaseConnection(storage, logger); err != nil {
 		logger.Fatal("Database connection test failed", zap.Error(err))
@@ -215,6 +386,21 @@ func main() {
 	<-quit
 
 	logger.Info("Shutting down server...")
+	stopJobs()
+	// Прерванное фоновое сжатие откатывается; дожидаемся его до закрытия хранилища
+	idleCompression.Wait()
+
+	// Прекращаем приём новых сообщений и даём активным потокам завершиться
+	streams := chatService.Streams()
+	logger.Info("Draining active streams",
+		zap.Int("active_streams", streams.Active()),
+		zap.Duration("drain_timeout", cfg.Server.ShutdownDrainTimeout),
+	)
+	finished, cancelled := streams.Drain(cfg.Server.ShutdownDrainTimeout)
+	logger.Info("Active streams drained",
+		zap.Int("finished", finished),
+		zap.Int("cancelled", cancelled),
+	)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -223,14 +409,35 @@ func main() {
 		logger.Error("Server forced to shutdown", zap.Error(err))
 	}
 
+	// Доставляем уведомления о сжатиях, уже поставленные в очередь
+	if compressionWebhook != nil {
+		if err := compressionWebhook.Close(ctx); err != nil {
+			logger.Warn("Pending compression webhook events dropped", zap.Error(err))
+		}
+	}
+
+	// Закрываем сессии MCP (сервер получает DELETE сессии) и клиенты Gemini
+	for name, client := range map[string]*llm.Client{"main": mainLLMClient, "shrink": shrinkLLMClient} {
+		if err := client.Close(); err != nil {
+			logger.Warn("Failed to close LLM client", zap.String("llm_client", name), zap.Error(err))
+		}
+	}
+
 	logger.Info("Server stopped gracefully")
 }
 
 func initMCPLLMClient(cfg *config.Config, logger *zap.Logger, clientType string) (*llm.Client, error) {
 	providerConfig := cfg.ToProviderConfig()
 	mcpConfig := cfg.ToMCPConfig()
+	maxConcurrent, queueTimeout := cfg.LLM.MaxConcurrentRequests, cfg.LLM.QueueTimeout
+	if clientType != "main" {
+		// Резюме строятся только по истории диалога и со своими параметрами генерации
+		providerConfig = cfg.ToShrinkProviderConfig()
+		mcpConfig.GoogleSearch = false
+		maxConcurrent, queueTimeout = cfg.ShrinkConcurrency()
+	}
 
-	// Создаем MCP Gemini провайдер
+	// Gemini создаётся с MCP; провайдеры без MCP фабрика создаёт без инструментов
 	factory := providers.NewFactory(logger.With(zap.String("llm_client", clientType)))
 	provider, err := factory.CreateProviderWithMCP(providerConfig, mcpConfig)
 	if err != nil {
@@ -238,6 +445,10 @@ func initMCPLLMClient(cfg *config.Config, logger *zap.Logger, clientType string)
 	}
 
 	client := llm.NewClientWithProvider(provider, logger.With(zap.String("llm_client", clientType)))
+	client.SetCircuitBreaker(cfg.LLM.CircuitBreaker)
+	client.SetRetryConfig(cfg.LLM.RequestRetry)
+	// У каждого клиента свой пул слотов: сжатие истории не занимает слоты интерактивного чата
+	client.SetConcurrencyLimit(maxConcurrent, queueTimeout)
 	return client, nil
 }
 
@@ -315,6 +526,27 @@ func logConfigInfo(cfg *config.Config, logger *zap.Logger) {
 		zap.Int("min_messages_in_window", cfg.Chat.MinMessagesInWindow),
 		zap.Int("max_messages_per_session", cfg.Chat.MaxMessagesPerSession),
 	)
+
+	if cfg.Chat.PurgeCompressedAfter > 0 {
+		logger.Warn("Compressed messages are purged after the grace period; summaries are the only long-term record",
+			zap.Duration("purge_compressed_after", cfg.Chat.PurgeCompressedAfter),
+			zap.Int("purge_batch_size", cfg.Chat.Cleanup.PurgeBatchSize),
+		)
+	}
+
+	if idle := cfg.Chat.IdleCompression; idle.Interval > 0 {
+		logger.Info("Idle session compression enabled",
+			zap.Duration("interval", idle.Interval),
+			zap.Duration("idle_after", idle.IdleAfter),
+			zap.Int("min_active_messages", idle.MinActiveMessages),
+			zap.Int("max_sessions_per_run", idle.MaxSessionsPerRun),
+			zap.Duration("min_delay", idle.MinDelay),
+		)
+	}
+
+	if cfg.Admin.Token == "" {
+		logger.Info("Admin API disabled: admin.token is not set")
+	}
 }
 
 func setupLogger(cfg config.LoggingConfig) (*zap.Logger, error) {
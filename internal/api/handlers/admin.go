@@ -0,0 +1,419 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"LLM_Chat/internal/api/middleware"
+	"LLM_Chat/internal/api/pagination"
+	"LLM_Chat/internal/config"
+	"LLM_Chat/internal/storage/interfaces"
+	"LLM_Chat/internal/storage/models"
+	"LLM_Chat/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type AdminHandler struct {
+	clients             map[string]llm.Reinitializer
+	mcpHandler          *MCPHandler
+	toolInvocations     interfaces.ToolInvocationStore
+	sessions            interfaces.SessionStore
+	reinitializeTimeout time.Duration
+	deleteBatchSize     int
+	logger              *zap.Logger
+}
+
+// NewAdminHandler создаёт обработчик административного API.
+// clients - LLM клиенты по именам ("main", "shrink"), mcpHandler может быть nil.
+func NewAdminHandler(
+	clients map[string]llm.Reinitializer,
+	mcpHandler *MCPHandler,
+	toolInvocations interfaces.ToolInvocationStore,
+	sessions interfaces.SessionStore,
+	cfg config.AdminConfig,
+	logger *zap.Logger,
+) *AdminHandler {
+	return &AdminHandler{
+		clients:             clients,
+		mcpHandler:          mcpHandler,
+		toolInvocations:     toolInvocations,
+		sessions:            sessions,
+		reinitializeTimeout: cfg.ReinitializeTimeout,
+		deleteBatchSize:     cfg.DeleteBatchSize,
+		logger:              logger,
+	}
+}
+
+// ToolInvocationsResponse страница журнала аудита вызовов инструментов
+type ToolInvocationsResponse struct {
+	pagination.Page[models.ToolInvocation]
+}
+
+// GET /admin/tool-invocations - журнал аудита вызовов MCP инструментов (новые первыми)
+func (h *AdminHandler) ListToolInvocations(c *gin.Context) {
+	params, ok := parsePagination(c, pagination.DefaultOptions)
+	if !ok {
+		return
+	}
+
+	filter := models.ToolInvocationFilter{
+		SessionID: c.Query("session_id"),
+		ToolName:  c.Query("tool"),
+	}
+
+	var err error
+	if filter.From, err = parseTimeQuery(c, "from"); err == nil {
+		filter.To, err = parseTimeQuery(c, "to")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid time range",
+			Code:    "INVALID_TIME_RANGE",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	invocations, total, err := h.toolInvocations.ListToolInvocations(c.Request.Context(), filter, params.Limit, params.Offset)
+	if err != nil {
+		h.logger.Error("Failed to list tool invocations", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to list tool invocations",
+			Code:    "AUDIT_LOG_ERROR",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ToolInvocationsResponse{
+		Page: pagination.NewPage(invocations, total, params),
+	})
+}
+
+// parseTimeQuery разбирает необязательный query параметр в формате RFC 3339
+func parseTimeQuery(c *gin.Context, name string) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp, got %q", name, value)
+	}
+	return parsed, nil
+}
+
+// DeleteSessionsFilter условия выбора сессий для массового удаления
+type DeleteSessionsFilter struct {
+	OlderThan    string `json:"older_than,omitempty"` // RFC 3339, последняя активность раньше этого момента
+	UserID       string `json:"user_id,omitempty" binding:"max=255"`
+	UntitledOnly bool   `json:"untitled_only,omitempty"`
+}
+
+// DeleteSessionsRequest задаёт сессии явным списком или фильтром, но не тем и другим сразу
+type DeleteSessionsRequest struct {
+	SessionIDs []string              `json:"session_ids,omitempty" binding:"omitempty,max=10000,dive,max=100"`
+	Filter     *DeleteSessionsFilter `json:"filter,omitempty"`
+	DryRun     bool                  `json:"dry_run,omitempty"`
+}
+
+// DeleteSessionsResponse итог массового удаления. В режиме dry_run ничего не удаляется,
+// а session_ids перечисляет сессии, которые были бы удалены. Сессии пачек, транзакция
+// которых не удалась, перечислены в failed_session_ids.
+type DeleteSessionsResponse struct {
+	DryRun           bool     `json:"dry_run"`
+	Matched          int      `json:"matched"`
+	Deleted          int      `json:"deleted"`
+	NotFound         int      `json:"not_found"`
+	Failed           int      `json:"failed"`
+	SessionIDs       []string `json:"session_ids,omitempty"`
+	FailedSessionIDs []string `json:"failed_session_ids,omitempty"`
+	RequestID        string   `json:"request_id,omitempty"`
+}
+
+// POST /admin/sessions/delete - массовое удаление сессий по списку ID или фильтру пачками
+func (h *AdminHandler) DeleteSessions(c *gin.Context) {
+	var req DeleteSessionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if middleware.IsBodyTooLarge(err) {
+			middleware.BodyTooLargeResponse(c, err)
+			return
+		}
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request format",
+			Code:    "INVALID_REQUEST",
+			Details: err.Error(),
+			Fields:  validationFields(err, &req),
+		})
+		return
+	}
+
+	filter, fields := req.sessionFilter()
+	if len(fields) > 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Code:    "VALIDATION_ERROR",
+			Details: fields[0].Message,
+			Fields:  fields,
+		})
+		return
+	}
+
+	requested := len(filter.IDs)
+	if filter.IDs == nil {
+		requested = -1
+	}
+
+	ctx := c.Request.Context()
+	ids, err := h.sessions.ListSessionIDs(ctx, filter)
+	if err != nil {
+		h.logger.Error("Failed to select sessions for deletion", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to select sessions",
+			Code:    "SESSION_LIST_ERROR",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	response := DeleteSessionsResponse{
+		DryRun:    req.DryRun,
+		Matched:   len(ids),
+		RequestID: middleware.GetRequestID(c),
+	}
+
+	if req.DryRun {
+		response.SessionIDs = ids
+	} else {
+		response.Deleted, response.FailedSessionIDs = h.deleteSessionsInBatches(ctx, ids)
+		response.Failed = len(response.FailedSessionIDs)
+	}
+
+	// Для явного списка отсутствующими считаются и сессии, удалённые параллельно с запросом
+	if requested >= 0 {
+		if req.DryRun {
+			response.NotFound = requested - response.Matched
+		} else {
+			response.NotFound = requested - response.Deleted - response.Failed
+		}
+	} else if !req.DryRun {
+		response.NotFound = response.Matched - response.Deleted - response.Failed
+	}
+
+	h.logger.Info("Admin audit: bulk session deletion",
+		zap.String("audit_action", "sessions.delete"),
+		zap.String("request_id", response.RequestID),
+		zap.String("client_ip", c.ClientIP()),
+		zap.String("user_agent", c.Request.UserAgent()),
+		zap.Bool("dry_run", req.DryRun),
+		zap.Int("requested_ids", len(req.SessionIDs)),
+		zap.Any("filter", req.Filter),
+		zap.Int("matched", response.Matched),
+		zap.Int("deleted", response.Deleted),
+		zap.Int("not_found", response.NotFound),
+		zap.Int("failed", response.Failed),
+	)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// sessionFilter проверяет запрос массового удаления и переводит его в фильтр хранилища.
+// Явный список ID очищается от пустых значений и повторов.
+func (req DeleteSessionsRequest) sessionFilter() (models.SessionFilter, []FieldError) {
+	var fields []FieldError
+	var filter models.SessionFilter
+
+	switch {
+	case len(req.SessionIDs) > 0 && req.Filter != nil:
+		return filter, []FieldError{{
+			Field:   "filter",
+			Code:    "invalid",
+			Message: "specify either session_ids or filter, not both",
+		}}
+	case len(req.SessionIDs) > 0:
+		seen := make(map[string]bool, len(req.SessionIDs))
+		filter.IDs = make([]string, 0, len(req.SessionIDs))
+		for _, id := range req.SessionIDs {
+			id = strings.TrimSpace(id)
+			if id == "" || seen[id] {
+				continue
+			}
+			seen[id] = true
+			filter.IDs = append(filter.IDs, id)
+		}
+		if len(filter.IDs) == 0 {
+			fields = append(fields, FieldError{
+				Field:   "session_ids",
+				Code:    "required",
+				Message: "session_ids must contain at least one non-empty ID",
+			})
+		}
+		return filter, fields
+	case req.Filter == nil:
+		return filter, []FieldError{{
+			Field:   "session_ids",
+			Code:    "required",
+			Message: "either session_ids or filter is required",
+		}}
+	}
+
+	if req.Filter.OlderThan != "" {
+		olderThan, err := time.Parse(time.RFC3339, req.Filter.OlderThan)
+		if err != nil {
+			fields = append(fields, FieldError{
+				Field:   "filter.older_than",
+				Code:    "invalid",
+				Message: fmt.Sprintf("filter.older_than must be an RFC 3339 timestamp, got %q", req.Filter.OlderThan),
+			})
+		}
+		filter.UpdatedBefore = olderThan
+	}
+	filter.UserID = strings.TrimSpace(req.Filter.UserID)
+
+	// Сессии не хранят заголовков, поэтому фильтр по ним не поддерживается
+	if req.Filter.UntitledOnly {
+		fields = append(fields, FieldError{
+			Field:   "filter.untitled_only",
+			Code:    "unsupported",
+			Message: "sessions have no titles; filter.untitled_only is not supported",
+		})
+	}
+
+	// Пустой фильтр выбрал бы все сессии
+	if len(fields) == 0 && filter.UpdatedBefore.IsZero() && filter.UserID == "" {
+		fields = append(fields, FieldError{
+			Field:   "filter",
+			Code:    "required",
+			Message: "filter must set older_than or user_id",
+		})
+	}
+
+	return filter, fields
+}
+
+// deleteSessionsInBatches удаляет сессии пачками по admin.delete_batch_size, каждая пачка
+// в своей транзакции. Ошибка пачки не останавливает удаление остальных.
+func (h *AdminHandler) deleteSessionsInBatches(ctx context.Context, ids []string) (int, []string) {
+	deleted := 0
+	var failed []string
+
+	for start := 0; start < len(ids); start += h.deleteBatchSize {
+		end := start + h.deleteBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := ids[start:end]
+
+		n, err := h.sessions.DeleteSessions(ctx, batch)
+		if err != nil {
+			h.logger.Error("Failed to delete session batch",
+				zap.Int("batch_start", start),
+				zap.Int("batch_size", len(batch)),
+				zap.Error(err),
+			)
+			failed = append(failed, batch...)
+			continue
+		}
+		deleted += n
+	}
+
+	return deleted, failed
+}
+
+// ReinitializeResult результат переинициализации одного LLM клиента
+type ReinitializeResult struct {
+	Client     string `json:"client"`
+	Success    bool   `json:"success"`
+	ToolsCount int    `json:"tools_count"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+	Retryable  bool   `json:"retryable,omitempty"`
+}
+
+type ReinitializeResponse struct {
+	Success   bool                 `json:"success"`
+	Results   []ReinitializeResult `json:"results"`
+	RequestID string               `json:"request_id,omitempty"`
+}
+
+// POST /admin/llm/reinitialize - пересоздание MCP сессии и клиента Gemini без перезапуска сервера
+func (h *AdminHandler) ReinitializeLLM(c *gin.Context) {
+	requestID := middleware.GetRequestID(c)
+	h.logger.Info("LLM reinitialization requested",
+		zap.String("request_id", requestID),
+		zap.String("client_ip", c.ClientIP()),
+	)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.reinitializeTimeout)
+	defer cancel()
+
+	names := make([]string, 0, len(h.clients))
+	for name := range h.clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	response := ReinitializeResponse{
+		Success:   true,
+		Results:   make([]ReinitializeResult, 0, len(names)),
+		RequestID: requestID,
+	}
+
+	for _, name := range names {
+		result := h.reinitialize(ctx, name, h.clients[name])
+		if !result.Success {
+			response.Success = false
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	if h.mcpHandler != nil {
+		h.mcpHandler.InvalidateCache()
+	}
+
+	code := http.StatusOK
+	if !response.Success {
+		code = http.StatusBadGateway
+	}
+
+	c.JSON(code, response)
+}
+
+func (h *AdminHandler) reinitialize(ctx context.Context, name string, client llm.Reinitializer) ReinitializeResult {
+	start := time.Now()
+	toolsCount, err := client.Reinitialize(ctx)
+
+	result := ReinitializeResult{
+		Client:     name,
+		Success:    err == nil,
+		ToolsCount: toolsCount,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+
+	if err != nil {
+		result.Error = err.Error()
+		result.Retryable = errors.Is(err, llm.ErrProviderReinitializing) || errors.Is(err, context.DeadlineExceeded)
+
+		h.logger.Error("LLM client reinitialization failed",
+			zap.String("client", name),
+			zap.Duration("duration", time.Since(start)),
+			zap.Error(err),
+		)
+		return result
+	}
+
+	h.logger.Info("LLM client reinitialized",
+		zap.String("client", name),
+		zap.Int("tools_count", toolsCount),
+		zap.Duration("duration", time.Since(start)),
+	)
+	return result
+}
@@ -1,14 +1,22 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"LLM_Chat/internal/api/middleware"
+	"LLM_Chat/internal/api/pagination"
+	"LLM_Chat/internal/language"
 	"LLM_Chat/internal/service/chat"
 	contextmgr "LLM_Chat/internal/service/context"
+	"LLM_Chat/internal/service/summary"
 	"LLM_Chat/internal/storage/interfaces"
 	"LLM_Chat/internal/storage/models"
+	"LLM_Chat/pkg/llm"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -16,17 +24,20 @@ import (
 
 type ChatHandler struct {
 	chatService  chat.ChatService
+	streams      *chat.StreamRegistry
 	sessionStore interfaces.SessionStore
 	logger       *zap.Logger
 }
 
 func NewChatHandler(
 	chatService chat.ChatService,
+	streams *chat.StreamRegistry,
 	sessionStore interfaces.SessionStore,
 	logger *zap.Logger,
 ) *ChatHandler {
 	return &ChatHandler{
 		chatService:  chatService,
+		streams:      streams,
 		sessionStore: sessionStore,
 		logger:       logger,
 	}
@@ -37,6 +48,50 @@ type ChatRequest struct {
 	Message   string `json:"message" binding:"required"`
 	Stream    bool   `json:"stream,omitempty"`
 	UserID    string `json:"user_id,omitempty"`
+	Model     string `json:"model,omitempty"` // переопределяет модель пользователя и сервера
+
+	// Seed зерно для воспроизводимой генерации; провайдеры без поддержки seed
+	// применяют жадное декодирование и возвращают предупреждение в generation.warnings
+	Seed          *int64 `json:"seed,omitempty"`
+	Deterministic bool   `json:"deterministic,omitempty"` // жадное декодирование (temperature 0, top_k 1)
+
+	// MaxIterations лимит итераций цикла вызова инструментов, не больше chat.max_iterations_limit
+	MaxIterations int `json:"max_iterations,omitempty"`
+
+	// MaxOutputTokens лимит длины ответа в токенах, не больше предела модели и chat.max_output_tokens_limit
+	MaxOutputTokens int `json:"max_output_tokens,omitempty"`
+
+	// Stop до 4 стоп-последовательностей; генерация останавливается перед ними, в ответ они не входят
+	Stop []string `json:"stop,omitempty"`
+
+	// AssistantPrefix начало ответа (prefill): модель продолжает этот текст, ответ содержит префикс и продолжение
+	AssistantPrefix string `json:"assistant_prefix,omitempty"`
+
+	// Grounding переопределяет настройку поиска Google (grounding.google_search) для запроса
+	Grounding *GroundingOptions `json:"grounding,omitempty"`
+
+	// ToolMode режим вызова инструментов MCP: auto, any (первый ход обязан вызвать инструмент) или none
+	ToolMode string `json:"tool_mode,omitempty"`
+
+	// ResourceURIs ресурсы MCP (GET /mcp/resources), текст которых добавляется в контекст запроса
+	ResourceURIs []string `json:"resource_uris,omitempty"`
+
+	// PromptName промпт MCP сервера (GET /mcp/prompts) вместо системного промпта по умолчанию
+	PromptName string            `json:"prompt_name,omitempty"`
+	PromptArgs map[string]string `json:"prompt_args,omitempty"`
+}
+
+// GroundingOptions параметры опоры ответа на поиск Google
+type GroundingOptions struct {
+	GoogleSearch *bool `json:"google_search,omitempty"`
+}
+
+// googleSearch флаг поиска из запроса; nil - настройка сервера
+func (r ChatRequest) googleSearch() *bool {
+	if r.Grounding == nil {
+		return nil
+	}
+	return r.Grounding.GoogleSearch
 }
 
 type ChatResponse struct {
@@ -48,12 +103,28 @@ type ChatResponse struct {
 	ProcessingTime string                `json:"processing_time"`
 	Cost           float64               `json:"cost,omitempty"`
 	ContextInfo    *chat.ContextMetadata `json:"context_info,omitempty"`
+
+	Generation *models.GenerationParams `json:"generation,omitempty"`
+
+	// Grounding источники поиска Google для отображения ссылок в интерфейсе
+	Grounding *models.Grounding `json:"grounding,omitempty"`
+
+	// FinishReason "max_tokens" или "stop_sequence", если генерация остановлена досрочно
+	FinishReason string `json:"finish_reason,omitempty"`
+	Iterations   int    `json:"iterations,omitempty"`
+
+	// ToolCalls вызовы MCP инструментов, выполненные для ответа, в порядке их начала
+	ToolCalls []chat.ToolCallSummary `json:"tool_calls,omitempty"`
 }
 
+// HistoryResponse страница истории в общем конверте пагинации
 type HistoryResponse struct {
-	SessionID string           `json:"session_id"`
-	Messages  []models.Message `json:"messages"`
-	Total     int              `json:"total"`
+	SessionID string `json:"session_id"`
+	pagination.Page[models.Message]
+
+	// Messages дублирует items для клиентов старого формата ответа.
+	// Deprecated: используйте items.
+	Messages []models.Message `json:"messages"`
 }
 
 type SessionResponse struct {
@@ -62,36 +133,71 @@ type SessionResponse struct {
 	ContextInfo *contextmgr.ContextInfo `json:"context_info,omitempty"`
 }
 
+// SessionLanguageRequest тело PUT /chat/:session_id/language
+type SessionLanguageRequest struct {
+	Language string `json:"language"` // "auto", код языка или пустое значение для языка из конфигурации
+}
+
+// SessionLanguageResponse настройка языка сессии после изменения
+type SessionLanguageResponse struct {
+	SessionID string `json:"session_id"`
+	Language  string `json:"language"` // действующая настройка с учётом значения по умолчанию
+}
+
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Code    string `json:"code,omitempty"`
-	Details string `json:"details,omitempty"`
+	Error   string       `json:"error"`
+	Code    string       `json:"code,omitempty"`
+	Details string       `json:"details,omitempty"`
+	Fields  []FieldError `json:"fields,omitempty"`
+
+	// RequestID идентификатор запроса (X-Request-ID); заполняется RecoveryMiddleware для INTERNAL_PANIC
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // POST /chat - основной эндпоинт для отправки сообщений
 func (h *ChatHandler) SendMessage(c *gin.Context) {
+	// Во время остановки сервера новые сообщения не принимаются
+	if h.streams.Draining() {
+		h.shuttingDownResponse(c)
+		return
+	}
+
 	var req ChatRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
+		if middleware.IsBodyTooLarge(err) {
+			middleware.BodyTooLargeResponse(c, err)
+			return
+		}
 		h.logger.Error("Invalid request", zap.Error(err))
 		c.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "Invalid request format",
 			Code:    "INVALID_REQUEST",
 			Details: err.Error(),
+			Fields:  validationFields(err, &req),
 		})
 		return
 	}
 
 	// Валидация запроса
 	if err := chat.ValidateProcessMessageRequest(chat.ProcessMessageRequest{
-		SessionID: req.SessionID,
-		Message:   req.Message,
-		UserID:    req.UserID,
+		SessionID:       req.SessionID,
+		Message:         req.Message,
+		UserID:          req.UserID,
+		MaxIterations:   req.MaxIterations,
+		MaxOutputTokens: req.MaxOutputTokens,
+		Stop:            req.Stop,
+		ToolMode:        req.ToolMode,
+		AssistantPrefix: req.AssistantPrefix,
+		ResourceURIs:    req.ResourceURIs,
+		PromptName:      req.PromptName,
+		PromptArgs:      req.PromptArgs,
 	}); err != nil {
 		h.logger.Error("Request validation failed", zap.Error(err))
 		c.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "Validation failed",
 			Code:    "VALIDATION_ERROR",
 			Details: err.Error(),
+			Fields:  validationFields(err, &req),
 		})
 		return
 	}
@@ -107,12 +213,30 @@ func (h *ChatHandler) SendMessage(c *gin.Context) {
 
 func (h *ChatHandler) handleRegularMessage(c *gin.Context, req ChatRequest) {
 	serviceReq := chat.ProcessMessageRequest{
-		SessionID: req.SessionID,
-		Message:   req.Message,
-		UserID:    req.UserID,
+		SessionID:       req.SessionID,
+		Message:         req.Message,
+		UserID:          req.UserID,
+		Model:           req.Model,
+		Seed:            req.Seed,
+		Deterministic:   req.Deterministic,
+		MaxIterations:   req.MaxIterations,
+		MaxOutputTokens: req.MaxOutputTokens,
+		Stop:            req.Stop,
+		GoogleSearch:    req.googleSearch(),
+		ToolMode:        req.ToolMode,
+		AssistantPrefix: req.AssistantPrefix,
+		ResourceURIs:    req.ResourceURIs,
+		PromptName:      req.PromptName,
+		PromptArgs:      req.PromptArgs,
 	}
 
 	resp, err := h.chatService.ProcessMessage(c.Request.Context(), serviceReq)
+	if h.requestValidationFailed(c, err, &req) {
+		return
+	}
+	if h.contentBlocked(c, err, req.SessionID) || h.toolLoopLimit(c, err) {
+		return
+	}
 	if err != nil {
 		h.logger.Error("Failed to process message",
 			zap.Error(err),
@@ -122,12 +246,40 @@ func (h *ChatHandler) handleRegularMessage(c *gin.Context, req ChatRequest) {
 		statusCode := http.StatusInternalServerError
 		errorCode := "PROCESSING_ERROR"
 
-		if strings.Contains(err.Error(), "context") {
-			statusCode = http.StatusRequestTimeout
-			errorCode = "TIMEOUT"
-		} else if strings.Contains(err.Error(), "API") {
+		var circuitErr *llm.CircuitOpenError
+		switch {
+		case errors.As(err, &circuitErr):
+			statusCode = http.StatusServiceUnavailable
+			errorCode = "LLM_CIRCUIT_OPEN"
+			c.Header("Retry-After", strconv.Itoa(circuitErr.RetryAfterSeconds()))
+		case errors.Is(err, llm.ErrProviderReinitializing):
+			statusCode = http.StatusServiceUnavailable
+			errorCode = "LLM_REINITIALIZING"
+			c.Header("Retry-After", "5")
+		case errors.Is(err, chat.ErrResourceUnavailable):
+			statusCode = http.StatusBadGateway
+			errorCode = "RESOURCE_UNAVAILABLE"
+		case errors.Is(err, chat.ErrPromptUnavailable):
+			statusCode = http.StatusBadGateway
+			errorCode = "PROMPT_UNAVAILABLE"
+		case errors.Is(err, llm.ErrMCPUnavailable):
+			statusCode = http.StatusServiceUnavailable
+			errorCode = "MCP_UNAVAILABLE"
+		case errors.Is(err, llm.ErrRateLimited):
+			statusCode = http.StatusTooManyRequests
+			errorCode = "RATE_LIMITED"
+		case errors.Is(err, llm.ErrAPIKeyNotSet), errors.Is(err, llm.ErrUnauthorized):
+			statusCode = http.StatusUnauthorized
+			errorCode = "LLM_UNAUTHORIZED"
+		case errors.Is(err, llm.ErrInsufficientCredits):
+			statusCode = http.StatusBadGateway
+			errorCode = "INSUFFICIENT_CREDITS"
+		case errors.Is(err, llm.ErrUpstream):
 			statusCode = http.StatusBadGateway
 			errorCode = "LLM_API_ERROR"
+		case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+			statusCode = http.StatusRequestTimeout
+			errorCode = "TIMEOUT"
 		}
 
 		c.JSON(statusCode, ErrorResponse{
@@ -154,34 +306,142 @@ func (h *ChatHandler) handleRegularMessage(c *gin.Context, req ChatRequest) {
 		Model:          resp.Model,
 		ProcessingTime: resp.ProcessingTime.String(),
 		ContextInfo:    resp.ContextInfo,
+		Generation:     resp.Generation,
+		Grounding:      resp.Grounding,
+		FinishReason:   resp.FinishReason,
+		Iterations:     resp.Iterations,
+		ToolCalls:      resp.ToolCalls,
 	})
 }
 
 func (h *ChatHandler) handleStreamingMessage(c *gin.Context, req ChatRequest) {
-	// Настройка Server-Sent Events
-	c.Header("Content-Type", "text/event-stream")
-	c.Header("Cache-Control", "no-cache")
-	c.Header("Connection", "keep-alive")
-	c.Header("Access-Control-Allow-Origin", "*")
-	c.Header("Access-Control-Allow-Headers", "Cache-Control")
+	// Поток может длиться дольше таймаута группы - клиент получает события по мере готовности
+	middleware.DisableTimeout(c)
 
 	serviceReq := chat.ProcessMessageRequest{
-		SessionID: req.SessionID,
-		Message:   req.Message,
-		UserID:    req.UserID,
+		SessionID:       req.SessionID,
+		Message:         req.Message,
+		UserID:          req.UserID,
+		Model:           req.Model,
+		Seed:            req.Seed,
+		Deterministic:   req.Deterministic,
+		MaxIterations:   req.MaxIterations,
+		MaxOutputTokens: req.MaxOutputTokens,
+		Stop:            req.Stop,
+		GoogleSearch:    req.googleSearch(),
+		ToolMode:        req.ToolMode,
+		AssistantPrefix: req.AssistantPrefix,
+		ResourceURIs:    req.ResourceURIs,
+		PromptName:      req.PromptName,
+		PromptArgs:      req.PromptArgs,
 	}
 
 	streamCh, err := h.chatService.ProcessMessageStream(c.Request.Context(), serviceReq)
+	if errors.Is(err, chat.ErrShuttingDown) {
+		h.shuttingDownResponse(c)
+		return
+	}
+	if h.requestValidationFailed(c, err, &req) {
+		return
+	}
+
+	// Настройка Server-Sent Events
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+
 	if err != nil {
 		h.logger.Error("Failed to start streaming", zap.Error(err))
 		h.writeSSEError(c, "Failed to start streaming", err.Error())
 		return
 	}
 
-	// Обрабатываем поток
+	h.writeStream(c, req.SessionID, streamCh)
+}
+
+// GET /chat/:session_id/stream/:message_id - возобновление потокового ответа после разрыва соединения.
+// Повторно отправляет события начиная с from_seq (или следующее за Last-Event-ID)
+// и продолжает поток, если генерация ещё идёт.
+func (h *ChatHandler) ResumeStream(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	messageID := c.Param("message_id")
+
+	fromSeq, ok := parseResumePosition(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid resume position",
+			Code:    "INVALID_FROM_SEQ",
+			Details: "from_seq and Last-Event-ID must be non-negative integers",
+		})
+		return
+	}
+
+	// Возобновлённый поток может длиться дольше таймаута группы
+	middleware.DisableTimeout(c)
+
+	streamCh, err := h.chatService.ResumeStream(c.Request.Context(), sessionID, messageID, fromSeq)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		errorCode := "RESUME_ERROR"
+
+		switch {
+		case errors.Is(err, chat.ErrStreamNotFound):
+			statusCode = http.StatusNotFound
+			errorCode = "STREAM_NOT_FOUND"
+		case errors.Is(err, chat.ErrStreamEventsExpired):
+			statusCode = http.StatusGone
+			errorCode = "STREAM_EVENTS_EXPIRED"
+		}
+
+		c.JSON(statusCode, ErrorResponse{
+			Error:   "Failed to resume stream",
+			Code:    errorCode,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+
+	h.writeStream(c, sessionID, streamCh)
+}
+
+// parseResumePosition определяет первый номер события для повторной отправки.
+// Last-Event-ID (номер последнего полученного события) имеет приоритет над from_seq.
+func parseResumePosition(c *gin.Context) (uint64, bool) {
+	if lastEventID := c.GetHeader("Last-Event-ID"); lastEventID != "" {
+		seq, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		return seq + 1, true
+	}
+
+	if fromSeq := c.Query("from_seq"); fromSeq != "" {
+		seq, err := strconv.ParseUint(fromSeq, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		return seq, true
+	}
+
+	return 0, true
+}
+
+// writeStream передаёт события потока клиенту в формате SSE до финального события
+func (h *ChatHandler) writeStream(c *gin.Context, sessionID string, streamCh <-chan chat.StreamResponse) {
 	var contextInfoSent bool
 	for streamResp := range streamCh {
+		h.writeSSEID(c, streamResp.Seq)
+
 		if streamResp.Error != nil {
+			if event, ok := typedStreamError(streamResp.Error); ok {
+				h.logger.Warn("Stream finished without answer", zap.Error(streamResp.Error))
+				h.writeSSEEvent(c, "error", event)
+				return
+			}
 			h.logger.Error("Stream error", zap.Error(streamResp.Error))
 			h.writeSSEError(c, "Stream error", streamResp.Error.Error())
 			return
@@ -190,13 +450,28 @@ func (h *ChatHandler) handleStreamingMessage(c *gin.Context, req ChatRequest) {
 		// Отправляем контекстную информацию в начале (только один раз)
 		if streamResp.ContextInfo != nil && !contextInfoSent {
 			h.writeSSEEvent(c, "context", map[string]interface{}{
-				"session_id":   req.SessionID,
+				"session_id":   sessionID,
 				"message_id":   streamResp.MessageID,
+				"model":        streamResp.Model,
 				"context_info": streamResp.ContextInfo,
 			})
 			contextInfoSent = true
 		}
 
+		if streamResp.ToolCall != nil {
+			h.writeSSEEvent(c, "tool_call", map[string]interface{}{
+				"message_id": streamResp.MessageID,
+				"tool_call":  streamResp.ToolCall,
+			})
+		}
+
+		if streamResp.ToolProgress != nil {
+			h.writeSSEEvent(c, "tool_progress", map[string]interface{}{
+				"message_id":    streamResp.MessageID,
+				"tool_progress": streamResp.ToolProgress,
+			})
+		}
+
 		if streamResp.Content != "" {
 			h.writeSSEEvent(c, "content", map[string]interface{}{
 				"content":    streamResp.Content,
@@ -205,9 +480,25 @@ func (h *ChatHandler) handleStreamingMessage(c *gin.Context, req ChatRequest) {
 		}
 
 		if streamResp.Done {
-			h.writeSSEEvent(c, "done", map[string]interface{}{
+			doneEvent := map[string]interface{}{
 				"message_id": streamResp.MessageID,
-			})
+			}
+			if streamResp.FinishReason != "" {
+				doneEvent["finish_reason"] = streamResp.FinishReason
+			}
+			if streamResp.Iterations > 0 {
+				doneEvent["iterations"] = streamResp.Iterations
+			}
+			if streamResp.Generation != nil {
+				doneEvent["generation"] = streamResp.Generation
+			}
+			if streamResp.Grounding != nil {
+				doneEvent["grounding"] = streamResp.Grounding
+			}
+			if len(streamResp.ToolCalls) > 0 {
+				doneEvent["tool_calls"] = streamResp.ToolCalls
+			}
+			h.writeSSEEvent(c, "done", doneEvent)
 			return
 		}
 
@@ -218,6 +509,177 @@ func (h *ChatHandler) handleStreamingMessage(c *gin.Context, req ChatRequest) {
 	}
 }
 
+// requestValidationFailed отвечает 400, если сервис отклонил параметры запроса,
+// которые проверяются по конфигурации (модель, max_iterations)
+func (h *ChatHandler) requestValidationFailed(c *gin.Context, err error, req *ChatRequest) bool {
+	var validationErrs chat.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return false
+	}
+
+	h.logger.Warn("Request rejected by service validation",
+		zap.String("session_id", req.SessionID),
+		zap.String("model", req.Model),
+		zap.Int("max_iterations", req.MaxIterations),
+		zap.Error(err),
+	)
+	response := ErrorResponse{
+		Error:   "Validation failed",
+		Code:    "VALIDATION_ERROR",
+		Details: err.Error(),
+		Fields:  validationFields(err, req),
+	}
+
+	// Клиент получает схему аргументов промпта, чтобы запросить недостающие
+	var argsErr *llm.PromptArgumentsError
+	if errors.As(err, &argsErr) {
+		c.JSON(http.StatusBadRequest, PromptArgumentsErrorResponse{
+			ErrorResponse: response,
+			Prompt:        argsErr.Prompt,
+			Arguments:     argsErr.Arguments,
+		})
+		return true
+	}
+
+	c.JSON(http.StatusBadRequest, response)
+	return true
+}
+
+// PromptArgumentsErrorResponse ошибка валидации с полной схемой аргументов выбранного промпта MCP
+type PromptArgumentsErrorResponse struct {
+	ErrorResponse
+	Prompt    string               `json:"prompt"`
+	Arguments []llm.PromptArgument `json:"arguments"`
+}
+
+// contentBlockedCode код ошибки блокировки фильтрами безопасности модели
+const contentBlockedCode = "CONTENT_BLOCKED"
+
+// ContentBlockedErrorResponse запрос или ответ заблокирован фильтрами безопасности модели
+type ContentBlockedErrorResponse struct {
+	ErrorResponse
+	BlockedSource     string   `json:"blocked_source"` // prompt или response
+	BlockReason       string   `json:"block_reason"`
+	BlockedCategories []string `json:"blocked_categories"`
+}
+
+// contentBlocked отвечает 422, если модель заблокировала запрос или ответ (настройка llm.safety)
+func (h *ChatHandler) contentBlocked(c *gin.Context, err error, sessionID string) bool {
+	var blocked *llm.ContentBlockedError
+	if !errors.As(err, &blocked) {
+		return false
+	}
+
+	h.logger.Warn("Message blocked by safety filters",
+		zap.String("session_id", sessionID),
+		zap.Error(err),
+	)
+	categories := blocked.Categories
+	if categories == nil {
+		categories = []string{}
+	}
+	c.JSON(http.StatusUnprocessableEntity, ContentBlockedErrorResponse{
+		ErrorResponse: ErrorResponse{
+			Error:   "Content blocked by safety filters",
+			Code:    contentBlockedCode,
+			Details: err.Error(),
+		},
+		BlockedSource:     blocked.Source,
+		BlockReason:       blocked.Reason,
+		BlockedCategories: categories,
+	})
+	return true
+}
+
+// toolLoopLimitCode код ошибки: модель не дала ответа за допустимое число итераций цикла инструментов
+const toolLoopLimitCode = "TOOL_LOOP_LIMIT"
+
+// ToolLoopLimitErrorResponse цикл вызова инструментов исчерпал лимит итераций без финального ответа
+type ToolLoopLimitErrorResponse struct {
+	ErrorResponse
+	Iterations int                    `json:"iterations"`
+	ToolCalls  []chat.ToolCallSummary `json:"tool_calls"`
+}
+
+// toolLoopLimit отвечает 502, если модель не дала финального ответа за лимит итераций.
+// Ответ ассистента при этом не сохраняется.
+func (h *ChatHandler) toolLoopLimit(c *gin.Context, err error) bool {
+	var limitErr *chat.ToolLoopLimitError
+	if !errors.As(err, &limitErr) {
+		return false
+	}
+
+	toolCalls := limitErr.ToolCalls
+	if toolCalls == nil {
+		toolCalls = []chat.ToolCallSummary{}
+	}
+	c.JSON(http.StatusBadGateway, ToolLoopLimitErrorResponse{
+		ErrorResponse: ErrorResponse{
+			Error:   "Model did not produce an answer within the tool iteration limit",
+			Code:    toolLoopLimitCode,
+			Details: err.Error(),
+		},
+		Iterations: limitErr.Iterations,
+		ToolCalls:  toolCalls,
+	})
+	return true
+}
+
+// typedStreamError данные события error для ошибок, у которых есть код и подробности
+// (блокировка фильтрами безопасности, лимит итераций цикла инструментов, разомкнутый выключатель)
+func typedStreamError(err error) (map[string]interface{}, bool) {
+	var circuitErr *llm.CircuitOpenError
+	if errors.As(err, &circuitErr) {
+		return map[string]interface{}{
+			"error":       "LLM is temporarily unavailable",
+			"details":     err.Error(),
+			"code":        "LLM_CIRCUIT_OPEN",
+			"retry_after": circuitErr.RetryAfterSeconds(),
+		}, true
+	}
+
+	var blocked *llm.ContentBlockedError
+	if errors.As(err, &blocked) {
+		return map[string]interface{}{
+			"error":              "Content blocked by safety filters",
+			"details":            err.Error(),
+			"code":               contentBlockedCode,
+			"blocked_source":     blocked.Source,
+			"block_reason":       blocked.Reason,
+			"blocked_categories": blocked.Categories,
+		}, true
+	}
+
+	var limitErr *chat.ToolLoopLimitError
+	if errors.As(err, &limitErr) {
+		return map[string]interface{}{
+			"error":      "Model did not produce an answer within the tool iteration limit",
+			"details":    err.Error(),
+			"code":       toolLoopLimitCode,
+			"iterations": limitErr.Iterations,
+			"tool_calls": limitErr.ToolCalls,
+		}, true
+	}
+	return nil, false
+}
+
+func (h *ChatHandler) shuttingDownResponse(c *gin.Context) {
+	c.Header("Retry-After", "5")
+	c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+		Error:   "Server is shutting down",
+		Code:    "SHUTTING_DOWN",
+		Details: "new messages are not accepted during shutdown, retry later",
+	})
+}
+
+// writeSSEID задаёт id следующего события; клиент вернёт его в Last-Event-ID при переподключении
+func (h *ChatHandler) writeSSEID(c *gin.Context, seq uint64) {
+	if seq == 0 {
+		return
+	}
+	c.Writer.WriteString("id: " + strconv.FormatUint(seq, 10) + "\n")
+}
+
 func (h *ChatHandler) writeSSEEvent(c *gin.Context, eventType string, data interface{}) {
 	c.SSEvent(eventType, data)
 }
@@ -240,16 +702,16 @@ func (h *ChatHandler) GetHistory(c *gin.Context) {
 		return
 	}
 
-	limitStr := c.DefaultQuery("limit", "50")
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit < 0 {
-		limit = 50
+	params, ok := parsePagination(c, pagination.DefaultOptions)
+	if !ok {
+		return
 	}
-	if limit > 200 {
-		limit = 200
+	includeTools, ok := parseBoolQuery(c, "include_tools")
+	if !ok {
+		return
 	}
 
-	messages, err := h.chatService.GetHistory(c.Request.Context(), sessionID, limit)
+	messages, total, err := h.chatService.GetHistory(c.Request.Context(), sessionID, params.Limit, params.Offset, includeTools)
 	if err != nil {
 		h.logger.Error("Failed to get messages",
 			zap.Error(err),
@@ -263,13 +725,48 @@ func (h *ChatHandler) GetHistory(c *gin.Context) {
 		return
 	}
 
+	page := pagination.NewPage(messages, total, params)
 	c.JSON(http.StatusOK, HistoryResponse{
 		SessionID: sessionID,
-		Messages:  messages,
-		Total:     len(messages),
+		Page:      page,
+		Messages:  page.Items,
 	})
 }
 
+// parsePagination разбирает параметры пагинации и отвечает 400 при ошибке
+func parsePagination(c *gin.Context, opts pagination.Options) (pagination.Params, bool) {
+	params, err := pagination.Parse(c, opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid pagination parameters",
+			Code:    "INVALID_PAGINATION",
+			Details: err.Error(),
+		})
+		return pagination.Params{}, false
+	}
+
+	return params, true
+}
+
+// parseBoolQuery читает необязательный булев query-параметр; при неверном значении отвечает 400
+func parseBoolQuery(c *gin.Context, name string) (bool, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return false, true
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   fmt.Sprintf("Invalid %s parameter", name),
+			Code:    "INVALID_REQUEST",
+			Details: fmt.Sprintf("%s must be a boolean, got %q", name, value),
+		})
+		return false, false
+	}
+	return parsed, true
+}
+
 // GET /chat/:session_id - получение информации о сессии
 func (h *ChatHandler) GetSession(c *gin.Context) {
 	sessionID := c.Param("session_id")
@@ -339,7 +836,7 @@ func (h *ChatHandler) GetContextInfo(c *gin.Context) {
 	c.JSON(http.StatusOK, contextInfo)
 }
 
-// POST /chat/:session_id/compress - принудительное сжатие контекста
+// POST /chat/:session_id/compress - проверка порогов и сжатие контекста; ?force=true сжимает без порогов
 func (h *ChatHandler) TriggerCompression(c *gin.Context) {
 	sessionID := c.Param("session_id")
 	if sessionID == "" {
@@ -350,7 +847,12 @@ func (h *ChatHandler) TriggerCompression(c *gin.Context) {
 		return
 	}
 
-	result, err := h.chatService.TriggerCompression(c.Request.Context(), sessionID)
+	force, ok := parseBoolQuery(c, "force")
+	if !ok {
+		return
+	}
+
+	result, err := h.chatService.TriggerCompression(c.Request.Context(), sessionID, force)
 	if err != nil {
 		h.logger.Error("Failed to trigger compression",
 			zap.Error(err),
@@ -366,6 +868,7 @@ func (h *ChatHandler) TriggerCompression(c *gin.Context) {
 
 	h.logger.Info("Compression triggered manually",
 		zap.String("session_id", sessionID),
+		zap.Bool("force", force),
 		zap.Bool("triggered", result.Triggered),
 		zap.Int("messages_compressed", result.MessagesCompressed),
 	)
@@ -376,6 +879,252 @@ func (h *ChatHandler) TriggerCompression(c *gin.Context) {
 	})
 }
 
+// POST /chat/:session_id/clone - копия сессии со всеми сообщениями и резюме
+func (h *ChatHandler) CloneSession(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "session_id is required",
+			Code:  "MISSING_SESSION_ID",
+		})
+		return
+	}
+
+	result, err := h.chatService.CloneSession(c.Request.Context(), sessionID)
+	if err != nil {
+		h.logger.Error("Failed to clone session",
+			zap.Error(err),
+			zap.String("session_id", sessionID),
+		)
+
+		if errors.Is(err, interfaces.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error: "Session not found",
+				Code:  "SESSION_NOT_FOUND",
+			})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to clone session",
+			Code:    "CLONE_ERROR",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, result)
+}
+
+// RangeSummaryRequest запрос на сжатие диапазона сообщений
+type RangeSummaryRequest struct {
+	FromMessageID string `json:"from_message_id" binding:"required"`
+	ToMessageID   string `json:"to_message_id" binding:"required"`
+	Level         int    `json:"level,omitempty" binding:"omitempty,oneof=1 2"`
+}
+
+// POST /chat/:session_id/summaries - ручное сжатие диапазона сообщений
+func (h *ChatHandler) CreateRangeSummary(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "session_id is required",
+			Code:  "MISSING_SESSION_ID",
+		})
+		return
+	}
+
+	var req RangeSummaryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if middleware.IsBodyTooLarge(err) {
+			middleware.BodyTooLargeResponse(c, err)
+			return
+		}
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request format",
+			Code:    "INVALID_REQUEST",
+			Details: err.Error(),
+			Fields:  validationFields(err, &req),
+		})
+		return
+	}
+	if req.Level == 0 {
+		req.Level = 1
+	}
+
+	result, err := h.chatService.CreateRangeSummary(c.Request.Context(), sessionID, req.FromMessageID, req.ToMessageID, req.Level)
+	if err != nil {
+		h.logger.Error("Failed to create range summary",
+			zap.Error(err),
+			zap.String("session_id", sessionID),
+		)
+
+		statusCode := http.StatusInternalServerError
+		errorCode := "SUMMARY_ERROR"
+
+		switch {
+		case errors.Is(err, interfaces.ErrMessageNotFound):
+			statusCode = http.StatusNotFound
+			errorCode = "MESSAGE_NOT_FOUND"
+		case errors.Is(err, contextmgr.ErrRangeAlreadyCompressed):
+			statusCode = http.StatusConflict
+			errorCode = "RANGE_ALREADY_COMPRESSED"
+		case errors.Is(err, contextmgr.ErrInvalidRange), errors.Is(err, summary.ErrNotEnoughMessages):
+			statusCode = http.StatusBadRequest
+			errorCode = "INVALID_RANGE"
+		}
+
+		c.JSON(statusCode, ErrorResponse{
+			Error:   "Failed to create summary",
+			Code:    errorCode,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	h.logger.Info("Range summary created manually",
+		zap.String("session_id", sessionID),
+		zap.String("summary_id", result.SummaryID),
+		zap.Int("messages_compressed", result.MessagesCompressed),
+	)
+
+	c.JSON(http.StatusCreated, result)
+}
+
+// GET /chat/:session_id/summaries/:summary_id/messages - исходные сообщения или резюме, сжатые в резюме
+func (h *ChatHandler) GetSummarySources(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	summaryID := c.Param("summary_id")
+
+	sources, err := h.chatService.GetSummarySources(c.Request.Context(), sessionID, summaryID)
+	if err != nil {
+		if errors.Is(err, interfaces.ErrSummaryNotFound) {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "Summary not found",
+				Code:    "SUMMARY_NOT_FOUND",
+				Details: err.Error(),
+			})
+			return
+		}
+
+		h.logger.Error("Failed to get summary sources",
+			zap.Error(err),
+			zap.String("session_id", sessionID),
+			zap.String("summary_id", summaryID),
+		)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to get summary sources",
+			Code:    "SUMMARY_ERROR",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, sources)
+}
+
+// POST /chat/:session_id/summaries/:summary_id/expand - отмена сжатия: источники возвращаются в контекст
+func (h *ChatHandler) ExpandSummary(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	summaryID := c.Param("summary_id")
+
+	result, err := h.chatService.ExpandSummary(c.Request.Context(), sessionID, summaryID)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		errorCode := "SUMMARY_ERROR"
+
+		switch {
+		case errors.Is(err, interfaces.ErrSummaryNotFound):
+			statusCode = http.StatusNotFound
+			errorCode = "SUMMARY_NOT_FOUND"
+		case errors.Is(err, interfaces.ErrSummaryCompressed):
+			statusCode = http.StatusConflict
+			errorCode = "SUMMARY_ALREADY_COMPRESSED"
+		case errors.Is(err, interfaces.ErrSourcesPurged):
+			statusCode = http.StatusConflict
+			errorCode = "SOURCES_PURGED"
+		default:
+			h.logger.Error("Failed to expand summary",
+				zap.Error(err),
+				zap.String("session_id", sessionID),
+				zap.String("summary_id", summaryID),
+			)
+		}
+
+		c.JSON(statusCode, ErrorResponse{
+			Error:   "Failed to expand summary",
+			Code:    errorCode,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	h.logger.Info("Summary expanded manually",
+		zap.String("session_id", sessionID),
+		zap.String("summary_id", summaryID),
+		zap.Int("messages_restored", result.MessagesRestored),
+		zap.Int("summaries_restored", result.SummariesRestored),
+	)
+
+	c.JSON(http.StatusOK, result)
+}
+
+// PUT /chat/:session_id/language - настройка языка ответов сессии
+func (h *ChatHandler) SetSessionLanguage(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "session_id is required",
+			Code:  "MISSING_SESSION_ID",
+		})
+		return
+	}
+
+	var req SessionLanguageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if middleware.IsBodyTooLarge(err) {
+			middleware.BodyTooLargeResponse(c, err)
+			return
+		}
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request format",
+			Code:    "INVALID_REQUEST",
+			Details: err.Error(),
+			Fields:  validationFields(err, &req),
+		})
+		return
+	}
+
+	setting := strings.ToLower(strings.TrimSpace(req.Language))
+	if err := h.chatService.SetSessionLanguage(c.Request.Context(), sessionID, setting); err != nil {
+		if errors.Is(err, language.ErrUnsupportedLanguage) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Validation failed",
+				Code:    "VALIDATION_ERROR",
+				Details: err.Error(),
+				Fields:  validationFields(err, &req),
+			})
+			return
+		}
+
+		h.logger.Error("Failed to set session language",
+			zap.Error(err),
+			zap.String("session_id", sessionID),
+		)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to set session language",
+			Code:    "SESSION_ERROR",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SessionLanguageResponse{
+		SessionID: sessionID,
+		Language:  h.chatService.LanguageSetting(c.Request.Context(), sessionID),
+	})
+}
+
 // DELETE /chat/:session_id - удаление сессии
 func (h *ChatHandler) DeleteSession(c *gin.Context) {
 	sessionID := c.Param("session_id")
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"LLM_Chat/internal/config"
+	"LLM_Chat/internal/service/chat"
+	"LLM_Chat/pkg/llm"
+)
+
+// reviewPrompt промпт MCP сервера с обязательным аргументом language
+var reviewPrompt = llm.PromptInfo{
+	Name: "review",
+	Arguments: []llm.PromptArgument{
+		{Name: "language", Description: "Язык кода", Required: true},
+		{Name: "style", Description: "Стиль ревью"},
+	},
+}
+
+// mcpLLM модель с ресурсами и промптом reviewPrompt MCP сервера
+type mcpLLM struct {
+	twoToolsLLM
+	resources map[string]string // URI -> текст
+}
+
+func (c *mcpLLM) ListResources(ctx context.Context, refresh bool) ([]llm.ResourceInfo, error) {
+	infos := make([]llm.ResourceInfo, 0, len(c.resources))
+	for uri := range c.resources {
+		infos = append(infos, llm.ResourceInfo{URI: uri, Name: uri})
+	}
+	return infos, nil
+}
+
+func (c *mcpLLM) ReadResource(ctx context.Context, uri string) (*llm.ResourceContent, error) {
+	text, ok := c.resources[uri]
+	if !ok {
+		return nil, llm.ErrResourceNotFound
+	}
+	return &llm.ResourceContent{URI: uri, Name: uri, Text: text}, nil
+}
+
+func (c *mcpLLM) ListPrompts(ctx context.Context, refresh bool) ([]llm.PromptInfo, error) {
+	return []llm.PromptInfo{reviewPrompt}, nil
+}
+
+func (c *mcpLLM) GetPrompt(ctx context.Context, name string, args map[string]string) (string, error) {
+	if name != reviewPrompt.Name {
+		return "", llm.ErrPromptNotFound
+	}
+	if args["language"] == "" {
+		return "", &llm.PromptArgumentsError{Prompt: name, Missing: []string{"language"}, Arguments: reviewPrompt.Arguments}
+	}
+	return "Review the " + args["language"] + " code", nil
+}
+
+func TestSendMessageResourceTooLarge(t *testing.T) {
+	cfg := testChatConfig()
+	cfg.Resources = config.ResourcesConfig{MaxBytes: 10, CacheTTL: time.Minute}
+	client := &mcpLLM{resources: map[string]string{
+		"docs://small": "short",
+		"docs://large": strings.Repeat("a", 11),
+	}}
+	r, store := newChatRouter(t, client, cfg)
+
+	w := postChat(r, `{"session_id": "s1", "message": "Что в документах?", "resource_uris": ["docs://small", "docs://large"]}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", w.Code, w.Body.String())
+	}
+	var resp ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != "VALIDATION_ERROR" || len(resp.Fields) != 1 ||
+		resp.Fields[0].Field != "resource_uris" || resp.Fields[0].Code != chat.ValidationCodeTooLong {
+		t.Errorf("response = %+v", resp)
+	}
+
+	// Отклонённый запрос не оставляет в истории неотвеченный вопрос
+	if count, _ := store.GetMessageCount(context.Background(), "s1"); count != 0 {
+		t.Errorf("messages saved = %d, want 0", count)
+	}
+}
+
+func TestSendMessagePromptMissingArgument(t *testing.T) {
+	r, store := newChatRouter(t, &mcpLLM{}, testChatConfig())
+
+	w := postChat(r, `{"session_id": "s1", "message": "Проверь код", "prompt_name": "review", "prompt_args": {"style": "strict"}}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", w.Code, w.Body.String())
+	}
+	var resp PromptArgumentsErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != "VALIDATION_ERROR" || len(resp.Fields) != 1 ||
+		resp.Fields[0].Field != "prompt_args.language" || resp.Fields[0].Code != chat.ValidationCodeRequired {
+		t.Errorf("response = %+v", resp.ErrorResponse)
+	}
+
+	// Ответ содержит полную схему аргументов, чтобы клиент запросил недостающие
+	if resp.Prompt != "review" || !reflect.DeepEqual(resp.Arguments, reviewPrompt.Arguments) {
+		t.Errorf("prompt = %q, arguments = %+v", resp.Prompt, resp.Arguments)
+	}
+	if count, _ := store.GetMessageCount(context.Background(), "s1"); count != 0 {
+		t.Errorf("messages saved = %d, want 0", count)
+	}
+}
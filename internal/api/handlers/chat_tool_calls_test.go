@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"LLM_Chat/internal/config"
+	"LLM_Chat/internal/language"
+	"LLM_Chat/internal/service/chat"
+	contextmgr "LLM_Chat/internal/service/context"
+	"LLM_Chat/internal/service/summary"
+	"LLM_Chat/internal/storage/memory"
+	"LLM_Chat/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// twoToolsLLM модель, которая перед ответом вызвала два инструмента
+type twoToolsLLM struct{}
+
+func (c *twoToolsLLM) traces() []llm.ToolCallTrace {
+	started := time.Now()
+	return []llm.ToolCallTrace{
+		{
+			ID:         "call_1",
+			Name:       "get_weather",
+			Arguments:  map[string]any{"city": "Москва"},
+			Result:     map[string]any{"temperature": float64(21)},
+			DurationMs: 120,
+			StartedAt:  started,
+		},
+		{
+			ID:         "call_2",
+			Name:       "get_forecast",
+			Arguments:  map[string]any{"days": float64(3)},
+			Result:     map[string]any{"forecast": "дождь"},
+			DurationMs: 40,
+			StartedAt:  started.Add(time.Millisecond),
+		},
+	}
+}
+
+func (c *twoToolsLLM) ChatCompletion(ctx context.Context, messages []llm.Message) (*llm.ChatResponse, error) {
+	return &llm.ChatResponse{
+		Model:      "fake-model",
+		Choices:    []llm.Choice{{Message: llm.Message{Role: "assistant", Content: "В Москве +21, потом дождь"}}},
+		Usage:      llm.Usage{TotalTokens: 5},
+		Iterations: 2,
+		ToolCalls:  c.traces(),
+	}, nil
+}
+
+func (c *twoToolsLLM) ChatCompletionStream(ctx context.Context, messages []llm.Message) (<-chan llm.StreamChunk, error) {
+	traces := c.traces()
+	ch := make(chan llm.StreamChunk, 8)
+	go func() {
+		defer close(ch)
+		for _, trace := range traces {
+			ch <- llm.StreamChunk{ToolCall: &llm.ToolCallEvent{
+				ID:         trace.ID,
+				Name:       trace.Name,
+				Phase:      "finished",
+				DurationMs: trace.DurationMs,
+				Success:    true,
+			}}
+		}
+		ch <- llm.StreamChunk{Content: "В Москве +21, "}
+		ch <- llm.StreamChunk{Content: "потом дождь"}
+		ch <- llm.StreamChunk{Done: true, Iterations: 2, ToolCalls: traces}
+	}()
+	return ch, nil
+}
+
+func (c *twoToolsLLM) GetProviderName() string { return "fake" }
+
+func (c *twoToolsLLM) GetSupportedModels() []string { return []string{"fake-model"} }
+
+// newChatRouter маршрут POST /chat над настоящим сервисом чата с моделью client и сессией s1
+func newChatRouter(t *testing.T, client llm.LLMClient, cfg *config.ChatConfig) (*gin.Engine, *memory.MemoryStorage) {
+	t.Helper()
+
+	store := memory.New()
+	summaryService := summary.NewService(store, &shrinkLLM{}, nil, nil, summary.DefaultConfig(), zap.NewNop())
+	manager := contextmgr.NewManager(store, summaryService, nil, nil, nil, contextmgr.DefaultConfig(), zap.NewNop())
+	service := chat.NewService(store, store, manager, client, nil, language.NewPolicy(store, cfg.Language), nil, nil, cfg, zap.NewNop())
+	if err := store.CreateSession(context.Background(), "s1"); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	handler := NewChatHandler(service, chat.NewStreamRegistry(), store, zap.NewNop())
+	r := gin.New()
+	r.POST("/chat", handler.SendMessage)
+	return r, store
+}
+
+// testChatConfig минимальная конфигурация чата для тестов обработчиков
+func testChatConfig() *config.ChatConfig {
+	return &config.ChatConfig{ContextWindowSize: 20, Language: language.Auto, MaxIterationsLimit: 10}
+}
+
+func postChat(r *gin.Engine, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// toolCallIDs идентификаторы вызовов в порядке ответа
+func toolCallIDs(calls []chat.ToolCallSummary) []string {
+	ids := make([]string, len(calls))
+	for i, call := range calls {
+		ids[i] = call.ID
+	}
+	return ids
+}
+
+func TestSendMessageReturnsBothToolCalls(t *testing.T) {
+	r, _ := newChatRouter(t, &twoToolsLLM{}, testChatConfig())
+	w := postChat(r, `{"session_id": "s1", "message": "Какая погода?"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp ChatResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if ids := toolCallIDs(resp.ToolCalls); len(ids) != 2 || ids[0] != "call_1" || ids[1] != "call_2" {
+		t.Fatalf("tool_calls = %v, want [call_1 call_2]", ids)
+	}
+	if resp.ToolCalls[1].Name != "get_forecast" || !resp.ToolCalls[1].Success || resp.Iterations != 2 {
+		t.Errorf("second tool call = %+v, iterations = %d", resp.ToolCalls[1], resp.Iterations)
+	}
+}
+
+func TestSendMessageStreamReturnsBothToolCalls(t *testing.T) {
+	r, _ := newChatRouter(t, &twoToolsLLM{}, testChatConfig())
+	w := postChat(r, `{"session_id": "s1", "message": "Какая погода?", "stream": true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+
+	// Событие SSE: строки "event:<тип>" и "data:<json>", разделённые пустой строкой
+	var toolEvents []string
+	var done struct {
+		ToolCalls []chat.ToolCallSummary `json:"tool_calls"`
+	}
+	for _, block := range strings.Split(w.Body.String(), "\n\n") {
+		var event, data string
+		for _, line := range strings.Split(block, "\n") {
+			if value, ok := strings.CutPrefix(line, "event:"); ok {
+				event = value
+			}
+			if value, ok := strings.CutPrefix(line, "data:"); ok {
+				data = value
+			}
+		}
+
+		switch event {
+		case "tool_call":
+			var payload struct {
+				ToolCall llm.ToolCallEvent `json:"tool_call"`
+			}
+			if err := json.Unmarshal([]byte(data), &payload); err != nil {
+				t.Fatalf("tool_call event: %v\n%s", err, data)
+			}
+			toolEvents = append(toolEvents, payload.ToolCall.ID)
+		case "done":
+			if err := json.Unmarshal([]byte(data), &done); err != nil {
+				t.Fatalf("done event: %v\n%s", err, data)
+			}
+		}
+	}
+
+	if len(toolEvents) != 2 || toolEvents[0] != "call_1" || toolEvents[1] != "call_2" {
+		t.Errorf("tool_call events = %v, want [call_1 call_2]\n%s", toolEvents, w.Body.String())
+	}
+	if ids := toolCallIDs(done.ToolCalls); len(ids) != 2 || ids[0] != "call_1" || ids[1] != "call_2" {
+		t.Errorf("done tool_calls = %v, want [call_1 call_2]\n%s", ids, w.Body.String())
+	}
+}
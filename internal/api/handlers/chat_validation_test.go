@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"LLM_Chat/internal/service/chat"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// sendChatRequest отправляет тело в POST /chat обработчику без сервиса чата:
+// запросы с ошибками не должны до него доходить
+func sendChatRequest(t *testing.T, body string) (*httptest.ResponseRecorder, ErrorResponse) {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	handler := NewChatHandler(nil, chat.NewStreamRegistry(), nil, zap.NewNop())
+	r := gin.New()
+	r.POST("/chat", handler.SendMessage)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+
+	var resp ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not an ErrorResponse: %v\n%s", err, w.Body.String())
+	}
+	return w, resp
+}
+
+func TestSendMessageValidationFields(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantCode   string
+		wantFields map[string]string // поле -> код
+	}{
+		{
+			name:       "missing fields",
+			body:       `{}`,
+			wantCode:   "INVALID_REQUEST",
+			wantFields: map[string]string{"session_id": "required", "message": "required"},
+		},
+		{
+			name:       "wrong type",
+			body:       `{"session_id": "s1", "message": 42}`,
+			wantCode:   "INVALID_REQUEST",
+			wantFields: map[string]string{"message": "invalid_type"},
+		},
+		{
+			name:       "blank message",
+			body:       `{"session_id": "s1", "message": "   "}`,
+			wantCode:   "VALIDATION_ERROR",
+			wantFields: map[string]string{"message": chat.ValidationCodeRequired},
+		},
+		{
+			name:       "message too long",
+			body:       fmt.Sprintf(`{"session_id": "s1", "message": %q}`, strings.Repeat("a", chat.MaxMessageLength+1)),
+			wantCode:   "VALIDATION_ERROR",
+			wantFields: map[string]string{"message": chat.ValidationCodeTooLong},
+		},
+		{
+			name: "several invalid fields",
+			body: fmt.Sprintf(`{"session_id": %q, "message": "hi", "stop": ["a", "b", "c", "d", "e"]}`,
+				strings.Repeat("s", chat.MaxSessionIDLength+1)),
+			wantCode:   "VALIDATION_ERROR",
+			wantFields: map[string]string{"session_id": chat.ValidationCodeInvalid, "stop": chat.ValidationCodeInvalid},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, resp := sendChatRequest(t, tt.body)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400: %s", w.Code, w.Body.String())
+			}
+			if resp.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", resp.Code, tt.wantCode)
+			}
+
+			got := make(map[string]string, len(resp.Fields))
+			for _, field := range resp.Fields {
+				if field.Message == "" {
+					t.Errorf("field %s has no message", field.Field)
+				}
+				got[field.Field] = field.Code
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.wantFields) {
+				t.Errorf("fields = %v, want %v", got, tt.wantFields)
+			}
+		})
+	}
+}
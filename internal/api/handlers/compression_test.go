@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"LLM_Chat/internal/config"
+	"LLM_Chat/internal/language"
+	"LLM_Chat/internal/service/chat"
+	contextmgr "LLM_Chat/internal/service/context"
+	"LLM_Chat/internal/service/summary"
+	"LLM_Chat/internal/storage/memory"
+	"LLM_Chat/internal/storage/models"
+	"LLM_Chat/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// shrinkLLM модель сжатия: отвечает JSON с якорями и резюме и считает запросы
+type shrinkLLM struct {
+	calls atomic.Int32
+}
+
+func (c *shrinkLLM) ChatCompletion(ctx context.Context, messages []llm.Message) (*llm.ChatResponse, error) {
+	n := c.calls.Add(1)
+	output, _ := json.Marshal(map[string]any{
+		"anchors": []string{fmt.Sprintf("Тема %d", n), "Погода"},
+		"summary": fmt.Sprintf("Резюме %d", n),
+	})
+	return &llm.ChatResponse{
+		Choices: []llm.Choice{{Message: llm.Message{Role: "assistant", Content: string(output)}}},
+		Usage:   llm.Usage{TotalTokens: 10},
+	}, nil
+}
+
+func (c *shrinkLLM) ChatCompletionStream(ctx context.Context, messages []llm.Message) (<-chan llm.StreamChunk, error) {
+	return nil, fmt.Errorf("streaming is not supported by the stub")
+}
+
+func (c *shrinkLLM) GetProviderName() string { return "stub" }
+
+func (c *shrinkLLM) GetSupportedModels() []string { return nil }
+
+// newContextRouter маршруты /context и /compress над настоящими сервисами и хранилищем в памяти
+// с n сообщениями в сессии s1
+func newContextRouter(t *testing.T, n int) (*gin.Engine, *memory.MemoryStorage, *shrinkLLM) {
+	t.Helper()
+
+	store := memory.New()
+	shrink := &shrinkLLM{}
+	summaryService := summary.NewService(store, shrink, nil, nil, summary.DefaultConfig(), zap.NewNop())
+	manager := contextmgr.NewManager(store, summaryService, nil, nil, nil, contextmgr.DefaultConfig(), zap.NewNop())
+	cfg := &config.ChatConfig{ContextWindowSize: 20, Language: language.Auto, MaxIterationsLimit: 10}
+	service := chat.NewService(store, store, manager, shrink, nil, language.NewPolicy(store, cfg.Language), nil, nil, cfg, zap.NewNop())
+
+	start := time.Now().Add(-time.Hour)
+	for i := range n {
+		msg := models.NewUserMessage("s1", fmt.Sprintf("message %d", i))
+		if i%2 == 1 {
+			msg = models.NewAssistantMessage("s1", fmt.Sprintf("message %d", i))
+		}
+		msg.ID = fmt.Sprintf("s1-m%03d", i)
+		msg.Timestamp = start.Add(time.Duration(i) * time.Second)
+		if err := store.SaveMessage(context.Background(), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	handler := NewChatHandler(service, chat.NewStreamRegistry(), store, zap.NewNop())
+	r := gin.New()
+	r.GET("/chat/:session_id/context", handler.GetContextInfo)
+	r.POST("/chat/:session_id/compress", handler.TriggerCompression)
+	return r, store, shrink
+}
+
+func serve(r *gin.Engine, method, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+// sessionState активные сообщения и резюме первого уровня сессии s1 в хранилище
+func sessionState(t *testing.T, store *memory.MemoryStorage) (messages, summaries int) {
+	t.Helper()
+
+	active, err := store.GetActiveMessages(context.Background(), "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	active1, err := store.GetActiveSummaries(context.Background(), "s1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(active), len(active1)
+}
+
+func TestGetContextInfoIsReadOnly(t *testing.T) {
+	r, store, shrink := newContextRouter(t, 20)
+
+	for range 2 {
+		w := serve(r, http.MethodGet, "/chat/s1/context")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+		}
+		var info contextmgr.ContextInfo
+		if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+			t.Fatal(err)
+		}
+		// Порог превышен, но /context только сообщает об этом
+		if !info.ShouldCompress || info.CompressionLevel != 1 || info.ActiveMessages != 20 || info.ActiveSummaries != 0 {
+			t.Errorf("context info = %+v", info)
+		}
+	}
+
+	if messages, summaries := sessionState(t, store); messages != 20 || summaries != 0 || shrink.calls.Load() != 0 {
+		t.Errorf("state after GET /context: %d active messages, %d summaries, %d shrink requests", messages, summaries, shrink.calls.Load())
+	}
+}
+
+func TestTriggerCompression(t *testing.T) {
+	tests := []struct {
+		name         string
+		messages     int
+		query        string
+		wantStatus   int
+		triggered    bool
+		wantMessages int // активных сообщений после запроса
+		wantSummary  int
+	}{
+		{"threshold exceeded", 20, "", http.StatusOK, true, 14, 1},
+		{"below threshold", 6, "", http.StatusOK, false, 6, 0},
+		{"forced below threshold", 10, "?force=true", http.StatusOK, true, 5, 1},
+		// Сверх минимального окна одно сообщение: резюме из него не составить
+		{"forced with too few messages", 6, "?force=true", http.StatusOK, false, 6, 0},
+		{"forced down to the minimum window", 20, "?force=true", http.StatusOK, true, 5, 1},
+		{"invalid force", 20, "?force=maybe", http.StatusBadRequest, false, 20, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, store, _ := newContextRouter(t, tt.messages)
+
+			w := serve(r, http.MethodPost, "/chat/s1/compress"+tt.query)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w.Code == http.StatusOK {
+				var resp struct {
+					Result chat.CompressionResult `json:"result"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatal(err)
+				}
+				compressed := tt.messages - tt.wantMessages
+				if resp.Result.Triggered != tt.triggered || resp.Result.MessagesCompressed != compressed {
+					t.Errorf("result = %+v, want triggered = %v and %d messages compressed", resp.Result, tt.triggered, compressed)
+				}
+			}
+
+			if messages, summaries := sessionState(t, store); messages != tt.wantMessages || summaries != tt.wantSummary {
+				t.Errorf("state: %d active messages, %d summaries; want %d and %d", messages, summaries, tt.wantMessages, tt.wantSummary)
+			}
+		})
+	}
+}
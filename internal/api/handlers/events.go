@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"LLM_Chat/internal/api/middleware"
+	"LLM_Chat/internal/config"
+	"LLM_Chat/internal/events"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type EventsHandler struct {
+	bus               *events.Bus
+	heartbeatInterval time.Duration
+	logger            *zap.Logger
+}
+
+func NewEventsHandler(bus *events.Bus, cfg config.EventsConfig, logger *zap.Logger) *EventsHandler {
+	return &EventsHandler{
+		bus:               bus,
+		heartbeatInterval: cfg.HeartbeatInterval,
+		logger:            logger,
+	}
+}
+
+// GET /chat/:session_id/events - поток системных событий сессии (SSE)
+func (h *EventsHandler) StreamSessionEvents(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "session_id is required",
+			Code:  "MISSING_SESSION_ID",
+		})
+		return
+	}
+
+	// Поток живёт до отключения клиента
+	middleware.DisableTimeout(c)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+
+	sub := h.bus.Subscribe(sessionID)
+	defer sub.Close()
+
+	h.logger.Info("Session events stream opened", zap.String("session_id", sessionID))
+
+	heartbeat := time.NewTicker(h.heartbeatInterval)
+	defer heartbeat.Stop()
+
+	// Сразу отправляем заголовки, чтобы клиент знал, что подписка активна
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			h.logger.Info("Session events stream closed", zap.String("session_id", sessionID))
+			return
+
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			c.SSEvent(string(event.Type), event)
+			c.Writer.Flush()
+
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
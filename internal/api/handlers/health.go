@@ -1,16 +1,80 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
+	"sync"
 	"time"
 
+	"LLM_Chat/internal/config"
+	"LLM_Chat/internal/storage/interfaces"
+	"LLM_Chat/pkg/llm"
+
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Имена проверок готовности
+const (
+	HealthCheckStorage = "storage"
+	HealthCheckMCP     = "mcp"
+	HealthCheckLLM     = "llm"
+)
+
+// Статусы отдельных проверок
+const (
+	CheckStatusOK       = "ok"
+	CheckStatusDegraded = "degraded"
+	CheckStatusDown     = "down"
+)
+
+// Статусы готовности сервиса
+const (
+	ReadinessReady    = "ready"
+	ReadinessDegraded = "degraded"
+	ReadinessNotReady = "not_ready"
 )
 
-type HealthHandler struct{}
+// MCPStatusChecker источник статуса MCP сервера
+type MCPStatusChecker interface {
+	CheckStatus(ctx context.Context) MCPStatusResponse
+}
+
+// LLMStatusReporter источник состояния LLM клиента
+type LLMStatusReporter interface {
+	GetProviderStatus() (llm.ProviderStatus, bool)
+	CircuitBreakerStatus() (llm.BreakerStatus, bool)
+}
+
+type HealthHandler struct {
+	storage         interfaces.HealthChecker
+	mcpChecker      MCPStatusChecker
+	llmReporter     LLMStatusReporter
+	checkTimeout    time.Duration
+	allowedDegraded map[string]bool
+	logger          *zap.Logger
+}
+
+func NewHealthHandler(
+	storage interfaces.HealthChecker,
+	mcpChecker MCPStatusChecker,
+	llmReporter LLMStatusReporter,
+	cfg config.HealthConfig,
+	logger *zap.Logger,
+) *HealthHandler {
+	allowed := make(map[string]bool, len(cfg.AllowedDegraded))
+	for _, name := range cfg.AllowedDegraded {
+		allowed[name] = true
+	}
 
-func NewHealthHandler() *HealthHandler {
-	return &HealthHandler{}
+	return &HealthHandler{
+		storage:         storage,
+		mcpChecker:      mcpChecker,
+		llmReporter:     llmReporter,
+		checkTimeout:    cfg.CheckTimeout,
+		allowedDegraded: allowed,
+		logger:          logger,
+	}
 }
 
 type HealthResponse struct {
@@ -19,10 +83,229 @@ type HealthResponse struct {
 	Version   string    `json:"version"`
 }
 
-func (h *HealthHandler) Check(c *gin.Context) {
+type ReadinessResponse struct {
+	Status    string                     `json:"status"`
+	Timestamp time.Time                  `json:"timestamp"`
+	Version   string                     `json:"version"`
+	Checks    map[string]DependencyCheck `json:"checks"`
+}
+
+type DependencyCheck struct {
+	Status    string                 `json:"status"`
+	Critical  bool                   `json:"critical"`
+	LatencyMs int64                  `json:"latency_ms"`
+	Message   string                 `json:"message,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// GET /health/live - процесс запущен и обрабатывает запросы
+func (h *HealthHandler) Live(c *gin.Context) {
 	c.JSON(http.StatusOK, HealthResponse{
 		Status:    "healthy",
 		Timestamp: time.Now(),
 		Version:   "1.0.0",
 	})
 }
+
+// GET /health/ready - готовность принимать трафик с проверкой зависимостей
+func (h *HealthHandler) Ready(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.checkTimeout)
+	defer cancel()
+
+	checks := map[string]func(context.Context) DependencyCheck{
+		HealthCheckStorage: h.checkStorage,
+		HealthCheckMCP:     h.checkMCP,
+		HealthCheckLLM:     h.checkLLM,
+	}
+
+	response := ReadinessResponse{
+		Status:    ReadinessReady,
+		Timestamp: time.Now(),
+		Version:   "1.0.0",
+		Checks:    make(map[string]DependencyCheck, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(context.Context) DependencyCheck) {
+			defer wg.Done()
+
+			start := time.Now()
+			result := check(ctx)
+			result.LatencyMs = time.Since(start).Milliseconds()
+			result.Critical = !h.allowedDegraded[name]
+
+			mu.Lock()
+			response.Checks[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	for name, result := range response.Checks {
+		switch {
+		case result.Status == CheckStatusDown && result.Critical:
+			response.Status = ReadinessNotReady
+		case result.Status != CheckStatusOK && response.Status == ReadinessReady:
+			response.Status = ReadinessDegraded
+		}
+
+		if result.Status != CheckStatusOK {
+			h.logger.Warn("Readiness check is not ok",
+				zap.String("check", name),
+				zap.String("status", result.Status),
+				zap.String("error", result.Error))
+		}
+	}
+
+	code := http.StatusOK
+	if response.Status == ReadinessNotReady {
+		code = http.StatusServiceUnavailable
+	}
+
+	c.JSON(code, response)
+}
+
+// checkStorage проверяет соединение с БД и загрузку пула
+func (h *HealthHandler) checkStorage(ctx context.Context) DependencyCheck {
+	stats, err := h.storage.HealthCheck(ctx)
+	if err != nil {
+		return DependencyCheck{
+			Status:  CheckStatusDown,
+			Message: "Database is unreachable",
+			Error:   err.Error(),
+		}
+	}
+
+	result := DependencyCheck{
+		Status:  CheckStatusOK,
+		Message: "Database is reachable",
+		Details: map[string]interface{}{
+			"open_connections":     stats.OpenConnections,
+			"in_use":               stats.InUse,
+			"idle":                 stats.Idle,
+			"max_open_connections": stats.MaxOpenConnections,
+			"wait_count":           stats.WaitCount,
+			"wait_duration_ms":     stats.WaitDuration.Milliseconds(),
+		},
+	}
+
+	// Все соединения заняты - запросы будут ждать в очереди
+	if stats.MaxOpenConnections > 0 && stats.InUse >= stats.MaxOpenConnections {
+		result.Status = CheckStatusDegraded
+		result.Message = "Database connection pool is exhausted"
+	}
+
+	return result
+}
+
+// checkMCP использует кэшируемую проверку MCP сервера
+func (h *HealthHandler) checkMCP(ctx context.Context) DependencyCheck {
+	status := h.mcpChecker.CheckStatus(ctx)
+
+	result := DependencyCheck{
+		Message: status.Message,
+		Error:   status.Error,
+		Details: map[string]interface{}{
+			"server_url":  status.ServerURL,
+			"tools_count": status.ToolsCount,
+			"probe_ms":    status.LatencyMs,
+			"cached":      status.Cached,
+		},
+	}
+
+	switch status.Status {
+	case MCPStatusConnected, MCPStatusDisabled:
+		result.Status = CheckStatusOK
+	case MCPStatusDegraded:
+		result.Status = CheckStatusDegraded
+	default:
+		result.Status = CheckStatusDown
+	}
+
+	return result
+}
+
+// checkLLM проверяет состояние клиента Gemini и выключателя запросов без обращения к API
+func (h *HealthHandler) checkLLM(ctx context.Context) DependencyCheck {
+	result := h.checkLLMProvider()
+
+	breaker, ok := h.llmReporter.CircuitBreakerStatus()
+	if !ok {
+		return result
+	}
+
+	if result.Details == nil {
+		result.Details = map[string]interface{}{}
+	}
+	breakerDetails := map[string]interface{}{
+		"state":                breaker.State,
+		"consecutive_failures": breaker.ConsecutiveFailures,
+		"failure_threshold":    breaker.FailureThreshold,
+	}
+	if !breaker.OpenedAt.IsZero() {
+		breakerDetails["opened_at"] = breaker.OpenedAt
+	}
+	if breaker.State == llm.BreakerOpen {
+		breakerDetails["retry_after_ms"] = breaker.RetryAfter.Milliseconds()
+	}
+	result.Details["circuit_breaker"] = breakerDetails
+
+	switch {
+	case breaker.State == llm.BreakerOpen:
+		result.Status = CheckStatusDown
+		result.Message = "LLM circuit breaker is open"
+	case breaker.State == llm.BreakerHalfOpen && result.Status == CheckStatusOK:
+		result.Status = CheckStatusDegraded
+		result.Message = "LLM circuit breaker is probing the provider"
+	}
+
+	return result
+}
+
+// checkLLMProvider состояние провайдера по последним запросам
+func (h *HealthHandler) checkLLMProvider() DependencyCheck {
+	status, ok := h.llmReporter.GetProviderStatus()
+	if !ok {
+		return DependencyCheck{
+			Status:  CheckStatusOK,
+			Message: "Provider does not report its status",
+		}
+	}
+
+	details := map[string]interface{}{
+		"initialized": status.Initialized,
+	}
+	if !status.LastSuccessAt.IsZero() {
+		details["last_success_at"] = status.LastSuccessAt
+	}
+	if !status.LastErrorAt.IsZero() {
+		details["last_error_at"] = status.LastErrorAt
+	}
+
+	switch {
+	case status.LastError != "" && status.LastErrorAt.After(status.LastSuccessAt):
+		return DependencyCheck{
+			Status:  CheckStatusDown,
+			Message: "Last LLM request failed",
+			Error:   status.LastError,
+			Details: details,
+		}
+	case !status.Initialized:
+		// Клиент инициализируется лениво при первом запросе
+		return DependencyCheck{
+			Status:  CheckStatusDegraded,
+			Message: "LLM client is not initialized yet",
+			Details: details,
+		}
+	default:
+		return DependencyCheck{
+			Status:  CheckStatusOK,
+			Message: "LLM client is initialized",
+			Details: details,
+		}
+	}
+}
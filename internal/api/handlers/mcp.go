@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"LLM_Chat/internal/config"
+	"LLM_Chat/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Статусы MCP соединения
+const (
+	MCPStatusConnected   = "connected"
+	MCPStatusDegraded    = "degraded"
+	MCPStatusUnreachable = "unreachable"
+	MCPStatusDisabled    = "disabled" // провайдер LLM работает без MCP
+)
+
+// MCPClient LLM клиент с MCP интеграцией
+type MCPClient interface {
+	llm.MCPProber
+	llm.MCPToolCounter
+	llm.ToolLister
+	llm.ToolCaller
+	llm.ResourceReader
+	llm.PromptProvider
+}
+
+type MCPHandler struct {
+	prober       MCPClient
+	serverURL    string
+	probeTimeout time.Duration
+	cacheTTL     time.Duration
+	logger       *zap.Logger
+
+	mu          sync.Mutex
+	cached      *MCPStatusResponse
+	cachedAt    time.Time
+	lastSuccess time.Time
+}
+
+func NewMCPHandler(prober MCPClient, cfg config.MCPConfig, logger *zap.Logger) *MCPHandler {
+	serverURL := cfg.ServerURL
+	if cfg.Transport == llm.MCPTransportStdio {
+		serverURL = "stdio:" + cfg.ServerPath
+	}
+	if len(cfg.Servers) > 0 {
+		endpoints := make([]string, 0, len(cfg.Servers))
+		for _, server := range cfg.Servers {
+			endpoint := server.ServerURL
+			if endpoint == "" {
+				endpoint = "stdio:" + server.ServerPath
+			}
+			endpoints = append(endpoints, server.Name+"="+endpoint)
+		}
+		serverURL = strings.Join(endpoints, ", ")
+	}
+
+	return &MCPHandler{
+		prober:       prober,
+		serverURL:    serverURL,
+		probeTimeout: cfg.ProbeTimeout,
+		cacheTTL:     cfg.StatusCacheTTL,
+		logger:       logger,
+	}
+}
+
+type MCPStatusResponse struct {
+	Status      string     `json:"status"`
+	ServerURL   string     `json:"server_url"`
+	LatencyMs   int64      `json:"latency_ms"`
+	ToolsCount  int        `json:"tools_count"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	CheckedAt   time.Time  `json:"checked_at"`
+	Cached      bool       `json:"cached"`
+	Message     string     `json:"message"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// GET /mcp/status - проверка связи с MCP сервером
+func (h *MCPHandler) GetStatus(c *gin.Context) {
+	status := h.CheckStatus(c.Request.Context())
+
+	code := http.StatusOK
+	if status.Status == MCPStatusUnreachable {
+		code = http.StatusServiceUnavailable
+	}
+
+	c.JSON(code, status)
+}
+
+// MCPToolsResponse инструменты, доступные модели
+type MCPToolsResponse struct {
+	Tools     []llm.ToolInfo `json:"tools"`
+	Count     int            `json:"count"`
+	Refreshed bool           `json:"refreshed"`
+}
+
+// GET /mcp/tools - инструменты, которые видит модель, с параметрами в формате Gemini.
+// Список берётся из открытых MCP сессий; ?refresh=true заново запрашивает его у серверов.
+func (h *MCPHandler) ListTools(c *gin.Context) {
+	refresh, ok := parseBoolQuery(c, "refresh")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.probeTimeout)
+	defer cancel()
+
+	tools, err := h.prober.ListTools(ctx, refresh)
+	if err != nil {
+		h.logger.Warn("Failed to list MCP tools", zap.Bool("refresh", refresh), zap.Error(err))
+		c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error:   "Failed to list MCP tools",
+			Code:    "MCP_TOOLS_ERROR",
+			Details: err.Error(),
+		})
+		return
+	}
+	if tools == nil {
+		tools = []llm.ToolInfo{}
+	}
+
+	c.JSON(http.StatusOK, MCPToolsResponse{
+		Tools:     tools,
+		Count:     len(tools),
+		Refreshed: refresh,
+	})
+}
+
+// MCPResourcesResponse ресурсы MCP серверов, которые можно подключить к запросу полем resource_uris
+type MCPResourcesResponse struct {
+	Resources []llm.ResourceInfo `json:"resources"`
+	Count     int                `json:"count"`
+	Refreshed bool               `json:"refreshed"`
+}
+
+// GET /mcp/resources - ресурсы MCP серверов (resources/list).
+// По умолчанию отдаётся список, полученный при подключении; refresh=true запрашивает его заново.
+func (h *MCPHandler) ListResources(c *gin.Context) {
+	refresh, ok := parseBoolQuery(c, "refresh")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.probeTimeout)
+	defer cancel()
+
+	resources, err := h.prober.ListResources(ctx, refresh)
+	if err != nil {
+		h.logger.Warn("Failed to list MCP resources", zap.Bool("refresh", refresh), zap.Error(err))
+		c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error:   "Failed to list MCP resources",
+			Code:    "MCP_RESOURCES_ERROR",
+			Details: err.Error(),
+		})
+		return
+	}
+	if resources == nil {
+		resources = []llm.ResourceInfo{}
+	}
+
+	c.JSON(http.StatusOK, MCPResourcesResponse{
+		Resources: resources,
+		Count:     len(resources),
+		Refreshed: refresh,
+	})
+}
+
+// MCPPromptsResponse промпты MCP серверов, которые запрос чата выбирает полем prompt_name
+type MCPPromptsResponse struct {
+	Prompts   []llm.PromptInfo `json:"prompts"`
+	Count     int              `json:"count"`
+	Refreshed bool             `json:"refreshed"`
+}
+
+// GET /mcp/prompts - шаблоны промптов MCP серверов (prompts/list) со схемами аргументов.
+// По умолчанию отдаётся список, полученный при подключении; refresh=true запрашивает его заново.
+func (h *MCPHandler) ListPrompts(c *gin.Context) {
+	refresh, ok := parseBoolQuery(c, "refresh")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.probeTimeout)
+	defer cancel()
+
+	prompts, err := h.prober.ListPrompts(ctx, refresh)
+	if err != nil {
+		h.logger.Warn("Failed to list MCP prompts", zap.Bool("refresh", refresh), zap.Error(err))
+		c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error:   "Failed to list MCP prompts",
+			Code:    "MCP_PROMPTS_ERROR",
+			Details: err.Error(),
+		})
+		return
+	}
+	if prompts == nil {
+		prompts = []llm.PromptInfo{}
+	}
+
+	c.JSON(http.StatusOK, MCPPromptsResponse{
+		Prompts:   prompts,
+		Count:     len(prompts),
+		Refreshed: refresh,
+	})
+}
+
+// MCPToolCallResponse результат прямого вызова инструмента
+type MCPToolCallResponse struct {
+	Tool      string         `json:"tool"`
+	Result    map[string]any `json:"result"`
+	LatencyMs int64          `json:"latency_ms"`
+}
+
+// POST /mcp/tools/:name/call - вызов инструмента в обход модели для отладки (mcp.debug_endpoints).
+// Тело - объект аргументов; пустое тело - вызов без аргументов.
+func (h *MCPHandler) CallTool(c *gin.Context) {
+	name := c.Param("name")
+
+	args := map[string]any{}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&args); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid tool arguments",
+				Code:    "INVALID_REQUEST",
+				Details: "body must be a JSON object of tool arguments: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	result, err := h.prober.CallTool(c.Request.Context(), name, args)
+	if err != nil {
+		h.logger.Warn("MCP tool debug call failed", zap.String("tool_name", name), zap.Error(err))
+
+		status, code := http.StatusBadGateway, "TOOL_CALL_FAILED"
+		switch {
+		case errors.Is(err, llm.ErrToolNotAllowed):
+			status, code = http.StatusForbidden, "TOOL_NOT_ALLOWED"
+		case errors.Is(err, llm.ErrUnknownTool):
+			status, code = http.StatusNotFound, "TOOL_NOT_FOUND"
+		case errors.Is(err, llm.ErrProviderReinitializing):
+			status, code = http.StatusServiceUnavailable, "PROVIDER_REINITIALIZING"
+		case errors.Is(err, llm.ErrMCPNotSupported):
+			status, code = http.StatusNotImplemented, "MCP_NOT_SUPPORTED"
+		case errors.Is(err, context.DeadlineExceeded):
+			status, code = http.StatusGatewayTimeout, "TOOL_TIMEOUT"
+		}
+		c.JSON(status, ErrorResponse{
+			Error:   "Tool call failed",
+			Code:    code,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Ошибка, которую вернул сам инструмент (IsError)
+	if result.IsError {
+		c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+			Error:   "Tool returned an error",
+			Code:    "TOOL_ERROR",
+			Details: result.Error,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, MCPToolCallResponse{
+		Tool:      name,
+		Result:    result.Result,
+		LatencyMs: result.Latency.Milliseconds(),
+	})
+}
+
+// ToolCounts возвращает число инструментов MCP сервера, доступных модели и скрытых фильтром
+func (h *MCPHandler) ToolCounts(ctx context.Context) (llm.MCPToolCounts, error) {
+	probeCtx, cancel := context.WithTimeout(ctx, h.probeTimeout)
+	defer cancel()
+
+	return h.prober.MCPToolCounts(probeCtx)
+}
+
+// InvalidateCache сбрасывает кэшированный статус, например после переинициализации MCP сессии
+func (h *MCPHandler) InvalidateCache() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.cached = nil
+}
+
+// CheckStatus возвращает статус MCP сервера, используя кэш чтобы не нагружать сервер частыми проверками
+func (h *MCPHandler) CheckStatus(ctx context.Context) MCPStatusResponse {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.cached != nil && time.Since(h.cachedAt) < h.cacheTTL {
+		cached := *h.cached
+		cached.Cached = true
+		return cached
+	}
+
+	status := h.probe(ctx)
+	h.cached = &status
+	h.cachedAt = status.CheckedAt
+
+	return status
+}
+
+// probe выполняет фактическую проверку MCP сервера с ограничением по времени
+func (h *MCPHandler) probe(ctx context.Context) MCPStatusResponse {
+	probeCtx, cancel := context.WithTimeout(ctx, h.probeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	toolsCount, err := h.prober.ProbeMCP(probeCtx)
+	latency := time.Since(start)
+
+	status := MCPStatusResponse{
+		ServerURL:  h.serverURL,
+		LatencyMs:  latency.Milliseconds(),
+		ToolsCount: toolsCount,
+		CheckedAt:  time.Now(),
+	}
+
+	switch {
+	case errors.Is(err, llm.ErrMCPNotSupported):
+		status.Status = MCPStatusDisabled
+		status.Message = "LLM provider does not use MCP"
+	case err != nil:
+		h.logger.Warn("MCP server probe failed",
+			zap.String("server_url", h.serverURL),
+			zap.Duration("latency", latency),
+			zap.Error(err))
+
+		status.Status = MCPStatusUnreachable
+		status.Message = "MCP server is unreachable"
+		status.Error = err.Error()
+	default:
+		h.lastSuccess = status.CheckedAt
+
+		// Медленный ответ или пустой список инструментов считаем деградацией
+		switch {
+		case toolsCount == 0:
+			status.Status = MCPStatusDegraded
+			status.Message = "MCP server is reachable but exposes no tools"
+		case latency > h.probeTimeout/2:
+			status.Status = MCPStatusDegraded
+			status.Message = "MCP server is responding slowly"
+		default:
+			status.Status = MCPStatusConnected
+			status.Message = "MCP server is connected"
+		}
+	}
+
+	if !h.lastSuccess.IsZero() {
+		lastSuccess := h.lastSuccess
+		status.LastSuccess = &lastSuccess
+	}
+
+	return status
+}
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 
+	"LLM_Chat/internal/api/middleware"
 	"LLM_Chat/pkg/llm"
 
 	"github.com/gin-gonic/gin"
@@ -54,32 +57,11 @@ type MCPInfo struct {
 
 // GET /models - получение информации о доступных провайдерах и моделях
 func (h *ModelsHandler) GetAvailableModels(c *gin.Context) {
-	// Получаем информацию о Gemini MCP провайдере
 	providerInfos := h.registry.GetAvailableProviders()
 
 	var availableProviders []ProviderInfo
-
 	for _, info := range providerInfos {
-		// Конвертируем модели в наш формат
-		var models []ModelInfo
-		for _, modelID := range info.SupportedModels {
-			model := h.getGeminiModelDetails(modelID)
-			models = append(models, model)
-		}
-
-		providerInfo := ProviderInfo{
-			Name:            info.Name,
-			Description:     info.Description,
-			SupportedModels: models,
-			RequiredConfig:  info.RequiredConfig,
-			Features: []string{
-				"Tool calling via MCP",
-				"Multi-modal support",
-				"Advanced reasoning",
-				"Large context window",
-			},
-		}
-		availableProviders = append(availableProviders, providerInfo)
+		availableProviders = append(availableProviders, h.providerInfo(info))
 	}
 
 	// Получаем текущий провайдер из конфигурации
@@ -88,9 +70,9 @@ func (h *ModelsHandler) GetAvailableModels(c *gin.Context) {
 	response := ModelsResponse{
 		CurrentProvider:    currentProvider,
 		AvailableProviders: availableProviders,
-		SupportedProviders: []string{"gemini"},
+		SupportedProviders: llm.GetSupportedProviders(h.logger),
 		MCPInfo: MCPInfo{
-			Enabled:     true,
+			Enabled:     llm.HasMCPSupport(currentProvider),
 			Description: "Model Context Protocol enables advanced tool integration and enhanced AI capabilities",
 			ServerURL:   h.getMCPServerURL(c),
 		},
@@ -110,40 +92,55 @@ func (h *ModelsHandler) GetProviderModels(c *gin.Context) {
 		return
 	}
 
-	if providerName != "gemini" {
+	info, ok := h.registry.GetProviderInfo(providerName)
+	if !ok {
 		c.JSON(http.StatusNotFound, ErrorResponse{
 			Error:   "provider not found",
 			Code:    "PROVIDER_NOT_FOUND",
-			Details: "Only 'gemini' provider is supported",
+			Details: fmt.Sprintf("Supported providers: %s", strings.Join(llm.GetSupportedProviders(h.logger), ", ")),
 		})
 		return
 	}
 
-	// Получаем информацию о Gemini MCP провайдере
-	providerInfos := h.registry.GetAvailableProviders()
-	targetProvider := providerInfos[0] // У нас только один провайдер
+	c.JSON(http.StatusOK, h.providerInfo(info))
+}
 
-	// Конвертируем модели в детальный формат
+// providerInfo описание провайдера реестра с деталями моделей и возможностями
+func (h *ModelsHandler) providerInfo(info llm.ProviderInfo) ProviderInfo {
 	var models []ModelInfo
-	for _, modelID := range targetProvider.SupportedModels {
-		model := h.getGeminiModelDetails(modelID)
-		models = append(models, model)
+	features := []string{
+		"Tool calling via MCP",
+		"Multi-modal support",
+		"Advanced reasoning",
+		"Large context window",
+	}
+	for _, modelID := range info.SupportedModels {
+		if info.HasMCP {
+			models = append(models, h.getGeminiModelDetails(modelID))
+		} else {
+			models = append(models, ModelInfo{
+				ID:          modelID,
+				Name:        modelID,
+				Provider:    info.ID,
+				Description: "OpenRouter model without MCP tool support",
+			})
+		}
+	}
+	if !info.HasMCP {
+		features = []string{
+			"OpenAI-compatible chat completions",
+			"Models from multiple vendors",
+			"Streaming responses",
+		}
 	}
 
-	response := ProviderInfo{
-		Name:            targetProvider.Name,
-		Description:     targetProvider.Description,
+	return ProviderInfo{
+		Name:            info.Name,
+		Description:     info.Description,
 		SupportedModels: models,
-		RequiredConfig:  targetProvider.RequiredConfig,
-		Features: []string{
-			"Tool calling via MCP",
-			"Multi-modal support",
-			"Advanced reasoning",
-			"Large context window",
-		},
+		RequiredConfig:  info.RequiredConfig,
+		Features:        features,
 	}
-
-	c.JSON(http.StatusOK, response)
 }
 
 func (h *ModelsHandler) getGeminiModelDetails(modelID string) ModelInfo {
@@ -193,8 +190,7 @@ func (h *ModelsHandler) getCurrentProvider(c *gin.Context) string {
 		}
 	}
 
-	// Возвращаем Gemini как единственный поддерживаемый провайдер
-	return "gemini"
+	return llm.ProviderGemini
 }
 
 func (h *ModelsHandler) getMCPServerURL(c *gin.Context) string {
@@ -211,19 +207,31 @@ func (h *ModelsHandler) ValidateProviderConfig(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
+		if middleware.IsBodyTooLarge(err) {
+			middleware.BodyTooLargeResponse(c, err)
+			return
+		}
 		c.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "Invalid request format",
 			Code:    "INVALID_REQUEST",
 			Details: err.Error(),
+			Fields:  validationFields(err, &req),
 		})
 		return
 	}
 
-	if req.Provider != "gemini" {
+	info, ok := h.registry.GetProviderInfo(req.Provider)
+	if !ok {
+		supported := strings.Join(llm.GetSupportedProviders(h.logger), ", ")
 		c.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "Unsupported provider",
 			Code:    "UNSUPPORTED_PROVIDER",
-			Details: "Only 'gemini' provider is supported",
+			Details: "Supported providers: " + supported,
+			Fields: []FieldError{{
+				Field:   "provider",
+				Code:    "unsupported",
+				Message: fmt.Sprintf("unsupported provider: %s (supported: %s)", req.Provider, supported),
+			}},
 		})
 		return
 	}
@@ -234,17 +242,14 @@ func (h *ModelsHandler) ValidateProviderConfig(c *gin.Context) {
 			Error:   "Provider configuration validation failed",
 			Code:    "VALIDATION_FAILED",
 			Details: err.Error(),
+			Fields:  validationFields(err, &req),
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":  "Gemini MCP configuration is valid",
+		"message":  info.Name + " configuration is valid",
 		"provider": req.Provider,
-		"features": []string{
-			"MCP tool integration",
-			"Advanced reasoning",
-			"Multi-modal support",
-		},
+		"features": h.providerInfo(info).Features,
 	})
 }
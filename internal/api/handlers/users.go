@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"LLM_Chat/internal/api/middleware"
+	"LLM_Chat/internal/service/chat"
+	"LLM_Chat/internal/storage/interfaces"
+	"LLM_Chat/internal/storage/models"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// MaxUserIDLength максимальная длина идентификатора пользователя
+const MaxUserIDLength = 255
+
+type UsersHandler struct {
+	preferences interfaces.PreferencesStore
+	models      *chat.ModelResolver
+	logger      *zap.Logger
+}
+
+func NewUsersHandler(preferences interfaces.PreferencesStore, modelResolver *chat.ModelResolver, logger *zap.Logger) *UsersHandler {
+	return &UsersHandler{
+		preferences: preferences,
+		models:      modelResolver,
+		logger:      logger,
+	}
+}
+
+// PreferencesRequest тело PUT /users/:user_id/preferences
+type PreferencesRequest struct {
+	DefaultModel string `json:"default_model"` // пустое значение сбрасывает выбор на модель сервера
+}
+
+// PreferencesResponse настройки пользователя и модель, которая будет использована по умолчанию
+type PreferencesResponse struct {
+	UserID         string             `json:"user_id"`
+	Prefs          models.Preferences `json:"prefs"`
+	EffectiveModel string             `json:"effective_model"`
+	UpdatedAt      *time.Time         `json:"updated_at,omitempty"`
+}
+
+// GET /users/:user_id/preferences - получение настроек пользователя
+func (h *UsersHandler) GetPreferences(c *gin.Context) {
+	userID, ok := h.userIDParam(c)
+	if !ok {
+		return
+	}
+
+	prefs, err := h.preferences.GetUserPreferences(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.Error("Failed to get user preferences", zap.String("user_id", userID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to get user preferences",
+			Code:    "PREFERENCES_ERROR",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Отсутствие сохранённых настроек - не ошибка, возвращаем значения по умолчанию
+	if prefs == nil {
+		prefs = &models.UserPreferences{UserID: userID}
+	}
+
+	c.JSON(http.StatusOK, h.preferencesResponse(prefs))
+}
+
+// PUT /users/:user_id/preferences - сохранение настроек пользователя
+func (h *UsersHandler) UpdatePreferences(c *gin.Context) {
+	userID, ok := h.userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req PreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if middleware.IsBodyTooLarge(err) {
+			middleware.BodyTooLargeResponse(c, err)
+			return
+		}
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request format",
+			Code:    "INVALID_REQUEST",
+			Details: err.Error(),
+			Fields:  validationFields(err, &req),
+		})
+		return
+	}
+
+	req.DefaultModel = strings.TrimSpace(req.DefaultModel)
+	if req.DefaultModel != "" {
+		if err := h.models.ValidateModel(req.DefaultModel); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Validation failed",
+				Code:    "VALIDATION_ERROR",
+				Details: err.Error(),
+				Fields: []FieldError{{
+					Field:   "default_model",
+					Code:    chat.ValidationCodeInvalid,
+					Message: err.Error(),
+				}},
+			})
+			return
+		}
+	}
+
+	prefs := models.UserPreferences{
+		UserID:    userID,
+		Prefs:     models.Preferences{DefaultModel: req.DefaultModel},
+		UpdatedAt: time.Now(),
+	}
+
+	if err := h.preferences.SaveUserPreferences(c.Request.Context(), prefs); err != nil {
+		h.logger.Error("Failed to save user preferences", zap.String("user_id", userID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to save user preferences",
+			Code:    "PREFERENCES_ERROR",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	h.logger.Info("User preferences updated",
+		zap.String("user_id", userID),
+		zap.String("default_model", req.DefaultModel),
+	)
+
+	c.JSON(http.StatusOK, h.preferencesResponse(&prefs))
+}
+
+func (h *UsersHandler) userIDParam(c *gin.Context) (string, bool) {
+	userID := strings.TrimSpace(c.Param("user_id"))
+	if userID == "" || len(userID) > MaxUserIDLength {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid user_id",
+			Code:  "INVALID_USER_ID",
+		})
+		return "", false
+	}
+	return userID, true
+}
+
+func (h *UsersHandler) preferencesResponse(prefs *models.UserPreferences) PreferencesResponse {
+	resp := PreferencesResponse{
+		UserID:         prefs.UserID,
+		Prefs:          prefs.Prefs,
+		EffectiveModel: h.models.DefaultModel(),
+	}
+	if prefs.Prefs.DefaultModel != "" && h.models.ValidateModel(prefs.Prefs.DefaultModel) == nil {
+		resp.EffectiveModel = prefs.Prefs.DefaultModel
+	}
+	if !prefs.UpdatedAt.IsZero() {
+		updatedAt := prefs.UpdatedAt
+		resp.UpdatedAt = &updatedAt
+	}
+	return resp
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"LLM_Chat/internal/service/chat"
+	"LLM_Chat/pkg/llm"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError ошибка валидации конкретного поля запроса
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// validationFields раскладывает ошибку привязки или валидации на ошибки полей.
+// req - указатель на структуру запроса, по её json тегам определяются имена полей.
+func validationFields(err error, req any) []FieldError {
+	var fields []FieldError
+
+	var bindErrs validator.ValidationErrors
+	if errors.As(err, &bindErrs) {
+		for _, fe := range bindErrs {
+			name := jsonFieldName(req, fe.StructField())
+			fields = append(fields, FieldError{
+				Field:   name,
+				Code:    bindingCode(fe.Tag()),
+				Message: bindingMessage(name, fe),
+			})
+		}
+		return fields
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return []FieldError{{
+			Field:   typeErr.Field,
+			Code:    "invalid_type",
+			Message: fmt.Sprintf("%s must be %s, got %s", typeErr.Field, typeErr.Type.Kind(), typeErr.Value),
+		}}
+	}
+
+	for _, e := range flattenErrors(err) {
+		var chatErr *chat.ValidationError
+		var configErr *llm.ConfigFieldError
+
+		switch {
+		case errors.As(e, &chatErr):
+			fields = append(fields, FieldError{Field: chatErr.Field, Code: chatErr.Code, Message: chatErr.Message})
+		case errors.As(e, &configErr):
+			fields = append(fields, FieldError{Field: configErr.Field, Code: configErr.Code, Message: configErr.Message})
+		}
+	}
+
+	return fields
+}
+
+// flattenErrors раскрывает ошибки, объединённые через errors.Join или ValidationErrors
+func flattenErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var errs []error
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, flattenErrors(e)...)
+		}
+		return errs
+	}
+
+	return []error{err}
+}
+
+// jsonFieldName возвращает имя поля из json тега структуры запроса
+func jsonFieldName(req any, structField string) string {
+	t := reflect.TypeOf(req)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t != nil && t.Kind() == reflect.Struct {
+		if f, ok := t.FieldByName(structField); ok {
+			if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name != "" && name != "-" {
+				return name
+			}
+		}
+	}
+
+	return structField
+}
+
+func bindingCode(tag string) string {
+	switch tag {
+	case "required":
+		return "required"
+	case "max":
+		return "too_long"
+	case "min":
+		return "too_short"
+	case "oneof":
+		return "invalid_value"
+	default:
+		return "invalid"
+	}
+}
+
+func bindingMessage(field string, fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", field)
+	case "max":
+		return fmt.Sprintf("%s must be at most %s", field, fe.Param())
+	case "min":
+		return fmt.Sprintf("%s must be at least %s", field, fe.Param())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", field, fe.Param())
+	default:
+		return fmt.Sprintf("%s failed '%s' validation", field, fe.Tag())
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminTokenHeader альтернативный заголовок для передачи токена администратора
+const AdminTokenHeader = "X-Admin-Token"
+
+// AdminAuthMiddleware пропускает запросы с токеном администратора
+// (Authorization: Bearer <token> или X-Admin-Token). Пустой токен отключает административный API.
+func AdminAuthMiddleware(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":   "Admin API is disabled",
+				"code":    "ADMIN_DISABLED",
+				"details": "set admin.token (CHAT_LLM_ADMIN_TOKEN) to enable admin endpoints",
+			})
+			return
+		}
+
+		provided := c.GetHeader(AdminTokenHeader)
+		if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			provided = strings.TrimPrefix(auth, "Bearer ")
+		}
+
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "Invalid or missing admin token",
+				"code":  "UNAUTHORIZED",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"LLM_Chat/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSMiddleware применяет политику CORS из конфигурации.
+// Запросы с неразрешённых origin не получают CORS заголовков, их preflight отклоняется.
+func CORSMiddleware(cfg config.CORSConfig) gin.HandlerFunc {
+	allowAll := false
+	var exact []string
+	var wildcards []originWildcard
+
+	for _, origin := range cfg.AllowedOrigins {
+		switch {
+		case origin == "*":
+			allowAll = true
+		case strings.Contains(origin, "://*."):
+			// https://*.example.com -> схема "https://" и суффикс ".example.com"
+			parts := strings.SplitN(strings.ToLower(origin), "*", 2)
+			wildcards = append(wildcards, originWildcard{scheme: parts[0], suffix: parts[1]})
+		default:
+			exact = append(exact, strings.ToLower(strings.TrimRight(origin, "/")))
+		}
+	}
+
+	isAllowed := func(origin string) bool {
+		if allowAll {
+			return true
+		}
+
+		origin = strings.ToLower(origin)
+		for _, allowed := range exact {
+			if origin == allowed {
+				return true
+			}
+		}
+
+		for _, wildcard := range wildcards {
+			if wildcard.matches(origin) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	allowMethods := strings.Join(cfg.AllowedMethods, ", ")
+	allowHeaders := strings.Join(cfg.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		c.Writer.Header().Add("Vary", "Origin")
+		if !isAllowed(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if allowAll && !cfg.AllowCredentials {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			c.Header("Access-Control-Allow-Methods", allowMethods)
+			c.Header("Access-Control-Allow-Headers", allowHeaders)
+			if cfg.MaxAge > 0 {
+				c.Header("Access-Control-Max-Age", maxAge)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// originWildcard разрешённый origin с поддоменом-шаблоном
+type originWildcard struct {
+	scheme string
+	suffix string
+}
+
+func (w originWildcard) matches(origin string) bool {
+	return strings.HasPrefix(origin, w.scheme) &&
+		strings.HasSuffix(origin, w.suffix) &&
+		len(origin) > len(w.scheme)+len(w.suffix)
+}
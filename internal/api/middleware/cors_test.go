@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"LLM_Chat/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func corsRouter(cfg config.CORSConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(CORSMiddleware(cfg))
+	r.POST("/chat", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		c.SSEvent("content", "hello")
+		c.Writer.Flush()
+		c.SSEvent("done", "")
+	})
+	return r
+}
+
+func restrictedCORS() config.CORSConfig {
+	return config.CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Content-Type", "X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	tests := []struct {
+		origin     string
+		wantStatus int
+		wantOrigin string
+	}{
+		{"https://app.example.com", http.StatusNoContent, "https://app.example.com"},
+		{"https://tenant.example.org", http.StatusNoContent, "https://tenant.example.org"},
+		{"https://example.org", http.StatusForbidden, ""},
+		{"https://evil.com", http.StatusForbidden, ""},
+		{"http://app.example.com", http.StatusForbidden, ""},
+	}
+
+	r := corsRouter(restrictedCORS())
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodOptions, "/chat", nil)
+		req.Header.Set("Origin", tt.origin)
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.origin, w.Code, tt.wantStatus)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+			t.Errorf("%s: Allow-Origin = %q, want %q", tt.origin, got, tt.wantOrigin)
+		}
+		if tt.wantStatus != http.StatusNoContent {
+			continue
+		}
+		if got := w.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST" {
+			t.Errorf("%s: Allow-Methods = %q", tt.origin, got)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type, X-Request-ID" {
+			t.Errorf("%s: Allow-Headers = %q", tt.origin, got)
+		}
+		if got := w.Header().Get("Access-Control-Max-Age"); got != "600" {
+			t.Errorf("%s: Max-Age = %q, want 600", tt.origin, got)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+			t.Errorf("%s: Allow-Credentials = %q, want true", tt.origin, got)
+		}
+	}
+}
+
+func TestCORSStreamingResponse(t *testing.T) {
+	r := corsRouter(restrictedCORS())
+
+	req := httptest.NewRequest(http.MethodPost, "/chat", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Политика применяется один раз: без дублирующих заголовков из обработчика
+	if got := w.Header().Values("Access-Control-Allow-Origin"); len(got) != 1 || got[0] != "https://app.example.com" {
+		t.Errorf("Allow-Origin = %v, want [https://app.example.com]", got)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/chat", nil)
+	req.Header.Set("Origin", "https://evil.com")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	for header := range w.Header() {
+		if strings.HasPrefix(header, "Access-Control-") {
+			t.Errorf("disallowed origin got CORS header %s", header)
+		}
+	}
+}
+
+func TestCORSWildcardWithoutCredentials(t *testing.T) {
+	r := corsRouter(config.CORSConfig{AllowedOrigins: []string{"*"}})
+
+	req := httptest.NewRequest(http.MethodPost, "/chat", nil)
+	req.Header.Set("Origin", "https://anything.test")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Allow-Origin = %q, want *", got)
+	}
+}
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GzipMiddleware сжимает ответы при поддержке клиентом (Accept-Encoding: gzip).
+// Ответы меньше minSize и потоковые ответы (text/event-stream, WebSocket) не сжимаются:
+// сжатие SSE ломает доставку событий по мере Flush.
+func GzipMiddleware(level, minSize int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") ||
+			c.Request.Method == http.MethodHead ||
+			isStreamingRequest(c) {
+			c.Next()
+			return
+		}
+
+		gw := &gzipWriter{ResponseWriter: c.Writer, level: level, minSize: minSize}
+		c.Writer = gw
+		defer gw.finish()
+
+		c.Next()
+	}
+}
+
+// gzipWriter буферизует начало ответа, пока не станет понятно, нужно ли сжатие
+type gzipWriter struct {
+	gin.ResponseWriter
+	level   int
+	minSize int
+
+	buf      bytes.Buffer
+	decided  bool
+	gz       *gzip.Writer
+	writeErr error
+}
+
+func (w *gzipWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		if w.gz != nil {
+			return w.gz.Write(data)
+		}
+		return w.ResponseWriter.Write(data)
+	}
+
+	if isEventStream(w.Header().Get("Content-Type")) {
+		w.decide(false)
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.buf.Write(data)
+	if w.buf.Len() >= w.minSize {
+		w.decide(w.compressible())
+		if w.writeErr != nil {
+			return 0, w.writeErr
+		}
+	}
+
+	return len(data), nil
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Written учитывает буферизованные данные, чтобы другие middleware не дописывали ответ поверх
+func (w *gzipWriter) Written() bool {
+	return w.buf.Len() > 0 || w.ResponseWriter.Written()
+}
+
+func (w *gzipWriter) Flush() {
+	if !w.decided {
+		w.decide(!isEventStream(w.Header().Get("Content-Type")) && w.buf.Len() >= w.minSize && w.compressible())
+	}
+
+	if w.gz != nil {
+		_ = w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Unwrap нужен http.ResponseController для доступа к исходному соединению
+func (w *gzipWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// compressible проверяет, что статус и заголовки ответа допускают сжатие
+func (w *gzipWriter) compressible() bool {
+	status := w.Status()
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		return false
+	}
+	return w.Header().Get("Content-Encoding") == ""
+}
+
+// decide фиксирует режим записи и сбрасывает накопленный буфер
+func (w *gzipWriter) decide(compress bool) {
+	w.decided = true
+
+	if compress {
+		gz, err := gzip.NewWriterLevel(w.ResponseWriter, w.level)
+		if err == nil {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Header().Add("Vary", "Accept-Encoding")
+			w.Header().Del("Content-Length")
+			w.gz = gz
+		}
+	}
+
+	if w.buf.Len() == 0 {
+		return
+	}
+
+	if w.gz != nil {
+		_, w.writeErr = w.gz.Write(w.buf.Bytes())
+	} else {
+		_, w.writeErr = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf.Reset()
+}
+
+// finish дописывает ответ после завершения обработчика
+func (w *gzipWriter) finish() {
+	if !w.decided {
+		// Ответ меньше порога - отправляем без сжатия
+		w.decide(false)
+	}
+
+	if w.gz != nil {
+		_ = w.gz.Close()
+	}
+}
+
+func isEventStream(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/event-stream")
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func gzipRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(GzipMiddleware(gzip.DefaultCompression, 1024))
+
+	r.GET("/history", func(c *gin.Context) {
+		items := make([]gin.H, 500)
+		for i := range items {
+			items[i] = gin.H{"role": "user", "content": strings.Repeat("message ", 20)}
+		}
+		c.JSON(http.StatusOK, gin.H{"items": items})
+	})
+	r.GET("/small", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+	// Потоковый ответ чата: клиент не передаёт Accept: text/event-stream
+	r.POST("/chat", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		for i := 0; i < 100; i++ {
+			c.SSEvent("content", strings.Repeat("chunk ", 10))
+			c.Writer.Flush()
+		}
+	})
+	return r
+}
+
+func gzipRequest(method, path string) *http.Request {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	return req
+}
+
+func TestGzipCompressesLargeHistory(t *testing.T) {
+	w := httptest.NewRecorder()
+	gzipRouter().ServeHTTP(w, gzipRequest(http.MethodGet, "/history"))
+
+	if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		Items []map[string]string `json:"items"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil || len(body.Items) != 500 {
+		t.Fatalf("decompressed body: %d items, err = %v", len(body.Items), err)
+	}
+}
+
+func TestGzipSkipsSmallAndStreamingResponses(t *testing.T) {
+	r := gzipRouter()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, gzipRequest(http.MethodGet, "/small"))
+	if got := w.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("small response: Content-Encoding = %q, want none", got)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, gzipRequest(http.MethodPost, "/chat"))
+	if got := w.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("SSE response: Content-Encoding = %q, want none", got)
+	}
+	if got := strings.Count(w.Body.String(), "event:content"); got != 100 {
+		t.Errorf("SSE events = %d, want 100", got)
+	}
+}
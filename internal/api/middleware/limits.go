@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"LLM_Chat/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LimitsMiddleware ограничивает размер тела запроса и количество заголовков,
+// а также задаёт дедлайн чтения тела для медленных клиентов.
+// Ограничение размера применяется через http.MaxBytesReader до разбора JSON.
+func LimitsMiddleware(cfg config.LimitsConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		headerCount := 0
+		for _, values := range c.Request.Header {
+			headerCount += len(values)
+		}
+		if cfg.MaxHeaderCount > 0 && headerCount > cfg.MaxHeaderCount {
+			c.AbortWithStatusJSON(http.StatusRequestHeaderFieldsTooLarge, gin.H{
+				"error":   "Too many request headers",
+				"code":    "HEADERS_TOO_LARGE",
+				"details": fmt.Sprintf("request has %d headers, limit is %d", headerCount, cfg.MaxHeaderCount),
+			})
+			return
+		}
+
+		limit := cfg.BodyLimit(c.FullPath())
+		if limit > 0 && c.Request.ContentLength > limit {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, bodyTooLargeError(limit))
+			return
+		}
+
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		if limit > 0 {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		}
+
+		if cfg.BodyReadTimeout > 0 {
+			rc := http.NewResponseController(c.Writer)
+			if err := rc.SetReadDeadline(time.Now().Add(cfg.BodyReadTimeout)); err == nil {
+				c.Request.Body = &deadlineBody{ReadCloser: c.Request.Body, rc: rc}
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// IsBodyTooLarge проверяет, что ошибка чтения тела вызвана превышением лимита
+func IsBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+// BodyTooLargeResponse пишет структурированную ошибку 413 для обработчиков
+func BodyTooLargeResponse(c *gin.Context, err error) {
+	var maxBytesErr *http.MaxBytesError
+	limit := int64(0)
+	if errors.As(err, &maxBytesErr) {
+		limit = maxBytesErr.Limit
+	}
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, bodyTooLargeError(limit))
+}
+
+func bodyTooLargeError(limit int64) gin.H {
+	return gin.H{
+		"error":   "Request body too large",
+		"code":    "BODY_TOO_LARGE",
+		"details": fmt.Sprintf("request body exceeds limit of %d bytes", limit),
+	}
+}
+
+// deadlineBody снимает дедлайн чтения после получения тела целиком,
+// иначе истёкший дедлайн оборвёт контекст долгого запроса
+type deadlineBody struct {
+	io.ReadCloser
+	rc *http.ResponseController
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		_ = b.rc.SetReadDeadline(time.Time{})
+	}
+	return n, err
+}
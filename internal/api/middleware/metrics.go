@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"LLM_Chat/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsMiddleware собирает HTTP метрики по шаблону маршрута (без параметров пути)
+func MetricsMiddleware(m *metrics.Metrics, skipPaths ...string) gin.HandlerFunc {
+	skip := make(map[string]bool, len(skipPaths))
+	for _, path := range skipPaths {
+		skip[path] = true
+	}
+
+	return func(c *gin.Context) {
+		if skip[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		inFlight := m.HTTPInFlight(route)
+		inFlight.Inc()
+		start := time.Now()
+
+		// RecoveryMiddleware зарегистрирован раньше и перехватывает панику уже после этого
+		// middleware, поэтому учёт выполняется в defer, а паника учитывается как ответ 500
+		defer func() {
+			inFlight.Dec()
+			if rec := recover(); rec != nil {
+				m.ObserveHTTPRequest(c.Request.Method, route, strconv.Itoa(http.StatusInternalServerError), time.Since(start))
+				panic(rec)
+			}
+			m.ObserveHTTPRequest(c.Request.Method, route, strconv.Itoa(c.Writer.Status()), time.Since(start))
+		}()
+
+		c.Next()
+	}
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"LLM_Chat/internal/metrics"
+	"LLM_Chat/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func TestMetricsEndpointAfterRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := metrics.New()
+
+	r := gin.New()
+	r.GET("/metrics", gin.WrapH(m.Handler()))
+	r.Use(MetricsMiddleware(m, "/metrics"))
+	r.GET("/api/v1/chat/:session_id/history", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"items": []string{}})
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/chat/secret-session/history", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+
+	// Наблюдатели LLM и инструментов пишут в тот же реестр
+	m.LLMObserver("main")("fake", "fake-model", llm.Usage{TotalTokens: 42}, time.Second, nil)
+	m.ToolCallObserver()("lookup", time.Second, errors.New("failed"))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+	scrape := string(body)
+
+	for _, series := range []string{
+		`llmchat_http_requests_total{method="GET",route="/api/v1/chat/:session_id/history",status="200"} 1`,
+		`llmchat_http_request_duration_seconds_count{method="GET",route="/api/v1/chat/:session_id/history",status="200"} 1`,
+		`llmchat_http_requests_in_flight{route="/api/v1/chat/:session_id/history"} 0`,
+		`llmchat_llm_tokens_total{client="main",model="fake-model",provider="fake"} 42`,
+		`llmchat_mcp_tool_calls_total{status="error",tool="lookup"} 1`,
+	} {
+		if !strings.Contains(scrape, series) {
+			t.Errorf("scrape lacks %s", series)
+		}
+	}
+	// Параметры пути не попадают в метки, сам /metrics не учитывается
+	if strings.Contains(scrape, "secret-session") {
+		t.Error("session id leaked into metric labels")
+	}
+	if strings.Contains(scrape, `route="/metrics"`) {
+		t.Error("/metrics requests are instrumented")
+	}
+}
+
+func TestMetricsAfterHandlerPanic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := metrics.New()
+
+	// Порядок как в routes.SetupRoutes: восстановление после паники снаружи метрик
+	r := gin.New()
+	r.Use(RecoveryMiddleware(zap.NewNop(), m))
+	r.GET("/metrics", gin.WrapH(m.Handler()))
+	r.Use(MetricsMiddleware(m, "/metrics"))
+	r.GET("/boom", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/boom", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+	scrape := string(body)
+
+	for _, series := range []string{
+		`llmchat_http_requests_total{method="GET",route="/boom",status="500"} 1`,
+		`llmchat_http_request_duration_seconds_count{method="GET",route="/boom",status="500"} 1`,
+		`llmchat_http_requests_in_flight{route="/boom"} 0`,
+	} {
+		if !strings.Contains(scrape, series) {
+			t.Errorf("scrape lacks %s", series)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"LLM_Chat/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// RequestIDHeader заголовок с идентификатором запроса
+	RequestIDHeader = "X-Request-ID"
+
+	requestIDKey = "request_id"
+)
+
+// RequestIDMiddleware присваивает запросу идентификатор (берёт из заголовка клиента или генерирует)
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" || len(requestID) > 128 {
+			requestID = uuid.New().String()
+		}
+
+		c.Set(requestIDKey, requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// GetRequestID возвращает идентификатор текущего запроса
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// RecoveryMiddleware перехватывает панику обработчика, логирует стек с идентификатором запроса
+// и возвращает клиенту стандартную ошибку без внутренних подробностей.
+// Для SSE потока, который уже начал передаваться, отправляется финальное событие error.
+func RecoveryMiddleware(logger *zap.Logger, appMetrics *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+
+			requestID := GetRequestID(c)
+			route := c.FullPath()
+			if route == "" {
+				route = "unmatched"
+			}
+
+			logger.Error("Panic recovered",
+				zap.String("request_id", requestID),
+				zap.String("method", c.Request.Method),
+				zap.String("path", c.Request.URL.Path),
+				zap.Any("panic", rec),
+				zap.ByteString("stack", debug.Stack()),
+			)
+
+			if appMetrics != nil {
+				appMetrics.IncPanics(route)
+			}
+
+			body := gin.H{
+				"error":      "Internal server error",
+				"code":       "INTERNAL_PANIC",
+				"request_id": requestID,
+			}
+
+			if c.Writer.Written() && isEventStream(c.Writer.Header().Get("Content-Type")) {
+				c.SSEvent("error", body)
+				c.Writer.Flush()
+				c.Abort()
+				return
+			}
+
+			if c.Writer.Written() {
+				// Ответ уже частично отправлен - остаётся только оборвать его
+				c.Abort()
+				return
+			}
+
+			c.AbortWithStatusJSON(http.StatusInternalServerError, body)
+		}()
+
+		c.Next()
+	}
+}
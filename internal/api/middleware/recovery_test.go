@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"LLM_Chat/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func recoveryRouter(t *testing.T) (*gin.Engine, *observer.ObservedLogs, *metrics.Metrics) {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zap.ErrorLevel)
+	appMetrics := metrics.New()
+
+	r := gin.New()
+	r.Use(RequestIDMiddleware())
+	r.Use(RecoveryMiddleware(zap.New(core), appMetrics))
+	r.GET("/panic", func(c *gin.Context) {
+		panic("secret internal state")
+	})
+	r.GET("/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		c.SSEvent("content", "partial")
+		c.Writer.Flush()
+		panic("stream failure")
+	})
+	return r, logs, appMetrics
+}
+
+func TestRecoveryReturnsErrorBody(t *testing.T) {
+	r, logs, appMetrics := recoveryRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set(RequestIDHeader, "req-42")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body is not JSON: %v\n%s", err, w.Body.String())
+	}
+	if body["code"] != "INTERNAL_PANIC" || body["request_id"] != "req-42" || body["error"] == "" {
+		t.Errorf("body = %v", body)
+	}
+	if strings.Contains(w.Body.String(), "secret") || strings.Contains(w.Body.String(), "goroutine") {
+		t.Errorf("body exposes panic details: %s", w.Body.String())
+	}
+
+	entries := logs.FilterMessage("Panic recovered").All()
+	if len(entries) != 1 {
+		t.Fatalf("log entries = %d, want 1", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["request_id"] != "req-42" || fields["path"] != "/panic" {
+		t.Errorf("log fields = %v", fields)
+	}
+	if stack, _ := fields["stack"].(string); !strings.Contains(stack, "goroutine") {
+		t.Error("log entry has no stack trace")
+	}
+
+	scrape := httptest.NewRecorder()
+	appMetrics.Handler().ServeHTTP(scrape, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(scrape.Body.String(), `llmchat_http_panics_total{route="/panic"} 1`) {
+		t.Error("panic counter was not incremented")
+	}
+}
+
+func TestRecoveryEndsStreamWithErrorEvent(t *testing.T) {
+	r, _, _ := recoveryRouter(t)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+
+	body := w.Body.String()
+	if !strings.Contains(body, "event:content") {
+		t.Fatalf("stream content was lost:\n%s", body)
+	}
+	if !strings.Contains(body, "event:error") || !strings.Contains(body, "INTERNAL_PANIC") {
+		t.Errorf("stream does not end with an error event:\n%s", body)
+	}
+}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// timeoutWriteGrace запас времени на запись ответа 504 после истечения дедлайна
+const timeoutWriteGrace = 5 * time.Second
+
+// timeoutParentCtxKey ключ исходного контекста запроса (без дедлайна) в gin.Context
+const timeoutParentCtxKey = "timeout_parent_ctx"
+
+// TimeoutMiddleware ограничивает время обработки запроса через дедлайн контекста.
+// Если обработчик не успел ответить, клиент получает 504 с JSON телом вместо частично записанного ответа.
+// Стриминговые запросы (SSE, WebSocket) не ограничиваются, а обработчик может снять ограничение через DisableTimeout.
+func TimeoutMiddleware(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isStreamingRequest(c) {
+			DisableTimeout(c)
+			c.Next()
+			return
+		}
+
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		parent := c.Request.Context()
+		ctx, cancel := context.WithTimeout(parent, timeout)
+		defer cancel()
+
+		// Серверный WriteTimeout подстраивается под таймаут группы, иначе соединение оборвётся раньше
+		_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(timeout + timeoutWriteGrace))
+
+		tw := &timeoutWriter{ResponseWriter: c.Writer, ctx: ctx}
+		c.Writer = tw
+		c.Request = c.Request.WithContext(ctx)
+		c.Set(timeoutParentCtxKey, parent)
+
+		c.Next()
+
+		if tw.released || ctx.Err() != context.DeadlineExceeded || tw.ResponseWriter.Written() {
+			return
+		}
+
+		tw.released = true
+		c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{
+			"error":   "Request timed out",
+			"code":    "GATEWAY_TIMEOUT",
+			"details": "request processing exceeded " + timeout.String(),
+		})
+	}
+}
+
+// DisableTimeout снимает ограничение TimeoutMiddleware для текущего запроса (для стриминга)
+func DisableTimeout(c *gin.Context) {
+	if tw, ok := c.Writer.(*timeoutWriter); ok {
+		tw.released = true
+	}
+
+	if parent, ok := c.Get(timeoutParentCtxKey); ok {
+		if ctx, ok := parent.(context.Context); ok {
+			c.Request = c.Request.WithContext(ctx)
+		}
+	}
+
+	// Снимаем и серверные дедлайны, иначе длинный поток будет оборван на уровне соединения
+	rc := http.NewResponseController(c.Writer)
+	_ = rc.SetWriteDeadline(time.Time{})
+	_ = rc.SetReadDeadline(time.Time{})
+}
+
+// isStreamingRequest определяет запросы, которые заведомо являются потоковыми
+func isStreamingRequest(c *gin.Context) bool {
+	if strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		return true
+	}
+	return strings.EqualFold(c.GetHeader("Upgrade"), "websocket")
+}
+
+// timeoutWriter отбрасывает запись ответа после истечения дедлайна,
+// чтобы middleware мог отдать корректный 504
+type timeoutWriter struct {
+	gin.ResponseWriter
+	ctx      context.Context
+	released bool
+}
+
+func (w *timeoutWriter) discard() bool {
+	return !w.released && !w.ResponseWriter.Written() && w.ctx.Err() == context.DeadlineExceeded
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	if w.discard() {
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	if w.discard() {
+		return len(s), nil
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	if w.discard() {
+		return
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+// Unwrap нужен http.ResponseController для доступа к исходному соединению
+func (w *timeoutWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
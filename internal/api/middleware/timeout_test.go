@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testTimeout = 50 * time.Millisecond
+
+// slowStream пишет события дольше таймаута группы
+func slowStream(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	for i := 0; i < 3; i++ {
+		time.Sleep(testTimeout)
+		if err := c.Request.Context().Err(); err != nil {
+			return
+		}
+		c.SSEvent("content", "chunk")
+		c.Writer.Flush()
+	}
+	c.SSEvent("done", "")
+}
+
+func timeoutRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(TimeoutMiddleware(testTimeout))
+
+	r.GET("/slow", func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+		case <-time.After(4 * testTimeout):
+		}
+		c.JSON(http.StatusOK, gin.H{"late": true})
+	})
+	r.GET("/events", slowStream)
+	// Поток по полю тела запроса: обработчик сам снимает ограничение
+	r.POST("/chat", func(c *gin.Context) {
+		DisableTimeout(c)
+		slowStream(c)
+	})
+	return r
+}
+
+func TestTimeoutReturnsGatewayTimeoutBody(t *testing.T) {
+	w := httptest.NewRecorder()
+	timeoutRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want 504", w.Code)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("504 body is not JSON: %v\n%s", err, w.Body.String())
+	}
+	if body["code"] != "GATEWAY_TIMEOUT" {
+		t.Errorf("code = %q, want GATEWAY_TIMEOUT", body["code"])
+	}
+	if strings.Contains(w.Body.String(), "late") {
+		t.Error("late handler output was written after the timeout")
+	}
+}
+
+func TestSlowStreamSurvivesTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *http.Request
+	}{
+		{"accept header", func() *http.Request {
+			req := httptest.NewRequest(http.MethodGet, "/events", nil)
+			req.Header.Set("Accept", "text/event-stream")
+			return req
+		}()},
+		{"disabled by handler", httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(`{"stream":true}`))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			timeoutRouter().ServeHTTP(w, tt.req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", w.Code)
+			}
+			body := w.Body.String()
+			if got := strings.Count(body, "event:content"); got != 3 {
+				t.Errorf("content events = %d, want 3:\n%s", got, body)
+			}
+			if !strings.Contains(body, "event:done") {
+				t.Errorf("stream was cut before done:\n%s", body)
+			}
+		})
+	}
+}
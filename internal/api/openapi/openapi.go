@@ -0,0 +1,231 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// spec поддерживается вручную и должна обновляться вместе с routes.SetupRoutes
+//
+//go:embed openapi.json
+var spec []byte
+
+// Spec возвращает OpenAPI спецификацию в JSON
+func Spec() []byte {
+	return spec
+}
+
+// SpecHandler отдаёт спецификацию (GET /api/v1/openapi.json)
+func SpecHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", spec)
+}
+
+// SwaggerUIHandler отдаёт страницу Swagger UI, загружающую спецификацию по specURL
+func SwaggerUIHandler(specURL string) gin.HandlerFunc {
+	page := fmt.Sprintf(swaggerUITemplate, specURL)
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+	}
+}
+
+const swaggerUITemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>LLM Chat API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.onload = function () {
+      SwaggerUIBundle({ url: %q, dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>`
+
+type document struct {
+	Paths map[string]map[string]struct {
+		Optional bool `json:"x-optional"`
+	} `json:"paths"`
+	Components struct {
+		Schemas map[string]schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type schema struct {
+	Ref        string                     `json:"$ref"`
+	Properties map[string]json.RawMessage `json:"properties"`
+	AllOf      []schema                   `json:"allOf"`
+}
+
+const schemaRefPrefix = "#/components/schemas/"
+
+var ginParamPattern = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+// Validate сверяет спецификацию с зарегистрированными маршрутами gin.
+// Возвращает ошибку со списком расхождений: маршруты без описания и описания без маршрутов
+// (операции с x-optional могут отсутствовать в роутере, например выключенные через конфиг).
+// ignorePaths исключает служебные маршруты с настраиваемым путём (например /metrics).
+func Validate(routes gin.RoutesInfo, ignorePaths ...string) error {
+	var doc document
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return fmt.Errorf("failed to parse OpenAPI spec: %w", err)
+	}
+
+	ignored := make(map[string]bool, len(ignorePaths))
+	for _, path := range ignorePaths {
+		ignored[path] = true
+	}
+
+	registered := make(map[string]bool, len(routes))
+	var problems []string
+
+	for _, route := range routes {
+		if ignored[route.Path] {
+			continue
+		}
+
+		path := ginParamPattern.ReplaceAllString(route.Path, "{$1}")
+		method := strings.ToLower(route.Method)
+		registered[method+" "+path] = true
+
+		if _, ok := doc.Paths[path][method]; !ok {
+			problems = append(problems, fmt.Sprintf("route %s %s is not documented", route.Method, path))
+		}
+	}
+
+	for path, operations := range doc.Paths {
+		for method, operation := range operations {
+			if !operation.Optional && !registered[method+" "+path] {
+				problems = append(problems, fmt.Sprintf("documented %s %s is not registered", strings.ToUpper(method), path))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("OpenAPI spec is out of sync with routes: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// ValidateSchemas сверяет свойства схем components.schemas с JSON полями типов Go.
+// schemas - имя схемы и значение типа, которым обработчики читают или отдают её тело;
+// свойства из allOf и $ref учитываются, встроенные структуры раскрываются как в encoding/json.
+func ValidateSchemas(schemas map[string]any) error {
+	var doc document
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return fmt.Errorf("failed to parse OpenAPI spec: %w", err)
+	}
+
+	var problems []string
+	for name, value := range schemas {
+		s, ok := doc.Components.Schemas[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("schema %s is not documented", name))
+			continue
+		}
+
+		documented := make(map[string]bool)
+		if err := doc.collectProperties(s, documented, map[string]bool{name: true}); err != nil {
+			problems = append(problems, fmt.Sprintf("schema %s: %v", name, err))
+			continue
+		}
+
+		fields := make(map[string]bool)
+		jsonFields(reflect.TypeOf(value), fields)
+
+		for field := range fields {
+			if !documented[field] {
+				problems = append(problems, fmt.Sprintf("field %s.%s is not documented", name, field))
+			}
+		}
+		for property := range documented {
+			if !fields[property] {
+				problems = append(problems, fmt.Sprintf("documented %s.%s has no field", name, property))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("OpenAPI schemas are out of sync with handler types: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// collectProperties собирает имена свойств схемы вместе со схемами из allOf и $ref
+func (d *document) collectProperties(s schema, out map[string]bool, visited map[string]bool) error {
+	for property := range s.Properties {
+		out[property] = true
+	}
+
+	if s.Ref != "" {
+		name, ok := strings.CutPrefix(s.Ref, schemaRefPrefix)
+		if !ok {
+			return fmt.Errorf("unsupported $ref %s", s.Ref)
+		}
+		ref, ok := d.Components.Schemas[name]
+		if !ok {
+			return fmt.Errorf("$ref to missing schema %s", name)
+		}
+		if visited[name] {
+			return nil
+		}
+		visited[name] = true
+		if err := d.collectProperties(ref, out, visited); err != nil {
+			return err
+		}
+	}
+
+	for _, part := range s.AllOf {
+		if err := d.collectProperties(part, out, visited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonFields собирает имена полей, под которыми encoding/json сериализует структуру
+func jsonFields(t reflect.Type, out map[string]bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			jsonFields(field.Type, out)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		out[name] = true
+	}
+}
@@ -0,0 +1,150 @@
+// Package pagination общий формат постраничной выдачи для списочных эндпоинтов
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Параметры запроса
+const (
+	LimitParam  = "limit"
+	OffsetParam = "offset"
+	CursorParam = "cursor"
+)
+
+// cursorPrefix версия формата курсора
+const cursorPrefix = "o1:"
+
+// ErrInvalidParams некорректные параметры пагинации
+var ErrInvalidParams = errors.New("invalid pagination parameters")
+
+// Options лимиты конкретного эндпоинта
+type Options struct {
+	DefaultLimit int
+	MaxLimit     int
+}
+
+// DefaultOptions лимиты по умолчанию для списков
+var DefaultOptions = Options{DefaultLimit: 50, MaxLimit: 200}
+
+// Params разобранные параметры запроса
+type Params struct {
+	Limit  int
+	Offset int
+}
+
+// Page конверт ответа списочного эндпоинта
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// Parse разбирает limit, offset и cursor из query параметров.
+// Отсутствующий или нулевой limit заменяется значением по умолчанию, слишком большой - MaxLimit.
+// Отрицательные и нечисловые значения, а также повреждённый курсор возвращают ErrInvalidParams.
+func Parse(c *gin.Context, opts Options) (Params, error) {
+	return ParseValues(c.Query(LimitParam), c.Query(OffsetParam), c.Query(CursorParam), opts)
+}
+
+// ParseValues разбирает строковые значения параметров (см. Parse)
+func ParseValues(limitStr, offsetStr, cursor string, opts Options) (Params, error) {
+	opts = opts.normalize()
+	params := Params{Limit: opts.DefaultLimit}
+
+	if s := strings.TrimSpace(limitStr); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 0 {
+			return Params{}, fmt.Errorf("%w: limit must be a non-negative integer, got %q", ErrInvalidParams, limitStr)
+		}
+		if limit > 0 {
+			params.Limit = limit
+		}
+	}
+	if params.Limit > opts.MaxLimit {
+		params.Limit = opts.MaxLimit
+	}
+
+	if s := strings.TrimSpace(offsetStr); s != "" {
+		offset, err := strconv.Atoi(s)
+		if err != nil || offset < 0 {
+			return Params{}, fmt.Errorf("%w: offset must be a non-negative integer, got %q", ErrInvalidParams, offsetStr)
+		}
+		params.Offset = offset
+	}
+
+	// Курсор имеет приоритет над offset
+	if s := strings.TrimSpace(cursor); s != "" {
+		offset, err := DecodeCursor(s)
+		if err != nil {
+			return Params{}, err
+		}
+		params.Offset = offset
+	}
+
+	return params, nil
+}
+
+// NewPage собирает конверт ответа для страницы items из total элементов
+func NewPage[T any](items []T, total int, params Params) Page[T] {
+	if items == nil {
+		items = []T{}
+	}
+
+	page := Page[T]{
+		Items:  items,
+		Total:  total,
+		Limit:  params.Limit,
+		Offset: params.Offset,
+	}
+
+	next := params.Offset + len(items)
+	if len(items) > 0 && next < total {
+		page.HasMore = true
+		page.NextCursor = EncodeCursor(next)
+	}
+
+	return page
+}
+
+// EncodeCursor кодирует смещение в непрозрачный курсор
+func EncodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+// DecodeCursor восстанавливает смещение из курсора
+func DecodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, fmt.Errorf("%w: malformed cursor", ErrInvalidParams)
+	}
+
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), cursorPrefix))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("%w: malformed cursor", ErrInvalidParams)
+	}
+
+	return offset, nil
+}
+
+func (o Options) normalize() Options {
+	if o.MaxLimit <= 0 {
+		o.MaxLimit = DefaultOptions.MaxLimit
+	}
+	if o.DefaultLimit <= 0 {
+		o.DefaultLimit = DefaultOptions.DefaultLimit
+	}
+	if o.DefaultLimit > o.MaxLimit {
+		o.DefaultLimit = o.MaxLimit
+	}
+	return o
+}
@@ -0,0 +1,76 @@
+package pagination
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseValues(t *testing.T) {
+	opts := Options{DefaultLimit: 20, MaxLimit: 100}
+
+	tests := []struct {
+		name                  string
+		limit, offset, cursor string
+		want                  Params
+		wantErr               bool
+	}{
+		{name: "missing values", want: Params{Limit: 20}},
+		{name: "zero limit", limit: "0", want: Params{Limit: 20}},
+		{name: "explicit values", limit: "10", offset: "30", want: Params{Limit: 10, Offset: 30}},
+		{name: "spaces", limit: " 10 ", offset: " 5", want: Params{Limit: 10, Offset: 5}},
+		{name: "absurd limit is clamped", limit: "1000000", want: Params{Limit: 100}},
+		{name: "cursor overrides offset", offset: "3", cursor: EncodeCursor(40), want: Params{Limit: 20, Offset: 40}},
+		{name: "negative limit", limit: "-1", wantErr: true},
+		{name: "negative offset", offset: "-5", wantErr: true},
+		{name: "non-numeric limit", limit: "ten", wantErr: true},
+		{name: "overflowing offset", offset: "99999999999999999999999", wantErr: true},
+		{name: "malformed cursor", cursor: "not-a-cursor", wantErr: true},
+		{name: "cursor of another format", cursor: "b2Zmc2V0OjE", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseValues(tt.limit, tt.offset, tt.cursor, opts)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidParams) {
+				t.Errorf("%s: err = %v, want ErrInvalidParams", tt.name, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %+v, %v; want %+v", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseValuesNormalizesOptions(t *testing.T) {
+	// Без лимитов эндпоинта действуют значения по умолчанию, DefaultLimit не больше MaxLimit
+	got, err := ParseValues("", "", "", Options{})
+	if err != nil || got.Limit != DefaultOptions.DefaultLimit {
+		t.Errorf("empty options: got %+v, %v", got, err)
+	}
+	got, err = ParseValues("", "", "", Options{DefaultLimit: 500, MaxLimit: 30})
+	if err != nil || got.Limit != 30 {
+		t.Errorf("default above max: got %+v, %v", got, err)
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	page := NewPage([]int{1, 2, 3}, 10, Params{Limit: 3, Offset: 3})
+	if !page.HasMore || page.Total != 10 || page.Offset != 3 {
+		t.Fatalf("page = %+v", page)
+	}
+	offset, err := DecodeCursor(page.NextCursor)
+	if err != nil || offset != 6 {
+		t.Errorf("next cursor offset = %d, %v; want 6", offset, err)
+	}
+
+	last := NewPage([]int{10}, 10, Params{Limit: 3, Offset: 9})
+	if last.HasMore || last.NextCursor != "" {
+		t.Errorf("last page = %+v, want no next cursor", last)
+	}
+
+	empty := NewPage[int](nil, 0, Params{Limit: 3})
+	if empty.Items == nil || empty.HasMore {
+		t.Errorf("empty page = %+v, want non-nil items", empty)
+	}
+}
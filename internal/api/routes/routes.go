@@ -3,7 +3,11 @@ package routes
 import (
 	"LLM_Chat/internal/api/handlers"
 	"LLM_Chat/internal/api/middleware"
+	"LLM_Chat/internal/api/openapi"
 	"LLM_Chat/internal/config"
+	"LLM_Chat/internal/metrics"
+	contextmgr "LLM_Chat/internal/service/context"
+	"LLM_Chat/pkg/llm"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -16,6 +20,11 @@ func SetupRoutes(
 	summaryHandler *handlers.SummaryHandler,
 	healthHandler *handlers.HealthHandler,
 	modelsHandler *handlers.ModelsHandler,
+	mcpHandler *handlers.MCPHandler,
+	adminHandler *handlers.AdminHandler,
+	eventsHandler *handlers.EventsHandler,
+	usersHandler *handlers.UsersHandler,
+	appMetrics *metrics.Metrics,
 ) *gin.Engine {
 
 	// Настройка Gin mode
@@ -28,35 +37,76 @@ func SetupRoutes(
 	r := gin.New()
 
 	// Middleware
-	r.Use(gin.Recovery())
-	r.Use(middleware.CORSMiddleware())
+	r.Use(middleware.RequestIDMiddleware())
+	r.Use(middleware.RecoveryMiddleware(logger, appMetrics))
+
+	// Публичный /metrics регистрируется до остальных middleware,
+	// чтобы на него не распространялись аутентификация и ограничения
+	metricsEnabled := cfg.Metrics.Enabled && appMetrics != nil
+	if metricsEnabled && cfg.Metrics.Public {
+		r.GET(cfg.Metrics.Path, gin.WrapH(appMetrics.Handler()))
+	}
+
+	if metricsEnabled {
+		r.Use(middleware.MetricsMiddleware(appMetrics, cfg.Metrics.Path))
+	}
+	r.Use(middleware.LimitsMiddleware(cfg.Server.Limits))
+	if cfg.Server.Compression.Enabled {
+		r.Use(middleware.GzipMiddleware(cfg.Server.Compression.Level, cfg.Server.Compression.MinSize))
+	}
+	r.Use(middleware.CORSMiddleware(cfg.Server.CORS))
 	r.Use(middleware.LoggingMiddleware(logger))
-	r.Use(middleware.TimeoutMiddleware(cfg.Server.ReadTimeout))
+
+	// OpenRouter работает без MCP: инструменты, ресурсы и промпты серверов недоступны
+	mcpEnabled := llm.HasMCPSupport(cfg.LLM.Provider)
+	providerDescription := "Google Gemini with MCP tool integration"
+	if !mcpEnabled {
+		providerDescription = "OpenRouter chat completions without MCP tools"
+	}
 
 	// Добавляем информацию о текущем провайдере в контекст
 	r.Use(func(c *gin.Context) {
-		c.Set("current_provider", "gemini")
-		c.Set("mcp_enabled", true)
+		c.Set("current_provider", cfg.LLM.Provider)
+		c.Set("mcp_enabled", mcpEnabled)
 		c.Set("mcp_server_url", cfg.MCP.ServerURL)
 		c.Next()
 	})
 
-	// Health check
-	r.GET("/health", healthHandler.Check)
+	// Непубличный /metrics доступен только с токеном администратора
+	if metricsEnabled && !cfg.Metrics.Public {
+		r.GET(cfg.Metrics.Path, middleware.AdminAuthMiddleware(cfg.Admin.Token), gin.WrapH(appMetrics.Handler()))
+	}
+
+	// Health checks
+	r.GET("/health", healthHandler.Live)
+	r.GET("/health/live", healthHandler.Live)
+	r.GET("/health/ready", healthHandler.Ready)
 
 	// API routes
 	api := r.Group("/api/v1")
 	{
+		// Документация API
+		api.GET("/openapi.json", openapi.SpecHandler)
+		if cfg.Server.SwaggerUI {
+			api.GET("/docs", openapi.SwaggerUIHandler("/api/v1/openapi.json"))
+		}
+
 		// Chat endpoints
 		chat := api.Group("/chat")
+		chat.Use(middleware.TimeoutMiddleware(cfg.Server.RouteTimeouts.Chat))
 		{
 			// Основные операции с чатом
 			chat.POST("", chatHandler.SendMessage)
 
+			// Возобновление потокового ответа (SSE, без таймаута группы)
+			chat.GET("/:session_id/stream/:message_id", chatHandler.ResumeStream)
+
 			// Операции с сессиями
 			chat.GET("/:session_id", chatHandler.GetSession)
 			chat.DELETE("/:session_id", chatHandler.DeleteSession)
 			chat.POST("/:session_id/clear", chatHandler.ClearSession)
+			chat.PUT("/:session_id/language", chatHandler.SetSessionLanguage)
+			chat.POST("/:session_id/clone", chatHandler.CloneSession)
 
 			// История сообщений
 			chat.GET("/:session_id/history", chatHandler.GetHistory)
@@ -68,10 +118,17 @@ func SetupRoutes(
 			// Операции с резюме
 			chat.GET("/:session_id/summary", summaryHandler.GetSummary)
 			chat.DELETE("/:session_id/summary", summaryHandler.DeleteSummary)
+			chat.POST("/:session_id/summaries", chatHandler.CreateRangeSummary)
+			chat.GET("/:session_id/summaries/:summary_id/messages", chatHandler.GetSummarySources)
+			chat.POST("/:session_id/summaries/:summary_id/expand", chatHandler.ExpandSummary)
+
+			// Системные события сессии (SSE, без таймаута группы)
+			chat.GET("/:session_id/events", eventsHandler.StreamSessionEvents)
 		}
 
 		// Models and Providers endpoints
 		models := api.Group("/models")
+		models.Use(middleware.TimeoutMiddleware(cfg.Server.RouteTimeouts.Metadata))
 		{
 			// Получение информации о доступных моделях
 			models.GET("", modelsHandler.GetAvailableModels)
@@ -83,18 +140,27 @@ func SetupRoutes(
 			models.POST("/validate", modelsHandler.ValidateProviderConfig)
 		}
 
+		// Настройки пользователей
+		users := api.Group("/users")
+		users.Use(middleware.TimeoutMiddleware(cfg.Server.RouteTimeouts.Metadata))
+		{
+			users.GET("/:user_id/preferences", usersHandler.GetPreferences)
+			users.PUT("/:user_id/preferences", usersHandler.UpdatePreferences)
+		}
+
 		// Provider information endpoints
 		providers := api.Group("/providers")
+		providers.Use(middleware.TimeoutMiddleware(cfg.Server.RouteTimeouts.Metadata))
 		{
 			// Получение информации о поддерживаемых провайдерах
 			providers.GET("", func(c *gin.Context) {
 				c.JSON(200, gin.H{
-					"current":   "gemini",
-					"supported": []string{"gemini"},
-					"default":   "gemini",
+					"current":   cfg.LLM.Provider,
+					"supported": llm.GetSupportedProviders(logger),
+					"default":   llm.ProviderGemini,
 					"features": map[string]interface{}{
-						"mcp_enabled":   true,
-						"tool_calling":  true,
+						"mcp_enabled":   mcpEnabled,
+						"tool_calling":  mcpEnabled,
 						"multimodal":    true,
 						"large_context": true,
 					},
@@ -104,11 +170,11 @@ func SetupRoutes(
 			// Получение информации о текущем провайдере
 			providers.GET("/current", func(c *gin.Context) {
 				c.JSON(200, gin.H{
-					"provider":    "gemini",
+					"provider":    cfg.LLM.Provider,
 					"model":       cfg.LLM.Model,
-					"description": "Google Gemini with MCP tool integration",
+					"description": providerDescription,
 					"mcp": gin.H{
-						"enabled":            true,
+						"enabled":            mcpEnabled,
 						"server_url":         cfg.MCP.ServerURL,
 						"system_prompt_path": cfg.MCP.SystemPromptPath,
 						"max_iterations":     cfg.MCP.MaxIterations,
@@ -119,54 +185,190 @@ func SetupRoutes(
 
 		// MCP specific endpoints
 		mcp := api.Group("/mcp")
+		mcp.Use(middleware.TimeoutMiddleware(cfg.Server.RouteTimeouts.Metadata))
 		{
 			// Получение информации о MCP сервере
 			mcp.GET("/info", func(c *gin.Context) {
-				c.JSON(200, gin.H{
-					"enabled":            true,
-					"server_url":         cfg.MCP.ServerURL,
-					"system_prompt_path": cfg.MCP.SystemPromptPath,
-					"max_iterations":     cfg.MCP.MaxIterations,
-					"description":        "Model Context Protocol integration for enhanced AI capabilities",
-				})
+				info := gin.H{
+					"enabled":                   mcpEnabled,
+					"transport":                 cfg.MCP.Transport,
+					"server_url":                cfg.MCP.ServerURL,
+					"server_path":               cfg.MCP.ServerPath,
+					"system_prompt_path":        cfg.MCP.SystemPromptPath,
+					"max_iterations":            cfg.MCP.MaxIterations,
+					"max_iterations_limit":      cfg.Chat.MaxIterationsLimit,
+					"max_tool_result_bytes":     cfg.MCP.MaxToolResultBytes,
+					"max_concurrent_tool_calls": cfg.MCP.MaxConcurrentToolCalls,
+					"tool_call_timeout":         cfg.MCP.ToolCallTimeout.String(),
+					"allowed_tools":             cfg.MCP.AllowedTools,
+					"denied_tools":              cfg.MCP.DeniedTools,
+					"tools_refresh_interval":    cfg.MCP.ToolsRefreshInterval.String(),
+					"reconnect_attempts":        cfg.MCP.ReconnectAttempts,
+					"debug_endpoints":           cfg.MCP.DebugEndpoints,
+					"description":               "Model Context Protocol integration for enhanced AI capabilities",
+				}
+
+				// Число инструментов требует запроса к серверу; его недоступность не мешает остальной информации
+				counts, err := mcpHandler.ToolCounts(c.Request.Context())
+				if err != nil {
+					info["tools_error"] = err.Error()
+				} else {
+					info["tools"] = counts
+				}
+				// Несколько серверов: число инструментов и состояние соединения каждого
+				if len(counts.Servers) > 0 {
+					info["servers"] = counts.Servers
+				}
+
+				c.JSON(200, info)
 			})
 
 			// Проверка статуса MCP соединения
-			mcp.GET("/status", func(c *gin.Context) {
-				// Здесь можно добавить проверку доступности MCP сервера
-				c.JSON(200, gin.H{
-					"status":     "configured", // можно расширить до проверки связи
-					"server_url": cfg.MCP.ServerURL,
-					"message":    "MCP server is configured and ready",
-				})
-			})
+			mcp.GET("/status", mcpHandler.GetStatus)
+
+			// Инструменты, доступные модели; ?refresh=true заново запрашивает список у серверов
+			mcp.GET("/tools", mcpHandler.ListTools)
+
+			// Ресурсы серверов, которые запрос чата подключает к контексту полем resource_uris
+			mcp.GET("/resources", mcpHandler.ListResources)
+
+			// Промпты серверов, которые запрос чата выбирает полем prompt_name
+			mcp.GET("/prompts", mcpHandler.ListPrompts)
+		}
+
+		// Прямой вызов инструмента для отладки; вызов инструмента может длиться дольше
+		// информационных запросов, поэтому действует таймаут чата
+		if cfg.MCP.DebugEndpoints {
+			mcpDebug := api.Group("/mcp")
+			mcpDebug.Use(middleware.TimeoutMiddleware(cfg.Server.RouteTimeouts.Chat))
+			mcpDebug.POST("/tools/:name/call", mcpHandler.CallTool)
 		}
 
 		// Config endpoints (для отладки и мониторинга)
 		configep := api.Group("/config")
+		configep.Use(middleware.TimeoutMiddleware(cfg.Server.RouteTimeouts.Metadata))
 		{
 			// Получение информации о конфигурации (без секретов)
 			configep.GET("/info", func(c *gin.Context) {
 				configSources := config.GetConfigSource(cfg)
+				shrinkConcurrency, _ := cfg.ShrinkConcurrency()
+
+				// Заголовки и окружение серверов могут содержать секреты и не выводятся
+				mcpServers := make([]gin.H, 0, len(cfg.MCP.Servers))
+				for _, server := range cfg.MCP.Servers {
+					mcpServers = append(mcpServers, gin.H{
+						"name":        server.Name,
+						"transport":   server.Transport,
+						"server_url":  server.ServerURL,
+						"server_path": server.ServerPath,
+					})
+				}
 
 				c.JSON(200, gin.H{
 					"server": gin.H{
 						"host": cfg.Server.Host,
 						"port": cfg.Server.Port,
+						"limits": gin.H{
+							"max_body_size":       cfg.Server.Limits.MaxBodySize,
+							"body_size_overrides": cfg.Server.Limits.BodySizeOverrides,
+							"max_header_bytes":    cfg.Server.Limits.MaxHeaderBytes,
+							"max_header_count":    cfg.Server.Limits.MaxHeaderCount,
+							"body_read_timeout":   cfg.Server.Limits.BodyReadTimeout.String(),
+						},
 					},
 					"chat": gin.H{
 						"max_messages_per_session": cfg.Chat.MaxMessagesPerSession,
 						"context_window_size":      cfg.Chat.ContextWindowSize,
+						"max_context_tokens":       cfg.Chat.MaxContextTokens,
+						"message_token_ratio":      cfg.Chat.MessageTokenRatio,
+						"summary_token_ratio":      cfg.Chat.SummaryTokenRatio,
+						"max_bulk_summaries":       cfg.Chat.MaxBulkSummaries,
+						"bulk_token_ratio":         cfg.Chat.BulkTokenRatio,
+						"summary_role":             cfg.Chat.SummaryRole,
+						"exclude_tool_messages":    cfg.Chat.ExcludeToolMessages,
+						"compression_webhook":      cfg.Chat.CompressionWebhookURL != "",
+						// Удаление исходных сообщений после сжатия: при enabled история
+						// старше срока доступна только в виде резюме
+						"purge_compressed_messages": gin.H{
+							"enabled":          cfg.Chat.PurgeCompressedAfter > 0,
+							"after":            cfg.Chat.PurgeCompressedAfter.String(),
+							"cleanup_interval": cfg.Chat.Cleanup.Interval.String(),
+						},
+						// Фоновое сжатие сессий без сообщений дольше idle_after
+						"idle_compression": gin.H{
+							"enabled":             cfg.Chat.IdleCompression.Interval > 0,
+							"interval":            cfg.Chat.IdleCompression.Interval.String(),
+							"idle_after":          cfg.Chat.IdleCompression.IdleAfter.String(),
+							"min_active_messages": cfg.Chat.IdleCompression.MinActiveMessages,
+						},
+						"recall": gin.H{
+							"enabled":         cfg.Chat.Recall.Enabled,
+							"embedding_model": cfg.Chat.Recall.EmbeddingModel,
+							"top_k":           cfg.Chat.Recall.TopK,
+							"min_similarity":  cfg.Chat.Recall.MinSimilarity,
+						},
+						"resources": gin.H{
+							"max_bytes": cfg.Chat.Resources.MaxBytes,
+							"cache_ttl": cfg.Chat.Resources.CacheTTL.String(),
+						},
+						"tool_calls": gin.H{
+							"max_argument_length": cfg.Chat.ToolCalls.MaxArgumentLength,
+							"max_result_bytes":    cfg.Chat.ToolCalls.MaxResultBytes,
+						},
 					},
 					"llm": gin.H{
-						"provider": "gemini",
-						"model":    cfg.LLM.Model,
+						"provider":       cfg.LLM.Provider,
+						"model":          cfg.LLM.Model,
+						"timeout":        cfg.LLM.Timeout.String(),
+						"shrink_timeout": cfg.ToShrinkProviderConfig().Timeout.String(),
+						// Поиск Google по умолчанию; запрос переопределяет его полем grounding
+						"grounding": gin.H{
+							"google_search": cfg.Grounding.GoogleSearch,
+						},
+						// Параметры сэмплирования; клиент сжатия истории использует их с переопределениями llm.shrink
+						"generation":        cfg.LLM.Generation,
+						"shrink_generation": cfg.ToShrinkProviderConfig().Generation,
+						"shrink_model":      cfg.ToShrinkProviderConfig().Model,
+						"safety":            cfg.LLM.Safety,
+						// Режим вызова инструментов; запрос переопределяет его полем tool_mode
+						"tool_mode":        cfg.LLM.ToolMode,
+						"shrink_tool_mode": cfg.ToShrinkProviderConfig().ToolMode,
+						"retry": gin.H{
+							"max_attempts":  cfg.LLM.Retry.MaxAttempts,
+							"initial_delay": cfg.LLM.Retry.InitialDelay.String(),
+							"max_delay":     cfg.LLM.Retry.MaxDelay.String(),
+							"multiplier":    cfg.LLM.Retry.Multiplier,
+						},
+						"request_retry": gin.H{
+							"max_retries":        cfg.LLM.RequestRetry.MaxRetries,
+							"initial_delay":      cfg.LLM.RequestRetry.InitialDelay.String(),
+							"max_delay":          cfg.LLM.RequestRetry.MaxDelay.String(),
+							"backoff_multiplier": cfg.LLM.RequestRetry.BackoffMultiplier,
+						},
+						"max_concurrent_requests":        cfg.LLM.MaxConcurrentRequests,
+						"queue_timeout":                  cfg.LLM.QueueTimeout.String(),
+						"shrink_max_concurrent_requests": shrinkConcurrency,
+						"circuit_breaker": gin.H{
+							"failure_threshold": cfg.LLM.CircuitBreaker.FailureThreshold,
+							"cool_down":         cfg.LLM.CircuitBreaker.CoolDown.String(),
+						},
 						// НЕ включаем API ключ в ответ
 					},
 					"mcp": gin.H{
-						"server_url":         cfg.MCP.ServerURL,
-						"system_prompt_path": cfg.MCP.SystemPromptPath,
-						"max_iterations":     cfg.MCP.MaxIterations,
+						"transport":                 cfg.MCP.Transport,
+						"server_path":               cfg.MCP.ServerPath,
+						"server_url":                cfg.MCP.ServerURL,
+						"system_prompt_path":        cfg.MCP.SystemPromptPath,
+						"max_iterations":            cfg.MCP.MaxIterations,
+						"max_tool_result_bytes":     cfg.MCP.MaxToolResultBytes,
+						"max_concurrent_tool_calls": cfg.MCP.MaxConcurrentToolCalls,
+						"tool_call_timeout":         cfg.MCP.ToolCallTimeout.String(),
+						"allowed_tools":             cfg.MCP.AllowedTools,
+						"denied_tools":              cfg.MCP.DeniedTools,
+						"tools_refresh_interval":    cfg.MCP.ToolsRefreshInterval.String(),
+						"reconnect_attempts":        cfg.MCP.ReconnectAttempts,
+						"debug_endpoints":           cfg.MCP.DebugEndpoints,
+						"servers":                   mcpServers,
 					},
 					"sources": configSources,
 				})
@@ -189,7 +391,38 @@ func SetupRoutes(
 				})
 			})
 		}
+
+		// Административные операции (требуют токен администратора)
+		admin := api.Group("/admin")
+		admin.Use(middleware.AdminAuthMiddleware(cfg.Admin.Token))
+		{
+			// Переинициализация MCP сессии и клиента Gemini; таймаут задаётся admin.reinitialize_timeout
+			admin.POST("/llm/reinitialize", adminHandler.ReinitializeLLM)
+
+			// Журнал аудита вызовов MCP инструментов
+			admin.GET("/tool-invocations", adminHandler.ListToolInvocations)
+
+			// Массовое удаление сессий по списку или фильтру; dry_run только показывает выборку
+			admin.POST("/sessions/delete", adminHandler.DeleteSessions)
+		}
+	}
+
+	// Спецификация поддерживается вручную - предупреждаем о расхождениях с маршрутами и типами
+	if err := openapi.Validate(r.Routes(), cfg.Metrics.Path); err != nil {
+		logger.Warn("OpenAPI specification drift detected", zap.Error(err))
+	}
+	if err := openapi.ValidateSchemas(DocumentedSchemas); err != nil {
+		logger.Warn("OpenAPI schema drift detected", zap.Error(err))
 	}
 
 	return r
 }
+
+// DocumentedSchemas схемы спецификации и типы обработчиков, поля которых они описывают
+var DocumentedSchemas = map[string]any{
+	"ChatRequest":     handlers.ChatRequest{},
+	"ChatResponse":    handlers.ChatResponse{},
+	"ErrorResponse":   handlers.ErrorResponse{},
+	"HistoryResponse": handlers.HistoryResponse{},
+	"ContextInfo":     contextmgr.ContextInfo{},
+}
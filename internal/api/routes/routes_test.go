@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"testing"
+
+	"LLM_Chat/internal/api/handlers"
+	"LLM_Chat/internal/api/openapi"
+	"LLM_Chat/internal/config"
+	"LLM_Chat/internal/metrics"
+
+	"go.uber.org/zap"
+)
+
+// TestRoutesMatchOpenAPISpec падает при расхождении спецификации и зарегистрированных маршрутов:
+// при старте сервера расхождение только записывается в журнал
+func TestRoutesMatchOpenAPISpec(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Metrics.Enabled = true
+	cfg.Metrics.Public = true
+	cfg.Metrics.Path = "/metrics"
+	cfg.Server.SwaggerUI = true
+
+	r := SetupRoutes(cfg, zap.NewNop(),
+		&handlers.ChatHandler{}, &handlers.SummaryHandler{}, &handlers.HealthHandler{},
+		&handlers.ModelsHandler{}, &handlers.MCPHandler{}, &handlers.AdminHandler{},
+		&handlers.EventsHandler{}, &handlers.UsersHandler{}, metrics.New())
+
+	if err := openapi.Validate(r.Routes(), cfg.Metrics.Path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestHandlerTypesMatchOpenAPISchemas падает, если JSON поля типов обработчиков
+// расходятся со свойствами их схем в спецификации
+func TestHandlerTypesMatchOpenAPISchemas(t *testing.T) {
+	if err := openapi.ValidateSchemas(DocumentedSchemas); err != nil {
+		t.Fatal(err)
+	}
+}
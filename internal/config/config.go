@@ -1,8 +1,15 @@
 package config
 
 import (
+	"LLM_Chat/internal/language"
+	contextmgr "LLM_Chat/internal/service/context"
+	"LLM_Chat/internal/service/summary"
+	"LLM_Chat/pkg/llm"
 	"LLM_Chat/pkg/llm/providers"
+	"LLM_Chat/pkg/redact"
 	"fmt"
+	"net/url"
+	"slices"
 	"strings"
 	"time"
 
@@ -10,12 +17,19 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Logging  LoggingConfig  `mapstructure:"logging"`
-	Chat     ChatConfig     `mapstructure:"chat"`
-	LLM      LLMConfig      `mapstructure:"llm"`
-	MCP      MCPConfig      `mapstructure:"mcp"`
+	Server    ServerConfig    `mapstructure:"server"`
+	Database  DatabaseConfig  `mapstructure:"database"`
+	Logging   LoggingConfig   `mapstructure:"logging"`
+	Chat      ChatConfig      `mapstructure:"chat"`
+	Summary   SummaryConfig   `mapstructure:"summary"`
+	LLM       LLMConfig       `mapstructure:"llm"`
+	MCP       MCPConfig       `mapstructure:"mcp"`
+	Health    HealthConfig    `mapstructure:"health"`
+	Metrics   MetricsConfig   `mapstructure:"metrics"`
+	Admin     AdminConfig     `mapstructure:"admin"`
+	Events    EventsConfig    `mapstructure:"events"`
+	Redaction RedactionConfig `mapstructure:"redaction"`
+	Grounding GroundingConfig `mapstructure:"grounding"`
 }
 
 type ServerConfig struct {
@@ -23,6 +37,54 @@ type ServerConfig struct {
 	Port         int           `mapstructure:"port"`
 	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
 	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+
+	ShutdownDrainTimeout time.Duration `mapstructure:"shutdown_drain_timeout"` // время на завершение активных SSE потоков при остановке
+
+	RouteTimeouts RouteTimeoutsConfig `mapstructure:"route_timeouts"`
+	SwaggerUI     bool                `mapstructure:"swagger_ui"` // страница /api/v1/docs
+
+	Compression CompressionConfig `mapstructure:"compression"`
+	CORS        CORSConfig        `mapstructure:"cors"`
+	Limits      LimitsConfig      `mapstructure:"limits"`
+}
+
+// LimitsConfig ограничения на размер и скорость получения запросов
+type LimitsConfig struct {
+	MaxBodySize       int64            `mapstructure:"max_body_size"`       // лимит тела запроса по умолчанию, байт
+	BodySizeOverrides map[string]int64 `mapstructure:"body_size_overrides"` // маршрут gin (/api/v1/chat) -> лимит
+	MaxHeaderBytes    int              `mapstructure:"max_header_bytes"`
+	MaxHeaderCount    int              `mapstructure:"max_header_count"`
+	BodyReadTimeout   time.Duration    `mapstructure:"body_read_timeout"` // дедлайн чтения тела для медленных клиентов
+}
+
+// BodyLimit возвращает лимит тела запроса для маршрута
+func (l LimitsConfig) BodyLimit(route string) int64 {
+	if limit, ok := l.BodySizeOverrides[route]; ok {
+		return limit
+	}
+	return l.MaxBodySize
+}
+
+// CORSConfig политика CORS (origin вида https://*.example.com разрешает поддомены)
+type CORSConfig struct {
+	AllowedOrigins   []string      `mapstructure:"allowed_origins"`
+	AllowedMethods   []string      `mapstructure:"allowed_methods"`
+	AllowedHeaders   []string      `mapstructure:"allowed_headers"`
+	AllowCredentials bool          `mapstructure:"allow_credentials"`
+	MaxAge           time.Duration `mapstructure:"max_age"`
+}
+
+// CompressionConfig настройки gzip сжатия ответов
+type CompressionConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	Level   int  `mapstructure:"level"`    // 1-9, -1 - уровень по умолчанию
+	MinSize int  `mapstructure:"min_size"` // минимальный размер ответа в байтах для сжатия
+}
+
+// RouteTimeoutsConfig таймауты обработки запросов по группам маршрутов (0 - без ограничения)
+type RouteTimeoutsConfig struct {
+	Chat     time.Duration `mapstructure:"chat"`     // запросы к LLM с циклом инструментов
+	Metadata time.Duration `mapstructure:"metadata"` // информационные endpoints (models, providers, mcp, config)
 }
 
 type DatabaseConfig struct {
@@ -51,13 +113,161 @@ type ChatConfig struct {
 	MessageCompressionRatio float64 `mapstructure:"message_compression_ratio"`
 	SummaryCompressionRatio float64 `mapstructure:"summary_compression_ratio"`
 	MinMessagesInWindow     int     `mapstructure:"min_messages_in_window"`
+
+	// Language язык ответов по умолчанию: "auto" (по последнему сообщению пользователя) или код языка;
+	// сессия может переопределить значение
+	Language string `mapstructure:"language"`
+
+	// MaxIterationsLimit предел max_iterations, который клиент может запросить для цикла вызова
+	// инструментов; не меньше mcp.max_iterations
+	MaxIterationsLimit int `mapstructure:"max_iterations_limit"`
+
+	// MaxOutputTokensLimit предел max_output_tokens, который клиент может запросить для ответа;
+	// действует вместе с ограничением модели
+	MaxOutputTokensLimit int `mapstructure:"max_output_tokens_limit"`
+
+	// MaxActiveAnchors сколько якорей активных резюме отдавать в метаданных контекста; 0 - не отдавать
+	MaxActiveAnchors int `mapstructure:"max_active_anchors"`
+
+	// MaxContextTokens бюджет контекста в токенах (CountTokens основного клиента): самые старые
+	// несистемные сообщения отбрасываются, пока контекст его превышает; 0 - только context_window_size
+	MaxContextTokens int `mapstructure:"max_context_tokens"`
+
+	// MessageTokenRatio и SummaryTokenRatio доли max_context_tokens, которые могут занять активные
+	// сообщения и резюме; при превышении сжатие запускается, даже если порог по числу не достигнут.
+	// Действуют только при max_context_tokens > 0
+	MessageTokenRatio float64 `mapstructure:"message_token_ratio"`
+	SummaryTokenRatio float64 `mapstructure:"summary_token_ratio"`
+
+	// MaxBulkSummaries сколько bulk резюме (уровень 2) держать в контексте: сверх этого старые
+	// сворачиваются в дайджест сессии (уровень 3); 0 - третий уровень выключен.
+	// BulkTokenRatio доля max_context_tokens для bulk резюме, действует при max_context_tokens > 0
+	MaxBulkSummaries int     `mapstructure:"max_bulk_summaries"`
+	BulkTokenRatio   float64 `mapstructure:"bulk_token_ratio"`
+
+	// SummaryRole роль сообщений с резюме в контексте модели (system, assistant или user),
+	// SummaryTemplate их текст: {summary} заменяется якорями и текстом резюме
+	SummaryRole     string `mapstructure:"summary_role"`
+	SummaryTemplate string `mapstructure:"summary_template"`
+
+	// ExcludeToolMessages сообщения вызовов инструментов сжимаются вместе с окружающим диалогом,
+	// но их результаты не передаются модели, составляющей резюме
+	ExcludeToolMessages bool `mapstructure:"exclude_tool_messages"`
+
+	// PurgeCompressedAfter через сколько после сжатия исходные сообщения удаляются задачей очистки,
+	// и долговременной записью остаются только резюме; 0 - сообщения хранятся всегда
+	PurgeCompressedAfter time.Duration `mapstructure:"purge_compressed_after"`
+
+	// CompressionWebhookURL адрес, на который после каждого успешного сжатия отправляется POST
+	// с его итогом (contextmgr.CompressionEvent); пустая строка - уведомления выключены
+	CompressionWebhookURL string `mapstructure:"compression_webhook_url"`
+	// CompressionWebhookTimeout ограничение одной попытки доставки уведомления
+	CompressionWebhookTimeout time.Duration `mapstructure:"compression_webhook_timeout"`
+
+	Cleanup         CleanupConfig         `mapstructure:"cleanup"`
+	IdleCompression IdleCompressionConfig `mapstructure:"idle_compression"`
+	StreamReplay    StreamReplayConfig    `mapstructure:"stream_replay"`
+	Recall          RecallConfig          `mapstructure:"recall"`
+	Resources       ResourcesConfig       `mapstructure:"resources"`
+	ToolCalls       ToolCallsConfig       `mapstructure:"tool_calls"`
+}
+
+// ToolCallsConfig вызовы инструментов в ответе API (tool_calls): длинные аргументы и результаты обрезаются
+type ToolCallsConfig struct {
+	MaxArgumentLength int `mapstructure:"max_argument_length"` // предел строкового аргумента в символах
+	MaxResultBytes    int `mapstructure:"max_result_bytes"`    // предел JSON результата в байтах
+}
+
+// ResourcesConfig ресурсы MCP, которые запрос подключает к контексту полем resource_uris
+type ResourcesConfig struct {
+	MaxBytes int           `mapstructure:"max_bytes"` // предел текста одного ресурса; больший ресурс отклоняется
+	CacheTTL time.Duration `mapstructure:"cache_ttl"` // сколько прочитанный ресурс хранится для сессии; 0 - без кэша
+}
+
+// RecallConfig семантический поиск по сжатой истории. Сообщения сохраняются с эмбеддингами
+// основного провайдера, а сжатые сообщения, близкие к вопросу, возвращаются в контекст отдельным блоком.
+// Сообщения, сохранённые до включения, эмбеддингов не имеют и в поиске не участвуют.
+type RecallConfig struct {
+	Enabled        bool    `mapstructure:"enabled"`
+	EmbeddingModel string  `mapstructure:"embedding_model"`
+	TopK           int     `mapstructure:"top_k"`          // максимум сообщений в блоке
+	MinSimilarity  float64 `mapstructure:"min_similarity"` // порог косинусного сходства (0, 1]
+	MaxTokens      int     `mapstructure:"max_tokens"`     // бюджет блока в токенах
+}
+
+// StreamReplayConfig буфер событий потоковых ответов для возобновления после разрыва соединения.
+// Память ограничена MaxStreams * BufferSize событиями.
+type StreamReplayConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	BufferSize int           `mapstructure:"buffer_size"` // событий на поток, старые вытесняются
+	TTL        time.Duration `mapstructure:"ttl"`         // сколько хранить завершённый поток
+	MaxStreams int           `mapstructure:"max_streams"` // потоков в буфере одновременно
+}
+
+// CleanupConfig фоновая задача обслуживания сообщений
+type CleanupConfig struct {
+	Interval       time.Duration `mapstructure:"interval"`         // 0 отключает задачу
+	PendingTimeout time.Duration `mapstructure:"pending_timeout"`  // после этого времени pending сообщение считается зависшим
+	PurgeBatchSize int           `mapstructure:"purge_batch_size"` // сжатых сообщений, удаляемых одним запросом
+}
+
+// IdleCompressionConfig фоновое сжатие сессий, в которых давно не было сообщений: контекст
+// сжимается заранее, а не на первом запросе после перерыва
+type IdleCompressionConfig struct {
+	Interval          time.Duration `mapstructure:"interval"`            // период поиска сессий; 0 отключает задачу
+	IdleAfter         time.Duration `mapstructure:"idle_after"`          // сколько сессия не обновлялась
+	MinActiveMessages int           `mapstructure:"min_active_messages"` // сжимаются сессии, где несжатых сообщений больше
+	MaxSessionsPerRun int           `mapstructure:"max_sessions_per_run"`
+	MinDelay          time.Duration `mapstructure:"min_delay"` // пауза между запросами сжатия к модели shrink
 }
 
 type LLMConfig struct {
-	Provider string `mapstructure:"provider"` // всегда "gemini" (MCP)
+	Provider string `mapstructure:"provider"` // gemini (с MCP) или openrouter (без инструментов MCP)
 	BaseURL  string `mapstructure:"base_url"`
 	APIKey   string `mapstructure:"api_key"`
 	Model    string `mapstructure:"model"`
+	// Timeout предел одного запроса к модели вместе с циклом вызова инструментов
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// Generation параметры сэмплирования основного клиента; незаданные оставляют значения модели
+	Generation providers.GenerationConfig `mapstructure:"generation"`
+	// Shrink переопределения для клиента сжатия истории (резюме и якоря)
+	Shrink LLMClientConfig `mapstructure:"shrink"`
+	// ToolMode режим вызова инструментов MCP основным клиентом: auto, any или none
+	ToolMode string `mapstructure:"tool_mode"`
+	// Safety порог блокировки по категории фильтров безопасности Gemini,
+	// например dangerous_content: block_only_high; незаданные категории используют пороги модели
+	Safety map[string]string `mapstructure:"safety"`
+	// Retry повторы хода диалога при ответах 429 и 5xx с экспоненциальной паузой
+	Retry providers.RetryConfig `mapstructure:"retry"`
+	// CircuitBreaker после серии ошибок провайдера запросы отклоняются сразу (503) до пробного запроса
+	CircuitBreaker llm.BreakerConfig `mapstructure:"circuit_breaker"`
+	// MaxConcurrentRequests одновременных запросов генерации основного клиента; 0 - без ограничения
+	MaxConcurrentRequests int `mapstructure:"max_concurrent_requests"`
+	// QueueTimeout ожидание свободного слота, после которого запрос получает 429
+	QueueTimeout time.Duration `mapstructure:"queue_timeout"`
+	// RequestRetry повторы запроса целиком при временных ошибках провайдера (сеть, 429, 5xx, MCP);
+	// применяются к основному клиенту и клиенту сжатия
+	RequestRetry llm.RetryConfig `mapstructure:"request_retry"`
+}
+
+// LLMClientConfig переопределения настроек llm для отдельного клиента; пустые значения берутся из llm
+type LLMClientConfig struct {
+	// Model модель клиента, например дешёвая модель для резюме вместо основной
+	Model   string `mapstructure:"model"`
+	APIKey  string `mapstructure:"api_key"`
+	BaseURL string `mapstructure:"base_url"`
+	// Timeout предел запроса клиента; резюме короче ответов чата, поэтому предел можно сделать меньше
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// Generation заданные параметры заменяют llm.generation, например temperature 0.2 для резюме
+	Generation providers.GenerationConfig `mapstructure:"generation"`
+	// ToolMode режим вызова инструментов; клиент сжатия по умолчанию работает без инструментов (none)
+	ToolMode string `mapstructure:"tool_mode"`
+	// MaxConcurrentRequests размер собственного пула клиента, чтобы сжатие не занимало слоты чата;
+	// 0 - пул того же размера, что llm.max_concurrent_requests
+	MaxConcurrentRequests int           `mapstructure:"max_concurrent_requests"`
+	QueueTimeout          time.Duration `mapstructure:"queue_timeout"`
 }
 
 type MCPConfig struct {
@@ -65,6 +275,166 @@ type MCPConfig struct {
 	HTTPHeaders      map[string]string `mapstructure:"http_headers"`
 	SystemPromptPath string            `mapstructure:"system_prompt_path"`
 	MaxIterations    int               `mapstructure:"max_iterations"`
+	ProbeTimeout     time.Duration     `mapstructure:"probe_timeout"`
+	StatusCacheTTL   time.Duration     `mapstructure:"status_cache_ttl"`
+
+	// MaxToolResultBytes предел размера результата инструмента в истории модели; больший результат обрезается
+	MaxToolResultBytes int `mapstructure:"max_tool_result_bytes"`
+	// MaxConcurrentToolCalls сколько вызовов инструментов из одного ответа модели выполняются одновременно
+	MaxConcurrentToolCalls int `mapstructure:"max_concurrent_tool_calls"`
+	// ToolCallTimeout время на один вызов инструмента; 0 - вызов ограничен только таймаутом запроса
+	ToolCallTimeout time.Duration `mapstructure:"tool_call_timeout"`
+	// AllowedTools если задан, модели доступны только эти инструменты; DeniedTools скрыты всегда
+	AllowedTools []string `mapstructure:"allowed_tools"`
+	DeniedTools  []string `mapstructure:"denied_tools"`
+	// ToolsRefreshInterval период обновления списка инструментов для серверов без уведомления
+	// tools/list_changed; 0 - список обновляется только по уведомлению
+	ToolsRefreshInterval time.Duration `mapstructure:"tools_refresh_interval"`
+	// ReconnectAttempts попытки переподключения при потере соединения с MCP сервером
+	ReconnectAttempts int `mapstructure:"reconnect_attempts"`
+
+	// Transport "http" (Streamable HTTP по server_url) или "stdio" (локальный процесс server_path)
+	Transport  string            `mapstructure:"transport"`
+	ServerPath string            `mapstructure:"server_path"` // stdio: исполняемый файл или скрипт сервера
+	PythonPath string            `mapstructure:"python_path"` // stdio: интерпретатор для server_path
+	ServerArgs []string          `mapstructure:"server_args"`
+	ServerEnv  map[string]string `mapstructure:"server_env"`
+
+	// DebugEndpoints включает POST /api/v1/mcp/tools/:name/call - вызов инструментов в обход модели.
+	// Не включайте в production: endpoint не требует отдельной авторизации
+	DebugEndpoints bool `mapstructure:"debug_endpoints"`
+
+	// Servers несколько MCP серверов; модель видит их инструменты с префиксом "имя__".
+	// Если список задан, поля подключения выше (transport, server_url, server_path, ...) не используются
+	Servers []MCPServerConfig `mapstructure:"servers"`
+}
+
+// MCPServerConfig подключение к одному из нескольких MCP серверов
+type MCPServerConfig struct {
+	Name        string            `mapstructure:"name"`      // префикс имён инструментов сервера
+	Transport   string            `mapstructure:"transport"` // по умолчанию http при заданном server_url, иначе stdio
+	ServerURL   string            `mapstructure:"server_url"`
+	HTTPHeaders map[string]string `mapstructure:"http_headers"`
+	ServerPath  string            `mapstructure:"server_path"`
+	PythonPath  string            `mapstructure:"python_path"`
+	ServerArgs  []string          `mapstructure:"server_args"`
+	ServerEnv   map[string]string `mapstructure:"server_env"`
+}
+
+// toProvider переводит настройки сервера в конфигурацию провайдера
+func (s MCPServerConfig) toProvider() providers.MCPServerConfig {
+	return providers.MCPServerConfig{
+		Name:        s.Name,
+		Transport:   s.Transport,
+		ServerURL:   s.ServerURL,
+		ServerPath:  s.ServerPath,
+		PythonPath:  s.PythonPath,
+		ServerArgs:  s.ServerArgs,
+		ServerEnv:   s.ServerEnv,
+		HTTPHeaders: s.HTTPHeaders,
+	}
+}
+
+type HealthConfig struct {
+	CheckTimeout    time.Duration `mapstructure:"check_timeout"`
+	AllowedDegraded []string      `mapstructure:"allowed_degraded"` // проверки, сбой которых не снимает готовность
+}
+
+// SummaryConfig настройки сервиса резюме
+type SummaryConfig struct {
+	// Language язык резюме: auto (язык сессии, если он закреплён, иначе преобладающий язык
+	// сжимаемых сообщений) или код языка из language.Supported
+	Language string `mapstructure:"language"`
+	// ChunkTokens предел оценки токенов диалога в одном запросе резюме; более длинный диалог
+	// резюмируется по частям, а резюме частей объединяются. 0 - всегда одним запросом
+	ChunkTokens int                  `mapstructure:"chunk_tokens"`
+	Prompts     SummaryPromptsConfig `mapstructure:"prompts"`
+}
+
+// SummaryPromptsConfig пути к файлам шаблонов системных промптов (text/template) вместо встроенных;
+// пустой путь оставляет встроенный шаблон. Заданный шаблон используется для резюме на любом языке.
+// Переменные шаблонов - summary.PromptData.
+type SummaryPromptsConfig struct {
+	AnchorsL1    string `mapstructure:"anchors_l1"`    // якоря из диалога
+	AnchorsL2    string `mapstructure:"anchors_l2"`    // якоря из резюме (уровни 2 и 3)
+	SummaryL1    string `mapstructure:"summary_l1"`    // резюме диалога
+	SummaryL2    string `mapstructure:"summary_l2"`    // bulk резюме
+	SummaryL3    string `mapstructure:"summary_l3"`    // дайджест сессии
+	StructuredL1 string `mapstructure:"structured_l1"` // якоря и резюме одним запросом, по уровням
+	StructuredL2 string `mapstructure:"structured_l2"`
+	StructuredL3 string `mapstructure:"structured_l3"`
+}
+
+type MetricsConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Path    string `mapstructure:"path"`
+	Public  bool   `mapstructure:"public"` // не применять к endpoint аутентификацию и rate limiting
+}
+
+// AdminConfig настройки административного API
+type AdminConfig struct {
+	Token               string        `mapstructure:"token"`                // пустой токен отключает /api/v1/admin
+	ReinitializeTimeout time.Duration `mapstructure:"reinitialize_timeout"` // ожидание текущих запросов и повторная инициализация
+	DeleteBatchSize     int           `mapstructure:"delete_batch_size"`    // сессий в одной транзакции массового удаления
+}
+
+// EventsConfig настройки потока системных событий сессии
+type EventsConfig struct {
+	SubscriberBuffer  int           `mapstructure:"subscriber_buffer"`  // очередь подписчика, при переполнении события отбрасываются
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"` // keep-alive комментарии для прокси
+}
+
+// RedactionConfig маскирование персональных данных перед сохранением сообщений и записью
+// вызовов инструментов в журнал аудита и логи; LLM получает текущий запрос без изменений
+type RedactionConfig struct {
+	Enabled   bool                     `mapstructure:"enabled"`
+	Detectors []string                 `mapstructure:"detectors"` // встроенные детекторы: email, card, phone
+	Patterns  []RedactionPatternConfig `mapstructure:"patterns"`  // дополнительные регулярные выражения
+}
+
+// RedactionPatternConfig пользовательское правило маскирования
+type RedactionPatternConfig struct {
+	Name        string `mapstructure:"name"`
+	Regex       string `mapstructure:"regex"`
+	Replacement string `mapstructure:"replacement"` // по умолчанию "[NAME]"
+}
+
+// GroundingConfig опора ответов основного клиента Gemini на поиск Google; запрос может переопределить
+// настройку флагом grounding.google_search. Клиент сжатия контекста поиск не использует.
+type GroundingConfig struct {
+	GoogleSearch bool `mapstructure:"google_search"`
+}
+
+// ToRedactConfig создаёт конфигурацию редактора персональных данных
+func (cfg *Config) ToRedactConfig() redact.Config {
+	patterns := make([]redact.Pattern, 0, len(cfg.Redaction.Patterns))
+	for _, p := range cfg.Redaction.Patterns {
+		patterns = append(patterns, redact.Pattern{
+			Name:        p.Name,
+			Regex:       p.Regex,
+			Replacement: p.Replacement,
+		})
+	}
+
+	return redact.Config{
+		Detectors: cfg.Redaction.Detectors,
+		Patterns:  patterns,
+	}
+}
+
+// SummaryPromptFiles файлы шаблонов промптов резюме по именам шаблонов (summary.LoadPrompts)
+func (cfg *Config) SummaryPromptFiles() map[string]string {
+	prompts := cfg.Summary.Prompts
+	return map[string]string{
+		summary.PromptAnchorsL1:    prompts.AnchorsL1,
+		summary.PromptAnchorsL2:    prompts.AnchorsL2,
+		summary.PromptSummaryL1:    prompts.SummaryL1,
+		summary.PromptSummaryL2:    prompts.SummaryL2,
+		summary.PromptSummaryL3:    prompts.SummaryL3,
+		summary.PromptStructuredL1: prompts.StructuredL1,
+		summary.PromptStructuredL2: prompts.StructuredL2,
+		summary.PromptStructuredL3: prompts.StructuredL3,
+	}
 }
 
 func (cfg *Config) ToProviderConfig() providers.Config {
@@ -73,17 +443,77 @@ func (cfg *Config) ToProviderConfig() providers.Config {
 		BaseURL:  cfg.LLM.BaseURL,
 		APIKey:   cfg.LLM.APIKey,
 		Model:    cfg.LLM.Model,
-		Timeout:  60 * time.Second, // или cfg.LLM.Timeout если добавить
+		Timeout:  cfg.LLM.Timeout,
+
+		Generation: cfg.LLM.Generation,
+		Safety:     cfg.LLM.Safety,
+		Retry:      cfg.LLM.Retry,
+		ToolMode:   cfg.LLM.ToolMode,
 	}
 }
 
+// ToShrinkProviderConfig конфигурация провайдера клиента сжатия: llm.* с переопределениями llm.shrink
+func (cfg *Config) ToShrinkProviderConfig() providers.Config {
+	providerConfig := cfg.ToProviderConfig()
+	if cfg.LLM.Shrink.Model != "" {
+		providerConfig.Model = cfg.LLM.Shrink.Model
+	}
+	if cfg.LLM.Shrink.APIKey != "" {
+		providerConfig.APIKey = cfg.LLM.Shrink.APIKey
+	}
+	if cfg.LLM.Shrink.BaseURL != "" {
+		providerConfig.BaseURL = cfg.LLM.Shrink.BaseURL
+	}
+	if cfg.LLM.Shrink.Timeout > 0 {
+		providerConfig.Timeout = cfg.LLM.Shrink.Timeout
+	}
+	providerConfig.Generation = cfg.LLM.Generation.Merge(cfg.LLM.Shrink.Generation)
+	providerConfig.ToolMode = cfg.LLM.Shrink.ToolMode
+	if providerConfig.ToolMode == "" {
+		// Резюме и якоря строятся только по тексту истории, вызовы инструментов им не нужны
+		providerConfig.ToolMode = providers.ToolModeNone
+	}
+	return providerConfig
+}
+
+// ShrinkConcurrency лимит одновременных запросов клиента сжатия; незаданные значения берутся из llm
+func (cfg *Config) ShrinkConcurrency() (int, time.Duration) {
+	limit, queueTimeout := cfg.LLM.Shrink.MaxConcurrentRequests, cfg.LLM.Shrink.QueueTimeout
+	if limit == 0 {
+		limit = cfg.LLM.MaxConcurrentRequests
+	}
+	if queueTimeout == 0 {
+		queueTimeout = cfg.LLM.QueueTimeout
+	}
+	return limit, queueTimeout
+}
+
 // ToMCPConfig создает MCP конфигурацию
 func (cfg *Config) ToMCPConfig() providers.MCPProviderConfig {
+	var servers []providers.MCPServerConfig
+	for _, server := range cfg.MCP.Servers {
+		servers = append(servers, server.toProvider())
+	}
+
 	return providers.MCPProviderConfig{
-		ServerURL:        cfg.MCP.ServerURL,
-		SystemPromptPath: cfg.MCP.SystemPromptPath,
-		MaxIterations:    cfg.MCP.MaxIterations,
-		HTTPHeaders:      cfg.MCP.HTTPHeaders,
+		Transport:              cfg.MCP.Transport,
+		ServerPath:             cfg.MCP.ServerPath,
+		PythonPath:             cfg.MCP.PythonPath,
+		ServerArgs:             cfg.MCP.ServerArgs,
+		ServerEnv:              cfg.MCP.ServerEnv,
+		ServerURL:              cfg.MCP.ServerURL,
+		SystemPromptPath:       cfg.MCP.SystemPromptPath,
+		MaxIterations:          cfg.MCP.MaxIterations,
+		MaxToolResultBytes:     cfg.MCP.MaxToolResultBytes,
+		MaxConcurrentToolCalls: cfg.MCP.MaxConcurrentToolCalls,
+		ToolCallTimeout:        cfg.MCP.ToolCallTimeout,
+		AllowedTools:           cfg.MCP.AllowedTools,
+		DeniedTools:            cfg.MCP.DeniedTools,
+		ToolsRefreshInterval:   cfg.MCP.ToolsRefreshInterval,
+		ReconnectAttempts:      cfg.MCP.ReconnectAttempts,
+		HTTPHeaders:            cfg.MCP.HTTPHeaders,
+		GoogleSearch:           cfg.Grounding.GoogleSearch,
+		Servers:                servers,
 	}
 }
 
@@ -134,6 +564,28 @@ func setDefaults() {
 	viper.SetDefault("server.port", 8080)
 	viper.SetDefault("server.read_timeout", "30s")
 	viper.SetDefault("server.write_timeout", "30s")
+	viper.SetDefault("server.shutdown_drain_timeout", "20s")
+	viper.SetDefault("server.route_timeouts.chat", "120s")
+	viper.SetDefault("server.route_timeouts.metadata", "10s")
+	viper.SetDefault("server.swagger_ui", false)
+	viper.SetDefault("server.compression.enabled", true)
+	viper.SetDefault("server.compression.level", -1)
+	viper.SetDefault("server.compression.min_size", 1024)
+	viper.SetDefault("server.cors.allowed_origins", []string{"*"})
+	viper.SetDefault("server.cors.allowed_methods", []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"})
+	viper.SetDefault("server.cors.allowed_headers", []string{
+		"Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization",
+		"Accept", "Origin", "Cache-Control", "X-Requested-With",
+	})
+	viper.SetDefault("server.cors.allow_credentials", false)
+	viper.SetDefault("server.cors.max_age", "12h")
+	viper.SetDefault("server.limits.max_body_size", 1<<20) // 1 MB
+	viper.SetDefault("server.limits.body_size_overrides", map[string]int64{
+		"/api/v1/chat": 256 << 10,
+	})
+	viper.SetDefault("server.limits.max_header_bytes", 64<<10)
+	viper.SetDefault("server.limits.max_header_count", 100)
+	viper.SetDefault("server.limits.body_read_timeout", "15s")
 
 	// Database defaults
 	viper.SetDefault("database.host", "localhost")
@@ -155,18 +607,124 @@ func setDefaults() {
 	// Chat defaults with multi-level compression
 	viper.SetDefault("chat.max_messages_per_session", 1000) // Увеличено для БД
 	viper.SetDefault("chat.context_window_size", 20)
+	viper.SetDefault("chat.max_context_tokens", 0)
 	viper.SetDefault("chat.message_compression_ratio", 0.3) // 30%
 	viper.SetDefault("chat.summary_compression_ratio", 0.8) // 80%
+	viper.SetDefault("chat.message_token_ratio", 0.7)       // 70% max_context_tokens
+	viper.SetDefault("chat.summary_token_ratio", 0.3)       // 30% max_context_tokens
+	viper.SetDefault("chat.max_bulk_summaries", 5)
+	viper.SetDefault("chat.bulk_token_ratio", 0.2) // 20% max_context_tokens
+	viper.SetDefault("chat.summary_role", "system")
+	viper.SetDefault("chat.summary_template", contextmgr.DefaultSummaryTemplate)
+	viper.SetDefault("chat.exclude_tool_messages", false)
+	viper.SetDefault("chat.compression_webhook_url", "")
+	viper.SetDefault("chat.compression_webhook_timeout", contextmgr.DefaultWebhookTimeout.String())
 	viper.SetDefault("chat.min_messages_in_window", 5)
+	viper.SetDefault("chat.language", language.Auto)
+	viper.SetDefault("chat.max_iterations_limit", 20)
+	viper.SetDefault("chat.max_output_tokens_limit", 8192)
+	viper.SetDefault("chat.max_active_anchors", 20)
+	viper.SetDefault("chat.recall.enabled", false)
+	viper.SetDefault("chat.recall.embedding_model", providers.DefaultGeminiEmbeddingModel)
+	viper.SetDefault("chat.recall.top_k", 3)
+	viper.SetDefault("chat.recall.min_similarity", 0.75)
+	viper.SetDefault("chat.recall.max_tokens", 1000)
+	viper.SetDefault("chat.cleanup.interval", "5m")
+	viper.SetDefault("chat.purge_compressed_after", 0)
+	viper.SetDefault("chat.cleanup.pending_timeout", "15m")
+	viper.SetDefault("chat.cleanup.purge_batch_size", 500)
+	viper.SetDefault("chat.idle_compression.interval", 0)
+	viper.SetDefault("chat.idle_compression.idle_after", "24h")
+	viper.SetDefault("chat.idle_compression.min_active_messages", 20)
+	viper.SetDefault("chat.idle_compression.max_sessions_per_run", 50)
+	viper.SetDefault("chat.idle_compression.min_delay", "2s")
+	viper.SetDefault("chat.stream_replay.enabled", true)
+	viper.SetDefault("chat.stream_replay.buffer_size", 2048)
+	viper.SetDefault("chat.stream_replay.ttl", "5m")
+	viper.SetDefault("chat.stream_replay.max_streams", 1000)
+	viper.SetDefault("chat.resources.max_bytes", 100000)
+	viper.SetDefault("chat.resources.cache_ttl", "5m")
+	viper.SetDefault("chat.tool_calls.max_argument_length", 200)
+	viper.SetDefault("chat.tool_calls.max_result_bytes", 2000)
 
 	// LLM defaults (только Gemini MCP)
 	viper.SetDefault("llm.provider", "gemini")
 	viper.SetDefault("llm.model", "gemini-2.5-flash")
+	viper.SetDefault("llm.tool_mode", providers.ToolModeAuto)
+	viper.SetDefault("llm.shrink.model", "")
+	viper.SetDefault("llm.shrink.api_key", "")
+	viper.SetDefault("llm.shrink.base_url", "")
+	viper.SetDefault("llm.timeout", providers.DefaultTimeout.String())
+	viper.SetDefault("llm.shrink.timeout", "0s")
+	viper.SetDefault("llm.shrink.tool_mode", providers.ToolModeNone)
+	viper.SetDefault("llm.retry.max_attempts", providers.DefaultRetryMaxAttempts)
+	viper.SetDefault("llm.retry.initial_delay", providers.DefaultRetryInitialDelay.String())
+	viper.SetDefault("llm.retry.max_delay", providers.DefaultRetryMaxDelay.String())
+	viper.SetDefault("llm.retry.multiplier", providers.DefaultRetryMultiplier)
+	viper.SetDefault("llm.circuit_breaker.failure_threshold", llm.DefaultBreakerFailureThreshold)
+	viper.SetDefault("llm.circuit_breaker.cool_down", llm.DefaultBreakerCoolDown.String())
+	viper.SetDefault("llm.request_retry.max_retries", llm.DefaultRetryConfig().MaxRetries)
+	viper.SetDefault("llm.request_retry.initial_delay", llm.DefaultRetryConfig().InitialDelay.String())
+	viper.SetDefault("llm.request_retry.max_delay", llm.DefaultRetryConfig().MaxDelay.String())
+	viper.SetDefault("llm.request_retry.backoff_multiplier", llm.DefaultRetryConfig().BackoffMultiplier)
+	viper.SetDefault("llm.max_concurrent_requests", 0)
+	viper.SetDefault("llm.queue_timeout", llm.DefaultQueueTimeout.String())
+	viper.SetDefault("llm.shrink.max_concurrent_requests", 0)
+	viper.SetDefault("llm.shrink.queue_timeout", "0s")
 
 	// MCP defaults
+	viper.SetDefault("mcp.transport", providers.MCPTransportHTTP)
 	viper.SetDefault("mcp.server_url", "http://localhost:8000/mcp")
+	viper.SetDefault("mcp.server_path", "")
+	viper.SetDefault("mcp.python_path", "")
+	viper.SetDefault("mcp.server_args", []string{})
 	viper.SetDefault("mcp.system_prompt_path", "system_prompt.txt")
 	viper.SetDefault("mcp.max_iterations", 10)
+	viper.SetDefault("mcp.max_tool_result_bytes", providers.DefaultMaxToolResultBytes)
+	viper.SetDefault("mcp.max_concurrent_tool_calls", providers.DefaultMaxConcurrentToolCalls)
+	viper.SetDefault("mcp.tool_call_timeout", "30s")
+	viper.SetDefault("mcp.allowed_tools", []string{})
+	viper.SetDefault("mcp.denied_tools", []string{})
+	viper.SetDefault("mcp.tools_refresh_interval", "5m")
+	viper.SetDefault("mcp.reconnect_attempts", providers.DefaultMCPReconnectAttempts)
+	viper.SetDefault("mcp.probe_timeout", "2s")
+	viper.SetDefault("mcp.status_cache_ttl", "10s")
+	viper.SetDefault("mcp.debug_endpoints", false)
+
+	// Health check defaults
+	viper.SetDefault("health.check_timeout", "3s")
+	viper.SetDefault("health.allowed_degraded", []string{"llm"})
+
+	// Metrics defaults
+	viper.SetDefault("summary.language", language.Auto)
+	viper.SetDefault("summary.chunk_tokens", summary.DefaultChunkTokenBudget)
+	// Пустой путь - встроенный шаблон
+	viper.SetDefault("summary.prompts.anchors_l1", "")
+	viper.SetDefault("summary.prompts.anchors_l2", "")
+	viper.SetDefault("summary.prompts.summary_l1", "")
+	viper.SetDefault("summary.prompts.summary_l2", "")
+	viper.SetDefault("summary.prompts.summary_l3", "")
+	viper.SetDefault("summary.prompts.structured_l1", "")
+	viper.SetDefault("summary.prompts.structured_l2", "")
+	viper.SetDefault("summary.prompts.structured_l3", "")
+
+	viper.SetDefault("metrics.enabled", true)
+	viper.SetDefault("metrics.path", "/metrics")
+	viper.SetDefault("metrics.public", true)
+
+	// Admin defaults
+	viper.SetDefault("admin.token", "")
+	viper.SetDefault("admin.reinitialize_timeout", "30s")
+	viper.SetDefault("admin.delete_batch_size", 100)
+
+	// Events defaults
+	viper.SetDefault("events.subscriber_buffer", 64)
+	viper.SetDefault("events.heartbeat_interval", "15s")
+
+	viper.SetDefault("redaction.enabled", false)
+	viper.SetDefault("redaction.detectors", redact.DefaultDetectors)
+
+	viper.SetDefault("grounding.google_search", false)
 }
 
 func buildDatabaseURL(dbConfig DatabaseConfig) string {
@@ -189,9 +747,13 @@ func getGeminiAPIKey() string {
 }
 
 func validateConfig(config *Config) error {
-	// Проверяем провайдер (должен быть только gemini)
-	if strings.ToLower(config.LLM.Provider) != "gemini" {
-		return fmt.Errorf("unsupported LLM provider: %s, only 'gemini' is supported", config.LLM.Provider)
+	config.LLM.Provider = strings.ToLower(strings.TrimSpace(config.LLM.Provider))
+	if !slices.Contains(providers.SupportedProviders(), config.LLM.Provider) {
+		return fmt.Errorf("unsupported LLM provider: %s (supported: %s)",
+			config.LLM.Provider, strings.Join(providers.SupportedProviders(), ", "))
+	}
+	if baseURL := strings.TrimSpace(config.LLM.BaseURL); baseURL != "" && !strings.HasPrefix(baseURL, "http") {
+		return fmt.Errorf("LLM base_url must start with http:// or https://")
 	}
 
 	// Проверяем наличие API ключа
@@ -211,10 +773,48 @@ llm:
 		return fmt.Errorf("invalid server port: %d", config.Server.Port)
 	}
 
+	if config.Server.RouteTimeouts.Chat < 0 || config.Server.RouteTimeouts.Metadata < 0 {
+		return fmt.Errorf("route timeouts cannot be negative")
+	}
+
+	if config.Server.ShutdownDrainTimeout < 0 {
+		return fmt.Errorf("shutdown drain timeout cannot be negative")
+	}
+
+	if config.Server.Compression.Enabled {
+		if config.Server.Compression.Level < -1 || config.Server.Compression.Level > 9 {
+			return fmt.Errorf("compression level must be between -1 and 9: %d", config.Server.Compression.Level)
+		}
+		if config.Server.Compression.MinSize < 0 {
+			return fmt.Errorf("compression min_size cannot be negative: %d", config.Server.Compression.MinSize)
+		}
+	}
+
+	if err := validateCORSConfig(config.Server.CORS); err != nil {
+		return err
+	}
+
+	if config.Server.Limits.MaxBodySize <= 0 {
+		return fmt.Errorf("max body size must be positive: %d", config.Server.Limits.MaxBodySize)
+	}
+
+	for route, limit := range config.Server.Limits.BodySizeOverrides {
+		if limit <= 0 {
+			return fmt.Errorf("body size override for %s must be positive: %d", route, limit)
+		}
+	}
+
+	if config.Server.Limits.MaxHeaderBytes < 0 || config.Server.Limits.MaxHeaderCount < 0 {
+		return fmt.Errorf("header limits cannot be negative")
+	}
+
 	// Проверяем конфигурацию чата
 	if config.Chat.ContextWindowSize <= 0 {
 		return fmt.Errorf("context window size must be positive: %d", config.Chat.ContextWindowSize)
 	}
+	if config.Chat.MaxContextTokens < 0 {
+		return fmt.Errorf("max context tokens cannot be negative: %d", config.Chat.MaxContextTokens)
+	}
 
 	if config.Chat.MaxMessagesPerSession <= 0 {
 		return fmt.Errorf("max messages per session must be positive: %d", config.Chat.MaxMessagesPerSession)
@@ -228,6 +828,107 @@ llm:
 		return fmt.Errorf("summary compression ratio must be between 0 and 1: %f", config.Chat.SummaryCompressionRatio)
 	}
 
+	if config.Chat.MaxContextTokens > 0 {
+		if config.Chat.MessageTokenRatio <= 0 || config.Chat.MessageTokenRatio >= 1 {
+			return fmt.Errorf("message token ratio must be between 0 and 1: %f", config.Chat.MessageTokenRatio)
+		}
+		if config.Chat.SummaryTokenRatio <= 0 || config.Chat.SummaryTokenRatio >= 1 {
+			return fmt.Errorf("summary token ratio must be between 0 and 1: %f", config.Chat.SummaryTokenRatio)
+		}
+		if config.Chat.MaxBulkSummaries > 0 && (config.Chat.BulkTokenRatio <= 0 || config.Chat.BulkTokenRatio >= 1) {
+			return fmt.Errorf("bulk token ratio must be between 0 and 1: %f", config.Chat.BulkTokenRatio)
+		}
+	}
+
+	if config.Chat.MaxBulkSummaries < 0 {
+		return fmt.Errorf("max bulk summaries cannot be negative: %d", config.Chat.MaxBulkSummaries)
+	}
+
+	switch config.Chat.SummaryRole {
+	case "assistant", "system", "user":
+	default:
+		return fmt.Errorf("summary role must be assistant, system or user: %q", config.Chat.SummaryRole)
+	}
+	if !strings.Contains(config.Chat.SummaryTemplate, contextmgr.SummaryPlaceholder) {
+		return fmt.Errorf("summary template must contain %s", contextmgr.SummaryPlaceholder)
+	}
+
+	if err := language.Validate(config.Chat.Language); err != nil {
+		return fmt.Errorf("invalid chat language: %w", err)
+	}
+
+	if config.Chat.Cleanup.Interval < 0 {
+		return fmt.Errorf("chat cleanup interval cannot be negative: %s", config.Chat.Cleanup.Interval)
+	}
+
+	if config.Chat.Cleanup.PendingTimeout <= 0 {
+		return fmt.Errorf("chat cleanup pending timeout must be positive: %s", config.Chat.Cleanup.PendingTimeout)
+	}
+	if config.Chat.Cleanup.PurgeBatchSize <= 0 {
+		return fmt.Errorf("chat cleanup purge batch size must be positive: %d", config.Chat.Cleanup.PurgeBatchSize)
+	}
+	if config.Chat.PurgeCompressedAfter < 0 {
+		return fmt.Errorf("chat purge_compressed_after cannot be negative: %s", config.Chat.PurgeCompressedAfter)
+	}
+	if config.Chat.PurgeCompressedAfter > 0 && config.Chat.Cleanup.Interval == 0 {
+		return fmt.Errorf("chat purge_compressed_after requires chat.cleanup.interval to be enabled")
+	}
+
+	if webhook := config.Chat.CompressionWebhookURL; webhook != "" {
+		parsed, err := url.Parse(webhook)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("chat compression webhook url must be an absolute http(s) URL: %q", webhook)
+		}
+		if config.Chat.CompressionWebhookTimeout <= 0 {
+			return fmt.Errorf("chat compression webhook timeout must be positive: %s", config.Chat.CompressionWebhookTimeout)
+		}
+	}
+
+	if idle := config.Chat.IdleCompression; idle.Interval < 0 {
+		return fmt.Errorf("chat idle compression interval cannot be negative: %s", idle.Interval)
+	} else if idle.Interval > 0 {
+		if idle.IdleAfter <= 0 {
+			return fmt.Errorf("chat idle compression idle_after must be positive: %s", idle.IdleAfter)
+		}
+		// Иначе сессия, сжатая до MinMessagesInWindow, снова попадала бы под сжатие после каждого простоя
+		if idle.MinActiveMessages < config.Chat.MinMessagesInWindow {
+			return fmt.Errorf("chat idle compression min_active_messages (%d) cannot be less than min_messages_in_window (%d)",
+				idle.MinActiveMessages, config.Chat.MinMessagesInWindow)
+		}
+		if idle.MaxSessionsPerRun <= 0 {
+			return fmt.Errorf("chat idle compression max_sessions_per_run must be positive: %d", idle.MaxSessionsPerRun)
+		}
+		if idle.MinDelay < 0 {
+			return fmt.Errorf("chat idle compression min_delay cannot be negative: %s", idle.MinDelay)
+		}
+	}
+
+	if replay := config.Chat.StreamReplay; replay.Enabled {
+		if replay.BufferSize <= 0 {
+			return fmt.Errorf("stream replay buffer size must be positive: %d", replay.BufferSize)
+		}
+		if replay.TTL <= 0 {
+			return fmt.Errorf("stream replay ttl must be positive: %s", replay.TTL)
+		}
+		if replay.MaxStreams <= 0 {
+			return fmt.Errorf("stream replay max streams must be positive: %d", replay.MaxStreams)
+		}
+	}
+
+	if config.Chat.Resources.MaxBytes <= 0 {
+		return fmt.Errorf("chat resources max_bytes must be positive: %d", config.Chat.Resources.MaxBytes)
+	}
+	if config.Chat.Resources.CacheTTL < 0 {
+		return fmt.Errorf("chat resources cache_ttl cannot be negative: %s", config.Chat.Resources.CacheTTL)
+	}
+
+	if config.Chat.ToolCalls.MaxArgumentLength <= 0 {
+		return fmt.Errorf("chat tool_calls max_argument_length must be positive: %d", config.Chat.ToolCalls.MaxArgumentLength)
+	}
+	if config.Chat.ToolCalls.MaxResultBytes <= 0 {
+		return fmt.Errorf("chat tool_calls max_result_bytes must be positive: %d", config.Chat.ToolCalls.MaxResultBytes)
+	}
+
 	// Проверяем конфигурацию LLM
 	if strings.TrimSpace(config.LLM.Model) == "" {
 		return fmt.Errorf("LLM model is required")
@@ -239,9 +940,66 @@ llm:
 		}
 	}
 
+	if shrinkURL := strings.TrimSpace(config.LLM.Shrink.BaseURL); shrinkURL != "" && !strings.HasPrefix(shrinkURL, "http") {
+		return fmt.Errorf("LLM shrink base_url must start with http:// or https://")
+	}
+
+	if err := config.LLM.Generation.Validate(); err != nil {
+		return fmt.Errorf("invalid llm generation: %w", err)
+	}
+	if err := config.LLM.Shrink.Generation.Validate(); err != nil {
+		return fmt.Errorf("invalid llm shrink generation: %w", err)
+	}
+	if err := providers.ValidateSafetySettings(config.LLM.Safety); err != nil {
+		return fmt.Errorf("invalid llm safety: %w", err)
+	}
+	if err := providers.ValidateToolMode(config.LLM.ToolMode); err != nil {
+		return fmt.Errorf("invalid llm tool_mode: %w", err)
+	}
+	if err := providers.ValidateToolMode(config.LLM.Shrink.ToolMode); err != nil {
+		return fmt.Errorf("invalid llm shrink tool_mode: %w", err)
+	}
+	if config.LLM.Timeout <= 0 {
+		return fmt.Errorf("LLM timeout must be positive: %s", config.LLM.Timeout)
+	}
+	if config.LLM.Shrink.Timeout < 0 {
+		return fmt.Errorf("LLM shrink timeout cannot be negative: %s", config.LLM.Shrink.Timeout)
+	}
+	if err := config.LLM.Retry.Validate(); err != nil {
+		return fmt.Errorf("invalid llm retry: %w", err)
+	}
+	if err := config.LLM.CircuitBreaker.Validate(); err != nil {
+		return fmt.Errorf("invalid llm circuit_breaker: %w", err)
+	}
+	if err := config.LLM.RequestRetry.Validate(); err != nil {
+		return fmt.Errorf("invalid llm request_retry: %w", err)
+	}
+	if config.LLM.MaxConcurrentRequests < 0 || config.LLM.Shrink.MaxConcurrentRequests < 0 {
+		return fmt.Errorf("LLM max_concurrent_requests cannot be negative")
+	}
+	if config.LLM.QueueTimeout < 0 || config.LLM.Shrink.QueueTimeout < 0 {
+		return fmt.Errorf("LLM queue_timeout cannot be negative")
+	}
+
 	// Проверяем MCP конфигурацию
-	if strings.TrimSpace(config.MCP.ServerURL) == "" {
-		return fmt.Errorf("MCP server URL is required")
+	if len(config.MCP.Servers) > 0 {
+		if err := validateMCPServers(config.MCP.Servers); err != nil {
+			return err
+		}
+	} else {
+		switch config.MCP.Transport {
+		case providers.MCPTransportHTTP:
+			if strings.TrimSpace(config.MCP.ServerURL) == "" {
+				return fmt.Errorf("MCP server URL is required")
+			}
+		case providers.MCPTransportStdio:
+			if strings.TrimSpace(config.MCP.ServerPath) == "" {
+				return fmt.Errorf("MCP server path is required for stdio transport")
+			}
+		default:
+			return fmt.Errorf("unsupported MCP transport: %s (supported: %s, %s)",
+				config.MCP.Transport, providers.MCPTransportHTTP, providers.MCPTransportStdio)
+		}
 	}
 
 	if strings.TrimSpace(config.MCP.SystemPromptPath) == "" {
@@ -252,6 +1010,114 @@ llm:
 		return fmt.Errorf("MCP max iterations must be positive: %d", config.MCP.MaxIterations)
 	}
 
+	if config.MCP.MaxToolResultBytes <= 0 {
+		return fmt.Errorf("MCP max tool result bytes must be positive: %d", config.MCP.MaxToolResultBytes)
+	}
+
+	if config.MCP.MaxConcurrentToolCalls <= 0 {
+		return fmt.Errorf("MCP max concurrent tool calls must be positive: %d", config.MCP.MaxConcurrentToolCalls)
+	}
+
+	if config.MCP.ToolCallTimeout < 0 {
+		return fmt.Errorf("MCP tool call timeout cannot be negative: %s", config.MCP.ToolCallTimeout)
+	}
+
+	if config.MCP.ToolsRefreshInterval < 0 {
+		return fmt.Errorf("MCP tools refresh interval cannot be negative: %s", config.MCP.ToolsRefreshInterval)
+	}
+
+	if config.MCP.ReconnectAttempts <= 0 {
+		return fmt.Errorf("MCP reconnect attempts must be positive: %d", config.MCP.ReconnectAttempts)
+	}
+
+	if config.Chat.MaxIterationsLimit < config.MCP.MaxIterations {
+		return fmt.Errorf("chat max iterations limit (%d) must not be less than MCP max iterations (%d)",
+			config.Chat.MaxIterationsLimit, config.MCP.MaxIterations)
+	}
+
+	if config.Chat.MaxOutputTokensLimit <= 0 {
+		return fmt.Errorf("chat max output tokens limit must be positive: %d", config.Chat.MaxOutputTokensLimit)
+	}
+
+	if config.Chat.MaxActiveAnchors < 0 {
+		return fmt.Errorf("chat max active anchors must not be negative: %d", config.Chat.MaxActiveAnchors)
+	}
+
+	if recall := config.Chat.Recall; recall.Enabled {
+		if strings.TrimSpace(recall.EmbeddingModel) == "" {
+			return fmt.Errorf("chat recall embedding_model is required when recall is enabled")
+		}
+		if recall.TopK <= 0 {
+			return fmt.Errorf("chat recall top_k must be positive: %d", recall.TopK)
+		}
+		if recall.MinSimilarity <= 0 || recall.MinSimilarity > 1 {
+			return fmt.Errorf("chat recall min_similarity must be in (0, 1]: %g", recall.MinSimilarity)
+		}
+		if recall.MaxTokens <= 0 {
+			return fmt.Errorf("chat recall max_tokens must be positive: %d", recall.MaxTokens)
+		}
+	}
+
+	if config.MCP.ProbeTimeout <= 0 {
+		return fmt.Errorf("MCP probe timeout must be positive: %s", config.MCP.ProbeTimeout)
+	}
+
+	if config.MCP.StatusCacheTTL < 0 {
+		return fmt.Errorf("MCP status cache TTL cannot be negative: %s", config.MCP.StatusCacheTTL)
+	}
+
+	// Проверяем конфигурацию health checks
+	if config.Health.CheckTimeout <= 0 {
+		return fmt.Errorf("health check timeout must be positive: %s", config.Health.CheckTimeout)
+	}
+
+	for _, check := range config.Health.AllowedDegraded {
+		switch check {
+		case "storage", "mcp", "llm":
+		default:
+			return fmt.Errorf("unknown health check in allowed_degraded: %s", check)
+		}
+	}
+
+	// Проверяем конфигурацию метрик
+	if config.Metrics.Enabled && !strings.HasPrefix(config.Metrics.Path, "/") {
+		return fmt.Errorf("metrics path must start with '/': %s", config.Metrics.Path)
+	}
+
+	// Проверяем конфигурацию административного API
+	if config.Admin.ReinitializeTimeout <= 0 {
+		return fmt.Errorf("admin reinitialize timeout must be positive: %s", config.Admin.ReinitializeTimeout)
+	}
+	if config.Admin.DeleteBatchSize <= 0 {
+		return fmt.Errorf("admin delete batch size must be positive: %d", config.Admin.DeleteBatchSize)
+	}
+
+	// Проверяем конфигурацию потока событий
+	if config.Events.SubscriberBuffer <= 0 {
+		return fmt.Errorf("events subscriber buffer must be positive: %d", config.Events.SubscriberBuffer)
+	}
+	if config.Events.HeartbeatInterval <= 0 {
+		return fmt.Errorf("events heartbeat interval must be positive: %s", config.Events.HeartbeatInterval)
+	}
+
+	// Проверяем правила маскирования персональных данных
+	if config.Redaction.Enabled {
+		if _, err := redact.New(config.ToRedactConfig()); err != nil {
+			return fmt.Errorf("invalid redaction config: %w", err)
+		}
+	}
+
+	if err := language.Validate(config.Summary.Language); err != nil {
+		return fmt.Errorf("invalid summary language: %w", err)
+	}
+	if config.Summary.ChunkTokens < 0 {
+		return fmt.Errorf("summary chunk tokens cannot be negative: %d", config.Summary.ChunkTokens)
+	}
+	// Шаблоны промптов резюме разбираются при запуске, а не при первом сжатии
+	if _, err := summary.LoadPrompts(config.SummaryPromptFiles()); err != nil {
+		return fmt.Errorf("invalid summary prompts: %w", err)
+	}
+
 	// Проверяем конфигурацию базы данных
 	if strings.TrimSpace(config.Database.URL) == "" {
 		return fmt.Errorf("database URL is required")
@@ -268,6 +1134,65 @@ llm:
 	return nil
 }
 
+// validateMCPServers проверяет список MCP серверов (имена становятся префиксами имён инструментов)
+// и подставляет транспорт по умолчанию
+func validateMCPServers(servers []MCPServerConfig) error {
+	seen := make(map[string]bool, len(servers))
+	for i, server := range servers {
+		if err := providers.ValidateMCPServerName(server.Name); err != nil {
+			return err
+		}
+		if seen[server.Name] {
+			return fmt.Errorf("duplicate MCP server name: %s", server.Name)
+		}
+		seen[server.Name] = true
+
+		transport := providers.ResolveMCPTransport(server.toProvider())
+		switch transport {
+		case providers.MCPTransportHTTP:
+			if strings.TrimSpace(server.ServerURL) == "" {
+				return fmt.Errorf("MCP server %s: server_url is required", server.Name)
+			}
+		case providers.MCPTransportStdio:
+			if strings.TrimSpace(server.ServerPath) == "" {
+				return fmt.Errorf("MCP server %s: server_path is required for stdio transport", server.Name)
+			}
+		default:
+			return fmt.Errorf("MCP server %s: unsupported transport: %s (supported: %s, %s)",
+				server.Name, transport, providers.MCPTransportHTTP, providers.MCPTransportStdio)
+		}
+		// Транспорт по умолчанию выводится из заданных полей; /config/info показывает итоговый
+		servers[i].Transport = transport
+	}
+	return nil
+}
+
+func validateCORSConfig(cors CORSConfig) error {
+	for _, origin := range cors.AllowedOrigins {
+		if origin == "*" {
+			// Браузеры не принимают credentials вместе с wildcard origin
+			if cors.AllowCredentials {
+				return fmt.Errorf("CORS allow_credentials cannot be used with wildcard origin '*'")
+			}
+			continue
+		}
+
+		if !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			return fmt.Errorf("CORS origin must start with http:// or https://: %s", origin)
+		}
+
+		if strings.Count(origin, "*") > 1 || (strings.Contains(origin, "*") && !strings.Contains(origin, "://*.")) {
+			return fmt.Errorf("CORS origin wildcard is only allowed as a subdomain prefix (https://*.example.com): %s", origin)
+		}
+	}
+
+	if cors.MaxAge < 0 {
+		return fmt.Errorf("CORS max_age cannot be negative: %s", cors.MaxAge)
+	}
+
+	return nil
+}
+
 // GetConfigSource возвращает информацию о том, откуда взяты настройки
 func GetConfigSource(config *Config) map[string]string {
 	sources := make(map[string]string)
@@ -296,8 +1221,21 @@ func GetConfigSource(config *Config) map[string]string {
 	}
 
 	sources["config_file"] = viper.ConfigFileUsed()
-	sources["provider"] = "gemini (MCP)"
+	sources["provider"] = config.LLM.Provider
+	if providers.HasMCPSupport(config.LLM.Provider) {
+		sources["provider"] += " (MCP)"
+	}
 	sources["mcp_server"] = config.MCP.ServerURL
+	if config.MCP.Transport == providers.MCPTransportStdio {
+		sources["mcp_server"] = strings.TrimSpace(config.MCP.PythonPath + " " + config.MCP.ServerPath)
+	}
+	if len(config.MCP.Servers) > 0 {
+		names := make([]string, 0, len(config.MCP.Servers))
+		for _, server := range config.MCP.Servers {
+			names = append(names, server.Name)
+		}
+		sources["mcp_server"] = "config.yaml (servers: " + strings.Join(names, ", ") + ")"
+	}
 	sources["system_prompt"] = config.MCP.SystemPromptPath
 	sources["database_url"] = config.Database.URL
 
@@ -315,9 +1253,22 @@ func GetGeminiEnvVars() []string {
 // GetMCPEnvVars возвращает переменные окружения для MCP
 func GetMCPEnvVars() []string {
 	return []string{
+		"CHAT_LLM_MCP_TRANSPORT",
 		"CHAT_LLM_MCP_SERVER_URL",
+		"CHAT_LLM_MCP_SERVER_PATH",
+		"CHAT_LLM_MCP_PYTHON_PATH",
 		"CHAT_LLM_MCP_SYSTEM_PROMPT_PATH",
 		"CHAT_LLM_MCP_MAX_ITERATIONS",
+		"CHAT_LLM_MCP_MAX_TOOL_RESULT_BYTES",
+		"CHAT_LLM_MCP_MAX_CONCURRENT_TOOL_CALLS",
+		"CHAT_LLM_MCP_TOOL_CALL_TIMEOUT",
+		"CHAT_LLM_MCP_ALLOWED_TOOLS",
+		"CHAT_LLM_MCP_DENIED_TOOLS",
+		"CHAT_LLM_MCP_TOOLS_REFRESH_INTERVAL",
+		"CHAT_LLM_MCP_RECONNECT_ATTEMPTS",
+		"CHAT_LLM_MCP_PROBE_TIMEOUT",
+		"CHAT_LLM_MCP_STATUS_CACHE_TTL",
+		"CHAT_LLM_MCP_DEBUG_ENDPOINTS",
 	}
 }
 
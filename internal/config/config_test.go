@@ -0,0 +1,25 @@
+package config
+
+import "testing"
+
+func TestValidateCORSConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		cors    CORSConfig
+		wantErr bool
+	}{
+		{"wildcard", CORSConfig{AllowedOrigins: []string{"*"}}, false},
+		{"wildcard with credentials", CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}, true},
+		{"exact with credentials", CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true}, false},
+		{"subdomain wildcard", CORSConfig{AllowedOrigins: []string{"https://*.example.com"}}, false},
+		{"wildcard in the middle", CORSConfig{AllowedOrigins: []string{"https://app.*.com"}}, true},
+		{"missing scheme", CORSConfig{AllowedOrigins: []string{"app.example.com"}}, true},
+		{"negative max age", CORSConfig{MaxAge: -1}, true},
+	}
+
+	for _, tt := range tests {
+		if err := validateCORSConfig(tt.cors); (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
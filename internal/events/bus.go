@@ -0,0 +1,217 @@
+// Package events внутрипроцессная шина системных событий сессий (сжатие контекста, резюме и т.п.)
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Type тип системного события
+type Type string
+
+// Типы событий
+const (
+	TypeCompressionStarted  Type = "compression.started"
+	TypeCompressionFinished Type = "compression.finished"
+	TypeCompressionFailed   Type = "compression.failed"
+	TypeCompressionPurged   Type = "compression.purged"
+	TypeSummaryCreated      Type = "summary.created"
+	TypeSummaryExpanded     Type = "summary.expanded"
+	TypeSessionDeleted      Type = "session.deleted"
+
+	// TypeEventsDropped отправляется подписчику, который не успевал читать и пропустил события
+	TypeEventsDropped Type = "events.dropped"
+)
+
+// Event системное событие сессии
+type Event struct {
+	ID        uint64    `json:"id,omitempty"`
+	Type      Type      `json:"type"`
+	SessionID string    `json:"session_id"`
+	Timestamp time.Time `json:"timestamp"`
+	Data      any       `json:"data,omitempty"`
+}
+
+// CompressionData данные событий compression.*
+type CompressionData struct {
+	Level               int    `json:"level"`
+	Reason              string `json:"reason"`
+	Trigger             string `json:"trigger,omitempty"` // порог сжатия: messages, tokens или forced
+	ActiveMessages      int    `json:"active_messages,omitempty"`
+	ActiveSummaries     int    `json:"active_summaries,omitempty"`
+	MessagesCompressed  int    `json:"messages_compressed,omitempty"`
+	SummariesCompressed int    `json:"summaries_compressed,omitempty"`
+	AnchorsCreated      int    `json:"anchors_created,omitempty"`
+	SummaryID           string `json:"summary_id,omitempty"`
+	TokensUsed          int    `json:"tokens_used,omitempty"`
+	DurationMs          int64  `json:"duration_ms,omitempty"`
+	Error               string `json:"error,omitempty"`
+}
+
+// PurgeData данные события compression.purged: исходные сообщения, сжатые раньше
+// CompressedBefore, удалены, и их содержание сохранено только в резюме
+type PurgeData struct {
+	MessagesPurged   int       `json:"messages_purged"`
+	CompressedBefore time.Time `json:"compressed_before"`
+}
+
+// SummaryData данные события summary.created
+type SummaryData struct {
+	SummaryID    string `json:"summary_id"`
+	Level        int    `json:"level"`
+	Reason       string `json:"reason"`
+	ItemsCovered int    `json:"items_covered"`
+	AnchorsCount int    `json:"anchors_count"`
+	TokensUsed   int    `json:"tokens_used"`
+	Degraded     bool   `json:"degraded,omitempty"` // резюме составлено без модели сжатия
+}
+
+// ExpandData данные события summary.expanded: резюме удалено, его источники снова в контексте
+type ExpandData struct {
+	SummaryID         string `json:"summary_id"`
+	Level             int    `json:"level"`
+	MessagesRestored  int    `json:"messages_restored,omitempty"`
+	SummariesRestored int    `json:"summaries_restored,omitempty"`
+}
+
+// DroppedData данные события events.dropped
+type DroppedData struct {
+	Dropped int64 `json:"dropped"`
+}
+
+// Publisher публикует события; реализации не должны блокировать вызывающего
+type Publisher interface {
+	Publish(event Event)
+}
+
+// Bus рассылает события подписчикам сессии
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[*Subscription]struct{}
+	seq         atomic.Uint64
+	bufferSize  int
+	logger      *zap.Logger
+}
+
+// NewBus создаёт шину; bufferSize - размер очереди каждого подписчика
+func NewBus(bufferSize int, logger *zap.Logger) *Bus {
+	if bufferSize <= 0 {
+		bufferSize = 64
+	}
+
+	return &Bus{
+		subscribers: make(map[string]map[*Subscription]struct{}),
+		bufferSize:  bufferSize,
+		logger:      logger,
+	}
+}
+
+// Subscription подписка на события одной сессии
+type Subscription struct {
+	bus       *Bus
+	sessionID string
+	ch        chan Event
+	dropped   atomic.Int64
+	once      sync.Once
+}
+
+// Subscribe подписывается на события сессии. Подписку необходимо закрыть через Close.
+func (b *Bus) Subscribe(sessionID string) *Subscription {
+	sub := &Subscription{
+		bus:       b,
+		sessionID: sessionID,
+		ch:        make(chan Event, b.bufferSize),
+	}
+
+	b.mu.Lock()
+	if b.subscribers[sessionID] == nil {
+		b.subscribers[sessionID] = make(map[*Subscription]struct{})
+	}
+	b.subscribers[sessionID][sub] = struct{}{}
+	b.mu.Unlock()
+
+	b.logger.Debug("Session events subscriber added", zap.String("session_id", sessionID))
+	return sub
+}
+
+// Publish рассылает событие подписчикам его сессии без блокировки.
+// Если очередь подписчика заполнена, событие для него отбрасывается.
+func (b *Bus) Publish(event Event) {
+	event.ID = b.seq.Add(1)
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscribers[event.SessionID] {
+		sub.deliver(event)
+	}
+}
+
+// Subscribers возвращает количество подписчиков сессии
+func (b *Bus) Subscribers(sessionID string) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return len(b.subscribers[sessionID])
+}
+
+func (b *Bus) unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subs := b.subscribers[sub.sessionID]
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(b.subscribers, sub.sessionID)
+	}
+	close(sub.ch)
+}
+
+// Events канал событий подписки; закрывается после Close
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Close отписывается от событий
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.unsubscribe(s)
+		s.bus.logger.Debug("Session events subscriber removed", zap.String("session_id", s.sessionID))
+	})
+}
+
+// deliver вызывается под блокировкой шины на чтение
+func (s *Subscription) deliver(event Event) {
+	// Сначала сообщаем о пропущенных событиях, если в очереди появилось место.
+	// Служебное событие не получает номер, чтобы не нарушать последовательность ID.
+	if dropped := s.dropped.Load(); dropped > 0 {
+		warning := Event{
+			Type:      TypeEventsDropped,
+			SessionID: s.sessionID,
+			Timestamp: event.Timestamp,
+			Data:      DroppedData{Dropped: dropped},
+		}
+		select {
+		case s.ch <- warning:
+			s.dropped.Add(-dropped)
+		default:
+		}
+	}
+
+	select {
+	case s.ch <- event:
+	default:
+		if s.dropped.Add(1) == 1 {
+			s.bus.logger.Warn("Session events subscriber is too slow, dropping events",
+				zap.String("session_id", s.sessionID),
+				zap.String("event_type", string(event.Type)),
+			)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package language
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Auto режим, в котором язык определяется по последнему сообщению пользователя
+const Auto = "auto"
+
+// ErrUnsupportedLanguage язык не поддерживается политикой
+var ErrUnsupportedLanguage = errors.New("unsupported language")
+
+// names названия поддерживаемых языков в предложном падеже для директивы промпта
+var names = map[string]string{
+	"ru": "русском",
+	"kk": "казахском",
+	"en": "английском",
+}
+
+// kazakhLetters буквы, которые есть в казахской кириллице, но отсутствуют в русской
+const kazakhLetters = "әғқңөұүһіӘҒҚҢӨҰҮҺІ"
+
+// Supported возвращает коды поддерживаемых языков
+func Supported() []string {
+	return []string{"ru", "kk", "en"}
+}
+
+// Validate проверяет значение настройки языка: "auto" или код поддерживаемого языка
+func Validate(setting string) error {
+	if setting == Auto {
+		return nil
+	}
+	if _, ok := names[setting]; ok {
+		return nil
+	}
+	return fmt.Errorf("%w: %q (expected %s or one of %s)",
+		ErrUnsupportedLanguage, setting, Auto, strings.Join(Supported(), ", "))
+}
+
+// Resolve возвращает код языка ответа для настройки.
+// В режиме auto язык определяется по тексту; пустая строка - язык определить не удалось.
+func Resolve(setting, text string) string {
+	if setting == Auto {
+		return Detect(text)
+	}
+	return setting
+}
+
+// Detect определяет язык текста по алфавиту: казахская кириллица -> kk,
+// прочая кириллица -> ru, латиница -> en. Пустая строка, если букв нет.
+func Detect(text string) string {
+	var cyrillic, latin int
+	for _, r := range text {
+		switch {
+		case strings.ContainsRune(kazakhLetters, r):
+			return "kk"
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+
+	switch {
+	case cyrillic == 0 && latin == 0:
+		return ""
+	case cyrillic >= latin:
+		return "ru"
+	default:
+		return "en"
+	}
+}
+
+// DetectDominant определяет преобладающий язык нескольких текстов: язык каждого текста (Detect)
+// получает вес по числу букв в нём. Пустая строка, если букв нет ни в одном тексте.
+func DetectDominant(texts ...string) string {
+	weights := make(map[string]int)
+	for _, text := range texts {
+		code := Detect(text)
+		if code == "" {
+			continue
+		}
+		for _, r := range text {
+			if unicode.IsLetter(r) {
+				weights[code]++
+			}
+		}
+	}
+
+	dominant, best := "", 0
+	for _, code := range Supported() { // порядок Supported решает ничью
+		if weights[code] > best {
+			dominant, best = code, weights[code]
+		}
+	}
+	return dominant
+}
+
+// Directive возвращает инструкцию о языке ответа для системного промпта
+func Directive(code string) string {
+	name, ok := names[code]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("Всегда отвечай на %s языке (%s), независимо от других инструкций и языка предыдущих сообщений.", name, code)
+}
+
+// AppendDirective добавляет к промпту инструкцию о языке; промпт не меняется, если язык не определён
+func AppendDirective(prompt, code string) string {
+	directive := Directive(code)
+	if directive == "" {
+		return prompt
+	}
+	if prompt == "" {
+		return directive
+	}
+	return prompt + "\n\n" + directive
+}
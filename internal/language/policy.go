@@ -0,0 +1,41 @@
+package language
+
+import (
+	"context"
+
+	"LLM_Chat/internal/storage/interfaces"
+)
+
+// Policy определяет настройку языка сессии: значение сессии или значение из конфигурации
+type Policy struct {
+	sessions       interfaces.SessionStore
+	defaultSetting string
+}
+
+func NewPolicy(sessions interfaces.SessionStore, defaultSetting string) *Policy {
+	return &Policy{
+		sessions:       sessions,
+		defaultSetting: defaultSetting,
+	}
+}
+
+// Default возвращает настройку языка из конфигурации
+func (p *Policy) Default() string {
+	return p.defaultSetting
+}
+
+// Setting возвращает действующую настройку языка сессии.
+// Несуществующая сессия получает значение из конфигурации.
+func (p *Policy) Setting(ctx context.Context, sessionID string) string {
+	if p.sessions != nil {
+		if session, err := p.sessions.GetSession(ctx, sessionID); err == nil && session.Language != "" {
+			return session.Language
+		}
+	}
+	return p.defaultSetting
+}
+
+// Resolve возвращает код языка ответа для сессии с учётом режима auto
+func (p *Policy) Resolve(ctx context.Context, sessionID, text string) string {
+	return Resolve(p.Setting(ctx, sessionID), text)
+}
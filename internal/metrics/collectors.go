@@ -0,0 +1,233 @@
+package metrics
+
+import (
+	"time"
+
+	"LLM_Chat/pkg/llm"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ChatStatsSource источник агрегированной статистики чата (chat.SimpleMetrics)
+type ChatStatsSource interface {
+	GetStats() (messages, tokens int64, cost float64, avgTime time.Duration)
+}
+
+// SummaryStatsSource источник агрегированной статистики резюме (summary.SummaryMetrics)
+type SummaryStatsSource interface {
+	GetStats() (summaries, anchors, tokens, compressed int64, avgTime time.Duration)
+}
+
+// IdleCompressionStatsSource источник счётчиков фонового сжатия (chat.IdleCompressionJob)
+type IdleCompressionStatsSource interface {
+	Stats() (sessions, compressions, failures int64)
+}
+
+// LLMStatsSource источник счётчиков запросов к провайдерам (llm.Stats)
+type LLMStatsSource interface {
+	Snapshot() []llm.ProviderStatsSnapshot
+}
+
+// LLMConcurrencySource источник загрузки пула одновременных запросов клиента (llm.Client)
+type LLMConcurrencySource interface {
+	ConcurrencyStats() (llm.LimiterStats, bool)
+}
+
+// RegisterChatStats регистрирует коллектор статистики чата
+func (m *Metrics) RegisterChatStats(source ChatStatsSource) error {
+	return m.registry.Register(&chatStatsCollector{source: source})
+}
+
+// RegisterSummaryStats регистрирует коллектор статистики резюме
+func (m *Metrics) RegisterSummaryStats(source SummaryStatsSource) error {
+	return m.registry.Register(&summaryStatsCollector{source: source})
+}
+
+// RegisterIdleCompressionStats регистрирует коллектор фонового сжатия простаивающих сессий
+func (m *Metrics) RegisterIdleCompressionStats(source IdleCompressionStatsSource) error {
+	return m.registry.Register(&idleCompressionStatsCollector{source: source})
+}
+
+// RegisterLLMStats регистрирует коллектор счётчиков провайдеров LLM
+func (m *Metrics) RegisterLLMStats(source LLMStatsSource) error {
+	return m.registry.Register(&llmStatsCollector{source: source})
+}
+
+// RegisterLLMConcurrency регистрирует коллектор пулов одновременных запросов по имени клиента
+func (m *Metrics) RegisterLLMConcurrency(sources map[string]LLMConcurrencySource) error {
+	return m.registry.Register(&llmConcurrencyCollector{sources: sources})
+}
+
+var (
+	chatMessagesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "chat", "messages_total"),
+		"Total number of processed chat messages.", nil, nil)
+	chatTokensDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "chat", "tokens_total"),
+		"Total number of tokens used by chat responses.", nil, nil)
+	chatCostDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "chat", "cost_total"),
+		"Total estimated cost of chat responses.", nil, nil)
+	chatResponseTimeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "chat", "response_time_avg_seconds"),
+		"Average chat response time in seconds.", nil, nil)
+
+	summariesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "summary", "created_total"),
+		"Total number of created summaries.", nil, nil)
+	summaryAnchorsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "summary", "anchors_total"),
+		"Total number of created anchors.", nil, nil)
+	summaryTokensDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "summary", "tokens_total"),
+		"Total number of tokens used for summaries.", nil, nil)
+	summaryCompressedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "summary", "items_compressed_total"),
+		"Total number of messages and summaries compressed.", nil, nil)
+	summaryTimeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "summary", "duration_avg_seconds"),
+		"Average summary creation time in seconds.", nil, nil)
+
+	idleSessionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "idle_compression", "sessions_total"),
+		"Total number of idle sessions processed by background compression.", nil, nil)
+	idleCompressionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "idle_compression", "compressions_total"),
+		"Total number of compressions performed by background compression.", nil, nil)
+	idleFailuresDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "idle_compression", "failures_total"),
+		"Total number of idle sessions whose background compression failed.", nil, nil)
+
+	providerRequestsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "llm_provider", "requests_total"),
+		"Total number of requests to LLM providers.", []string{"provider", "model"}, nil)
+	providerErrorsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "llm_provider", "errors_total"),
+		"Total number of failed LLM provider requests by error type.", []string{"provider", "model", "type"}, nil)
+	providerLatencyDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "llm_provider", "latency_seconds"),
+		"LLM provider request latency in seconds.", []string{"provider", "model"}, nil)
+
+	llmConcurrencyLimitDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "llm", "concurrency_limit"),
+		"Maximum number of concurrent LLM requests per client.", []string{"client"}, nil)
+	llmInFlightDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "llm", "requests_in_flight"),
+		"Number of LLM requests currently holding a concurrency slot.", []string{"client"}, nil)
+	llmQueuedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "llm", "requests_queued"),
+		"Number of LLM requests waiting for a concurrency slot.", []string{"client"}, nil)
+	llmQueueRejectedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "llm", "queue_timeouts_total"),
+		"Total number of LLM requests rejected after waiting queue_timeout for a slot.", []string{"client"}, nil)
+)
+
+type chatStatsCollector struct {
+	source ChatStatsSource
+}
+
+func (c *chatStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- chatMessagesDesc
+	ch <- chatTokensDesc
+	ch <- chatCostDesc
+	ch <- chatResponseTimeDesc
+}
+
+func (c *chatStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	messages, tokens, cost, avgTime := c.source.GetStats()
+
+	ch <- prometheus.MustNewConstMetric(chatMessagesDesc, prometheus.CounterValue, float64(messages))
+	ch <- prometheus.MustNewConstMetric(chatTokensDesc, prometheus.CounterValue, float64(tokens))
+	ch <- prometheus.MustNewConstMetric(chatCostDesc, prometheus.CounterValue, cost)
+	ch <- prometheus.MustNewConstMetric(chatResponseTimeDesc, prometheus.GaugeValue, avgTime.Seconds())
+}
+
+type summaryStatsCollector struct {
+	source SummaryStatsSource
+}
+
+func (c *summaryStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- summariesDesc
+	ch <- summaryAnchorsDesc
+	ch <- summaryTokensDesc
+	ch <- summaryCompressedDesc
+	ch <- summaryTimeDesc
+}
+
+func (c *summaryStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	summaries, anchors, tokens, compressed, avgTime := c.source.GetStats()
+
+	ch <- prometheus.MustNewConstMetric(summariesDesc, prometheus.CounterValue, float64(summaries))
+	ch <- prometheus.MustNewConstMetric(summaryAnchorsDesc, prometheus.CounterValue, float64(anchors))
+	ch <- prometheus.MustNewConstMetric(summaryTokensDesc, prometheus.CounterValue, float64(tokens))
+	ch <- prometheus.MustNewConstMetric(summaryCompressedDesc, prometheus.CounterValue, float64(compressed))
+	ch <- prometheus.MustNewConstMetric(summaryTimeDesc, prometheus.GaugeValue, avgTime.Seconds())
+}
+
+type idleCompressionStatsCollector struct {
+	source IdleCompressionStatsSource
+}
+
+func (c *idleCompressionStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- idleSessionsDesc
+	ch <- idleCompressionsDesc
+	ch <- idleFailuresDesc
+}
+
+func (c *idleCompressionStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	sessions, compressions, failures := c.source.Stats()
+
+	ch <- prometheus.MustNewConstMetric(idleSessionsDesc, prometheus.CounterValue, float64(sessions))
+	ch <- prometheus.MustNewConstMetric(idleCompressionsDesc, prometheus.CounterValue, float64(compressions))
+	ch <- prometheus.MustNewConstMetric(idleFailuresDesc, prometheus.CounterValue, float64(failures))
+}
+
+type llmStatsCollector struct {
+	source LLMStatsSource
+}
+
+func (c *llmStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- providerRequestsDesc
+	ch <- providerErrorsDesc
+	ch <- providerLatencyDesc
+}
+
+func (c *llmStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, stats := range c.source.Snapshot() {
+		ch <- prometheus.MustNewConstMetric(providerRequestsDesc, prometheus.CounterValue,
+			float64(stats.Requests), stats.Provider, stats.Model)
+
+		for _, errType := range []string{llm.ErrorTypeRateLimit, llm.ErrorTypeTimeout, llm.ErrorTypeUpstream, llm.ErrorTypeOther} {
+			ch <- prometheus.MustNewConstMetric(providerErrorsDesc, prometheus.CounterValue,
+				float64(stats.Errors[errType]), stats.Provider, stats.Model, errType)
+		}
+
+		ch <- prometheus.MustNewConstHistogram(providerLatencyDesc,
+			stats.LatencyCount, stats.LatencySum, stats.LatencyBuckets, stats.Provider, stats.Model)
+	}
+}
+
+type llmConcurrencyCollector struct {
+	sources map[string]LLMConcurrencySource
+}
+
+func (c *llmConcurrencyCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- llmConcurrencyLimitDesc
+	ch <- llmInFlightDesc
+	ch <- llmQueuedDesc
+	ch <- llmQueueRejectedDesc
+}
+
+func (c *llmConcurrencyCollector) Collect(ch chan<- prometheus.Metric) {
+	for client, source := range c.sources {
+		stats, ok := source.ConcurrencyStats()
+		if !ok {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(llmConcurrencyLimitDesc, prometheus.GaugeValue, float64(stats.Limit), client)
+		ch <- prometheus.MustNewConstMetric(llmInFlightDesc, prometheus.GaugeValue, float64(stats.InFlight), client)
+		ch <- prometheus.MustNewConstMetric(llmQueuedDesc, prometheus.GaugeValue, float64(stats.Queued), client)
+		ch <- prometheus.MustNewConstMetric(llmQueueRejectedDesc, prometheus.CounterValue, float64(stats.Rejected), client)
+	}
+}
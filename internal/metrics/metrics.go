@@ -0,0 +1,171 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"LLM_Chat/pkg/llm"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace общий префикс всех метрик приложения
+const namespace = "llmchat"
+
+// Metrics реестр Prometheus метрик приложения.
+// Метки намеренно не содержат session_id и других высококардинальных значений.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	httpInFlight *prometheus.GaugeVec
+	httpPanics   *prometheus.CounterVec
+
+	llmRequests *prometheus.CounterVec
+	llmTokens   *prometheus.CounterVec
+	llmDuration *prometheus.HistogramVec
+
+	toolCalls    *prometheus.CounterVec
+	toolDuration *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Total number of HTTP requests.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "HTTP request duration in seconds.",
+			Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+		}, []string{"method", "route", "status"}),
+		httpInFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_in_flight",
+			Help:      "Number of HTTP requests currently being served.",
+		}, []string{"route"}),
+		httpPanics: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "panics_total",
+			Help:      "Total number of panics recovered in HTTP handlers.",
+		}, []string{"route"}),
+
+		llmRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "llm",
+			Name:      "requests_total",
+			Help:      "Total number of LLM completion requests.",
+		}, []string{"client", "provider", "status"}),
+		llmTokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "llm",
+			Name:      "tokens_total",
+			Help:      "Total number of tokens used by LLM requests.",
+		}, []string{"client", "provider", "model"}),
+		llmDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "llm",
+			Name:      "request_duration_seconds",
+			Help:      "LLM completion request duration in seconds.",
+			Buckets:   []float64{0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+		}, []string{"client", "provider"}),
+
+		toolCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "mcp",
+			Name:      "tool_calls_total",
+			Help:      "Total number of MCP tool calls.",
+		}, []string{"tool", "status"}),
+		toolDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "mcp",
+			Name:      "tool_call_duration_seconds",
+			Help:      "MCP tool call duration in seconds.",
+			Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		}, []string{"tool"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.httpInFlight,
+		m.httpPanics,
+		m.llmRequests,
+		m.llmTokens,
+		m.llmDuration,
+		m.toolCalls,
+		m.toolDuration,
+	)
+
+	return m
+}
+
+// Handler возвращает HTTP обработчик для /metrics
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Registry возвращает реестр для регистрации дополнительных коллекторов
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// HTTPInFlight возвращает счётчик активных запросов для маршрута
+func (m *Metrics) HTTPInFlight(route string) prometheus.Gauge {
+	return m.httpInFlight.WithLabelValues(route)
+}
+
+// ObserveHTTPRequest записывает завершённый HTTP запрос
+func (m *Metrics) ObserveHTTPRequest(method, route, status string, duration time.Duration) {
+	m.httpRequests.WithLabelValues(method, route, status).Inc()
+	m.httpDuration.WithLabelValues(method, route, status).Observe(duration.Seconds())
+}
+
+// IncPanics учитывает перехваченную панику обработчика
+func (m *Metrics) IncPanics(route string) {
+	m.httpPanics.WithLabelValues(route).Inc()
+}
+
+// LLMObserver возвращает наблюдателя за запросами к LLM для клиента (main, shrink)
+func (m *Metrics) LLMObserver(client string) llm.UsageObserver {
+	return func(provider, model string, usage llm.Usage, duration time.Duration, err error) {
+		status := "success"
+		if err != nil {
+			status = "error"
+		}
+
+		m.llmRequests.WithLabelValues(client, provider, status).Inc()
+		m.llmDuration.WithLabelValues(client, provider).Observe(duration.Seconds())
+
+		if usage.TotalTokens > 0 {
+			m.llmTokens.WithLabelValues(client, provider, model).Add(float64(usage.TotalTokens))
+		}
+	}
+}
+
+// ToolCallObserver возвращает наблюдателя за вызовами MCP инструментов
+func (m *Metrics) ToolCallObserver() llm.ToolCallObserver {
+	return func(tool string, duration time.Duration, err error) {
+		status := "success"
+		if err != nil {
+			status = "error"
+		}
+
+		m.toolCalls.WithLabelValues(tool, status).Inc()
+		m.toolDuration.WithLabelValues(tool).Observe(duration.Seconds())
+	}
+}
@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"LLM_Chat/internal/storage/interfaces"
+	"LLM_Chat/internal/storage/models"
+	"LLM_Chat/pkg/llm"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// recordTimeout ограничение времени записи одной записи журнала
+const recordTimeout = 3 * time.Second
+
+// ToolRecorder записывает вызовы MCP инструментов в журнал аудита.
+// Ошибки хранилища только логируются - запрос пользователя из-за них не прерывается.
+type ToolRecorder struct {
+	store  interfaces.ToolInvocationStore
+	logger *zap.Logger
+}
+
+func NewToolRecorder(store interfaces.ToolInvocationStore, logger *zap.Logger) *ToolRecorder {
+	return &ToolRecorder{
+		store:  store,
+		logger: logger.With(zap.String("component", "tool_audit")),
+	}
+}
+
+// RecordToolInvocation сохраняет запись о вызове инструмента.
+// Запись выполняется и после отмены запроса: инструмент уже был выполнен.
+func (r *ToolRecorder) RecordToolInvocation(ctx context.Context, invocation llm.ToolInvocation) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+
+	record := models.ToolInvocation{
+		ID:         uuid.New().String(),
+		SessionID:  invocation.SessionID,
+		UserID:     invocation.UserID,
+		ToolName:   invocation.Tool,
+		Arguments:  invocation.Arguments,
+		ResultHash: invocation.ResultHash,
+		Success:    invocation.Success,
+		Error:      invocation.Error,
+		DurationMs: invocation.Duration.Milliseconds(),
+		CreatedAt:  invocation.StartedAt,
+	}
+
+	if err := r.store.SaveToolInvocation(ctx, record); err != nil {
+		r.logger.Error("Failed to record tool invocation",
+			zap.String("tool_name", record.ToolName),
+			zap.String("session_id", record.SessionID),
+			zap.Bool("success", record.Success),
+			zap.Error(err),
+		)
+	}
+}
+
+// Verify interface implementation
+var _ llm.ToolInvocationRecorder = (*ToolRecorder)(nil)
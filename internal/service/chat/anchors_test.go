@@ -0,0 +1,56 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestActiveAnchorsAfterCompression(t *testing.T) {
+	ctx := context.Background()
+	client := &recordingLLM{reply: "ok"}
+	service, store := newTestService(t, client, testChatConfig())
+	for i := 0; i < 2; i++ {
+		seedDialog(t, store, "s1", i*30, (i+1)*30)
+		result, err := service.TriggerCompression(ctx, "s1", true)
+		if err != nil {
+			t.Fatalf("TriggerCompression: %v", err)
+		}
+		if result.MessagesCompressed == 0 {
+			t.Fatalf("compression %d did not compress messages: %+v", i+1, result)
+		}
+	}
+
+	// Якоря обоих резюме без повторов общего "Погода"
+	want := "[Погода Тема 1 Тема 2]"
+
+	resp, err := service.ProcessMessage(ctx, ProcessMessageRequest{SessionID: "s1", Message: "Что дальше?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(resp.ContextInfo.ActiveAnchors); got != want {
+		t.Errorf("ProcessMessage anchors = %s, want %s", got, want)
+	}
+
+	stream, err := service.ProcessMessageStream(ctx, ProcessMessageRequest{SessionID: "s1", Message: "А ещё?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var streamed []string
+	for event := range stream {
+		if event.ContextInfo != nil {
+			streamed = event.ContextInfo.ActiveAnchors
+		}
+	}
+	if got := fmt.Sprint(streamed); got != want {
+		t.Errorf("stream context event anchors = %s, want %s", got, want)
+	}
+
+	info, err := service.GetContextInfo(ctx, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(info.ActiveAnchors); got != want {
+		t.Errorf("GetContextInfo anchors = %s, want %s", got, want)
+	}
+}
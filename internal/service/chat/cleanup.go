@@ -0,0 +1,148 @@
+package chat
+
+import (
+	"context"
+	"time"
+
+	"LLM_Chat/internal/config"
+	"LLM_Chat/internal/events"
+	"LLM_Chat/internal/storage/interfaces"
+
+	"go.uber.org/zap"
+)
+
+// cleanupRunTimeout ограничение времени одного прохода задачи очистки
+const cleanupRunTimeout = 30 * time.Second
+
+// CleanupJob фоновая задача обслуживания сообщений.
+// Закрывает сообщения пользователя, зависшие в pending (например, после падения
+// процесса во время генерации), переводя их в failed. Если задан purgeCompressedAfter,
+// удаляет исходные сообщения, сжатые в резюме раньше этого срока.
+type CleanupJob struct {
+	messageStore         interfaces.MessageStore
+	events               events.Publisher
+	config               config.CleanupConfig
+	purgeCompressedAfter time.Duration
+	logger               *zap.Logger
+}
+
+// NewCleanupJob создаёт задачу очистки; purgeCompressedAfter = 0 отключает удаление сжатых сообщений
+func NewCleanupJob(
+	messageStore interfaces.MessageStore,
+	eventPublisher events.Publisher,
+	cfg config.CleanupConfig,
+	purgeCompressedAfter time.Duration,
+	logger *zap.Logger,
+) *CleanupJob {
+	return &CleanupJob{
+		messageStore:         messageStore,
+		events:               eventPublisher,
+		config:               cfg,
+		purgeCompressedAfter: purgeCompressedAfter,
+		logger:               logger.With(zap.String("component", "cleanup_job")),
+	}
+}
+
+// Start выполняет первый проход сразу (восстановление после перезапуска) и затем
+// повторяет его с интервалом config.Interval до отмены ctx. Не блокирует вызывающего.
+func (j *CleanupJob) Start(ctx context.Context) {
+	if j.config.Interval <= 0 {
+		j.logger.Info("Cleanup job disabled")
+		return
+	}
+
+	go func() {
+		j.run(ctx)
+
+		ticker := time.NewTicker(j.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				j.run(ctx)
+			}
+		}
+	}()
+}
+
+// RunOnce переводит в failed сообщения, ожидающие ответа дольше config.PendingTimeout
+func (j *CleanupJob) RunOnce(ctx context.Context) (int, error) {
+	return j.messageStore.FailStalePendingMessages(ctx, time.Now().Add(-j.config.PendingTimeout))
+}
+
+// PurgeCompressed удаляет пачками по config.PurgeBatchSize сообщения, сжатые раньше
+// purgeCompressedAfter, и публикует compression.purged по каждой затронутой сессии.
+// Резюме остаются; их границы покрытия могут ссылаться на удалённые сообщения.
+func (j *CleanupJob) PurgeCompressed(ctx context.Context) (int, error) {
+	if j.purgeCompressedAfter <= 0 {
+		return 0, nil
+	}
+
+	compressedBefore := time.Now().Add(-j.purgeCompressedAfter)
+	perSession := make(map[string]int)
+	total := 0
+
+	var err error
+	for ctx.Err() == nil {
+		var batch map[string]int
+		batch, err = j.messageStore.PurgeCompressedMessages(ctx, compressedBefore, j.config.PurgeBatchSize)
+		if err != nil {
+			break
+		}
+
+		purged := 0
+		for sessionID, count := range batch {
+			perSession[sessionID] += count
+			purged += count
+		}
+		total += purged
+
+		if purged < j.config.PurgeBatchSize {
+			break
+		}
+	}
+
+	// Уже удалённые пачки попадают в историю сжатия и при ошибке следующей пачки
+	for sessionID, count := range perSession {
+		j.events.Publish(events.Event{
+			Type:      events.TypeCompressionPurged,
+			SessionID: sessionID,
+			Data: events.PurgeData{
+				MessagesPurged:   count,
+				CompressedBefore: compressedBefore,
+			},
+		})
+	}
+
+	return total, err
+}
+
+func (j *CleanupJob) run(ctx context.Context) {
+	runCtx, cancel := context.WithTimeout(ctx, cleanupRunTimeout)
+	defer cancel()
+
+	failed, err := j.RunOnce(runCtx)
+	if err != nil {
+		j.logger.Error("Cleanup run failed", zap.Error(err))
+	} else if failed > 0 {
+		j.logger.Warn("Stale pending messages marked as failed",
+			zap.Int("messages", failed),
+			zap.Duration("pending_timeout", j.config.PendingTimeout),
+		)
+	}
+
+	purged, err := j.PurgeCompressed(runCtx)
+	if err != nil {
+		j.logger.Error("Compressed messages purge failed", zap.Int("purged", purged), zap.Error(err))
+		return
+	}
+	if purged > 0 {
+		j.logger.Info("Compressed messages purged",
+			zap.Int("messages", purged),
+			zap.Duration("purge_compressed_after", j.purgeCompressedAfter),
+		)
+	}
+}
@@ -0,0 +1,37 @@
+package chat
+
+import (
+	"context"
+	"testing"
+)
+
+func TestForcedCompressionReportsCounts(t *testing.T) {
+	ctx := context.Background()
+	service, store := newTestService(t, &recordingLLM{reply: "ok"}, testChatConfig())
+
+	// Пять сжатий сообщений дают резюме; шестое сворачивает три старших в bulk резюме, два остаются
+	for i := range 5 {
+		seedDialog(t, store, "s1", i*10, (i+1)*10)
+		result, err := service.TriggerCompression(ctx, "s1", true)
+		if err != nil {
+			t.Fatalf("TriggerCompression: %v", err)
+		}
+		if result.Level != 1 || result.MessagesCompressed == 0 || result.AnchorsCreated != 2 || result.TokensUsed == 0 {
+			t.Fatalf("level 1 compression %d: %+v", i+1, result)
+		}
+		if result.SummariesCompressed != 0 {
+			t.Errorf("level 1 compression reports %d compressed summaries", result.SummariesCompressed)
+		}
+	}
+
+	result, err := service.TriggerCompression(ctx, "s1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Level != 2 || result.SummariesCompressed != 3 || result.AnchorsCreated != 2 || result.TokensUsed == 0 {
+		t.Errorf("level 2 compression: %+v", result)
+	}
+	if result.MessagesCompressed != 0 {
+		t.Errorf("level 2 compression reports %d compressed messages", result.MessagesCompressed)
+	}
+}
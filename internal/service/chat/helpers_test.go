@@ -0,0 +1,150 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"LLM_Chat/internal/config"
+	"LLM_Chat/internal/language"
+	contextmgr "LLM_Chat/internal/service/context"
+	"LLM_Chat/internal/service/summary"
+	"LLM_Chat/internal/storage/memory"
+	"LLM_Chat/internal/storage/models"
+	"LLM_Chat/pkg/llm"
+
+	"go.uber.org/zap"
+)
+
+// recordingLLM модель для тестов: запоминает сообщения каждого запроса и отвечает reply,
+// в потоковом режиме - по словам
+type recordingLLM struct {
+	mu       sync.Mutex
+	requests [][]llm.Message
+	reply    string
+}
+
+func (c *recordingLLM) record(messages []llm.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, append([]llm.Message(nil), messages...))
+}
+
+func (c *recordingLLM) ChatCompletion(ctx context.Context, messages []llm.Message) (*llm.ChatResponse, error) {
+	c.record(messages)
+	return &llm.ChatResponse{
+		Model:   "fake-model",
+		Choices: []llm.Choice{{Message: llm.Message{Role: "assistant", Content: c.reply}}},
+		Usage:   llm.Usage{TotalTokens: 5},
+	}, nil
+}
+
+func (c *recordingLLM) ChatCompletionStream(ctx context.Context, messages []llm.Message) (<-chan llm.StreamChunk, error) {
+	c.record(messages)
+	ch := make(chan llm.StreamChunk, 16)
+	go func() {
+		defer close(ch)
+		for _, word := range strings.SplitAfter(c.reply, " ") {
+			ch <- llm.StreamChunk{Content: word}
+		}
+		ch <- llm.StreamChunk{Done: true}
+	}()
+	return ch, nil
+}
+
+func (c *recordingLLM) GetProviderName() string { return "fake" }
+
+func (c *recordingLLM) GetSupportedModels() []string { return []string{"fake-model"} }
+
+// lastSystemPrompt системный промпт последнего запроса к модели
+func (c *recordingLLM) lastSystemPrompt() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.requests) == 0 {
+		return ""
+	}
+	messages := c.requests[len(c.requests)-1]
+	if len(messages) == 0 || messages[0].Role != "system" {
+		return ""
+	}
+	return messages[0].Content
+}
+
+// shrinkLLM модель сжатия: отвечает JSON с якорями "Погода" и "Тема N" и резюме
+type shrinkLLM struct {
+	recordingLLM
+}
+
+func (c *shrinkLLM) ChatCompletion(ctx context.Context, messages []llm.Message) (*llm.ChatResponse, error) {
+	c.record(messages)
+	c.mu.Lock()
+	n := len(c.requests)
+	c.mu.Unlock()
+
+	output, _ := json.Marshal(map[string]any{
+		"anchors": []string{"Погода", fmt.Sprintf("Тема %d", n)},
+		"summary": fmt.Sprintf("Резюме %d", n),
+	})
+	return &llm.ChatResponse{
+		Choices: []llm.Choice{{Message: llm.Message{Role: "assistant", Content: string(output)}}},
+		Usage:   llm.Usage{TotalTokens: 10},
+	}, nil
+}
+
+// testChatConfig минимальная конфигурация чата для тестов
+func testChatConfig() *config.ChatConfig {
+	return &config.ChatConfig{
+		ContextWindowSize:  20,
+		Language:           language.Auto,
+		MaxIterationsLimit: 10,
+	}
+}
+
+// newTestService сервис чата над хранилищем в памяти, настоящим менеджером контекста и моделью client;
+// резюме создаёт shrinkLLM
+func newTestService(t *testing.T, client llm.LLMClient, cfg *config.ChatConfig) (*Service, *memory.MemoryStorage) {
+	t.Helper()
+
+	store := memory.New()
+	summaryService := summary.NewService(store, &shrinkLLM{}, nil, nil, summary.DefaultConfig(), zap.NewNop())
+	manager := contextmgr.NewManager(store, summaryService, nil, nil, nil, contextmgr.DefaultConfig(), zap.NewNop())
+	policy := language.NewPolicy(store, cfg.Language)
+	return NewService(store, store, manager, client, nil, policy, nil, nil, cfg, zap.NewNop()), store
+}
+
+// seedDialog сохраняет сообщения диалога с номерами from..to-1 и возрастающим временем создания
+func seedDialog(t *testing.T, store *memory.MemoryStorage, sessionID string, from, to int) {
+	t.Helper()
+
+	ctx := context.Background()
+	start := time.Now().Add(-time.Hour)
+	for i := from; i < to; i++ {
+		msg := models.NewUserMessage(sessionID, fmt.Sprintf("message %d", i))
+		if i%2 == 1 {
+			msg = models.NewAssistantMessage(sessionID, fmt.Sprintf("message %d", i))
+		}
+		msg.ID = fmt.Sprintf("%s-m%03d", sessionID, i)
+		msg.Timestamp = start.Add(time.Duration(i) * time.Second)
+		if err := store.SaveMessage(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// collectStream читает поток до закрытия и возвращает текст ответа и первую ошибку
+func collectStream(t *testing.T, ch <-chan StreamResponse) (string, error) {
+	t.Helper()
+
+	var b strings.Builder
+	for resp := range ch {
+		if resp.Error != nil {
+			return b.String(), resp.Error
+		}
+		b.WriteString(resp.Content)
+	}
+	return b.String(), nil
+}
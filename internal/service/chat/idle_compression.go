@@ -0,0 +1,213 @@
+package chat
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"LLM_Chat/internal/config"
+	contextmgr "LLM_Chat/internal/service/context"
+	"LLM_Chat/internal/storage/interfaces"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// idleCompressionTimeout ограничение времени сжатия одной сессии (все проходы)
+	idleCompressionTimeout = 5 * time.Minute
+	// idleCompressionMaxPasses предел проходов сжатия одной сессии. Compress сжимает один уровень
+	// за вызов, и новое резюме может превысить порог старшего уровня, поэтому проходов бывает
+	// несколько; предел защищает от зацикливания
+	idleCompressionMaxPasses = 10
+)
+
+// IdleCompressionJob фоновое сжатие сессий, которые не обновлялись дольше config.IdleAfter и в
+// которых больше config.MinActiveMessages несжатых сообщений. Сжатие выполняется через
+// ContextManager.Compress с Force для сообщений (уровень 1) под блокировкой сессии, поэтому не
+// пересекается со сжатием из запросов; старшие уровни сжимаются только по своим порогам. Запросы к модели shrink идут не чаще одного в config.MinDelay.
+type IdleCompressionJob struct {
+	sessionStore   interfaces.SessionStore
+	contextManager contextmgr.ContextManager
+	config         config.IdleCompressionConfig
+	logger         *zap.Logger
+
+	lastCompress time.Time     // только в горутине задачи
+	done         chan struct{} // закрывается, когда горутина задачи завершилась
+
+	sessions     atomic.Int64
+	compressions atomic.Int64
+	failures     atomic.Int64
+}
+
+// NewIdleCompressionJob создаёт задачу сжатия простаивающих сессий
+func NewIdleCompressionJob(
+	sessionStore interfaces.SessionStore,
+	contextManager contextmgr.ContextManager,
+	cfg config.IdleCompressionConfig,
+	logger *zap.Logger,
+) *IdleCompressionJob {
+	return &IdleCompressionJob{
+		sessionStore:   sessionStore,
+		contextManager: contextManager,
+		config:         cfg,
+		logger:         logger.With(zap.String("component", "idle_compression_job")),
+		done:           make(chan struct{}),
+	}
+}
+
+// Start повторяет проход с интервалом config.Interval до отмены ctx; первый проход - через
+// интервал после запуска, чтобы не нагружать модель при старте. Не блокирует вызывающего.
+func (j *IdleCompressionJob) Start(ctx context.Context) {
+	if j.config.Interval <= 0 {
+		j.logger.Info("Idle compression job disabled")
+		close(j.done)
+		return
+	}
+
+	go func() {
+		defer close(j.done)
+
+		ticker := time.NewTicker(j.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				j.run(ctx)
+			}
+		}
+	}()
+}
+
+// Wait ждёт завершения задачи после отмены контекста Start: прерванное сжатие откатывается,
+// и после возврата задача больше не обращается к хранилищу
+func (j *IdleCompressionJob) Wait() {
+	<-j.done
+}
+
+// Stats возвращает число обработанных сессий, выполненных сжатий и ошибок с момента запуска
+func (j *IdleCompressionJob) Stats() (sessions, compressions, failures int64) {
+	return j.sessions.Load(), j.compressions.Load(), j.failures.Load()
+}
+
+// RunOnce сжимает до config.MaxSessionsPerRun простаивающих сессий, давно неактивные первыми,
+// и возвращает число выполненных сжатий. Ошибка сжатия сессии не прерывает проход.
+func (j *IdleCompressionJob) RunOnce(ctx context.Context) (int, error) {
+	idleBefore := time.Now().Add(-j.config.IdleAfter)
+	ids, err := j.sessionStore.ListSessionsUpdatedBefore(ctx, idleBefore, j.config.MinActiveMessages, j.config.MaxSessionsPerRun)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, sessionID := range ids {
+		if ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+
+		compressed, err := j.compressSession(ctx, sessionID, idleBefore)
+		total += compressed
+		if err != nil {
+			if ctx.Err() != nil {
+				return total, ctx.Err()
+			}
+			j.failures.Add(1)
+			j.logger.Error("Idle session compression failed",
+				zap.String("session_id", sessionID),
+				zap.Int("compressions", compressed),
+				zap.Error(err),
+			)
+		}
+	}
+
+	return total, nil
+}
+
+// compressSession сжимает сессию, пока Compress находит что сжимать.
+// Сессия, обновлённая после поиска, пропускается: её сожмёт следующий запрос по порогам.
+func (j *IdleCompressionJob) compressSession(ctx context.Context, sessionID string, idleBefore time.Time) (int, error) {
+	session, err := j.sessionStore.GetSession(ctx, sessionID)
+	if err != nil {
+		return 0, err
+	}
+	if !session.UpdatedAt.Before(idleBefore) {
+		return 0, nil
+	}
+	j.sessions.Add(1)
+
+	sessionCtx, cancel := context.WithTimeout(ctx, idleCompressionTimeout)
+	defer cancel()
+
+	compressed := 0
+	for pass := 0; pass < idleCompressionMaxPasses; pass++ {
+		if err := j.throttle(sessionCtx); err != nil {
+			return compressed, err
+		}
+
+		info, err := j.contextManager.Compress(sessionCtx, sessionID, contextmgr.CompressOptions{Force: true, ForceLevel: 1})
+		j.lastCompress = time.Now()
+		if err != nil {
+			return compressed, err
+		}
+		if !info.Triggered {
+			return compressed, nil
+		}
+
+		compressed++
+		j.compressions.Add(1)
+		j.logger.Info("Idle session compressed",
+			zap.String("session_id", sessionID),
+			zap.Int("level", info.Level),
+			zap.Int("messages_compressed", info.MessagesCompressed),
+			zap.Int("summaries_compressed", info.SummariesCompressed),
+			zap.Int("tokens_used", info.TokensUsed),
+		)
+	}
+
+	j.logger.Warn("Idle session compression reached the pass limit",
+		zap.String("session_id", sessionID),
+		zap.Int("passes", idleCompressionMaxPasses),
+	)
+	return compressed, nil
+}
+
+// throttle выдерживает паузу config.MinDelay после предыдущего сжатия
+func (j *IdleCompressionJob) throttle(ctx context.Context) error {
+	wait := j.config.MinDelay - time.Since(j.lastCompress)
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (j *IdleCompressionJob) run(ctx context.Context) {
+	start := time.Now()
+
+	compressed, err := j.RunOnce(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			j.logger.Info("Idle compression run stopped", zap.Int("compressions", compressed))
+			return
+		}
+		j.logger.Error("Idle compression run failed", zap.Int("compressions", compressed), zap.Error(err))
+		return
+	}
+	if compressed > 0 {
+		j.logger.Info("Idle compression run finished",
+			zap.Int("compressions", compressed),
+			zap.Duration("duration", time.Since(start)),
+			zap.Duration("idle_after", j.config.IdleAfter),
+		)
+	}
+}
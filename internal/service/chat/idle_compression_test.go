@@ -0,0 +1,96 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"LLM_Chat/internal/config"
+	contextmgr "LLM_Chat/internal/service/context"
+	"LLM_Chat/internal/service/summary"
+	"LLM_Chat/internal/storage/memory"
+	"LLM_Chat/internal/storage/models"
+
+	"go.uber.org/zap"
+)
+
+// seedSummaries сохраняет count активных резюме уровня level
+func seedSummaries(t *testing.T, store *memory.MemoryStorage, sessionID string, level, count int) {
+	t.Helper()
+
+	for i := 0; i < count; i++ {
+		err := store.SaveSummary(context.Background(), models.Summary{
+			ID:           fmt.Sprintf("%s-l%d-%d", sessionID, level, i),
+			SessionID:    sessionID,
+			SummaryText:  fmt.Sprintf("level %d summary %d", level, i),
+			SummaryLevel: level,
+			UpdatedAt:    time.Now(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestIdleCompressionForcesOnlyMessages(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	summaryService := summary.NewService(store, &shrinkLLM{}, nil, nil, summary.DefaultConfig(), zap.NewNop())
+	// 12 сообщений ниже порога уровня 1: сжать их может только Force
+	contextConfig := contextmgr.DefaultConfig()
+	contextConfig.MessageCompressionRatio = 1
+	manager := contextmgr.NewManager(store, summaryService, nil, nil, nil, contextConfig, zap.NewNop())
+
+	// s1: резюме и bulk резюме ниже своих порогов, сжимаются только сообщения
+	if err := store.CreateSession(ctx, "s1"); err != nil {
+		t.Fatal(err)
+	}
+	seedSummaries(t, store, "s1", 2, 3)
+	seedSummaries(t, store, "s1", 1, 5)
+	seedDialog(t, store, "s1", 0, 12)
+
+	// s2: bulk резюме выше порога MaxBulkSummaries сворачиваются в дайджест по порогу
+	if err := store.CreateSession(ctx, "s2"); err != nil {
+		t.Fatal(err)
+	}
+	seedSummaries(t, store, "s2", 2, contextConfig.MaxBulkSummaries+1)
+	seedDialog(t, store, "s2", 0, 12)
+
+	// Отрицательный IdleAfter: только что обновлённая сессия уже считается простаивающей
+	job := NewIdleCompressionJob(store, manager, config.IdleCompressionConfig{
+		IdleAfter:         -time.Minute,
+		MinActiveMessages: 10,
+		MaxSessionsPerRun: 10,
+	}, zap.NewNop())
+
+	compressed, err := job.RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if compressed != 3 {
+		t.Errorf("compressions = %d, want 3", compressed)
+	}
+
+	for _, sessionID := range []string{"s1", "s2"} {
+		active, _ := store.GetActiveMessages(ctx, sessionID)
+		if len(active) != contextConfig.MinMessagesInWindow {
+			t.Errorf("%s: active messages = %d, want %d", sessionID, len(active), contextConfig.MinMessagesInWindow)
+		}
+	}
+
+	digests, _ := store.GetActiveSummaries(ctx, "s1", 3)
+	bulk, _ := store.GetSummariesByLevel(ctx, "s1", 2)
+	if len(digests) != 0 || len(bulk) != 3 {
+		t.Errorf("s1: digests = %d, bulk summaries = %d; want 0 and 3", len(digests), len(bulk))
+	}
+	digests, _ = store.GetActiveSummaries(ctx, "s2", 3)
+	bulk, _ = store.GetSummariesByLevel(ctx, "s2", 2)
+	if len(digests) != 1 || len(bulk) != contextConfig.MaxBulkSummaries/2 {
+		t.Errorf("s2: digests = %d, bulk summaries = %d; want 1 and %d", len(digests), len(bulk), contextConfig.MaxBulkSummaries/2)
+	}
+
+	if sessions, compressions, failures := job.Stats(); sessions != 2 || compressions != 3 || failures != 0 {
+		t.Errorf("stats = %d sessions, %d compressions, %d failures", sessions, compressions, failures)
+	}
+}
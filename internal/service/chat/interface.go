@@ -10,10 +10,17 @@ import (
 type ChatService interface {
 	ProcessMessage(ctx context.Context, req ProcessMessageRequest) (*ProcessMessageResponse, error)
 	ProcessMessageStream(ctx context.Context, req ProcessMessageRequest) (<-chan StreamResponse, error)
-	GetHistory(ctx context.Context, sessionID string, limit int) ([]models.Message, error)
+	ResumeStream(ctx context.Context, sessionID, messageID string, fromSeq uint64) (<-chan StreamResponse, error)
+	GetHistory(ctx context.Context, sessionID string, limit, offset int, includeTools bool) ([]models.Message, int, error)
 	GetContextInfo(ctx context.Context, sessionID string) (*contextmgr.ContextInfo, error)
 	DeleteSession(ctx context.Context, sessionID string) error
-	TriggerCompression(ctx context.Context, sessionID string) (*CompressionResult, error)
+	CloneSession(ctx context.Context, sessionID string) (*models.SessionCloneResult, error)
+	SetSessionLanguage(ctx context.Context, sessionID, setting string) error
+	LanguageSetting(ctx context.Context, sessionID string) string
+	TriggerCompression(ctx context.Context, sessionID string, force bool) (*CompressionResult, error)
+	CreateRangeSummary(ctx context.Context, sessionID, fromMessageID, toMessageID string, level int) (*RangeSummaryResult, error)
+	GetSummarySources(ctx context.Context, sessionID, summaryID string) (*models.SummarySources, error)
+	ExpandSummary(ctx context.Context, sessionID, summaryID string) (*ExpandSummaryResult, error)
 }
 
 // Verify interface implementation
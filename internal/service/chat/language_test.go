@@ -0,0 +1,76 @@
+package chat
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"LLM_Chat/internal/language"
+)
+
+func TestSystemPromptFollowsSessionLanguage(t *testing.T) {
+	ctx := context.Background()
+	client := &recordingLLM{reply: "Hello there"}
+	service, store := newTestService(t, client, testChatConfig())
+
+	if err := store.CreateSession(ctx, "s1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.SetSessionLanguage(ctx, "s1", "en"); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(path string) {
+		t.Helper()
+		prompt := client.lastSystemPrompt()
+		if !strings.HasSuffix(prompt, language.Directive("en")) {
+			t.Errorf("%s: system prompt lacks the English directive:\n%s", path, prompt)
+		}
+		// Базовый промпт не должен противоречить инструкции о языке
+		if strings.Contains(prompt, "русском") {
+			t.Errorf("%s: system prompt mentions another language:\n%s", path, prompt)
+		}
+	}
+
+	// Сообщение на русском не меняет явно выбранный язык сессии
+	if _, err := service.ProcessMessage(ctx, ProcessMessageRequest{SessionID: "s1", Message: "Привет, как дела?"}); err != nil {
+		t.Fatalf("ProcessMessage: %v", err)
+	}
+	check("ProcessMessage")
+
+	stream, err := service.ProcessMessageStream(ctx, ProcessMessageRequest{SessionID: "s1", Message: "Что нового?"})
+	if err != nil {
+		t.Fatalf("ProcessMessageStream: %v", err)
+	}
+	if _, err := collectStream(t, stream); err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	check("ProcessMessageStream")
+}
+
+func TestSystemPromptAutoLanguage(t *testing.T) {
+	ctx := context.Background()
+	client := &recordingLLM{reply: "ok"}
+	service, _ := newTestService(t, client, testChatConfig())
+
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"Сәлеметсіз бе, қалайсыз?", "kk"},
+		{"Как настроить сервер?", "ru"},
+		{"How do I configure the server?", "en"},
+	}
+	for _, tt := range tests {
+		stream, err := service.ProcessMessageStream(ctx, ProcessMessageRequest{SessionID: "auto", Message: tt.message})
+		if err != nil {
+			t.Fatalf("ProcessMessageStream(%q): %v", tt.message, err)
+		}
+		if _, err := collectStream(t, stream); err != nil {
+			t.Fatalf("stream(%q): %v", tt.message, err)
+		}
+		if prompt := client.lastSystemPrompt(); !strings.HasSuffix(prompt, language.Directive(tt.want)) {
+			t.Errorf("message %q: system prompt lacks the %s directive:\n%s", tt.message, tt.want, prompt)
+		}
+	}
+}
@@ -45,8 +45,9 @@ func (m *SimpleMetrics) GetStats() (messages, tokens int64, cost float64, avgTim
 }
 
 func (s *Service) recordMetrics(tokens int, cost float64, responseTime time.Duration) {
-	// TODO: Интегрировать с реальной системой метрик (Prometheus, etc.)
-	s.logger.Info("Message metrics",
+	s.metrics.RecordMessage(tokens, cost, responseTime)
+
+	s.logger.Debug("Message metrics",
 		zap.Int("tokens", tokens),
 		zap.Float64("cost", cost),
 		zap.Duration("response_time", responseTime),
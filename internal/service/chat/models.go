@@ -0,0 +1,105 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"LLM_Chat/internal/storage/interfaces"
+
+	"go.uber.org/zap"
+)
+
+// ErrUnsupportedModel модель не поддерживается текущим провайдером
+var ErrUnsupportedModel = errors.New("model is not supported by the provider")
+
+// Источники выбранной модели
+const (
+	ModelSourceRequest    = "request"
+	ModelSourcePreference = "user_preference"
+	ModelSourceDefault    = "server_default"
+)
+
+// ModelResolver выбирает модель для запроса:
+// явный выбор в запросе -> настройка пользователя -> модель сервера
+type ModelResolver struct {
+	preferences  interfaces.PreferencesStore
+	defaultModel string
+	supported    []string
+	logger       *zap.Logger
+}
+
+func NewModelResolver(
+	preferences interfaces.PreferencesStore,
+	defaultModel string,
+	supportedModels []string,
+	logger *zap.Logger,
+) *ModelResolver {
+	return &ModelResolver{
+		preferences:  preferences,
+		defaultModel: defaultModel,
+		supported:    supportedModels,
+		logger:       logger,
+	}
+}
+
+// DefaultModel возвращает модель сервера по умолчанию
+func (r *ModelResolver) DefaultModel() string {
+	return r.defaultModel
+}
+
+// SupportedModels возвращает модели, которые можно выбрать для запроса
+func (r *ModelResolver) SupportedModels() []string {
+	return r.supported
+}
+
+// ValidateModel проверяет, что модель поддерживается провайдером.
+// Модель сервера по умолчанию допустима всегда.
+func (r *ModelResolver) ValidateModel(model string) error {
+	if model == r.defaultModel {
+		return nil
+	}
+	for _, supported := range r.supported {
+		if model == supported {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s (supported: %s)", ErrUnsupportedModel, model, strings.Join(r.supported, ", "))
+}
+
+// Resolve определяет модель для запроса и источник выбора.
+// Неподдерживаемая модель в запросе - ошибка валидации поля model;
+// устаревшая модель в настройках пользователя игнорируется с предупреждением.
+func (r *ModelResolver) Resolve(ctx context.Context, userID, requested string) (string, string, error) {
+	if requested != "" {
+		if err := r.ValidateModel(requested); err != nil {
+			return "", "", ValidationErrors{{
+				Field:   "model",
+				Code:    ValidationCodeInvalid,
+				Message: err.Error(),
+				Err:     ErrUnsupportedModel,
+			}}
+		}
+		return requested, ModelSourceRequest, nil
+	}
+
+	if userID != "" && r.preferences != nil {
+		prefs, err := r.preferences.GetUserPreferences(ctx, userID)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to load user preferences: %w", err)
+		}
+
+		if prefs != nil && prefs.Prefs.DefaultModel != "" {
+			if err := r.ValidateModel(prefs.Prefs.DefaultModel); err == nil {
+				return prefs.Prefs.DefaultModel, ModelSourcePreference, nil
+			}
+			r.logger.Warn("Preferred model is no longer supported, falling back to server default",
+				zap.String("user_id", userID),
+				zap.String("model", prefs.Prefs.DefaultModel),
+			)
+		}
+	}
+
+	return r.defaultModel, ModelSourceDefault, nil
+}
@@ -0,0 +1,92 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"LLM_Chat/pkg/llm"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrPromptArgsWithoutName prompt_args передан без prompt_name
+	ErrPromptArgsWithoutName = errors.New("prompt_args requires prompt_name")
+
+	// ErrPromptUnavailable промпт не удалось получить: сервер недоступен или вернул ошибку
+	ErrPromptUnavailable = errors.New("MCP prompt is unavailable")
+)
+
+// loadPrompt получает выбранный в запросе промпт MCP сервера; без prompt_name возвращает пустую строку.
+// Неизвестный промпт - ошибка поля prompt_name, недостающие обязательные аргументы - ошибки полей
+// prompt_args.<имя> (errors.As находит llm.PromptArgumentsError со схемой аргументов).
+func (s *Service) loadPrompt(ctx context.Context, req ProcessMessageRequest) (string, error) {
+	if req.PromptName == "" {
+		return "", nil
+	}
+
+	provider, ok := s.llmClient.(llm.PromptProvider)
+	if !ok {
+		return "", promptNotSupported(fmt.Sprintf("provider '%s'", s.llmClient.GetProviderName()))
+	}
+
+	prompt, err := provider.GetPrompt(ctx, req.PromptName, req.PromptArgs)
+	var argsErr *llm.PromptArgumentsError
+	switch {
+	case err == nil:
+	case errors.As(err, &argsErr):
+		return "", promptArgumentErrors(argsErr)
+	case errors.Is(err, llm.ErrPromptNotFound):
+		return "", ValidationErrors{{
+			Field:   "prompt_name",
+			Code:    ValidationCodeInvalid,
+			Message: err.Error(),
+			Err:     llm.ErrPromptNotFound,
+		}}
+	case errors.Is(err, llm.ErrPromptsNotSupported):
+		return "", promptNotSupported(err.Error())
+	case errors.Is(err, llm.ErrProviderReinitializing):
+		return "", err
+	default:
+		return "", fmt.Errorf("%w: %w", ErrPromptUnavailable, err)
+	}
+
+	s.logger.Debug("MCP prompt selected",
+		zap.String("session_id", req.SessionID),
+		zap.String("prompt", req.PromptName),
+	)
+	return prompt, nil
+}
+
+func promptNotSupported(details string) ValidationErrors {
+	return ValidationErrors{{
+		Field:   "prompt_name",
+		Code:    ValidationCodeUnsupported,
+		Message: fmt.Sprintf("%s: %s", llm.ErrPromptsNotSupported, details),
+		Err:     llm.ErrPromptsNotSupported,
+	}}
+}
+
+// promptArgumentErrors ошибка поля для каждого недостающего аргумента
+func promptArgumentErrors(argsErr *llm.PromptArgumentsError) ValidationErrors {
+	descriptions := make(map[string]string, len(argsErr.Arguments))
+	for _, arg := range argsErr.Arguments {
+		descriptions[arg.Name] = arg.Description
+	}
+
+	errs := make(ValidationErrors, 0, len(argsErr.Missing))
+	for _, name := range argsErr.Missing {
+		message := fmt.Sprintf("argument %s of prompt %s is required", name, argsErr.Prompt)
+		if descriptions[name] != "" {
+			message += ": " + descriptions[name]
+		}
+		errs = append(errs, &ValidationError{
+			Field:   "prompt_args." + name,
+			Code:    ValidationCodeRequired,
+			Message: message,
+			Err:     argsErr,
+		})
+	}
+	return errs
+}
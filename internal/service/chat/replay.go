@@ -0,0 +1,230 @@
+package chat
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"LLM_Chat/internal/config"
+)
+
+var (
+	// ErrStreamNotFound поток не найден в буфере: неизвестен, принадлежит другой сессии или уже удалён по TTL
+	ErrStreamNotFound = errors.New("stream not found")
+	// ErrStreamEventsExpired запрошенные события уже вытеснены из кольцевого буфера
+	ErrStreamEventsExpired = errors.New("requested stream events are no longer buffered")
+)
+
+// ReplayBuffer хранит события потоковых ответов для повторной отправки после переподключения клиента.
+// Для каждого сообщения ассистента ведётся кольцевой буфер из BufferSize последних событий;
+// завершённые потоки удаляются через TTL, общее число потоков ограничено MaxStreams.
+type ReplayBuffer struct {
+	mu      sync.Mutex
+	streams map[string]*replayStream
+	config  config.StreamReplayConfig
+	now     func() time.Time
+}
+
+// replayStream события одного потока.
+// notify закрывается и пересоздаётся при каждом изменении, чтобы разбудить читателей.
+type replayStream struct {
+	mu         sync.Mutex
+	sessionID  string
+	events     []StreamResponse // кольцо, событие с номером seq лежит в events[(seq-1)%len(events)]
+	lastSeq    uint64
+	finished   bool
+	createdAt  time.Time
+	finishedAt time.Time
+	notify     chan struct{}
+}
+
+func NewReplayBuffer(cfg config.StreamReplayConfig) *ReplayBuffer {
+	return &ReplayBuffer{
+		streams: make(map[string]*replayStream),
+		config:  cfg,
+		now:     time.Now,
+	}
+}
+
+// Enabled сообщает, включена ли буферизация потоков
+func (b *ReplayBuffer) Enabled() bool {
+	return b != nil && b.config.Enabled
+}
+
+// Open создаёт буфер для нового потока, освобождая место под лимит MaxStreams
+func (b *ReplayBuffer) Open(sessionID, messageID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.expireLocked(now)
+
+	for len(b.streams) >= b.config.MaxStreams {
+		b.evictOldestLocked()
+	}
+
+	b.streams[messageID] = &replayStream{
+		sessionID: sessionID,
+		events:    make([]StreamResponse, b.config.BufferSize),
+		createdAt: now,
+		notify:    make(chan struct{}),
+	}
+}
+
+// Append присваивает событию очередной номер и сохраняет его.
+// Событие с Done или Error завершает поток.
+func (b *ReplayBuffer) Append(messageID string, resp StreamResponse) StreamResponse {
+	stream := b.get(messageID)
+	if stream == nil {
+		// Поток вытеснен из буфера - событие отдаётся без номера
+		return resp
+	}
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	if stream.finished {
+		return resp
+	}
+
+	stream.lastSeq++
+	resp.Seq = stream.lastSeq
+	stream.events[(resp.Seq-1)%uint64(len(stream.events))] = resp
+
+	if resp.Done || resp.Error != nil {
+		stream.finished = true
+		stream.finishedAt = b.now()
+	}
+
+	close(stream.notify)
+	stream.notify = make(chan struct{})
+
+	return resp
+}
+
+// Finish помечает поток завершённым, если генерация закончилась без финального события
+func (b *ReplayBuffer) Finish(messageID string) {
+	stream := b.get(messageID)
+	if stream == nil {
+		return
+	}
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	if stream.finished {
+		return
+	}
+
+	stream.finished = true
+	stream.finishedAt = b.now()
+	close(stream.notify)
+	stream.notify = make(chan struct{})
+}
+
+// Since возвращает события потока с номерами от fromSeq включительно,
+// признак завершения потока и канал, закрываемый при появлении новых событий.
+func (b *ReplayBuffer) Since(sessionID, messageID string, fromSeq uint64) ([]StreamResponse, bool, <-chan struct{}, error) {
+	stream := b.get(messageID)
+	if stream == nil || stream.sessionID != sessionID {
+		return nil, false, nil, ErrStreamNotFound
+	}
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	if fromSeq == 0 {
+		fromSeq = 1
+	}
+
+	capacity := uint64(len(stream.events))
+	var oldest uint64 = 1
+	if stream.lastSeq > capacity {
+		oldest = stream.lastSeq - capacity + 1
+	}
+	if fromSeq < oldest {
+		return nil, false, nil, ErrStreamEventsExpired
+	}
+
+	var events []StreamResponse
+	for seq := fromSeq; seq <= stream.lastSeq; seq++ {
+		events = append(events, stream.events[(seq-1)%capacity])
+	}
+
+	return events, stream.finished, stream.notify, nil
+}
+
+// Active возвращает количество потоков в буфере
+func (b *ReplayBuffer) Active() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.expireLocked(b.now())
+	return len(b.streams)
+}
+
+// get возвращает поток, если он есть в буфере и не истёк.
+// Полная очистка истёкших потоков выполняется в Open и Active.
+func (b *ReplayBuffer) get(messageID string) *replayStream {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stream, ok := b.streams[messageID]
+	if !ok {
+		return nil
+	}
+
+	if b.expired(stream, b.now()) {
+		delete(b.streams, messageID)
+		return nil
+	}
+
+	return stream
+}
+
+// expireLocked удаляет завершённые потоки старше TTL
+func (b *ReplayBuffer) expireLocked(now time.Time) {
+	for id, stream := range b.streams {
+		if b.expired(stream, now) {
+			delete(b.streams, id)
+		}
+	}
+}
+
+func (b *ReplayBuffer) expired(stream *replayStream, now time.Time) bool {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	return stream.finished && now.Sub(stream.finishedAt) > b.config.TTL
+}
+
+// evictOldestLocked вытесняет самый старый поток, в первую очередь среди завершённых
+func (b *ReplayBuffer) evictOldestLocked() {
+	var (
+		victim         string
+		victimTime     time.Time
+		victimFinished bool
+	)
+
+	for id, stream := range b.streams {
+		stream.mu.Lock()
+		finished, createdAt := stream.finished, stream.createdAt
+		stream.mu.Unlock()
+
+		better := victim == "" ||
+			(finished && !victimFinished) ||
+			(finished == victimFinished && createdAt.Before(victimTime))
+		if better {
+			victim, victimTime, victimFinished = id, createdAt, finished
+		}
+	}
+
+	// Будим читателей вытесненного потока: при следующем запросе они получат ErrStreamNotFound
+	stream := b.streams[victim]
+	stream.mu.Lock()
+	close(stream.notify)
+	stream.notify = make(chan struct{})
+	stream.mu.Unlock()
+
+	delete(b.streams, victim)
+}
@@ -0,0 +1,167 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"LLM_Chat/internal/config"
+	"LLM_Chat/pkg/llm"
+)
+
+func testReplayConfig() config.StreamReplayConfig {
+	return config.StreamReplayConfig{Enabled: true, BufferSize: 4, TTL: time.Minute, MaxStreams: 2}
+}
+
+func TestReplayBufferSince(t *testing.T) {
+	buffer := NewReplayBuffer(testReplayConfig())
+	buffer.Open("s1", "m1")
+	for _, content := range []string{"a", "b", "c"} {
+		buffer.Append("m1", StreamResponse{Content: content})
+	}
+
+	events, finished, _, err := buffer.Since("s1", "m1", 2)
+	if err != nil || finished || len(events) != 2 || events[0].Content != "b" || events[0].Seq != 2 {
+		t.Fatalf("Since(2) = %+v, %v, %v", events, finished, err)
+	}
+	if _, _, _, err := buffer.Since("other", "m1", 1); !errors.Is(err, ErrStreamNotFound) {
+		t.Errorf("other session: err = %v, want ErrStreamNotFound", err)
+	}
+
+	// Кольцо на 4 события: после шестого первые два вытеснены
+	buffer.Append("m1", StreamResponse{Content: "d"})
+	buffer.Append("m1", StreamResponse{Content: "e"})
+	buffer.Append("m1", StreamResponse{Done: true})
+	if _, _, _, err := buffer.Since("s1", "m1", 2); !errors.Is(err, ErrStreamEventsExpired) {
+		t.Errorf("evicted events: err = %v, want ErrStreamEventsExpired", err)
+	}
+	events, finished, _, err = buffer.Since("s1", "m1", 3)
+	if err != nil || !finished || len(events) != 4 || !events[3].Done {
+		t.Errorf("Since(3) after done = %+v, %v, %v", events, finished, err)
+	}
+
+	// После финального события поток больше не пополняется
+	if resp := buffer.Append("m1", StreamResponse{Content: "late"}); resp.Seq != 0 {
+		t.Errorf("event after done got seq %d", resp.Seq)
+	}
+}
+
+func TestReplayBufferLimits(t *testing.T) {
+	now := time.Now()
+	buffer := NewReplayBuffer(testReplayConfig())
+	buffer.now = func() time.Time { return now }
+
+	buffer.Open("s1", "m1")
+	buffer.Append("m1", StreamResponse{Done: true})
+	buffer.Open("s1", "m2")
+
+	// Завершённый поток удаляется по TTL
+	now = now.Add(2 * time.Minute)
+	if _, _, _, err := buffer.Since("s1", "m1", 1); !errors.Is(err, ErrStreamNotFound) {
+		t.Errorf("expired stream: err = %v, want ErrStreamNotFound", err)
+	}
+
+	// Сверх MaxStreams вытесняется самый старый поток
+	buffer.Open("s1", "m3")
+	buffer.Open("s1", "m4")
+	if active := buffer.Active(); active != 2 {
+		t.Errorf("active streams = %d, want 2", active)
+	}
+	if _, _, _, err := buffer.Since("s1", "m2", 1); !errors.Is(err, ErrStreamNotFound) {
+		t.Errorf("evicted stream: err = %v, want ErrStreamNotFound", err)
+	}
+}
+
+// gatedLLM потоковая модель, которая отдаёт первую часть ответа и ждёт release перед остальными
+type gatedLLM struct {
+	recordingLLM
+	first, rest []string
+	release     chan struct{}
+}
+
+func (c *gatedLLM) ChatCompletionStream(ctx context.Context, messages []llm.Message) (<-chan llm.StreamChunk, error) {
+	c.record(messages)
+	ch := make(chan llm.StreamChunk)
+	go func() {
+		defer close(ch)
+		for _, content := range c.first {
+			ch <- llm.StreamChunk{Content: content}
+		}
+		<-c.release
+		for _, content := range c.rest {
+			ch <- llm.StreamChunk{Content: content}
+		}
+		ch <- llm.StreamChunk{Done: true}
+	}()
+	return ch, nil
+}
+
+func TestResumeStreamAfterDisconnect(t *testing.T) {
+	client := &gatedLLM{first: []string{"Hello, "}, rest: []string{"how ", "are ", "you?"}, release: make(chan struct{})}
+	cfg := testChatConfig()
+	cfg.StreamReplay = config.StreamReplayConfig{Enabled: true, BufferSize: 100, TTL: time.Minute, MaxStreams: 10}
+	service, _ := newTestService(t, client, cfg)
+
+	clientCtx, disconnect := context.WithCancel(context.Background())
+	stream, err := service.ProcessMessageStream(clientCtx, ProcessMessageRequest{SessionID: "s1", Message: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Читаем до первой части ответа и обрываем соединение
+	var messageID string
+	var lastSeq uint64
+	var received strings.Builder
+	for resp := range stream {
+		if resp.MessageID != "" {
+			messageID = resp.MessageID
+		}
+		lastSeq = resp.Seq
+		received.WriteString(resp.Content)
+		if resp.Content != "" {
+			break
+		}
+	}
+	disconnect()
+	close(client.release)
+
+	// Генерация продолжается без клиента; переподключение получает пропущенное и финал
+	resumed, err := service.ResumeStream(context.Background(), "s1", messageID, lastSeq+1)
+	if err != nil {
+		t.Fatalf("ResumeStream: %v", err)
+	}
+	done := false
+	for resp := range resumed {
+		if resp.Error != nil {
+			t.Fatalf("resumed stream error: %v", resp.Error)
+		}
+		if resp.Seq != lastSeq+1 {
+			t.Errorf("seq = %d after %d: events were lost or repeated", resp.Seq, lastSeq)
+		}
+		lastSeq = resp.Seq
+		received.WriteString(resp.Content)
+		done = done || resp.Done
+	}
+
+	if !done {
+		t.Error("resumed stream did not end with done")
+	}
+	if got := received.String(); got != "Hello, how are you?" {
+		t.Errorf("received %q, want the full answer", got)
+	}
+
+	// Завершённый поток можно перечитать с начала
+	replayed, err := service.ResumeStream(context.Background(), "s1", messageID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var full strings.Builder
+	for resp := range replayed {
+		full.WriteString(resp.Content)
+	}
+	if full.String() != "Hello, how are you?" {
+		t.Errorf("replay from start = %q", full.String())
+	}
+}
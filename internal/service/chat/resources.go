@@ -0,0 +1,206 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"LLM_Chat/pkg/llm"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrResourceTooLarge текст ресурса больше chat.resources.max_bytes
+	ErrResourceTooLarge = errors.New("MCP resource is too large")
+
+	// ErrResourceUnavailable ресурс не удалось прочитать: сервер недоступен или вернул ошибку
+	ErrResourceUnavailable = errors.New("MCP resource is unavailable")
+)
+
+const resourceHeader = "Document attached by the user from an MCP resource. Use it as reference material for the current question:"
+
+// resourceCache прочитанные ресурсы по сессиям: повторные ходы диалога с теми же resource_uris
+// не запрашивают сервер, пока не истёк TTL
+type resourceCache struct {
+	ttl time.Duration // 0 - кэш отключён
+
+	mu       sync.Mutex
+	sessions map[string]map[string]cachedResource
+}
+
+type cachedResource struct {
+	content   *llm.ResourceContent
+	expiresAt time.Time
+}
+
+func newResourceCache(ttl time.Duration) *resourceCache {
+	return &resourceCache{ttl: ttl, sessions: make(map[string]map[string]cachedResource)}
+}
+
+func (c *resourceCache) get(sessionID, uri string) (*llm.ResourceContent, bool) {
+	if c.ttl <= 0 {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.sessions[sessionID][uri]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.content, true
+}
+
+// put сохраняет ресурс и удаляет устаревшие записи всех сессий
+func (c *resourceCache) put(sessionID, uri string, content *llm.ResourceContent) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for id, entries := range c.sessions {
+		for key, entry := range entries {
+			if now.After(entry.expiresAt) {
+				delete(entries, key)
+			}
+		}
+		if len(entries) == 0 {
+			delete(c.sessions, id)
+		}
+	}
+
+	entries, ok := c.sessions[sessionID]
+	if !ok {
+		entries = make(map[string]cachedResource)
+		c.sessions[sessionID] = entries
+	}
+	entries[uri] = cachedResource{content: content, expiresAt: now.Add(c.ttl)}
+}
+
+// forget удаляет ресурсы сессии
+func (c *resourceCache) forget(sessionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.sessions, sessionID)
+}
+
+// loadResources читает ресурсы запроса (из кэша сессии или с MCP сервера) и возвращает их
+// системными сообщениями для контекста. Неизвестный, двоичный или слишком большой ресурс -
+// ошибка валидации поля resource_uris; сбой чтения - ErrResourceUnavailable.
+func (s *Service) loadResources(ctx context.Context, req ProcessMessageRequest) ([]llm.Message, error) {
+	if len(req.ResourceURIs) == 0 {
+		return nil, nil
+	}
+	if err := validateResourceURIs(req.ResourceURIs); err != nil {
+		return nil, ValidationErrors{err}
+	}
+
+	reader, ok := s.llmClient.(llm.ResourceReader)
+	if !ok {
+		return nil, ValidationErrors{{
+			Field:   "resource_uris",
+			Code:    ValidationCodeUnsupported,
+			Message: fmt.Sprintf("%s: provider '%s'", llm.ErrResourcesNotSupported, s.llmClient.GetProviderName()),
+			Err:     llm.ErrResourcesNotSupported,
+		}}
+	}
+
+	var errs ValidationErrors
+	messages := make([]llm.Message, 0, len(req.ResourceURIs))
+	for _, uri := range req.ResourceURIs {
+		content, cached := s.resources.get(req.SessionID, uri)
+		if !cached {
+			var err error
+			content, err = reader.ReadResource(ctx, uri)
+			if err != nil {
+				if fieldErr := resourceFieldError(err); fieldErr != nil {
+					errs = append(errs, fieldErr)
+					continue
+				}
+				if errors.Is(err, llm.ErrProviderReinitializing) {
+					return nil, err
+				}
+				return nil, fmt.Errorf("%w: %w", ErrResourceUnavailable, err)
+			}
+		}
+
+		if len(content.Text) > s.config.Resources.MaxBytes {
+			errs = append(errs, &ValidationError{
+				Field: "resource_uris",
+				Code:  ValidationCodeTooLong,
+				Message: fmt.Sprintf("%s: %s is %d bytes, limit is %d",
+					ErrResourceTooLarge, uri, len(content.Text), s.config.Resources.MaxBytes),
+				Err: ErrResourceTooLarge,
+			})
+			continue
+		}
+
+		if !cached {
+			s.resources.put(req.SessionID, uri, content)
+		}
+		messages = append(messages, resourceMessage(content))
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	s.logger.Debug("MCP resources attached",
+		zap.String("session_id", req.SessionID),
+		zap.Strings("uris", req.ResourceURIs),
+	)
+	return messages, nil
+}
+
+// resourceFieldError переводит ошибку чтения, вызванную самим URI, в ошибку поля resource_uris
+func resourceFieldError(err error) *ValidationError {
+	var code string
+	var sentinel error
+	switch {
+	case errors.Is(err, llm.ErrResourceNotFound):
+		code, sentinel = ValidationCodeInvalid, llm.ErrResourceNotFound
+	case errors.Is(err, llm.ErrResourceNotText):
+		code, sentinel = ValidationCodeInvalid, llm.ErrResourceNotText
+	case errors.Is(err, llm.ErrResourcesNotSupported):
+		code, sentinel = ValidationCodeUnsupported, llm.ErrResourcesNotSupported
+	default:
+		return nil
+	}
+	return &ValidationError{Field: "resource_uris", Code: code, Message: err.Error(), Err: sentinel}
+}
+
+// resourceMessage системное сообщение с текстом ресурса
+func resourceMessage(content *llm.ResourceContent) llm.Message {
+	title := content.URI
+	if content.Name != "" && content.Name != content.URI {
+		title = fmt.Sprintf("%s (%s)", content.Name, content.URI)
+	}
+	return llm.Message{
+		Role:    "system",
+		Content: fmt.Sprintf("%s %s\n\n%s", resourceHeader, title, content.Text),
+	}
+}
+
+// withResources добавляет ресурсы после системных сообщений в начале контекста
+func withResources(messages, resources []llm.Message) []llm.Message {
+	if len(resources) == 0 {
+		return messages
+	}
+
+	insertAt := 0
+	for insertAt < len(messages) && messages[insertAt].Role == "system" {
+		insertAt++
+	}
+
+	result := make([]llm.Message, 0, len(messages)+len(resources))
+	result = append(result, messages[:insertAt]...)
+	result = append(result, resources...)
+	return append(result, messages[insertAt:]...)
+}
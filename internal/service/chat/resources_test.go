@@ -0,0 +1,78 @@
+package chat
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"LLM_Chat/internal/config"
+	"LLM_Chat/pkg/llm"
+)
+
+// resourceLLM модель с одним ресурсом MCP сервера; считает чтения
+type resourceLLM struct {
+	recordingLLM
+	reads atomic.Int32
+}
+
+func (c *resourceLLM) ListResources(ctx context.Context, refresh bool) ([]llm.ResourceInfo, error) {
+	return []llm.ResourceInfo{{URI: "docs://guide", Name: "guide"}}, nil
+}
+
+func (c *resourceLLM) ReadResource(ctx context.Context, uri string) (*llm.ResourceContent, error) {
+	if uri != "docs://guide" {
+		return nil, llm.ErrResourceNotFound
+	}
+	c.reads.Add(1)
+	return &llm.ResourceContent{URI: uri, Name: "guide", Text: "Руководство"}, nil
+}
+
+func TestResourceCacheTTL(t *testing.T) {
+	ctx := context.Background()
+	client := &resourceLLM{}
+	cfg := testChatConfig()
+	cfg.Resources = config.ResourcesConfig{MaxBytes: 1000, CacheTTL: time.Minute}
+	service, _ := newTestService(t, client, cfg)
+
+	load := func(sessionID string) {
+		t.Helper()
+		messages, err := service.loadResources(ctx, ProcessMessageRequest{SessionID: sessionID, ResourceURIs: []string{"docs://guide"}})
+		if err != nil {
+			t.Fatalf("loadResources: %v", err)
+		}
+		if len(messages) != 1 || messages[0].Role != "system" {
+			t.Fatalf("resource messages = %+v", messages)
+		}
+	}
+
+	// Повторный ход сессии берёт ресурс из кэша, другая сессия читает его сама
+	load("s1")
+	load("s1")
+	if reads := client.reads.Load(); reads != 1 {
+		t.Errorf("reads after two turns = %d, want 1", reads)
+	}
+	load("s2")
+	if reads := client.reads.Load(); reads != 2 {
+		t.Errorf("reads after another session = %d, want 2", reads)
+	}
+
+	// После TTL ресурс читается заново
+	service.resources.mu.Lock()
+	entry := service.resources.sessions["s1"]["docs://guide"]
+	entry.expiresAt = time.Now().Add(-time.Second)
+	service.resources.sessions["s1"]["docs://guide"] = entry
+	service.resources.mu.Unlock()
+
+	load("s1")
+	if reads := client.reads.Load(); reads != 3 {
+		t.Errorf("reads after TTL = %d, want 3", reads)
+	}
+
+	// Удалённая сессия теряет кэш
+	service.resources.forget("s2")
+	load("s2")
+	if reads := client.reads.Load(); reads != 4 {
+		t.Errorf("reads after forget = %d, want 4", reads)
+	}
+}
@@ -2,15 +2,19 @@ package chat
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"LLM_Chat/internal/config"
+	"LLM_Chat/internal/events"
+	"LLM_Chat/internal/language"
 	contextmgr "LLM_Chat/internal/service/context"
 	"LLM_Chat/internal/storage/interfaces"
 	"LLM_Chat/internal/storage/models"
 	"LLM_Chat/pkg/llm"
+	"LLM_Chat/pkg/redact"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
@@ -21,7 +25,15 @@ type Service struct {
 	sessionStore   interfaces.SessionStore
 	contextManager contextmgr.ContextManager
 	llmClient      llm.LLMClient
+	models         *ModelResolver
+	language       *language.Policy
+	events         events.Publisher
+	redactor       *redact.Redactor // nil - сообщения сохраняются без маскирования
 	config         *config.ChatConfig
+	metrics        *SimpleMetrics
+	streams        *StreamRegistry
+	replay         *ReplayBuffer
+	resources      *resourceCache
 	logger         *zap.Logger
 }
 
@@ -30,6 +42,10 @@ func NewService(
 	sessionStore interfaces.SessionStore,
 	contextManager contextmgr.ContextManager,
 	llmClient llm.LLMClient,
+	modelResolver *ModelResolver,
+	languagePolicy *language.Policy,
+	eventPublisher events.Publisher,
+	redactor *redact.Redactor,
 	config *config.ChatConfig,
 	logger *zap.Logger,
 ) *Service {
@@ -38,15 +54,65 @@ func NewService(
 		sessionStore:   sessionStore,
 		contextManager: contextManager,
 		llmClient:      llmClient,
+		models:         modelResolver,
+		language:       languagePolicy,
+		events:         eventPublisher,
+		redactor:       redactor,
 		config:         config,
+		metrics:        NewSimpleMetrics(),
+		streams:        NewStreamRegistry(),
+		replay:         NewReplayBuffer(config.StreamReplay),
+		resources:      newResourceCache(config.Resources.CacheTTL),
 		logger:         logger,
 	}
 }
 
+// Metrics возвращает накопленные метрики сервиса
+func (s *Service) Metrics() *SimpleMetrics {
+	return s.metrics
+}
+
+// Streams возвращает реестр активных потоковых генераций
+func (s *Service) Streams() *StreamRegistry {
+	return s.streams
+}
+
 type ProcessMessageRequest struct {
 	SessionID string
 	Message   string
 	UserID    string
+	Model     string // явный выбор модели; пустое значение - настройка пользователя или модель сервера
+
+	// Seed и Deterministic запрашивают воспроизводимую генерацию
+	Seed          *int64
+	Deterministic bool
+
+	// MaxIterations лимит итераций цикла вызова инструментов; 0 - mcp.max_iterations
+	MaxIterations int
+
+	// MaxOutputTokens лимит длины ответа в токенах; 0 - значение провайдера по умолчанию
+	MaxOutputTokens int
+
+	// Stop стоп-последовательности; в ответ не входят
+	Stop []string
+
+	// GoogleSearch включает или выключает поиск Google для запроса; nil - grounding.google_search
+	GoogleSearch *bool
+
+	// ToolMode режим вызова инструментов MCP (auto, any, none); пусто - llm.tool_mode
+	ToolMode string
+
+	// AssistantPrefix начало ответа ассистента (prefill); модель продолжает его,
+	// сохраняется и возвращается префикс вместе с продолжением
+	AssistantPrefix string
+
+	// ResourceURIs ресурсы MCP, текст которых добавляется в контекст запроса системными сообщениями;
+	// в историю сессии не сохраняются
+	ResourceURIs []string
+
+	// PromptName промпт MCP сервера вместо системного промпта по умолчанию; PromptArgs - его аргументы
+	PromptName string
+	PromptArgs map[string]string
 }
 
 type ProcessMessageResponse struct {
@@ -57,6 +123,11 @@ type ProcessMessageResponse struct {
 	Model          string
 	ProcessingTime time.Duration
 	ContextInfo    *ContextMetadata
+	Generation     *models.GenerationParams
+	Grounding      *models.Grounding
+	FinishReason   string // "max_tokens" или "stop_sequence", если генерация остановлена досрочно
+	Iterations     int    // использованные итерации цикла вызова инструментов
+	ToolCalls      []ToolCallSummary
 }
 
 type ContextMetadata struct {
@@ -65,14 +136,42 @@ type ContextMetadata struct {
 	HasSummary           bool `json:"has_summary"`
 	CompressionTriggered bool `json:"compression_triggered"`
 	MessagesCompressed   int  `json:"messages_compressed,omitempty"`
+	AnchorsCreated       int  `json:"anchors_created,omitempty"`
+
+	// CompressionFailed сжатие при построении контекста не удалось, контекст собран без него
+	CompressionFailed bool `json:"compression_failed,omitempty"`
+
+	// ActiveAnchors якоря активных резюме, на которые опирается контекст
+	ActiveAnchors []string `json:"active_anchors,omitempty"`
+
+	// RecalledMessages сжатые сообщения, возвращённые в контекст поиском по эмбеддингам
+	RecalledMessages int `json:"recalled_messages,omitempty"`
+
+	// AttachedResources ресурсы MCP из resource_uris, добавленные в контекст
+	AttachedResources int `json:"attached_resources,omitempty"`
 }
 
 type StreamResponse struct {
-	Content     string
-	Done        bool
-	Error       error
-	MessageID   string
-	ContextInfo *ContextMetadata `json:"context_info,omitempty"`
+	Content      string
+	Done         bool
+	Error        error
+	MessageID    string
+	FinishReason string
+	Iterations   int                   // использованные итерации цикла инструментов; передаётся с Done
+	Seq          uint64                // номер события в буфере повторной отправки (SSE id); 0 - буфер отключён
+	Model        string                // модель генерации, передаётся вместе с ContextInfo
+	ContextInfo  *ContextMetadata      `json:"context_info,omitempty"`
+	ToolCall     *llm.ToolCallEvent    `json:"tool_call,omitempty"`
+	ToolProgress *llm.ToolProgressInfo `json:"tool_progress,omitempty"`
+
+	// Generation применённые параметры генерации; передаётся в финальном событии
+	Generation *models.GenerationParams `json:"generation,omitempty"`
+
+	// ToolCalls сводка вызовов инструментов ответа; передаётся в финальном событии
+	ToolCalls []ToolCallSummary `json:"tool_calls,omitempty"`
+
+	// Grounding источники поиска Google; передаётся в финальном событии
+	Grounding *models.Grounding `json:"grounding,omitempty"`
 }
 
 // ProcessMessage обрабатывает сообщение пользователя с управлением контекстом
@@ -89,25 +188,55 @@ func (s *Service) ProcessMessage(ctx context.Context, req ProcessMessageRequest)
 	if err := ValidateProcessMessageRequest(req); err != nil {
 		return nil, err
 	}
+	if err := validateMaxIterations(req, s.config.MaxIterationsLimit); err != nil {
+		return nil, err
+	}
+	if err := validateAssistantPrefix(req, s.llmClient); err != nil {
+		return nil, err
+	}
+
+	// Модель определяется один раз в начале запроса -
+	// изменение настроек пользователя не влияет на уже выполняющиеся запросы
+	model, err := s.resolveModel(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateMaxOutputTokens(req, model, s.config.MaxOutputTokensLimit, s.llmClient); err != nil {
+		return nil, err
+	}
+
+	// Ресурсы и промпт читаются до сохранения сообщения: недоступный ресурс не оставляет в истории неотвеченный вопрос
+	resources, err := s.loadResources(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	prompt, err := s.loadPrompt(ctx, req)
+	if err != nil {
+		return nil, err
+	}
 
 	// 2. Создаём сессию если её нет
-	if err := s.ensureSession(ctx, req.SessionID); err != nil {
+	if err := s.ensureSession(ctx, req.SessionID, req.UserID); err != nil {
 		return nil, fmt.Errorf("failed to ensure session: %w", err)
 	}
 
-	// 3. Сохраняем сообщение пользователя
-	userMessage := models.NewUserMessage(req.SessionID, req.Message)
-	userMessage.ID = uuid.New().String()
+	// 3. Сохраняем сообщение пользователя; оно ожидает ответа до сохранения ответа ассистента
+	userMessage := s.newUserMessage(req)
 
 	if err := s.messageStore.SaveMessage(ctx, userMessage); err != nil {
 		return nil, fmt.Errorf("failed to save user message: %w", err)
 	}
+	s.contextManager.IndexMessages(userMessage)
+
+	userStatus := models.MessageStatusFailed
+	defer func() { s.setMessageStatus(userMessage.ID, userStatus) }()
 
 	// 4. Строим контекст с помощью Context Manager
 	contextReq := contextmgr.ContextRequest{
 		SessionID:     req.SessionID,
-		SystemPrompt:  s.getSystemPrompt(),
+		SystemPrompt:  s.systemPromptFor(ctx, req, prompt),
 		IncludeSystem: true,
+		Query:         userMessage.Content, // с маскированными данными, как и сохранённые эмбеддинги
 	}
 
 	contextResp, err := s.contextManager.BuildContext(ctx, contextReq)
@@ -124,7 +253,9 @@ func (s *Service) ProcessMessage(ctx context.Context, req ProcessMessageRequest)
 	)
 
 	// 5. Отправляем запрос к LLM
-	llmResponse, err := s.llmClient.ChatCompletion(ctx, contextResp.Messages)
+	llmCtx := llm.WithRequestOptions(ctx, requestOptions(model, req))
+	llmMessages := withResources(withOriginalUserMessage(contextResp.Messages, userMessage.Content, req.Message), resources)
+	llmResponse, err := s.llmClient.ChatCompletion(llmCtx, withAssistantPrefix(llmMessages, req.AssistantPrefix))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get LLM response: %w", err)
 	}
@@ -132,17 +263,28 @@ func (s *Service) ProcessMessage(ctx context.Context, req ProcessMessageRequest)
 	if len(llmResponse.Choices) == 0 {
 		return nil, fmt.Errorf("no choices in LLM response")
 	}
+	if err := s.toolLoopLimit(req.SessionID, llmResponse.Choices[0].FinishReason, llmResponse.Iterations, llmResponse.ToolCalls); err != nil {
+		return nil, err
+	}
 
-	assistantContent := llmResponse.Choices[0].Message.Content
+	// Провайдер возвращает только продолжение - ответ сохраняется целиком вместе с префиксом
+	assistantContent := req.AssistantPrefix + llmResponse.Choices[0].Message.Content
+
+	// 6. Сохраняем вызовы инструментов и ответ ассистента
+	if err := s.saveToolMessages(ctx, req.SessionID, llmResponse.ToolCalls); err != nil {
+		return nil, err
+	}
 
-	// 6. Сохраняем ответ ассистента
 	assistantMessage := models.NewAssistantMessage(req.SessionID, assistantContent)
 	assistantMessage.ID = uuid.New().String()
 	assistantMessage.Metadata = models.Metadata{
-		Tokens: llmResponse.Usage.TotalTokens,
-		Model:  llmResponse.Model,
-		Cost:   s.calculateCost(llmResponse.Usage.TotalTokens),
+		Model:          model,
+		Generation:     s.generationParams(req.SessionID, llmResponse.Generation),
+		Grounding:      groundingSources(llmResponse.Grounding),
+		FinishReason:   limitFinishReason(llmResponse.Choices[0].FinishReason),
+		ToolIterations: llmResponse.Iterations,
 	}
+	s.applyUsage(&assistantMessage.Metadata, llmResponse.Usage)
 
 	s.logger.Debug("Creating assistant message",
 		zap.String("message_id", assistantMessage.ID),
@@ -154,8 +296,11 @@ func (s *Service) ProcessMessage(ctx context.Context, req ProcessMessageRequest)
 	if err := s.messageStore.SaveMessage(ctx, assistantMessage); err != nil {
 		return nil, fmt.Errorf("failed to save assistant message: %w", err)
 	}
+	s.contextManager.IndexMessages(assistantMessage)
+	userStatus = models.MessageStatusCompleted
 
 	processingTime := time.Since(startTime)
+	s.recordMetrics(llmResponse.Usage.TotalTokens, assistantMessage.Metadata.Cost, processingTime)
 
 	// 7. Формируем метаданные контекста
 	contextMetadata := &ContextMetadata{
@@ -163,10 +308,15 @@ func (s *Service) ProcessMessage(ctx context.Context, req ProcessMessageRequest)
 		ContextWindowUsed:    len(contextResp.Messages),
 		HasSummary:           contextResp.HasSummary,
 		CompressionTriggered: contextResp.SummaryUpdated,
+		ActiveAnchors:        contextResp.ActiveAnchors,
+		RecalledMessages:     contextResp.RecalledMessages,
+		AttachedResources:    len(resources),
+		CompressionFailed:    compressionFailed(contextResp.CompressionInfo),
 	}
 
 	if contextResp.CompressionInfo != nil && contextResp.CompressionInfo.Triggered {
 		contextMetadata.MessagesCompressed = contextResp.CompressionInfo.MessagesCompressed
+		contextMetadata.AnchorsCreated = contextResp.CompressionInfo.AnchorsCreated
 	}
 
 	s.logger.Info("Message processed successfully with context",
@@ -186,9 +336,140 @@ func (s *Service) ProcessMessage(ctx context.Context, req ProcessMessageRequest)
 		Model:          llmResponse.Model,
 		ProcessingTime: processingTime,
 		ContextInfo:    contextMetadata,
+		Generation:     assistantMessage.Metadata.Generation,
+		Grounding:      assistantMessage.Metadata.Grounding,
+		FinishReason:   assistantMessage.Metadata.FinishReason,
+		Iterations:     llmResponse.Iterations,
+		ToolCalls:      s.toolCallSummaries(llmResponse.ToolCalls),
 	}, nil
 }
 
+// compressionFailed сжатие при построении контекста завершилось ошибкой
+func compressionFailed(info *contextmgr.CompressionInfo) bool {
+	return info != nil && info.Reason == contextmgr.ReasonCompressionFailed
+}
+
+// limitFinishReason оставляет только причины завершения по лимиту длины ответа
+// или стоп-последовательности - обычное завершение в метаданных не сохраняется.
+// Лимит итераций до сохранения не доходит (см. toolLoopLimit).
+func limitFinishReason(reason string) string {
+	switch reason {
+	case llm.FinishReasonMaxTokens, llm.FinishReasonStopSequence:
+		return reason
+	}
+	return ""
+}
+
+// requestOptions параметры генерации запроса для провайдера
+func requestOptions(model string, req ProcessMessageRequest) llm.RequestOptions {
+	return llm.RequestOptions{
+		Model:           model,
+		Seed:            req.Seed,
+		Deterministic:   req.Deterministic,
+		MaxIterations:   req.MaxIterations,
+		MaxOutputTokens: req.MaxOutputTokens,
+		Stop:            req.Stop,
+		GoogleSearch:    req.GoogleSearch,
+		ToolMode:        req.ToolMode,
+		SessionID:       req.SessionID,
+		UserID:          req.UserID,
+	}
+}
+
+// newUserMessage создаёт сообщение пользователя для сохранения. При включённом маскировании
+// персональные данные заменяются токенами, а число замен записывается в метаданные.
+func (s *Service) newUserMessage(req ProcessMessageRequest) models.Message {
+	content, redactions := s.redactor.Redact(req.Message)
+
+	msg := models.NewUserMessage(req.SessionID, content)
+	msg.ID = uuid.New().String()
+	msg.Status = models.MessageStatusPending
+	msg.Metadata.Redactions = redactions
+
+	if redactions > 0 {
+		s.logger.Debug("User message redacted",
+			zap.String("session_id", req.SessionID),
+			zap.String("message_id", msg.ID),
+			zap.Int("redactions", redactions),
+		)
+	}
+	return msg
+}
+
+// withOriginalUserMessage возвращает в контекст исходный текст текущего сообщения: в хранилище
+// оно сохранено с маскированными данными, но в текущем запросе LLM получает его без изменений
+func withOriginalUserMessage(messages []llm.Message, stored, original string) []llm.Message {
+	if stored == original {
+		return messages
+	}
+
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" && messages[i].Content == stored {
+			result := make([]llm.Message, len(messages))
+			copy(result, messages)
+			result[i].Content = original
+			return result
+		}
+	}
+	return messages
+}
+
+// withAssistantPrefix добавляет в конец контекста начало ответа ассистента, которое продолжит модель
+func withAssistantPrefix(messages []llm.Message, prefix string) []llm.Message {
+	if prefix == "" {
+		return messages
+	}
+
+	result := make([]llm.Message, 0, len(messages)+1)
+	result = append(result, messages...)
+	return append(result, llm.Message{Role: "assistant", Content: prefix})
+}
+
+// generationParams переводит применённые провайдером параметры генерации в метаданные сообщения
+// и логирует предупреждения о неподдерживаемых параметрах
+func (s *Service) generationParams(sessionID string, info *llm.GenerationInfo) *models.GenerationParams {
+	if info == nil {
+		return nil
+	}
+
+	params := &models.GenerationParams{
+		Seed:            info.Seed,
+		Temperature:     info.Temperature,
+		TopK:            info.TopK,
+		MaxOutputTokens: info.MaxOutputTokens,
+		Deterministic:   info.Deterministic,
+	}
+
+	for _, warning := range info.Warnings {
+		s.logger.Warn("Generation parameter not applied as requested",
+			zap.String("session_id", sessionID),
+			zap.String("parameter", warning.Parameter),
+			zap.String("code", string(warning.Code)),
+			zap.String("details", warning.Message),
+		)
+		params.Warnings = append(params.Warnings, models.GenerationWarning{
+			Code:      string(warning.Code),
+			Parameter: warning.Parameter,
+			Message:   warning.Message,
+		})
+	}
+
+	return params
+}
+
+// groundingSources переводит результаты поиска Google в метаданные сообщения
+func groundingSources(info *llm.GroundingInfo) *models.Grounding {
+	if info == nil {
+		return nil
+	}
+
+	grounding := &models.Grounding{SearchQueries: info.SearchQueries}
+	for _, source := range info.Sources {
+		grounding.Sources = append(grounding.Sources, models.GroundingSource{URI: source.URI, Title: source.Title})
+	}
+	return grounding
+}
+
 // ProcessMessageStream обрабатывает сообщение с потоковым ответом
 func (s *Service) ProcessMessageStream(ctx context.Context, req ProcessMessageRequest) (<-chan StreamResponse, error) {
 	s.logger.Info("Processing streaming message with context management",
@@ -196,37 +477,84 @@ func (s *Service) ProcessMessageStream(ctx context.Context, req ProcessMessageRe
 		zap.String("user_id", req.UserID),
 	)
 
+	// Валидация до открытия потока: ошибки возвращаются сразу, а не событием потока
+	if err := ValidateProcessMessageRequest(req); err != nil {
+		return nil, err
+	}
+	if err := validateMaxIterations(req, s.config.MaxIterationsLimit); err != nil {
+		return nil, err
+	}
+	if err := validateAssistantPrefix(req, s.llmClient); err != nil {
+		return nil, err
+	}
+
+	model, err := s.resolveModel(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateMaxOutputTokens(req, model, s.config.MaxOutputTokensLimit, s.llmClient); err != nil {
+		return nil, err
+	}
+
+	// Ресурсы и промпт читаются до открытия потока, чтобы ошибка в них вернулась обычным ответом
+	resources, err := s.loadResources(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	prompt, err := s.loadPrompt(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// При включённом буфере генерация не зависит от соединения клиента:
+	// после разрыва ответ дописывается в буфер и доступен через ResumeStream
+	clientCtx := ctx
+	if s.replay.Enabled() {
+		ctx = context.WithoutCancel(ctx)
+	}
+
+	// Регистрируем поток до начала работы, чтобы его можно было остановить или дождаться при завершении сервера
+	assistantMessageID := uuid.New().String()
+	ctx, done, err := s.streams.Register(ctx, req.SessionID, assistantMessageID)
+	if err != nil {
+		return nil, err
+	}
+
 	responseCh := make(chan StreamResponse, 100)
+	out := (<-chan StreamResponse)(responseCh)
+	if s.replay.Enabled() {
+		s.replay.Open(req.SessionID, assistantMessageID)
+		out = s.relayStream(clientCtx, assistantMessageID, responseCh)
+	}
 
 	go func() {
 		defer close(responseCh)
+		defer done()
 
-		// 1. Валидация
-		if err := ValidateProcessMessageRequest(req); err != nil {
-			responseCh <- StreamResponse{Error: err}
-			return
-		}
-
-		// 2. Создаём сессию если её нет
-		if err := s.ensureSession(ctx, req.SessionID); err != nil {
+		// 1-2. Запрос проверен до открытия потока; создаём сессию если её нет
+		if err := s.ensureSession(ctx, req.SessionID, req.UserID); err != nil {
 			responseCh <- StreamResponse{Error: fmt.Errorf("failed to ensure session: %w", err)}
 			return
 		}
 
-		// 3. Сохраняем сообщение пользователя
-		userMessage := models.NewUserMessage(req.SessionID, req.Message)
-		userMessage.ID = uuid.New().String()
+		// 3. Сохраняем сообщение пользователя; оно ожидает ответа до сохранения ответа ассистента
+		userMessage := s.newUserMessage(req)
 
 		if err := s.messageStore.SaveMessage(ctx, userMessage); err != nil {
 			responseCh <- StreamResponse{Error: fmt.Errorf("failed to save user message: %w", err)}
 			return
 		}
+		s.contextManager.IndexMessages(userMessage)
+
+		userStatus := models.MessageStatusFailed
+		defer func() { s.setMessageStatus(userMessage.ID, userStatus) }()
 
 		// 4. Строим контекст
 		contextReq := contextmgr.ContextRequest{
 			SessionID:     req.SessionID,
-			SystemPrompt:  s.getSystemPrompt(),
+			SystemPrompt:  s.systemPromptFor(ctx, req, prompt),
 			IncludeSystem: true,
+			Query:         userMessage.Content, // с маскированными данными, как и сохранённые эмбеддинги
 		}
 
 		contextResp, err := s.contextManager.BuildContext(ctx, contextReq)
@@ -241,29 +569,128 @@ func (s *Service) ProcessMessageStream(ctx context.Context, req ProcessMessageRe
 			ContextWindowUsed:    len(contextResp.Messages),
 			HasSummary:           contextResp.HasSummary,
 			CompressionTriggered: contextResp.SummaryUpdated,
+			ActiveAnchors:        contextResp.ActiveAnchors,
+			RecalledMessages:     contextResp.RecalledMessages,
+			AttachedResources:    len(resources),
+			CompressionFailed:    compressionFailed(contextResp.CompressionInfo),
 		}
 
 		if contextResp.CompressionInfo != nil && contextResp.CompressionInfo.Triggered {
 			contextMetadata.MessagesCompressed = contextResp.CompressionInfo.MessagesCompressed
+			contextMetadata.AnchorsCreated = contextResp.CompressionInfo.AnchorsCreated
 		}
 
 		// 6. Начинаем стриминговый запрос к LLM
-		streamCh, err := s.llmClient.ChatCompletionStream(ctx, contextResp.Messages)
+		llmCtx := llm.WithRequestOptions(ctx, requestOptions(model, req))
+		llmMessages := withResources(withOriginalUserMessage(contextResp.Messages, userMessage.Content, req.Message), resources)
+		streamCh, err := s.llmClient.ChatCompletionStream(llmCtx, withAssistantPrefix(llmMessages, req.AssistantPrefix))
 		if err != nil {
 			responseCh <- StreamResponse{Error: fmt.Errorf("failed to start LLM stream: %w", err)}
 			return
 		}
 
-		assistantMessageID := uuid.New().String()
-
 		// Отправляем информацию о контексте в начале стрима
 		responseCh <- StreamResponse{
 			MessageID:   assistantMessageID,
+			Model:       model,
 			ContextInfo: contextMetadata,
 		}
 
 		// 7. Обрабатываем поток
-		s.handleStreamResponseWithContext(ctx, req.SessionID, assistantMessageID, streamCh, responseCh, contextMetadata)
+		if s.handleStreamResponseWithContext(ctx, req.SessionID, assistantMessageID, model, req.AssistantPrefix, streamCh, responseCh, contextMetadata) {
+			userStatus = models.MessageStatusCompleted
+		}
+	}()
+
+	return out, nil
+}
+
+// relayStream нумерует события потока, сохраняет их в буфере повторной отправки
+// и передаёт клиенту, пока тот подключён. После отключения клиента события только буферизуются.
+func (s *Service) relayStream(clientCtx context.Context, messageID string, in <-chan StreamResponse) <-chan StreamResponse {
+	out := make(chan StreamResponse, cap(in))
+
+	go func() {
+		defer close(out)
+		defer s.replay.Finish(messageID)
+
+		attached := true
+		for resp := range in {
+			resp = s.replay.Append(messageID, resp)
+			if !attached {
+				continue
+			}
+
+			select {
+			case out <- resp:
+			case <-clientCtx.Done():
+				attached = false
+				s.logger.Info("Stream client disconnected, buffering for resume",
+					zap.String("message_id", messageID),
+					zap.Uint64("seq", resp.Seq),
+				)
+			}
+		}
+	}()
+
+	return out
+}
+
+// ResumeStream повторно отправляет события потока начиная с fromSeq и продолжает
+// передавать новые, если генерация ещё идёт. Поток завершается финальным событием.
+func (s *Service) ResumeStream(ctx context.Context, sessionID, messageID string, fromSeq uint64) (<-chan StreamResponse, error) {
+	if !s.replay.Enabled() {
+		return nil, ErrStreamNotFound
+	}
+
+	events, finished, notify, err := s.replay.Since(sessionID, messageID, fromSeq)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Resuming stream",
+		zap.String("session_id", sessionID),
+		zap.String("message_id", messageID),
+		zap.Uint64("from_seq", fromSeq),
+		zap.Int("buffered_events", len(events)),
+		zap.Bool("finished", finished),
+	)
+
+	responseCh := make(chan StreamResponse, 100)
+
+	go func() {
+		defer close(responseCh)
+
+		next := fromSeq
+		for {
+			for _, event := range events {
+				select {
+				case responseCh <- event:
+					next = event.Seq + 1
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if finished {
+				return
+			}
+
+			select {
+			case <-notify:
+			case <-ctx.Done():
+				return
+			}
+
+			events, finished, notify, err = s.replay.Since(sessionID, messageID, next)
+			if err != nil {
+				select {
+				case responseCh <- StreamResponse{Error: err, MessageID: messageID}:
+				case <-ctx.Done():
+				}
+				return
+			}
+		}
 	}()
 
 	return responseCh, nil
@@ -271,25 +698,58 @@ func (s *Service) ProcessMessageStream(ctx context.Context, req ProcessMessageRe
 
 func (s *Service) handleStreamResponseWithContext(
 	ctx context.Context,
-	sessionID, assistantMessageID string,
+	sessionID, assistantMessageID, model, prefix string,
 	streamCh <-chan llm.StreamChunk,
 	responseCh chan<- StreamResponse,
 	contextMetadata *ContextMetadata,
-) {
+) (answered bool) {
 	var fullContent strings.Builder
 	startTime := time.Now()
 
-	for chunk := range streamCh {
+	// Начало ответа отправляется первым чанком, дальше идёт продолжение от провайдера
+	if prefix != "" {
+		fullContent.WriteString(prefix)
+		responseCh <- StreamResponse{
+			Content:   prefix,
+			MessageID: assistantMessageID,
+		}
+	}
+
+	for {
+		var chunk llm.StreamChunk
+		var ok bool
+
 		select {
 		case <-ctx.Done():
+			cause := context.Cause(ctx)
+			if errors.Is(cause, ErrStreamShutdown) {
+				return s.savePartialResponse(sessionID, assistantMessageID, model, fullContent.String(), FinishReasonShutdown, startTime, responseCh)
+			}
 			responseCh <- StreamResponse{Error: ctx.Err()}
-			return
-		default:
+			return false
+		case chunk, ok = <-streamCh:
+			if !ok {
+				return false
+			}
 		}
 
 		if chunk.Error != nil {
 			responseCh <- StreamResponse{Error: chunk.Error}
-			return
+			return false
+		}
+
+		// События инструментов передаём в том же порядке, что и текст
+		if chunk.ToolCall != nil {
+			responseCh <- StreamResponse{
+				ToolCall:  chunk.ToolCall,
+				MessageID: assistantMessageID,
+			}
+		}
+		if chunk.ToolProgress != nil {
+			responseCh <- StreamResponse{
+				ToolProgress: chunk.ToolProgress,
+				MessageID:    assistantMessageID,
+			}
 		}
 
 		if chunk.Content != "" {
@@ -301,23 +761,38 @@ func (s *Service) handleStreamResponseWithContext(
 		}
 
 		if chunk.Done {
+			if err := s.toolLoopLimit(sessionID, chunk.FinishReason, chunk.Iterations, chunk.ToolCalls); err != nil {
+				responseCh <- StreamResponse{Error: err, MessageID: assistantMessageID}
+				return false
+			}
+
+			if err := s.saveToolMessages(ctx, sessionID, chunk.ToolCalls); err != nil {
+				s.logger.Error("Failed to save tool messages", zap.Error(err))
+				responseCh <- StreamResponse{Error: err}
+				return false
+			}
+
 			// Сохраняем полный ответ ассистента
-			assistantMessage := models.Message{
-				ID:        assistantMessageID,
-				SessionID: sessionID,
-				Role:      "assistant",
-				Content:   fullContent.String(),
-				Timestamp: time.Now(),
-				Metadata: models.Metadata{
-					Model: "streamed",
-				},
+			assistantMessage := models.NewAssistantMessage(sessionID, fullContent.String())
+			assistantMessage.ID = assistantMessageID
+			assistantMessage.Metadata = models.Metadata{
+				Model:          model,
+				Generation:     s.generationParams(sessionID, chunk.Generation),
+				Grounding:      groundingSources(chunk.Grounding),
+				FinishReason:   limitFinishReason(chunk.FinishReason),
+				ToolIterations: chunk.Iterations,
+			}
+			if chunk.Usage != nil {
+				s.applyUsage(&assistantMessage.Metadata, *chunk.Usage)
 			}
 
 			if err := s.messageStore.SaveMessage(ctx, assistantMessage); err != nil {
 				s.logger.Error("Failed to save streamed message", zap.Error(err))
 				responseCh <- StreamResponse{Error: err}
-				return
+				return false
 			}
+			s.contextManager.IndexMessages(assistantMessage)
+			s.recordMetrics(assistantMessage.Metadata.Tokens, assistantMessage.Metadata.Cost, time.Since(startTime))
 
 			s.logger.Info("Streaming message completed with context",
 				zap.String("session_id", sessionID),
@@ -328,17 +803,122 @@ func (s *Service) handleStreamResponseWithContext(
 			)
 
 			responseCh <- StreamResponse{
-				Done:      true,
-				MessageID: assistantMessageID,
+				Done:         true,
+				MessageID:    assistantMessageID,
+				FinishReason: assistantMessage.Metadata.FinishReason,
+				Iterations:   chunk.Iterations,
+				Generation:   assistantMessage.Metadata.Generation,
+				Grounding:    assistantMessage.Metadata.Grounding,
+				ToolCalls:    s.toolCallSummaries(chunk.ToolCalls),
 			}
-			return
+			return true
 		}
 	}
 }
 
+// savePartialResponse сохраняет частично сгенерированный ответ прерванного потока
+// и сообщает, был ли сохранён ответ. Контекст запроса уже отменён,
+// поэтому сохранение выполняется с отдельным таймаутом.
+func (s *Service) savePartialResponse(
+	sessionID, assistantMessageID, model, content, finishReason string,
+	startTime time.Time,
+	responseCh chan<- StreamResponse,
+) bool {
+	saved := false
+	if content != "" {
+		saveCtx, cancel := context.WithTimeout(context.Background(), partialSaveTimeout)
+		defer cancel()
+
+		assistantMessage := models.NewAssistantMessage(sessionID, content)
+		assistantMessage.ID = assistantMessageID
+		assistantMessage.Metadata = models.Metadata{
+			Model:        model,
+			FinishReason: finishReason,
+		}
+
+		if err := s.messageStore.SaveMessage(saveCtx, assistantMessage); err != nil {
+			s.logger.Error("Failed to save partial streamed message",
+				zap.String("message_id", assistantMessageID),
+				zap.Error(err),
+			)
+		} else {
+			saved = true
+		}
+	}
+
+	s.logger.Info("Streaming message interrupted",
+		zap.String("session_id", sessionID),
+		zap.String("message_id", assistantMessageID),
+		zap.String("finish_reason", finishReason),
+		zap.Int("content_length", len(content)),
+		zap.Duration("duration", time.Since(startTime)),
+	)
+
+	responseCh <- StreamResponse{
+		Done:         true,
+		MessageID:    assistantMessageID,
+		FinishReason: finishReason,
+	}
+	return saved
+}
+
+// setMessageStatus фиксирует итог обработки сообщения пользователя.
+// Контекст запроса к этому моменту может быть отменён, поэтому используется отдельный таймаут;
+// при неудаче сообщение останется pending и будет закрыто задачей очистки.
+func (s *Service) setMessageStatus(messageID, status string) {
+	ctx, cancel := context.WithTimeout(context.Background(), partialSaveTimeout)
+	defer cancel()
+
+	if err := s.messageStore.UpdateMessageStatus(ctx, messageID, status); err != nil {
+		s.logger.Warn("Failed to update message status",
+			zap.String("message_id", messageID),
+			zap.String("status", status),
+			zap.Error(err),
+		)
+	}
+}
+
 // GetContextInfo возвращает информацию о контексте сессии
 func (s *Service) GetContextInfo(ctx context.Context, sessionID string) (*contextmgr.ContextInfo, error) {
-	return s.contextManager.GetContextInfo(ctx, sessionID)
+	info, err := s.contextManager.GetContextInfo(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	info.Language = s.LanguageSetting(ctx, sessionID)
+	return info, nil
+}
+
+// LanguageSetting возвращает действующую настройку языка сессии ("auto" или код языка)
+func (s *Service) LanguageSetting(ctx context.Context, sessionID string) string {
+	if s.language == nil {
+		return ""
+	}
+	return s.language.Setting(ctx, sessionID)
+}
+
+// SetSessionLanguage задаёт язык ответов сессии; пустое значение возвращает язык из конфигурации
+func (s *Service) SetSessionLanguage(ctx context.Context, sessionID, setting string) error {
+	if setting != "" {
+		if err := language.Validate(setting); err != nil {
+			return ValidationErrors{{
+				Field:   "language",
+				Code:    ValidationCodeInvalid,
+				Message: err.Error(),
+				Err:     err,
+			}}
+		}
+	}
+
+	if err := s.sessionStore.SetSessionLanguage(ctx, sessionID, setting); err != nil {
+		return fmt.Errorf("failed to set session language: %w", err)
+	}
+
+	s.logger.Info("Session language updated",
+		zap.String("session_id", sessionID),
+		zap.String("language", setting),
+	)
+	return nil
 }
 
 // DeleteSession удаляет сессию и очищает контекст
@@ -355,90 +935,285 @@ func (s *Service) DeleteSession(ctx context.Context, sessionID string) error {
 	if err := s.messageStore.DeleteSession(ctx, sessionID); err != nil {
 		return fmt.Errorf("failed to delete messages: %w", err)
 	}
+	s.resources.forget(sessionID)
+
+	if s.events != nil {
+		s.events.Publish(events.Event{
+			Type:      events.TypeSessionDeleted,
+			SessionID: sessionID,
+		})
+	}
 
 	s.logger.Info("Session deleted with context cleanup",
 		zap.String("session_id", sessionID))
 	return nil
 }
 
-// TriggerCompression принудительно запускает сжатие контекста
-func (s *Service) TriggerCompression(ctx context.Context, sessionID string) (*CompressionResult, error) {
+// CloneSession копирует сессию вместе со сжатым состоянием под новым ID.
+// Исходная сессия не изменяется; в копии резюме ссылаются только на её собственные сообщения.
+func (s *Service) CloneSession(ctx context.Context, sessionID string) (*models.SessionCloneResult, error) {
+	result, err := s.sessionStore.CloneSession(ctx, sessionID, uuid.New().String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone session: %w", err)
+	}
+
+	s.logger.Info("Session cloned",
+		zap.String("source_session_id", sessionID),
+		zap.String("session_id", result.SessionID),
+		zap.Int("messages_copied", result.MessagesCopied),
+		zap.Int("summaries_copied", result.SummariesCopied),
+	)
+	return result, nil
+}
+
+// TriggerCompression проверяет пороги и сжимает контекст; force сжимает независимо от порогов.
+// Контекст для LLM при этом не строится
+func (s *Service) TriggerCompression(ctx context.Context, sessionID string, force bool) (*CompressionResult, error) {
 	s.logger.Info("Manually triggering compression",
 		zap.String("session_id", sessionID),
+		zap.Bool("force", force),
 	)
 
-	// Строим контекст, что может вызвать сжатие
-	contextReq := contextmgr.ContextRequest{
-		SessionID:     sessionID,
-		SystemPrompt:  s.getSystemPrompt(),
-		IncludeSystem: false, // Не нужен системный промпт для проверки
+	// Заполненность контекста до сжатия: по ней видно, какой порог сработал
+	before, err := s.contextManager.GetContextInfo(ctx, sessionID)
+	if err != nil {
+		s.logger.Warn("Failed to get context info before compression",
+			zap.String("session_id", sessionID),
+			zap.Error(err),
+		)
 	}
 
-	contextResp, err := s.contextManager.BuildContext(ctx, contextReq)
+	info, err := s.contextManager.Compress(ctx, sessionID, contextmgr.CompressOptions{Force: force})
 	if err != nil {
-		return nil, fmt.Errorf("failed to build context for compression: %w", err)
+		return nil, fmt.Errorf("failed to compress context: %w", err)
 	}
 
 	result := &CompressionResult{
-		SessionID:     sessionID,
-		Triggered:     contextResp.SummaryUpdated,
-		TotalMessages: contextResp.TotalMessages,
-		ContextSize:   len(contextResp.Messages),
-		HasSummary:    contextResp.HasSummary,
+		SessionID:           sessionID,
+		Triggered:           info.Triggered,
+		Reason:              info.Reason,
+		Trigger:             info.Trigger,
+		Level:               info.Level,
+		MessagesCompressed:  info.MessagesCompressed,
+		SummariesCompressed: info.SummariesCompressed,
+		AnchorsCreated:      info.AnchorsCreated,
+		TokensUsed:          info.TokensUsed,
+		Duration:            info.Duration,
+		ContextBefore:       before,
 	}
 
-	if contextResp.CompressionInfo != nil {
-		result.MessagesCompressed = contextResp.CompressionInfo.MessagesCompressed
-		result.AnchorsCreated = contextResp.CompressionInfo.AnchorsCreated
-		result.TokensUsed = contextResp.CompressionInfo.TokensUsed
-		result.Duration = contextResp.CompressionInfo.Duration
+	after, err := s.contextManager.GetContextInfo(ctx, sessionID)
+	if err != nil {
+		// Сжатие уже выполнено, поэтому ошибка чтения контекста не делает запрос неуспешным
+		s.logger.Warn("Failed to get context info after compression",
+			zap.String("session_id", sessionID),
+			zap.Error(err),
+		)
+		return result, nil
 	}
 
+	result.TotalMessages = after.TotalMessages
+	result.ContextSize = after.ContextMessages
+	result.HasSummary = after.ActiveSummaries+after.BulkSummaries+after.SessionDigests > 0
+
 	return result, nil
 }
 
-type CompressionResult struct {
+// RangeSummaryResult результат ручного сжатия диапазона сообщений
+type RangeSummaryResult struct {
 	SessionID          string        `json:"session_id"`
-	Triggered          bool          `json:"triggered"`
-	TotalMessages      int           `json:"total_messages"`
-	ContextSize        int           `json:"context_size"`
-	HasSummary         bool          `json:"has_summary"`
+	SummaryID          string        `json:"summary_id"`
+	Level              int           `json:"level"`
+	Summary            string        `json:"summary"`
+	Anchors            []string      `json:"anchors"`
 	MessagesCompressed int           `json:"messages_compressed"`
-	AnchorsCreated     int           `json:"anchors_created"`
 	TokensUsed         int           `json:"tokens_used"`
 	Duration           time.Duration `json:"duration"`
 }
 
+// CreateRangeSummary сжимает сообщения между fromMessageID и toMessageID включительно
+func (s *Service) CreateRangeSummary(ctx context.Context, sessionID, fromMessageID, toMessageID string, level int) (*RangeSummaryResult, error) {
+	s.logger.Info("Manually creating summary for message range",
+		zap.String("session_id", sessionID),
+		zap.String("from_message_id", fromMessageID),
+		zap.String("to_message_id", toMessageID),
+		zap.Int("level", level),
+	)
+
+	resp, err := s.contextManager.CompressMessageRange(ctx, sessionID, fromMessageID, toMessageID, level)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress message range: %w", err)
+	}
+
+	return &RangeSummaryResult{
+		SessionID:          sessionID,
+		SummaryID:          resp.SummaryID,
+		Level:              resp.SummaryLevel,
+		Summary:            resp.BriefSummary,
+		Anchors:            resp.Anchors,
+		MessagesCompressed: resp.MessagesCompressed,
+		TokensUsed:         resp.TokensUsed,
+		Duration:           resp.Duration,
+	}, nil
+}
+
+// GetSummarySources возвращает резюме сессии вместе со сжатыми в него сообщениями или резюме
+func (s *Service) GetSummarySources(ctx context.Context, sessionID, summaryID string) (*models.SummarySources, error) {
+	sources, err := s.contextManager.GetSummarySources(ctx, sessionID, summaryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get summary sources: %w", err)
+	}
+	return sources, nil
+}
+
+// ExpandSummaryResult результат развёртывания резюме и контекст сессии после него
+type ExpandSummaryResult struct {
+	SessionID string `json:"session_id"`
+	models.SummaryExpansion
+
+	Context *contextmgr.ContextInfo `json:"context,omitempty"`
+}
+
+// ExpandSummary отменяет сжатие резюме: его источники снова попадают в контекст LLM
+func (s *Service) ExpandSummary(ctx context.Context, sessionID, summaryID string) (*ExpandSummaryResult, error) {
+	expansion, err := s.contextManager.ExpandSummary(ctx, sessionID, summaryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand summary: %w", err)
+	}
+
+	result := &ExpandSummaryResult{
+		SessionID:        sessionID,
+		SummaryExpansion: *expansion,
+	}
+
+	// Сжатие уже отменено, поэтому ошибка чтения контекста не делает запрос неуспешным
+	contextInfo, err := s.GetContextInfo(ctx, sessionID)
+	if err != nil {
+		s.logger.Warn("Failed to get context info after summary expansion",
+			zap.String("session_id", sessionID),
+			zap.Error(err),
+		)
+	} else {
+		result.Context = contextInfo
+	}
+
+	return result, nil
+}
+
+type CompressionResult struct {
+	SessionID           string        `json:"session_id"`
+	Triggered           bool          `json:"triggered"`
+	Reason              string        `json:"reason,omitempty"`
+	Trigger             string        `json:"trigger,omitempty"` // порог, по которому сработало сжатие: messages, tokens или forced
+	Level               int           `json:"level,omitempty"`
+	TotalMessages       int           `json:"total_messages"`
+	ContextSize         int           `json:"context_size"`
+	HasSummary          bool          `json:"has_summary"`
+	MessagesCompressed  int           `json:"messages_compressed"`
+	SummariesCompressed int           `json:"summaries_compressed"`
+	AnchorsCreated      int           `json:"anchors_created"`
+	TokensUsed          int           `json:"tokens_used"`
+	Duration            time.Duration `json:"duration"`
+
+	// ContextBefore заполненность контекста перед проверкой, включая доли по числу и по токенам
+	ContextBefore *contextmgr.ContextInfo `json:"context_before,omitempty"`
+}
+
+// getSystemPrompt промпт по умолчанию. Язык ответа задаёт инструкция, которую добавляет systemPromptFor.
 func (s *Service) getSystemPrompt() string {
-	return `Ты полезный AI-ассистент. Отвечай на русском языке, если пользователь пишет на русском. 
+	return `Ты полезный AI-ассистент.
 Будь вежливым, информативным и помогай пользователю решать его задачи.
 Если не знаешь ответа, честно скажи об этом.
 
 Если в контексте есть резюме предыдущего разговора, учитывай его при формировании ответов, но не упоминай явно, что ты читаешь резюме.`
 }
 
-func (s *Service) ensureSession(ctx context.Context, sessionID string) error {
-	_, err := s.sessionStore.GetSession(ctx, sessionID)
+// systemPromptFor возвращает системный промпт с инструкцией о языке ответа: выбранный промпт
+// MCP сервера или промпт по умолчанию. В режиме auto язык определяется по текущему сообщению пользователя.
+func (s *Service) systemPromptFor(ctx context.Context, req ProcessMessageRequest, prompt string) string {
+	if prompt == "" {
+		prompt = s.getSystemPrompt()
+	}
+	if s.language == nil {
+		return prompt
+	}
+
+	code := s.language.Resolve(ctx, req.SessionID, req.Message)
+	s.logger.Debug("Response language resolved",
+		zap.String("session_id", req.SessionID),
+		zap.String("language", code),
+	)
+	return language.AppendDirective(prompt, code)
+}
+
+// resolveModel определяет модель запроса: явный выбор -> настройка пользователя -> модель сервера
+func (s *Service) resolveModel(ctx context.Context, req ProcessMessageRequest) (string, error) {
+	if s.models == nil {
+		return req.Model, nil
+	}
+
+	model, source, err := s.models.Resolve(ctx, req.UserID, req.Model)
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug("Model resolved",
+		zap.String("session_id", req.SessionID),
+		zap.String("model", model),
+		zap.String("source", source),
+	)
+	return model, nil
+}
+
+// ensureSession создаёт сессию при необходимости и запоминает пользователя,
+// первым обратившегося к ней с user_id
+func (s *Service) ensureSession(ctx context.Context, sessionID, userID string) error {
+	session, err := s.sessionStore.GetSession(ctx, sessionID)
 	if err != nil {
-		return s.sessionStore.CreateSession(ctx, sessionID)
+		if err := s.sessionStore.CreateSession(ctx, sessionID); err != nil {
+			return err
+		}
+		session = &models.ChatSession{ID: sessionID}
+	}
+
+	if userID != "" && session.UserID == "" {
+		if err := s.sessionStore.SetSessionUser(ctx, sessionID, userID); err != nil {
+			// владелец нужен только для обслуживания, запрос продолжается без него
+			s.logger.Warn("Failed to set session user",
+				zap.String("session_id", sessionID),
+				zap.String("user_id", userID),
+				zap.Error(err))
+		}
 	}
 	return nil
 }
 
+// applyUsage записывает токены ответа и их стоимость в метаданные сообщения
+func (s *Service) applyUsage(meta *models.Metadata, usage llm.Usage) {
+	meta.Tokens = usage.TotalTokens
+	meta.PromptTokens = usage.PromptTokens
+	meta.CompletionTokens = usage.CompletionTokens
+	meta.Cost = s.calculateCost(usage.TotalTokens)
+}
+
 func (s *Service) calculateCost(tokens int) float64 {
 	costPerToken := 0.0001
 	return float64(tokens) * costPerToken
 }
 
-func (s *Service) GetHistory(ctx context.Context, sessionID string, limit int) ([]models.Message, error) {
+// GetHistory возвращает страницу истории сессии; includeTools добавляет сообщения вызовов инструментов
+func (s *Service) GetHistory(ctx context.Context, sessionID string, limit, offset int, includeTools bool) ([]models.Message, int, error) {
 	if limit <= 0 {
 		limit = 50
 	}
+	if offset < 0 {
+		offset = 0
+	}
 
-	messages, err := s.messageStore.GetMessages(ctx, sessionID, limit)
+	messages, total, err := s.messageStore.GetMessagesPage(ctx, sessionID, limit, offset, includeTools)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get messages: %w", err)
+		return nil, 0, fmt.Errorf("failed to get messages: %w", err)
 	}
 
-	return messages, nil
+	return messages, total, nil
 }
@@ -0,0 +1,167 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrShuttingDown сервер завершает работу и не принимает новые запросы
+	ErrShuttingDown = errors.New("server is shutting down")
+	// ErrStreamShutdown генерация прервана из-за остановки сервера
+	ErrStreamShutdown = errors.New("stream cancelled due to server shutdown")
+)
+
+const (
+	// FinishReasonShutdown ответ прерван остановкой сервера
+	FinishReasonShutdown = "shutdown"
+
+	// streamCancelGrace время на сохранение частичных ответов после отмены потоков
+	streamCancelGrace = 5 * time.Second
+	// partialSaveTimeout таймаут сохранения частичного ответа
+	partialSaveTimeout = 3 * time.Second
+)
+
+// StreamRegistry реестр активных потоковых генераций.
+// Используется для остановки отдельных потоков и для их слива при завершении сервера.
+type StreamRegistry struct {
+	mu       sync.Mutex
+	streams  map[string]*activeStream
+	draining bool
+	wg       sync.WaitGroup
+}
+
+type activeStream struct {
+	sessionID string
+	cancel    context.CancelCauseFunc
+}
+
+func NewStreamRegistry() *StreamRegistry {
+	return &StreamRegistry{
+		streams: make(map[string]*activeStream),
+	}
+}
+
+// Register регистрирует поток и возвращает его контекст и функцию завершения.
+// Во время остановки сервера возвращает ErrShuttingDown.
+func (r *StreamRegistry) Register(parent context.Context, sessionID, messageID string) (context.Context, func(), error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.draining {
+		return nil, nil, ErrShuttingDown
+	}
+
+	ctx, cancel := context.WithCancelCause(parent)
+	r.streams[messageID] = &activeStream{
+		sessionID: sessionID,
+		cancel:    cancel,
+	}
+	r.wg.Add(1)
+
+	var once sync.Once
+	done := func() {
+		once.Do(func() {
+			r.mu.Lock()
+			delete(r.streams, messageID)
+			r.mu.Unlock()
+
+			cancel(nil)
+			r.wg.Done()
+		})
+	}
+
+	return ctx, done, nil
+}
+
+// Cancel останавливает поток по идентификатору сообщения ассистента
+func (r *StreamRegistry) Cancel(messageID string, cause error) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stream, ok := r.streams[messageID]
+	if !ok {
+		return false
+	}
+
+	stream.cancel(cause)
+	return true
+}
+
+// CancelSession останавливает все потоки сессии и возвращает их количество
+func (r *StreamRegistry) CancelSession(sessionID string, cause error) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cancelled := 0
+	for _, stream := range r.streams {
+		if stream.sessionID == sessionID {
+			stream.cancel(cause)
+			cancelled++
+		}
+	}
+
+	return cancelled
+}
+
+// Active возвращает количество активных потоков
+func (r *StreamRegistry) Active() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.streams)
+}
+
+// Draining сообщает, что сервер завершает работу
+func (r *StreamRegistry) Draining() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.draining
+}
+
+// Drain прекращает приём новых потоков и ждёт завершения активных в течение window.
+// Оставшиеся потоки отменяются с причиной ErrStreamShutdown, чтобы они сохранили частичный ответ.
+func (r *StreamRegistry) Drain(window time.Duration) (finished, cancelled int) {
+	r.mu.Lock()
+	r.draining = true
+	initial := len(r.streams)
+	r.mu.Unlock()
+
+	if initial == 0 {
+		return 0, 0
+	}
+
+	if r.wait(window) {
+		return initial, 0
+	}
+
+	r.mu.Lock()
+	cancelled = len(r.streams)
+	for _, stream := range r.streams {
+		stream.cancel(ErrStreamShutdown)
+	}
+	r.mu.Unlock()
+
+	r.wait(streamCancelGrace)
+
+	return initial - cancelled, cancelled
+}
+
+// wait ждёт завершения всех потоков не дольше timeout
+func (r *StreamRegistry) wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -0,0 +1,120 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"LLM_Chat/pkg/llm"
+
+	"go.uber.org/zap"
+)
+
+// ToolCallSummary вызов MCP инструмента в ответе API. Строковые аргументы и JSON результата
+// обрезаются по chat.tool_calls, чтобы большие выводы инструментов не раздували ответ.
+type ToolCallSummary struct {
+	ID              string         `json:"id"`
+	Name            string         `json:"name"`
+	Arguments       map[string]any `json:"arguments,omitempty"`
+	Result          string         `json:"result,omitempty"` // JSON результата, переданного модели
+	ResultTruncated bool           `json:"result_truncated,omitempty"`
+	DurationMs      int64          `json:"duration_ms"`
+	Success         bool           `json:"success"`
+	Error           string         `json:"error,omitempty"`
+}
+
+// ErrToolLoopLimit модель не дала финального ответа за допустимое число итераций цикла инструментов
+var ErrToolLoopLimit = errors.New("tool loop reached iteration limit without a final answer")
+
+// ToolLoopLimitError исчерпан лимит итераций цикла инструментов. Ответ ассистента не сохраняется,
+// вызовы инструментов возвращаются клиенту для диагностики.
+type ToolLoopLimitError struct {
+	Iterations int
+	ToolCalls  []ToolCallSummary
+}
+
+func (e *ToolLoopLimitError) Error() string {
+	return fmt.Sprintf("%s (%d iterations, %d tool calls)", ErrToolLoopLimit, e.Iterations, len(e.ToolCalls))
+}
+
+func (e *ToolLoopLimitError) Unwrap() error {
+	return ErrToolLoopLimit
+}
+
+// toolLoopLimit возвращает ToolLoopLimitError, если провайдер остановил цикл инструментов по лимиту
+func (s *Service) toolLoopLimit(sessionID, finishReason string, iterations int, calls []llm.ToolCallTrace) error {
+	if finishReason != llm.FinishReasonMaxIterations {
+		return nil
+	}
+
+	s.logger.Warn("Tool loop limit reached, assistant message is not saved",
+		zap.String("session_id", sessionID),
+		zap.Int("iterations", iterations),
+		zap.Int("tool_calls", len(calls)),
+	)
+	return &ToolLoopLimitError{Iterations: iterations, ToolCalls: s.toolCallSummaries(calls)}
+}
+
+// toolCallSummaries сводка вызовов инструментов ответа в порядке их начала
+func (s *Service) toolCallSummaries(calls []llm.ToolCallTrace) []ToolCallSummary {
+	if len(calls) == 0 {
+		return nil
+	}
+
+	limits := s.config.ToolCalls
+	summaries := make([]ToolCallSummary, 0, len(calls))
+	for _, call := range calls {
+		summary := ToolCallSummary{
+			ID:         call.ID,
+			Name:       call.Name,
+			DurationMs: call.DurationMs,
+			Success:    call.Error == "",
+			Error:      call.Error,
+		}
+		if len(call.Arguments) > 0 {
+			summary.Arguments = shortenStrings(call.Arguments, limits.MaxArgumentLength).(map[string]any)
+		}
+		if call.Result != nil {
+			if data, err := json.Marshal(call.Result); err == nil {
+				summary.Result, summary.ResultTruncated = truncateBytes(string(data), limits.MaxResultBytes)
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// shortenStrings обрезает строки длиннее limit символов внутри JSON-подобного значения
+func shortenStrings(value any, limit int) any {
+	switch v := value.(type) {
+	case string:
+		if utf8.RuneCountInString(v) > limit {
+			return string([]rune(v)[:limit]) + "…"
+		}
+		return v
+	case map[string]any:
+		shortened := make(map[string]any, len(v))
+		for key, item := range v {
+			shortened[key] = shortenStrings(item, limit)
+		}
+		return shortened
+	case []any:
+		shortened := make([]any, len(v))
+		for i, item := range v {
+			shortened[i] = shortenStrings(item, limit)
+		}
+		return shortened
+	default:
+		return v
+	}
+}
+
+// truncateBytes обрезает текст до limit байт, не разрывая символы UTF-8
+func truncateBytes(text string, limit int) (string, bool) {
+	if len(text) <= limit {
+		return text, false
+	}
+	return strings.ToValidUTF8(text[:limit], ""), true
+}
@@ -0,0 +1,51 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"LLM_Chat/internal/storage/models"
+	"LLM_Chat/pkg/llm"
+
+	"github.com/google/uuid"
+)
+
+// saveToolMessages сохраняет вызовы инструментов ответа сообщениями с ролью tool.
+// Время сообщения - начало вызова, поэтому в истории они стоят между вопросом и ответом.
+// В контекст LLM такие сообщения не попадают, а сжимаются вместе с диалогом (см. interfaces.MessageStore).
+func (s *Service) saveToolMessages(ctx context.Context, sessionID string, calls []llm.ToolCallTrace) error {
+	for _, call := range calls {
+		msg, err := toolMessage(sessionID, call)
+		if err != nil {
+			return err
+		}
+		if err := s.messageStore.SaveMessage(ctx, msg); err != nil {
+			return fmt.Errorf("failed to save tool message: %w", err)
+		}
+	}
+	return nil
+}
+
+// toolMessage сообщение вызова инструмента: результат в тексте, аргументы и итог в метаданных
+func toolMessage(sessionID string, call llm.ToolCallTrace) (models.Message, error) {
+	result := call.Result
+	if result == nil {
+		result = map[string]any{"error": call.Error}
+	}
+	content, err := json.Marshal(result)
+	if err != nil {
+		return models.Message{}, fmt.Errorf("failed to encode result of tool %s: %w", call.Name, err)
+	}
+
+	msg := models.NewToolMessage(sessionID, string(content), call.Name, call.ID)
+	msg.ID = uuid.New().String()
+	msg.Timestamp = call.StartedAt
+	msg.Metadata.ToolCall = &models.ToolCallDetails{
+		Arguments:  call.Arguments,
+		Success:    call.Error == "",
+		Error:      call.Error,
+		DurationMs: call.DurationMs,
+	}
+	return msg, nil
+}
@@ -0,0 +1,107 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"LLM_Chat/pkg/llm"
+)
+
+// toolCallingLLM модель, которая перед ответом вызвала два инструмента, второй - с ошибкой
+type toolCallingLLM struct {
+	recordingLLM
+}
+
+func (c *toolCallingLLM) ChatCompletion(ctx context.Context, messages []llm.Message) (*llm.ChatResponse, error) {
+	resp, err := c.recordingLLM.ChatCompletion(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	// Вызовы начались до сохранения ответа, второй - после первого
+	first := time.Now()
+	time.Sleep(time.Millisecond)
+	second := time.Now()
+
+	resp.Iterations = 2
+	resp.ToolCalls = []llm.ToolCallTrace{
+		{
+			ID:         "call_1",
+			Name:       "get_weather",
+			Arguments:  map[string]any{"city": "Москва"},
+			Result:     map[string]any{"temperature": float64(21)},
+			DurationMs: 120,
+			StartedAt:  first,
+		},
+		{
+			ID:         "call_2",
+			Name:       "get_forecast",
+			Arguments:  map[string]any{"days": float64(3)},
+			Error:      "forecast service unavailable",
+			DurationMs: 40,
+			StartedAt:  second,
+		},
+	}
+	return resp, nil
+}
+
+func TestToolCallsStoredAsMessages(t *testing.T) {
+	ctx := context.Background()
+	service, store := newTestService(t, &toolCallingLLM{recordingLLM{reply: "В Москве +21"}}, testChatConfig())
+	if err := store.CreateSession(ctx, "s1"); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := service.ProcessMessage(ctx, ProcessMessageRequest{SessionID: "s1", Message: "Какая погода?"})
+	if err != nil {
+		t.Fatalf("ProcessMessage: %v", err)
+	}
+	if len(resp.ToolCalls) != 2 {
+		t.Errorf("response tool calls = %d, want 2", len(resp.ToolCalls))
+	}
+
+	history, total, err := service.GetHistory(ctx, "s1", 10, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roles := make([]string, len(history))
+	for i, msg := range history {
+		roles[i] = msg.Role
+	}
+	// Вызовы инструментов сохраняются между вопросом и ответом
+	if total != 4 || len(roles) != 4 || roles[0] != "user" || roles[1] != "tool" || roles[2] != "tool" || roles[3] != "assistant" {
+		t.Fatalf("history roles = %v (total %d), want [user tool tool assistant]", roles, total)
+	}
+
+	weather := history[1]
+	if weather.ToolName != "get_weather" || weather.ToolCallID != "call_1" || !weather.IsToolCall() {
+		t.Errorf("tool message = %+v", weather)
+	}
+	var result map[string]any
+	if err := json.Unmarshal([]byte(weather.Content), &result); err != nil || result["temperature"] != float64(21) {
+		t.Errorf("tool result = %q, want the JSON result", weather.Content)
+	}
+	details := weather.Metadata.ToolCall
+	if details == nil || !details.Success || details.DurationMs != 120 || details.Arguments["city"] != "Москва" {
+		t.Errorf("tool details = %+v", details)
+	}
+
+	failed := history[2]
+	if failed.Metadata.ToolCall == nil || failed.Metadata.ToolCall.Success || failed.Metadata.ToolCall.Error != "forecast service unavailable" {
+		t.Errorf("failed tool details = %+v", failed.Metadata.ToolCall)
+	}
+	if err := json.Unmarshal([]byte(failed.Content), &result); err != nil || result["error"] != "forecast service unavailable" {
+		t.Errorf("failed tool content = %q", failed.Content)
+	}
+
+	// Без include_tools история содержит только диалог
+	history, total, err = service.GetHistory(ctx, "s1", 10, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(history) != 2 {
+		t.Errorf("history without tools = %d of %d, want 2", len(history), total)
+	}
+}
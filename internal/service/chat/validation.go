@@ -2,7 +2,10 @@ package chat
 
 import (
 	"errors"
+	"fmt"
 	"strings"
+
+	"LLM_Chat/pkg/llm"
 )
 
 var (
@@ -10,29 +13,291 @@ var (
 	ErrEmptyMessage     = errors.New("message cannot be empty")
 	ErrMessageTooLong   = errors.New("message is too long")
 	ErrInvalidSessionID = errors.New("invalid session ID format")
+
+	// ErrAssistantPrefixTooLong начало ответа ассистента длиннее MaxMessageLength
+	ErrAssistantPrefixTooLong = errors.New("assistant prefix is too long")
+
+	// ErrInvalidStopSequences стоп-последовательностей больше MaxStopSequences, есть пустые или слишком длинные
+	ErrInvalidStopSequences = errors.New("invalid stop sequences")
+
+	// ErrInvalidMaxOutputTokens max_output_tokens отрицательный или превышает предел модели или сервера
+	ErrInvalidMaxOutputTokens = errors.New("invalid max output tokens")
+
+	// ErrInvalidMaxIterations max_iterations отрицательный или превышает chat.max_iterations_limit
+	ErrInvalidMaxIterations = errors.New("invalid max iterations")
+
+	// ErrInvalidResourceURIs ресурсов больше MaxResourceURIs, есть пустые или повторяющиеся URI
+	ErrInvalidResourceURIs = errors.New("invalid resource URIs")
+
+	// ErrInvalidToolMode tool_mode не входит в auto, any, none
+	ErrInvalidToolMode = errors.New("invalid tool mode")
 )
 
 const (
-	MaxMessageLength   = 10000 // Максимальная длина сообщения
-	MaxSessionIDLength = 100   // Максимальная длина session ID
+	MaxMessageLength      = 10000 // Максимальная длина сообщения
+	MaxSessionIDLength    = 100   // Максимальная длина session ID
+	MaxStopSequences      = 4     // Максимум стоп-последовательностей в запросе
+	MaxStopSequenceLength = 64    // Максимальная длина стоп-последовательности в байтах
+	MaxResourceURIs       = 10    // Максимум ресурсов MCP в запросе
 )
 
+// Коды ошибок валидации полей
+const (
+	ValidationCodeRequired = "required"
+	ValidationCodeTooLong  = "too_long"
+	ValidationCodeInvalid  = "invalid"
+
+	// ValidationCodeUnsupported параметр не поддерживается текущим провайдером
+	ValidationCodeUnsupported = "unsupported"
+)
+
+// ValidationError ошибка валидации конкретного поля запроса.
+// errors.Is сопоставляет её с исходной ошибкой (ErrEmptyMessage и т.п.).
+type ValidationError struct {
+	Field   string // имя поля в JSON запросе
+	Code    string
+	Message string
+	Err     error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationErrors набор ошибок валидации полей одного запроса
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, fieldErr := range e {
+		errs[i] = fieldErr
+	}
+	return errs
+}
+
+// ValidateProcessMessageRequest проверяет запрос и возвращает ValidationErrors со всеми ошибками полей
 func ValidateProcessMessageRequest(req ProcessMessageRequest) error {
+	var errs ValidationErrors
+
 	if strings.TrimSpace(req.SessionID) == "" {
-		return ErrEmptySessionID
+		errs = append(errs, &ValidationError{
+			Field:   "session_id",
+			Code:    ValidationCodeRequired,
+			Message: ErrEmptySessionID.Error(),
+			Err:     ErrEmptySessionID,
+		})
+	} else if len(req.SessionID) > MaxSessionIDLength {
+		errs = append(errs, &ValidationError{
+			Field: "session_id",
+			Code:  ValidationCodeInvalid,
+			Message: fmt.Sprintf("%s: length %d exceeds limit of %d",
+				ErrInvalidSessionID, len(req.SessionID), MaxSessionIDLength),
+			Err: ErrInvalidSessionID,
+		})
 	}
 
-	if len(req.SessionID) > MaxSessionIDLength {
-		return ErrInvalidSessionID
+	if strings.TrimSpace(req.Message) == "" {
+		errs = append(errs, &ValidationError{
+			Field:   "message",
+			Code:    ValidationCodeRequired,
+			Message: ErrEmptyMessage.Error(),
+			Err:     ErrEmptyMessage,
+		})
+	} else if len(req.Message) > MaxMessageLength {
+		errs = append(errs, &ValidationError{
+			Field: "message",
+			Code:  ValidationCodeTooLong,
+			Message: fmt.Sprintf("%s: %d bytes, limit is %d",
+				ErrMessageTooLong, len(req.Message), MaxMessageLength),
+			Err: ErrMessageTooLong,
+		})
 	}
 
-	if strings.TrimSpace(req.Message) == "" {
-		return ErrEmptyMessage
+	if len(req.AssistantPrefix) > MaxMessageLength {
+		errs = append(errs, &ValidationError{
+			Field: "assistant_prefix",
+			Code:  ValidationCodeTooLong,
+			Message: fmt.Sprintf("%s: %d bytes, limit is %d",
+				ErrAssistantPrefixTooLong, len(req.AssistantPrefix), MaxMessageLength),
+			Err: ErrAssistantPrefixTooLong,
+		})
+	}
+
+	if req.MaxIterations < 0 {
+		errs = append(errs, &ValidationError{
+			Field:   "max_iterations",
+			Code:    ValidationCodeInvalid,
+			Message: fmt.Sprintf("%s: must not be negative, got %d", ErrInvalidMaxIterations, req.MaxIterations),
+			Err:     ErrInvalidMaxIterations,
+		})
+	}
+
+	if err := validateStopSequences(req.Stop); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := validateResourceURIs(req.ResourceURIs); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(req.PromptArgs) > 0 && req.PromptName == "" {
+		errs = append(errs, &ValidationError{
+			Field:   "prompt_args",
+			Code:    ValidationCodeInvalid,
+			Message: ErrPromptArgsWithoutName.Error(),
+			Err:     ErrPromptArgsWithoutName,
+		})
+	}
+
+	switch req.ToolMode {
+	case "", llm.ToolModeAuto, llm.ToolModeAny, llm.ToolModeNone:
+	default:
+		errs = append(errs, &ValidationError{
+			Field: "tool_mode",
+			Code:  ValidationCodeInvalid,
+			Message: fmt.Sprintf("%s: %q, expected %s, %s or %s",
+				ErrInvalidToolMode, req.ToolMode, llm.ToolModeAuto, llm.ToolModeAny, llm.ToolModeNone),
+			Err: ErrInvalidToolMode,
+		})
 	}
 
-	if len(req.Message) > MaxMessageLength {
-		return ErrMessageTooLong
+	if req.MaxOutputTokens < 0 {
+		errs = append(errs, &ValidationError{
+			Field:   "max_output_tokens",
+			Code:    ValidationCodeInvalid,
+			Message: fmt.Sprintf("%s: must not be negative, got %d", ErrInvalidMaxOutputTokens, req.MaxOutputTokens),
+			Err:     ErrInvalidMaxOutputTokens,
+		})
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateStopSequences проверяет количество и длину стоп-последовательностей
+func validateStopSequences(stops []string) *ValidationError {
+	invalid := func(format string, args ...any) *ValidationError {
+		return &ValidationError{
+			Field:   "stop",
+			Code:    ValidationCodeInvalid,
+			Message: fmt.Sprintf("%s: %s", ErrInvalidStopSequences, fmt.Sprintf(format, args...)),
+			Err:     ErrInvalidStopSequences,
+		}
+	}
+
+	if len(stops) > MaxStopSequences {
+		return invalid("%d sequences, limit is %d", len(stops), MaxStopSequences)
+	}
+
+	for i, stop := range stops {
+		if stop == "" {
+			return invalid("sequence %d is empty", i)
+		}
+		if len(stop) > MaxStopSequenceLength {
+			return invalid("sequence %d is %d bytes, limit is %d", i, len(stop), MaxStopSequenceLength)
+		}
+	}
+
+	return nil
+}
+
+// validateResourceURIs проверяет количество ресурсов и отсутствие пустых и повторяющихся URI
+func validateResourceURIs(uris []string) *ValidationError {
+	invalid := func(format string, args ...any) *ValidationError {
+		return &ValidationError{
+			Field:   "resource_uris",
+			Code:    ValidationCodeInvalid,
+			Message: fmt.Sprintf("%s: %s", ErrInvalidResourceURIs, fmt.Sprintf(format, args...)),
+			Err:     ErrInvalidResourceURIs,
+		}
+	}
+
+	if len(uris) > MaxResourceURIs {
+		return invalid("%d resources, limit is %d", len(uris), MaxResourceURIs)
+	}
+
+	seen := make(map[string]bool, len(uris))
+	for i, uri := range uris {
+		if strings.TrimSpace(uri) == "" {
+			return invalid("uri %d is empty", i)
+		}
+		if seen[uri] {
+			return invalid("uri %s is repeated", uri)
+		}
+		seen[uri] = true
 	}
 
 	return nil
 }
+
+// validateMaxIterations проверяет запрошенный лимит итераций по пределу из конфигурации
+func validateMaxIterations(req ProcessMessageRequest, limit int) error {
+	if req.MaxIterations <= limit {
+		return nil
+	}
+
+	return ValidationErrors{{
+		Field:   "max_iterations",
+		Code:    ValidationCodeInvalid,
+		Message: fmt.Sprintf("%s: %d exceeds limit of %d", ErrInvalidMaxIterations, req.MaxIterations, limit),
+		Err:     ErrInvalidMaxIterations,
+	}}
+}
+
+// validateAssistantPrefix проверяет, что провайдер умеет продолжать ответ с заданного начала
+func validateAssistantPrefix(req ProcessMessageRequest, client llm.LLMClient) error {
+	if req.AssistantPrefix == "" {
+		return nil
+	}
+
+	if supporter, ok := client.(llm.PrefillSupporter); ok && supporter.SupportsPrefill() {
+		return nil
+	}
+
+	return ValidationErrors{{
+		Field:   "assistant_prefix",
+		Code:    ValidationCodeUnsupported,
+		Message: fmt.Sprintf("%s: provider '%s'", llm.ErrPrefillNotSupported, client.GetProviderName()),
+		Err:     llm.ErrPrefillNotSupported,
+	}}
+}
+
+// validateMaxOutputTokens проверяет запрошенную длину ответа по пределу сервера и пределу модели,
+// если провайдер его знает
+func validateMaxOutputTokens(req ProcessMessageRequest, model string, serverLimit int, client llm.LLMClient) error {
+	if req.MaxOutputTokens <= 0 {
+		return nil
+	}
+
+	limit, source := serverLimit, "server limit"
+	if limiter, ok := client.(llm.OutputTokenLimiter); ok {
+		if modelLimit := limiter.OutputTokenLimit(model); modelLimit > 0 && modelLimit < limit {
+			limit, source = modelLimit, fmt.Sprintf("limit of model %s", model)
+		}
+	}
+
+	if req.MaxOutputTokens <= limit {
+		return nil
+	}
+
+	return ValidationErrors{{
+		Field:   "max_output_tokens",
+		Code:    ValidationCodeInvalid,
+		Message: fmt.Sprintf("%s: %d exceeds %s of %d", ErrInvalidMaxOutputTokens, req.MaxOutputTokens, source, limit),
+		Err:     ErrInvalidMaxOutputTokens,
+	}}
+}
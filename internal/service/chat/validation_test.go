@@ -0,0 +1,28 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestProcessMessageStreamValidatesBeforeOpeningStream(t *testing.T) {
+	client := &recordingLLM{reply: "ok"}
+	service, _ := newTestService(t, client, testChatConfig())
+
+	// Ошибка возвращается сразу, поток не регистрируется
+	stream, err := service.ProcessMessageStream(context.Background(), ProcessMessageRequest{SessionID: "s1", Message: "  "})
+	var validationErrs ValidationErrors
+	if !errors.As(err, &validationErrs) || !errors.Is(err, ErrEmptyMessage) {
+		t.Fatalf("err = %v, want ValidationErrors with ErrEmptyMessage", err)
+	}
+	if stream != nil {
+		t.Error("stream was opened for an invalid request")
+	}
+	if active := service.Streams().Active(); active != 0 {
+		t.Errorf("active streams = %d, want 0", active)
+	}
+	if len(client.requests) != 0 {
+		t.Error("invalid request reached the model")
+	}
+}
@@ -0,0 +1,54 @@
+package context
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"LLM_Chat/internal/service/summary"
+	"LLM_Chat/internal/storage/memory"
+	"LLM_Chat/internal/storage/models"
+
+	"go.uber.org/zap"
+)
+
+// failingApplyStore хранилище, в котором сжатие обрывается на записи результата
+type failingApplyStore struct {
+	*memory.MemoryStorage
+}
+
+func (s failingApplyStore) ApplyCompression(ctx context.Context, compression models.Compression) error {
+	return errors.New("connection lost")
+}
+
+func TestFailedCompressionLeavesNoPartialState(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	shrink := &shrinkStub{}
+	summaryService := summary.NewService(store, shrink, nil, nil, summary.DefaultConfig(), zap.NewNop())
+	manager := NewManager(failingApplyStore{store}, summaryService, nil, nil, nil, DefaultConfig(), zap.NewNop())
+	seedDialog(t, store, "s1", numberedDialog(20)...)
+
+	if _, err := manager.Compress(ctx, "s1", CompressOptions{Force: true}); err == nil {
+		t.Fatal("Compress succeeded with a failing store")
+	}
+	// Контекст строится из несжатых сообщений
+	resp, err := manager.BuildContext(ctx, ContextRequest{SessionID: "s1"})
+	if err != nil {
+		t.Fatalf("BuildContext: %v", err)
+	}
+	if resp.CompressionInfo.Reason != ReasonCompressionFailed || resp.HasSummary {
+		t.Errorf("compression info = %+v, has summary = %v", resp.CompressionInfo, resp.HasSummary)
+	}
+
+	// Резюме создано моделью, но не сохранено отдельно от пометки источников
+	if shrink.calls() != 2 {
+		t.Errorf("shrink requests = %d, want 2", shrink.calls())
+	}
+	summaries, _ := store.GetActiveSummaries(ctx, "s1", 1)
+	active, _ := store.GetActiveMessages(ctx, "s1")
+	all, _, _ := store.GetMessagesPage(ctx, "s1", 100, 0, true)
+	if len(summaries) != 0 || len(active) != 20 || len(all) != 20 {
+		t.Errorf("partial state: %d summaries, %d active of %d messages", len(summaries), len(active), len(all))
+	}
+}
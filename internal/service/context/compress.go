@@ -0,0 +1,210 @@
+package context
+
+import (
+	"context"
+	"fmt"
+
+	"LLM_Chat/internal/events"
+	"LLM_Chat/internal/service/summary"
+
+	"go.uber.org/zap"
+)
+
+// Сколько последних резюме всегда остаётся несжатыми, в том числе при принудительном сжатии
+const (
+	minKeepSummaries     = 2
+	minKeepBulkSummaries = 1
+)
+
+// CompressOptions параметры Compress
+type CompressOptions struct {
+	// Force снижает пороги сжатия до нуля: сжимается старший уровень, в котором есть элементы
+	// сверх минимума, оставляемого несжатым (MinMessagesInWindow сообщений, 2 резюме, 1 bulk резюме)
+	Force bool
+
+	// ForceLevel ограничивает Force одним уровнем (1-3); остальные уровни сжимаются только
+	// по своим порогам. 0 - Force действует на все уровни
+	ForceLevel int
+}
+
+// forced сообщает, снимает ли Force пороги уровня level
+func (o CompressOptions) forced(level int) bool {
+	return o.Force && (o.ForceLevel == 0 || o.ForceLevel == level)
+}
+
+// compressionStep сжатие одного уровня
+type compressionStep struct {
+	level       int
+	reason      string
+	description string
+	trigger     string // порог, превышенный без Force; пустая строка - не превышен
+	forceable   bool   // есть что сжимать при Force
+	items       int
+	countRatio  float64
+	tokenRatio  float64
+	errContext  string
+	compress    func(started func()) (*summary.SummaryResponse, error)
+}
+
+// Compress проверяет пороги и выполняет сжатие, начиная со старшего уровня; уровень, на котором
+// сжимать оказалось нечего, пропускается. Проверка и сжатие выполняются под блокировкой сессии:
+// параллельный запрос дождётся окончания сжатия и увидит уже сжатое состояние.
+func (m *Manager) Compress(ctx context.Context, sessionID string, opts CompressOptions) (*CompressionInfo, error) {
+	unlock, err := m.compressLocks.lock(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire compression lock: %w", err)
+	}
+	defer unlock()
+
+	// Получаем текущее состояние контекста
+	activeMessages, err := m.messageStore.GetActiveMessages(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active messages: %w", err)
+	}
+
+	activeSummaries, err := m.messageStore.GetActiveSummaries(ctx, sessionID, 1) // level 1 summaries
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active summaries: %w", err)
+	}
+
+	bulkSummaries, err := m.messageStore.GetSummariesByLevel(ctx, sessionID, 2) // level 2 summaries
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bulk summaries: %w", err)
+	}
+
+	load := m.measureLoad(ctx, activeMessages, activeSummaries, bulkSummaries)
+
+	steps := []compressionStep{
+		{
+			level:       3,
+			reason:      ReasonDigestCompression,
+			description: "Triggering level 3 compression (bulk summaries -> session digest)",
+			trigger:     m.bulkTrigger(load, len(bulkSummaries)),
+			forceable:   m.config.MaxBulkSummaries > 0 && len(bulkSummaries) > minKeepBulkSummaries,
+			items:       len(bulkSummaries),
+			tokenRatio:  load.bulkTokenRatio,
+			errContext:  "failed to compress bulk summaries",
+			compress: func(started func()) (*summary.SummaryResponse, error) {
+				return m.compressBulkSummaries(ctx, sessionID, bulkSummaries, load.bulkTokens, opts.forced(3), started)
+			},
+		},
+		{
+			level:       2,
+			reason:      ReasonSummaryCompression,
+			description: "Triggering level 2 compression (summaries -> bulk summary)",
+			trigger:     m.summaryTrigger(load, len(activeSummaries)),
+			forceable:   len(activeSummaries) > minKeepSummaries,
+			items:       len(activeSummaries),
+			countRatio:  load.summaryRatio,
+			tokenRatio:  load.summaryTokenRatio,
+			errContext:  "failed to compress summaries",
+			compress: func(started func()) (*summary.SummaryResponse, error) {
+				return m.compressSummaries(ctx, sessionID, activeSummaries, load.summaryTokens, opts.forced(2), started)
+			},
+		},
+		{
+			level:       1,
+			reason:      ReasonMessageCompression,
+			description: "Triggering level 1 compression (messages -> summary)",
+			trigger:     m.messageTrigger(load, len(activeMessages)),
+			forceable:   len(activeMessages) > m.config.MinMessagesInWindow,
+			items:       len(activeMessages),
+			countRatio:  load.messageRatio,
+			tokenRatio:  load.messageTokenRatio,
+			errContext:  "failed to compress messages",
+			compress: func(started func()) (*summary.SummaryResponse, error) {
+				return m.compressMessages(ctx, sessionID, activeMessages, load.messageTokens, opts.forced(1), started)
+			},
+		},
+	}
+
+	for _, step := range steps {
+		trigger := step.trigger
+		if trigger == "" && opts.forced(step.level) && step.forceable {
+			trigger = TriggerForced
+		}
+		if trigger == "" {
+			continue
+		}
+
+		info, err := m.runCompressionStep(sessionID, step, trigger)
+		if err != nil {
+			return nil, err
+		}
+		if info.Triggered {
+			return info, nil
+		}
+	}
+
+	m.logger.Debug("No compression needed",
+		zap.String("session_id", sessionID),
+		zap.Bool("force", opts.Force),
+		zap.Int("active_messages", len(activeMessages)),
+		zap.Int("active_summaries", len(activeSummaries)),
+		zap.Int("bulk_summaries", len(bulkSummaries)),
+		zap.Float64("message_ratio", load.messageRatio),
+		zap.Float64("summary_ratio", load.summaryRatio),
+		zap.Float64("message_token_ratio", load.messageTokenRatio),
+		zap.Float64("summary_token_ratio", load.summaryTokenRatio),
+	)
+
+	return &CompressionInfo{}, nil
+}
+
+// runCompressionStep выполняет сжатие уровня и публикует события compression.*.
+// Если сжимать оказалось нечего, возвращает CompressionInfo с Triggered = false; события
+// при этом не публикуются: compression.started отправляется только перед созданием резюме.
+func (m *Manager) runCompressionStep(sessionID string, step compressionStep, trigger string) (*CompressionInfo, error) {
+	m.logger.Info(step.description,
+		zap.String("session_id", sessionID),
+		zap.Int("active_items", step.items),
+		zap.String("trigger", trigger),
+		zap.Float64("compression_ratio", step.countRatio),
+		zap.Float64("token_ratio", step.tokenRatio),
+	)
+
+	startData := events.CompressionData{
+		Level:   step.level,
+		Reason:  step.reason,
+		Trigger: trigger,
+	}
+	if step.level == 1 {
+		startData.ActiveMessages = step.items
+	} else {
+		startData.ActiveSummaries = step.items
+	}
+	published := false
+	compressionResult, err := step.compress(func() {
+		published = true
+		m.publish(sessionID, events.TypeCompressionStarted, startData)
+	})
+	if err != nil {
+		if published {
+			startData.Error = err.Error()
+			m.publish(sessionID, events.TypeCompressionFailed, startData)
+		}
+		return nil, fmt.Errorf("%s: %w", step.errContext, err)
+	}
+
+	info := &CompressionInfo{
+		Triggered:           compressionResult.SummaryID != "",
+		Reason:              step.reason,
+		Trigger:             trigger,
+		Level:               step.level,
+		MessagesCompressed:  compressionResult.MessagesCompressed,
+		SummariesCompressed: compressionResult.SummariesCompressed,
+		AnchorsCreated:      compressionResult.AnchorsCreated,
+		TokensUsed:          compressionResult.TokensUsed,
+		Duration:            compressionResult.Duration,
+	}
+	if !info.Triggered {
+		m.logger.Debug("Nothing to compress at this level",
+			zap.String("session_id", sessionID),
+			zap.Int("level", step.level),
+		)
+		return info, nil
+	}
+
+	m.publishCompressionFinished(sessionID, info, compressionResult.SummaryID)
+	return info, nil
+}
@@ -0,0 +1,238 @@
+package context
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"LLM_Chat/internal/events"
+	"LLM_Chat/internal/service/summary"
+	"LLM_Chat/internal/storage/memory"
+	"LLM_Chat/internal/storage/models"
+
+	"go.uber.org/zap"
+)
+
+func TestConcurrentBuildContextCompressesOnce(t *testing.T) {
+	ctx := context.Background()
+	manager, store, shrink := newTestManager(t, DefaultConfig(), nil)
+	shrink.delay = 20 * time.Millisecond
+	seedDialog(t, store, "s1", numberedDialog(20)...)
+
+	// Запросы одной сессии приходят одновременно (поток и его повтор)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := manager.BuildContext(ctx, ContextRequest{SessionID: "s1"}); err != nil {
+				t.Errorf("BuildContext: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	summaries, err := store.GetActiveSummaries(ctx, "s1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 || shrink.calls() != 1 {
+		t.Fatalf("summaries = %d, shrink requests = %d; want exactly one", len(summaries), shrink.calls())
+	}
+	active, _ := store.GetActiveMessages(ctx, "s1")
+	if len(active) != 14 {
+		t.Errorf("active messages = %d, want 14", len(active))
+	}
+	if n := len(manager.compressLocks.locks); n != 0 {
+		t.Errorf("compression locks left after the requests: %d", n)
+	}
+}
+
+func TestSessionLockHonoursContext(t *testing.T) {
+	locks := newSessionLocks()
+	unlock, err := locks.lock(context.Background(), "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Другая сессия не ждёт
+	unlockOther, err := locks.lock(context.Background(), "s2")
+	if err != nil {
+		t.Fatalf("lock of another session: %v", err)
+	}
+	unlockOther()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := locks.lock(ctx, "s1"); err != context.DeadlineExceeded {
+		t.Errorf("waiting for a held lock: err = %v, want DeadlineExceeded", err)
+	}
+
+	unlock()
+	unlock() // повторное освобождение ничего не делает
+	if len(locks.locks) != 0 {
+		t.Errorf("locks left: %d", len(locks.locks))
+	}
+}
+
+func TestLongSessionReachesSessionDigest(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.ContextWindowSize = 10
+	cfg.MaxBulkSummaries = 2
+	manager, store, shrink := newTestManager(t, cfg, nil)
+
+	// Диалог растёт по 4 сообщения на запрос
+	levels := make(map[int]int)
+	next := 0
+	for turn := 0; turn < 40 && levels[3] == 0; turn++ {
+		for range 4 {
+			msg := models.NewUserMessage("s1", fmt.Sprintf("message %d", next))
+			if next%2 == 1 {
+				msg = models.NewAssistantMessage("s1", fmt.Sprintf("message %d", next))
+			}
+			msg.ID = fmt.Sprintf("s1-m%03d", next)
+			msg.Timestamp = baseTime.Add(time.Duration(next) * time.Minute)
+			if err := store.SaveMessage(ctx, msg); err != nil {
+				t.Fatal(err)
+			}
+			next++
+		}
+
+		resp, err := manager.BuildContext(ctx, ContextRequest{SessionID: "s1"})
+		if err != nil {
+			t.Fatalf("BuildContext: %v", err)
+		}
+		if info := resp.CompressionInfo; info.Triggered {
+			levels[info.Level]++
+		}
+	}
+	if levels[1] == 0 || levels[2] == 0 || levels[3] == 0 {
+		t.Fatalf("compression levels reached = %v, want 1, 2 and 3", levels)
+	}
+
+	digests, _ := store.GetActiveSummaries(ctx, "s1", 3)
+	bulk, _ := store.GetSummariesByLevel(ctx, "s1", 2)
+	if len(digests) != 1 || len(bulk) > cfg.MaxBulkSummaries {
+		t.Fatalf("digests = %d, bulk summaries = %d", len(digests), len(bulk))
+	}
+	if shrink.calls() != levels[1]+levels[2]+levels[3] {
+		t.Errorf("shrink requests = %d, compressions = %v", shrink.calls(), levels)
+	}
+
+	// Дайджест заменяет сжатые bulk резюме: его источники - резюме второго уровня
+	sources, err := store.GetSummarySources(ctx, "s1", digests[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources.Summaries) < 2 {
+		t.Errorf("digest sources = %d summaries, want at least 2", len(sources.Summaries))
+	}
+	for _, s := range sources.Summaries {
+		if s.SummaryLevel != 2 {
+			t.Errorf("digest source %s has level %d, want 2", s.ID, s.SummaryLevel)
+		}
+	}
+}
+
+// eventRecorder запоминает типы опубликованных событий
+type eventRecorder struct {
+	mu    sync.Mutex
+	types []events.Type
+}
+
+func (r *eventRecorder) Publish(event events.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.types = append(r.types, event.Type)
+}
+
+func (r *eventRecorder) published() []events.Type {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]events.Type(nil), r.types...)
+}
+
+func TestCompressionEventsOnlyWhenSummaryIsCreated(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	shrink := &shrinkStub{}
+	recorder := &eventRecorder{}
+	summaryService := summary.NewService(store, shrink, nil, nil, summary.DefaultConfig(), zap.NewNop())
+	manager := NewManager(store, summaryService, nil, nil, recorder, DefaultConfig(), zap.NewNop())
+
+	// Порог сообщений превышен, но окно оставляет 14 сообщений несжатыми: 10 сообщений
+	// сжимать нечего, из 16 сжимаются лишь 2 - меньше минимума для резюме
+	for _, n := range []int{10, 16} {
+		sessionID := fmt.Sprintf("short-%d", n)
+		seedDialog(t, store, sessionID, numberedDialog(n)...)
+
+		info, err := manager.Compress(ctx, sessionID, CompressOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Triggered {
+			t.Errorf("%d messages: compression triggered: %+v", n, info)
+		}
+	}
+	if published := recorder.published(); len(published) != 0 || shrink.calls() != 0 {
+		t.Fatalf("events = %v, shrink requests = %d; want none when nothing is compressed", published, shrink.calls())
+	}
+
+	seedDialog(t, store, "long", numberedDialog(20)...)
+	info, err := manager.Compress(ctx, "long", CompressOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Triggered {
+		t.Fatalf("20 messages were not compressed: %+v", info)
+	}
+	want := []events.Type{events.TypeCompressionStarted, events.TypeCompressionFinished}
+	if published := recorder.published(); fmt.Sprint(published) != fmt.Sprint(want) {
+		t.Errorf("events = %v, want %v", published, want)
+	}
+}
+
+func TestTokenTriggerBelowCountThreshold(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.MaxContextTokens = 1000
+	cfg.MinMessagesInWindow = 2
+	manager, store, _ := newTestManager(t, cfg, nil)
+
+	// 6 сообщений из 20 - ровно порог по числу (0.3), но около 900 токенов из 1000 - выше порога 0.7
+	contents := make([]string, 6)
+	for i := range contents {
+		contents[i] = fmt.Sprintf("%d %s", i, strings.Repeat("word ", 120))
+	}
+	seedDialog(t, store, "s1", contents...)
+
+	info, err := manager.GetContextInfo(ctx, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.MessageRatio > cfg.MessageCompressionRatio || info.MessageTokenRatio <= cfg.MessageTokenRatio {
+		t.Fatalf("message ratio = %.2f, token ratio = %.2f; want only the token ratio over its threshold",
+			info.MessageRatio, info.MessageTokenRatio)
+	}
+	if !info.ShouldCompress || info.CompressionLevel != 1 || info.CompressionTrigger != TriggerTokens {
+		t.Errorf("context info = %+v, want level 1 compression by tokens", info)
+	}
+
+	result, err := manager.Compress(ctx, "s1", CompressOptions{})
+	if err != nil {
+		t.Fatalf("Compress: %v", err)
+	}
+	if !result.Triggered || result.Level != 1 || result.Trigger != TriggerTokens {
+		t.Fatalf("compression = %+v, want level 1 by tokens", result)
+	}
+
+	// Несжатыми остаются сообщения, которые помещаются в половину токенного порога
+	active, _ := store.GetActiveMessages(ctx, "s1")
+	if len(active) != 2 || result.MessagesCompressed != 4 {
+		t.Errorf("active messages = %d, compressed = %d; want 2 and 4", len(active), result.MessagesCompressed)
+	}
+}
@@ -0,0 +1,50 @@
+package context
+
+import (
+	"context"
+	"fmt"
+
+	"LLM_Chat/internal/events"
+	"LLM_Chat/internal/storage/models"
+
+	"go.uber.org/zap"
+)
+
+// GetSummarySources возвращает резюме сессии и сжатые в него источники
+func (m *Manager) GetSummarySources(ctx context.Context, sessionID, summaryID string) (*models.SummarySources, error) {
+	return m.messageStore.GetSummarySources(ctx, sessionID, summaryID)
+}
+
+// ExpandSummary отменяет сжатие резюме. Контекст строится из активных сообщений и резюме,
+// поэтому следующий BuildContext видит сессию так, будто этого сжатия не было; если пороги
+// по-прежнему превышены, автоматическое сжатие сработает снова.
+// Выполняется под блокировкой сжатия сессии, чтобы не развернуть резюме, которое как раз сжимается.
+func (m *Manager) ExpandSummary(ctx context.Context, sessionID, summaryID string) (*models.SummaryExpansion, error) {
+	unlock, err := m.compressLocks.lock(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire compression lock: %w", err)
+	}
+	defer unlock()
+
+	expansion, err := m.messageStore.ExpandSummary(ctx, sessionID, summaryID)
+	if err != nil {
+		return nil, err
+	}
+
+	m.logger.Info("Summary expanded",
+		zap.String("session_id", sessionID),
+		zap.String("summary_id", summaryID),
+		zap.Int("level", expansion.SummaryLevel),
+		zap.Int("messages_restored", expansion.MessagesRestored),
+		zap.Int("summaries_restored", expansion.SummariesRestored),
+	)
+
+	m.publish(sessionID, events.TypeSummaryExpanded, events.ExpandData{
+		SummaryID:         expansion.SummaryID,
+		Level:             expansion.SummaryLevel,
+		MessagesRestored:  expansion.MessagesRestored,
+		SummariesRestored: expansion.SummariesRestored,
+	})
+
+	return expansion, nil
+}
@@ -0,0 +1,97 @@
+package context
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"LLM_Chat/internal/storage/interfaces"
+	"LLM_Chat/internal/storage/models"
+)
+
+func TestExpandSummaryRebuildsContext(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.MinMessagesInWindow = 2
+	manager, store, shrink := newTestManager(t, cfg, nil)
+	seedDialog(t, store, "s1", numberedDialog(6)...)
+
+	// Порог по числу не превышен: сжатие только принудительное, и после отмены оно не повторится
+	result, err := manager.Compress(ctx, "s1", CompressOptions{Force: true})
+	if err != nil || !result.Triggered || result.MessagesCompressed != 4 {
+		t.Fatalf("Compress = %+v, %v; want 4 messages compressed", result, err)
+	}
+	summaries, _ := store.GetActiveSummaries(ctx, "s1", 1)
+	if len(summaries) != 1 {
+		t.Fatalf("summaries = %d, want 1", len(summaries))
+	}
+
+	resp, err := manager.BuildContext(ctx, ContextRequest{SessionID: "s1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := contextText(resp.Messages); !strings.Contains(text, "Резюме 1") || strings.Contains(text, "message 0\n") {
+		t.Fatalf("context before expanding:\n%s", text)
+	}
+
+	expansion, err := manager.ExpandSummary(ctx, "s1", summaries[0].ID)
+	if err != nil {
+		t.Fatalf("ExpandSummary: %v", err)
+	}
+	if expansion.MessagesRestored != 4 || expansion.SummaryLevel != 1 {
+		t.Errorf("expansion = %+v", expansion)
+	}
+
+	// Контекст снова содержит исходные сообщения и не содержит резюме
+	resp, err = manager.BuildContext(ctx, ContextRequest{SessionID: "s1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := contextText(resp.Messages)
+	if strings.Contains(text, "Резюме 1") {
+		t.Errorf("expanded summary is still in the context:\n%s", text)
+	}
+	for i := range 6 {
+		if !strings.Contains(text, fmt.Sprintf("message %d\n", i)) {
+			t.Errorf("context lacks message %d:\n%s", i, text)
+		}
+	}
+	if shrink.calls() != 1 {
+		t.Errorf("shrink requests = %d, want 1: the expanded messages were compressed again", shrink.calls())
+	}
+}
+
+func TestExpandSummaryCompressedIntoBulk(t *testing.T) {
+	ctx := context.Background()
+	manager, store, _ := newTestManager(t, DefaultConfig(), nil)
+	for i := range 5 {
+		err := store.SaveSummary(ctx, models.Summary{
+			ID:           fmt.Sprintf("s1-l1-%d", i),
+			SessionID:    "s1",
+			SummaryText:  fmt.Sprintf("summary %d", i),
+			SummaryLevel: 1,
+			UpdatedAt:    baseTime.Add(time.Duration(i) * time.Second),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := manager.Compress(ctx, "s1", CompressOptions{Force: true, ForceLevel: 2})
+	if err != nil || !result.Triggered || result.Level != 2 || result.SummariesCompressed != 3 {
+		t.Fatalf("Compress = %+v, %v; want 3 summaries compressed into a bulk summary", result, err)
+	}
+
+	// Резюме внутри bulk резюме не разворачивается, состояние сессии не меняется
+	if _, err := manager.ExpandSummary(ctx, "s1", "s1-l1-0"); !errors.Is(err, interfaces.ErrSummaryCompressed) {
+		t.Fatalf("expanding a summary inside a bulk summary: err = %v, want ErrSummaryCompressed", err)
+	}
+	level1, _ := store.GetActiveSummaries(ctx, "s1", 1)
+	bulk, _ := store.GetSummariesByLevel(ctx, "s1", 2)
+	if len(level1) != 2 || len(bulk) != 1 {
+		t.Errorf("level 1 = %d, bulk = %d; want 2 and 1", len(level1), len(bulk))
+	}
+}
@@ -0,0 +1,100 @@
+package context
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"LLM_Chat/internal/storage/memory"
+	"LLM_Chat/internal/storage/models"
+)
+
+// update перезаписывает эталонные файлы testdata: go test -run Golden -update
+var update = flag.Bool("update", false, "update golden files")
+
+// assertGolden сравнивает текст с эталоном testdata/name
+func assertGolden(t *testing.T, name, got string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("golden file: %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("context differs from %s:\n--- got\n%s\n--- want\n%s", path, got, want)
+	}
+}
+
+func TestBuildContextGolden(t *testing.T) {
+	custom := DefaultConfig()
+	custom.SummaryRole = "user"
+	custom.SummaryTemplate = "Справка о начале разговора:\n\n" + SummaryPlaceholder
+
+	tests := []struct {
+		golden string
+		config Config
+	}{
+		{"context_default.golden", DefaultConfig()},
+		{"context_custom_role.golden", custom},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			ctx := context.Background()
+			manager, store, _ := newTestManager(t, tt.config, nil)
+			seedSummarizedSession(t, store, "s1")
+
+			resp, err := manager.BuildContext(ctx, ContextRequest{SessionID: "s1", SystemPrompt: "Ты помощник по администрированию.", IncludeSystem: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertGolden(t, tt.golden, contextText(resp.Messages))
+
+			// Повторы якорей без учёта регистра отбрасываются
+			if got := len(resp.ActiveAnchors); got != 3 {
+				t.Errorf("active anchors = %v, want 3", resp.ActiveAnchors)
+			}
+		})
+	}
+}
+
+// seedSummarizedSession сессия с bulk резюме, двумя активными резюме и свежими сообщениями
+func seedSummarizedSession(t *testing.T, store *memory.MemoryStorage, sessionID string) {
+	t.Helper()
+
+	summaries := []models.Summary{
+		{
+			ID:           "bulk1",
+			SummaryText:  "Пользователь выбирал хостинг и остановился на VPS.",
+			Anchors:      []string{"Выбор хостинга", "Сравнение тарифов"},
+			SummaryLevel: 2,
+		},
+		{
+			ID:           "sum1",
+			SummaryText:  "Установили nginx и открыли порт 80.",
+			Anchors:      []string{"Установка nginx", " ", "выбор хостинга"},
+			SummaryLevel: 1,
+		},
+		{
+			ID:           "sum2",
+			SummaryText:  "Обсудили резервное копирование.",
+			SummaryLevel: 1,
+		},
+	}
+	for i, s := range summaries {
+		s.SessionID = sessionID
+		s.UpdatedAt = baseTime.Add(time.Duration(i) * time.Second)
+		if err := store.SaveSummary(context.Background(), s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seedDialog(t, store, sessionID, "Как включить HTTPS?", "Используйте certbot.", "Спасибо!")
+}
@@ -0,0 +1,111 @@
+package context
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"LLM_Chat/internal/service/summary"
+	"LLM_Chat/internal/storage/memory"
+	"LLM_Chat/internal/storage/models"
+	"LLM_Chat/pkg/llm"
+
+	"go.uber.org/zap"
+)
+
+var baseTime = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// shrinkStub модель сжатия: отвечает JSON с якорями и резюме и запоминает запросы
+type shrinkStub struct {
+	mu       sync.Mutex
+	requests [][]llm.Message
+	err      error         // ошибка каждого запроса
+	delay    time.Duration // время ответа
+}
+
+func (s *shrinkStub) ChatCompletion(ctx context.Context, messages []llm.Message) (*llm.ChatResponse, error) {
+	s.mu.Lock()
+	s.requests = append(s.requests, messages)
+	n := len(s.requests)
+	s.mu.Unlock()
+
+	time.Sleep(s.delay)
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	output, _ := json.Marshal(map[string]any{
+		"anchors": []string{fmt.Sprintf("Тема %d", n)},
+		"summary": fmt.Sprintf("Резюме %d", n),
+	})
+	return &llm.ChatResponse{
+		Choices: []llm.Choice{{Message: llm.Message{Role: "assistant", Content: string(output)}}},
+		Usage:   llm.Usage{TotalTokens: 10},
+	}, nil
+}
+
+func (s *shrinkStub) ChatCompletionStream(ctx context.Context, messages []llm.Message) (<-chan llm.StreamChunk, error) {
+	return nil, fmt.Errorf("streaming is not supported by the stub")
+}
+
+func (s *shrinkStub) GetProviderName() string { return "stub" }
+
+func (s *shrinkStub) GetSupportedModels() []string { return nil }
+
+func (s *shrinkStub) calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+// newTestManager менеджер контекста над хранилищем в памяти и настоящим сервисом резюме
+func newTestManager(t *testing.T, cfg Config, embedder Embedder) (*Manager, *memory.MemoryStorage, *shrinkStub) {
+	t.Helper()
+
+	store := memory.New()
+	shrink := &shrinkStub{}
+	summaryService := summary.NewService(store, shrink, nil, nil, summary.DefaultConfig(), zap.NewNop())
+	return NewManager(store, summaryService, embedder, nil, nil, cfg, zap.NewNop()), store, shrink
+}
+
+// seedDialog сохраняет сообщения с возрастающим временем создания; чётные - от пользователя
+func seedDialog(t *testing.T, store *memory.MemoryStorage, sessionID string, contents ...string) []models.Message {
+	t.Helper()
+
+	messages := make([]models.Message, len(contents))
+	for i, content := range contents {
+		msg := models.NewUserMessage(sessionID, content)
+		if i%2 == 1 {
+			msg = models.NewAssistantMessage(sessionID, content)
+		}
+		msg.ID = fmt.Sprintf("%s-m%03d", sessionID, i)
+		msg.Timestamp = baseTime.Add(time.Duration(i) * time.Minute)
+		if err := store.SaveMessage(context.Background(), msg); err != nil {
+			t.Fatalf("SaveMessage: %v", err)
+		}
+		messages[i] = msg
+	}
+	return messages
+}
+
+// numberedDialog содержимое n сообщений "message 0" ... "message n-1"
+func numberedDialog(n int) []string {
+	contents := make([]string, n)
+	for i := range contents {
+		contents[i] = fmt.Sprintf("message %d", i)
+	}
+	return contents
+}
+
+// contextText сообщения контекста построчно в виде "role: content"
+func contextText(messages []llm.Message) string {
+	var b strings.Builder
+	for _, msg := range messages {
+		fmt.Fprintf(&b, "%s: %s\n", msg.Role, msg.Content)
+	}
+	return b.String()
+}
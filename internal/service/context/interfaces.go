@@ -2,13 +2,27 @@ package context
 
 import (
 	"context"
+
+	"LLM_Chat/internal/service/summary"
+	"LLM_Chat/internal/storage/models"
 )
 
 // ContextManager определяет интерфейс для управления контекстом
 type ContextManager interface {
+	// BuildContext сжимает контекст по порогам (Compress) и собирает сообщения для LLM
 	BuildContext(ctx context.Context, req ContextRequest) (*ContextResponse, error)
+	// Compress проверяет пороги и выполняет сжатие; Force сжимает независимо от порогов
+	Compress(ctx context.Context, sessionID string, opts CompressOptions) (*CompressionInfo, error)
+	// GetContextInfo только читает состояние контекста и ничего не сжимает
 	GetContextInfo(ctx context.Context, sessionID string) (*ContextInfo, error)
 	CleanupSession(ctx context.Context, sessionID string) error
+	CompressMessageRange(ctx context.Context, sessionID, fromMessageID, toMessageID string, level int) (*summary.SummaryResponse, error)
+	// GetSummarySources возвращает резюме и сжатые в него сообщения или резюме
+	GetSummarySources(ctx context.Context, sessionID, summaryID string) (*models.SummarySources, error)
+	// ExpandSummary отменяет сжатие: источники резюме возвращаются в контекст, резюме удаляется
+	ExpandSummary(ctx context.Context, sessionID, summaryID string) (*models.SummaryExpansion, error)
+	// IndexMessages сохраняет эмбеддинги сообщений для поиска по сжатой истории (в фоне, если поиск включён)
+	IndexMessages(messages ...models.Message)
 }
 
 // Verify interface implementation
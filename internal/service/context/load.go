@@ -0,0 +1,159 @@
+package context
+
+import (
+	"context"
+
+	"LLM_Chat/internal/storage/models"
+	"LLM_Chat/pkg/tokens"
+)
+
+// Порог, по которому сработало сжатие
+const (
+	TriggerMessages = "messages" // доля активных элементов от ContextWindowSize
+	TriggerTokens   = "tokens"   // доля оценки токенов от MaxContextTokens
+	TriggerForced   = "forced"   // CompressOptions.Force, пороги не превышены
+)
+
+// contextLoad заполненность контекста сессии по числу элементов и по оценке токенов
+type contextLoad struct {
+	messageRatio      float64
+	summaryRatio      float64
+	messageTokenRatio float64 // 0, если токенный порог выключен
+	summaryTokenRatio float64
+	bulkTokenRatio    float64
+
+	messageTokens []int // оценка токенов каждого активного сообщения; nil без токенного порога
+	summaryTokens []int
+	bulkTokens    []int
+}
+
+// tokenThresholds включён ли учёт токенов при решении о сжатии
+func (m *Manager) tokenThresholds() bool {
+	return m.config.MaxContextTokens > 0
+}
+
+// measureLoad оценивает заполненность контекста активными сообщениями, резюме первого уровня
+// и bulk резюме
+func (m *Manager) measureLoad(ctx context.Context, messages []models.Message, summaries, bulk []models.Summary) contextLoad {
+	window := float64(m.config.ContextWindowSize)
+	load := contextLoad{
+		messageRatio: float64(len(messages)) / window,
+		summaryRatio: float64(len(summaries)) / window,
+	}
+	if !m.tokenThresholds() {
+		return load
+	}
+
+	load.messageTokens = make([]int, len(messages))
+	messageTotal := 0
+	for i, msg := range messages {
+		load.messageTokens[i] = m.textTokens(ctx, msg.Content)
+		messageTotal += load.messageTokens[i]
+	}
+
+	var summaryTotal, bulkTotal int
+	load.summaryTokens, summaryTotal = m.summaryTokens(ctx, summaries)
+	load.bulkTokens, bulkTotal = m.summaryTokens(ctx, bulk)
+
+	budget := float64(m.config.MaxContextTokens)
+	load.messageTokenRatio = float64(messageTotal) / budget
+	load.summaryTokenRatio = float64(summaryTotal) / budget
+	load.bulkTokenRatio = float64(bulkTotal) / budget
+	return load
+}
+
+// summaryTokens оценка токенов резюме в том виде, в каком они попадают в контекст, и их сумма
+func (m *Manager) summaryTokens(ctx context.Context, summaries []models.Summary) ([]int, int) {
+	counts := make([]int, len(summaries))
+	total := 0
+	for i, s := range summaries {
+		counts[i] = m.textTokens(ctx, m.summaryMessage(s).Content)
+		total += counts[i]
+	}
+	return counts, total
+}
+
+// messageTrigger порог, по которому нужно сжатие сообщений; пустая строка - сжатие не нужно
+func (m *Manager) messageTrigger(load contextLoad, count int) string {
+	switch {
+	case count == 0:
+		return ""
+	case load.messageRatio > m.config.MessageCompressionRatio:
+		return TriggerMessages
+	case m.tokenThresholds() && m.config.MessageTokenRatio > 0 && load.messageTokenRatio > m.config.MessageTokenRatio:
+		return TriggerTokens
+	}
+	return ""
+}
+
+// summaryTrigger порог, по которому нужно сжатие резюме; пустая строка - сжатие не нужно
+func (m *Manager) summaryTrigger(load contextLoad, count int) string {
+	switch {
+	case count == 0:
+		return ""
+	case load.summaryRatio > m.config.SummaryCompressionRatio:
+		return TriggerMessages
+	case m.tokenThresholds() && m.config.SummaryTokenRatio > 0 && load.summaryTokenRatio > m.config.SummaryTokenRatio:
+		return TriggerTokens
+	}
+	return ""
+}
+
+// bulkTrigger порог, по которому нужно свернуть bulk резюме в дайджест сессии; пустая строка - не нужно.
+// MaxBulkSummaries = 0 отключает третий уровень сжатия.
+func (m *Manager) bulkTrigger(load contextLoad, count int) string {
+	switch {
+	case m.config.MaxBulkSummaries <= 0 || count < 2:
+		return ""
+	case count > m.config.MaxBulkSummaries:
+		return TriggerMessages
+	case m.tokenThresholds() && m.config.BulkTokenRatio > 0 && load.bulkTokenRatio > m.config.BulkTokenRatio:
+		return TriggerTokens
+	}
+	return ""
+}
+
+// compressionDecision уровень, причина и порог сжатия, которое нужно выполнить; уровень 0 - сжатие не нужно.
+// Старшие уровни проверяются первыми, как в Compress.
+func (m *Manager) compressionDecision(load contextLoad, messages, summaries, bulk int) (level int, reason, trigger string) {
+	if trigger := m.bulkTrigger(load, bulk); trigger != "" {
+		return 3, ReasonDigestCompression, trigger
+	}
+	if trigger := m.summaryTrigger(load, summaries); trigger != "" {
+		return 2, ReasonSummaryCompression, trigger
+	}
+	if trigger := m.messageTrigger(load, messages); trigger != "" {
+		return 1, ReasonMessageCompression, trigger
+	}
+	return 0, "", ""
+}
+
+// keepCount сколько последних элементов оставить несжатыми. Без оценки токенов - countKeep,
+// иначе не больше, чем помещается в половину токенного порога ratio, чтобы после сжатия
+// порог не сработал снова на следующем запросе. Не меньше minKeep.
+func (m *Manager) keepCount(countKeep int, counts []int, ratio float64, minKeep int) int {
+	keep := countKeep
+	if counts != nil && ratio > 0 {
+		budget := int(float64(m.config.MaxContextTokens) * ratio / 2)
+		fit, total := 0, 0
+		for i := len(counts) - 1; i >= 0; i-- {
+			total += counts[i]
+			if total > budget {
+				break
+			}
+			fit++
+		}
+		keep = min(keep, fit)
+	}
+	return max(keep, minKeep)
+}
+
+// textTokens оценка токенов одного текста вместе со служебной разметкой сообщения.
+// Если счётчик вернул ошибку, используется эвристическая оценка.
+func (m *Manager) textTokens(ctx context.Context, text string) int {
+	n, err := m.tokenCounter.Count(ctx, text)
+	if err != nil {
+		n = tokens.Estimate(text)
+	}
+	return n + tokens.MessageOverhead
+}
@@ -0,0 +1,64 @@
+package context
+
+import (
+	"context"
+	"sync"
+)
+
+// sessionLocks взаимоисключение сжатия в пределах сессии: два одновременных запроса одной сессии
+// иначе прочитали бы одни и те же активные сообщения и сжали бы их дважды. Блокировка действует
+// в пределах процесса; запись сессии удаляется, когда её никто не держит и не ждёт.
+type sessionLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sessionLock
+}
+
+type sessionLock struct {
+	sem  chan struct{}
+	refs int // владелец и ожидающие
+}
+
+func newSessionLocks() *sessionLocks {
+	return &sessionLocks{
+		locks: make(map[string]*sessionLock),
+	}
+}
+
+// lock захватывает блокировку сессии или возвращает ошибку контекста, не дождавшись её.
+// Возвращённая функция освобождает блокировку.
+func (l *sessionLocks) lock(ctx context.Context, sessionID string) (func(), error) {
+	l.mu.Lock()
+	entry, ok := l.locks[sessionID]
+	if !ok {
+		entry = &sessionLock{sem: make(chan struct{}, 1)}
+		l.locks[sessionID] = entry
+	}
+	entry.refs++
+	l.mu.Unlock()
+
+	select {
+	case entry.sem <- struct{}{}:
+	case <-ctx.Done():
+		l.release(sessionID, entry)
+		return nil, ctx.Err()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-entry.sem
+			l.release(sessionID, entry)
+		})
+	}, nil
+}
+
+// release снимает ссылку на запись сессии и удаляет её после последней
+func (l *sessionLocks) release(sessionID string, entry *sessionLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry.refs--
+	if entry.refs == 0 {
+		delete(l.locks, sessionID)
+	}
+}
@@ -2,13 +2,18 @@ package context
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	"LLM_Chat/internal/events"
 	"LLM_Chat/internal/service/summary"
 	"LLM_Chat/internal/storage/interfaces"
 	"LLM_Chat/internal/storage/models"
 	"LLM_Chat/pkg/llm"
+	"LLM_Chat/pkg/tokens"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
@@ -17,8 +22,14 @@ import (
 type Manager struct {
 	messageStore   interfaces.ExtendedMessageStore
 	summaryService summary.SummaryService
+	embedder       Embedder // nil - семантический поиск по сжатой истории выключен
+	tokenCounter   tokens.Estimator
+	events         events.Publisher
+	notifier       CompressionNotifier // nil - внешние уведомления о сжатии выключены
 	logger         *zap.Logger
 	config         Config
+
+	compressLocks *sessionLocks // одно сжатие сессии за раз
 }
 
 type Config struct {
@@ -27,6 +38,17 @@ type Config struct {
 	MinMessagesInWindow       int     // Минимум сообщений в окне
 	MessageCompressionRatio   float64 // Коэффициент для сжатия сообщений (30%)
 	SummaryCompressionRatio   float64 // Коэффициент для сжатия резюме (80%)
+	MaxActiveAnchors          int     // Максимум якорей активных резюме в метаданных контекста (0 - не выводить)
+	MaxContextTokens          int     // Бюджет контекста в токенах (0 - только ограничение по числу сообщений)
+	MessageTokenRatio         float64 // Доля MaxContextTokens в активных сообщениях, после которой запускается сжатие 1 уровня
+	SummaryTokenRatio         float64 // Доля MaxContextTokens в активных резюме, после которой запускается сжатие 2 уровня
+	MaxBulkSummaries          int     // Максимум bulk резюме до сворачивания старых в дайджест сессии (0 - без третьего уровня)
+	BulkTokenRatio            float64 // Доля MaxContextTokens в bulk резюме, после которой запускается сжатие 3 уровня
+	SummaryRole               string  // Роль сообщений с резюме в контексте LLM
+	SummaryTemplate           string  // Шаблон сообщения с резюме; {summary} заменяется якорями и текстом резюме
+	ExcludeToolMessages       bool    // Сообщения инструментов сжимаются вместе с диалогом, но не передаются в резюме
+
+	Recall RecallConfig // семантический поиск по сжатой истории
 }
 
 func DefaultConfig() Config {
@@ -36,20 +58,43 @@ func DefaultConfig() Config {
 		MinMessagesInWindow:       5,
 		MessageCompressionRatio:   0.3, // 30% от окна контекста
 		SummaryCompressionRatio:   0.8, // 80% от окна контекста
+		MaxActiveAnchors:          20,
+		MessageTokenRatio:         0.7, // 70% бюджета токенов
+		SummaryTokenRatio:         0.3, // 30% бюджета токенов
+		MaxBulkSummaries:          5,
+		BulkTokenRatio:            0.2, // 20% бюджета токенов
+		SummaryRole:               "system",
+		SummaryTemplate:           DefaultSummaryTemplate,
+		Recall: RecallConfig{
+			EmbeddingModel: llm.DefaultEmbeddingModel,
+			TopK:           3,
+			MinSimilarity:  0.75,
+			MaxTokens:      1000,
+		},
 	}
 }
 
 func NewManager(
 	messageStore interfaces.ExtendedMessageStore,
 	summaryService summary.SummaryService,
+	embedder Embedder,
+	tokenCounter tokens.Estimator, // nil - эвристическая оценка без обращения к API
+	eventPublisher events.Publisher,
 	config Config,
 	logger *zap.Logger,
 ) *Manager {
+	if tokenCounter == nil {
+		tokenCounter = tokens.NewHeuristic()
+	}
 	return &Manager{
 		messageStore:   messageStore,
 		summaryService: summaryService,
+		embedder:       embedder,
+		tokenCounter:   tokenCounter,
+		events:         eventPublisher,
 		config:         config,
 		logger:         logger,
+		compressLocks:  newSessionLocks(),
 	}
 }
 
@@ -57,6 +102,12 @@ type ContextRequest struct {
 	SessionID     string
 	SystemPrompt  string
 	IncludeSystem bool
+
+	// IncludeFailed включает сообщения пользователя, генерация ответа на которые завершилась ошибкой
+	IncludeFailed bool
+
+	// Query текущий вопрос пользователя для поиска по сжатой истории (при включённом recall)
+	Query string
 }
 
 type ContextResponse struct {
@@ -66,12 +117,39 @@ type ContextResponse struct {
 	HasSummary      bool
 	SummaryUpdated  bool
 	CompressionInfo *CompressionInfo
+	ActiveAnchors   []string // якоря резюме, попавших в контекст, без повторов
+
+	// RecalledMessages сколько сжатых сообщений возвращено в контекст поиском по эмбеддингам
+	RecalledMessages int
 }
 
+// Оформление резюме в контексте LLM по умолчанию (chat.summary_template)
+const (
+	SummaryPlaceholder     = "{summary}"
+	DefaultSummaryTemplate = "Резюме предыдущей части диалога (справка о более раннем разговоре, а не реплика ассистента; не цитируй её пользователю):\n\n" + SummaryPlaceholder
+)
+
+// Причины сжатия
+const (
+	ReasonMessageCompression = "message_compression" // уровень 1: сообщения в резюме
+	ReasonSummaryCompression = "summary_compression" // уровень 2: резюме в bulk резюме
+	ReasonDigestCompression  = "digest_compression"  // уровень 3: bulk резюме в дайджест сессии
+	ReasonManualRange        = "manual_range"        // диапазон, выбранный вручную
+	ReasonCompressionFailed  = "compression_failed"  // сжатие при построении контекста не удалось
+)
+
+var (
+	// ErrInvalidRange некорректный диапазон сообщений для сжатия
+	ErrInvalidRange = errors.New("invalid message range")
+	// ErrRangeAlreadyCompressed диапазон пересекается с уже сжатыми сообщениями
+	ErrRangeAlreadyCompressed = errors.New("message range overlaps already compressed messages")
+)
+
 type CompressionInfo struct {
 	Triggered           bool
 	Reason              string
-	Level               int // 1 = message compression, 2 = summary compression
+	Trigger             string // TriggerMessages, TriggerTokens или TriggerForced
+	Level               int    // 1 = message compression, 2 = summary compression, 3 = session digest
 	MessagesCompressed  int
 	SummariesCompressed int
 	AnchorsCreated      int
@@ -104,21 +182,40 @@ func (m *Manager) BuildContext(ctx context.Context, req ContextRequest) (*Contex
 		zap.Int("total_messages", totalCount),
 	)
 
-	// 2. Проверяем необходимость сжатия (двухуровневая проверка)
-	compressionInfo, err := m.checkAndCompress(ctx, req.SessionID)
+	// 2. Проверяем пороги и при необходимости сжимаем. Ошибка сжатия не мешает ответу:
+	// контекст строится из несжатых сообщений, сжатие повторится при следующем запросе
+	compressionInfo, err := m.Compress(ctx, req.SessionID, CompressOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to check compression: %w", err)
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("failed to check compression: %w", err)
+		}
+		m.logger.Warn("Compression failed, building context from uncompressed messages",
+			zap.String("session_id", req.SessionID),
+			zap.Error(err),
+		)
+		compressionInfo = &CompressionInfo{Reason: ReasonCompressionFailed}
 	}
 	response.CompressionInfo = compressionInfo
 
-	// 3. Собираем финальный контекст для LLM
-	contextMessages, hasSummary, err := m.buildLLMContext(ctx, req)
+	// 3. Ищем в сжатой истории сообщения, близкие к вопросу; без результатов поиска контекст строится как обычно
+	recalled, err := m.recallMessages(ctx, req.SessionID, req.Query)
+	if err != nil {
+		m.logger.Warn("Recall of compressed messages failed",
+			zap.String("session_id", req.SessionID),
+			zap.Error(err),
+		)
+	}
+
+	// 4. Собираем финальный контекст для LLM
+	contextMessages, hasSummary, anchors, err := m.buildLLMContext(ctx, req, recalled)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build LLM context: %w", err)
 	}
 
 	response.Messages = contextMessages
+	response.RecalledMessages = len(recalled)
 	response.HasSummary = hasSummary
+	response.ActiveAnchors = anchors
 	response.SummaryUpdated = compressionInfo.Triggered
 
 	duration := time.Since(startTime)
@@ -135,103 +232,81 @@ func (m *Manager) BuildContext(ctx context.Context, req ContextRequest) (*Contex
 	return response, nil
 }
 
-// checkAndCompress проверяет необходимость сжатия на обоих уровнях
-func (m *Manager) checkAndCompress(ctx context.Context, sessionID string) (*CompressionInfo, error) {
-	info := &CompressionInfo{}
-
-	// Получаем текущее состояние контекста
-	activeMessages, err := m.messageStore.GetActiveMessages(ctx, sessionID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get active messages: %w", err)
-	}
-
-	activeSummaries, err := m.messageStore.GetActiveSummaries(ctx, sessionID, 1) // level 1 summaries
-	if err != nil {
-		return nil, fmt.Errorf("failed to get active summaries: %w", err)
-	}
-
-	bulkSummaries, err := m.messageStore.GetSummariesByLevel(ctx, sessionID, 2) // level 2 summaries
-	if err != nil {
-		return nil, fmt.Errorf("failed to get bulk summaries: %w", err)
-	}
-
-	// Проверяем сжатие второго уровня (summaries -> bulk summaries)
-	summaryCompressionRatio := float64(len(activeSummaries)) / float64(m.config.ContextWindowSize)
-	if len(activeSummaries) > 0 && summaryCompressionRatio > m.config.SummaryCompressionRatio {
-		m.logger.Info("Triggering level 2 compression (summaries -> bulk summary)",
-			zap.String("session_id", sessionID),
-			zap.Int("active_summaries", len(activeSummaries)),
-			zap.Float64("compression_ratio", summaryCompressionRatio),
-		)
-
-		compressionResult, err := m.compressSummaries(ctx, sessionID, activeSummaries)
-		if err != nil {
-			return nil, fmt.Errorf("failed to compress summaries: %w", err)
-		}
-
-		info.Triggered = true
-		info.Reason = "summary_compression"
-		info.Level = 2
-		info.SummariesCompressed = compressionResult.SummariesCompressed
-		//info.AnchorsCreated = compressionResult.AnchorsCreated
-		info.TokensUsed = compressionResult.TokensUsed
-		info.Duration = compressionResult.Duration
+// publishCompressionFinished публикует итог сжатия и передаёт его наблюдателю сжатий
+func (m *Manager) publishCompressionFinished(sessionID string, info *CompressionInfo, summaryID string) {
+	m.publish(sessionID, events.TypeCompressionFinished, events.CompressionData{
+		Level:               info.Level,
+		Reason:              info.Reason,
+		Trigger:             info.Trigger,
+		MessagesCompressed:  info.MessagesCompressed,
+		SummariesCompressed: info.SummariesCompressed,
+		AnchorsCreated:      info.AnchorsCreated,
+		SummaryID:           summaryID,
+		TokensUsed:          info.TokensUsed,
+		DurationMs:          info.Duration.Milliseconds(),
+	})
+	m.notifyCompression(sessionID, info, summaryID)
+}
 
-		return info, nil
+// publish отправляет событие сессии, если шина событий подключена
+func (m *Manager) publish(sessionID string, eventType events.Type, data any) {
+	if m.events == nil {
+		return
 	}
 
-	// Проверяем сжатие первого уровня (messages -> summaries)
-	messageCompressionRatio := float64(len(activeMessages)) / float64(m.config.ContextWindowSize)
-	if len(activeMessages) > 0 && messageCompressionRatio > m.config.MessageCompressionRatio {
-		m.logger.Info("Triggering level 1 compression (messages -> summary)",
-			zap.String("session_id", sessionID),
-			zap.Int("active_messages", len(activeMessages)),
-			zap.Float64("compression_ratio", messageCompressionRatio),
-		)
-
-		compressionResult, err := m.compressMessages(ctx, sessionID, activeMessages)
-		if err != nil {
-			return nil, fmt.Errorf("failed to compress messages: %w", err)
-		}
-
-		info.Triggered = true
-		info.Reason = "message_compression"
-		info.Level = 1
-		info.MessagesCompressed = compressionResult.MessagesCompressed
-		//info.AnchorsCreated = compressionResult.AnchorsCreated
-		info.TokensUsed = compressionResult.TokensUsed
-		info.Duration = compressionResult.Duration
+	m.events.Publish(events.Event{
+		Type:      eventType,
+		SessionID: sessionID,
+		Data:      data,
+	})
+}
 
-		return info, nil
+// enoughForSummary проверяет, хватает ли элементов для резюме, до обращения к shrink модели:
+// уровень, на котором сжимать нечего, не публикует события сжатия
+func (m *Manager) enoughForSummary(sessionID string, count int) bool {
+	if count >= m.summaryService.MinMessagesForSummary() {
+		return true
 	}
 
-	m.logger.Debug("No compression needed",
+	m.logger.Debug("Not enough items for summary",
 		zap.String("session_id", sessionID),
-		zap.Int("active_messages", len(activeMessages)),
-		zap.Int("active_summaries", len(activeSummaries)),
-		zap.Int("bulk_summaries", len(bulkSummaries)),
-		zap.Float64("message_ratio", messageCompressionRatio),
-		zap.Float64("summary_ratio", summaryCompressionRatio),
+		zap.Int("compress_count", count),
 	)
-
-	return info, nil
+	return false
 }
 
-// compressMessages сжимает обычные сообщения в резюме первого уровня
-func (m *Manager) compressMessages(ctx context.Context, sessionID string, messages []models.Message) (*summary.SummaryResponse, error) {
+// compressMessages сжимает обычные сообщения в резюме первого уровня.
+// tokenCounts - оценка токенов каждого сообщения (nil без токенного порога);
+// started вызывается перед созданием резюме, если сжимать есть что.
+func (m *Manager) compressMessages(ctx context.Context, sessionID string, messages []models.Message, tokenCounts []int, force bool, started func()) (*summary.SummaryResponse, error) {
 	startTime := time.Now()
 
-	// Оставляем последние сообщения несжатыми
-	keepCount := int(float64(m.config.ContextWindowSize) * (1.0 - m.config.MessageCompressionRatio))
-	if keepCount < m.config.MinMessagesInWindow {
-		keepCount = m.config.MinMessagesInWindow
+	// Оставляем последние сообщения несжатыми; принудительное сжатие оставляет только минимум окна
+	keepCount := m.config.MinMessagesInWindow
+	if !force {
+		keepCount = m.keepCount(
+			int(float64(m.config.ContextWindowSize)*(1.0-m.config.MessageCompressionRatio)),
+			tokenCounts, m.config.MessageTokenRatio, m.config.MinMessagesInWindow,
+		)
 	}
 
 	if len(messages) <= keepCount {
 		return &summary.SummaryResponse{}, nil // Недостаточно сообщений для сжатия
 	}
 
-	messagesToCompress := messages[:len(messages)-keepCount]
+	messagesToCompress, err := m.withToolMessages(ctx, sessionID, messages[:len(messages)-keepCount], time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	summaryCount := len(messagesToCompress)
+	if m.config.ExcludeToolMessages {
+		summaryCount = len(withoutToolMessages(messagesToCompress))
+	}
+	if !m.enoughForSummary(sessionID, summaryCount) {
+		return &summary.SummaryResponse{}, nil
+	}
+	started()
 
 	m.logger.Info("Compressing messages to summary",
 		zap.String("session_id", sessionID),
@@ -240,12 +315,46 @@ func (m *Manager) compressMessages(ctx context.Context, sessionID string, messag
 		zap.Int("keep_count", keepCount),
 	)
 
+	summaryResp, err := m.CompressRange(ctx, sessionID, messagesToCompress, 1, ReasonMessageCompression)
+	if errors.Is(err, summary.ErrNotEnoughMessages) {
+		m.logger.Debug("Not enough messages for summary",
+			zap.String("session_id", sessionID),
+			zap.Int("compress_count", len(messagesToCompress)),
+		)
+		return &summary.SummaryResponse{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	summaryResp.Duration = time.Since(startTime)
+
+	m.logger.Info("Message compression completed",
+		zap.String("session_id", sessionID),
+		zap.Int("messages_compressed", len(messagesToCompress)),
+		zap.String("summary_id", summaryResp.SummaryID),
+		zap.Duration("duration", summaryResp.Duration),
+	)
+
+	return summaryResp, nil
+}
+
+// CompressRange создаёт резюме для переданных сообщений и одной транзакцией сохраняет его
+// вместе с summary сообщением и пометкой исходных сообщений как сжатых.
+// При ExcludeToolMessages сообщения инструментов помечаются сжатыми, но в резюме не передаются.
+func (m *Manager) CompressRange(ctx context.Context, sessionID string, messages []models.Message, level int, reason string) (*summary.SummaryResponse, error) {
+	summaryMessages := messages
+	if m.config.ExcludeToolMessages {
+		summaryMessages = withoutToolMessages(messages)
+	}
+
 	// Создаем резюме через SummaryService
 	summaryReq := summary.SummaryRequest{
 		SessionID:    sessionID,
-		Messages:     messagesToCompress,
-		Reason:       "message_compression",
-		SummaryLevel: 1, // Regular summary
+		Messages:     summaryMessages,
+		Reason:       reason,
+		SummaryLevel: level,
+		SkipSave:     true,
 	}
 
 	summaryResp, err := m.summaryService.CreateSummary(ctx, summaryReq)
@@ -254,43 +363,151 @@ func (m *Manager) compressMessages(ctx context.Context, sessionID string, messag
 	}
 
 	// Создаем summary message для хранения в БД
-	summaryMessage := models.NewSummaryMessage(sessionID, summaryResp.BriefSummary, 1)
+	summaryMessage := models.NewSummaryMessage(sessionID, summaryResp.BriefSummary, level)
 	summaryMessage.ID = uuid.New().String()
 
-	if err := m.messageStore.SaveMessage(ctx, summaryMessage); err != nil {
-		return nil, fmt.Errorf("failed to save summary message: %w", err)
+	messageIDs := make([]string, len(messages))
+	for i, msg := range messages {
+		messageIDs[i] = msg.ID
 	}
 
-	// Помечаем исходные сообщения как сжатые
-	messageIDs := make([]string, len(messagesToCompress))
-	for i, msg := range messagesToCompress {
-		messageIDs[i] = msg.ID
+	if err := m.messageStore.ApplyCompression(ctx, models.Compression{
+		Summary:        summaryResp.Summary,
+		SummaryMessage: summaryMessage,
+		MessageIDs:     messageIDs,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to apply message compression: %w", err)
 	}
 
-	if err := m.messageStore.MarkMessagesAsCompressed(ctx, messageIDs, summaryResp.SummaryID); err != nil {
-		return nil, fmt.Errorf("failed to mark messages as compressed: %w", err)
+	summaryResp.MessagesCompressed = len(messages)
+	return summaryResp, nil
+}
+
+// CompressMessageRange сжимает сообщения между fromMessageID и toMessageID включительно,
+// независимо от автоматических порогов
+func (m *Manager) CompressMessageRange(ctx context.Context, sessionID, fromMessageID, toMessageID string, level int) (*summary.SummaryResponse, error) {
+	if level != 1 && level != 2 {
+		return nil, fmt.Errorf("%w: level must be 1 or 2, got %d", ErrInvalidRange, level)
 	}
 
-	summaryResp.Duration = time.Since(startTime)
+	unlock, err := m.compressLocks.lock(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire compression lock: %w", err)
+	}
+	defer unlock()
 
-	m.logger.Info("Message compression completed",
+	messages, err := m.messageStore.GetMessagesInRange(ctx, sessionID, fromMessageID, toMessageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("%w: message '%s' is after '%s' or range has no regular messages",
+			ErrInvalidRange, fromMessageID, toMessageID)
+	}
+
+	var compressed []string
+	for _, msg := range messages {
+		if msg.IsCompressed {
+			compressed = append(compressed, msg.ID)
+		}
+	}
+	if len(compressed) > 0 {
+		return nil, fmt.Errorf("%w: %d of %d messages are already compressed (first: %s)",
+			ErrRangeAlreadyCompressed, len(compressed), len(messages), compressed[0])
+	}
+
+	messages, err = m.withToolMessages(ctx, sessionID, messages, messages[0].Timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	m.logger.Info("Compressing explicit message range",
 		zap.String("session_id", sessionID),
-		zap.Int("messages_compressed", len(messagesToCompress)),
-		zap.String("summary_id", summaryResp.SummaryID),
-		zap.Duration("duration", summaryResp.Duration),
+		zap.String("from_message_id", fromMessageID),
+		zap.String("to_message_id", toMessageID),
+		zap.Int("messages_count", len(messages)),
+		zap.Int("level", level),
 	)
 
+	startTime := time.Now()
+	startData := events.CompressionData{
+		Level:          level,
+		Reason:         ReasonManualRange,
+		ActiveMessages: len(messages),
+	}
+	m.publish(sessionID, events.TypeCompressionStarted, startData)
+
+	summaryResp, err := m.CompressRange(ctx, sessionID, messages, level, ReasonManualRange)
+	if err != nil {
+		startData.Error = err.Error()
+		m.publish(sessionID, events.TypeCompressionFailed, startData)
+		return nil, err
+	}
+	summaryResp.Duration = time.Since(startTime)
+
+	m.publishCompressionFinished(sessionID, &CompressionInfo{
+		Triggered:          true,
+		Reason:             ReasonManualRange,
+		Level:              level,
+		MessagesCompressed: summaryResp.MessagesCompressed,
+		AnchorsCreated:     summaryResp.AnchorsCreated,
+		TokensUsed:         summaryResp.TokensUsed,
+		Duration:           summaryResp.Duration,
+	}, summaryResp.SummaryID)
+
 	return summaryResp, nil
 }
 
-// compressSummaries сжимает резюме первого уровня в bulk summary
-func (m *Manager) compressSummaries(ctx context.Context, sessionID string, summaries []models.Summary) (*summary.SummaryResponse, error) {
+// withToolMessages добавляет к сжимаемым сообщениям несжатые сообщения инструментов, созданные
+// не раньше from и не позже последнего из сообщений, и упорядочивает всё по времени. Вызовы
+// инструментов стоят между вопросом и ответом, поэтому сжимаются вместе со своей частью диалога.
+func (m *Manager) withToolMessages(ctx context.Context, sessionID string, messages []models.Message, from time.Time) ([]models.Message, error) {
+	if len(messages) == 0 {
+		return messages, nil
+	}
+
+	toolMessages, err := m.messageStore.GetActiveToolMessages(ctx, sessionID, from, messages[len(messages)-1].Timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tool messages: %w", err)
+	}
+	if len(toolMessages) == 0 {
+		return messages, nil
+	}
+
+	merged := make([]models.Message, 0, len(messages)+len(toolMessages))
+	merged = append(merged, messages...)
+	merged = append(merged, toolMessages...)
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Timestamp.Before(merged[j].Timestamp)
+	})
+	return merged, nil
+}
+
+// withoutToolMessages сообщения без вызовов инструментов
+func withoutToolMessages(messages []models.Message) []models.Message {
+	filtered := make([]models.Message, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Role != "tool" {
+			filtered = append(filtered, msg)
+		}
+	}
+	return filtered
+}
+
+// compressSummaries сжимает резюме первого уровня в bulk summary.
+// tokenCounts - оценка токенов каждого резюме (nil без токенного порога);
+// started вызывается перед созданием резюме, если сжимать есть что.
+func (m *Manager) compressSummaries(ctx context.Context, sessionID string, summaries []models.Summary, tokenCounts []int, force bool, started func()) (*summary.SummaryResponse, error) {
 	startTime := time.Now()
 
-	// Оставляем последние резюме несжатыми
-	keepCount := int(float64(m.config.ContextWindowSize) * (1.0 - m.config.SummaryCompressionRatio))
-	if keepCount < 2 { // Минимум 2 резюме оставляем
-		keepCount = 2
+	// Оставляем последние резюме несжатыми, минимум 2
+	keepCount := minKeepSummaries
+	if !force {
+		keepCount = m.keepCount(
+			int(float64(m.config.ContextWindowSize)*(1.0-m.config.SummaryCompressionRatio)),
+			tokenCounts, m.config.SummaryTokenRatio, minKeepSummaries,
+		)
 	}
 
 	if len(summaries) <= keepCount {
@@ -298,6 +515,10 @@ func (m *Manager) compressSummaries(ctx context.Context, sessionID string, summa
 	}
 
 	summariesToCompress := summaries[:len(summaries)-keepCount]
+	if !m.enoughForSummary(sessionID, len(summariesToCompress)) {
+		return &summary.SummaryResponse{}, nil
+	}
+	started()
 
 	m.logger.Info("Compressing summaries to bulk summary",
 		zap.String("session_id", sessionID),
@@ -306,23 +527,23 @@ func (m *Manager) compressSummaries(ctx context.Context, sessionID string, summa
 		zap.Int("keep_count", keepCount),
 	)
 
-	// Конвертируем summaries в messages для SummaryService
-	summaryMessages := make([]models.Message, len(summariesToCompress))
-	for i, summary := range summariesToCompress {
-		summaryMessages[i] = models.NewSummaryMessage(sessionID, summary.SummaryText, 1)
-		summaryMessages[i].ID = summary.ID
-		summaryMessages[i].Timestamp = summary.UpdatedAt
-	}
-
 	// Создаем bulk summary
 	summaryReq := summary.SummaryRequest{
 		SessionID:    sessionID,
-		Messages:     summaryMessages,
-		Reason:       "summary_compression",
+		Messages:     summariesAsMessages(sessionID, summariesToCompress),
+		Reason:       ReasonSummaryCompression,
 		SummaryLevel: 2, // Bulk summary
+		SkipSave:     true,
 	}
 
 	summaryResp, err := m.summaryService.CreateSummary(ctx, summaryReq)
+	if errors.Is(err, summary.ErrNotEnoughMessages) {
+		m.logger.Debug("Not enough summaries for bulk summary",
+			zap.String("session_id", sessionID),
+			zap.Int("compress_count", len(summariesToCompress)),
+		)
+		return &summary.SummaryResponse{}, nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create bulk summary: %w", err)
 	}
@@ -331,18 +552,18 @@ func (m *Manager) compressSummaries(ctx context.Context, sessionID string, summa
 	bulkSummaryMessage := models.NewSummaryMessage(sessionID, summaryResp.BriefSummary, 2)
 	bulkSummaryMessage.ID = uuid.New().String()
 
-	if err := m.messageStore.SaveMessage(ctx, bulkSummaryMessage); err != nil {
-		return nil, fmt.Errorf("failed to save bulk summary message: %w", err)
-	}
-
-	// Помечаем исходные резюме как сжатые
+	// Сохраняем bulk summary и помечаем исходные резюме как сжатые одной транзакцией
 	summaryIDs := make([]string, len(summariesToCompress))
 	for i, summary := range summariesToCompress {
 		summaryIDs[i] = summary.ID
 	}
 
-	if err := m.messageStore.MarkSummariesAsCompressed(ctx, summaryIDs, summaryResp.SummaryID); err != nil {
-		return nil, fmt.Errorf("failed to mark summaries as compressed: %w", err)
+	if err := m.messageStore.ApplyCompression(ctx, models.Compression{
+		Summary:        summaryResp.Summary,
+		SummaryMessage: bulkSummaryMessage,
+		SummaryIDs:     summaryIDs,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to apply summary compression: %w", err)
 	}
 
 	summaryResp.SummariesCompressed = len(summariesToCompress)
@@ -358,54 +579,171 @@ func (m *Manager) compressSummaries(ctx context.Context, sessionID string, summa
 	return summaryResp, nil
 }
 
-// buildLLMContext строит финальный контекст для отправки в LLM
-func (m *Manager) buildLLMContext(ctx context.Context, req ContextRequest) ([]llm.Message, bool, error) {
+// compressBulkSummaries сворачивает старые bulk резюме вместе с прежним дайджестом в новый дайджест
+// сессии (резюме третьего уровня). Активным остаётся один дайджест, поэтому его размер не растёт
+// с длиной сессии. tokenCounts - оценка токенов каждого bulk резюме (nil без токенного порога);
+// started вызывается перед созданием резюме, если сжимать есть что.
+func (m *Manager) compressBulkSummaries(ctx context.Context, sessionID string, bulkSummaries []models.Summary, tokenCounts []int, force bool, started func()) (*summary.SummaryResponse, error) {
+	startTime := time.Now()
+
+	// Оставляем последние bulk резюме несжатыми, минимум одно
+	keepCount := minKeepBulkSummaries
+	if !force {
+		keepCount = m.keepCount(m.config.MaxBulkSummaries/2, tokenCounts, m.config.BulkTokenRatio, minKeepBulkSummaries)
+	}
+	if len(bulkSummaries) <= keepCount {
+		return &summary.SummaryResponse{}, nil
+	}
+
+	digests, err := m.messageStore.GetActiveSummaries(ctx, sessionID, 3)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session digests: %w", err)
+	}
+
+	// Прежний дайджест идёт первым: он описывает более раннюю часть сессии
+	sources := append(digests, bulkSummaries[:len(bulkSummaries)-keepCount]...)
+	if !m.enoughForSummary(sessionID, len(sources)) {
+		return &summary.SummaryResponse{}, nil
+	}
+	started()
+
+	m.logger.Info("Compressing bulk summaries to session digest",
+		zap.String("session_id", sessionID),
+		zap.Int("total_bulk_summaries", len(bulkSummaries)),
+		zap.Int("previous_digests", len(digests)),
+		zap.Int("compress_count", len(sources)),
+		zap.Int("keep_count", keepCount),
+	)
+
+	summaryResp, err := m.summaryService.CreateSummary(ctx, summary.SummaryRequest{
+		SessionID:    sessionID,
+		Messages:     summariesAsMessages(sessionID, sources),
+		Reason:       ReasonDigestCompression,
+		SummaryLevel: 3,
+		SkipSave:     true,
+	})
+	if errors.Is(err, summary.ErrNotEnoughMessages) {
+		m.logger.Debug("Not enough summaries for session digest",
+			zap.String("session_id", sessionID),
+			zap.Int("sources", len(sources)),
+		)
+		return &summary.SummaryResponse{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session digest: %w", err)
+	}
+
+	digestMessage := models.NewSummaryMessage(sessionID, summaryResp.BriefSummary, 3)
+	digestMessage.ID = uuid.New().String()
+
+	sourceIDs := make([]string, len(sources))
+	for i, s := range sources {
+		sourceIDs[i] = s.ID
+	}
+
+	if err := m.messageStore.ApplyCompression(ctx, models.Compression{
+		Summary:        summaryResp.Summary,
+		SummaryMessage: digestMessage,
+		SummaryIDs:     sourceIDs,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to apply digest compression: %w", err)
+	}
+
+	summaryResp.SummariesCompressed = len(sources)
+	summaryResp.Duration = time.Since(startTime)
+
+	m.logger.Info("Session digest created",
+		zap.String("session_id", sessionID),
+		zap.Int("summaries_compressed", len(sources)),
+		zap.String("digest_id", summaryResp.SummaryID),
+		zap.Duration("duration", summaryResp.Duration),
+	)
+
+	return summaryResp, nil
+}
+
+// summariesAsMessages представляет резюме сообщениями для SummaryService
+func summariesAsMessages(sessionID string, summaries []models.Summary) []models.Message {
+	messages := make([]models.Message, len(summaries))
+	for i, s := range summaries {
+		messages[i] = models.NewSummaryMessage(sessionID, s.SummaryText, s.SummaryLevel)
+		messages[i].ID = s.ID
+		messages[i].Timestamp = s.UpdatedAt
+	}
+	return messages
+}
+
+// buildLLMContext строит финальный контекст для отправки в LLM.
+// Вместе с сообщениями возвращает якоря включённых в контекст резюме.
+// Найденные в сжатой истории сообщения добавляются системным блоком после резюме.
+func (m *Manager) buildLLMContext(ctx context.Context, req ContextRequest, recalled []models.Message) ([]llm.Message, bool, []string, error) {
 	var contextMessages []llm.Message
 	hasSummary := false
 
-	// 1. Добавляем системный промпт если нужно
+	// 1. Добавляем системный промпт если нужно. Не обрезаются он, дайджест сессии и bulk резюме:
+	// их число ограничено сжатием старших уровней, а вытеснение начинается со старых резюме
+	// первого уровня и продолжается старыми сообщениями
+	pinned := 0
 	if req.IncludeSystem && req.SystemPrompt != "" {
 		contextMessages = append(contextMessages, llm.Message{
 			Role:    "system",
 			Content: req.SystemPrompt,
 		})
+		pinned = 1
+	}
+
+	// 2. Получаем дайджест сессии (уровень 3) и bulk summaries (уровень 2), не свёрнутые в него
+	digests, err := m.messageStore.GetActiveSummaries(ctx, req.SessionID, 3)
+	if err != nil {
+		return nil, false, nil, fmt.Errorf("failed to get session digests: %w", err)
+	}
+
+	for _, s := range digests {
+		contextMessages = append(contextMessages, m.summaryMessage(s))
+		hasSummary = true
 	}
 
-	// 2. Получаем bulk summaries (уровень 2) - всегда включаем все
 	bulkSummaries, err := m.messageStore.GetSummariesByLevel(ctx, req.SessionID, 2)
 	if err != nil {
-		return nil, false, fmt.Errorf("failed to get bulk summaries: %w", err)
+		return nil, false, nil, fmt.Errorf("failed to get bulk summaries: %w", err)
 	}
 
-	for _, summary := range bulkSummaries {
-		contextMessages = append(contextMessages, llm.Message{
-			Role:    "assistant", // Резюме от ассистента
-			Content: summary.SummaryText,
-		})
+	for _, s := range bulkSummaries {
+		contextMessages = append(contextMessages, m.summaryMessage(s))
 		hasSummary = true
 	}
+	pinned += len(digests) + len(bulkSummaries)
 
 	// 3. Получаем активные обычные summaries (уровень 1) - не сжатые в bulk
 	activeSummaries, err := m.messageStore.GetActiveSummaries(ctx, req.SessionID, 1)
 	if err != nil {
-		return nil, false, fmt.Errorf("failed to get active summaries: %w", err)
+		return nil, false, nil, fmt.Errorf("failed to get active summaries: %w", err)
 	}
 
-	for _, summary := range activeSummaries {
-		contextMessages = append(contextMessages, llm.Message{
-			Role:    "assistant",
-			Content: summary.SummaryText,
-		})
+	for _, s := range activeSummaries {
+		contextMessages = append(contextMessages, m.summaryMessage(s))
 		hasSummary = true
 	}
 
+	anchors := m.collectAnchors(digests, bulkSummaries, activeSummaries)
+
+	if len(recalled) > 0 {
+		contextMessages = append(contextMessages, recallBlock(recalled))
+	}
+
 	// 4. Получаем активные обычные сообщения - не сжатые в summaries
 	activeMessages, err := m.messageStore.GetActiveMessages(ctx, req.SessionID)
 	if err != nil {
-		return nil, false, fmt.Errorf("failed to get active messages: %w", err)
+		return nil, false, nil, fmt.Errorf("failed to get active messages: %w", err)
 	}
 
+	skippedFailed := 0
 	for _, msg := range activeMessages {
+		// Вопрос без ответа сбивает модель - по умолчанию не передаём его
+		if msg.IsFailed() && !req.IncludeFailed {
+			skippedFailed++
+			continue
+		}
 		contextMessages = append(contextMessages, llm.Message{
 			Role:    msg.Role,
 			Content: msg.Content,
@@ -413,63 +751,179 @@ func (m *Manager) buildLLMContext(ctx context.Context, req ContextRequest) ([]ll
 	}
 
 	// 5. Обрезаем контекст до максимального размера если необходимо
-	contextMessages = m.trimContext(contextMessages, req.IncludeSystem)
+	contextMessages = m.trimContext(ctx, contextMessages, pinned)
 
 	m.logger.Debug("LLM context assembled",
 		zap.String("session_id", req.SessionID),
+		zap.Int("session_digests", len(digests)),
 		zap.Int("bulk_summaries", len(bulkSummaries)),
 		zap.Int("active_summaries", len(activeSummaries)),
 		zap.Int("active_messages", len(activeMessages)),
+		zap.Int("skipped_failed", skippedFailed),
 		zap.Int("total_context_messages", len(contextMessages)),
 		zap.Bool("has_summary", hasSummary),
+		zap.Int("active_anchors", len(anchors)),
+		zap.Int("recalled_messages", len(recalled)),
 	)
 
-	return contextMessages, hasSummary, nil
+	return contextMessages, hasSummary, anchors, nil
 }
 
-// trimContext обрезает контекст до максимального размера
-func (m *Manager) trimContext(messages []llm.Message, preserveSystem bool) []llm.Message {
-	if len(messages) <= m.config.ContextWindowSize {
-		return messages
+// summaryMessage сообщение контекста с резюме любого уровня: якоря и текст резюме в шаблоне
+// SummaryTemplate. Явная пометка не даёт модели принять резюме за собственную реплику.
+func (m *Manager) summaryMessage(s models.Summary) llm.Message {
+	role := m.config.SummaryRole
+	if role == "" {
+		role = "system"
 	}
+	template := m.config.SummaryTemplate
+	if template == "" {
+		template = DefaultSummaryTemplate
+	}
+	return llm.Message{
+		Role:    role,
+		Content: strings.ReplaceAll(template, SummaryPlaceholder, summary.FormatForContext(s, "")),
+	}
+}
 
-	// Сохраняем системные сообщения если нужно
-	var systemMessages []llm.Message
-	var regularMessages []llm.Message
+// collectAnchors собирает якоря резюме в порядке их следования в контексте.
+// Повторы (без учёта регистра и пробелов по краям) отбрасываются,
+// количество ограничено MaxActiveAnchors.
+func (m *Manager) collectAnchors(summaryGroups ...[]models.Summary) []string {
+	limit := m.config.MaxActiveAnchors
+	if limit <= 0 {
+		return nil
+	}
 
-	for _, msg := range messages {
-		if msg.Role == "system" && preserveSystem {
-			systemMessages = append(systemMessages, msg)
-		} else {
-			regularMessages = append(regularMessages, msg)
+	var anchors []string
+	seen := make(map[string]struct{})
+
+	for _, summaries := range summaryGroups {
+		for _, summary := range summaries {
+			for _, anchor := range summary.Anchors {
+				anchor = strings.TrimSpace(anchor)
+				if anchor == "" {
+					continue
+				}
+
+				key := strings.ToLower(anchor)
+				if _, ok := seen[key]; ok {
+					continue
+				}
+				seen[key] = struct{}{}
+
+				anchors = append(anchors, anchor)
+				if len(anchors) >= limit {
+					return anchors
+				}
+			}
 		}
 	}
 
-	// Берём последние сообщения, учитывая место для системных
-	availableSlots := m.config.ContextWindowSize - len(systemMessages)
-	if availableSlots <= 0 {
-		return systemMessages // Только системные сообщения
+	return anchors
+}
+
+// trimContext обрезает контекст до размера окна, а затем, если задан MaxContextTokens, до бюджета токенов.
+// Первые pinned сообщений (системный промпт, дайджест и bulk резюме) не обрезаются; остальные,
+// включая резюме первого уровня, вытесняются начиная с самых старых.
+func (m *Manager) trimContext(ctx context.Context, messages []llm.Message, pinned int) []llm.Message {
+	messages = m.trimToWindow(messages, pinned)
+	if m.config.MaxContextTokens <= 0 {
+		return messages
+	}
+	return m.trimToTokenBudget(ctx, messages, pinned)
+}
+
+// trimToWindow оставляет первые pinned сообщений и последние сообщения до ContextWindowSize.
+// Последнее сообщение остаётся всегда.
+func (m *Manager) trimToWindow(messages []llm.Message, pinned int) []llm.Message {
+	if len(messages) <= m.config.ContextWindowSize || pinned >= len(messages) {
+		return messages
 	}
 
-	if len(regularMessages) > availableSlots {
-		regularMessages = regularMessages[len(regularMessages)-availableSlots:]
+	// Берём последние сообщения, учитывая место для закреплённых
+	availableSlots := m.config.ContextWindowSize - pinned
+	if availableSlots < 1 {
+		availableSlots = 1
+	}
+	rest := messages[pinned:]
+	if len(rest) > availableSlots {
+		rest = rest[len(rest)-availableSlots:]
 	}
 
-	// Объединяем системные и обрезанные обычные сообщения
-	result := make([]llm.Message, 0, len(systemMessages)+len(regularMessages))
-	result = append(result, systemMessages...)
-	result = append(result, regularMessages...)
+	result := make([]llm.Message, 0, pinned+len(rest))
+	result = append(result, messages[:pinned]...)
+	result = append(result, rest...)
 
 	m.logger.Debug("Context trimmed",
 		zap.Int("original_size", len(messages)),
 		zap.Int("trimmed_size", len(result)),
-		zap.Int("system_messages", len(systemMessages)),
-		zap.Int("regular_messages", len(regularMessages)),
+		zap.Int("pinned_messages", pinned),
 	)
 
 	return result
 }
 
+// trimToTokenBudget удаляет самые старые сообщения после первых pinned, пока контекст превышает
+// MaxContextTokens. Последнее сообщение остаётся всегда, даже если одно оно не укладывается в бюджет.
+func (m *Manager) trimToTokenBudget(ctx context.Context, messages []llm.Message, pinned int) []llm.Message {
+	counts := m.messageTokens(ctx, messages)
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+
+	budget := m.config.MaxContextTokens
+	if total <= budget {
+		return messages
+	}
+
+	originalTokens := total
+	result := make([]llm.Message, 0, len(messages))
+	for i, msg := range messages {
+		if total > budget && i >= pinned && i != len(messages)-1 {
+			total -= counts[i]
+			continue
+		}
+		result = append(result, msg)
+	}
+
+	m.logger.Debug("Context trimmed to token budget",
+		zap.Int("max_context_tokens", budget),
+		zap.Int("original_tokens", originalTokens),
+		zap.Int("trimmed_tokens", total),
+		zap.Int("original_size", len(messages)),
+		zap.Int("trimmed_size", len(result)),
+	)
+	if total > budget {
+		m.logger.Warn("Context exceeds token budget after trimming",
+			zap.Int("max_context_tokens", budget),
+			zap.Int("context_tokens", total),
+		)
+	}
+
+	return result
+}
+
+// messageTokens оценивает токены каждого сообщения вместе со служебной разметкой.
+// Если счётчик вернул ошибку, используется эвристическая оценка.
+func (m *Manager) messageTokens(ctx context.Context, messages []llm.Message) []int {
+	counts := make([]int, len(messages))
+	for i, msg := range messages {
+		counts[i] = m.textTokens(ctx, msg.Content)
+	}
+	return counts
+}
+
+// estimateTokens оценка токенов контекста целиком
+func (m *Manager) estimateTokens(ctx context.Context, messages []llm.Message) int {
+	total := 0
+	for _, n := range m.messageTokens(ctx, messages) {
+		total += n
+	}
+	return total
+}
+
 // GetContextInfo возвращает детальную информацию о текущем контексте
 func (m *Manager) GetContextInfo(ctx context.Context, sessionID string) (*ContextInfo, error) {
 	totalCount, err := m.messageStore.GetMessageCount(ctx, sessionID)
@@ -493,53 +947,70 @@ func (m *Manager) GetContextInfo(ctx context.Context, sessionID string) (*Contex
 		return nil, fmt.Errorf("failed to get bulk summaries: %w", err)
 	}
 
-	// Определяем, нужно ли сжатие
-	messageRatio := float64(len(activeMessages)) / float64(m.config.ContextWindowSize)
-	summaryRatio := float64(len(activeSummaries)) / float64(m.config.ContextWindowSize)
+	digests, err := m.messageStore.GetActiveSummaries(ctx, sessionID, 3)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session digests: %w", err)
+	}
 
-	var shouldCompress bool
-	var compressionReason string
-	var compressionLevel int
+	// Определяем, нужно ли сжатие, по тем же порогам, что и Compress; сам GetContextInfo ничего не сжимает
+	load := m.measureLoad(ctx, activeMessages, activeSummaries, bulkSummaries)
+	compressionLevel, compressionReason, compressionTrigger := m.compressionDecision(
+		load, len(activeMessages), len(activeSummaries), len(bulkSummaries))
 
-	if len(activeSummaries) > 0 && summaryRatio > m.config.SummaryCompressionRatio {
-		shouldCompress = true
-		compressionReason = "summary_compression"
-		compressionLevel = 2
-	} else if len(activeMessages) > 0 && messageRatio > m.config.MessageCompressionRatio {
-		shouldCompress = true
-		compressionReason = "message_compression"
-		compressionLevel = 1
+	// Оценка токенов контекста, который получит модель (без системного промпта и результатов recall)
+	contextMessages, _, _, err := m.buildLLMContext(ctx, ContextRequest{SessionID: sessionID}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build context: %w", err)
 	}
 
 	return &ContextInfo{
-		SessionID:         sessionID,
-		TotalMessages:     totalCount,
-		ActiveMessages:    len(activeMessages),
-		ActiveSummaries:   len(activeSummaries),
-		BulkSummaries:     len(bulkSummaries),
-		ContextWindowSize: m.config.ContextWindowSize,
-		MaxBeforeCompress: m.config.MaxMessagesBeforeCompress,
-		ShouldCompress:    shouldCompress,
-		CompressionReason: compressionReason,
-		CompressionLevel:  compressionLevel,
-		MessageRatio:      messageRatio,
-		SummaryRatio:      summaryRatio,
+		SessionID:          sessionID,
+		TotalMessages:      totalCount,
+		ActiveMessages:     len(activeMessages),
+		ActiveSummaries:    len(activeSummaries),
+		BulkSummaries:      len(bulkSummaries),
+		SessionDigests:     len(digests),
+		ContextWindowSize:  m.config.ContextWindowSize,
+		MaxBeforeCompress:  m.config.MaxMessagesBeforeCompress,
+		ShouldCompress:     compressionLevel > 0,
+		CompressionReason:  compressionReason,
+		CompressionLevel:   compressionLevel,
+		CompressionTrigger: compressionTrigger,
+		MessageRatio:       load.messageRatio,
+		SummaryRatio:       load.summaryRatio,
+		MessageTokenRatio:  load.messageTokenRatio,
+		SummaryTokenRatio:  load.summaryTokenRatio,
+		BulkTokenRatio:     load.bulkTokenRatio,
+		ActiveAnchors:      m.collectAnchors(digests, bulkSummaries, activeSummaries),
+		ContextMessages:    len(contextMessages),
+		EstimatedTokens:    m.estimateTokens(ctx, contextMessages),
+		MaxContextTokens:   m.config.MaxContextTokens,
 	}, nil
 }
 
 type ContextInfo struct {
-	SessionID         string  `json:"session_id"`
-	TotalMessages     int     `json:"total_messages"`
-	ActiveMessages    int     `json:"active_messages"`
-	ActiveSummaries   int     `json:"active_summaries"`
-	BulkSummaries     int     `json:"bulk_summaries"`
-	ContextWindowSize int     `json:"context_window_size"`
-	MaxBeforeCompress int     `json:"max_before_compress"`
-	ShouldCompress    bool    `json:"should_compress"`
-	CompressionReason string  `json:"compression_reason,omitempty"`
-	CompressionLevel  int     `json:"compression_level,omitempty"`
-	MessageRatio      float64 `json:"message_ratio"`
-	SummaryRatio      float64 `json:"summary_ratio"`
+	SessionID          string   `json:"session_id"`
+	TotalMessages      int      `json:"total_messages"`
+	ActiveMessages     int      `json:"active_messages"`
+	ActiveSummaries    int      `json:"active_summaries"`
+	BulkSummaries      int      `json:"bulk_summaries"`
+	SessionDigests     int      `json:"session_digests"`
+	ContextWindowSize  int      `json:"context_window_size"`
+	MaxBeforeCompress  int      `json:"max_before_compress"`
+	ShouldCompress     bool     `json:"should_compress"`
+	CompressionReason  string   `json:"compression_reason,omitempty"`
+	CompressionLevel   int      `json:"compression_level,omitempty"`
+	CompressionTrigger string   `json:"compression_trigger,omitempty"` // messages или tokens
+	MessageRatio       float64  `json:"message_ratio"`                 // активные сообщения / окно контекста
+	SummaryRatio       float64  `json:"summary_ratio"`
+	MessageTokenRatio  float64  `json:"message_token_ratio,omitempty"` // токены активных сообщений / max_context_tokens
+	SummaryTokenRatio  float64  `json:"summary_token_ratio,omitempty"`
+	BulkTokenRatio     float64  `json:"bulk_token_ratio,omitempty"`
+	ActiveAnchors      []string `json:"active_anchors,omitempty"`
+	ContextMessages    int      `json:"context_messages"`             // сообщения контекста после обрезки, без системного промпта
+	EstimatedTokens    int      `json:"estimated_tokens"`             // токены контекста после обрезки
+	MaxContextTokens   int      `json:"max_context_tokens,omitempty"` // бюджет контекста; 0 - не ограничен
+	Language           string   `json:"language,omitempty"`           // настройка языка сессии, заполняется сервисом чата
 }
 
 // CleanupSession очищает контекст сессии
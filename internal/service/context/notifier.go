@@ -0,0 +1,204 @@
+package context
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// CompressionEvent итог успешного сжатия для внешних наблюдателей
+type CompressionEvent struct {
+	SessionID           string    `json:"session_id"`
+	Level               int       `json:"level"`
+	Reason              string    `json:"reason"`
+	Trigger             string    `json:"trigger,omitempty"`
+	SummaryID           string    `json:"summary_id"`
+	MessagesCompressed  int       `json:"messages_compressed"`
+	SummariesCompressed int       `json:"summaries_compressed"`
+	AnchorsCreated      int       `json:"anchors_created"`
+	TokensUsed          int       `json:"tokens_used"`
+	DurationMs          int64     `json:"duration_ms"`
+	CompressedAt        time.Time `json:"compressed_at"`
+}
+
+// CompressionNotifier получает итог каждого успешного сжатия. NotifyCompression вызывается
+// синхронно из сжатия под блокировкой сессии, поэтому не должен блокироваться: медленную
+// доставку реализация выполняет в фоне, а её ошибки не влияют на запрос чата.
+type CompressionNotifier interface {
+	NotifyCompression(event CompressionEvent)
+}
+
+// SetCompressionNotifier подключает наблюдателя сжатий; nil отключает уведомления.
+// Вызывается при инициализации, до первых запросов.
+func (m *Manager) SetCompressionNotifier(notifier CompressionNotifier) {
+	m.notifier = notifier
+}
+
+// notifyCompression передаёт итог сжатия наблюдателю; сжатие, не создавшее резюме, пропускается
+func (m *Manager) notifyCompression(sessionID string, info *CompressionInfo, summaryID string) {
+	if m.notifier == nil || summaryID == "" {
+		return
+	}
+
+	m.notifier.NotifyCompression(CompressionEvent{
+		SessionID:           sessionID,
+		Level:               info.Level,
+		Reason:              info.Reason,
+		Trigger:             info.Trigger,
+		SummaryID:           summaryID,
+		MessagesCompressed:  info.MessagesCompressed,
+		SummariesCompressed: info.SummariesCompressed,
+		AnchorsCreated:      info.AnchorsCreated,
+		TokensUsed:          info.TokensUsed,
+		DurationMs:          info.Duration.Milliseconds(),
+		CompressedAt:        time.Now().UTC(),
+	})
+}
+
+const (
+	// DefaultWebhookTimeout ограничение одной попытки доставки (chat.compression_webhook_timeout)
+	DefaultWebhookTimeout = 5 * time.Second
+
+	webhookQueueSize  = 100 // недоставленных уведомлений; при переполнении новые отбрасываются
+	webhookMaxRetries = 2
+	webhookRetryDelay = 1 * time.Second // удваивается с каждым повтором
+)
+
+// WebhookNotifier отправляет итоги сжатий POST запросом с JSON телом CompressionEvent.
+// Уведомления доставляются по одному фоновой горутиной: неуспешная попытка (сетевая ошибка,
+// 429 или 5xx) повторяется до webhookMaxRetries раз, после чего уведомление отбрасывается.
+type WebhookNotifier struct {
+	url        string
+	client     *http.Client
+	timeout    time.Duration
+	retryDelay time.Duration // пауза перед первым повтором
+	logger     *zap.Logger
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan CompressionEvent
+	done   chan struct{}
+}
+
+// NewWebhookNotifier создаёт наблюдателя и запускает доставку; timeout <= 0 - DefaultWebhookTimeout
+func NewWebhookNotifier(url string, timeout time.Duration, logger *zap.Logger) *WebhookNotifier {
+	if timeout <= 0 {
+		timeout = DefaultWebhookTimeout
+	}
+
+	n := &WebhookNotifier{
+		url:        url,
+		client:     &http.Client{},
+		timeout:    timeout,
+		retryDelay: webhookRetryDelay,
+		logger:     logger.With(zap.String("component", "compression_webhook")),
+		queue:      make(chan CompressionEvent, webhookQueueSize),
+		done:       make(chan struct{}),
+	}
+	go n.run()
+	return n
+}
+
+// NotifyCompression ставит уведомление в очередь, не дожидаясь доставки; после Close ничего не делает
+func (n *WebhookNotifier) NotifyCompression(event CompressionEvent) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if n.closed {
+		return
+	}
+
+	select {
+	case n.queue <- event:
+	default:
+		n.logger.Warn("Compression webhook queue is full, event dropped",
+			zap.String("session_id", event.SessionID),
+			zap.String("summary_id", event.SummaryID),
+		)
+	}
+}
+
+// Close прекращает приём уведомлений и ждёт доставки поставленных в очередь не дольше ctx
+func (n *WebhookNotifier) Close(ctx context.Context) error {
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.queue)
+	}
+	n.mu.Unlock()
+
+	select {
+	case <-n.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (n *WebhookNotifier) run() {
+	defer close(n.done)
+
+	for event := range n.queue {
+		if err := n.deliver(event); err != nil {
+			n.logger.Warn("Compression webhook delivery failed",
+				zap.String("session_id", event.SessionID),
+				zap.String("summary_id", event.SummaryID),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// deliver отправляет уведомление с повторами временных ошибок
+func (n *WebhookNotifier) deliver(event CompressionEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal compression event: %w", err)
+	}
+
+	delay := n.retryDelay
+	for attempt := 0; ; attempt++ {
+		retryable, err := n.post(body)
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt == webhookMaxRetries {
+			return fmt.Errorf("attempt %d: %w", attempt+1, err)
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// post выполняет одну попытку; retryable - стоит ли её повторять
+func (n *WebhookNotifier) post(body []byte) (retryable bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), n.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("request failed: %w", err)
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,114 @@
+package context
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// webhookRecorder принимающая сторона вебхука: отвечает статусами statuses по очереди
+// (последний - на все остальные запросы) и запоминает запросы
+type webhookRecorder struct {
+	statuses []int
+
+	mu     sync.Mutex
+	events []CompressionEvent
+}
+
+func (rec *webhookRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var event CompressionEvent
+	_ = json.NewDecoder(r.Body).Decode(&event)
+
+	rec.mu.Lock()
+	rec.events = append(rec.events, event)
+	status := rec.statuses[min(len(rec.events), len(rec.statuses))-1]
+	rec.mu.Unlock()
+
+	w.WriteHeader(status)
+}
+
+func (rec *webhookRecorder) received() []CompressionEvent {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]CompressionEvent(nil), rec.events...)
+}
+
+// webhookServer тестовый вебхук, закрывается по окончании теста
+func webhookServer(t *testing.T, statuses ...int) (*httptest.Server, *webhookRecorder) {
+	t.Helper()
+
+	rec := &webhookRecorder{statuses: statuses}
+	srv := httptest.NewServer(rec)
+	t.Cleanup(srv.Close)
+	return srv, rec
+}
+
+// newTestWebhook наблюдатель без фоновой доставки и с короткой паузой между повторами
+func newTestWebhook(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:        url,
+		client:     &http.Client{},
+		timeout:    time.Second,
+		retryDelay: time.Millisecond,
+		logger:     zap.NewNop(),
+	}
+}
+
+func TestWebhookRetriesServerErrors(t *testing.T) {
+	srv, rec := webhookServer(t, http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusOK)
+
+	if err := newTestWebhook(srv.URL).deliver(CompressionEvent{SessionID: "s1", Level: 1}); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	events := rec.received()
+	if len(events) != 3 {
+		t.Fatalf("requests = %d, want 3", len(events))
+	}
+	if last := events[2]; last.SessionID != "s1" || last.Level != 1 {
+		t.Errorf("delivered event = %+v", last)
+	}
+
+	// Повторы ограничены webhookMaxRetries
+	srv, rec = webhookServer(t, http.StatusBadGateway)
+	if err := newTestWebhook(srv.URL).deliver(CompressionEvent{SessionID: "s1"}); err == nil {
+		t.Error("deliver to a failing webhook succeeded")
+	}
+	if n := len(rec.received()); n != webhookMaxRetries+1 {
+		t.Errorf("requests to a failing webhook = %d, want %d", n, webhookMaxRetries+1)
+	}
+}
+
+func TestWebhookDoesNotRetryClientErrors(t *testing.T) {
+	srv, rec := webhookServer(t, http.StatusBadRequest, http.StatusOK)
+
+	if err := newTestWebhook(srv.URL).deliver(CompressionEvent{SessionID: "s1"}); err == nil {
+		t.Error("deliver succeeded after 400")
+	}
+	if n := len(rec.received()); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+}
+
+func TestWebhookNotifierDeliversQueuedEvents(t *testing.T) {
+	srv, rec := webhookServer(t, http.StatusNoContent)
+	notifier := NewWebhookNotifier(srv.URL, time.Second, zap.NewNop())
+
+	notifier.NotifyCompression(CompressionEvent{SessionID: "s1", SummaryID: "sum1"})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := notifier.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if events := rec.received(); len(events) != 1 || events[0].SummaryID != "sum1" {
+		t.Errorf("delivered events = %+v", events)
+	}
+	// После Close уведомление отбрасывается, а не отправляется в закрытую очередь
+	notifier.NotifyCompression(CompressionEvent{SessionID: "s1", SummaryID: "sum2"})
+}
@@ -0,0 +1,198 @@
+package context
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"LLM_Chat/internal/storage/models"
+	"LLM_Chat/pkg/llm"
+	"LLM_Chat/pkg/tokens"
+
+	"go.uber.org/zap"
+)
+
+// recallIndexTimeout время на вычисление и сохранение эмбеддингов сообщений в фоне
+const recallIndexTimeout = 30 * time.Second
+
+// recallHeader заголовок блока с найденными сжатыми сообщениями
+const recallHeader = "Recalled from earlier in the conversation. These original messages were compressed into summaries; use them only if they are relevant to the current question:"
+
+// Embedder вычисляет эмбеддинги текста (llm.Client)
+type Embedder interface {
+	Embed(ctx context.Context, model string, texts []string) ([][]float32, error)
+}
+
+// RecallConfig семантический поиск по сжатой истории: сообщения сохраняются с эмбеддингами,
+// и сжатые сообщения, близкие к текущему вопросу, возвращаются в контекст отдельным блоком
+type RecallConfig struct {
+	Enabled        bool
+	EmbeddingModel string
+	TopK           int     // максимум сообщений в блоке
+	MinSimilarity  float64 // порог косинусного сходства с вопросом
+	MaxTokens      int     // бюджет блока в токенах (эвристическая оценка)
+}
+
+func (m *Manager) recallEnabled() bool {
+	return m.config.Recall.Enabled && m.embedder != nil
+}
+
+// IndexMessages сохраняет эмбеддинги сообщений в фоне, не задерживая ответ.
+// Ошибки только логируются: сообщение без эмбеддинга просто не участвует в поиске.
+func (m *Manager) IndexMessages(messages ...models.Message) {
+	if !m.recallEnabled() {
+		return
+	}
+
+	var batch []models.Message
+	for _, msg := range messages {
+		if msg.IsRegular() && (msg.Role == "user" || msg.Role == "assistant") && strings.TrimSpace(msg.Content) != "" {
+			batch = append(batch, msg)
+		}
+	}
+	if len(batch) == 0 {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), recallIndexTimeout)
+		defer cancel()
+
+		if err := m.indexMessages(ctx, batch); err != nil {
+			m.logger.Warn("Failed to index messages for recall",
+				zap.String("session_id", batch[0].SessionID),
+				zap.Int("messages", len(batch)),
+				zap.Error(err),
+			)
+		}
+	}()
+}
+
+func (m *Manager) indexMessages(ctx context.Context, messages []models.Message) error {
+	texts := make([]string, len(messages))
+	for i, msg := range messages {
+		texts[i] = msg.Content
+	}
+
+	model := m.config.Recall.EmbeddingModel
+	vectors, err := m.embedder.Embed(ctx, model, texts)
+	if err != nil {
+		return err
+	}
+	if len(vectors) != len(messages) {
+		return fmt.Errorf("embedder returned %d vectors for %d messages", len(vectors), len(messages))
+	}
+
+	embeddings := make([]models.MessageEmbedding, 0, len(messages))
+	for i, msg := range messages {
+		if len(vectors[i]) == 0 {
+			continue
+		}
+		embeddings = append(embeddings, models.MessageEmbedding{
+			MessageID: msg.ID,
+			SessionID: msg.SessionID,
+			Model:     model,
+			Vector:    vectors[i],
+		})
+	}
+
+	return m.messageStore.SaveMessageEmbeddings(ctx, embeddings)
+}
+
+// recallMessages возвращает сжатые сообщения, близкие к вопросу, в хронологическом порядке.
+// Кандидаты выбираются по убыванию сходства, пока не наберётся TopK или не кончится бюджет токенов.
+func (m *Manager) recallMessages(ctx context.Context, sessionID, query string) ([]models.Message, error) {
+	if !m.recallEnabled() || strings.TrimSpace(query) == "" {
+		return nil, nil
+	}
+
+	cfg := m.config.Recall
+	candidates, err := m.messageStore.GetCompressedMessageEmbeddings(ctx, sessionID, cfg.EmbeddingModel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message embeddings: %w", err)
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	vectors, err := m.embedder.Embed(ctx, cfg.EmbeddingModel, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	if len(vectors) == 0 || len(vectors[0]) == 0 {
+		return nil, nil
+	}
+
+	type scored struct {
+		msg        models.Message
+		similarity float64
+	}
+	var matches []scored
+	for _, candidate := range candidates {
+		similarity := cosineSimilarity(vectors[0], candidate.Vector)
+		if similarity >= cfg.MinSimilarity {
+			matches = append(matches, scored{msg: candidate.Message, similarity: similarity})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].similarity > matches[j].similarity
+	})
+
+	var recalled []models.Message
+	budget := cfg.MaxTokens - tokens.Estimate(recallHeader)
+	for _, match := range matches {
+		if len(recalled) >= cfg.TopK {
+			break
+		}
+		cost := tokens.Estimate(match.msg.Content) + tokens.MessageOverhead
+		if cost > budget {
+			continue
+		}
+		budget -= cost
+		recalled = append(recalled, match.msg)
+	}
+
+	sort.SliceStable(recalled, func(i, j int) bool {
+		return recalled[i].Timestamp.Before(recalled[j].Timestamp)
+	})
+
+	m.logger.Debug("Compressed messages recalled",
+		zap.String("session_id", sessionID),
+		zap.Int("candidates", len(candidates)),
+		zap.Int("above_threshold", len(matches)),
+		zap.Int("recalled", len(recalled)),
+	)
+
+	return recalled, nil
+}
+
+// recallBlock системное сообщение с найденными сжатыми сообщениями
+func recallBlock(messages []models.Message) llm.Message {
+	var b strings.Builder
+	b.WriteString(recallHeader)
+	for _, msg := range messages {
+		fmt.Fprintf(&b, "\n\n[%s] %s: %s", msg.Timestamp.UTC().Format("2006-01-02 15:04"), msg.Role, msg.Content)
+	}
+	return llm.Message{Role: "system", Content: b.String()}
+}
+
+// cosineSimilarity косинусное сходство векторов; векторы разной длины несравнимы
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
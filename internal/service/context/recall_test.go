@@ -0,0 +1,108 @@
+package context
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// keywordEmbedder детерминированные эмбеддинги: измерение на каждое ключевое слово
+// и постоянная составляющая, чтобы вектор текста без ключевых слов не был нулевым
+type keywordEmbedder struct {
+	keywords []string
+}
+
+func (e keywordEmbedder) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		text = strings.ToLower(text)
+		vector := make([]float32, len(e.keywords)+1)
+		for j, keyword := range e.keywords {
+			if strings.Contains(text, keyword) {
+				vector[j] = 1
+			}
+		}
+		vector[len(e.keywords)] = 0.1
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+func TestRecallBuriedDetail(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.Recall.Enabled = true
+	embedder := keywordEmbedder{keywords: []string{"order", "weather", "pizza"}}
+	manager, store, _ := newTestManager(t, cfg, embedder)
+
+	contents := numberedDialog(40)
+	contents[2] = "My order number is 48213, please keep it in mind"
+	contents[6] = "What is the weather like today?"
+	contents[10] = "I would like to order a pizza"
+	messages := seedDialog(t, store, "s1", contents...)
+
+	if err := manager.indexMessages(ctx, messages); err != nil {
+		t.Fatalf("indexMessages: %v", err)
+	}
+	info, err := manager.Compress(ctx, "s1", CompressOptions{Force: true})
+	if err != nil || !info.Triggered {
+		t.Fatalf("Compress: info = %+v, err = %v", info, err)
+	}
+
+	resp, err := manager.BuildContext(ctx, ContextRequest{SessionID: "s1", Query: "Remind me my order number?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := contextText(resp.Messages)
+	if !strings.Contains(text, recallHeader) || !strings.Contains(text, "48213") {
+		t.Fatalf("buried detail was not recalled:\n%s", text)
+	}
+	if strings.Contains(text, "weather") {
+		t.Errorf("unrelated compressed message was recalled:\n%s", text)
+	}
+	// "order a pizza" делит с вопросом ключевое слово, но его сходство ниже порога
+	if resp.RecalledMessages != 1 {
+		t.Errorf("RecalledMessages = %d, want 1", resp.RecalledMessages)
+	}
+}
+
+func TestRecallToleratesMissingEmbeddings(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.Recall.Enabled = true
+	manager, store, _ := newTestManager(t, cfg, keywordEmbedder{keywords: []string{"order"}})
+
+	contents := numberedDialog(30)
+	contents[2] = "My order number is 48213"
+	seedDialog(t, store, "s1", contents...)
+
+	// Сообщения сохранены до включения поиска и не проиндексированы
+	if _, err := manager.Compress(ctx, "s1", CompressOptions{Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := manager.BuildContext(ctx, ContextRequest{SessionID: "s1", Query: "my order number?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.RecalledMessages != 0 || strings.Contains(contextText(resp.Messages), recallHeader) {
+		t.Errorf("recall without embeddings: RecalledMessages = %d", resp.RecalledMessages)
+	}
+}
+
+func TestRecallDisabledByDefault(t *testing.T) {
+	if DefaultConfig().Recall.Enabled {
+		t.Fatal("recall must be disabled by default")
+	}
+
+	ctx := context.Background()
+	manager, store, _ := newTestManager(t, DefaultConfig(), keywordEmbedder{keywords: []string{"order"}})
+	messages := seedDialog(t, store, "s1", "My order number is 48213", "ok", "thanks")
+
+	// Выключенный поиск не индексирует сообщения и ничего не возвращает
+	manager.IndexMessages(messages...)
+	recalled, err := manager.recallMessages(ctx, "s1", "order number")
+	if err != nil || recalled != nil {
+		t.Errorf("recallMessages = %v, %v; want nothing", recalled, err)
+	}
+}
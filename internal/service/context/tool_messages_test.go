@@ -0,0 +1,87 @@
+package context
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"LLM_Chat/internal/storage/models"
+)
+
+func TestCompressionWithToolMessages(t *testing.T) {
+	tests := []struct {
+		name         string
+		exclude      bool
+		wantInDigest []string
+		notInDigest  []string
+	}{
+		{
+			name:         "rendered",
+			wantInDigest: []string{"Инструмент weather вернул: {\"temp\": 21", "…", "Пользователь: сообщение 2"},
+			notInDigest:  []string{"tail-marker"},
+		},
+		{
+			name:         "excluded",
+			exclude:      true,
+			wantInDigest: []string{"Пользователь: сообщение 2"},
+			notInDigest:  []string{"weather", "temp"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			cfg := DefaultConfig()
+			cfg.ExcludeToolMessages = tt.exclude
+			manager, store, shrink := newTestManager(t, cfg, nil)
+
+			contents := make([]string, 12)
+			for i := range contents {
+				contents[i] = fmt.Sprintf("сообщение %d", i)
+			}
+			seedDialog(t, store, "s1", contents...)
+			// Вызов инструмента между вопросом 2 и ответом 3
+			result := `{"temp": 21, "forecast": "` + strings.Repeat("sunny ", 100) + `tail-marker"}`
+			tool := models.NewToolMessage("s1", result, "weather", "call-1")
+			tool.ID = "s1-tool"
+			tool.Timestamp = baseTime.Add(2*time.Minute + 30*time.Second)
+			if err := store.SaveMessage(ctx, tool); err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := manager.Compress(ctx, "s1", CompressOptions{Force: true})
+			if err != nil || !info.Triggered {
+				t.Fatalf("Compress: info = %+v, err = %v", info, err)
+			}
+
+			if shrink.calls() != 1 {
+				t.Fatalf("shrink requests = %d, want 1", shrink.calls())
+			}
+			prompt := contextText(shrink.requests[0])
+			for _, want := range tt.wantInDigest {
+				if !strings.Contains(prompt, want) {
+					t.Errorf("summary prompt lacks %q:\n%s", want, prompt)
+				}
+			}
+			for _, unwanted := range tt.notInDigest {
+				if strings.Contains(prompt, unwanted) {
+					t.Errorf("summary prompt contains %q:\n%s", unwanted, prompt)
+				}
+			}
+
+			// В обоих режимах сообщение инструмента сжато вместе со своей частью диалога
+			tools, err := store.GetActiveToolMessages(ctx, "s1", time.Time{}, baseTime.Add(time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tools) != 0 {
+				t.Errorf("active tool messages after compression = %d, want 0", len(tools))
+			}
+			// Счётчик сжатых сообщений учитывает и сообщение инструмента
+			if info.MessagesCompressed != 8 {
+				t.Errorf("messages compressed = %d, want 7 dialog messages and the tool message", info.MessagesCompressed)
+			}
+		})
+	}
+}
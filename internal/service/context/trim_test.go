@@ -0,0 +1,142 @@
+package context
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"LLM_Chat/internal/storage/models"
+	"LLM_Chat/pkg/llm"
+)
+
+func TestBuildContextTrimsSummariesBeforeDialogue(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.ContextWindowSize = 10
+	manager, store, _ := newTestManager(t, cfg, nil)
+
+	// Накопленных резюме больше, чем помещается в окно: раньше они вытесняли весь диалог
+	for i := 0; i < 12; i++ {
+		err := store.SaveSummary(ctx, models.Summary{
+			ID:           fmt.Sprintf("sum%02d", i),
+			SessionID:    "s1",
+			SummaryText:  fmt.Sprintf("summary %d", i),
+			SummaryLevel: 1,
+			UpdatedAt:    baseTime.Add(time.Duration(i) * time.Second),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	seedDialog(t, store, "s1", "question 1", "answer 1", "question 2")
+
+	messages, _, _, err := manager.buildLLMContext(ctx, ContextRequest{SessionID: "s1", SystemPrompt: "prompt", IncludeSystem: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) != cfg.ContextWindowSize {
+		t.Fatalf("context size = %d, want %d:\n%s", len(messages), cfg.ContextWindowSize, contextText(messages))
+	}
+	if messages[0].Content != "prompt" {
+		t.Errorf("system prompt was trimmed:\n%s", contextText(messages))
+	}
+	text := contextText(messages)
+	for _, want := range []string{"question 1", "answer 1", "question 2", "summary 11", "summary 6"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("context lacks %q:\n%s", want, text)
+		}
+	}
+	// Самые старые резюме вытесняются первыми
+	if strings.Contains(text, "summary 5") {
+		t.Errorf("oldest summary was kept:\n%s", text)
+	}
+}
+
+func TestBuildContextKeepsDigestAndBulkSummaries(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.ContextWindowSize = 10
+	manager, store, _ := newTestManager(t, cfg, nil)
+
+	save := func(id, text string, level, i int) {
+		err := store.SaveSummary(ctx, models.Summary{
+			ID:           id,
+			SessionID:    "s1",
+			SummaryText:  text,
+			SummaryLevel: level,
+			UpdatedAt:    baseTime.Add(time.Duration(i) * time.Second),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	save("digest", "session digest", 3, 0)
+	save("bulk0", "bulk summary 0", 2, 1)
+	save("bulk1", "bulk summary 1", 2, 2)
+	for i := 0; i < 12; i++ {
+		save(fmt.Sprintf("sum%02d", i), fmt.Sprintf("summary %02d", i), 1, 10+i)
+	}
+	seedDialog(t, store, "s1", "question 1", "answer 1", "question 2")
+
+	messages, _, _, err := manager.buildLLMContext(ctx, ContextRequest{SessionID: "s1", SystemPrompt: "prompt", IncludeSystem: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) != cfg.ContextWindowSize {
+		t.Fatalf("context size = %d, want %d:\n%s", len(messages), cfg.ContextWindowSize, contextText(messages))
+	}
+	// Дайджест и bulk резюме стоят в начале контекста, но вытесняются резюме первого уровня
+	text := contextText(messages)
+	for _, want := range []string{"prompt", "session digest", "bulk summary 0", "bulk summary 1", "summary 09", "summary 11", "question 1", "question 2"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("context lacks %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "summary 08") {
+		t.Errorf("oldest level 1 summary was kept:\n%s", text)
+	}
+}
+
+func TestTrimToTokenBudgetTrimsSummaries(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxContextTokens = 60
+	manager, _, _ := newTestManager(t, cfg, nil)
+
+	messages := []llm.Message{
+		{Role: "system", Content: "prompt"},
+		{Role: "system", Content: strings.Repeat("old summary ", 20)},
+		{Role: "system", Content: "recent summary"},
+		{Role: "user", Content: "question"},
+	}
+	trimmed := manager.trimToTokenBudget(context.Background(), messages, 1)
+
+	text := contextText(trimmed)
+	if strings.Contains(text, "old summary") {
+		t.Errorf("old summary was not trimmed:\n%s", text)
+	}
+	for _, want := range []string{"prompt", "recent summary", "question"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("context lacks %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestTrimToWindowKeepsLastMessage(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ContextWindowSize = 1
+	manager, _, _ := newTestManager(t, cfg, nil)
+
+	messages := []llm.Message{
+		{Role: "system", Content: "prompt"},
+		{Role: "system", Content: "summary"},
+		{Role: "user", Content: "question"},
+	}
+	trimmed := manager.trimToWindow(messages, 1)
+	if got := contextText(trimmed); got != "system: prompt\nuser: question\n" {
+		t.Errorf("trimmed context:\n%s", got)
+	}
+}
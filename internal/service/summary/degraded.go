@@ -0,0 +1,123 @@
+package summary
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"LLM_Chat/internal/storage/models"
+	"LLM_Chat/pkg/llm"
+
+	"go.uber.org/zap"
+)
+
+// degradedSentenceMaxLength предел одного предложения в резервном резюме, в символах
+const degradedSentenceMaxLength = 150
+
+// summarizeWithFallback создаёт якоря и резюме, повторяя попытку один раз. Временные ошибки
+// провайдера уже повторил клиент модели сжатия (llm.request_retry), поэтому здесь повторяются
+// остальные: неразобранный или пустой ответ модели и другие постоянные ошибки. Если модель
+// не ответила и при повторе, возвращает резервное резюме без модели (degraded = true), чтобы
+// контекст сессии не рос без ограничений из-за недоступности модели. Отмена ctx не маскируется.
+func (s *Service) summarizeWithFallback(ctx context.Context, req SummaryRequest, lang string, tokensUsed *int) (anchors []string, briefSummary string, degraded bool, err error) {
+	anchors, briefSummary, err = s.summarize(ctx, req, lang, tokensUsed)
+	if err == nil {
+		return anchors, briefSummary, false, nil
+	}
+	if ctx.Err() != nil {
+		return nil, "", false, err
+	}
+
+	if !retriedByClient(err) {
+		s.logger.Warn("Summary creation failed, retrying",
+			zap.String("session_id", req.SessionID),
+			zap.Int("summary_level", req.SummaryLevel),
+			zap.Error(err),
+		)
+
+		anchors, briefSummary, err = s.summarize(ctx, req, lang, tokensUsed)
+		if err == nil {
+			return anchors, briefSummary, false, nil
+		}
+		if ctx.Err() != nil {
+			return nil, "", false, err
+		}
+	}
+
+	s.logger.Error("Summary creation failed, creating degraded summary without the model",
+		zap.String("session_id", req.SessionID),
+		zap.Int("summary_level", req.SummaryLevel),
+		zap.Int("items", len(req.Messages)),
+		zap.Error(err),
+	)
+	return nil, s.degradedSummary(req.Messages, req.SummaryLevel, lang), true, nil
+}
+
+// retriedByClient сообщает, что ошибку уже повторил клиент модели сжатия (временные ошибки
+// провайдера) или что повтор её не исправит (разомкнутый выключатель, очередь запросов)
+func retriedByClient(err error) bool {
+	for _, target := range append([]error{llm.ErrCircuitOpen, llm.ErrQueueTimeout}, llm.DefaultRetryableErrors...) {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// degradedSummary резервное резюме: помеченный список первых предложений сжимаемых сообщений
+// (или резюме), обрезанный до SummaryMaxLength
+func (s *Service) degradedSummary(messages []models.Message, summaryLevel int, lang string) string {
+	labels := labelsFor(lang)
+
+	var b strings.Builder
+	b.WriteString(labels.degraded)
+	for _, msg := range messages {
+		if msg.Role == "tool" {
+			continue
+		}
+		sentence := firstSentence(msg.Content)
+		if sentence == "" {
+			continue
+		}
+
+		b.WriteString("\n")
+		if summaryLevel == 1 {
+			b.WriteString(labels.role(msg.Role))
+			b.WriteString(": ")
+		} else {
+			b.WriteString("- ")
+		}
+		b.WriteString(sentence)
+
+		if b.Len() > s.config.SummaryMaxLength {
+			break
+		}
+	}
+
+	return s.truncateSummary(b.String())
+}
+
+// firstSentence первое предложение текста (до знака конца предложения или перевода строки),
+// не длиннее degradedSentenceMaxLength символов
+func firstSentence(text string) string {
+	text = strings.TrimSpace(text)
+	if newline := strings.IndexByte(text, '\n'); newline >= 0 {
+		text = strings.TrimSpace(text[:newline])
+	}
+	for i, r := range text {
+		if r == '.' || r == '!' || r == '?' {
+			end := i + utf8.RuneLen(r)
+			if end == len(text) || text[end] == ' ' {
+				text = text[:end]
+				break
+			}
+		}
+	}
+
+	if utf8.RuneCountInString(text) > degradedSentenceMaxLength {
+		runes := []rune(text)
+		text = string(runes[:degradedSentenceMaxLength]) + "…"
+	}
+	return text
+}
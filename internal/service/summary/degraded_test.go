@@ -0,0 +1,117 @@
+package summary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"LLM_Chat/internal/storage/models"
+	"LLM_Chat/pkg/llm"
+
+	"go.uber.org/zap"
+)
+
+// failingLLM модель сжатия, первые failures запросов к которой (все при failures = 0) завершаются
+// ошибкой err, а остальные получают структурированный ответ
+type failingLLM struct {
+	llm.LLMClient
+	err      error
+	failures int
+	calls    int
+}
+
+func (c *failingLLM) ChatCompletion(ctx context.Context, messages []llm.Message) (*llm.ChatResponse, error) {
+	c.calls++
+	if c.failures == 0 || c.calls <= c.failures {
+		err := c.err
+		if err == nil {
+			err = errors.New("model unavailable")
+		}
+		return nil, err
+	}
+	return &llm.ChatResponse{
+		Choices: []llm.Choice{{Message: llm.Message{Role: "assistant", Content: `{"anchors": ["Настройка сервера"], "summary": "Пользователь настраивает сервер."}`}}},
+	}, nil
+}
+
+func TestDegradedSummaryAfterRetry(t *testing.T) {
+	messages := []models.Message{
+		{ID: "1", Role: "user", Content: "Привет. Как настроить сервер?"},
+		{ID: "2", Role: "tool", Content: "{}"},
+		{ID: "3", Role: "assistant", Content: "Нужно 3.5 ГБ памяти! Затем ещё"},
+		{ID: "4", Role: "user", Content: strings.Repeat("я", 400)},
+	}
+	client := &failingLLM{}
+	cfg := DefaultConfig()
+	cfg.SummaryMaxLength = 300
+	service := NewService(nil, client, nil, nil, cfg, zap.NewNop())
+
+	resp, err := service.CreateSummary(context.Background(), SummaryRequest{SessionID: "s", Messages: messages, SummaryLevel: 1, SkipSave: true})
+	if err != nil {
+		t.Fatalf("CreateSummary: %v", err)
+	}
+	if !resp.Degraded {
+		t.Error("summary is not marked as degraded")
+	}
+	// Постоянная ошибка повторяется сервисом один раз
+	if client.calls != 2 {
+		t.Errorf("model calls = %d, want 2", client.calls)
+	}
+
+	summary := resp.BriefSummary
+	if !strings.HasPrefix(summary, "[Резервное") ||
+		!strings.Contains(summary, "Пользователь: Привет.") ||
+		!strings.Contains(summary, "Ассистент: Нужно 3.5 ГБ памяти!\n") {
+		t.Errorf("unexpected degraded summary:\n%s", summary)
+	}
+	if len(summary) > cfg.SummaryMaxLength || !utf8.ValidString(summary) {
+		t.Errorf("degraded summary is not truncated correctly: %d bytes", len(summary))
+	}
+}
+
+func TestDegradedSummaryKeepsCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	service := NewService(nil, &failingLLM{}, nil, nil, DefaultConfig(), zap.NewNop())
+	messages := []models.Message{{ID: "1", Role: "user", Content: "a"}, {ID: "2", Role: "assistant", Content: "b"}}
+	if _, err := service.CreateSummary(ctx, SummaryRequest{SessionID: "s", Messages: messages, SummaryLevel: 1, SkipSave: true}); err == nil {
+		t.Fatal("canceled request produced a degraded summary")
+	}
+}
+
+func TestSummaryRetrySucceeds(t *testing.T) {
+	client := &failingLLM{err: errors.New("malformed response"), failures: 1}
+	service := NewService(nil, client, nil, nil, DefaultConfig(), zap.NewNop())
+
+	resp, err := service.CreateSummary(context.Background(), SummaryRequest{SessionID: "s", Messages: dialog(), SummaryLevel: 1, SkipSave: true})
+	if err != nil {
+		t.Fatalf("CreateSummary: %v", err)
+	}
+	if resp.Degraded || resp.BriefSummary != "Пользователь настраивает сервер." {
+		t.Errorf("response = %q, degraded %v; want the summary of the retry", resp.BriefSummary, resp.Degraded)
+	}
+	if client.calls != 2 {
+		t.Errorf("model calls = %d, want 2", client.calls)
+	}
+}
+
+func TestSummaryTransientErrorNotRetriedByService(t *testing.T) {
+	// Временные ошибки провайдера повторяет клиент модели сжатия (llm.request_retry)
+	client := &failingLLM{err: fmt.Errorf("%w: service unavailable", llm.ErrUpstream)}
+	service := NewService(nil, client, nil, nil, DefaultConfig(), zap.NewNop())
+
+	resp, err := service.CreateSummary(context.Background(), SummaryRequest{SessionID: "s", Messages: dialog(), SummaryLevel: 1, SkipSave: true})
+	if err != nil {
+		t.Fatalf("CreateSummary: %v", err)
+	}
+	if !resp.Degraded {
+		t.Error("summary is not marked as degraded")
+	}
+	if client.calls != 1 {
+		t.Errorf("model calls = %d, want 1", client.calls)
+	}
+}
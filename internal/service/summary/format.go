@@ -0,0 +1,43 @@
+package summary
+
+import (
+	"strings"
+
+	"LLM_Chat/internal/storage/models"
+)
+
+// anchorsHeader заголовок списка якорей резюме в контексте модели
+const anchorsHeader = "Ключевые темы:"
+
+// FormatForContext текст резюме для контекста модели: заголовок label (если задан), якоря списком
+// "Ключевые темы" и текст резюме. Используется для резюме обоих уровней.
+func FormatForContext(summary models.Summary, label string) string {
+	var b strings.Builder
+
+	if label != "" {
+		b.WriteString(label)
+		b.WriteString(":\n\n")
+	}
+
+	anchors := make([]string, 0, len(summary.Anchors))
+	for _, anchor := range summary.Anchors {
+		if anchor = strings.TrimSpace(anchor); anchor != "" {
+			anchors = append(anchors, anchor)
+		}
+	}
+	if len(anchors) > 0 {
+		b.WriteString(anchorsHeader)
+		b.WriteString("\n")
+		for _, anchor := range anchors {
+			b.WriteString("- ")
+			b.WriteString(anchor)
+			b.WriteString("\n")
+		}
+		if summary.SummaryText != "" {
+			b.WriteString("\n")
+		}
+	}
+
+	b.WriteString(summary.SummaryText)
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -10,6 +10,8 @@ import (
 type SummaryService interface {
 	ShouldCreateSummary(ctx context.Context, sessionID string, messageCount int) (bool, string)
 	CreateSummary(ctx context.Context, req SummaryRequest) (*SummaryResponse, error)
+	// MinMessagesForSummary минимум сообщений, из которых CreateSummary создаёт резюме
+	MinMessagesForSummary() int
 	UpdateSummary(ctx context.Context, sessionID string, newMessages []models.Message) (*SummaryResponse, error)
 	GetSummary(ctx context.Context, sessionID string) (*models.Summary, error)
 	GetContextForLLM(ctx context.Context, sessionID string, recentMessages []models.Message) ([]llm.Message, error)
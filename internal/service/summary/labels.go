@@ -0,0 +1,119 @@
+package summary
+
+import (
+	"fmt"
+	"strings"
+
+	"LLM_Chat/internal/language"
+	"LLM_Chat/internal/storage/models"
+)
+
+// dialogLabels подписи исходного текста в запросе резюме на языке резюме
+type dialogLabels struct {
+	roles       map[string]string
+	participant string // роль без подписи
+	toolResult  string // формат: имя инструмента, результат
+
+	anchorsDialog    string // заголовок диалога в запросе якорей
+	anchorsSummaries string // заголовок резюме в запросе якорей
+	summaryDialog    string // заголовок диалога в запросе резюме
+	summarySummaries string // заголовок резюме в запросе резюме
+	summaryItem      string // формат: номер резюме
+	degraded         string // пометка резервного резюме, составленного без модели
+}
+
+var labelsByLanguage = map[string]dialogLabels{
+	"ru": {
+		roles: map[string]string{
+			"user":      "Пользователь",
+			"assistant": "Ассистент",
+			"tool":      "Инструмент",
+			"system":    "Система",
+		},
+		participant:      "Участник",
+		toolResult:       "Инструмент %s вернул: %s",
+		anchorsDialog:    "Диалог для анализа:",
+		anchorsSummaries: "Резюме для анализа:",
+		summaryDialog:    "Диалог для резюмирования:",
+		summarySummaries: "Резюме для объединения:",
+		summaryItem:      "Резюме %d",
+		degraded:         "[Резервное резюме без модели: первые предложения сообщений]",
+	},
+	"en": {
+		roles: map[string]string{
+			"user":      "User",
+			"assistant": "Assistant",
+			"tool":      "Tool",
+			"system":    "System",
+		},
+		participant:      "Participant",
+		toolResult:       "Tool %s returned: %s",
+		anchorsDialog:    "Dialogue to analyze:",
+		anchorsSummaries: "Summaries to analyze:",
+		summaryDialog:    "Dialogue to summarize:",
+		summarySummaries: "Summaries to merge:",
+		summaryItem:      "Summary %d",
+		degraded:         "[Fallback summary without the model: first sentences of the messages]",
+	},
+	"kk": {
+		roles: map[string]string{
+			"user":      "Пайдаланушы",
+			"assistant": "Ассистент",
+			"tool":      "Құрал",
+			"system":    "Жүйе",
+		},
+		participant:      "Қатысушы",
+		toolResult:       "%s құралы қайтарды: %s",
+		anchorsDialog:    "Талдауға арналған диалог:",
+		anchorsSummaries: "Талдауға арналған түйіндемелер:",
+		summaryDialog:    "Түйіндеме жасауға арналған диалог:",
+		summarySummaries: "Біріктіруге арналған түйіндемелер:",
+		summaryItem:      "Түйіндеме %d",
+		degraded:         "[Модельсіз резервтік түйіндеме: хабарламалардың алғашқы сөйлемдері]",
+	},
+}
+
+// labelsFor подписи для языка резюме; неизвестный или не определённый язык - русские
+func labelsFor(lang string) dialogLabels {
+	if labels, ok := labelsByLanguage[lang]; ok {
+		return labels
+	}
+	return labelsByLanguage[defaultPromptLanguage]
+}
+
+// role отображаемое имя роли
+func (l dialogLabels) role(role string) string {
+	if name, ok := l.roles[role]; ok {
+		return name
+	}
+	return l.participant
+}
+
+// dialogText исходный текст запроса: диалог построчно или пронумерованные резюме (уровни 2 и 3)
+func (s *Service) dialogText(messages []models.Message, summaryLevel int, labels dialogLabels, dialogHeader, summariesHeader string) string {
+	var b strings.Builder
+	if summaryLevel >= 2 {
+		b.WriteString(summariesHeader)
+		b.WriteString("\n\n")
+		for i, msg := range messages {
+			fmt.Fprintf(&b, labels.summaryItem+":\n%s\n\n", i+1, msg.Content)
+		}
+		return b.String()
+	}
+
+	b.WriteString(dialogHeader)
+	b.WriteString("\n\n")
+	for _, msg := range messages {
+		b.WriteString(s.dialogLine(msg, labels))
+	}
+	return b.String()
+}
+
+// appendLanguageDirective добавляет к промпту инструкцию о языке резюме; для английского она
+// на английском, как и встроенные английские шаблоны
+func appendLanguageDirective(prompt, lang string) string {
+	if lang == "en" {
+		return prompt + "\n\nAlways write in English, regardless of the language of the source text and of these instructions."
+	}
+	return language.AppendDirective(prompt, lang)
+}
@@ -0,0 +1,90 @@
+package summary
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"LLM_Chat/internal/storage/models"
+
+	"go.uber.org/zap"
+)
+
+func TestSummaryLanguage(t *testing.T) {
+	english := []models.Message{
+		{ID: "1", Role: "user", Content: "How do I configure nginx as a reverse proxy?"},
+		{ID: "2", Role: "assistant", Content: "Use proxy_pass inside a location block."},
+		{ID: "3", Role: "tool", ToolName: "search", Content: `{"results": 3}`},
+		{ID: "4", Role: "user", Content: "Thanks, and how do I enable TLS?"},
+	}
+	// Пользователь пишет по-русски, ассистент отвечает длинными английскими цитатами:
+	// язык определяется по сообщениям пользователя
+	mixed := []models.Message{
+		{ID: "1", Role: "user", Content: "Как настроить nginx?"},
+		{ID: "2", Role: "assistant", Content: "Here is the official documentation excerpt: use proxy_pass inside a location block and reload the server afterwards."},
+		{ID: "3", Role: "user", Content: "Спасибо, а как включить HTTPS?"},
+	}
+
+	tests := []struct {
+		name        string
+		language    string
+		messages    []models.Message
+		promptStart string
+		directive   string
+		dialog      []string
+		unwanted    string
+	}{
+		{
+			name:        "english transcript",
+			language:    "auto",
+			messages:    english,
+			promptStart: "You are an expert",
+			directive:   "Always write in English",
+			dialog:      []string{"Dialogue to summarize:", "User: How do I", "Assistant: Use proxy_pass", "Tool search returned:"},
+			unwanted:    "Пользователь",
+		},
+		{
+			name:        "mixed transcript",
+			language:    "auto",
+			messages:    mixed,
+			promptStart: "Ты эксперт",
+			directive:   "(ru)",
+			dialog:      []string{"Диалог для резюмирования:", "Пользователь: Как настроить", "Ассистент: Here is"},
+			unwanted:    "User:",
+		},
+		{
+			name:        "configured language",
+			language:    "ru",
+			messages:    english,
+			promptStart: "Ты эксперт",
+			directive:   "(ru)",
+			dialog:      []string{"Пользователь: How do I", "Инструмент search вернул:"},
+			unwanted:    "User:",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &recordingLLM{replies: []string{`{"anchors": ["Topic"], "summary": "Done"}`}}
+			cfg := DefaultConfig()
+			cfg.Language = tt.language
+			service := NewService(nil, client, nil, nil, cfg, zap.NewNop())
+
+			if _, err := service.CreateSummary(context.Background(), SummaryRequest{SessionID: "s", Messages: tt.messages, SummaryLevel: 1, SkipSave: true}); err != nil {
+				t.Fatalf("CreateSummary: %v", err)
+			}
+
+			prompt, dialog := client.requests[0][0].Content, client.requests[0][1].Content
+			if !strings.HasPrefix(prompt, tt.promptStart) || !strings.Contains(prompt, tt.directive) {
+				t.Errorf("system prompt:\n%s", prompt)
+			}
+			for _, want := range tt.dialog {
+				if !strings.Contains(dialog, want) {
+					t.Errorf("dialog lacks %q:\n%s", want, dialog)
+				}
+			}
+			if strings.Contains(dialog, tt.unwanted) {
+				t.Errorf("dialog contains %q:\n%s", tt.unwanted, dialog)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package summary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"LLM_Chat/internal/storage/models"
+	"LLM_Chat/pkg/tokens"
+
+	"go.uber.org/zap"
+)
+
+// summarize создаёт якоря и резюме. Диалог, не помещающийся в ChunkTokenBudget, резюмируется
+// по частям (map), после чего резюме частей объединяются в итоговое (reduce).
+// Потраченные токены всех запросов добавляются к tokensUsed.
+func (s *Service) summarize(ctx context.Context, req SummaryRequest, lang string, tokensUsed *int) ([]string, string, error) {
+	if req.SummaryLevel == 1 {
+		if chunks := s.chunkMessages(req.Messages, labelsFor(lang)); len(chunks) > 1 {
+			return s.mapReduce(ctx, req, chunks, lang, tokensUsed)
+		}
+	}
+	return s.summarizeBatch(ctx, req, req.Messages, req.SummaryLevel, lang, tokensUsed)
+}
+
+// summarizeBatch создаёт якоря и резюме одним запросом; если ответ не разобран - двумя запросами
+func (s *Service) summarizeBatch(ctx context.Context, req SummaryRequest, messages []models.Message, summaryLevel int, lang string, tokensUsed *int) ([]string, string, error) {
+	anchors, briefSummary, structuredTokens, err := s.createStructuredSummary(ctx, messages, summaryLevel, lang)
+	*tokensUsed += structuredTokens
+	if errors.Is(err, errStructuredOutput) {
+		s.logger.Warn("Structured summary output could not be parsed, falling back to separate anchors and summary requests",
+			zap.String("session_id", req.SessionID),
+			zap.Int("summary_level", summaryLevel),
+			zap.Error(err),
+		)
+		return s.createAnchorsAndSummary(ctx, messages, summaryLevel, lang, tokensUsed)
+	}
+	return anchors, briefSummary, err
+}
+
+// mapReduce резюмирует каждую часть диалога и объединяет резюме частей запросом второго уровня
+func (s *Service) mapReduce(ctx context.Context, req SummaryRequest, chunks [][]models.Message, lang string, tokensUsed *int) ([]string, string, error) {
+	s.logger.Info("Summarizing long dialog in chunks",
+		zap.String("session_id", req.SessionID),
+		zap.Int("messages_count", len(req.Messages)),
+		zap.Int("chunks", len(chunks)),
+		zap.Int("chunk_token_budget", s.config.ChunkTokenBudget),
+	)
+
+	partials := make([]models.Message, 0, len(chunks))
+	for i, chunk := range chunks {
+		anchors, briefSummary, err := s.summarizeBatch(ctx, req, chunk, 1, lang, tokensUsed)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to summarize chunk %d of %d: %w", i+1, len(chunks), err)
+		}
+		partials = append(partials, models.Message{
+			SessionID: req.SessionID,
+			Role:      "assistant",
+			Content:   FormatForContext(models.Summary{SummaryText: briefSummary, Anchors: anchors}, ""),
+			Timestamp: chunk[len(chunk)-1].Timestamp,
+		})
+	}
+
+	anchors, briefSummary, err := s.summarizeBatch(ctx, req, partials, 2, lang, tokensUsed)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to merge chunk summaries: %w", err)
+	}
+	return anchors, briefSummary, nil
+}
+
+// chunkMessages делит сообщения на части по ChunkTokenBudget токенов строк диалога, сохраняя
+// порядок. Сообщение не делится: сообщение больше бюджета составляет отдельную часть.
+// ChunkTokenBudget = 0 - одна часть.
+func (s *Service) chunkMessages(messages []models.Message, labels dialogLabels) [][]models.Message {
+	if s.config.ChunkTokenBudget <= 0 || len(messages) == 0 {
+		return [][]models.Message{messages}
+	}
+
+	var chunks [][]models.Message
+	var current []models.Message
+	currentTokens := 0
+	for _, msg := range messages {
+		msgTokens := tokens.Estimate(s.dialogLine(msg, labels))
+		if len(current) > 0 && currentTokens+msgTokens > s.config.ChunkTokenBudget {
+			chunks = append(chunks, current)
+			current, currentTokens = nil, 0
+		}
+		current = append(current, msg)
+		currentTokens += msgTokens
+	}
+	return append(chunks, current)
+}
@@ -0,0 +1,144 @@
+package summary
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"LLM_Chat/internal/storage/models"
+	"LLM_Chat/pkg/llm"
+	"LLM_Chat/pkg/tokens"
+
+	"go.uber.org/zap"
+)
+
+// markerPattern метки сообщений синтетического диалога: M000, M001, ...
+var markerPattern = regexp.MustCompile(`M\d{3}`)
+
+// echoLLM модель сжатия, резюме которой - метки сообщений из запроса: по итоговому резюме
+// видно, какие сообщения на него повлияли
+type echoLLM struct {
+	llm.LLMClient
+
+	mu     sync.Mutex
+	inputs []string
+}
+
+func (c *echoLLM) ChatCompletion(ctx context.Context, messages []llm.Message) (*llm.ChatResponse, error) {
+	input := messages[len(messages)-1].Content
+	c.mu.Lock()
+	c.inputs = append(c.inputs, input)
+	c.mu.Unlock()
+
+	markers := strings.Join(markerPattern.FindAllString(input, -1), " ")
+	return &llm.ChatResponse{
+		Choices: []llm.Choice{{Message: llm.Message{Role: "assistant", Content: fmt.Sprintf(`{"anchors": ["Метки"], "summary": %q}`, markers)}}},
+		Usage:   llm.Usage{TotalTokens: 7},
+	}, nil
+}
+
+// syntheticDialog n сообщений с метками M000... и текстом заметной длины
+func syntheticDialog(n int) []models.Message {
+	messages := make([]models.Message, n)
+	for i := range messages {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		messages[i] = models.Message{
+			ID:      fmt.Sprint(i),
+			Role:    role,
+			Content: fmt.Sprintf("M%03d %s", i, strings.Repeat("слово ", 40)),
+		}
+	}
+	return messages
+}
+
+func TestMapReduceSummarizesEveryChunk(t *testing.T) {
+	client := &echoLLM{}
+	cfg := DefaultConfig()
+	cfg.Language = "ru"
+	cfg.ChunkTokenBudget = 2000
+	cfg.SummaryMaxLength = 2000
+	service := NewService(nil, client, nil, nil, cfg, zap.NewNop())
+
+	resp, err := service.CreateSummary(context.Background(), SummaryRequest{SessionID: "s", Messages: syntheticDialog(200), SummaryLevel: 1, SkipSave: true})
+	if err != nil {
+		t.Fatalf("CreateSummary: %v", err)
+	}
+
+	maps, reduce := client.inputs[:len(client.inputs)-1], client.inputs[len(client.inputs)-1]
+	if len(maps) < 3 {
+		t.Fatalf("chunks = %d, want several", len(maps))
+	}
+
+	// Части идут по порядку и не делят сообщения: каждая метка ровно в одной части
+	next := 0
+	for i, input := range maps {
+		for _, marker := range markerPattern.FindAllString(input, -1) {
+			if marker != fmt.Sprintf("M%03d", next) {
+				t.Fatalf("chunk %d: marker %s, want M%03d", i+1, marker, next)
+			}
+			next++
+		}
+	}
+	if next != 200 {
+		t.Fatalf("chunks contain %d messages, want 200", next)
+	}
+
+	// Резюме каждой части попало в объединяющий запрос, а через него - в итоговое резюме
+	if !strings.Contains(reduce, "Резюме для объединения:") {
+		t.Errorf("reduce request is not a level 2 request:\n%s", reduce)
+	}
+	if got := markerPattern.FindAllString(resp.BriefSummary, -1); len(got) != 200 || got[0] != "M000" || got[199] != "M199" {
+		t.Errorf("final summary carries %d markers, want all 200", len(got))
+	}
+	if resp.TokensUsed != 7*len(client.inputs) {
+		t.Errorf("tokens used = %d, want %d for %d requests", resp.TokensUsed, 7*len(client.inputs), len(client.inputs))
+	}
+}
+
+func TestChunkMessages(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ChunkTokenBudget = 300
+	service := NewService(nil, nil, nil, nil, cfg, zap.NewNop())
+	labels := labelsFor("ru")
+
+	messages := syntheticDialog(10)
+	messages[4].Content = "M004 " + strings.Repeat("длинное ", 500) // больше бюджета
+
+	chunks := service.chunkMessages(messages, labels)
+	var ids []string
+	for _, chunk := range chunks {
+		total := 0
+		for _, msg := range chunk {
+			ids = append(ids, msg.ID)
+			total += tokens.Estimate(service.dialogLine(msg, labels))
+		}
+		if total > cfg.ChunkTokenBudget && len(chunk) > 1 {
+			t.Errorf("chunk of %d messages exceeds the budget: %d tokens", len(chunk), total)
+		}
+	}
+	if fmt.Sprint(ids) != "[0 1 2 3 4 5 6 7 8 9]" {
+		t.Errorf("messages in chunks = %v, want the original order", ids)
+	}
+
+	// Сообщение больше бюджета составляет отдельную часть
+	found := false
+	for _, chunk := range chunks {
+		if len(chunk) == 1 && chunk[0].ID == "4" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("oversized message shares a chunk")
+	}
+
+	cfg.ChunkTokenBudget = 0
+	if chunks := NewService(nil, nil, nil, nil, cfg, zap.NewNop()).chunkMessages(messages, labels); len(chunks) != 1 {
+		t.Errorf("chunks without a budget = %d, want 1", len(chunks))
+	}
+}
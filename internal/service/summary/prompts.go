@@ -0,0 +1,132 @@
+package summary
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// Имена шаблонов системных промптов (ключи summary.prompts). Уровень 2 шаблона якорей
+// используется и для дайджеста сессии.
+const (
+	PromptAnchorsL1    = "anchors_l1"
+	PromptAnchorsL2    = "anchors_l2"
+	PromptSummaryL1    = "summary_l1"
+	PromptSummaryL2    = "summary_l2"
+	PromptSummaryL3    = "summary_l3"
+	PromptStructuredL1 = "structured_l1"
+	PromptStructuredL2 = "structured_l2"
+	PromptStructuredL3 = "structured_l3"
+)
+
+// promptNames все шаблоны сервиса резюме
+var promptNames = []string{
+	PromptAnchorsL1, PromptAnchorsL2,
+	PromptSummaryL1, PromptSummaryL2, PromptSummaryL3,
+	PromptStructuredL1, PromptStructuredL2, PromptStructuredL3,
+}
+
+// Языки встроенных шаблонов (prompts/<язык>/<имя>.tmpl). Для остальных языков используются
+// русские шаблоны с инструкцией о языке резюме.
+const defaultPromptLanguage = "ru"
+
+var promptLanguages = []string{"ru", "en"}
+
+//go:embed prompts/*/*.tmpl
+var defaultPromptFiles embed.FS
+
+// PromptData переменные шаблона промпта
+type PromptData struct {
+	AnchorsCount int      // сколько якорей создать
+	MaxLength    int      // предел длины резюме в символах
+	Anchors      []string // якоря для ориентира (шаблоны summary_*); {{join .Anchors ", "}}
+}
+
+var promptFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+// Prompts шаблоны системных промптов резюме (text/template): встроенные для каждого языка
+// и заданные в конфигурации, которые применяются независимо от языка
+type Prompts struct {
+	defaults  map[string]map[string]*template.Template // язык -> имя -> шаблон
+	overrides map[string]*template.Template
+}
+
+// DefaultPrompts встроенные шаблоны
+func DefaultPrompts() *Prompts {
+	prompts := &Prompts{
+		defaults:  make(map[string]map[string]*template.Template, len(promptLanguages)),
+		overrides: make(map[string]*template.Template),
+	}
+	for _, lang := range promptLanguages {
+		templates := make(map[string]*template.Template, len(promptNames))
+		for _, name := range promptNames {
+			text, err := defaultPromptFiles.ReadFile("prompts/" + lang + "/" + name + ".tmpl")
+			if err != nil {
+				panic(fmt.Sprintf("embedded prompt %s/%s: %v", lang, name, err))
+			}
+			templates[name] = template.Must(parsePrompt(name, string(text)))
+		}
+		prompts.defaults[lang] = templates
+	}
+	return prompts
+}
+
+// LoadPrompts заменяет встроенные шаблоны файлами files (имя шаблона -> путь) для всех языков;
+// пустой путь оставляет встроенные шаблоны. Каждый шаблон проверяется пробной подстановкой,
+// чтобы ошибки в нём обнаруживались при запуске, а не при сжатии.
+func LoadPrompts(files map[string]string) (*Prompts, error) {
+	prompts := DefaultPrompts()
+	for name, path := range files {
+		if path == "" {
+			continue
+		}
+		if _, ok := prompts.defaults[defaultPromptLanguage][name]; !ok {
+			return nil, fmt.Errorf("unknown summary prompt %q", name)
+		}
+
+		text, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read summary prompt %s: %w", name, err)
+		}
+		tmpl, err := parsePrompt(name, string(text))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse summary prompt %s (%s): %w", name, path, err)
+		}
+		prompts.overrides[name] = tmpl
+
+		sample := PromptData{AnchorsCount: 5, MaxLength: 500, Anchors: []string{"якорь"}}
+		if _, err := prompts.Render(name, defaultPromptLanguage, sample); err != nil {
+			return nil, fmt.Errorf("invalid summary prompt %s (%s): %w", name, path, err)
+		}
+	}
+	return prompts, nil
+}
+
+// Render подставляет переменные в шаблон name для языка резюме lang: шаблон из конфигурации,
+// встроенный шаблон языка или, если его нет, русский
+func (p *Prompts) Render(name, lang string, data PromptData) (string, error) {
+	tmpl, ok := p.overrides[name]
+	if !ok {
+		templates, found := p.defaults[lang]
+		if !found {
+			templates = p.defaults[defaultPromptLanguage]
+		}
+		if tmpl, ok = templates[name]; !ok {
+			return "", fmt.Errorf("unknown summary prompt %q", name)
+		}
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render summary prompt %s: %w", name, err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+func parsePrompt(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(promptFuncs).Option("missingkey=error").Parse(text)
+}
@@ -0,0 +1,96 @@
+package summary
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultPromptsRender(t *testing.T) {
+	prompts := DefaultPrompts()
+	data := PromptData{AnchorsCount: 4, MaxLength: 300, Anchors: []string{"Сервер", "HTTPS"}}
+
+	// Каждый встроенный шаблон каждого языка подставляет переменные без ошибок
+	for _, lang := range promptLanguages {
+		for _, name := range promptNames {
+			text, err := prompts.Render(name, lang, data)
+			if err != nil {
+				t.Errorf("Render(%s, %s): %v", name, lang, err)
+				continue
+			}
+			if text == "" || strings.Contains(text, "{{") || strings.Contains(text, "<no value>") {
+				t.Errorf("Render(%s, %s) = %q", name, lang, text)
+			}
+		}
+	}
+
+	tests := []struct {
+		name, lang, want string
+	}{
+		{PromptAnchorsL1, "ru", "Создай ровно 4 якорей"},
+		{PromptSummaryL2, "ru", "максимум 300 символов"},
+		{PromptSummaryL2, "ru", "Якоря для ориентира: Сервер, HTTPS"},
+		{PromptAnchorsL1, "en", "expert in dialogue analysis"},
+		// Для языка без встроенных шаблонов используются русские
+		{PromptAnchorsL1, "de", "Ты эксперт по анализу диалогов"},
+	}
+	for _, tt := range tests {
+		text, _ := prompts.Render(tt.name, tt.lang, data)
+		if !strings.Contains(text, tt.want) {
+			t.Errorf("Render(%s, %s) lacks %q:\n%s", tt.name, tt.lang, tt.want, text)
+		}
+	}
+}
+
+func TestLoadPromptsOverride(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "summary_l1.tmpl")
+	if err := os.WriteFile(path, []byte("Summarize in {{.MaxLength}} chars. Anchors: {{join .Anchors \"; \"}}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	prompts, err := LoadPrompts(map[string]string{PromptSummaryL1: path, PromptAnchorsL1: ""})
+	if err != nil {
+		t.Fatalf("LoadPrompts: %v", err)
+	}
+	// Шаблон из конфигурации применяется для любого языка, остальные остаются встроенными
+	for _, lang := range []string{"ru", "en"} {
+		text, err := prompts.Render(PromptSummaryL1, lang, PromptData{MaxLength: 100, Anchors: []string{"x", "y"}})
+		if err != nil || text != "Summarize in 100 chars. Anchors: x; y" {
+			t.Errorf("override for %s = %q, %v", lang, text, err)
+		}
+	}
+	if text, _ := prompts.Render(PromptAnchorsL1, "ru", PromptData{AnchorsCount: 5}); !strings.Contains(text, "Создай ровно 5 якорей") {
+		t.Errorf("default anchors prompt replaced:\n%s", text)
+	}
+}
+
+func TestLoadPromptsFailsAtStartup(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, text string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{"syntax error", map[string]string{PromptSummaryL1: write("syntax.tmpl", "{{.MaxLength")}},
+		{"unknown field", map[string]string{PromptSummaryL1: write("field.tmpl", "{{.Unknown}}")}},
+		{"unknown function", map[string]string{PromptSummaryL1: write("func.tmpl", "{{upper .MaxLength}}")}},
+		{"unknown prompt", map[string]string{"summary_l9": write("name.tmpl", "text")}},
+		{"missing file", map[string]string{PromptSummaryL1: filepath.Join(dir, "missing.tmpl")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadPrompts(tt.files); err == nil {
+				t.Error("LoadPrompts succeeded")
+			}
+		})
+	}
+}
@@ -2,10 +2,13 @@ package summary
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
+	"LLM_Chat/internal/events"
+	"LLM_Chat/internal/language"
 	"LLM_Chat/internal/storage/interfaces"
 	"LLM_Chat/internal/storage/models"
 	"LLM_Chat/pkg/llm"
@@ -14,11 +17,20 @@ import (
 	"go.uber.org/zap"
 )
 
+// DefaultChunkTokenBudget предел диалога в одном запросе резюме по умолчанию (summary.chunk_tokens)
+const DefaultChunkTokenBudget = 12000
+
+// ErrNotEnoughMessages недостаточно сообщений для создания резюме
+var ErrNotEnoughMessages = errors.New("not enough messages for summary")
+
 type Service struct {
 	summaryStore interfaces.SummaryStore
 	shrinkClient llm.LLMClient // Отдельный клиент для сжатия
+	events       events.Publisher
+	language     *language.Policy
 	logger       *zap.Logger
 	config       Config
+	metrics      *SummaryMetrics
 }
 
 type Config struct {
@@ -27,6 +39,17 @@ type Config struct {
 	AnchorsCount             int // Количество якорей для создания
 	SummaryMaxLength         int // Максимальная длина резюме
 	MinMessagesForSummary    int // Минимум сообщений для создания резюме
+	ToolResultMaxLength      int // Предел результата инструмента в диалоге для резюме, в байтах
+
+	// ChunkTokenBudget предел оценки токенов диалога в одном запросе резюме первого уровня;
+	// более длинный диалог резюмируется по частям. 0 - всегда одним запросом
+	ChunkTokenBudget int
+
+	// Language язык резюме, подписей ролей и встроенных промптов: код языка или auto
+	// (язык сессии, если он закреплён, иначе преобладающий язык сжимаемых сообщений)
+	Language string
+
+	Prompts *Prompts // Шаблоны системных промптов; nil - встроенные
 }
 
 func DefaultConfig() Config {
@@ -36,28 +59,48 @@ func DefaultConfig() Config {
 		AnchorsCount:             5,
 		SummaryMaxLength:         500,
 		MinMessagesForSummary:    3, // Минимум для работы с многоуровневым сжатием
+		ToolResultMaxLength:      300,
+		ChunkTokenBudget:         DefaultChunkTokenBudget,
+		Language:                 language.Auto,
 	}
 }
 
 func NewService(
 	summaryStore interfaces.SummaryStore,
 	shrinkClient llm.LLMClient,
+	eventPublisher events.Publisher,
+	languagePolicy *language.Policy,
 	config Config,
 	logger *zap.Logger,
 ) *Service {
+	if config.Prompts == nil {
+		config.Prompts = DefaultPrompts()
+	}
 	return &Service{
 		summaryStore: summaryStore,
 		shrinkClient: shrinkClient,
+		events:       eventPublisher,
+		language:     languagePolicy,
 		config:       config,
 		logger:       logger,
+		metrics:      NewSummaryMetrics(),
 	}
 }
 
+// Metrics возвращает накопленные метрики сервиса
+func (s *Service) Metrics() *SummaryMetrics {
+	return s.metrics
+}
+
 type SummaryRequest struct {
 	SessionID    string
 	Messages     []models.Message
 	Reason       string // Причина создания резюме
-	SummaryLevel int    // 1 = regular summary, 2 = bulk summary
+	SummaryLevel int    // 1 = regular summary, 2 = bulk summary, 3 = session digest
+
+	// SkipSave не сохранять резюме: вызывающая сторона сохраняет Summary из ответа
+	// вместе с пометкой источников (ApplyCompression)
+	SkipSave bool
 }
 
 type SummaryResponse struct {
@@ -67,9 +110,13 @@ type SummaryResponse struct {
 	BriefSummary        string
 	SummaryLevel        int
 	TokensUsed          int
+	AnchorsCreated      int // Количество якорей созданного резюме
 	MessagesCompressed  int // Количество сжатых сообщений
 	SummariesCompressed int // Количество сжатых резюме (для bulk summaries)
 	Duration            time.Duration
+	Degraded            bool // резюме составлено без модели сжатия (резервное)
+
+	Summary models.Summary // созданная запись резюме
 }
 
 // CreateSummary создаёт резюме указанного уровня
@@ -84,25 +131,24 @@ func (s *Service) CreateSummary(ctx context.Context, req SummaryRequest) (*Summa
 	)
 
 	if len(req.Messages) < s.config.MinMessagesForSummary {
-		return nil, fmt.Errorf("not enough messages for summary: %d < %d",
-			len(req.Messages), s.config.MinMessagesForSummary)
+		return nil, fmt.Errorf("%w: %d < %d",
+			ErrNotEnoughMessages, len(req.Messages), s.config.MinMessagesForSummary)
 	}
 
 	// Validate summary level
-	if req.SummaryLevel < 1 || req.SummaryLevel > 2 {
-		return nil, fmt.Errorf("invalid summary level: %d (must be 1 or 2)", req.SummaryLevel)
+	if req.SummaryLevel < 1 || req.SummaryLevel > 3 {
+		return nil, fmt.Errorf("invalid summary level: %d (must be 1, 2 or 3)", req.SummaryLevel)
 	}
 
-	// 1. Создаём якоря (ключевые моменты)
-	anchors, err := s.createAnchors(ctx, req.Messages, req.SummaryLevel)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create anchors: %w", err)
-	}
+	// Язык резюме следует настройке сессии, чтобы контекст не переключал язык ответов
+	lang := s.resolveLanguage(ctx, req)
 
-	// 2. Создаём краткое резюме
-	briefSummary, tokensUsed, err := s.createBriefSummary(ctx, req.Messages, anchors, req.SummaryLevel)
+	// 1-2. Якоря и краткое резюме; длинный диалог резюмируется по частям.
+	// При ошибке модели - повтор, затем резервное резюме без модели
+	tokensUsed := 0
+	anchors, briefSummary, degraded, err := s.summarizeWithFallback(ctx, req, lang, &tokensUsed)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create brief summary: %w", err)
+		return nil, err
 	}
 
 	// 3. Определяем границы сжатия
@@ -127,11 +173,30 @@ func (s *Service) CreateSummary(ctx context.Context, req SummaryRequest) (*Summa
 		UpdatedAt:           time.Now(),
 	}
 
-	if err := s.summaryStore.SaveSummary(ctx, summary); err != nil {
-		return nil, fmt.Errorf("failed to save summary: %w", err)
+	if !req.SkipSave {
+		if err := s.summaryStore.SaveSummary(ctx, summary); err != nil {
+			return nil, fmt.Errorf("failed to save summary: %w", err)
+		}
 	}
 
 	duration := time.Since(startTime)
+	s.metrics.RecordSummary(len(anchors), tokensUsed, len(req.Messages), duration)
+
+	if s.events != nil {
+		s.events.Publish(events.Event{
+			Type:      events.TypeSummaryCreated,
+			SessionID: req.SessionID,
+			Data: events.SummaryData{
+				SummaryID:    summaryID,
+				Level:        req.SummaryLevel,
+				Reason:       req.Reason,
+				ItemsCovered: len(req.Messages),
+				AnchorsCount: len(anchors),
+				TokensUsed:   tokensUsed,
+				Degraded:     degraded,
+			},
+		})
+	}
 
 	s.logger.Info("Multi-level summary created successfully",
 		zap.String("session_id", req.SessionID),
@@ -140,122 +205,113 @@ func (s *Service) CreateSummary(ctx context.Context, req SummaryRequest) (*Summa
 		zap.Int("anchors_count", len(anchors)),
 		zap.Int("summary_length", len(briefSummary)),
 		zap.Int("tokens_used", tokensUsed),
+		zap.Bool("degraded", degraded),
 		zap.Int("compressed_items", len(req.Messages)),
 		zap.Duration("duration", duration),
 	)
 
 	response := &SummaryResponse{
-		SessionID:    req.SessionID,
-		SummaryID:    summaryID,
-		Anchors:      anchors,
-		BriefSummary: briefSummary,
-		SummaryLevel: req.SummaryLevel,
-		TokensUsed:   tokensUsed,
-		Duration:     duration,
+		SessionID:      req.SessionID,
+		SummaryID:      summaryID,
+		Anchors:        anchors,
+		AnchorsCreated: len(anchors),
+		BriefSummary:   briefSummary,
+		SummaryLevel:   req.SummaryLevel,
+		TokensUsed:     tokensUsed,
+		Degraded:       degraded,
+		Duration:       duration,
+		Summary:        summary,
 	}
 
 	// Устанавливаем соответствующие поля в зависимости от уровня
 	if req.SummaryLevel == 1 {
 		response.MessagesCompressed = len(req.Messages)
-	} else if req.SummaryLevel == 2 {
+	} else {
 		response.SummariesCompressed = len(req.Messages)
 	}
 
 	return response, nil
 }
 
-// createAnchors создаёт ключевые якоря из истории сообщений/резюме
-func (s *Service) createAnchors(ctx context.Context, messages []models.Message, summaryLevel int) ([]string, error) {
-	// Формируем промпт для создания якорей в зависимости от уровня
-	var systemPrompt string
-	if summaryLevel == 2 {
-		systemPrompt = `Ты эксперт по анализу диалогов. Твоя задача - выделить ключевые моменты из набора резюме в виде коротких якорей.
-
-Якорь - это краткая фраза (3-7 слов), которая отражает важную тему или группу тем из резюме.
-
-Правила:
-1. Создай ровно %d якорей
-2. Каждый якорь должен быть коротким и информативным
-3. Якоря должны отражать основные темы из всех резюме
-4. Используй тот же язык, что и в резюме
-5. Сконцентрируйся на самых важных и общих темах
-6. Отвечай только списком якорей, по одному на строке, без нумерации
-
-Пример хороших якорей для bulk summary:
-- "Обсуждение технических решений"
-- "Карьерное планирование"
-- "Анализ проектных задач"
-- "Рекомендации и советы"`
-	} else {
-		systemPrompt = `Ты эксперт по анализу диалогов. Твоя задача - выделить ключевые моменты из разговора в виде коротких якорей.
-
-Якорь - это краткая фраза (3-7 слов), которая отражает важную тему или поворотный момент в разговоре.
-
-Правила:
-1. Создай ровно %d якорей
-2. Каждый якорь должен быть коротким и информативным
-3. Якоря должны отражать основные темы и важные моменты
-4. Используй тот же язык, что и в диалоге
-5. Отвечай только списком якорей, по одному на строке, без нумерации
+// createAnchorsAndSummary создаёт якоря и резюме двумя последовательными запросами,
+// добавляя потраченные токены к tokensUsed
+func (s *Service) createAnchorsAndSummary(ctx context.Context, messages []models.Message, summaryLevel int, lang string, tokensUsed *int) ([]string, string, error) {
+	anchors, anchorTokens, err := s.createAnchors(ctx, messages, summaryLevel, lang)
+	*tokensUsed += anchorTokens
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create anchors: %w", err)
+	}
 
-Пример хороших якорей:
-- "Обсуждение карьерных планов"
-- "Проблемы с проектом"
-- "Рекомендации по книгам"
-- "Планы на выходные"`
+	briefSummary, summaryTokens, err := s.createBriefSummary(ctx, messages, anchors, summaryLevel, lang)
+	*tokensUsed += summaryTokens
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create brief summary: %w", err)
 	}
 
-	systemPrompt = fmt.Sprintf(systemPrompt, s.config.AnchorsCount)
+	return anchors, briefSummary, nil
+}
 
-	// Формируем контент в зависимости от уровня
-	var dialogBuilder strings.Builder
-	if summaryLevel == 2 {
-		dialogBuilder.WriteString("Резюме для анализа:\n\n")
-		for i, msg := range messages {
-			dialogBuilder.WriteString(fmt.Sprintf("Резюме %d: %s\n\n", i+1, msg.Content))
+// resolveLanguage определяет язык резюме: Config.Language, если он задан явно, иначе язык,
+// закреплённый за сессией, иначе преобладающий язык сжимаемых сообщений пользователя (для резюме
+// старших уровней - сжимаемых резюме). Пустая строка - язык определить не удалось.
+func (s *Service) resolveLanguage(ctx context.Context, req SummaryRequest) string {
+	if s.config.Language != "" && s.config.Language != language.Auto {
+		return s.config.Language
+	}
+	if s.language != nil {
+		if setting := s.language.Setting(ctx, req.SessionID); setting != language.Auto {
+			return setting
 		}
-	} else {
-		dialogBuilder.WriteString("Диалог для анализа:\n\n")
-		for _, msg := range messages {
-			role := s.getRoleDisplayName(msg.Role)
-			dialogBuilder.WriteString(fmt.Sprintf("%s: %s\n", role, msg.Content))
+	}
+
+	var texts, userTexts []string
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case "tool":
+		case "user":
+			userTexts = append(userTexts, msg.Content)
+			texts = append(texts, msg.Content)
+		default:
+			texts = append(texts, msg.Content)
 		}
 	}
+	if len(userTexts) > 0 {
+		texts = userTexts
+	}
+	return language.DetectDominant(texts...)
+}
+
+// createAnchors создаёт ключевые якоря из истории сообщений/резюме и возвращает потраченные токены
+func (s *Service) createAnchors(ctx context.Context, messages []models.Message, summaryLevel int, lang string) ([]string, int, error) {
+	// Промпт для создания якорей в зависимости от уровня
+	promptName := PromptAnchorsL1
+	if summaryLevel >= 2 {
+		promptName = PromptAnchorsL2
+	}
+	systemPrompt, err := s.config.Prompts.Render(promptName, lang, PromptData{AnchorsCount: s.config.AnchorsCount})
+	if err != nil {
+		return nil, 0, err
+	}
+	systemPrompt = appendLanguageDirective(systemPrompt, lang)
 
+	labels := labelsFor(lang)
 	llmMessages := []llm.Message{
 		{Role: "system", Content: systemPrompt},
-		{Role: "user", Content: dialogBuilder.String()},
+		{Role: "user", Content: s.dialogText(messages, summaryLevel, labels, labels.anchorsDialog, labels.anchorsSummaries)},
 	}
 
 	response, err := s.shrinkClient.ChatCompletion(ctx, llmMessages)
 	if err != nil {
-		return nil, fmt.Errorf("LLM request failed: %w", err)
+		return nil, 0, fmt.Errorf("LLM request failed: %w", err)
 	}
 
 	if len(response.Choices) == 0 {
-		return nil, fmt.Errorf("no response from LLM")
+		return nil, 0, fmt.Errorf("no response from LLM")
 	}
 
 	// Парсим якоря из ответа
 	anchorsText := strings.TrimSpace(response.Choices[0].Message.Content)
-	anchorLines := strings.Split(anchorsText, "\n")
-
-	var anchors []string
-	for _, line := range anchorLines {
-		anchor := strings.TrimSpace(line)
-		anchor = strings.TrimPrefix(anchor, "-")
-		anchor = strings.TrimPrefix(anchor, "•")
-		anchor = strings.TrimSpace(anchor)
-
-		if anchor != "" && len(anchor) > 3 {
-			anchors = append(anchors, anchor)
-		}
-	}
-
-	// Ограничиваем количество якорей
-	if len(anchors) > s.config.AnchorsCount {
-		anchors = anchors[:s.config.AnchorsCount]
-	}
+	anchors := s.normalizeAnchors(strings.Split(anchorsText, "\n"))
 
 	s.logger.Debug("Created anchors for multi-level summary",
 		zap.Int("summary_level", summaryLevel),
@@ -263,71 +319,31 @@ func (s *Service) createAnchors(ctx context.Context, messages []models.Message,
 		zap.Strings("anchors_parsed", anchors),
 	)
 
-	return anchors, nil
+	return anchors, response.Usage.TotalTokens, nil
 }
 
 // createBriefSummary создаёт краткое резюме в зависимости от уровня
-func (s *Service) createBriefSummary(ctx context.Context, messages []models.Message, anchors []string, summaryLevel int) (string, int, error) {
-	var systemPrompt string
-	if summaryLevel == 2 {
-		systemPrompt = `Ты эксперт по созданию кратких резюме. Создай краткое резюме из набора резюме диалогов.
-
-Требования:
-1. Резюме должно быть максимум %d символов
-2. Используй тот же язык, что и в исходных резюме
-3. Отражай основные темы и выводы из всех резюме
-4. Будь конкретным и информативным
-5. Создай обобщенное резюме, которое покрывает все важные аспекты
-6. Используй предоставленные якоря как ориентир
-
-Якоря для ориентира: %s
-
-Отвечай только текстом резюме, без дополнительных комментариев.`
-	} else {
-		systemPrompt = `Ты эксперт по созданию кратких резюме диалогов. Создай краткое резюме разговора.
-
-Требования:
-1. Резюме должно быть максимум %d символов
-2. Используй тот же язык, что и в диалоге
-3. Отражай основные темы и выводы
-4. Будь конкретным и информативным
-5. Включи важные детали и решения
-6. Используй предоставленные якоря как ориентир
-
-Якоря для ориентира: %s
-
-Отвечай только текстом резюме, без дополнительных комментариев.`
+func (s *Service) createBriefSummary(ctx context.Context, messages []models.Message, anchors []string, summaryLevel int, lang string) (string, int, error) {
+	promptName := PromptSummaryL1
+	switch summaryLevel {
+	case 3:
+		promptName = PromptSummaryL3
+	case 2:
+		promptName = PromptSummaryL2
 	}
-
-	anchorsStr := strings.Join(anchors, ", ")
-	systemPrompt = fmt.Sprintf(systemPrompt, s.config.SummaryMaxLength, anchorsStr)
-
-	// Формируем контент для резюмирования
-	var dialogBuilder strings.Builder
-	if summaryLevel == 2 {
-		dialogBuilder.WriteString("Резюме для объединения:\n\n")
-		for i, msg := range messages {
-			dialogBuilder.WriteString(fmt.Sprintf("Резюме %d:\n%s\n\n", i+1, msg.Content))
-		}
-	} else {
-		dialogBuilder.WriteString("Диалог для резюмирования:\n\n")
-
-		// Для первого уровня можем пропускать сообщения если их слишком много
-		step := 1
-		if len(messages) > 20 {
-			step = len(messages) / 20 // Берём примерно 20 сообщений
-		}
-
-		for i := 0; i < len(messages); i += step {
-			msg := messages[i]
-			role := s.getRoleDisplayName(msg.Role)
-			dialogBuilder.WriteString(fmt.Sprintf("%s: %s\n", role, msg.Content))
-		}
+	systemPrompt, err := s.config.Prompts.Render(promptName, lang, PromptData{
+		MaxLength: s.config.SummaryMaxLength,
+		Anchors:   anchors,
+	})
+	if err != nil {
+		return "", 0, err
 	}
+	systemPrompt = appendLanguageDirective(systemPrompt, lang)
 
+	labels := labelsFor(lang)
 	llmMessages := []llm.Message{
 		{Role: "system", Content: systemPrompt},
-		{Role: "user", Content: dialogBuilder.String()},
+		{Role: "user", Content: s.dialogText(messages, summaryLevel, labels, labels.summaryDialog, labels.summarySummaries)},
 	}
 
 	response, err := s.shrinkClient.ChatCompletion(ctx, llmMessages)
@@ -339,12 +355,7 @@ func (s *Service) createBriefSummary(ctx context.Context, messages []models.Mess
 		return "", 0, fmt.Errorf("no response from LLM")
 	}
 
-	summary := strings.TrimSpace(response.Choices[0].Message.Content)
-
-	// Ограничиваем длину резюме
-	if len(summary) > s.config.SummaryMaxLength {
-		summary = summary[:s.config.SummaryMaxLength-3] + "..."
-	}
+	summary := s.truncateSummary(strings.TrimSpace(response.Choices[0].Message.Content))
 
 	s.logger.Debug("Created brief summary",
 		zap.Int("summary_level", summaryLevel),
@@ -355,20 +366,46 @@ func (s *Service) createBriefSummary(ctx context.Context, messages []models.Mess
 	return summary, response.Usage.TotalTokens, nil
 }
 
-// getRoleDisplayName возвращает отображаемое имя роли
-func (s *Service) getRoleDisplayName(role string) string {
-	switch role {
-	case "user":
-		return "Пользователь"
-	case "assistant":
-		return "Ассистент"
-	case "tool":
-		return "Инструмент"
-	case "system":
-		return "Система"
-	default:
-		return "Участник"
+// normalizeAnchors очищает якоря от маркеров списка, отбрасывает слишком короткие
+// и ограничивает их количество AnchorsCount
+func (s *Service) normalizeAnchors(lines []string) []string {
+	var anchors []string
+	for _, line := range lines {
+		anchor := strings.TrimSpace(line)
+		anchor = strings.TrimPrefix(anchor, "-")
+		anchor = strings.TrimPrefix(anchor, "•")
+		anchor = strings.TrimSpace(anchor)
+
+		if anchor != "" && len(anchor) > 3 {
+			anchors = append(anchors, anchor)
+		}
+	}
+
+	if len(anchors) > s.config.AnchorsCount {
+		anchors = anchors[:s.config.AnchorsCount]
+	}
+	return anchors
+}
+
+// truncateSummary ограничивает длину резюме SummaryMaxLength, не разрезая символ UTF-8
+func (s *Service) truncateSummary(summary string) string {
+	if len(summary) > s.config.SummaryMaxLength {
+		summary = strings.ToValidUTF8(summary[:s.config.SummaryMaxLength-3], "") + "..."
+	}
+	return summary
+}
+
+// dialogLine строка диалога для промпта резюме. Результат инструмента - сырой JSON, поэтому он
+// подписывается именем инструмента и обрезается до ToolResultMaxLength
+func (s *Service) dialogLine(msg models.Message, labels dialogLabels) string {
+	if msg.Role == "tool" {
+		result := msg.Content
+		if len(result) > s.config.ToolResultMaxLength {
+			result = strings.ToValidUTF8(result[:s.config.ToolResultMaxLength], "") + "…"
+		}
+		return fmt.Sprintf(labels.toolResult+"\n", msg.ToolName, result)
 	}
+	return fmt.Sprintf("%s: %s\n", labels.role(msg.Role), msg.Content)
 }
 
 // ShouldCreateSummary определяет, нужно ли создавать резюме (deprecated, используется Context Manager)
@@ -385,6 +422,11 @@ func (s *Service) ShouldCreateSummary(ctx context.Context, sessionID string, mes
 	return false, ""
 }
 
+// MinMessagesForSummary минимум сообщений, из которых CreateSummary создаёт резюме
+func (s *Service) MinMessagesForSummary() int {
+	return s.config.MinMessagesForSummary
+}
+
 // GetSummary получает существующее резюме для сессии
 func (s *Service) GetSummary(ctx context.Context, sessionID string) (*models.Summary, error) {
 	return s.summaryStore.GetSummary(ctx, sessionID)
@@ -427,7 +469,7 @@ func (s *Service) GetContextForLLM(ctx context.Context, sessionID string, recent
 	}
 
 	// Добавляем недавние сообщения
-	recentLLMMessages := llm.ConvertToLLMMessages(recentMessages)
+	recentLLMMessages := llm.ConvertToLLMMessages(recentMessages, llm.ConvertOptions{})
 	context = append(context, recentLLMMessages...)
 
 	return context, nil
@@ -435,36 +477,16 @@ func (s *Service) GetContextForLLM(ctx context.Context, sessionID string, recent
 
 // formatSummaryForContext форматирует резюме для использования в контексте
 func (s *Service) formatSummaryForContext(summary *models.Summary) string {
-	var builder strings.Builder
-
 	levelName := "резюме"
-	if summary.SummaryLevel == 2 {
+	switch summary.SummaryLevel {
+	case 2:
 		levelName = "обобщенное резюме"
+	case 3:
+		levelName = "дайджест сессии"
 	}
 
-	builder.WriteString(fmt.Sprintf("Контекст предыдущего разговора (%s):\n\n", levelName))
-
-	if len(summary.Anchors) > 0 {
-		builder.WriteString("Ключевые темы:\n")
-		for _, anchor := range summary.Anchors {
-			builder.WriteString(fmt.Sprintf("- %s\n", anchor))
-		}
-		builder.WriteString("\n")
-	}
-
-	if summary.SummaryText != "" {
-		if summary.SummaryLevel == 2 {
-			builder.WriteString("Краткое обобщение диалогов:\n")
-		} else {
-			builder.WriteString("Краткое резюме:\n")
-		}
-		builder.WriteString(summary.SummaryText)
-		builder.WriteString("\n\n")
-	}
-
-	builder.WriteString("Продолжай диалог, учитывая этот контекст.")
-
-	return builder.String()
+	label := fmt.Sprintf("Контекст предыдущего разговора (%s)", levelName)
+	return FormatForContext(*summary, label) + "\n\nПродолжай диалог, учитывая этот контекст."
 }
 
 // DeleteSummary удаляет резюме для сессии
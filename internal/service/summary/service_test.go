@@ -0,0 +1,71 @@
+package summary
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"LLM_Chat/internal/storage/models"
+	"LLM_Chat/pkg/llm"
+
+	"go.uber.org/zap"
+)
+
+// recordingLLM модель сжатия, которая запоминает запросы и отвечает replies по очереди
+// (последним ответом - на все остальные запросы)
+type recordingLLM struct {
+	llm.LLMClient
+
+	mu       sync.Mutex
+	replies  []string
+	requests [][]llm.Message
+}
+
+func (c *recordingLLM) ChatCompletion(ctx context.Context, messages []llm.Message) (*llm.ChatResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests = append(c.requests, messages)
+	reply := c.replies[min(len(c.requests), len(c.replies))-1]
+	return &llm.ChatResponse{
+		Choices: []llm.Choice{{Message: llm.Message{Role: "assistant", Content: reply}}},
+		Usage:   llm.Usage{PromptTokens: 8, CompletionTokens: 2, TotalTokens: 10},
+	}, nil
+}
+
+// dialog сообщения диалога на русском для резюме
+func dialog() []models.Message {
+	return []models.Message{
+		{ID: "1", Role: "user", Content: "Как настроить сервер?"},
+		{ID: "2", Role: "assistant", Content: "Установите nginx и откройте порт 80."},
+		{ID: "3", Role: "user", Content: "А как включить HTTPS?"},
+	}
+}
+
+func TestSummaryPromptSentAsSystemMessage(t *testing.T) {
+	client := &recordingLLM{replies: []string{`{"anchors": ["Настройка сервера"], "summary": "Пользователь настраивает nginx."}`}}
+	cfg := DefaultConfig()
+	cfg.Language = "ru"
+	service := NewService(nil, client, nil, nil, cfg, zap.NewNop())
+
+	resp, err := service.CreateSummary(context.Background(), SummaryRequest{SessionID: "s", Messages: dialog(), SummaryLevel: 1, SkipSave: true})
+	if err != nil {
+		t.Fatalf("CreateSummary: %v", err)
+	}
+	if resp.BriefSummary != "Пользователь настраивает nginx." {
+		t.Errorf("summary = %q", resp.BriefSummary)
+	}
+
+	prompt, err := DefaultPrompts().Render(PromptStructuredL1, "ru", PromptData{AnchorsCount: cfg.AnchorsCount, MaxLength: cfg.SummaryMaxLength})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(client.requests) != 1 {
+		t.Fatalf("model requests = %d, want 1", len(client.requests))
+	}
+	// Промпт резюме уходит системным сообщением: провайдер делает из него системную инструкцию
+	request := client.requests[0]
+	if len(request) != 2 || request[0].Role != "system" || request[0].Content != appendLanguageDirective(prompt, "ru") || request[1].Role != "user" {
+		t.Errorf("request = %+v", request)
+	}
+}
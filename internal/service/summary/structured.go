@@ -0,0 +1,121 @@
+package summary
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"LLM_Chat/internal/storage/models"
+	"LLM_Chat/pkg/llm"
+
+	"go.uber.org/zap"
+)
+
+// errStructuredOutput ответ модели не удалось разобрать как JSON с якорями и резюме
+var errStructuredOutput = errors.New("invalid structured summary output")
+
+// structuredOutput ответ модели на запрос резюме с якорями
+type structuredOutput struct {
+	Anchors []string `json:"anchors"`
+	Summary string   `json:"summary"`
+}
+
+// createStructuredSummary создаёт якоря и резюме одним запросом к модели. Если ответ не разобран,
+// возвращает errStructuredOutput вместе с потраченными токенами.
+func (s *Service) createStructuredSummary(ctx context.Context, messages []models.Message, summaryLevel int, lang string) ([]string, string, int, error) {
+	promptName := PromptStructuredL1
+	switch summaryLevel {
+	case 3:
+		promptName = PromptStructuredL3
+	case 2:
+		promptName = PromptStructuredL2
+	}
+	systemPrompt, err := s.config.Prompts.Render(promptName, lang, PromptData{
+		AnchorsCount: s.config.AnchorsCount,
+		MaxLength:    s.config.SummaryMaxLength,
+	})
+	if err != nil {
+		return nil, "", 0, err
+	}
+	systemPrompt = appendLanguageDirective(systemPrompt, lang)
+
+	labels := labelsFor(lang)
+	llmMessages := []llm.Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: s.dialogText(messages, summaryLevel, labels, labels.summaryDialog, labels.summarySummaries)},
+	}
+
+	response, err := s.shrinkClient.ChatCompletion(ctx, llmMessages)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("LLM request failed: %w", err)
+	}
+
+	if len(response.Choices) == 0 {
+		return nil, "", 0, fmt.Errorf("no response from LLM")
+	}
+	tokensUsed := response.Usage.TotalTokens
+
+	raw := response.Choices[0].Message.Content
+	output, err := parseStructuredOutput(raw)
+	if err != nil {
+		s.logger.Debug("Structured summary output rejected",
+			zap.Int("summary_level", summaryLevel),
+			zap.String("output_raw", raw),
+			zap.Error(err),
+		)
+		return nil, "", tokensUsed, err
+	}
+
+	anchors := s.normalizeAnchors(output.Anchors)
+	summary := s.truncateSummary(strings.TrimSpace(output.Summary))
+
+	s.logger.Debug("Created structured summary",
+		zap.Int("summary_level", summaryLevel),
+		zap.Strings("anchors", anchors),
+		zap.Int("summary_length", len(summary)),
+		zap.Int("tokens_used", tokensUsed),
+	)
+
+	return anchors, summary, tokensUsed, nil
+}
+
+// parseStructuredOutput извлекает JSON объект из ответа модели: markdown ограждение кода
+// и текст до и после объекта пропускаются. Ответ без резюме считается ошибкой.
+func parseStructuredOutput(raw string) (*structuredOutput, error) {
+	text := stripCodeFence(strings.TrimSpace(raw))
+
+	start := strings.Index(text, "{")
+	if start < 0 {
+		return nil, fmt.Errorf("%w: no JSON object", errStructuredOutput)
+	}
+
+	// Decoder читает первое значение и не смотрит на текст после него
+	var output structuredOutput
+	if err := json.NewDecoder(strings.NewReader(text[start:])).Decode(&output); err != nil {
+		return nil, fmt.Errorf("%w: %v", errStructuredOutput, err)
+	}
+	if strings.TrimSpace(output.Summary) == "" {
+		return nil, fmt.Errorf("%w: empty summary", errStructuredOutput)
+	}
+
+	return &output, nil
+}
+
+// stripCodeFence убирает ограждение ```json ... ```, если объект в него обёрнут;
+// незакрытое ограждение (обрезанный ответ) снимается только в начале
+func stripCodeFence(text string) string {
+	fence := strings.Index(text, "```")
+	brace := strings.Index(text, "{")
+	if fence < 0 || (brace >= 0 && brace < fence) {
+		return text
+	}
+
+	body := strings.TrimLeftFunc(text[fence+3:], unicode.IsLetter) // язык после ограждения: ```json
+	if end := strings.Index(body, "```"); end >= 0 {
+		body = body[:end]
+	}
+	return strings.TrimSpace(body)
+}
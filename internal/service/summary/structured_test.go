@@ -0,0 +1,85 @@
+package summary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestParseStructuredOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		summary string
+		anchors string
+	}{
+		{"plain", `{"anchors": ["Настройка сервера"], "summary": "Резюме"}`, "Резюме", "[Настройка сервера]"},
+		{"fenced", "```json\n{\"anchors\": [\"a\"], \"summary\": \"Резюме\"}\n```", "Резюме", "[a]"},
+		{"fence without language", "```\n{\"summary\": \"Резюме\"}\n```", "Резюме", "[]"},
+		{"prose around fence", "Вот резюме:\n```json\n{\"anchors\": [], \"summary\": \"Резюме\"}\n```\nНадеюсь, помогло", "Резюме", "[]"},
+		{"prose around object", "Конечно! {\"anchors\": [\"q\"], \"summary\": \"Резюме\"} Что-то ещё { не json", "Резюме", "[q]"},
+		{"fence inside summary", "{\"summary\": \"Код в ``` ограждении\"}", "Код в ``` ограждении", "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := parseStructuredOutput(tt.raw)
+			if err != nil {
+				t.Fatalf("parseStructuredOutput: %v", err)
+			}
+			if output.Summary != tt.summary || fmt.Sprint(output.Anchors) != tt.anchors {
+				t.Errorf("output = %+v, want summary %q and anchors %s", output, tt.summary, tt.anchors)
+			}
+		})
+	}
+}
+
+func TestParseStructuredOutputRejectsBrokenJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"truncated", `{"anchors": ["a"], "summary": "обрез`},
+		{"truncated in fence", "```json\n{\"anchors\": [\"a\"],"},
+		{"no object", "просто текст"},
+		{"empty", ""},
+		{"no summary", `{"anchors": ["a"]}`},
+		{"blank summary", `{"anchors": ["a"], "summary": "  "}`},
+		{"wrong anchors type", `{"anchors": "a", "summary": "Резюме"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseStructuredOutput(tt.raw); !errors.Is(err, errStructuredOutput) {
+				t.Errorf("err = %v, want errStructuredOutput", err)
+			}
+		})
+	}
+}
+
+func TestStructuredSummaryFallsBackToTwoCalls(t *testing.T) {
+	client := &recordingLLM{replies: []string{
+		"```json\n{\"anchors\": [\"Настройка сервера\"], \"summary\": \"Пользователь",
+		"Настройка сервера\nВключение HTTPS",
+		"Пользователь настраивает nginx и HTTPS.",
+	}}
+	cfg := DefaultConfig()
+	cfg.Language = "ru"
+	service := NewService(nil, client, nil, nil, cfg, zap.NewNop())
+
+	resp, err := service.CreateSummary(context.Background(), SummaryRequest{SessionID: "s", Messages: dialog(), SummaryLevel: 1, SkipSave: true})
+	if err != nil {
+		t.Fatalf("CreateSummary: %v", err)
+	}
+	if len(client.requests) != 3 {
+		t.Fatalf("model requests = %d, want the structured request and two fallback requests", len(client.requests))
+	}
+	if resp.BriefSummary != "Пользователь настраивает nginx и HTTPS." || fmt.Sprint(resp.Anchors) != "[Настройка сервера Включение HTTPS]" {
+		t.Errorf("response = %q, anchors %v", resp.BriefSummary, resp.Anchors)
+	}
+	// Токены неразобранного ответа тоже потрачены
+	if resp.TokensUsed != 30 {
+		t.Errorf("tokens used = %d, want 30", resp.TokensUsed)
+	}
+}
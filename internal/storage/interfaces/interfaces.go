@@ -3,12 +3,33 @@ package interfaces
 import (
 	"LLM_Chat/internal/storage/models"
 	"context"
+	"errors"
+	"time"
 )
 
+var (
+	// ErrMessageNotFound сообщение не найдено в указанной сессии
+	ErrMessageNotFound = errors.New("message not found")
+	// ErrSessionNotFound сессия не найдена
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrAlreadyCompressed источники сжатия уже сжаты другим резюме
+	ErrAlreadyCompressed = errors.New("compression sources are already compressed")
+	// ErrSummaryNotFound резюме не найдено в указанной сессии
+	ErrSummaryNotFound = errors.New("summary not found")
+	// ErrSummaryCompressed резюме само сжато в резюме старшего уровня
+	ErrSummaryCompressed = errors.New("summary is compressed into a higher-level summary")
+	// ErrSourcesPurged часть сжатых сообщений удалена очисткой, восстановить их нельзя
+	ErrSourcesPurged = errors.New("compressed source messages have been purged")
+)
+
+// MessageStore хранилище сообщений. Сообщения вызовов инструментов (role tool) возвращают только
+// GetMessagesPage с includeTools и GetActiveToolMessages: в контекст LLM и счётчики они не входят,
+// а сжимаются вместе с окружающим их диалогом.
 type MessageStore interface {
 	// Basic message operations
 	SaveMessage(ctx context.Context, msg models.Message) error
 	GetMessages(ctx context.Context, sessionID string, limit int) ([]models.Message, error)
+	GetMessagesPage(ctx context.Context, sessionID string, limit, offset int, includeTools bool) ([]models.Message, int, error)
 	GetMessageCount(ctx context.Context, sessionID string) (int, error)
 	DeleteSession(ctx context.Context, sessionID string) error
 
@@ -17,9 +38,25 @@ type MessageStore interface {
 
 	// LLM-specific operations (returns uncompressed messages)
 	GetActiveMessages(ctx context.Context, sessionID string) ([]models.Message, error)
+	// GetActiveToolMessages возвращает несжатые сообщения инструментов, созданные между from и to
+	// включительно; нулевой from - с начала сессии
+	GetActiveToolMessages(ctx context.Context, sessionID string, from, to time.Time) ([]models.Message, error)
+
+	// Range operations (regular messages between two messages by created_at, inclusive)
+	GetMessagesInRange(ctx context.Context, sessionID, fromMessageID, toMessageID string) ([]models.Message, error)
 
 	// Compression operations
 	MarkMessagesAsCompressed(ctx context.Context, messageIDs []string, summaryID string) error
+
+	// Status operations
+	UpdateMessageStatus(ctx context.Context, messageID, status string) error
+	// FailStalePendingMessages переводит в failed сообщения, ожидающие ответа дольше olderThan
+	FailStalePendingMessages(ctx context.Context, olderThan time.Time) (int, error)
+
+	// Retention operations
+	// PurgeCompressedMessages удаляет до limit сообщений, сжатых раньше olderThan,
+	// и возвращает количество удалённых по сессиям
+	PurgeCompressedMessages(ctx context.Context, olderThan time.Time, limit int) (map[string]int, error)
 }
 
 type SummaryStore interface {
@@ -34,13 +71,68 @@ type SummaryStore interface {
 
 	// Bulk summary operations (for compressing summaries themselves)
 	MarkSummariesAsCompressed(ctx context.Context, summaryIDs []string, bulkSummaryID string) error
+
+	// ApplyCompression сохраняет резюме и его сообщение и помечает источники сжатыми в одной
+	// транзакции. Если часть источников уже сжата, ничего не меняет и возвращает ErrAlreadyCompressed
+	ApplyCompression(ctx context.Context, compression models.Compression) error
+
+	// GetSummarySources возвращает резюме сессии и источники, которые оно сжало
+	GetSummarySources(ctx context.Context, sessionID, summaryID string) (*models.SummarySources, error)
+	// ExpandSummary отменяет сжатие в одной транзакции: источники снова становятся активными,
+	// резюме и его сообщение удаляются. Резюме, сжатое в резюме старшего уровня, не развёртывается
+	// (ErrSummaryCompressed), как и резюме, исходные сообщения которого уже удалены (ErrSourcesPurged)
+	ExpandSummary(ctx context.Context, sessionID, summaryID string) (*models.SummaryExpansion, error)
 }
 
 type SessionStore interface {
 	CreateSession(ctx context.Context, sessionID string) error
 	GetSession(ctx context.Context, sessionID string) (*models.ChatSession, error)
 	UpdateSession(ctx context.Context, sessionID string) error
+	// SetSessionLanguage задаёт язык ответов сессии (создаёт сессию при необходимости); пустое значение сбрасывает настройку
+	SetSessionLanguage(ctx context.Context, sessionID, language string) error
 	DeleteSession(ctx context.Context, sessionID string) error
+	// CloneSession копирует сессию со всеми сообщениями и резюме под новым ID в одной транзакции;
+	// ссылки на резюме и границы покрытия переназначаются на новые ID
+	CloneSession(ctx context.Context, sourceID, targetID string) (*models.SessionCloneResult, error)
+	// SetSessionUser привязывает сессию к пользователю, если владелец ещё не задан
+	SetSessionUser(ctx context.Context, sessionID, userID string) error
+	// ListSessionIDs возвращает ID сессий по фильтру, давно неактивные первыми
+	ListSessionIDs(ctx context.Context, filter models.SessionFilter) ([]string, error)
+	// ListSessionsUpdatedBefore возвращает до limit сессий, не обновлявшихся с before, в которых
+	// больше minActiveMessages несжатых сообщений диалога (без сообщений инструментов), давно неактивные первыми
+	ListSessionsUpdatedBefore(ctx context.Context, before time.Time, minActiveMessages, limit int) ([]string, error)
+	// DeleteSessions удаляет сессии одной транзакцией вместе с сообщениями и резюме
+	// и возвращает количество удалённых; отсутствующие ID пропускаются
+	DeleteSessions(ctx context.Context, ids []string) (int, error)
+}
+
+// PreferencesStore хранит настройки пользователей
+type PreferencesStore interface {
+	// GetUserPreferences возвращает nil без ошибки, если настройки ещё не сохранялись
+	GetUserPreferences(ctx context.Context, userID string) (*models.UserPreferences, error)
+	SaveUserPreferences(ctx context.Context, prefs models.UserPreferences) error
+}
+
+// ToolInvocationStore журнал аудита вызовов MCP инструментов
+type ToolInvocationStore interface {
+	SaveToolInvocation(ctx context.Context, invocation models.ToolInvocation) error
+	// ListToolInvocations возвращает страницу записей (новые первыми) и общее количество по фильтру
+	ListToolInvocations(ctx context.Context, filter models.ToolInvocationFilter, limit, offset int) ([]models.ToolInvocation, int, error)
+}
+
+// EmbeddingStore векторы сообщений для семантического поиска по сжатой истории
+type EmbeddingStore interface {
+	// SaveMessageEmbeddings сохраняет векторы (повторное сохранение заменяет вектор);
+	// векторы удалённых к этому моменту сообщений пропускаются
+	SaveMessageEmbeddings(ctx context.Context, embeddings []models.MessageEmbedding) error
+	// GetCompressedMessageEmbeddings возвращает сжатые сообщения сессии с векторами модели model;
+	// сообщения без вектора (сохранённые до включения поиска) пропускаются
+	GetCompressedMessageEmbeddings(ctx context.Context, sessionID, model string) ([]models.EmbeddedMessage, error)
+}
+
+// HealthChecker checks storage availability for readiness probes
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) (*models.StorageHealth, error)
 }
 
 // ExtendedMessageStore combines all storage interfaces for convenience
@@ -48,4 +140,5 @@ type ExtendedMessageStore interface {
 	MessageStore
 	SummaryStore
 	SessionStore
+	EmbeddingStore
 }
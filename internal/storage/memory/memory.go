@@ -12,17 +12,22 @@ import (
 )
 
 type MemoryStorage struct {
-	messages  map[string][]models.Message   // sessionID -> messages
-	summaries map[string]models.Summary     // sessionID -> summary
-	sessions  map[string]models.ChatSession // sessionID -> session
+	messages  map[string][]models.Message        // sessionID -> messages
+	summaries map[string][]models.Summary        // sessionID -> резюме всех уровней в порядке создания
+	sessions  map[string]models.ChatSession      // sessionID -> session
+	prefs     map[string]models.UserPreferences  // userID -> preferences
+	toolLog   []models.ToolInvocation            // журнал аудита в порядке записи
+	vectors   map[string]models.MessageEmbedding // messageID -> вектор сообщения
 	mu        sync.RWMutex
 }
 
 func New() *MemoryStorage {
 	return &MemoryStorage{
 		messages:  make(map[string][]models.Message),
-		summaries: make(map[string]models.Summary),
+		summaries: make(map[string][]models.Summary),
 		sessions:  make(map[string]models.ChatSession),
+		prefs:     make(map[string]models.UserPreferences),
+		vectors:   make(map[string]models.MessageEmbedding),
 	}
 }
 
@@ -31,6 +36,9 @@ func (m *MemoryStorage) SaveMessage(ctx context.Context, msg models.Message) err
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if msg.Status == "" {
+		msg.Status = models.MessageStatusCompleted
+	}
 	m.messages[msg.SessionID] = append(m.messages[msg.SessionID], msg)
 
 	// Update session
@@ -43,18 +51,68 @@ func (m *MemoryStorage) SaveMessage(ctx context.Context, msg models.Message) err
 	return nil
 }
 
+func (m *MemoryStorage) UpdateMessageStatus(ctx context.Context, messageID, status string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for sessionID, messages := range m.messages {
+		for i := range messages {
+			if messages[i].ID == messageID {
+				m.messages[sessionID][i].Status = status
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("%w: %s", interfaces.ErrMessageNotFound, messageID)
+}
+
+func (m *MemoryStorage) FailStalePendingMessages(ctx context.Context, olderThan time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	failed := 0
+	for sessionID, messages := range m.messages {
+		for i := range messages {
+			if messages[i].Status == models.MessageStatusPending && messages[i].Timestamp.Before(olderThan) {
+				m.messages[sessionID][i].Status = models.MessageStatusFailed
+				failed++
+			}
+		}
+	}
+
+	return failed, nil
+}
+
+// PurgeCompressedMessages не знает времени сжатия и ориентируется на время создания сообщения
+func (m *MemoryStorage) PurgeCompressedMessages(ctx context.Context, olderThan time.Time, limit int) (map[string]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	purged := make(map[string]int)
+	total := 0
+	for sessionID, messages := range m.messages {
+		kept := messages[:0]
+		for _, msg := range messages {
+			if total < limit && msg.IsCompressed && msg.IsRegular() && msg.Timestamp.Before(olderThan) {
+				purged[sessionID]++
+				total++
+				continue
+			}
+			kept = append(kept, msg)
+		}
+		m.messages[sessionID] = kept
+	}
+
+	return purged, nil
+}
+
 func (m *MemoryStorage) GetMessages(ctx context.Context, sessionID string, limit int) ([]models.Message, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	messages, exists := m.messages[sessionID]
-	if !exists {
-		return []models.Message{}, nil
-	}
-
-	// Sort by timestamp
-	sort.Slice(messages, func(i, j int) bool {
-		return messages[i].Timestamp.Before(messages[j].Timestamp)
+	messages := m.sessionMessages(sessionID, func(msg *models.Message) bool {
+		return msg.Role != "tool"
 	})
 
 	// Apply limit
@@ -65,16 +123,129 @@ func (m *MemoryStorage) GetMessages(ctx context.Context, sessionID string, limit
 	return messages, nil
 }
 
+func (m *MemoryStorage) GetMessagesPage(ctx context.Context, sessionID string, limit, offset int, includeTools bool) ([]models.Message, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	messages := m.sessionMessages(sessionID, func(msg *models.Message) bool {
+		return includeTools || msg.Role != "tool"
+	})
+
+	total := len(messages)
+	if offset >= total {
+		return []models.Message{}, total, nil
+	}
+
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	return messages[offset:end], total, nil
+}
+
+func (m *MemoryStorage) GetMessagesInRange(ctx context.Context, sessionID, fromMessageID, toMessageID string) ([]models.Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var fromAt, toAt time.Time
+	var fromFound, toFound bool
+	for _, msg := range m.messages[sessionID] {
+		if msg.ID == fromMessageID {
+			fromAt, fromFound = msg.Timestamp, true
+		}
+		if msg.ID == toMessageID {
+			toAt, toFound = msg.Timestamp, true
+		}
+	}
+	if !fromFound {
+		return nil, fmt.Errorf("%w: %s", interfaces.ErrMessageNotFound, fromMessageID)
+	}
+	if !toFound {
+		return nil, fmt.Errorf("%w: %s", interfaces.ErrMessageNotFound, toMessageID)
+	}
+
+	return m.sessionMessages(sessionID, func(msg *models.Message) bool {
+		return msg.IsRegular() && msg.Role != "tool" && !msg.Timestamp.Before(fromAt) && !msg.Timestamp.After(toAt)
+	}), nil
+}
+
+func (m *MemoryStorage) GetMessagesForUI(ctx context.Context, sessionID string) ([]models.Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.sessionMessages(sessionID, func(msg *models.Message) bool {
+		return msg.IsRegular() && msg.Role != "tool"
+	}), nil
+}
+
+func (m *MemoryStorage) GetActiveMessages(ctx context.Context, sessionID string) ([]models.Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.sessionMessages(sessionID, func(msg *models.Message) bool {
+		return msg.IsRegular() && msg.Role != "tool" && !msg.IsCompressed
+	}), nil
+}
+
+func (m *MemoryStorage) GetActiveToolMessages(ctx context.Context, sessionID string, from, to time.Time) ([]models.Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.sessionMessages(sessionID, func(msg *models.Message) bool {
+		return msg.IsRegular() && msg.Role == "tool" && !msg.IsCompressed &&
+			!msg.Timestamp.Before(from) && !msg.Timestamp.After(to)
+	}), nil
+}
+
+func (m *MemoryStorage) MarkMessagesAsCompressed(ctx context.Context, messageIDs []string, summaryID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	compressed := make(map[string]bool, len(messageIDs))
+	for _, id := range messageIDs {
+		compressed[id] = true
+	}
+	for _, messages := range m.messages {
+		for i := range messages {
+			if compressed[messages[i].ID] {
+				messages[i].IsCompressed = true
+				messages[i].SummaryID = summaryID
+			}
+		}
+	}
+
+	return nil
+}
+
+// sessionMessages копия сообщений сессии, отобранных keep, в порядке создания
+func (m *MemoryStorage) sessionMessages(sessionID string, keep func(msg *models.Message) bool) []models.Message {
+	var result []models.Message
+	for _, msg := range m.messages[sessionID] {
+		if keep(&msg) {
+			result = append(result, msg)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp.Before(result[j].Timestamp)
+	})
+
+	return result
+}
+
 func (m *MemoryStorage) GetMessageCount(ctx context.Context, sessionID string) (int, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	messages, exists := m.messages[sessionID]
-	if !exists {
-		return 0, nil
+	count := 0
+	for _, msg := range m.messages[sessionID] {
+		if msg.IsRegular() && msg.Role != "tool" {
+			count++
+		}
 	}
 
-	return len(messages), nil
+	return count, nil
 }
 
 func (m *MemoryStorage) DeleteSession(ctx context.Context, sessionID string) error {
@@ -93,11 +264,12 @@ func (m *MemoryStorage) GetSummary(ctx context.Context, sessionID string) (*mode
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	summary, exists := m.summaries[sessionID]
-	if !exists {
-		return nil, fmt.Errorf("summary not found for session %s", sessionID)
+	summaries := m.summaries[sessionID]
+	if len(summaries) == 0 {
+		return nil, fmt.Errorf("%w: session %s", interfaces.ErrSummaryNotFound, sessionID)
 	}
 
+	summary := summaries[len(summaries)-1]
 	return &summary, nil
 }
 
@@ -105,10 +277,218 @@ func (m *MemoryStorage) SaveSummary(ctx context.Context, summary models.Summary)
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	m.summaries[summary.SessionID] = summary
+	m.summaries[summary.SessionID] = append(m.summaries[summary.SessionID], summary)
 	return nil
 }
 
+// GetSummariesByLevel как и в PostgreSQL, возвращает только несжатые резюме уровня
+func (m *MemoryStorage) GetSummariesByLevel(ctx context.Context, sessionID string, level int) ([]models.Summary, error) {
+	return m.GetActiveSummaries(ctx, sessionID, level)
+}
+
+func (m *MemoryStorage) GetActiveSummaries(ctx context.Context, sessionID string, level int) ([]models.Summary, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []models.Summary
+	for _, summary := range m.summaries[sessionID] {
+		if summary.SummaryLevel == level && !summary.IsCompressed {
+			result = append(result, summary)
+		}
+	}
+
+	return result, nil
+}
+
+func (m *MemoryStorage) MarkSummariesAsCompressed(ctx context.Context, summaryIDs []string, bulkSummaryID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	compressed := make(map[string]bool, len(summaryIDs))
+	for _, id := range summaryIDs {
+		compressed[id] = true
+	}
+	for _, summaries := range m.summaries {
+		for i := range summaries {
+			if compressed[summaries[i].ID] {
+				summaries[i].IsCompressed = true
+				summaries[i].SummaryID = bulkSummaryID
+			}
+		}
+	}
+
+	return nil
+}
+
+// ApplyCompression применяет сжатие под одной блокировкой: источники проверяются до любых
+// изменений, поэтому при ошибке состояние хранилища не меняется
+func (m *MemoryStorage) ApplyCompression(ctx context.Context, compression models.Compression) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sessionID := compression.Summary.SessionID
+	messages := m.messages[sessionID]
+	summaries := m.summaries[sessionID]
+
+	compressedMessages := make(map[string]bool, len(compression.MessageIDs))
+	for _, id := range compression.MessageIDs {
+		compressedMessages[id] = true
+	}
+	marked := 0
+	for _, msg := range messages {
+		if compressedMessages[msg.ID] {
+			if msg.IsCompressed {
+				return fmt.Errorf("%w: message %s", interfaces.ErrAlreadyCompressed, msg.ID)
+			}
+			marked++
+		}
+	}
+	if marked != len(compressedMessages) {
+		return fmt.Errorf("%w: %d of %d messages not found", interfaces.ErrMessageNotFound, len(compressedMessages)-marked, len(compressedMessages))
+	}
+
+	compressedSummaries := make(map[string]bool, len(compression.SummaryIDs))
+	for _, id := range compression.SummaryIDs {
+		compressedSummaries[id] = true
+	}
+	marked = 0
+	for _, summary := range summaries {
+		if compressedSummaries[summary.ID] {
+			if summary.IsCompressed {
+				return fmt.Errorf("%w: summary %s", interfaces.ErrAlreadyCompressed, summary.ID)
+			}
+			marked++
+		}
+	}
+	if marked != len(compressedSummaries) {
+		return fmt.Errorf("%w: %d of %d summaries not found", interfaces.ErrSummaryNotFound, len(compressedSummaries)-marked, len(compressedSummaries))
+	}
+
+	for i := range messages {
+		if compressedMessages[messages[i].ID] {
+			messages[i].IsCompressed = true
+			messages[i].SummaryID = compression.Summary.ID
+		}
+	}
+	for i := range summaries {
+		if compressedSummaries[summaries[i].ID] {
+			summaries[i].IsCompressed = true
+			summaries[i].SummaryID = compression.Summary.ID
+		}
+	}
+
+	msg := compression.SummaryMessage
+	msg.SummaryID = compression.Summary.ID
+	if msg.Status == "" {
+		msg.Status = models.MessageStatusCompleted
+	}
+	m.messages[sessionID] = append(messages, msg)
+	m.summaries[sessionID] = append(summaries, compression.Summary)
+
+	return nil
+}
+
+// GetSummarySources возвращает резюме сессии и сжатые в него сообщения или резюме младших уровней
+func (m *MemoryStorage) GetSummarySources(ctx context.Context, sessionID, summaryID string) (*models.SummarySources, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	index := m.summaryIndex(sessionID, summaryID)
+	if index < 0 {
+		return nil, fmt.Errorf("%w: %s", interfaces.ErrSummaryNotFound, summaryID)
+	}
+
+	sources := &models.SummarySources{Summary: m.summaries[sessionID][index]}
+	if sources.Summary.SummaryLevel == 1 {
+		sources.Messages = m.sessionMessages(sessionID, func(msg *models.Message) bool {
+			return msg.SummaryID == summaryID && msg.IsRegular()
+		})
+		return sources, nil
+	}
+
+	for _, summary := range m.summaries[sessionID] {
+		if summary.SummaryID == summaryID {
+			sources.Summaries = append(sources.Summaries, summary)
+		}
+	}
+	return sources, nil
+}
+
+// ExpandSummary развёртывает резюме под одной блокировкой
+func (m *MemoryStorage) ExpandSummary(ctx context.Context, sessionID, summaryID string) (*models.SummaryExpansion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	index := m.summaryIndex(sessionID, summaryID)
+	if index < 0 {
+		return nil, fmt.Errorf("%w: %s", interfaces.ErrSummaryNotFound, summaryID)
+	}
+	summaries := m.summaries[sessionID]
+	summary := summaries[index]
+	if summary.IsCompressed {
+		return nil, fmt.Errorf("%w: %s is covered by %s", interfaces.ErrSummaryCompressed, summaryID, summary.SummaryID)
+	}
+
+	expansion := &models.SummaryExpansion{
+		SummaryID:    summaryID,
+		SummaryLevel: summary.SummaryLevel,
+	}
+
+	messages := m.messages[sessionID]
+	if summary.SummaryLevel == 1 {
+		for _, msg := range messages {
+			if msg.SummaryID == summaryID && msg.IsRegular() {
+				expansion.MessagesRestored++
+			}
+		}
+		if expansion.MessagesRestored < summary.MessageCount {
+			return nil, fmt.Errorf("%w: %d of %d messages of summary %s remain",
+				interfaces.ErrSourcesPurged, expansion.MessagesRestored, summary.MessageCount, summaryID)
+		}
+	}
+
+	kept := messages[:0]
+	for _, msg := range messages {
+		if msg.SummaryID != summaryID {
+			kept = append(kept, msg)
+			continue
+		}
+		if !msg.IsRegular() {
+			continue // сообщение самого резюме
+		}
+		msg.IsCompressed = false
+		msg.SummaryID = ""
+		kept = append(kept, msg)
+	}
+	m.messages[sessionID] = kept
+
+	keptSummaries := make([]models.Summary, 0, len(summaries)-1)
+	for i, s := range summaries {
+		if i == index {
+			continue
+		}
+		if s.SummaryID == summaryID {
+			s.IsCompressed = false
+			s.SummaryID = ""
+			expansion.SummariesRestored++
+		}
+		keptSummaries = append(keptSummaries, s)
+	}
+	m.summaries[sessionID] = keptSummaries
+
+	return expansion, nil
+}
+
+// summaryIndex позиция резюме сессии по ID или -1
+func (m *MemoryStorage) summaryIndex(sessionID, summaryID string) int {
+	for i, summary := range m.summaries[sessionID] {
+		if summary.ID == summaryID {
+			return i
+		}
+	}
+	return -1
+}
+
 func (m *MemoryStorage) DeleteSummary(ctx context.Context, sessionID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -163,7 +543,282 @@ func (m *MemoryStorage) UpdateSession(ctx context.Context, sessionID string) err
 	return nil
 }
 
+func (m *MemoryStorage) SetSessionLanguage(ctx context.Context, sessionID, language string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, exists := m.sessions[sessionID]
+	if !exists {
+		session = models.ChatSession{
+			ID:        sessionID,
+			CreatedAt: time.Now(),
+		}
+	}
+
+	session.Language = language
+	session.UpdatedAt = time.Now()
+	m.sessions[sessionID] = session
+
+	return nil
+}
+
+func (m *MemoryStorage) CloneSession(ctx context.Context, sourceID, targetID string) (*models.SessionCloneResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	source, exists := m.sessions[sourceID]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", interfaces.ErrSessionNotFound, sourceID)
+	}
+	if _, exists := m.sessions[targetID]; exists {
+		return nil, fmt.Errorf("session %s already exists", targetID)
+	}
+
+	cloned := models.CloneSessionContent(targetID, m.messages[sourceID], m.summaries[sourceID])
+
+	now := time.Now()
+	m.sessions[targetID] = models.ChatSession{
+		ID:           targetID,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		MessageCount: len(cloned.Messages),
+		Language:     source.Language,
+		ClonedFrom:   sourceID,
+		UserID:       source.UserID,
+	}
+	if len(cloned.Messages) > 0 {
+		m.messages[targetID] = cloned.Messages
+	}
+	if len(cloned.Summaries) > 0 {
+		m.summaries[targetID] = cloned.Summaries
+	}
+
+	return &models.SessionCloneResult{
+		SessionID:       targetID,
+		SourceSessionID: sourceID,
+		MessagesCopied:  len(cloned.Messages),
+		SummariesCopied: len(cloned.Summaries),
+	}, nil
+}
+
+func (m *MemoryStorage) SetSessionUser(ctx context.Context, sessionID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, exists := m.sessions[sessionID]
+	if !exists || session.UserID != "" {
+		return nil
+	}
+
+	session.UserID = userID
+	m.sessions[sessionID] = session
+
+	return nil
+}
+
+func (m *MemoryStorage) ListSessionIDs(ctx context.Context, filter models.SessionFilter) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var only map[string]bool
+	if filter.IDs != nil {
+		only = make(map[string]bool, len(filter.IDs))
+		for _, id := range filter.IDs {
+			only[id] = true
+		}
+	}
+
+	var sessions []models.ChatSession
+	for _, session := range m.sessions {
+		if only != nil && !only[session.ID] {
+			continue
+		}
+		if !filter.UpdatedBefore.IsZero() && !session.UpdatedAt.Before(filter.UpdatedBefore) {
+			continue
+		}
+		if filter.UserID != "" && session.UserID != filter.UserID {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].UpdatedAt.Equal(sessions[j].UpdatedAt) {
+			return sessions[i].UpdatedAt.Before(sessions[j].UpdatedAt)
+		}
+		return sessions[i].ID < sessions[j].ID
+	})
+
+	ids := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		ids = append(ids, session.ID)
+	}
+
+	return ids, nil
+}
+
+func (m *MemoryStorage) ListSessionsUpdatedBefore(ctx context.Context, before time.Time, minActiveMessages, limit int) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var sessions []models.ChatSession
+	for _, session := range m.sessions {
+		if !session.UpdatedAt.Before(before) {
+			continue
+		}
+		active := 0
+		for _, msg := range m.messages[session.ID] {
+			if msg.IsRegular() && msg.Role != "tool" && !msg.IsCompressed {
+				active++
+			}
+		}
+		if active > minActiveMessages {
+			sessions = append(sessions, session)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].UpdatedAt.Equal(sessions[j].UpdatedAt) {
+			return sessions[i].UpdatedAt.Before(sessions[j].UpdatedAt)
+		}
+		return sessions[i].ID < sessions[j].ID
+	})
+	if len(sessions) > limit {
+		sessions = sessions[:limit]
+	}
+
+	ids := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		ids = append(ids, session.ID)
+	}
+
+	return ids, nil
+}
+
+func (m *MemoryStorage) DeleteSessions(ctx context.Context, ids []string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deleted := 0
+	for _, id := range ids {
+		if _, exists := m.sessions[id]; !exists {
+			continue
+		}
+		delete(m.messages, id)
+		delete(m.summaries, id)
+		delete(m.sessions, id)
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// PreferencesStore implementation
+func (m *MemoryStorage) GetUserPreferences(ctx context.Context, userID string) (*models.UserPreferences, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	prefs, exists := m.prefs[userID]
+	if !exists {
+		return nil, nil
+	}
+
+	return &prefs, nil
+}
+
+func (m *MemoryStorage) SaveUserPreferences(ctx context.Context, prefs models.UserPreferences) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if prefs.UpdatedAt.IsZero() {
+		prefs.UpdatedAt = time.Now()
+	}
+	m.prefs[prefs.UserID] = prefs
+
+	return nil
+}
+
+// ToolInvocationStore implementation
+func (m *MemoryStorage) SaveToolInvocation(ctx context.Context, invocation models.ToolInvocation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if invocation.CreatedAt.IsZero() {
+		invocation.CreatedAt = time.Now()
+	}
+	m.toolLog = append(m.toolLog, invocation)
+
+	return nil
+}
+
+func (m *MemoryStorage) ListToolInvocations(ctx context.Context, filter models.ToolInvocationFilter, limit, offset int) ([]models.ToolInvocation, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var matched []models.ToolInvocation
+	for i := len(m.toolLog) - 1; i >= 0; i-- {
+		invocation := m.toolLog[i]
+		if filter.SessionID != "" && invocation.SessionID != filter.SessionID {
+			continue
+		}
+		if filter.ToolName != "" && invocation.ToolName != filter.ToolName {
+			continue
+		}
+		if !filter.From.IsZero() && invocation.CreatedAt.Before(filter.From) {
+			continue
+		}
+		if !filter.To.IsZero() && invocation.CreatedAt.After(filter.To) {
+			continue
+		}
+		matched = append(matched, invocation)
+	}
+
+	total := len(matched)
+	if offset >= total {
+		return []models.ToolInvocation{}, total, nil
+	}
+
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	return matched[offset:end], total, nil
+}
+
+// EmbeddingStore implementation
+func (m *MemoryStorage) SaveMessageEmbeddings(ctx context.Context, embeddings []models.MessageEmbedding) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, embedding := range embeddings {
+		m.vectors[embedding.MessageID] = embedding
+	}
+	return nil
+}
+
+func (m *MemoryStorage) GetCompressedMessageEmbeddings(ctx context.Context, sessionID, model string) ([]models.EmbeddedMessage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []models.EmbeddedMessage
+	for _, msg := range m.messages[sessionID] {
+		if !msg.IsCompressed || !msg.IsRegular() {
+			continue
+		}
+		embedding, ok := m.vectors[msg.ID]
+		if !ok || embedding.Model != model {
+			continue
+		}
+		result = append(result, models.EmbeddedMessage{Message: msg, Vector: embedding.Vector})
+	}
+	return result, nil
+}
+
 // Verify interfaces implementation
 var _ interfaces.MessageStore = (*MemoryStorage)(nil)
 var _ interfaces.SummaryStore = (*MemoryStorage)(nil)
 var _ interfaces.SessionStore = (*MemoryStorage)(nil)
+var _ interfaces.PreferencesStore = (*MemoryStorage)(nil)
+var _ interfaces.ToolInvocationStore = (*MemoryStorage)(nil)
+var _ interfaces.EmbeddingStore = (*MemoryStorage)(nil)
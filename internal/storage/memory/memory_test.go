@@ -0,0 +1,358 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"LLM_Chat/internal/storage/interfaces"
+	"LLM_Chat/internal/storage/models"
+)
+
+var baseTime = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// seedMessages сохраняет n сообщений диалога с возрастающим временем создания
+func seedMessages(t *testing.T, store *MemoryStorage, sessionID string, n int) []models.Message {
+	t.Helper()
+
+	messages := make([]models.Message, n)
+	for i := range messages {
+		msg := models.NewUserMessage(sessionID, fmt.Sprintf("message %d", i))
+		if i%2 == 1 {
+			msg = models.NewAssistantMessage(sessionID, fmt.Sprintf("message %d", i))
+		}
+		msg.ID = fmt.Sprintf("%s-m%d", sessionID, i)
+		msg.Timestamp = baseTime.Add(time.Duration(i) * time.Minute)
+		if err := store.SaveMessage(context.Background(), msg); err != nil {
+			t.Fatalf("SaveMessage: %v", err)
+		}
+		messages[i] = msg
+	}
+	return messages
+}
+
+func compressionOf(sessionID, summaryID string, level int, messageIDs, summaryIDs []string) models.Compression {
+	summaryMessage := models.NewSummaryMessage(sessionID, "summary "+summaryID, level)
+	summaryMessage.ID = "msg-" + summaryID
+	return models.Compression{
+		Summary: models.Summary{
+			ID:           summaryID,
+			SessionID:    sessionID,
+			SummaryText:  "summary " + summaryID,
+			SummaryLevel: level,
+			MessageCount: len(messageIDs),
+		},
+		SummaryMessage: summaryMessage,
+		MessageIDs:     messageIDs,
+		SummaryIDs:     summaryIDs,
+	}
+}
+
+func messageIDs(messages []models.Message) []string {
+	ids := make([]string, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.ID
+	}
+	return ids
+}
+
+func TestActiveMessagesAndSummaries(t *testing.T) {
+	ctx := context.Background()
+	store := New()
+	messages := seedMessages(t, store, "s1", 6)
+
+	tool := models.NewToolMessage("s1", `{"ok":true}`, "lookup", "call-1")
+	tool.ID = "s1-tool"
+	tool.Timestamp = baseTime.Add(90 * time.Second)
+	if err := store.SaveMessage(ctx, tool); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.ApplyCompression(ctx, compressionOf("s1", "sum1", 1, messageIDs(messages[:4]), nil)); err != nil {
+		t.Fatalf("ApplyCompression: %v", err)
+	}
+
+	active, err := store.GetActiveMessages(ctx, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := messageIDs(active); fmt.Sprint(got) != "[s1-m4 s1-m5]" {
+		t.Errorf("active messages = %v, want [s1-m4 s1-m5]", got)
+	}
+
+	summaries, err := store.GetActiveSummaries(ctx, "s1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 || summaries[0].ID != "sum1" {
+		t.Fatalf("active summaries = %+v, want sum1", summaries)
+	}
+
+	// Сообщения инструментов не входят в активные сообщения и счётчик
+	count, err := store.GetMessageCount(ctx, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 6 {
+		t.Errorf("message count = %d, want 6", count)
+	}
+	tools, err := store.GetActiveToolMessages(ctx, "s1", time.Time{}, baseTime.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tools) != 1 || tools[0].ID != "s1-tool" {
+		t.Errorf("active tool messages = %v, want [s1-tool]", messageIDs(tools))
+	}
+
+	page, total, err := store.GetMessagesPage(ctx, "s1", 3, 2, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 8 || fmt.Sprint(messageIDs(page)) != "[s1-tool s1-m2 s1-m3]" {
+		t.Errorf("page = %v (total %d), want [s1-tool s1-m2 s1-m3] of 8", messageIDs(page), total)
+	}
+
+	inRange, err := store.GetMessagesInRange(ctx, "s1", "s1-m1", "s1-m3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(messageIDs(inRange)) != "[s1-m1 s1-m2 s1-m3]" {
+		t.Errorf("range = %v, want [s1-m1 s1-m2 s1-m3]", messageIDs(inRange))
+	}
+	if _, err := store.GetMessagesInRange(ctx, "s1", "missing", "s1-m3"); !errors.Is(err, interfaces.ErrMessageNotFound) {
+		t.Errorf("range with unknown message: err = %v, want ErrMessageNotFound", err)
+	}
+}
+
+func TestApplyCompressionRejectsPartialState(t *testing.T) {
+	ctx := context.Background()
+	store := New()
+	messages := seedMessages(t, store, "s1", 6)
+
+	if err := store.ApplyCompression(ctx, compressionOf("s1", "sum1", 1, messageIDs(messages[:3]), nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Второе сжатие пересекается с первым: ни резюме, ни пометки не должны появиться
+	err := store.ApplyCompression(ctx, compressionOf("s1", "sum2", 1, messageIDs(messages[2:5]), nil))
+	if !errors.Is(err, interfaces.ErrAlreadyCompressed) {
+		t.Fatalf("err = %v, want ErrAlreadyCompressed", err)
+	}
+
+	active, _ := store.GetActiveMessages(ctx, "s1")
+	if got := messageIDs(active); fmt.Sprint(got) != "[s1-m3 s1-m4 s1-m5]" {
+		t.Errorf("active messages after failed compression = %v", got)
+	}
+	summaries, _ := store.GetActiveSummaries(ctx, "s1", 1)
+	if len(summaries) != 1 {
+		t.Errorf("summaries after failed compression = %d, want 1", len(summaries))
+	}
+	all, _, _ := store.GetMessagesPage(ctx, "s1", 100, 0, true)
+	for _, msg := range all {
+		if msg.ID == "msg-sum2" {
+			t.Error("summary message of the failed compression was saved")
+		}
+	}
+}
+
+func TestBulkCompressionAndExpand(t *testing.T) {
+	ctx := context.Background()
+	store := New()
+	messages := seedMessages(t, store, "s1", 6)
+
+	for i, summaryID := range []string{"sum1", "sum2", "sum3"} {
+		ids := messageIDs(messages[i*2 : i*2+2])
+		if err := store.ApplyCompression(ctx, compressionOf("s1", summaryID, 1, ids, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.ApplyCompression(ctx, compressionOf("s1", "bulk1", 2, nil, []string{"sum1", "sum2"})); err != nil {
+		t.Fatal(err)
+	}
+
+	level1, _ := store.GetActiveSummaries(ctx, "s1", 1)
+	level2, _ := store.GetSummariesByLevel(ctx, "s1", 2)
+	if len(level1) != 1 || level1[0].ID != "sum3" || len(level2) != 1 || level2[0].ID != "bulk1" {
+		t.Fatalf("level 1 = %+v, level 2 = %+v", level1, level2)
+	}
+
+	if _, err := store.ExpandSummary(ctx, "s1", "sum1"); !errors.Is(err, interfaces.ErrSummaryCompressed) {
+		t.Errorf("expanding a compressed summary: err = %v, want ErrSummaryCompressed", err)
+	}
+
+	sources, err := store.GetSummarySources(ctx, "s1", "bulk1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources.Summaries) != 2 {
+		t.Errorf("bulk sources = %d summaries, want 2", len(sources.Summaries))
+	}
+
+	expansion, err := store.ExpandSummary(ctx, "s1", "bulk1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expansion.SummariesRestored != 2 {
+		t.Errorf("summaries restored = %d, want 2", expansion.SummariesRestored)
+	}
+	level1, _ = store.GetActiveSummaries(ctx, "s1", 1)
+	if len(level1) != 3 {
+		t.Errorf("level 1 summaries after expand = %d, want 3", len(level1))
+	}
+
+	expansion, err = store.ExpandSummary(ctx, "s1", "sum1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expansion.MessagesRestored != 2 {
+		t.Errorf("messages restored = %d, want 2", expansion.MessagesRestored)
+	}
+	active, _ := store.GetActiveMessages(ctx, "s1")
+	if fmt.Sprint(messageIDs(active)) != "[s1-m0 s1-m1]" {
+		t.Errorf("active messages after expand = %v, want [s1-m0 s1-m1]", messageIDs(active))
+	}
+}
+
+func TestCompressedMessageEmbeddings(t *testing.T) {
+	ctx := context.Background()
+	store := New()
+	messages := seedMessages(t, store, "s1", 4)
+
+	err := store.SaveMessageEmbeddings(ctx, []models.MessageEmbedding{
+		{MessageID: "s1-m0", SessionID: "s1", Model: "m", Vector: []float32{1, 0}},
+		{MessageID: "s1-m1", SessionID: "s1", Model: "other", Vector: []float32{0, 1}},
+		{MessageID: "s1-m3", SessionID: "s1", Model: "m", Vector: []float32{1, 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ApplyCompression(ctx, compressionOf("s1", "sum1", 1, messageIDs(messages[:2]), nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Несжатое s1-m3 и вектор другой модели не возвращаются, s1-m2 без вектора пропускается
+	embedded, err := store.GetCompressedMessageEmbeddings(ctx, "s1", "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(embedded) != 1 || embedded[0].Message.ID != "s1-m0" {
+		t.Errorf("embedded = %+v, want only s1-m0", embedded)
+	}
+}
+
+func TestCloneSessionWithCompressedState(t *testing.T) {
+	ctx := context.Background()
+	store := New()
+	if err := store.CreateSession(ctx, "s1"); err != nil {
+		t.Fatal(err)
+	}
+	messages := seedMessages(t, store, "s1", 6)
+	for i, summaryID := range []string{"sum1", "sum2"} {
+		ids := messageIDs(messages[i*2 : i*2+2])
+		if err := store.ApplyCompression(ctx, compressionOf("s1", summaryID, 1, ids, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.ApplyCompression(ctx, compressionOf("s1", "bulk1", 2, nil, []string{"sum1", "sum2"})); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := store.CloneSession(ctx, "s1", "s2")
+	if err != nil {
+		t.Fatalf("CloneSession: %v", err)
+	}
+	// 6 сообщений диалога и 3 сообщения резюме
+	if result.MessagesCopied != 9 || result.SummariesCopied != 3 {
+		t.Errorf("result = %+v, want 9 messages and 3 summaries", result)
+	}
+	session, err := store.GetSession(ctx, "s2")
+	if err != nil || session.ClonedFrom != "s1" {
+		t.Fatalf("cloned session = %+v, err = %v", session, err)
+	}
+
+	// Сжатое состояние копии работает на её собственных ID
+	active, _ := store.GetActiveMessages(ctx, "s2")
+	if len(active) != 2 || active[0].Content != "message 4" {
+		t.Errorf("active messages of the clone = %+v", active)
+	}
+	level2, _ := store.GetSummariesByLevel(ctx, "s2", 2)
+	if len(level2) != 1 || level2[0].ID == "bulk1" {
+		t.Fatalf("level 2 summaries of the clone = %+v", level2)
+	}
+	if _, err := store.ExpandSummary(ctx, "s2", level2[0].ID); err != nil {
+		t.Fatalf("expanding the cloned bulk summary: %v", err)
+	}
+	level1, _ := store.GetActiveSummaries(ctx, "s2", 1)
+	if len(level1) != 2 {
+		t.Fatalf("level 1 summaries of the clone after expand = %d, want 2", len(level1))
+	}
+	if _, err := store.ExpandSummary(ctx, "s2", level1[0].ID); err != nil {
+		t.Fatalf("expanding a cloned summary: %v", err)
+	}
+
+	// Исходная сессия не изменилась
+	sourceLevel1, _ := store.GetActiveSummaries(ctx, "s1", 1)
+	sourceLevel2, _ := store.GetSummariesByLevel(ctx, "s1", 2)
+	sourceActive, _ := store.GetActiveMessages(ctx, "s1")
+	if len(sourceLevel1) != 0 || len(sourceLevel2) != 1 || len(sourceActive) != 2 {
+		t.Errorf("source changed: %d level 1, %d level 2, %d active messages", len(sourceLevel1), len(sourceLevel2), len(sourceActive))
+	}
+
+	if _, err := store.CloneSession(ctx, "missing", "s3"); !errors.Is(err, interfaces.ErrSessionNotFound) {
+		t.Errorf("cloning a missing session: err = %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestApplyCompressionFailureLeavesNoPartialState(t *testing.T) {
+	tests := []struct {
+		name        string
+		compression func(messages []models.Message) models.Compression
+		want        error
+	}{
+		{
+			// Сбой на последнем источнике: первые уже прошли проверку
+			name: "missing message",
+			compression: func(messages []models.Message) models.Compression {
+				return compressionOf("s1", "sum2", 1, append(messageIDs(messages[3:5]), "missing"), nil)
+			},
+			want: interfaces.ErrMessageNotFound,
+		},
+		{
+			// Сообщения в порядке, сбой на пометке резюме
+			name: "missing summary",
+			compression: func(messages []models.Message) models.Compression {
+				return compressionOf("s1", "sum2", 1, messageIDs(messages[3:5]), []string{"sum1", "missing"})
+			},
+			want: interfaces.ErrSummaryNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := New()
+			messages := seedMessages(t, store, "s1", 6)
+			if err := store.ApplyCompression(ctx, compressionOf("s1", "sum1", 1, messageIDs(messages[:3]), nil)); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := store.ApplyCompression(ctx, tt.compression(messages)); !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+
+			active, _ := store.GetActiveMessages(ctx, "s1")
+			if got := messageIDs(active); fmt.Sprint(got) != "[s1-m3 s1-m4 s1-m5]" {
+				t.Errorf("active messages = %v", got)
+			}
+			summaries, _ := store.GetActiveSummaries(ctx, "s1", 1)
+			if len(summaries) != 1 || summaries[0].ID != "sum1" || summaries[0].IsCompressed {
+				t.Errorf("summaries = %+v, want only sum1", summaries)
+			}
+			all, _, _ := store.GetMessagesPage(ctx, "s1", 100, 0, true)
+			if len(all) != 7 {
+				t.Errorf("messages = %d, want 6 and the summary message of sum1", len(all))
+			}
+		})
+	}
+}
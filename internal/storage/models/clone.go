@@ -0,0 +1,57 @@
+package models
+
+import "github.com/google/uuid"
+
+// ClonedContent сообщения и резюме сессии, перенесённые под новые ID
+type ClonedContent struct {
+	Messages  []Message
+	Summaries []Summary
+}
+
+// CloneSessionContent копирует сообщения и резюме в сессию targetID под новыми ID.
+// summary_id сообщений и резюме и границы покрытия резюме переназначаются на копии,
+// поэтому сжатое состояние копии ссылается только на её собственные записи.
+// Ссылка на резюме вне копируемого набора сбрасывается.
+func CloneSessionContent(targetID string, messages []Message, summaries []Summary) ClonedContent {
+	messageIDs := make(map[string]string, len(messages))
+	for _, msg := range messages {
+		messageIDs[msg.ID] = uuid.New().String()
+	}
+
+	summaryIDs := make(map[string]string, len(summaries))
+	for _, summary := range summaries {
+		summaryIDs[summary.ID] = uuid.New().String()
+	}
+
+	cloned := ClonedContent{
+		Messages:  make([]Message, 0, len(messages)),
+		Summaries: make([]Summary, 0, len(summaries)),
+	}
+
+	for _, summary := range summaries {
+		summary.ID = summaryIDs[summary.ID]
+		summary.SessionID = targetID
+		summary.SummaryID = summaryIDs[summary.SummaryID]
+		summary.CoversFromMessageID = remapID(messageIDs, summary.CoversFromMessageID)
+		summary.CoversToMessageID = remapID(messageIDs, summary.CoversToMessageID)
+		summary.Anchors = append([]string(nil), summary.Anchors...)
+		cloned.Summaries = append(cloned.Summaries, summary)
+	}
+
+	for _, msg := range messages {
+		msg.ID = messageIDs[msg.ID]
+		msg.SessionID = targetID
+		msg.SummaryID = summaryIDs[msg.SummaryID]
+		cloned.Messages = append(cloned.Messages, msg)
+	}
+
+	return cloned
+}
+
+// remapID возвращает новый ID сообщения или исходный, если сообщение не копировалось
+func remapID(ids map[string]string, id string) string {
+	if mapped, ok := ids[id]; ok {
+		return mapped
+	}
+	return id
+}
@@ -0,0 +1,69 @@
+package models
+
+import "testing"
+
+func TestCloneSessionContentRemapsReferences(t *testing.T) {
+	messages := []Message{
+		{ID: "m1", SessionID: "s", IsCompressed: true, SummaryID: "a"},
+		{ID: "m2", SessionID: "s", IsCompressed: true, SummaryID: "a"},
+		{ID: "m3", SessionID: "s"},
+	}
+	summaries := []Summary{
+		{ID: "a", SessionID: "s", SummaryLevel: 1, CoversFromMessageID: "m1", CoversToMessageID: "m2", IsCompressed: true, SummaryID: "b", Anchors: []string{"x"}},
+		{ID: "b", SessionID: "s", SummaryLevel: 2, CoversFromMessageID: "m1", CoversToMessageID: "m2"},
+		// Ссылка на резюме вне копируемого набора
+		{ID: "c", SessionID: "s", SummaryLevel: 1, SummaryID: "missing"},
+	}
+
+	cloned := CloneSessionContent("t", messages, summaries)
+	if len(cloned.Messages) != 3 || len(cloned.Summaries) != 3 {
+		t.Fatalf("cloned %d messages and %d summaries, want 3 and 3", len(cloned.Messages), len(cloned.Summaries))
+	}
+
+	messageIDs := map[string]bool{}
+	for _, msg := range cloned.Messages {
+		messageIDs[msg.ID] = true
+	}
+	summaryIDs := map[string]bool{}
+	for _, summary := range cloned.Summaries {
+		summaryIDs[summary.ID] = true
+	}
+
+	// Все ссылки копии указывают на её собственные записи
+	for _, msg := range cloned.Messages {
+		if msg.SessionID != "t" || msg.ID == "m1" || msg.ID == "m2" || msg.ID == "m3" {
+			t.Errorf("message was not moved to the clone: %+v", msg)
+		}
+		if msg.SummaryID != "" && !summaryIDs[msg.SummaryID] {
+			t.Errorf("message %s references summary %s outside the clone", msg.ID, msg.SummaryID)
+		}
+	}
+	for _, summary := range cloned.Summaries[:2] {
+		if summary.SessionID != "t" || !messageIDs[summary.CoversFromMessageID] || !messageIDs[summary.CoversToMessageID] {
+			t.Errorf("summary coverage is outside the clone: %+v", summary)
+		}
+		if summary.SummaryID != "" && !summaryIDs[summary.SummaryID] {
+			t.Errorf("summary %s references summary %s outside the clone", summary.ID, summary.SummaryID)
+		}
+	}
+
+	// Связи сжатия сохраняются
+	if cloned.Messages[0].SummaryID != cloned.Summaries[0].ID || cloned.Messages[1].SummaryID != cloned.Summaries[0].ID {
+		t.Error("compressed messages lost their summary")
+	}
+	if cloned.Messages[2].SummaryID != "" {
+		t.Errorf("active message got summary %q", cloned.Messages[2].SummaryID)
+	}
+	if cloned.Summaries[0].SummaryID != cloned.Summaries[1].ID {
+		t.Error("compressed summary lost its bulk summary")
+	}
+	if cloned.Summaries[2].SummaryID != "" {
+		t.Errorf("dangling reference was kept: %q", cloned.Summaries[2].SummaryID)
+	}
+
+	// Исходные записи не меняются, якоря не разделяются
+	cloned.Summaries[0].Anchors[0] = "y"
+	if messages[0].ID != "m1" || summaries[0].SummaryID != "b" || summaries[0].Anchors[0] != "x" {
+		t.Error("source content was mutated")
+	}
+}
@@ -9,7 +9,8 @@ type Message struct {
 	SessionID   string `json:"session_id"`
 	Role        string `json:"role"` // user, assistant, system, tool
 	Content     string `json:"content"`
-	MessageType string `json:"message_type"` // regular, summary, bulk_summary
+	MessageType string `json:"message_type"` // regular, summary, bulk_summary, session_digest
+	Status      string `json:"status"`       // pending, completed, failed
 
 	// Compression fields
 	IsCompressed bool   `json:"is_compressed"`
@@ -27,6 +28,79 @@ type Metadata struct {
 	Tokens int     `json:"tokens,omitempty"`
 	Cost   float64 `json:"cost,omitempty"`
 	Model  string  `json:"model,omitempty"`
+
+	// PromptTokens и CompletionTokens разбивка Tokens по всем итерациям цикла инструментов
+	PromptTokens     int `json:"prompt_tokens,omitempty"`
+	CompletionTokens int `json:"completion_tokens,omitempty"`
+
+	// FinishReason причина незавершённой генерации ("shutdown", "max_iterations", "max_tokens", "stop_sequence")
+	FinishReason string `json:"finish_reason,omitempty"`
+
+	// ToolIterations использованные итерации цикла вызова инструментов
+	ToolIterations int `json:"tool_iterations,omitempty"`
+
+	// Generation параметры воспроизводимой генерации (seed, deterministic), применённые провайдером
+	Generation *GenerationParams `json:"generation,omitempty"`
+
+	// Grounding источники поиска Google, на которые опирается ответ ассистента
+	Grounding *Grounding `json:"grounding,omitempty"`
+
+	// Redactions число фрагментов с персональными данными, заменённых токенами перед сохранением
+	Redactions int `json:"redactions,omitempty"`
+
+	// ToolCall аргументы и итог вызова MCP инструмента (только для сообщений с ролью tool)
+	ToolCall *ToolCallDetails `json:"tool_call,omitempty"`
+}
+
+// ToolCallDetails вызов MCP инструмента; результат хранится в тексте сообщения
+type ToolCallDetails struct {
+	Arguments  map[string]any `json:"arguments,omitempty"` // после маскирования чувствительных значений
+	Success    bool           `json:"success"`
+	Error      string         `json:"error,omitempty"`
+	DurationMs int64          `json:"duration_ms"`
+}
+
+// GenerationParams фактически применённые параметры генерации ответа
+type GenerationParams struct {
+	Seed            *int64              `json:"seed,omitempty"`
+	Temperature     *float32            `json:"temperature,omitempty"`
+	TopK            *int32              `json:"top_k,omitempty"`
+	MaxOutputTokens *int32              `json:"max_output_tokens,omitempty"`
+	Deterministic   bool                `json:"deterministic"`
+	Warnings        []GenerationWarning `json:"warnings,omitempty"`
+}
+
+// GenerationWarning предупреждение провайдера о неподдерживаемом параметре
+type GenerationWarning struct {
+	Code      string `json:"code"`
+	Parameter string `json:"parameter"`
+	Message   string `json:"message"`
+}
+
+// Grounding результаты поиска Google для ответа: запросы модели и найденные источники
+type Grounding struct {
+	SearchQueries []string          `json:"search_queries,omitempty"`
+	Sources       []GroundingSource `json:"sources,omitempty"`
+}
+
+// GroundingSource источник, на который ссылается ответ
+type GroundingSource struct {
+	URI   string `json:"uri"`
+	Title string `json:"title,omitempty"`
+}
+
+// MessageEmbedding вектор сообщения для семантического поиска по сжатой истории
+type MessageEmbedding struct {
+	MessageID string    `json:"message_id"`
+	SessionID string    `json:"session_id"`
+	Model     string    `json:"model"` // векторы разных моделей несравнимы
+	Vector    []float32 `json:"vector"`
+}
+
+// EmbeddedMessage сжатое сообщение вместе с его вектором
+type EmbeddedMessage struct {
+	Message Message   `json:"message"`
+	Vector  []float32 `json:"vector"`
 }
 
 type Summary struct {
@@ -35,7 +109,7 @@ type Summary struct {
 	SummaryText string   `json:"summary_text"`
 	Anchors     []string `json:"anchors"`
 
-	// Multi-level compression: 1 = regular summary, 2 = bulk summary
+	// Multi-level compression: 1 = regular summary, 2 = bulk summary, 3 = session digest
 	SummaryLevel int `json:"summary_level"`
 
 	// Coverage boundaries
@@ -51,13 +125,100 @@ type Summary struct {
 	UpdatedAt  time.Time `json:"updated_at"`
 }
 
+// Compression результат сжатия, который хранилище применяет целиком или не применяет совсем:
+// резюме, его сообщение в истории и пометка сжатых источников
+type Compression struct {
+	Summary        Summary
+	SummaryMessage Message
+	MessageIDs     []string // сжатые сообщения (резюме первого уровня или ручной диапазон)
+	SummaryIDs     []string // сжатые резюме первого уровня (bulk summary)
+}
+
+// SummarySources резюме вместе со сжатыми в него источниками: сообщениями (резюме первого уровня)
+// или резюме младших уровней (bulk резюме и дайджест сессии)
+type SummarySources struct {
+	Summary   Summary   `json:"summary"`
+	Messages  []Message `json:"messages"`
+	Summaries []Summary `json:"summaries"`
+}
+
+// SummaryExpansion результат развёртывания резюме обратно в источники
+type SummaryExpansion struct {
+	SummaryID         string `json:"summary_id"`
+	SummaryLevel      int    `json:"summary_level"`
+	MessagesRestored  int    `json:"messages_restored"`
+	SummariesRestored int    `json:"summaries_restored"`
+}
+
 type ChatSession struct {
 	ID           string    `json:"id"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 	MessageCount int       `json:"message_count"`
+	Language     string    `json:"language,omitempty"`    // пустое значение - язык из конфигурации
+	ClonedFrom   string    `json:"cloned_from,omitempty"` // исходная сессия, если сессия создана клонированием
+	UserID       string    `json:"user_id,omitempty"`     // пользователь, отправивший первое сообщение с user_id
+}
+
+// SessionCloneResult итог клонирования сессии
+type SessionCloneResult struct {
+	SessionID       string `json:"session_id"`
+	SourceSessionID string `json:"source_session_id"`
+	MessagesCopied  int    `json:"messages_copied"`
+	SummariesCopied int    `json:"summaries_copied"`
+}
+
+// UserPreferences настройки пользователя (хранятся в виде JSONB-блоба)
+type UserPreferences struct {
+	UserID    string      `json:"user_id"`
+	Prefs     Preferences `json:"prefs"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// Preferences содержимое блоба настроек пользователя
+type Preferences struct {
+	// DefaultModel модель по умолчанию для запросов пользователя
+	DefaultModel string `json:"default_model,omitempty"`
+}
+
+// ToolInvocation запись журнала аудита вызовов MCP инструментов.
+// Хранится независимо от сообщений и не удаляется вместе с сессией.
+type ToolInvocation struct {
+	ID         string         `json:"id"`
+	SessionID  string         `json:"session_id,omitempty"`
+	UserID     string         `json:"user_id,omitempty"`
+	ToolName   string         `json:"tool_name"`
+	Arguments  map[string]any `json:"arguments,omitempty"` // после маскирования чувствительных значений
+	ResultHash string         `json:"result_hash,omitempty"`
+	Success    bool           `json:"success"`
+	Error      string         `json:"error,omitempty"`
+	DurationMs int64          `json:"duration_ms"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// ToolInvocationFilter условия выборки журнала аудита; пустые поля не ограничивают выборку
+type ToolInvocationFilter struct {
+	SessionID string
+	ToolName  string
+	From      time.Time
+	To        time.Time
 }
 
+// SessionFilter условия выборки сессий для обслуживания; пустые поля не ограничивают выборку
+type SessionFilter struct {
+	IDs           []string  // выборка только из перечисленных сессий
+	UpdatedBefore time.Time // последняя активность в сессии раньше указанного момента
+	UserID        string
+}
+
+// Статусы сообщения: сообщение пользователя ожидает ответа (pending) до сохранения
+// ответа ассистента (completed) или ошибки генерации (failed)
+const (
+	MessageStatusPending   = "pending"
+	MessageStatusCompleted = "completed"
+	MessageStatusFailed    = "failed"
+)
+
 // Helper methods for Message
 func (m *Message) IsRegular() bool {
 	return m.MessageType == "regular"
@@ -71,6 +232,14 @@ func (m *Message) IsBulkSummary() bool {
 	return m.MessageType == "bulk_summary"
 }
 
+func (m *Message) IsSessionDigest() bool {
+	return m.MessageType == "session_digest"
+}
+
+func (m *Message) IsFailed() bool {
+	return m.Status == MessageStatusFailed
+}
+
 func (m *Message) IsToolCall() bool {
 	return m.Role == "tool" && m.ToolName != ""
 }
@@ -84,6 +253,10 @@ func (s *Summary) IsBulkSummary() bool {
 	return s.SummaryLevel == 2
 }
 
+func (s *Summary) IsSessionDigest() bool {
+	return s.SummaryLevel == 3
+}
+
 // Factory functions for creating messages
 func NewUserMessage(sessionID, content string) Message {
 	return Message{
@@ -109,8 +282,11 @@ func NewAssistantMessage(sessionID, content string) Message {
 
 func NewSummaryMessage(sessionID, content string, summaryLevel int) Message {
 	messageType := "summary"
-	if summaryLevel == 2 {
+	switch summaryLevel {
+	case 2:
 		messageType = "bulk_summary"
+	case 3:
+		messageType = "session_digest"
 	}
 
 	return Message{
@@ -156,3 +332,13 @@ func NewBulkSummary(sessionID, summaryText string, anchors []string) Summary {
 		UpdatedAt:    time.Now(),
 	}
 }
+
+// StorageHealth результат проверки хранилища (ping + статистика пула соединений)
+type StorageHealth struct {
+	OpenConnections    int           `json:"open_connections"`
+	InUse              int           `json:"in_use"`
+	Idle               int           `json:"idle"`
+	MaxOpenConnections int           `json:"max_open_connections"`
+	WaitCount          int64         `json:"wait_count"`
+	WaitDuration       time.Duration `json:"wait_duration"`
+}
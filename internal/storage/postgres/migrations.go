@@ -371,4 +371,122 @@ COMMENT ON TABLE summaries IS 'Multi-level summaries: level 1 (regular) and leve
 COMMENT ON COLUMN summaries.summary_level IS '1 = regular summary, 2 = bulk summary of summaries';
 COMMENT ON COLUMN summaries.covers_from_message_id IS 'First message ID covered by this summary';
 COMMENT ON COLUMN summaries.covers_to_message_id IS 'Last message ID covered by this summary';`,
+
+	// Migration 002: User preferences
+	`-- Migration: 002_user_preferences.sql
+-- Per-user preferences stored as a JSONB blob
+
+CREATE TABLE user_preferences (
+    user_id VARCHAR(255) PRIMARY KEY,
+    prefs JSONB NOT NULL DEFAULT '{}'::jsonb,
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+COMMENT ON TABLE user_preferences IS 'Per-user preferences (default model etc.)';
+COMMENT ON COLUMN user_preferences.prefs IS 'Preferences blob, e.g. {"default_model": "gemini-2.0-flash"}';`,
+
+	// Migration 003: Session language
+	`-- Migration: 003_session_language.sql
+-- Per-session response language override
+
+ALTER TABLE chat_sessions ADD COLUMN language VARCHAR(16) NULL;
+
+COMMENT ON COLUMN chat_sessions.language IS 'Response language: auto or language code; NULL uses chat.language from config';`,
+
+	// Migration 004: Message status
+	`-- Migration: 004_message_status.sql
+-- Message lifecycle: user messages are pending until the answer is stored or generation fails
+
+ALTER TABLE messages ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'completed';
+
+CREATE INDEX idx_messages_pending ON messages(created_at) WHERE status = 'pending';
+
+COMMENT ON COLUMN messages.status IS 'pending (awaiting answer), completed, failed (generation error)';`,
+
+	// Migration 005: Tool invocation audit log
+	`-- Migration: 005_tool_invocations.sql
+-- Audit log of executed MCP tools; no foreign keys so records survive session deletion
+
+CREATE TABLE tool_invocations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    session_id VARCHAR(100) NULL,
+    user_id VARCHAR(255) NULL,
+    tool_name VARCHAR(100) NOT NULL,
+    arguments JSONB NOT NULL DEFAULT '{}',
+    result_hash VARCHAR(64) NULL,
+    success BOOLEAN NOT NULL,
+    error TEXT NULL,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_tool_invocations_created_at ON tool_invocations(created_at DESC);
+CREATE INDEX idx_tool_invocations_session ON tool_invocations(session_id, created_at DESC);
+CREATE INDEX idx_tool_invocations_tool ON tool_invocations(tool_name, created_at DESC);
+
+COMMENT ON TABLE tool_invocations IS 'Audit log of MCP tool calls, independent of chat messages';
+COMMENT ON COLUMN tool_invocations.arguments IS 'Tool arguments after redaction of sensitive values';
+COMMENT ON COLUMN tool_invocations.result_hash IS 'SHA-256 of the JSON tool result';`,
+
+	// Migration 006: Session clone source
+	`-- Migration: 006_session_cloned_from.sql
+-- Sessions created by POST /chat/:session_id/clone remember their source session
+
+ALTER TABLE chat_sessions ADD COLUMN cloned_from VARCHAR(100) NULL;
+
+COMMENT ON COLUMN chat_sessions.cloned_from IS 'Source session ID for cloned sessions; not a foreign key so clones outlive their source';`,
+
+	// Migration 007: Session owner
+	`-- Migration: 007_session_user_id.sql
+-- Owner of the session for per-user maintenance (bulk deletion by user)
+
+ALTER TABLE chat_sessions ADD COLUMN user_id VARCHAR(255) NULL;
+
+CREATE INDEX idx_chat_sessions_user_id ON chat_sessions(user_id);
+
+COMMENT ON COLUMN chat_sessions.user_id IS 'user_id of the first request that referenced the session; NULL for anonymous sessions';`,
+
+	// Migration 008: Purge of compressed messages
+	`-- Migration: 008_purge_compressed_messages.sql
+-- With chat.purge_compressed_after set, compressed messages are deleted after a grace period
+-- and summaries remain the only long-term record
+
+ALTER TABLE messages ADD COLUMN compressed_at TIMESTAMP NULL;
+
+CREATE INDEX idx_messages_purge ON messages(compressed_at) WHERE is_compressed = true;
+
+COMMENT ON COLUMN messages.compressed_at IS 'When the message was folded into a summary; NULL for messages compressed before this migration (created_at is used instead)';
+COMMENT ON COLUMN summaries.covers_from_message_id IS 'First message ID covered by this summary; the message may have been purged';
+COMMENT ON COLUMN summaries.covers_to_message_id IS 'Last message ID covered by this summary; the message may have been purged';`,
+
+	// Migration 009: Message embeddings
+	`-- Migration: 009_message_embeddings.sql
+-- Embeddings of regular messages for semantic recall of compressed history (chat.recall)
+
+CREATE TABLE message_embeddings (
+    message_id UUID PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE,
+    session_id VARCHAR(100) NOT NULL,
+    model VARCHAR(100) NOT NULL,
+    embedding REAL[] NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_message_embeddings_session ON message_embeddings(session_id, model);
+
+COMMENT ON TABLE message_embeddings IS 'One embedding per message; messages saved before chat.recall was enabled have none';
+COMMENT ON COLUMN message_embeddings.model IS 'Embedding model; vectors of different models are not compared';`,
+
+	// Migration 010: Session digests
+	`-- Migration: 010_session_digest.sql
+-- Level 3 compression: the oldest bulk summaries are folded into a rolling session digest
+
+ALTER TABLE summaries DROP CONSTRAINT IF EXISTS summaries_summary_level_check;
+ALTER TABLE summaries ADD CONSTRAINT summaries_summary_level_check CHECK (summary_level IN (1, 2, 3));
+
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_message_type_check;
+ALTER TABLE messages ADD CONSTRAINT messages_message_type_check
+    CHECK (message_type IN ('regular', 'summary', 'bulk_summary', 'session_digest'));
+
+COMMENT ON COLUMN messages.message_type IS 'Type: regular (normal messages), summary (level 1), bulk_summary (level 2), session_digest (level 3)';
+COMMENT ON COLUMN summaries.summary_level IS '1 = regular summary, 2 = bulk summary of summaries, 3 = session digest of bulk summaries';`,
 }
@@ -4,7 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"LLM_Chat/internal/storage/interfaces"
@@ -20,6 +22,12 @@ type PostgresStorage struct {
 	logger *zap.Logger
 }
 
+// execer общие для *sql.DB и *sql.Tx методы: запросы выполняются и вне транзакции, и в ней
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
 func New(databaseURL string, logger *zap.Logger) (*PostgresStorage, error) {
 	db, err := sql.Open("postgres", databaseURL)
 	if err != nil {
@@ -51,18 +59,53 @@ func (s *PostgresStorage) GetDB() *sql.DB {
 	return s.db
 }
 
+// HealthCheck проверяет соединение с БД и возвращает статистику пула
+func (s *PostgresStorage) HealthCheck(ctx context.Context) (*models.StorageHealth, error) {
+	if err := s.db.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	stats := s.db.Stats()
+	return &models.StorageHealth{
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		MaxOpenConnections: stats.MaxOpenConnections,
+		WaitCount:          stats.WaitCount,
+		WaitDuration:       stats.WaitDuration,
+	}, nil
+}
+
 // MessageStore implementation
 func (s *PostgresStorage) SaveMessage(ctx context.Context, msg models.Message) error {
+	if err := s.saveMessage(ctx, s.db, msg); err != nil {
+		return err
+	}
+
+	s.logger.Debug("Message saved",
+		zap.String("message_id", msg.ID),
+		zap.String("session_id", msg.SessionID),
+		zap.String("message_type", msg.MessageType))
+
+	return nil
+}
+
+func (s *PostgresStorage) saveMessage(ctx context.Context, exec execer, msg models.Message) error {
 	query := `
-		INSERT INTO messages (id, session_id, role, content, message_type, is_compressed, 
+		INSERT INTO messages (id, session_id, role, content, message_type, status, is_compressed, 
 		                     summary_id, tool_name, tool_call_id, created_at, metadata)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
 
 	metadataJSON, err := json.Marshal(msg.Metadata)
 	if err != nil {
 		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
+	status := msg.Status
+	if status == "" {
+		status = models.MessageStatusCompleted
+	}
+
 	var summaryID *string
 	if msg.SummaryID != "" {
 		summaryID = &msg.SummaryID
@@ -76,28 +119,22 @@ func (s *PostgresStorage) SaveMessage(ctx context.Context, msg models.Message) e
 		toolCallID = &msg.ToolCallID
 	}
 
-	_, err = s.db.ExecContext(ctx, query,
-		msg.ID, msg.SessionID, msg.Role, msg.Content, msg.MessageType,
+	_, err = exec.ExecContext(ctx, query,
+		msg.ID, msg.SessionID, msg.Role, msg.Content, msg.MessageType, status,
 		msg.IsCompressed, summaryID, toolName, toolCallID, msg.Timestamp, metadataJSON)
 
 	if err != nil {
 		return fmt.Errorf("failed to save message: %w", err)
 	}
-
-	s.logger.Debug("Message saved",
-		zap.String("message_id", msg.ID),
-		zap.String("session_id", msg.SessionID),
-		zap.String("message_type", msg.MessageType))
-
 	return nil
 }
 
 func (s *PostgresStorage) GetMessages(ctx context.Context, sessionID string, limit int) ([]models.Message, error) {
 	query := `
-		SELECT id, session_id, role, content, message_type, is_compressed, 
+		SELECT id, session_id, role, content, message_type, status, is_compressed, 
 		       summary_id, tool_name, tool_call_id, created_at, metadata
 		FROM messages 
-		WHERE session_id = $1 
+		WHERE session_id = $1 AND role <> 'tool'
 		ORDER BY created_at ASC
 		LIMIT $2`
 
@@ -110,12 +147,98 @@ func (s *PostgresStorage) GetMessages(ctx context.Context, sessionID string, lim
 	return s.scanMessages(rows)
 }
 
+// GetMessagesPage возвращает страницу сообщений сессии и общее количество сообщений;
+// сообщения вызовов инструментов входят в выборку только с includeTools
+func (s *PostgresStorage) GetMessagesPage(ctx context.Context, sessionID string, limit, offset int, includeTools bool) ([]models.Message, int, error) {
+	condition := `session_id = $1`
+	if !includeTools {
+		condition += ` AND role <> 'tool'`
+	}
+
+	var total int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM messages WHERE `+condition, sessionID).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count messages: %w", err)
+	}
+
+	if total == 0 || offset >= total {
+		return []models.Message{}, total, nil
+	}
+
+	query := `
+		SELECT id, session_id, role, content, message_type, status, is_compressed, 
+		       summary_id, tool_name, tool_call_id, created_at, metadata
+		FROM messages 
+		WHERE ` + condition + `
+		ORDER BY created_at ASC, id ASC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := s.db.QueryContext(ctx, query, sessionID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query messages page: %w", err)
+	}
+	defer rows.Close()
+
+	messages, err := s.scanMessages(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return messages, total, nil
+}
+
+// GetMessagesInRange возвращает обычные сообщения сессии между двумя сообщениями включительно
+func (s *PostgresStorage) GetMessagesInRange(ctx context.Context, sessionID, fromMessageID, toMessageID string) ([]models.Message, error) {
+	fromAt, err := s.getMessageCreatedAt(ctx, sessionID, fromMessageID)
+	if err != nil {
+		return nil, err
+	}
+
+	toAt, err := s.getMessageCreatedAt(ctx, sessionID, toMessageID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, session_id, role, content, message_type, status, is_compressed, 
+		       summary_id, tool_name, tool_call_id, created_at, metadata
+		FROM messages 
+		WHERE session_id = $1 AND message_type = 'regular' AND role <> 'tool'
+		  AND created_at BETWEEN $2 AND $3
+		ORDER BY created_at ASC`
+
+	rows, err := s.db.QueryContext(ctx, query, sessionID, fromAt, toAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages in range: %w", err)
+	}
+	defer rows.Close()
+
+	return s.scanMessages(rows)
+}
+
+func (s *PostgresStorage) getMessageCreatedAt(ctx context.Context, sessionID, messageID string) (time.Time, error) {
+	var createdAt time.Time
+	err := s.db.QueryRowContext(ctx,
+		`SELECT created_at FROM messages WHERE id = $1 AND session_id = $2`,
+		messageID, sessionID,
+	).Scan(&createdAt)
+
+	if err == sql.ErrNoRows {
+		return time.Time{}, fmt.Errorf("%w: %s", interfaces.ErrMessageNotFound, messageID)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get message: %w", err)
+	}
+
+	return createdAt, nil
+}
+
 func (s *PostgresStorage) GetMessagesForUI(ctx context.Context, sessionID string) ([]models.Message, error) {
 	query := `
-		SELECT id, session_id, role, content, message_type, is_compressed, 
+		SELECT id, session_id, role, content, message_type, status, is_compressed, 
 		       summary_id, tool_name, tool_call_id, created_at, metadata
 		FROM messages 
-		WHERE session_id = $1 AND message_type = 'regular'
+		WHERE session_id = $1 AND message_type = 'regular' AND role <> 'tool'
 		ORDER BY created_at ASC`
 
 	rows, err := s.db.QueryContext(ctx, query, sessionID)
@@ -129,10 +252,10 @@ func (s *PostgresStorage) GetMessagesForUI(ctx context.Context, sessionID string
 
 func (s *PostgresStorage) GetActiveMessages(ctx context.Context, sessionID string) ([]models.Message, error) {
 	query := `
-		SELECT id, session_id, role, content, message_type, is_compressed, 
+		SELECT id, session_id, role, content, message_type, status, is_compressed, 
 		       summary_id, tool_name, tool_call_id, created_at, metadata
 		FROM messages 
-		WHERE session_id = $1 AND message_type = 'regular' AND is_compressed = false
+		WHERE session_id = $1 AND message_type = 'regular' AND role <> 'tool' AND is_compressed = false
 		ORDER BY created_at ASC`
 
 	rows, err := s.db.QueryContext(ctx, query, sessionID)
@@ -144,8 +267,27 @@ func (s *PostgresStorage) GetActiveMessages(ctx context.Context, sessionID strin
 	return s.scanMessages(rows)
 }
 
+// GetActiveToolMessages возвращает несжатые сообщения инструментов сессии между from и to включительно
+func (s *PostgresStorage) GetActiveToolMessages(ctx context.Context, sessionID string, from, to time.Time) ([]models.Message, error) {
+	query := `
+		SELECT id, session_id, role, content, message_type, status, is_compressed, 
+		       summary_id, tool_name, tool_call_id, created_at, metadata
+		FROM messages 
+		WHERE session_id = $1 AND message_type = 'regular' AND role = 'tool' AND is_compressed = false
+		  AND created_at BETWEEN $2 AND $3
+		ORDER BY created_at ASC`
+
+	rows, err := s.db.QueryContext(ctx, query, sessionID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query active tool messages: %w", err)
+	}
+	defer rows.Close()
+
+	return s.scanMessages(rows)
+}
+
 func (s *PostgresStorage) GetMessageCount(ctx context.Context, sessionID string) (int, error) {
-	query := `SELECT COUNT(*) FROM messages WHERE session_id = $1 AND message_type = 'regular'`
+	query := `SELECT COUNT(*) FROM messages WHERE session_id = $1 AND message_type = 'regular' AND role <> 'tool'`
 
 	var count int
 	err := s.db.QueryRowContext(ctx, query, sessionID).Scan(&count)
@@ -172,7 +314,7 @@ func (s *PostgresStorage) MarkMessagesAsCompressed(ctx context.Context, messageI
 		return nil
 	}
 
-	query := `UPDATE messages SET is_compressed = true, summary_id = $1 WHERE id = ANY($2)`
+	query := `UPDATE messages SET is_compressed = true, summary_id = $1, compressed_at = NOW() WHERE id = ANY($2)`
 
 	_, err := s.db.ExecContext(ctx, query, summaryID, pq.Array(messageIDs))
 	if err != nil {
@@ -186,6 +328,150 @@ func (s *PostgresStorage) MarkMessagesAsCompressed(ctx context.Context, messageI
 	return nil
 }
 
+// UpdateMessageStatus меняет статус сообщения
+func (s *PostgresStorage) UpdateMessageStatus(ctx context.Context, messageID, status string) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE messages SET status = $1 WHERE id = $2`, status, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to update message status: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w: %s", interfaces.ErrMessageNotFound, messageID)
+	}
+
+	s.logger.Debug("Message status updated",
+		zap.String("message_id", messageID),
+		zap.String("status", status))
+	return nil
+}
+
+// FailStalePendingMessages переводит зависшие pending сообщения в failed
+func (s *PostgresStorage) FailStalePendingMessages(ctx context.Context, olderThan time.Time) (int, error) {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE messages SET status = $1 WHERE status = $2 AND created_at < $3`,
+		models.MessageStatusFailed, models.MessageStatusPending, olderThan)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail stale pending messages: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return int(rowsAffected), nil
+}
+
+// PurgeCompressedMessages удаляет до limit сжатых сообщений, сжатых раньше olderThan,
+// и возвращает количество удалённых по сессиям. Строки, заблокированные другими
+// транзакциями, пропускаются до следующего прохода.
+func (s *PostgresStorage) PurgeCompressedMessages(ctx context.Context, olderThan time.Time, limit int) (map[string]int, error) {
+	query := `
+		WITH purged AS (
+			SELECT id FROM messages
+			WHERE is_compressed = true AND message_type = 'regular'
+			  AND COALESCE(compressed_at, created_at) < $1
+			ORDER BY created_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		DELETE FROM messages m USING purged
+		WHERE m.id = purged.id
+		RETURNING m.session_id`
+
+	rows, err := s.db.QueryContext(ctx, query, olderThan, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to purge compressed messages: %w", err)
+	}
+	defer rows.Close()
+
+	purged := make(map[string]int)
+	for rows.Next() {
+		var sessionID string
+		if err := rows.Scan(&sessionID); err != nil {
+			return nil, fmt.Errorf("failed to scan purged message: %w", err)
+		}
+		purged[sessionID]++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate purged messages: %w", err)
+	}
+
+	return purged, nil
+}
+
+// EmbeddingStore implementation
+
+// SaveMessageEmbeddings сохраняет векторы одной транзакцией; сообщения, удалённые
+// до сохранения вектора (очистка, удаление сессии), пропускаются без ошибки
+func (s *PostgresStorage) SaveMessageEmbeddings(ctx context.Context, embeddings []models.MessageEmbedding) error {
+	if len(embeddings) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO message_embeddings (message_id, session_id, model, embedding)
+		SELECT $1, $2, $3, $4 WHERE EXISTS (SELECT 1 FROM messages WHERE id = $1)
+		ON CONFLICT (message_id) DO UPDATE SET
+			model = EXCLUDED.model,
+			embedding = EXCLUDED.embedding,
+			created_at = NOW()`
+
+	for _, embedding := range embeddings {
+		if _, err := tx.ExecContext(ctx, query,
+			embedding.MessageID, embedding.SessionID, embedding.Model, pq.Array(embedding.Vector),
+		); err != nil {
+			return fmt.Errorf("failed to save embedding of message %s: %w", embedding.MessageID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit message embeddings: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) GetCompressedMessageEmbeddings(ctx context.Context, sessionID, model string) ([]models.EmbeddedMessage, error) {
+	query := `
+		SELECT m.id, m.role, m.content, m.created_at, e.embedding
+		FROM message_embeddings e
+		JOIN messages m ON m.id = e.message_id
+		WHERE e.session_id = $1 AND e.model = $2
+		  AND m.is_compressed = true AND m.message_type = 'regular'
+		ORDER BY m.created_at ASC`
+
+	rows, err := s.db.QueryContext(ctx, query, sessionID, model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query message embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.EmbeddedMessage
+	for rows.Next() {
+		msg := models.Message{SessionID: sessionID, MessageType: "regular", IsCompressed: true}
+		var vector pq.Float32Array
+		if err := rows.Scan(&msg.ID, &msg.Role, &msg.Content, &msg.Timestamp, &vector); err != nil {
+			return nil, fmt.Errorf("failed to scan message embedding: %w", err)
+		}
+		result = append(result, models.EmbeddedMessage{Message: msg, Vector: vector})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate message embeddings: %w", err)
+	}
+
+	return result, nil
+}
+
 // SummaryStore implementation
 func (s *PostgresStorage) GetSummary(ctx context.Context, sessionID string) (*models.Summary, error) {
 	query := `
@@ -238,6 +524,19 @@ func (s *PostgresStorage) GetActiveSummaries(ctx context.Context, sessionID stri
 }
 
 func (s *PostgresStorage) SaveSummary(ctx context.Context, summary models.Summary) error {
+	if err := s.saveSummary(ctx, s.db, summary); err != nil {
+		return err
+	}
+
+	s.logger.Debug("Summary saved",
+		zap.String("summary_id", summary.ID),
+		zap.String("session_id", summary.SessionID),
+		zap.Int("summary_level", summary.SummaryLevel))
+
+	return nil
+}
+
+func (s *PostgresStorage) saveSummary(ctx context.Context, exec execer, summary models.Summary) error {
 	query := `
 		INSERT INTO summaries (id, session_id, summary_text, anchors, summary_level,
 		                      covers_from_message_id, covers_to_message_id, message_count,
@@ -254,7 +553,7 @@ func (s *PostgresStorage) SaveSummary(ctx context.Context, summary models.Summar
 		summaryID = &summary.SummaryID
 	}
 
-	_, err = s.db.ExecContext(ctx, query,
+	_, err = exec.ExecContext(ctx, query,
 		summary.ID, summary.SessionID, summary.SummaryText, anchorsJSON, summary.SummaryLevel,
 		summary.CoversFromMessageID, summary.CoversToMessageID, summary.MessageCount,
 		summary.IsCompressed, summaryID, summary.TokensUsed, summary.UpdatedAt)
@@ -262,12 +561,6 @@ func (s *PostgresStorage) SaveSummary(ctx context.Context, summary models.Summar
 	if err != nil {
 		return fmt.Errorf("failed to save summary: %w", err)
 	}
-
-	s.logger.Debug("Summary saved",
-		zap.String("summary_id", summary.ID),
-		zap.String("session_id", summary.SessionID),
-		zap.Int("summary_level", summary.SummaryLevel))
-
 	return nil
 }
 
@@ -299,6 +592,224 @@ func (s *PostgresStorage) MarkSummariesAsCompressed(ctx context.Context, summary
 	return nil
 }
 
+// ApplyCompression сохраняет резюме, его сообщение и пометки источников одной транзакцией.
+// Источники помечаются только если ещё не сжаты: если параллельное сжатие успело раньше,
+// транзакция откатывается с ErrAlreadyCompressed.
+func (s *PostgresStorage) ApplyCompression(ctx context.Context, compression models.Compression) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	summary := compression.Summary
+	if err := s.saveSummary(ctx, tx, summary); err != nil {
+		return err
+	}
+	// Сообщение резюме ссылается на своё резюме, чтобы ExpandSummary мог удалить его вместе с ним
+	summaryMessage := compression.SummaryMessage
+	summaryMessage.SummaryID = summary.ID
+	if err := s.saveMessage(ctx, tx, summaryMessage); err != nil {
+		return err
+	}
+
+	if len(compression.MessageIDs) > 0 {
+		result, err := tx.ExecContext(ctx,
+			`UPDATE messages SET is_compressed = true, summary_id = $1, compressed_at = NOW()
+			 WHERE id = ANY($2) AND is_compressed = false`,
+			summary.ID, pq.Array(compression.MessageIDs))
+		if err != nil {
+			return fmt.Errorf("failed to mark messages as compressed: %w", err)
+		}
+		if err := checkAllMarked(result, len(compression.MessageIDs), "messages"); err != nil {
+			return err
+		}
+	}
+
+	if len(compression.SummaryIDs) > 0 {
+		result, err := tx.ExecContext(ctx,
+			`UPDATE summaries SET is_compressed = true, summary_id = $1
+			 WHERE id = ANY($2) AND is_compressed = false`,
+			summary.ID, pq.Array(compression.SummaryIDs))
+		if err != nil {
+			return fmt.Errorf("failed to mark summaries as compressed: %w", err)
+		}
+		if err := checkAllMarked(result, len(compression.SummaryIDs), "summaries"); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit compression: %w", err)
+	}
+
+	s.logger.Debug("Compression applied",
+		zap.String("summary_id", summary.ID),
+		zap.String("session_id", summary.SessionID),
+		zap.Int("summary_level", summary.SummaryLevel),
+		zap.Int("messages_compressed", len(compression.MessageIDs)),
+		zap.Int("summaries_compressed", len(compression.SummaryIDs)))
+
+	return nil
+}
+
+// checkAllMarked проверяет, что пометку сжатия получили все источники
+func checkAllMarked(result sql.Result, expected int, kind string) error {
+	marked, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if int(marked) != expected {
+		return fmt.Errorf("%w: %d of %d %s", interfaces.ErrAlreadyCompressed, expected-int(marked), expected, kind)
+	}
+	return nil
+}
+
+// GetSummarySources возвращает резюме и сжатые в него сообщения или резюме младших уровней
+func (s *PostgresStorage) GetSummarySources(ctx context.Context, sessionID, summaryID string) (*models.SummarySources, error) {
+	summary, err := s.getSessionSummary(ctx, s.db, sessionID, summaryID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := &models.SummarySources{Summary: *summary}
+	if summary.SummaryLevel == 1 {
+		rows, err := s.db.QueryContext(ctx, `
+			SELECT id, session_id, role, content, message_type, status, is_compressed, 
+			       summary_id, tool_name, tool_call_id, created_at, metadata
+			FROM messages 
+			WHERE summary_id = $1 AND message_type = 'regular'
+			ORDER BY created_at ASC`, summaryID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query summary messages: %w", err)
+		}
+		defer rows.Close()
+
+		if sources.Messages, err = s.scanMessages(rows); err != nil {
+			return nil, err
+		}
+		return sources, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, session_id, summary_text, anchors, summary_level, 
+		       covers_from_message_id, covers_to_message_id, message_count,
+		       is_compressed, summary_id, tokens_used, created_at
+		FROM summaries 
+		WHERE summary_id = $1
+		ORDER BY created_at ASC`, summaryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query compressed summaries: %w", err)
+	}
+	defer rows.Close()
+
+	if sources.Summaries, err = s.scanSummaries(rows); err != nil {
+		return nil, err
+	}
+	return sources, nil
+}
+
+// ExpandSummary развёртывает резюме одной транзакцией: снимает пометку сжатия с источников,
+// удаляет сообщение резюме и само резюме. Строка резюме блокируется, чтобы параллельное
+// сжатие второго уровня не успело сжать его между проверкой и удалением.
+func (s *PostgresStorage) ExpandSummary(ctx context.Context, sessionID, summaryID string) (*models.SummaryExpansion, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	summary, err := s.getSessionSummary(ctx, tx, sessionID, summaryID, true)
+	if err != nil {
+		return nil, err
+	}
+	if summary.IsCompressed {
+		return nil, fmt.Errorf("%w: %s is covered by %s", interfaces.ErrSummaryCompressed, summaryID, summary.SummaryID)
+	}
+
+	expansion := &models.SummaryExpansion{
+		SummaryID:    summary.ID,
+		SummaryLevel: summary.SummaryLevel,
+	}
+
+	if summary.SummaryLevel == 1 {
+		result, err := tx.ExecContext(ctx,
+			`UPDATE messages SET is_compressed = false, summary_id = NULL, compressed_at = NULL
+			 WHERE summary_id = $1 AND message_type = 'regular'`,
+			summaryID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore compressed messages: %w", err)
+		}
+		restored, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if int(restored) < summary.MessageCount {
+			return nil, fmt.Errorf("%w: %d of %d messages of summary %s remain",
+				interfaces.ErrSourcesPurged, restored, summary.MessageCount, summaryID)
+		}
+		expansion.MessagesRestored = int(restored)
+	} else {
+		result, err := tx.ExecContext(ctx,
+			`UPDATE summaries SET is_compressed = false, summary_id = NULL WHERE summary_id = $1`,
+			summaryID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore compressed summaries: %w", err)
+		}
+		restored, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		expansion.SummariesRestored = int(restored)
+	}
+
+	// Сообщения резюме, сохранённые до появления ссылки на резюме, находятся по тексту
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM messages
+		WHERE session_id = $1 AND message_type IN ('summary', 'bulk_summary', 'session_digest')
+		  AND (summary_id = $2 OR (summary_id IS NULL AND content = $3))`,
+		sessionID, summaryID, summary.SummaryText)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete summary message: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM summaries WHERE id = $1`, summaryID); err != nil {
+		return nil, fmt.Errorf("failed to delete summary: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit summary expansion: %w", err)
+	}
+
+	s.logger.Debug("Summary expanded",
+		zap.String("summary_id", summaryID),
+		zap.String("session_id", sessionID),
+		zap.Int("summary_level", summary.SummaryLevel),
+		zap.Int("messages_restored", expansion.MessagesRestored),
+		zap.Int("summaries_restored", expansion.SummariesRestored))
+
+	return expansion, nil
+}
+
+// getSessionSummary читает резюме сессии по ID; forUpdate блокирует строку до конца транзакции
+func (s *PostgresStorage) getSessionSummary(ctx context.Context, exec execer, sessionID, summaryID string, forUpdate bool) (*models.Summary, error) {
+	query := `
+		SELECT id, session_id, summary_text, anchors, summary_level, 
+		       covers_from_message_id, covers_to_message_id, message_count,
+		       is_compressed, summary_id, tokens_used, created_at
+		FROM summaries 
+		WHERE id = $1 AND session_id = $2`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+
+	summary, err := s.scanSummary(exec.QueryRowContext(ctx, query, summaryID, sessionID))
+	if errors.Is(err, interfaces.ErrSummaryNotFound) {
+		return nil, fmt.Errorf("%w: %s", interfaces.ErrSummaryNotFound, summaryID)
+	}
+	return summary, err
+}
+
 // SessionStore implementation
 func (s *PostgresStorage) CreateSession(ctx context.Context, sessionID string) error {
 	query := `INSERT INTO chat_sessions (id, created_at, updated_at, message_count) VALUES ($1, NOW(), NOW(), 0)`
@@ -317,11 +828,12 @@ func (s *PostgresStorage) CreateSession(ctx context.Context, sessionID string) e
 }
 
 func (s *PostgresStorage) GetSession(ctx context.Context, sessionID string) (*models.ChatSession, error) {
-	query := `SELECT id, created_at, updated_at, message_count FROM chat_sessions WHERE id = $1`
+	query := `SELECT id, created_at, updated_at, message_count, language, cloned_from, user_id FROM chat_sessions WHERE id = $1`
 
 	var session models.ChatSession
+	var language, clonedFrom, userID sql.NullString
 	err := s.db.QueryRowContext(ctx, query, sessionID).Scan(
-		&session.ID, &session.CreatedAt, &session.UpdatedAt, &session.MessageCount)
+		&session.ID, &session.CreatedAt, &session.UpdatedAt, &session.MessageCount, &language, &clonedFrom, &userID)
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("session not found: %s", sessionID)
@@ -329,6 +841,9 @@ func (s *PostgresStorage) GetSession(ctx context.Context, sessionID string) (*mo
 	if err != nil {
 		return nil, fmt.Errorf("failed to get session: %w", err)
 	}
+	session.Language = language.String
+	session.ClonedFrom = clonedFrom.String
+	session.UserID = userID.String
 
 	return &session, nil
 }
@@ -353,6 +868,449 @@ func (s *PostgresStorage) UpdateSession(ctx context.Context, sessionID string) e
 	return nil
 }
 
+func (s *PostgresStorage) SetSessionLanguage(ctx context.Context, sessionID, language string) error {
+	query := `
+		INSERT INTO chat_sessions (id, created_at, updated_at, message_count, language)
+		VALUES ($1, NOW(), NOW(), 0, $2)
+		ON CONFLICT (id) DO UPDATE SET language = EXCLUDED.language, updated_at = NOW()`
+
+	var lang *string
+	if language != "" {
+		lang = &language
+	}
+
+	if _, err := s.db.ExecContext(ctx, query, sessionID, lang); err != nil {
+		return fmt.Errorf("failed to set session language: %w", err)
+	}
+
+	s.logger.Debug("Session language set",
+		zap.String("session_id", sessionID),
+		zap.String("language", language))
+	return nil
+}
+
+// SetSessionUser не перезаписывает уже заданного владельца сессии
+func (s *PostgresStorage) SetSessionUser(ctx context.Context, sessionID, userID string) error {
+	query := `UPDATE chat_sessions SET user_id = $2 WHERE id = $1 AND user_id IS NULL`
+
+	if _, err := s.db.ExecContext(ctx, query, sessionID, userID); err != nil {
+		return fmt.Errorf("failed to set session user: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) ListSessionIDs(ctx context.Context, filter models.SessionFilter) ([]string, error) {
+	var conditions []string
+	var args []interface{}
+
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.IDs != nil {
+		addCondition("id = ANY($%d)", pq.Array(filter.IDs))
+	}
+	if !filter.UpdatedBefore.IsZero() {
+		addCondition("updated_at < $%d", filter.UpdatedBefore)
+	}
+	if filter.UserID != "" {
+		addCondition("user_id = $%d", filter.UserID)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT id FROM chat_sessions "+where+" ORDER BY updated_at, id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan session id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate sessions: %w", err)
+	}
+
+	return ids, nil
+}
+
+func (s *PostgresStorage) ListSessionsUpdatedBefore(ctx context.Context, before time.Time, minActiveMessages, limit int) ([]string, error) {
+	query := `
+		SELECT s.id
+		FROM chat_sessions s
+		WHERE s.updated_at < $1
+		  AND (SELECT COUNT(*) FROM messages m
+		       WHERE m.session_id = s.id AND m.message_type = 'regular' AND m.role <> 'tool'
+		         AND m.is_compressed = false) > $2
+		ORDER BY s.updated_at, s.id
+		LIMIT $3`
+
+	rows, err := s.db.QueryContext(ctx, query, before, minActiveMessages, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list idle sessions: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan session id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate idle sessions: %w", err)
+	}
+
+	return ids, nil
+}
+
+// DeleteSessions удаляет пачку сессий в одной транзакции: при ошибке не удаляется ни одна
+// сессия пачки. Сообщения и резюме удаляются каскадно.
+func (s *PostgresStorage) DeleteSessions(ctx context.Context, ids []string) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM chat_sessions WHERE id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete sessions: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit session deletion: %w", err)
+	}
+
+	s.logger.Info("Sessions deleted",
+		zap.Int("requested", len(ids)),
+		zap.Int64("deleted", deleted))
+	return int(deleted), nil
+}
+
+// CloneSession копирует сессию в одной транзакции. Резюме вставляются без ссылок друг на друга,
+// затем сообщения с новыми summary_id, после чего восстанавливаются ссылки между резюме,
+// поэтому внешние ключи не зависят от порядка вставки.
+func (s *PostgresStorage) CloneSession(ctx context.Context, sourceID, targetID string) (*models.SessionCloneResult, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var language, userID sql.NullString
+	err = tx.QueryRowContext(ctx, `SELECT language, user_id FROM chat_sessions WHERE id = $1 FOR SHARE`, sourceID).Scan(&language, &userID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", interfaces.ErrSessionNotFound, sourceID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source session: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO chat_sessions (id, created_at, updated_at, message_count, language, cloned_from, user_id)
+		VALUES ($1, NOW(), NOW(), 0, $2, $3, $4)`,
+		targetID, language, sourceID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cloned session: %w", err)
+	}
+
+	messages, err := s.cloneSourceMessages(ctx, tx, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	summaries, err := s.cloneSourceSummaries(ctx, tx, sourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	cloned := models.CloneSessionContent(targetID, messages, summaries)
+
+	for _, summary := range cloned.Summaries {
+		anchorsJSON, err := json.Marshal(summary.Anchors)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal anchors: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO summaries (id, session_id, summary_text, anchors, summary_level,
+			                      covers_from_message_id, covers_to_message_id, message_count,
+			                      is_compressed, summary_id, tokens_used, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULL, $10, $11)`,
+			summary.ID, summary.SessionID, summary.SummaryText, anchorsJSON, summary.SummaryLevel,
+			summary.CoversFromMessageID, summary.CoversToMessageID, summary.MessageCount,
+			summary.IsCompressed, summary.TokensUsed, summary.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy summary: %w", err)
+		}
+	}
+
+	for _, msg := range cloned.Messages {
+		metadataJSON, err := json.Marshal(msg.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO messages (id, session_id, role, content, message_type, status, is_compressed,
+			                     summary_id, tool_name, tool_call_id, created_at, metadata)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+			msg.ID, msg.SessionID, msg.Role, msg.Content, msg.MessageType, msg.Status,
+			msg.IsCompressed, nullString(msg.SummaryID), nullString(msg.ToolName), nullString(msg.ToolCallID),
+			msg.Timestamp, metadataJSON)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy message: %w", err)
+		}
+	}
+
+	for _, summary := range cloned.Summaries {
+		if summary.SummaryID == "" {
+			continue
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE summaries SET summary_id = $1 WHERE id = $2`, summary.SummaryID, summary.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to link copied summary: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit session clone: %w", err)
+	}
+
+	s.logger.Info("Session cloned",
+		zap.String("source_session_id", sourceID),
+		zap.String("session_id", targetID),
+		zap.Int("messages", len(cloned.Messages)),
+		zap.Int("summaries", len(cloned.Summaries)))
+
+	return &models.SessionCloneResult{
+		SessionID:       targetID,
+		SourceSessionID: sourceID,
+		MessagesCopied:  len(cloned.Messages),
+		SummariesCopied: len(cloned.Summaries),
+	}, nil
+}
+
+func (s *PostgresStorage) cloneSourceMessages(ctx context.Context, tx *sql.Tx, sessionID string) ([]models.Message, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, session_id, role, content, message_type, status, is_compressed,
+		       summary_id, tool_name, tool_call_id, created_at, metadata
+		FROM messages
+		WHERE session_id = $1
+		ORDER BY created_at ASC`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query source messages: %w", err)
+	}
+	defer rows.Close()
+
+	return s.scanMessages(rows)
+}
+
+func (s *PostgresStorage) cloneSourceSummaries(ctx context.Context, tx *sql.Tx, sessionID string) ([]models.Summary, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, session_id, summary_text, anchors, summary_level,
+		       covers_from_message_id, covers_to_message_id, message_count,
+		       is_compressed, summary_id, tokens_used, created_at
+		FROM summaries
+		WHERE session_id = $1
+		ORDER BY created_at ASC`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query source summaries: %w", err)
+	}
+	defer rows.Close()
+
+	return s.scanSummaries(rows)
+}
+
+// PreferencesStore implementation
+func (s *PostgresStorage) GetUserPreferences(ctx context.Context, userID string) (*models.UserPreferences, error) {
+	query := `SELECT user_id, prefs, updated_at FROM user_preferences WHERE user_id = $1`
+
+	var prefs models.UserPreferences
+	var prefsJSON []byte
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&prefs.UserID, &prefsJSON, &prefs.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user preferences: %w", err)
+	}
+
+	if err := json.Unmarshal(prefsJSON, &prefs.Prefs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal user preferences: %w", err)
+	}
+
+	return &prefs, nil
+}
+
+func (s *PostgresStorage) SaveUserPreferences(ctx context.Context, prefs models.UserPreferences) error {
+	query := `
+		INSERT INTO user_preferences (user_id, prefs, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET prefs = EXCLUDED.prefs, updated_at = EXCLUDED.updated_at`
+
+	prefsJSON, err := json.Marshal(prefs.Prefs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal user preferences: %w", err)
+	}
+
+	updatedAt := prefs.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = time.Now()
+	}
+
+	if _, err := s.db.ExecContext(ctx, query, prefs.UserID, prefsJSON, updatedAt); err != nil {
+		return fmt.Errorf("failed to save user preferences: %w", err)
+	}
+
+	s.logger.Debug("User preferences saved", zap.String("user_id", prefs.UserID))
+	return nil
+}
+
+// ToolInvocationStore implementation
+func (s *PostgresStorage) SaveToolInvocation(ctx context.Context, invocation models.ToolInvocation) error {
+	query := `
+		INSERT INTO tool_invocations (id, session_id, user_id, tool_name, arguments,
+		                              result_hash, success, error, duration_ms, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+	createdAt := invocation.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	arguments := invocation.Arguments
+	if arguments == nil {
+		arguments = map[string]any{}
+	}
+	argumentsJSON, err := json.Marshal(arguments)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tool arguments: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, query,
+		invocation.ID, nullString(invocation.SessionID), nullString(invocation.UserID), invocation.ToolName, argumentsJSON,
+		nullString(invocation.ResultHash), invocation.Success, nullString(invocation.Error),
+		invocation.DurationMs, createdAt)
+	if err != nil {
+		return fmt.Errorf("failed to save tool invocation: %w", err)
+	}
+
+	return nil
+}
+
+func (s *PostgresStorage) ListToolInvocations(ctx context.Context, filter models.ToolInvocationFilter, limit, offset int) ([]models.ToolInvocation, int, error) {
+	var conditions []string
+	var args []interface{}
+
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.SessionID != "" {
+		addCondition("session_id = $%d", filter.SessionID)
+	}
+	if filter.ToolName != "" {
+		addCondition("tool_name = $%d", filter.ToolName)
+	}
+	if !filter.From.IsZero() {
+		addCondition("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("created_at <= $%d", filter.To)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM tool_invocations "+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count tool invocations: %w", err)
+	}
+
+	if total == 0 || offset >= total {
+		return []models.ToolInvocation{}, total, nil
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, session_id, user_id, tool_name, arguments, result_hash,
+		       success, error, duration_ms, created_at
+		FROM tool_invocations
+		%s
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d OFFSET $%d`, where, len(args)+1, len(args)+2)
+
+	rows, err := s.db.QueryContext(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query tool invocations: %w", err)
+	}
+	defer rows.Close()
+
+	var invocations []models.ToolInvocation
+	for rows.Next() {
+		var invocation models.ToolInvocation
+		var sessionID, userID, resultHash, errText sql.NullString
+		var argumentsJSON []byte
+
+		err := rows.Scan(&invocation.ID, &sessionID, &userID, &invocation.ToolName, &argumentsJSON,
+			&resultHash, &invocation.Success, &errText, &invocation.DurationMs, &invocation.CreatedAt)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan tool invocation: %w", err)
+		}
+
+		invocation.SessionID = sessionID.String
+		invocation.UserID = userID.String
+		invocation.ResultHash = resultHash.String
+		invocation.Error = errText.String
+
+		if len(argumentsJSON) > 0 {
+			if err := json.Unmarshal(argumentsJSON, &invocation.Arguments); err != nil {
+				return nil, 0, fmt.Errorf("failed to unmarshal tool arguments: %w", err)
+			}
+		}
+
+		invocations = append(invocations, invocation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to iterate tool invocations: %w", err)
+	}
+
+	return invocations, total, nil
+}
+
+// nullString возвращает nil для пустой строки, чтобы в базу записался NULL
+func nullString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
 // Helper methods for scanning
 func (s *PostgresStorage) scanMessages(rows *sql.Rows) ([]models.Message, error) {
 	var messages []models.Message
@@ -363,7 +1321,7 @@ func (s *PostgresStorage) scanMessages(rows *sql.Rows) ([]models.Message, error)
 		var metadataJSON []byte
 
 		err := rows.Scan(
-			&msg.ID, &msg.SessionID, &msg.Role, &msg.Content, &msg.MessageType,
+			&msg.ID, &msg.SessionID, &msg.Role, &msg.Content, &msg.MessageType, &msg.Status,
 			&msg.IsCompressed, &summaryID, &toolName, &toolCallID,
 			&msg.Timestamp, &metadataJSON)
 
@@ -410,7 +1368,7 @@ func (s *PostgresStorage) scanSummary(row *sql.Row) (*models.Summary, error) {
 		&summary.TokensUsed, &summary.UpdatedAt)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("summary not found")
+		return nil, interfaces.ErrSummaryNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to scan summary: %w", err)
@@ -473,3 +1431,7 @@ func (s *PostgresStorage) scanSummaries(rows *sql.Rows) ([]models.Summary, error
 var _ interfaces.MessageStore = (*PostgresStorage)(nil)
 var _ interfaces.SummaryStore = (*PostgresStorage)(nil)
 var _ interfaces.SessionStore = (*PostgresStorage)(nil)
+var _ interfaces.HealthChecker = (*PostgresStorage)(nil)
+var _ interfaces.PreferencesStore = (*PostgresStorage)(nil)
+var _ interfaces.ToolInvocationStore = (*PostgresStorage)(nil)
+var _ interfaces.EmbeddingStore = (*PostgresStorage)(nil)
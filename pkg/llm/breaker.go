@@ -0,0 +1,183 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Значения llm.circuit_breaker по умолчанию
+const (
+	DefaultBreakerFailureThreshold = 5
+	DefaultBreakerCoolDown         = 30 * time.Second
+)
+
+// Состояния автоматического выключателя
+const (
+	BreakerClosed   = "closed"    // запросы идут к провайдеру
+	BreakerOpen     = "open"      // запросы отклоняются без обращения к провайдеру
+	BreakerHalfOpen = "half_open" // пропускается один пробный запрос
+)
+
+// ErrCircuitOpen выключатель разомкнут после серии ошибок провайдера, запрос отклонён без обращения к API
+var ErrCircuitOpen = errors.New("LLM circuit breaker is open")
+
+// CircuitOpenError отказ разомкнутого выключателя с временем до пробного запроса
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrCircuitOpen, e.RetryAfter.Round(time.Second))
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+// RetryAfterSeconds время до пробного запроса в целых секундах для заголовка Retry-After
+func (e *CircuitOpenError) RetryAfterSeconds() int {
+	return max(1, int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+// BreakerConfig параметры автоматического выключателя вызовов модели
+type BreakerConfig struct {
+	FailureThreshold int           `mapstructure:"failure_threshold"` // ошибок подряд до размыкания; 0 отключает выключатель
+	CoolDown         time.Duration `mapstructure:"cool_down"`         // время в разомкнутом состоянии до пробного запроса
+}
+
+// Validate проверяет параметры выключателя
+func (b BreakerConfig) Validate() error {
+	if b.FailureThreshold < 0 {
+		return fmt.Errorf("failure_threshold cannot be negative: %d", b.FailureThreshold)
+	}
+	if b.FailureThreshold > 0 && b.CoolDown <= 0 {
+		return fmt.Errorf("cool_down must be positive: %s", b.CoolDown)
+	}
+	return nil
+}
+
+// BreakerStatus состояние выключателя для проверок готовности
+type BreakerStatus struct {
+	State               string
+	ConsecutiveFailures int
+	FailureThreshold    int
+	OpenedAt            time.Time     // нулевое в замкнутом состоянии
+	RetryAfter          time.Duration // до пробного запроса в разомкнутом состоянии
+}
+
+// circuitBreaker размыкается после FailureThreshold ошибок провайдера подряд, через CoolDown
+// пропускает один пробный запрос и замыкается при его успехе
+type circuitBreaker struct {
+	config BreakerConfig
+	now    func() time.Time
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool // пробный запрос полуоткрытого состояния ещё выполняется
+}
+
+func newCircuitBreaker(config BreakerConfig, now func() time.Time, logger *zap.Logger) *circuitBreaker {
+	return &circuitBreaker{
+		config: config,
+		now:    now,
+		logger: logger,
+		state:  BreakerClosed,
+	}
+}
+
+// allow решает, можно ли выполнить запрос; в полуоткрытом состоянии пропускает только один
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		remaining := b.config.CoolDown - b.now().Sub(b.openedAt)
+		if remaining > 0 {
+			return &CircuitOpenError{RetryAfter: remaining}
+		}
+		b.setState(BreakerHalfOpen)
+		b.probing = true
+		return nil
+	case BreakerHalfOpen:
+		if b.probing {
+			return &CircuitOpenError{RetryAfter: b.config.CoolDown}
+		}
+		b.probing = true
+		return nil
+	}
+	return nil
+}
+
+// record учитывает результат запроса, пропущенного allow
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		// Клиент ушёл раньше ответа - о провайдере ничего не известно
+	case breakerFailure(err):
+		b.failures++
+		if b.state == BreakerHalfOpen || b.failures >= b.config.FailureThreshold {
+			b.openedAt = b.now()
+			b.setState(BreakerOpen)
+		}
+	default:
+		b.failures = 0
+		if b.state != BreakerClosed {
+			b.setState(BreakerClosed)
+		}
+	}
+}
+
+// setState меняет состояние; вызывается под mu
+func (b *circuitBreaker) setState(state string) {
+	if b.state == state {
+		return
+	}
+	b.logger.Warn("LLM circuit breaker state changed",
+		zap.String("from", b.state),
+		zap.String("to", state),
+		zap.Int("consecutive_failures", b.failures),
+	)
+	b.state = state
+}
+
+func (b *circuitBreaker) status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		FailureThreshold:    b.config.FailureThreshold,
+	}
+	if b.state != BreakerClosed {
+		status.OpenedAt = b.openedAt
+	}
+	if b.state == BreakerOpen {
+		status.RetryAfter = max(0, b.config.CoolDown-b.now().Sub(b.openedAt))
+	}
+	return status
+}
+
+// breakerFailure ошибки, говорящие о недоступности провайдера. Ошибки запроса (блокировка фильтрами,
+// неверные параметры, ключ API) не размыкают выключатель: повтор через время их не исправит.
+func breakerFailure(err error) bool {
+	return errors.Is(err, ErrUpstream) ||
+		errors.Is(err, ErrRateLimited) ||
+		errors.Is(err, ErrMCPUnavailable) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,144 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fakeClock управляемое время выключателя
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestBreaker(threshold int, coolDown time.Duration) (*circuitBreaker, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	return newCircuitBreaker(BreakerConfig{FailureThreshold: threshold, CoolDown: coolDown}, clock.Now, zap.NewNop()), clock
+}
+
+// call пропускает запрос через выключатель и записывает его результат
+func call(b *circuitBreaker, result error) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+	b.record(result)
+	return nil
+}
+
+func TestBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	b, clock := newTestBreaker(3, 10*time.Second)
+	upstream := fmt.Errorf("%w: 503", ErrUpstream)
+
+	// Успешный запрос сбрасывает счётчик ошибок подряд
+	call(b, upstream)
+	call(b, upstream)
+	call(b, nil)
+	call(b, upstream)
+	call(b, upstream)
+	if status := b.status(); status.State != BreakerClosed || status.ConsecutiveFailures != 2 {
+		t.Fatalf("status = %+v, want closed with 2 failures", status)
+	}
+
+	call(b, upstream)
+	if status := b.status(); status.State != BreakerOpen || status.OpenedAt != clock.now || status.RetryAfter != 10*time.Second {
+		t.Fatalf("status = %+v, want open", status)
+	}
+
+	clock.advance(4 * time.Second)
+	err := b.allow()
+	var circuitErr *CircuitOpenError
+	if !errors.As(err, &circuitErr) || !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow in open state: err = %v, want CircuitOpenError", err)
+	}
+	if circuitErr.RetryAfter != 6*time.Second || circuitErr.RetryAfterSeconds() != 6 {
+		t.Errorf("retry after = %s (%d s), want 6s", circuitErr.RetryAfter, circuitErr.RetryAfterSeconds())
+	}
+}
+
+func TestBreakerHalfOpenProbe(t *testing.T) {
+	b, clock := newTestBreaker(1, 10*time.Second)
+	upstream := fmt.Errorf("%w: 503", ErrUpstream)
+
+	call(b, upstream)
+	clock.advance(10 * time.Second)
+
+	// После охлаждения пропускается один пробный запрос
+	if err := b.allow(); err != nil {
+		t.Fatalf("probe rejected: %v", err)
+	}
+	if b.status().State != BreakerHalfOpen {
+		t.Fatalf("state = %s, want half_open", b.status().State)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("second request during the probe: err = %v, want ErrCircuitOpen", err)
+	}
+
+	// Отменённый пробный запрос ничего не говорит о провайдере
+	b.record(context.Canceled)
+	if b.status().State != BreakerHalfOpen {
+		t.Fatalf("state after canceled probe = %s, want half_open", b.status().State)
+	}
+
+	// Неудачная проба снова размыкает выключатель на полный срок
+	if err := call(b, upstream); err != nil {
+		t.Fatal(err)
+	}
+	if status := b.status(); status.State != BreakerOpen || status.RetryAfter != 10*time.Second {
+		t.Fatalf("status after failed probe = %+v", status)
+	}
+
+	clock.advance(10 * time.Second)
+	if err := call(b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if status := b.status(); status.State != BreakerClosed || status.ConsecutiveFailures != 0 || !status.OpenedAt.IsZero() {
+		t.Errorf("status after successful probe = %+v, want closed", status)
+	}
+}
+
+func TestBreakerIgnoresRequestErrors(t *testing.T) {
+	b, _ := newTestBreaker(1, time.Minute)
+
+	for _, err := range []error{ErrUnauthorized, errors.New("blocked by safety filters"), context.Canceled} {
+		call(b, err)
+	}
+	if status := b.status(); status.State != BreakerClosed || status.ConsecutiveFailures != 0 {
+		t.Errorf("status = %+v, want closed without failures", status)
+	}
+
+	for _, err := range []error{ErrRateLimited, ErrMCPUnavailable, context.DeadlineExceeded} {
+		if !breakerFailure(fmt.Errorf("request: %w", err)) {
+			t.Errorf("%v is not counted as a provider failure", err)
+		}
+	}
+}
+
+func TestClientFailsFastWhenCircuitOpen(t *testing.T) {
+	provider := &fakeProvider{errs: []error{ErrUpstream, ErrUpstream}}
+	client := NewClientWithProvider(provider, zap.NewNop())
+	client.SetCircuitBreaker(BreakerConfig{FailureThreshold: 2, CoolDown: time.Minute})
+
+	messages := []Message{{Role: "user", Content: "hi"}}
+	for range 2 {
+		if _, err := client.ChatCompletion(context.Background(), messages); !errors.Is(err, ErrUpstream) {
+			t.Fatalf("err = %v, want ErrUpstream", err)
+		}
+	}
+	if _, err := client.ChatCompletion(context.Background(), messages); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+	if provider.calls() != 2 {
+		t.Errorf("provider calls = %d, want 2", provider.calls())
+	}
+	if status, ok := client.CircuitBreakerStatus(); !ok || status.State != BreakerOpen {
+		t.Errorf("status = %+v, %v; want open", status, ok)
+	}
+}
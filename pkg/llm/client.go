@@ -2,8 +2,14 @@ package llm
 
 import (
 	"LLM_Chat/pkg/llm/providers"
+	"LLM_Chat/pkg/redact"
 	"context"
 	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -11,9 +17,20 @@ import (
 // Client обертка над провайдерами для обратной совместимости
 type Client struct {
 	provider providers.Provider
+	observer UsageObserver
+	breaker  *circuitBreaker // nil - выключатель отключён
+	limiter  *requestLimiter // nil - без ограничения одновременных запросов
+	retry    RetryConfig     // повторы ChatCompletion; нулевое значение - без повторов
+	stats    *Stats
 	logger   *zap.Logger
+
+	closeOnce sync.Once
+	closeErr  error
 }
 
+// UsageObserver получает сведения о каждом запросе к LLM (используется для метрик)
+type UsageObserver func(provider, model string, usage Usage, duration time.Duration, err error)
+
 // Message совместимый тип (переиспользуем из providers)
 type Message = providers.Message
 
@@ -32,22 +49,221 @@ type Usage = providers.Usage
 // StreamChunk совместимый тип
 type StreamChunk = providers.StreamChunk
 
+// MCPProber совместимый тип
+type MCPProber = providers.MCPProber
+
+// MCPToolCounter совместимый тип
+type MCPToolCounter = providers.MCPToolCounter
+
+// MCPToolCounts совместимый тип
+type MCPToolCounts = providers.MCPToolCounts
+
+// ToolLister совместимый тип
+type ToolLister = providers.ToolLister
+
+// ToolInfo совместимый тип
+type ToolInfo = providers.ToolInfo
+
+// ToolCaller совместимый тип
+type ToolCaller = providers.ToolCaller
+
+// ToolCallResult совместимый тип
+type ToolCallResult = providers.ToolCallResult
+
+// ResourceReader совместимый тип
+type ResourceReader = providers.ResourceReader
+
+// ResourceInfo совместимый тип
+type ResourceInfo = providers.ResourceInfo
+
+// ResourceContent совместимый тип
+type ResourceContent = providers.ResourceContent
+
+// PromptProvider совместимый тип
+type PromptProvider = providers.PromptProvider
+
+// PromptInfo совместимый тип
+type PromptInfo = providers.PromptInfo
+
+// PromptArgument совместимый тип
+type PromptArgument = providers.PromptArgument
+
+// PromptArgumentsError совместимый тип
+type PromptArgumentsError = providers.PromptArgumentsError
+
+// ProviderStatus совместимый тип
+type ProviderStatus = providers.ProviderStatus
+
+// ToolCallObserver совместимый тип
+type ToolCallObserver = providers.ToolCallObserver
+
+// ToolCallEvent совместимый тип
+type ToolCallEvent = providers.ToolCallEvent
+
+// ToolProgressInfo совместимый тип
+type ToolProgressInfo = providers.ToolProgressInfo
+
+// ToolCallTrace совместимый тип
+type ToolCallTrace = providers.ToolCallTrace
+
+// ToolInvocation совместимый тип
+type ToolInvocation = providers.ToolInvocation
+
+// ToolInvocationRecorder совместимый тип
+type ToolInvocationRecorder = providers.ToolInvocationRecorder
+
+// Reinitializer совместимый тип
+type Reinitializer = providers.Reinitializer
+
+// ErrProviderReinitializing совместимая ошибка
+var ErrProviderReinitializing = providers.ErrProviderReinitializing
+
+// RequestOptions совместимый тип
+type RequestOptions = providers.RequestOptions
+
+// GenerationInfo совместимый тип
+type GenerationInfo = providers.GenerationInfo
+
+// Warning совместимый тип
+type Warning = providers.Warning
+
+// GroundingInfo совместимый тип
+type GroundingInfo = providers.GroundingInfo
+
+// GroundingSource совместимый тип
+type GroundingSource = providers.GroundingSource
+
+// PrefillSupporter совместимый тип
+type PrefillSupporter = providers.PrefillSupporter
+
+// ErrPrefillNotSupported совместимая ошибка
+var ErrPrefillNotSupported = providers.ErrPrefillNotSupported
+
+// ErrEmbeddingsNotSupported совместимая ошибка
+var ErrEmbeddingsNotSupported = providers.ErrEmbeddingsNotSupported
+
+// ErrTokenCountingNotSupported совместимая ошибка
+var ErrTokenCountingNotSupported = providers.ErrTokenCountingNotSupported
+
+// ErrContentBlocked совместимая ошибка
+var ErrContentBlocked = providers.ErrContentBlocked
+
+// ContentBlockedError совместимый тип
+type ContentBlockedError = providers.ContentBlockedError
+
+// ErrToolNotAllowed совместимая ошибка
+var ErrToolNotAllowed = providers.ErrToolNotAllowed
+
+// ErrUnknownTool совместимая ошибка
+var ErrUnknownTool = providers.ErrUnknownTool
+
+// ErrResourcesNotSupported совместимая ошибка
+var ErrResourcesNotSupported = providers.ErrResourcesNotSupported
+
+// ErrResourceNotFound совместимая ошибка
+var ErrResourceNotFound = providers.ErrResourceNotFound
+
+// ErrResourceNotText совместимая ошибка
+var ErrResourceNotText = providers.ErrResourceNotText
+
+// ErrPromptsNotSupported совместимая ошибка
+var ErrPromptsNotSupported = providers.ErrPromptsNotSupported
+
+// ErrPromptNotFound совместимая ошибка
+var ErrPromptNotFound = providers.ErrPromptNotFound
+
+// ErrPromptArguments совместимая ошибка
+var ErrPromptArguments = providers.ErrPromptArguments
+
+// DefaultEmbeddingModel модель эмбеддингов по умолчанию
+const DefaultEmbeddingModel = providers.DefaultGeminiEmbeddingModel
+
+// Совместимые константы транспортов MCP
+const (
+	MCPTransportHTTP  = providers.MCPTransportHTTP
+	MCPTransportStdio = providers.MCPTransportStdio
+)
+
+// OutputTokenLimiter совместимый тип
+type OutputTokenLimiter = providers.OutputTokenLimiter
+
+// Совместимые константы имён провайдеров
+const (
+	ProviderGemini     = providers.ProviderGemini
+	ProviderOpenRouter = providers.ProviderOpenRouter
+)
+
+// ErrMCPNotSupported совместимая ошибка
+var ErrMCPNotSupported = providers.ErrMCPNotSupported
+
+// Совместимые константы режимов вызова инструментов
+const (
+	ToolModeAuto = providers.ToolModeAuto
+	ToolModeAny  = providers.ToolModeAny
+	ToolModeNone = providers.ToolModeNone
+)
+
+// Совместимые константы причин завершения генерации
+const (
+	FinishReasonMaxIterations = providers.FinishReasonMaxIterations
+	FinishReasonMaxTokens     = providers.FinishReasonMaxTokens
+)
+
+// WithRequestOptions передаёт параметры генерации для одного запроса через контекст
+func WithRequestOptions(ctx context.Context, opts RequestOptions) context.Context {
+	return providers.WithRequestOptions(ctx, opts)
+}
+
 // NewClientWithProvider создает клиент с готовым провайдером
 func NewClientWithProvider(provider providers.Provider, logger *zap.Logger) *Client {
 	return &Client{
 		provider: provider,
+		stats:    NewStats(),
 		logger:   logger,
 	}
 }
 
-// ChatCompletion выполняет запрос к LLM (делегирует провайдеру)
+// ChatCompletion выполняет запрос к LLM (делегирует провайдеру) с повторами из SetRetryConfig
 func (c *Client) ChatCompletion(ctx context.Context, messages []Message) (*ChatResponse, error) {
+	return c.ChatCompletionWithRetry(ctx, messages, c.retry)
+}
+
+// chatCompletion одна попытка запроса
+func (c *Client) chatCompletion(ctx context.Context, messages []Message) (*ChatResponse, error) {
 	c.logger.Debug("Executing chat completion",
 		zap.String("provider", c.provider.GetName()),
 		zap.Int("messages_count", len(messages)),
 	)
 
-	return c.provider.ChatCompletion(ctx, messages)
+	if err := c.checkPrefill(messages); err != nil {
+		return nil, err
+	}
+	if err := c.acquireSlot(ctx); err != nil {
+		return nil, err
+	}
+	defer c.releaseSlot()
+	if err := c.allowRequest(); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := c.provider.ChatCompletion(ctx, messages)
+	c.recordResult(err)
+	if err == nil {
+		applyStopSequences(resp, providers.RequestOptionsFromContext(ctx).Stop)
+	}
+	c.stats.Record(c.provider.GetName(), requestModel(ctx, resp), time.Since(start), err)
+	if c.observer != nil {
+		var usage Usage
+		var model string
+		if resp != nil {
+			usage = resp.Usage
+			model = resp.Model
+		}
+		c.observer(c.provider.GetName(), model, usage, time.Since(start), err)
+	}
+
+	return resp, err
 }
 
 // ChatCompletionStream выполняет стриминговый запрос к LLM
@@ -57,7 +273,210 @@ func (c *Client) ChatCompletionStream(ctx context.Context, messages []Message) (
 		zap.Int("messages_count", len(messages)),
 	)
 
-	return c.provider.ChatCompletionStream(ctx, messages)
+	if err := c.checkPrefill(messages); err != nil {
+		return nil, err
+	}
+	// Слот занят до конца потока и освобождается в instrumentStream
+	if err := c.acquireSlot(ctx); err != nil {
+		return nil, err
+	}
+	if err := c.allowRequest(); err != nil {
+		c.releaseSlot()
+		return nil, err
+	}
+
+	start := time.Now()
+	chunks, err := c.provider.ChatCompletionStream(ctx, messages)
+	if err != nil {
+		c.releaseSlot()
+		c.recordResult(err)
+		c.stats.Record(c.provider.GetName(), requestModel(ctx, nil), time.Since(start), err)
+		return nil, err
+	}
+
+	if stops := providers.RequestOptionsFromContext(ctx).Stop; len(stops) > 0 {
+		chunks = filterStopSequences(chunks, stops)
+	}
+
+	return c.instrumentStream(ctx, chunks, start), nil
+}
+
+// instrumentStream пересылает чанки потока и учитывает запрос в Stats по его завершении
+func (c *Client) instrumentStream(ctx context.Context, chunks <-chan StreamChunk, start time.Time) <-chan StreamChunk {
+	out := make(chan StreamChunk, cap(chunks))
+
+	go func() {
+		defer close(out)
+
+		var streamErr error
+		defer func() {
+			c.releaseSlot()
+			c.recordResult(streamErr)
+			c.stats.Record(c.provider.GetName(), requestModel(ctx, nil), time.Since(start), streamErr)
+		}()
+
+		for chunk := range chunks {
+			if chunk.Error != nil && streamErr == nil {
+				streamErr = chunk.Error
+			}
+
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				// Получатель ушёл - дочитываем поток, чтобы горутина провайдера завершилась
+				if streamErr == nil {
+					streamErr = ctx.Err()
+				}
+				for range chunks {
+				}
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// SetCircuitBreaker включает автоматический выключатель запросов к модели; FailureThreshold 0 его отключает.
+// Вызывается при инициализации, до первых запросов.
+func (c *Client) SetCircuitBreaker(config BreakerConfig) {
+	c.breaker = nil
+	if config.FailureThreshold > 0 {
+		c.breaker = newCircuitBreaker(config, time.Now, c.logger)
+	}
+}
+
+// CircuitBreakerStatus возвращает состояние выключателя, если он включён
+func (c *Client) CircuitBreakerStatus() (BreakerStatus, bool) {
+	if c.breaker == nil {
+		return BreakerStatus{}, false
+	}
+	return c.breaker.status(), true
+}
+
+// SetConcurrencyLimit ограничивает число одновременных запросов генерации клиента; 0 снимает ограничение.
+// Запрос сверх лимита ждёт слот не дольше queueTimeout и получает ErrRateLimited.
+// Вызывается при инициализации, до первых запросов.
+func (c *Client) SetConcurrencyLimit(limit int, queueTimeout time.Duration) {
+	c.limiter = nil
+	if limit > 0 {
+		if queueTimeout <= 0 {
+			queueTimeout = DefaultQueueTimeout
+		}
+		c.limiter = newRequestLimiter(limit, queueTimeout)
+	}
+}
+
+// ConcurrencyStats возвращает загрузку пула одновременных запросов, если лимит задан
+func (c *Client) ConcurrencyStats() (LimiterStats, bool) {
+	if c.limiter == nil {
+		return LimiterStats{}, false
+	}
+	return c.limiter.stats(), true
+}
+
+func (c *Client) acquireSlot(ctx context.Context) error {
+	if c.limiter == nil {
+		return nil
+	}
+	return c.limiter.acquire(ctx)
+}
+
+func (c *Client) releaseSlot() {
+	if c.limiter != nil {
+		c.limiter.release()
+	}
+}
+
+// allowRequest отклоняет запрос с *CircuitOpenError, пока выключатель разомкнут
+func (c *Client) allowRequest() error {
+	if c.breaker == nil {
+		return nil
+	}
+	return c.breaker.allow()
+}
+
+// recordResult передаёт результат запроса выключателю
+func (c *Client) recordResult(err error) {
+	if c.breaker != nil {
+		c.breaker.record(err)
+	}
+}
+
+// SupportsPrefill сообщает, умеет ли провайдер продолжать ответ с заданного начала
+func (c *Client) SupportsPrefill() bool {
+	supporter, ok := c.provider.(providers.PrefillSupporter)
+	return ok && supporter.SupportsPrefill()
+}
+
+// OutputTokenLimit возвращает предел max_output_tokens модели, если провайдер его знает (иначе 0)
+func (c *Client) OutputTokenLimit(model string) int {
+	limiter, ok := c.provider.(providers.OutputTokenLimiter)
+	if !ok {
+		return 0
+	}
+	return limiter.OutputTokenLimit(model)
+}
+
+// checkPrefill отклоняет запрос с началом ответа ассистента, если провайдер его не поддерживает:
+// иначе провайдер воспринял бы префикс как обычную реплику истории
+func (c *Client) checkPrefill(messages []Message) error {
+	if providers.HasAssistantPrefix(messages) && !c.SupportsPrefill() {
+		return fmt.Errorf("%w: provider '%s'", ErrPrefillNotSupported, c.provider.GetName())
+	}
+	return nil
+}
+
+// Stats возвращает счётчики запросов клиента
+func (c *Client) Stats() *Stats {
+	return c.stats
+}
+
+// SetStats подменяет счётчики клиента общими, чтобы несколько клиентов
+// одного провайдера учитывались вместе
+func (c *Client) SetStats(stats *Stats) {
+	if stats != nil {
+		c.stats = stats
+	}
+}
+
+// SetUsageObserver устанавливает наблюдателя за запросами к LLM
+func (c *Client) SetUsageObserver(observer UsageObserver) {
+	c.observer = observer
+}
+
+// SetToolCallObserver устанавливает наблюдателя за вызовами MCP инструментов, если провайдер это поддерживает
+func (c *Client) SetToolCallObserver(observer ToolCallObserver) bool {
+	observable, ok := c.provider.(providers.ToolCallObservable)
+	if !ok {
+		return false
+	}
+
+	observable.SetToolCallObserver(observer)
+	return true
+}
+
+// SetToolInvocationRecorder устанавливает журнал аудита вызовов MCP инструментов, если провайдер это поддерживает
+func (c *Client) SetToolInvocationRecorder(recorder ToolInvocationRecorder) bool {
+	recordable, ok := c.provider.(providers.ToolInvocationRecordable)
+	if !ok {
+		return false
+	}
+
+	recordable.SetToolInvocationRecorder(recorder)
+	return true
+}
+
+// SetRedactor устанавливает маскирование персональных данных в журнале аудита и логах
+// вызовов инструментов, если провайдер это поддерживает
+func (c *Client) SetRedactor(redactor *redact.Redactor) bool {
+	redactable, ok := c.provider.(providers.Redactable)
+	if !ok {
+		return false
+	}
+
+	redactable.SetRedactor(redactor)
+	return true
 }
 
 // GetProviderName возвращает имя используемого провайдера
@@ -70,15 +489,151 @@ func (c *Client) GetSupportedModels() []string {
 	return c.provider.GetSupportedModels()
 }
 
+// ProbeMCP проверяет доступность MCP сервера, если провайдер это поддерживает
+func (c *Client) ProbeMCP(ctx context.Context) (int, error) {
+	prober, ok := c.provider.(providers.MCPProber)
+	if !ok {
+		return 0, fmt.Errorf("%w: provider '%s'", ErrMCPNotSupported, c.provider.GetName())
+	}
+
+	return prober.ProbeMCP(ctx)
+}
+
+// MCPToolCounts возвращает число инструментов MCP сервера, доступных модели и скрытых фильтром
+func (c *Client) MCPToolCounts(ctx context.Context) (MCPToolCounts, error) {
+	counter, ok := c.provider.(providers.MCPToolCounter)
+	if !ok {
+		return MCPToolCounts{}, fmt.Errorf("%w: provider '%s'", ErrMCPNotSupported, c.provider.GetName())
+	}
+
+	return counter.MCPToolCounts(ctx)
+}
+
+// ListTools возвращает инструменты, доступные модели; refresh заново запрашивает их у MCP серверов
+func (c *Client) ListTools(ctx context.Context, refresh bool) ([]ToolInfo, error) {
+	lister, ok := c.provider.(providers.ToolLister)
+	if !ok {
+		return nil, fmt.Errorf("%w: provider '%s'", ErrMCPNotSupported, c.provider.GetName())
+	}
+
+	return lister.ListTools(ctx, refresh)
+}
+
+// CallTool вызывает инструмент в обход модели, если провайдер это поддерживает
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]any) (*ToolCallResult, error) {
+	caller, ok := c.provider.(providers.ToolCaller)
+	if !ok {
+		return nil, fmt.Errorf("%w: provider '%s'", ErrMCPNotSupported, c.provider.GetName())
+	}
+
+	return caller.CallTool(ctx, name, args)
+}
+
+// ListResources возвращает ресурсы MCP серверов, если провайдер их поддерживает
+func (c *Client) ListResources(ctx context.Context, refresh bool) ([]ResourceInfo, error) {
+	reader, ok := c.provider.(providers.ResourceReader)
+	if !ok {
+		return nil, fmt.Errorf("%w: provider '%s'", ErrResourcesNotSupported, c.provider.GetName())
+	}
+
+	return reader.ListResources(ctx, refresh)
+}
+
+// ReadResource читает ресурс MCP сервера, если провайдер это поддерживает
+func (c *Client) ReadResource(ctx context.Context, uri string) (*ResourceContent, error) {
+	reader, ok := c.provider.(providers.ResourceReader)
+	if !ok {
+		return nil, fmt.Errorf("%w: provider '%s'", ErrResourcesNotSupported, c.provider.GetName())
+	}
+
+	return reader.ReadResource(ctx, uri)
+}
+
+// ListPrompts возвращает промпты MCP серверов, если провайдер их поддерживает
+func (c *Client) ListPrompts(ctx context.Context, refresh bool) ([]PromptInfo, error) {
+	prompts, ok := c.provider.(providers.PromptProvider)
+	if !ok {
+		return nil, fmt.Errorf("%w: provider '%s'", ErrPromptsNotSupported, c.provider.GetName())
+	}
+
+	return prompts.ListPrompts(ctx, refresh)
+}
+
+// GetPrompt возвращает текст промпта MCP сервера, если провайдер это поддерживает
+func (c *Client) GetPrompt(ctx context.Context, name string, args map[string]string) (string, error) {
+	prompts, ok := c.provider.(providers.PromptProvider)
+	if !ok {
+		return "", fmt.Errorf("%w: provider '%s'", ErrPromptsNotSupported, c.provider.GetName())
+	}
+
+	return prompts.GetPrompt(ctx, name, args)
+}
+
+// Embed вычисляет эмбеддинги текстов, если провайдер это поддерживает
+func (c *Client) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	embedder, ok := c.provider.(providers.Embedder)
+	if !ok {
+		return nil, fmt.Errorf("%w: provider '%s'", providers.ErrEmbeddingsNotSupported, c.provider.GetName())
+	}
+
+	return embedder.Embed(ctx, model, texts)
+}
+
+// CountTokens считает входные токены сообщений через API модели, если провайдер это поддерживает
+func (c *Client) CountTokens(ctx context.Context, messages []Message) (int, error) {
+	counter, ok := c.provider.(providers.TokenCounter)
+	if !ok {
+		return 0, fmt.Errorf("%w: provider '%s'", providers.ErrTokenCountingNotSupported, c.provider.GetName())
+	}
+
+	return counter.CountTokens(ctx, messages)
+}
+
+// Reinitialize переинициализирует провайдер, если провайдер это поддерживает
+func (c *Client) Reinitialize(ctx context.Context) (int, error) {
+	reinitializer, ok := c.provider.(providers.Reinitializer)
+	if !ok {
+		return 0, fmt.Errorf("provider '%s' does not support reinitialization", c.provider.GetName())
+	}
+
+	return reinitializer.Reinitialize(ctx)
+}
+
+// Close освобождает соединения провайдера, если провайдер реализует io.Closer.
+// Повторные вызовы провайдер не закрывают и возвращают результат первого.
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		if closer, ok := c.provider.(io.Closer); ok {
+			c.closeErr = closer.Close()
+		}
+	})
+	return c.closeErr
+}
+
+// GetProviderStatus возвращает состояние провайдера, если провайдер это поддерживает
+func (c *Client) GetProviderStatus() (ProviderStatus, bool) {
+	reporter, ok := c.provider.(providers.StatusReporter)
+	if !ok {
+		return ProviderStatus{}, false
+	}
+
+	return reporter.Status(), true
+}
+
 // ValidateProvider проверяет, поддерживается ли провайдер
 func ValidateProvider(providerName string, logger *zap.Logger) error {
-	if providerName != "gemini" {
-		return fmt.Errorf("unsupported provider '%s', only 'gemini' is supported", providerName)
+	if !slices.Contains(providers.SupportedProviders(), strings.ToLower(providerName)) {
+		return fmt.Errorf("unsupported provider '%s' (supported: %s)", providerName, strings.Join(providers.SupportedProviders(), ", "))
 	}
 	return nil
 }
 
 // GetSupportedProviders возвращает список всех поддерживаемых провайдеров
 func GetSupportedProviders(logger *zap.Logger) []string {
-	return []string{"gemini"}
+	return providers.SupportedProviders()
+}
+
+// HasMCPSupport совместимая функция
+func HasMCPSupport(providerName string) bool {
+	return providers.HasMCPSupport(providerName)
 }
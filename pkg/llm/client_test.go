@@ -0,0 +1,52 @@
+package llm
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// closingProvider провайдер с соединениями: считает вызовы Close
+type closingProvider struct {
+	fakeProvider
+	closes atomic.Int32
+	err    error
+}
+
+func (p *closingProvider) Close() error {
+	p.closes.Add(1)
+	return p.err
+}
+
+func TestCloseClosesProviderOnce(t *testing.T) {
+	provider := &closingProvider{err: errors.New("close failed")}
+	client := NewClientWithProvider(provider, zap.NewNop())
+
+	// Завершение сервера может выполниться повторно и одновременно
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.Close(); err == nil || err.Error() != "close failed" {
+				t.Errorf("Close = %v, want the provider error", err)
+			}
+		}()
+	}
+	wg.Wait()
+	client.Close()
+
+	if got := provider.closes.Load(); got != 1 {
+		t.Errorf("provider Close calls = %d, want 1", got)
+	}
+}
+
+func TestCloseWithoutCloser(t *testing.T) {
+	client := NewClientWithProvider(&fakeProvider{}, zap.NewNop())
+	if err := client.Close(); err != nil {
+		t.Errorf("Close = %v, want nil", err)
+	}
+}
@@ -1,13 +1,35 @@
 package llm
 
-import "errors"
+import (
+	"errors"
+
+	"LLM_Chat/pkg/llm/providers"
+)
 
 var (
-	ErrAPIKeyNotSet        = errors.New("API key is not set")
-	ErrInvalidModel        = errors.New("invalid model specified")
-	ErrEmptyMessages       = errors.New("messages cannot be empty")
-	ErrContextCanceled     = errors.New("context was canceled")
-	ErrStreamClosed        = errors.New("stream was closed")
-	ErrRateLimited         = errors.New("rate limited by API")
-	ErrInsufficientCredits = errors.New("insufficient credits")
+	ErrInvalidModel    = errors.New("invalid model specified")
+	ErrEmptyMessages   = errors.New("messages cannot be empty")
+	ErrContextCanceled = errors.New("context was canceled")
+	ErrStreamClosed    = errors.New("stream was closed")
 )
+
+// Классы ошибок провайдеров (совместимые ошибки), проверяются через errors.Is
+var (
+	ErrAPIKeyNotSet        = providers.ErrAPIKeyNotSet
+	ErrUnauthorized        = providers.ErrUnauthorized
+	ErrRateLimited         = providers.ErrRateLimited
+	ErrInsufficientCredits = providers.ErrInsufficientCredits
+	ErrUpstream            = providers.ErrUpstream
+	ErrMCPUnavailable      = providers.ErrMCPUnavailable
+)
+
+// ConfigFieldError ошибка конкретного поля конфигурации провайдера
+type ConfigFieldError struct {
+	Field   string
+	Code    string // "required", "unsupported"
+	Message string
+}
+
+func (e *ConfigFieldError) Error() string {
+	return e.Message
+}
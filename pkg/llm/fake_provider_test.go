@@ -0,0 +1,55 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"LLM_Chat/pkg/llm/providers"
+)
+
+// fakeProvider провайдер для тестов: возвращает ошибки из errs по очереди, затем ответ "ok"
+// и запоминает сообщения каждого запроса
+type fakeProvider struct {
+	mu       sync.Mutex
+	errs     []error
+	requests [][]Message
+	toolCall bool // отмечать вызов инструмента в каждом запросе
+}
+
+func (p *fakeProvider) GetName() string { return "fake" }
+
+func (p *fakeProvider) ChatCompletion(ctx context.Context, messages []Message) (*ChatResponse, error) {
+	p.mu.Lock()
+	p.requests = append(p.requests, messages)
+	var err error
+	if len(p.errs) > 0 {
+		err, p.errs = p.errs[0], p.errs[1:]
+	}
+	p.mu.Unlock()
+
+	if p.toolCall {
+		providers.MarkToolCalled(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ChatResponse{
+		Model:   "fake-model",
+		Choices: []Choice{{Message: Message{Role: "assistant", Content: "ok"}}},
+	}, nil
+}
+
+func (p *fakeProvider) ChatCompletionStream(ctx context.Context, messages []Message) (<-chan StreamChunk, error) {
+	return nil, fmt.Errorf("streaming is not supported by the fake provider")
+}
+
+func (p *fakeProvider) GetSupportedModels() []string { return []string{"fake-model"} }
+
+func (p *fakeProvider) ValidateConfig() error { return nil }
+
+func (p *fakeProvider) calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.requests)
+}
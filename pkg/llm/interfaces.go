@@ -22,10 +22,12 @@ type SummaryClient interface {
 
 // ProviderInfo информация о провайдере
 type ProviderInfo struct {
+	ID              string   `json:"id"` // значение llm.provider
 	Name            string   `json:"name"`
 	SupportedModels []string `json:"supported_models"`
 	Description     string   `json:"description"`
 	RequiredConfig  []string `json:"required_config"`
+	HasMCP          bool     `json:"has_mcp"`
 }
 
 // ProviderRegistry интерфейс для работы с реестром провайдеров
@@ -36,3 +38,12 @@ type ProviderRegistry interface {
 
 // Verify interface implementation
 var _ LLMClient = (*Client)(nil)
+var _ MCPProber = (*Client)(nil)
+var _ MCPToolCounter = (*Client)(nil)
+var _ ToolLister = (*Client)(nil)
+var _ ToolCaller = (*Client)(nil)
+var _ ResourceReader = (*Client)(nil)
+var _ PromptProvider = (*Client)(nil)
+var _ Reinitializer = (*Client)(nil)
+var _ PrefillSupporter = (*Client)(nil)
+var _ OutputTokenLimiter = (*Client)(nil)
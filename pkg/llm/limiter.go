@@ -0,0 +1,83 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultQueueTimeout ожидание свободного слота по умолчанию (llm.queue_timeout)
+const DefaultQueueTimeout = 30 * time.Second
+
+// ErrQueueTimeout запрос не дождался свободного слота; оборачивается вместе с ErrRateLimited
+var ErrQueueTimeout = errors.New("timed out waiting for a free LLM request slot")
+
+// LimiterStats загрузка пула одновременных запросов клиента
+type LimiterStats struct {
+	Limit    int
+	InFlight int64
+	Queued   int64
+	Rejected uint64 // запросы, не дождавшиеся слота за queue_timeout
+}
+
+// requestLimiter ограничивает число одновременных запросов клиента к API модели.
+// Запрос сверх лимита ждёт слот не дольше queueTimeout.
+type requestLimiter struct {
+	slots        chan struct{}
+	queueTimeout time.Duration
+
+	inFlight atomic.Int64
+	queued   atomic.Int64
+	rejected atomic.Uint64
+}
+
+func newRequestLimiter(limit int, queueTimeout time.Duration) *requestLimiter {
+	return &requestLimiter{
+		slots:        make(chan struct{}, limit),
+		queueTimeout: queueTimeout,
+	}
+}
+
+// acquire занимает слот; при таймауте очереди возвращает ErrRateLimited и ErrQueueTimeout
+func (l *requestLimiter) acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+		l.inFlight.Add(1)
+		return nil
+	default:
+	}
+
+	l.queued.Add(1)
+	defer l.queued.Add(-1)
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case l.slots <- struct{}{}:
+		l.inFlight.Add(1)
+		return nil
+	case <-timer.C:
+		l.rejected.Add(1)
+		return fmt.Errorf("%w: %w: all %d slots are busy, waited %s", ErrRateLimited, ErrQueueTimeout, cap(l.slots), l.queueTimeout)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release освобождает слот, занятый acquire
+func (l *requestLimiter) release() {
+	l.inFlight.Add(-1)
+	<-l.slots
+}
+
+func (l *requestLimiter) stats() LimiterStats {
+	return LimiterStats{
+		Limit:    cap(l.slots),
+		InFlight: l.inFlight.Load(),
+		Queued:   l.queued.Load(),
+		Rejected: l.rejected.Load(),
+	}
+}
@@ -0,0 +1,18 @@
+package providers
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrEmbeddingsNotSupported провайдер не умеет вычислять эмбеддинги текста
+var ErrEmbeddingsNotSupported = errors.New("embeddings are not supported by provider")
+
+// DefaultGeminiEmbeddingModel модель эмбеддингов Gemini по умолчанию
+const DefaultGeminiEmbeddingModel = "text-embedding-004"
+
+// Embedder опциональный интерфейс провайдеров, вычисляющих эмбеддинги текста
+type Embedder interface {
+	// Embed возвращает по одному вектору на каждый текст в том же порядке
+	Embed(ctx context.Context, model string, texts []string) ([][]float32, error)
+}
@@ -0,0 +1,54 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+)
+
+// Классы ошибок провайдеров. Конкретная ошибка API оборачивается в один из них,
+// чтобы вызывающая сторона различала ошибки через errors.Is.
+var (
+	ErrAPIKeyNotSet        = errors.New("API key is not set")
+	ErrUnauthorized        = errors.New("API key rejected by LLM API")
+	ErrRateLimited         = errors.New("rate limited by API")
+	ErrInsufficientCredits = errors.New("insufficient credits")
+	ErrUpstream            = errors.New("LLM API request failed")
+	ErrMCPUnavailable      = errors.New("MCP server unavailable")
+)
+
+// statusErrorClass класс ошибки API модели по HTTP статусу ответа
+func statusErrorClass(status int) error {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusPaymentRequired:
+		return ErrInsufficientCredits
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	}
+	return ErrUpstream
+}
+
+// apiError оборачивает ошибку запроса к API модели в класс по статусу googleapi; ошибки без статуса
+// (сеть, разбор ответа) относятся к ErrUpstream. Блокировка фильтрами и отмена запроса не меняются.
+func apiError(err error) error {
+	if err == nil || errors.Is(err, ErrContentBlocked) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	class := ErrUpstream
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		class = statusErrorClass(apiErr.Code)
+		// Gemini отвечает на неверный ключ статусом 400 INVALID_ARGUMENT
+		if apiErr.Code == http.StatusBadRequest && strings.Contains(strings.ToLower(apiErr.Message), "api key") {
+			class = ErrUnauthorized
+		}
+	}
+	return fmt.Errorf("%w: %w", class, err)
+}
@@ -7,6 +7,25 @@ import (
 	"go.uber.org/zap"
 )
 
+// Имена провайдеров llm.provider
+const (
+	ProviderGemini     = "gemini"
+	ProviderOpenRouter = "openrouter"
+)
+
+// DefaultOpenRouterBaseURL адрес API OpenRouter, если llm.base_url не задан
+const DefaultOpenRouterBaseURL = "https://openrouter.ai/api/v1"
+
+// SupportedProviders провайдеры, которые умеет создавать фабрика
+func SupportedProviders() []string {
+	return []string{ProviderGemini, ProviderOpenRouter}
+}
+
+// HasMCPSupport сообщает, поддерживает ли провайдер инструменты MCP
+func HasMCPSupport(provider string) bool {
+	return strings.ToLower(provider) == ProviderGemini
+}
+
 type Factory struct {
 	logger *zap.Logger
 }
@@ -21,7 +40,7 @@ func (f *Factory) CreateProvider(config Config) (Provider, error) {
 	provider := strings.ToLower(config.Provider)
 
 	switch provider {
-	case "gemini":
+	case ProviderGemini:
 		// Создаем MCP конфигурацию (должна передаваться извне)
 		// Это временное решение - в реальности конфигурация будет передаваться из main.go
 		mcpConfig := MCPProviderConfig{
@@ -31,23 +50,33 @@ func (f *Factory) CreateProvider(config Config) (Provider, error) {
 			HTTPHeaders:      nil,
 		}
 		return NewMCPGeminiProvider(config, mcpConfig, f.logger)
+	case ProviderOpenRouter:
+		if strings.TrimSpace(config.BaseURL) == "" {
+			config.BaseURL = DefaultOpenRouterBaseURL
+		}
+		config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+		return NewOpenRouterProvider(config, f.logger)
 	default:
-		return nil, fmt.Errorf("unsupported provider: %s (only 'gemini' is supported)", config.Provider)
+		return nil, fmt.Errorf("unsupported provider: %s (supported: %s)", config.Provider, strings.Join(SupportedProviders(), ", "))
 	}
 }
 
 func (f *Factory) GetSupportedProviders() []string {
-	return []string{"gemini"}
+	return SupportedProviders()
 }
 
-// CreateProviderWithMCP создает провайдер с MCP конфигурацией
+// CreateProviderWithMCP создает провайдер с MCP конфигурацией.
+// Провайдеры без поддержки MCP создаются через CreateProvider, MCP конфигурация для них не используется.
 func (f *Factory) CreateProviderWithMCP(config Config, mcpConfig MCPProviderConfig) (Provider, error) {
-	provider := strings.ToLower(config.Provider)
-
-	switch provider {
-	case "gemini":
+	if HasMCPSupport(config.Provider) {
 		return NewMCPGeminiProvider(config, mcpConfig, f.logger)
-	default:
-		return nil, fmt.Errorf("unsupported provider: %s (only 'gemini' is supported)", config.Provider)
 	}
+
+	provider, err := f.CreateProvider(config)
+	if err != nil {
+		return nil, err
+	}
+	f.logger.Info("Provider has no MCP support, MCP configuration is ignored",
+		zap.String("provider", provider.GetName()))
+	return provider, nil
 }
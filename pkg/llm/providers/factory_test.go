@@ -0,0 +1,66 @@
+package providers
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestFactoryCreatesProviders(t *testing.T) {
+	promptPath := filepath.Join(t.TempDir(), "system_prompt.txt")
+	if err := os.WriteFile(promptPath, []byte("You are a test assistant."), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mcpConfig := MCPProviderConfig{ServerURL: "http://localhost:8000/mcp", SystemPromptPath: promptPath, MaxIterations: 3}
+	factory := NewFactory(zap.NewNop())
+
+	tests := []struct {
+		name    string
+		config  Config
+		want    string
+		baseURL string // ожидаемый адрес OpenRouter
+		mcp     bool
+	}{
+		{"gemini", Config{Provider: "gemini", APIKey: "key", Model: "gemini-2.0-flash"}, ProviderGemini, "", true},
+		{"openrouter default URL", Config{Provider: "OpenRouter", APIKey: "key", Model: "openai/gpt-4o"}, ProviderOpenRouter, DefaultOpenRouterBaseURL, false},
+		{"openrouter custom URL", Config{Provider: "openrouter", APIKey: "key", Model: "openai/gpt-4o", BaseURL: "http://proxy/v1/"}, ProviderOpenRouter, "http://proxy/v1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := factory.CreateProviderWithMCP(tt.config, mcpConfig)
+			if err != nil {
+				t.Fatalf("CreateProviderWithMCP: %v", err)
+			}
+			if provider.GetName() != tt.want {
+				t.Errorf("provider = %s, want %s", provider.GetName(), tt.want)
+			}
+			if _, ok := provider.(MCPProber); ok != tt.mcp {
+				t.Errorf("MCP support = %v, want %v", ok, tt.mcp)
+			}
+			if openRouter, ok := provider.(*OpenRouterProvider); ok && openRouter.baseURL != tt.baseURL {
+				t.Errorf("base URL = %q, want %q", openRouter.baseURL, tt.baseURL)
+			}
+			if HasMCPSupport(tt.config.Provider) != tt.mcp {
+				t.Errorf("HasMCPSupport(%q) = %v", tt.config.Provider, !tt.mcp)
+			}
+		})
+	}
+}
+
+func TestFactoryRejectsInvalidConfig(t *testing.T) {
+	factory := NewFactory(zap.NewNop())
+
+	if _, err := factory.CreateProvider(Config{Provider: "unknown", APIKey: "key"}); err == nil || !strings.Contains(err.Error(), "gemini, openrouter") {
+		t.Errorf("unknown provider: err = %v", err)
+	}
+	if _, err := factory.CreateProvider(Config{Provider: "openrouter", Model: "openai/gpt-4o"}); !errors.Is(err, ErrAPIKeyNotSet) {
+		t.Errorf("OpenRouter without API key: err = %v, want ErrAPIKeyNotSet", err)
+	}
+	if _, err := factory.CreateProviderWithMCP(Config{Provider: "gemini", Model: "gemini-2.0-flash"}, MCPProviderConfig{}); !errors.Is(err, ErrAPIKeyNotSet) {
+		t.Errorf("Gemini without API key: err = %v, want ErrAPIKeyNotSet", err)
+	}
+}
@@ -9,53 +9,125 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
+
+	"LLM_Chat/pkg/mcp/schema"
+	"LLM_Chat/pkg/redact"
 
 	"github.com/google/generative-ai-go/genai"
-	"github.com/google/jsonschema-go/jsonschema"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
 )
 
 type MCPGeminiProvider struct {
 	// MCP components
-	mcpClient   *mcp.Client
-	session     *mcp.ClientSession
+	servers     []*mcpServer // один сервер без префикса или несколько серверов с префиксами имён инструментов
 	available   []*mcp.Tool
 	geminiTools []*genai.FunctionDeclaration
+	toolsMu     sync.RWMutex // защищает available, geminiTools, model.Tools и инструменты серверов при обновлении списка
+	resourcesMu sync.RWMutex // защищает ресурсы и промпты серверов
 
 	// Gemini components
 	genClient *genai.Client
 	model     *genai.GenerativeModel
+	grounding *groundingTransport // поиск Google на уровне REST запросов
 
 	// Configuration
-	mcpServerURL     string
-	systemPromptPath string
-	maxIterations    int
-	httpHeaders      map[string]string
-	geminiAPIKey     string
-	geminiBaseURL    string
-	geminiModel      string
-	systemPrompt     string
+	systemPromptPath  string
+	maxIterations     int
+	maxResultBytes    int           // предел размера результата инструмента в истории модели
+	maxToolCalls      int           // одновременные вызовы инструментов из одного ответа модели
+	toolCallTimeout   time.Duration // 0 - вызов ограничен только контекстом запроса
+	toolFilter        ToolFilter    // инструменты, доступные модели
+	refreshInterval   time.Duration // периодическое обновление списка инструментов; 0 - только по уведомлению
+	reconnectAttempts int           // попытки переподключения при потере соединения с MCP сервером
+	geminiAPIKey      string
+	geminiBaseURL     string
+	geminiModel       string
+	systemPrompt      string
+	googleSearch      bool // поиск Google по умолчанию для запросов без явного флага
+	generation        GenerationConfig
+	toolMode          string                 // ToolModeAuto, ToolModeAny или ToolModeNone
+	safetySettings    []*genai.SafetySetting // пороги фильтров безопасности; пусто - пороги модели
+	retry             RetryConfig            // повторы хода при временных ошибках API
+	timeout           time.Duration          // предел одного запроса вместе с циклом инструментов
+
+	// Состояние для проверок готовности
+	statusMu      sync.Mutex
+	lastError     string
+	lastErrorAt   time.Time
+	lastSuccessAt time.Time
+
+	// progressCalls токены прогресса выполняющихся вызовов (string -> progressTarget)
+	progressCalls sync.Map
+	progressSeq   atomic.Int64
+
+	toolObserver ToolCallObserver
+	toolRecorder ToolInvocationRecorder
+	redactor     *redact.Redactor // nil - маскирование выключено
+
+	// lifecycleMu удерживается на чтение запросами и на запись при переинициализации
+	lifecycleMu    sync.RWMutex
+	reinitializing atomic.Bool
+
+	// initMu одна инициализация за раз: параллельные первые запросы ждут её, а не создают
+	// свои сессии MCP и клиенты Gemini. Порядок блокировок: lifecycleMu, initMu, connectMu сервера.
+	initMu      sync.Mutex
+	initialized atomic.Bool // для Status без ожидания initMu
 
 	logger *zap.Logger
 }
 
 func NewMCPGeminiProvider(config Config, mcpConfig MCPProviderConfig, logger *zap.Logger) (Provider, error) {
-	if config.Timeout == 0 {
-		config.Timeout = 60 * time.Second
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+
+	if mcpConfig.MaxToolResultBytes == 0 {
+		mcpConfig.MaxToolResultBytes = DefaultMaxToolResultBytes
+	}
+	if mcpConfig.ReconnectAttempts <= 0 {
+		mcpConfig.ReconnectAttempts = DefaultMCPReconnectAttempts
+	}
+	if mcpConfig.MaxConcurrentToolCalls <= 0 {
+		mcpConfig.MaxConcurrentToolCalls = DefaultMaxConcurrentToolCalls
 	}
 
 	provider := &MCPGeminiProvider{
-		mcpServerURL:     mcpConfig.ServerURL,
-		systemPromptPath: mcpConfig.SystemPromptPath,
-		maxIterations:    mcpConfig.MaxIterations,
-		httpHeaders:      mcpConfig.HTTPHeaders,
-		geminiAPIKey:     config.APIKey,
-		geminiBaseURL:    config.BaseURL, // ← ДОБАВИТЬ ЭТО
-		geminiModel:      config.Model,
-		logger:           logger.With(zap.String("provider", "gemini-mcp")),
+		systemPromptPath:  mcpConfig.SystemPromptPath,
+		maxIterations:     mcpConfig.MaxIterations,
+		maxResultBytes:    mcpConfig.MaxToolResultBytes,
+		maxToolCalls:      mcpConfig.MaxConcurrentToolCalls,
+		toolCallTimeout:   mcpConfig.ToolCallTimeout,
+		toolFilter:        NewToolFilter(mcpConfig.AllowedTools, mcpConfig.DeniedTools),
+		refreshInterval:   mcpConfig.ToolsRefreshInterval,
+		reconnectAttempts: mcpConfig.ReconnectAttempts,
+		geminiAPIKey:      config.APIKey,
+		geminiBaseURL:     config.BaseURL, // ← ДОБАВИТЬ ЭТО
+		geminiModel:       config.Model,
+		googleSearch:      mcpConfig.GoogleSearch,
+		generation:        config.Generation,
+		toolMode:          config.ToolMode,
+		retry:             config.Retry.withDefaults(),
+		timeout:           config.Timeout,
+		logger:            logger.With(zap.String("provider", "gemini-mcp")),
+	}
+
+	safetySettings, err := geminiSafetySettings(config.Safety)
+	if err != nil {
+		return nil, fmt.Errorf("invalid safety config: %w", err)
+	}
+	provider.safetySettings = safetySettings
+
+	for _, serverConfig := range mcpConfig.ServerConfigs() {
+		provider.servers = append(provider.servers, newMCPServer(serverConfig, provider.logger))
 	}
 
 	if err := provider.ValidateConfig(); err != nil {
@@ -65,11 +137,31 @@ func NewMCPGeminiProvider(config Config, mcpConfig MCPProviderConfig, logger *za
 	return provider, nil
 }
 
+// toolsRefreshTimeout время на повторный запрос списка инструментов
+const toolsRefreshTimeout = 10 * time.Second
+
+// DefaultMaxConcurrentToolCalls одновременные вызовы инструментов по умолчанию
+const DefaultMaxConcurrentToolCalls = 4
+
 type MCPProviderConfig struct {
-	ServerURL        string
-	SystemPromptPath string
-	MaxIterations    int
-	HTTPHeaders      map[string]string
+	Transport              string // MCPTransportHTTP, MCPTransportStdio или пусто (см. ResolveMCPTransport)
+	ServerURL              string
+	ServerPath             string            // stdio: исполняемый файл или скрипт сервера
+	PythonPath             string            // stdio: интерпретатор для ServerPath (необязательно)
+	ServerArgs             []string          // stdio: аргументы сервера
+	ServerEnv              map[string]string // stdio: дополнительные переменные окружения сервера
+	SystemPromptPath       string
+	MaxIterations          int
+	MaxToolResultBytes     int           // 0 - DefaultMaxToolResultBytes, отрицательное значение отключает обрезку
+	MaxConcurrentToolCalls int           // 0 - DefaultMaxConcurrentToolCalls
+	ToolCallTimeout        time.Duration // время на один вызов инструмента; 0 - без отдельного ограничения
+	AllowedTools           []string      // если задан, модели доступны только эти инструменты
+	DeniedTools            []string      // инструменты, скрытые от модели
+	ToolsRefreshInterval   time.Duration // период обновления списка инструментов для серверов без уведомлений
+	ReconnectAttempts      int           // 0 - DefaultMCPReconnectAttempts
+	HTTPHeaders            map[string]string
+	GoogleSearch           bool              // grounding поиском Google по умолчанию
+	Servers                []MCPServerConfig // несколько серверов; поля подключения выше тогда не используются
 }
 
 func (p *MCPGeminiProvider) GetName() string {
@@ -78,13 +170,31 @@ func (p *MCPGeminiProvider) GetName() string {
 
 func (p *MCPGeminiProvider) ValidateConfig() error {
 	if p.geminiAPIKey == "" {
-		return fmt.Errorf("Gemini API key is required")
+		return fmt.Errorf("Gemini %w", ErrAPIKeyNotSet)
 	}
 	if p.geminiModel == "" {
 		return fmt.Errorf("Gemini model is required")
 	}
-	if p.mcpServerURL == "" {
-		return fmt.Errorf("MCP server URL is required")
+	if err := p.generation.Validate(); err != nil {
+		return fmt.Errorf("invalid generation config: %w", err)
+	}
+	if err := ValidateToolMode(p.toolMode); err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(p.servers))
+	for _, srv := range p.servers {
+		if p.namedServers() {
+			if err := ValidateMCPServerName(srv.cfg.Name); err != nil {
+				return err
+			}
+			if seen[srv.cfg.Name] {
+				return fmt.Errorf("duplicate MCP server name: %s", srv.cfg.Name)
+			}
+			seen[srv.cfg.Name] = true
+		}
+		if err := srv.validate(); err != nil {
+			return err
+		}
 	}
 	if p.systemPromptPath == "" {
 		return fmt.Errorf("system prompt path is required")
@@ -95,6 +205,19 @@ func (p *MCPGeminiProvider) ValidateConfig() error {
 	return nil
 }
 
+// geminiOutputTokenLimits максимальная длина ответа поддерживаемых моделей в токенах
+var geminiOutputTokenLimits = map[string]int{
+	"gemini-2.5-flash": 65536,
+	"gemini-2.0-flash": 8192,
+	"gemini-1.5-pro":   8192,
+	"gemini-1.5-flash": 8192,
+}
+
+// OutputTokenLimit возвращает предел max_output_tokens модели (0 - неизвестен)
+func (p *MCPGeminiProvider) OutputTokenLimit(model string) int {
+	return geminiOutputTokenLimits[model]
+}
+
 func (p *MCPGeminiProvider) GetSupportedModels() []string {
 	return []string{
 		"gemini-2.5-flash",
@@ -134,44 +257,143 @@ func (p *MCPGeminiProvider) loadSystemPrompt() error {
 	return nil
 }
 
-// initializeMCP инициализирует MCP соединение
-func (p *MCPGeminiProvider) initializeMCP(ctx context.Context) error {
-	p.logger.Info("Connecting to MCP server", zap.String("url", p.mcpServerURL))
+// connectServer подключается к MCP серверу и загружает его инструменты
+func (p *MCPGeminiProvider) connectServer(ctx context.Context, srv *mcpServer) error {
+	srv.logger.Info("Connecting to MCP server",
+		zap.String("transport", srv.cfg.Transport),
+		zap.String("endpoint", srv.mcpEndpoint()))
 
-	transport := &mcp.StreamableClientTransport{
-		Endpoint:   strings.TrimRight(p.mcpServerURL, "/"),
-		HTTPClient: p.httpClientWithHeaders(p.httpHeaders),
-	}
+	transport := srv.mcpTransport()
 
 	impl := &mcp.Implementation{Name: "go-mcp-client", Version: "0.2.0"}
-	client := mcp.NewClient(impl, &mcp.ClientOptions{})
+	client := mcp.NewClient(impl, &mcp.ClientOptions{
+		ToolListChangedHandler: func(_ context.Context, req *mcp.ToolListChangedRequest) {
+			// Обработчик вызывается из цикла чтения сессии, поэтому список запрашивается отдельно
+			go p.refreshTools(srv, req.Session, "list_changed")
+		},
+		ProgressNotificationHandler: func(_ context.Context, req *mcp.ProgressNotificationClientRequest) {
+			p.handleToolProgress(req.Params)
+		},
+	})
 
 	session, err := client.Connect(ctx, transport, nil)
 	if err != nil {
 		return fmt.Errorf("failed to connect to MCP server: %w", err)
 	}
-	p.mcpClient = client
-	p.session = session
 
 	// Получаем список инструментов
-	ltr, err := p.session.ListTools(ctx, &mcp.ListToolsParams{})
+	ltr, err := session.ListTools(ctx, &mcp.ListToolsParams{})
 	if err != nil {
-		p.session.Close()
+		session.Close()
 		return fmt.Errorf("failed to list MCP tools: %w", err)
 	}
-	p.available = ltr.Tools
+	available, filtered := p.toolFilter.Apply(srv.exposedTools(ltr.Tools))
 
-	p.logger.Info("MCP tools loaded", zap.Int("count", len(p.available)))
-	for _, t := range p.available {
-		p.logger.Debug("Available tool", zap.String("name", t.Name), zap.String("description", t.Description))
+	// Ресурсы и промпты не обязательны для работы модели: ошибка списка только логируется
+	if err := p.loadServerResources(ctx, srv, session); err != nil {
+		srv.logger.Warn("Failed to list MCP resources", zap.Error(err))
+	}
+	if err := p.loadServerPrompts(ctx, srv, session); err != nil {
+		srv.logger.Warn("Failed to list MCP prompts", zap.Error(err))
+	}
+
+	srv.sessionMu.Lock()
+	defer srv.sessionMu.Unlock()
+
+	srv.logger.Info("MCP tools loaded", zap.Int("count", len(available)), zap.Strings("filtered", filtered))
+	for _, t := range available {
+		srv.logger.Debug("Available tool", zap.String("name", t.Name), zap.String("description", t.Description))
 	}
 
 	// Конвертируем инструменты для Gemini
-	p.geminiTools = p.convertMCPToGeminiTools(p.available)
+	p.setServerTools(srv, available)
+
+	srv.client = client
+	srv.session = session
+	if p.refreshInterval > 0 {
+		srv.refreshStop = make(chan struct{})
+		go p.refreshToolsPeriodically(srv, session, srv.refreshStop)
+	}
 
 	return nil
 }
 
+// setServerTools заменяет инструменты сервера и пересобирает общий список и объявления функций Gemini.
+// Запросы, уже начавшие генерацию, продолжают со своей копией списка.
+func (p *MCPGeminiProvider) setServerTools(srv *mcpServer, tools []*mcp.Tool) {
+	p.toolsMu.Lock()
+	defer p.toolsMu.Unlock()
+
+	srv.tools = tools
+	var available []*mcp.Tool
+	for _, s := range p.servers {
+		available = append(available, s.tools...)
+	}
+	declarations := schema.ToGeminiFunctionDeclarations(available)
+
+	p.available = available
+	p.geminiTools = declarations
+	if p.model != nil {
+		p.model.Tools = []*genai.Tool{{FunctionDeclarations: declarations}}
+	}
+}
+
+// toolDeclarations текущие объявления функций Gemini
+func (p *MCPGeminiProvider) toolDeclarations() []*genai.FunctionDeclaration {
+	p.toolsMu.RLock()
+	defer p.toolsMu.RUnlock()
+	return p.geminiTools
+}
+
+// refreshTools заново запрашивает список инструментов сервера (по уведомлению tools/list_changed
+// или по таймеру). Устаревшая после переподключения сессия игнорируется.
+func (p *MCPGeminiProvider) refreshTools(srv *mcpServer, session *mcp.ClientSession, reason string) {
+	if session == nil || p.reinitializing.Load() {
+		return
+	}
+
+	p.lifecycleMu.RLock()
+	defer p.lifecycleMu.RUnlock()
+
+	// Обновление не пересекается с переподключением, заменяющим сессию и её инструменты
+	srv.connectMu.Lock()
+	defer srv.connectMu.Unlock()
+
+	if srv.currentSession() != session {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), toolsRefreshTimeout)
+	defer cancel()
+
+	available, filtered, err := p.reloadServerTools(ctx, srv, session)
+	if err != nil {
+		srv.logger.Warn("Failed to refresh MCP tools", zap.String("reason", reason), zap.Error(err))
+		return
+	}
+
+	srv.logger.Info("MCP tools refreshed",
+		zap.String("reason", reason),
+		zap.Int("count", len(available)),
+		zap.Strings("filtered", filtered),
+	)
+}
+
+// refreshToolsPeriodically обновляет список инструментов для серверов, не присылающих tools/list_changed
+func (p *MCPGeminiProvider) refreshToolsPeriodically(srv *mcpServer, session *mcp.ClientSession, stop <-chan struct{}) {
+	ticker := time.NewTicker(p.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.refreshTools(srv, session, "interval")
+		}
+	}
+}
+
 // initializeGemini инициализирует Gemini клиент
 func (p *MCPGeminiProvider) initializeGemini(ctx context.Context) error {
 	p.logger.Info("Initializing Gemini client",
@@ -187,14 +409,28 @@ func (p *MCPGeminiProvider) initializeGemini(ctx context.Context) error {
 		p.logger.Info("Using custom Gemini endpoint", zap.String("endpoint", endpoint))
 	}
 
+	// Транспорт поиска Google встраивается под стандартный транспорт с API ключом,
+	// поэтому авторизация запросов не меняется
+	grounding := &groundingTransport{next: http.DefaultTransport}
+	transport, err := htransport.NewTransport(ctx, grounding, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create Gemini transport: %w", err)
+	}
+	opts = append(opts, option.WithHTTPClient(&http.Client{Transport: transport}))
+
 	genClient, err := genai.NewClient(ctx, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create Gemini client: %w", err)
 	}
 	p.genClient = genClient
+	p.grounding = grounding
 
-	p.model = p.genClient.GenerativeModel(p.geminiModel)
-	p.model.Tools = []*genai.Tool{{FunctionDeclarations: p.geminiTools}}
+	model := p.genClient.GenerativeModel(p.geminiModel)
+	p.configureModel(model)
+	p.toolsMu.Lock()
+	model.Tools = []*genai.Tool{{FunctionDeclarations: p.geminiTools}}
+	p.model = model
+	p.toolsMu.Unlock()
 
 	return nil
 }
@@ -203,7 +439,10 @@ func (p *MCPGeminiProvider) initializeGemini(ctx context.Context) error {
 // Добавить в ensureInitialized метод более детальное логирование:
 
 func (p *MCPGeminiProvider) ensureInitialized(ctx context.Context) error {
-	if p.session != nil && p.genClient != nil && p.systemPrompt != "" {
+	p.initMu.Lock()
+	defer p.initMu.Unlock()
+
+	if p.mcpConnected() && p.genClient != nil && p.systemPrompt != "" {
 		return nil // уже инициализировано
 	}
 
@@ -220,13 +459,13 @@ func (p *MCPGeminiProvider) ensureInitialized(ctx context.Context) error {
 	}
 
 	// Инициализируем MCP
-	if p.session == nil {
-		p.logger.Info("Initializing MCP connection", zap.String("server_url", p.mcpServerURL))
+	if !p.mcpConnected() {
+		p.logger.Info("Initializing MCP connection", zap.Int("servers", len(p.servers)))
 		if err := p.initializeMCP(ctx); err != nil {
 			p.logger.Error("Failed to initialize MCP", zap.Error(err))
-			return err
+			return fmt.Errorf("%w: %w", ErrMCPUnavailable, err)
 		}
-		p.logger.Info("MCP initialized successfully", zap.Int("tools_count", len(p.available)))
+		p.logger.Info("MCP initialized successfully", zap.Int("tools_count", len(p.toolDeclarations())))
 	}
 
 	// Инициализируем Gemini
@@ -239,69 +478,156 @@ func (p *MCPGeminiProvider) ensureInitialized(ctx context.Context) error {
 		p.logger.Info("Gemini initialized successfully")
 	}
 
+	p.initialized.Store(true)
 	p.logger.Info("MCP Gemini initialization completed successfully")
 	return nil
 }
 
 func (p *MCPGeminiProvider) ChatCompletion(ctx context.Context, messages []Message) (*ChatResponse, error) {
+	return p.complete(ctx, messages, nil)
+}
+
+// complete выполняет запрос; onText, если задан, получает текст ответа по мере генерации
+func (p *MCPGeminiProvider) complete(ctx context.Context, messages []Message, onText func(string)) (*ChatResponse, error) {
+	if p.reinitializing.Load() {
+		return nil, ErrProviderReinitializing
+	}
+
+	p.lifecycleMu.RLock()
+	defer p.lifecycleMu.RUnlock()
+
+	// Предел действует независимо от контекста вызывающей стороны
+	callCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	resp, err := p.chatCompletion(callCtx, messages, onText)
+	if err != nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("%w: %w", context.DeadlineExceeded, err)
+		}
+		err = fmt.Errorf("request exceeded llm timeout %s: %w", p.timeout, err)
+	}
+	p.recordResult(err)
+	return resp, err
+}
+
+// Status возвращает состояние клиента Gemini без обращения к API
+func (p *MCPGeminiProvider) Status() ProviderStatus {
+	p.statusMu.Lock()
+	defer p.statusMu.Unlock()
+
+	return ProviderStatus{
+		Initialized:   p.initialized.Load(),
+		LastError:     p.lastError,
+		LastErrorAt:   p.lastErrorAt,
+		LastSuccessAt: p.lastSuccessAt,
+	}
+}
+
+// recordResult запоминает результат последнего запроса к Gemini
+func (p *MCPGeminiProvider) recordResult(err error) {
+	p.statusMu.Lock()
+	defer p.statusMu.Unlock()
+
+	if err != nil {
+		p.lastError = err.Error()
+		p.lastErrorAt = time.Now()
+		return
+	}
+	p.lastSuccessAt = time.Now()
+}
+
+func (p *MCPGeminiProvider) chatCompletion(ctx context.Context, messages []Message, onText func(string)) (*ChatResponse, error) {
 	if err := p.ensureInitialized(ctx); err != nil {
 		return nil, fmt.Errorf("initialization failed: %w", err)
 	}
 
-	p.model.SystemInstruction = &genai.Content{Parts: []genai.Part{genai.Text(p.systemPrompt)}}
-	p.model.Tools = []*genai.Tool{{FunctionDeclarations: p.geminiTools}}
+	opts := RequestOptionsFromContext(ctx)
+	modelName, model := p.generativeModel(ctx)
+	generation := p.applyGeneration(model, opts)
+	model.SystemInstruction = &genai.Content{Parts: []genai.Part{genai.Text(p.systemInstruction(messages))}}
+	toolMode := p.toolMode
+	if opts.ToolMode != "" {
+		toolMode = opts.ToolMode
+	}
+	model.Tools, model.ToolConfig = nil, nil
+	if declarations := p.toolDeclarations(); toolMode != ToolModeNone && len(declarations) > 0 {
+		model.Tools = []*genai.Tool{{FunctionDeclarations: declarations}}
+		model.ToolConfig = geminiToolConfig(toolMode)
+	}
+
+	traces := &toolTraceCollector{}
+	ctx = withToolTraceCollector(ctx, traces)
+
+	var grounding *groundingCollector
+	if p.googleSearchEnabled(opts) {
+		grounding = &groundingCollector{}
+		ctx = withGroundingCollector(ctx, grounding)
+	}
 
+	messages, prefix := SplitAssistantPrefix(messages)
 	history, lastUser := p.toGenaiHistory(messages)
+	if prefix != "" {
+		// SDK всегда завершает запрос репликой пользователя, поэтому начало ответа
+		// становится последней репликой модели в истории, а модель просят её продолжить
+		history = append(history, lastUser, &genai.Content{Role: "model", Parts: []genai.Part{genai.Text(prefix)}})
+		lastUser = &genai.Content{Role: "user", Parts: []genai.Part{genai.Text(prefillContinuePrompt)}}
+	}
 
-	chat := p.model.StartChat()
+	chat := model.StartChat()
 	chat.History = history
 
+	maxIterations := p.maxIterations
+	if opts.MaxIterations > 0 {
+		maxIterations = opts.MaxIterations
+	}
+
 	var finalAnswer string
-	var totalTokens int
+	var usage Usage
+	var iterations int
+	var truncated bool // ответ обрезан по лимиту max_output_tokens
+
+	// preamble текст ходов с вызовами функций, который уже передан в onText (см. sendMessage)
+	var preamble string
 
-	resp, err := chat.SendMessage(ctx, lastUser.Parts...)
+	resp, sent, err := p.sendWithRetry(ctx, chat, onText, lastUser.Parts...)
 	if err != nil {
-		return nil, fmt.Errorf("Gemini generate error: %w", err)
+		return nil, fmt.Errorf("Gemini generate error: %w", apiError(err))
 	}
 
-	for i := 0; i < p.maxIterations; i++ {
+	for ; iterations < maxIterations; iterations++ {
 		if resp == nil || len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
-			return nil, errors.New("no response from Gemini")
+			return nil, fmt.Errorf("%w: no response from Gemini", ErrUpstream)
 		}
 
-		// Подсчёт usage (если SDK вернул)
-		if resp.UsageMetadata != nil {
-			totalTokens += int(resp.UsageMetadata.TotalTokenCount)
-		}
+		// usage суммируется по всем ходам, включая ходы с вызовами инструментов
+		addGeminiUsage(&usage, resp.UsageMetadata)
 
 		cand := resp.Candidates[0]
 		fcalls := cand.FunctionCalls()
 
 		if len(fcalls) > 0 {
-			for _, fc := range fcalls {
-				args := fc.Args
-				if args == nil {
-					args = map[string]any{}
-				}
-				result, err := p.callMCPTool(ctx, fc.Name, args)
-				if err != nil {
-					result = map[string]any{"error": err.Error()}
+			preamble += sent
+			results := p.callMCPTools(ctx, fcalls)
+
+			// Результаты отправляются следующим сообщением: SDK добавит их в историю
+			// одним ходом после вызова функций, без пустого текстового хода
+			responses := make([]genai.Part, len(fcalls))
+			for i, fc := range fcalls {
+				responses[i] = genai.FunctionResponse{
+					Name:     fc.Name,
+					Response: results[i],
 				}
+			}
 
-				chat.History = append(chat.History, &genai.Content{
-					Role: "tool",
-					Parts: []genai.Part{
-						genai.FunctionResponse{
-							Name:     fc.Name,
-							Response: result,
-						},
-					},
-				})
+			if toolMode == ToolModeAny {
+				// Обязательный вызов выполнен; дальше модель может ответить текстом
+				model.ToolConfig = geminiToolConfig(ToolModeAuto)
 			}
 
-			resp, err = chat.SendMessage(ctx, genai.Text(""))
+			resp, sent, err = p.sendWithRetry(ctx, chat, onText, responses...)
 			if err != nil {
-				return nil, fmt.Errorf("Gemini generate error (after tool): %w", err)
+				return nil, fmt.Errorf("Gemini generate error (after tool): %w", apiError(err))
 			}
 			continue
 		}
@@ -318,19 +644,43 @@ func (p *MCPGeminiProvider) ChatCompletion(ctx context.Context, messages []Messa
 		}
 
 		finalAnswer = strings.Join(textParts, "\n")
+		if prefix != "" {
+			// Продолжение склеивается с префиксом, поэтому начальные пробелы и переводы строк сохраняются
+			finalAnswer = continuationText(cand.Content.Parts)
+		}
+		if preamble != "" {
+			// Текст ходов с вызовами функций уже передан клиенту, поэтому входит и в ответ
+			finalAnswer = strings.TrimRightFunc(preamble+sent, unicode.IsSpace)
+		}
 		if strings.TrimSpace(finalAnswer) == "" {
 			finalAnswer = "Нет текстового ответа"
+			emitText(onText, finalAnswer)
 		}
+		truncated = cand.FinishReason == genai.FinishReasonMaxTokens
+		iterations++
 		break
 	}
 
+	finishReason := "stop"
+	if truncated {
+		finishReason = FinishReasonMaxTokens
+	}
 	if finalAnswer == "" {
-		finalAnswer = "Достигнут лимит итераций без финального ответа"
+		// Финального ответа нет: возвращается причина max_iterations без текста,
+		// решение об ошибке принимает вызывающая сторона
+		finishReason = FinishReasonMaxIterations
+
+		p.logger.Warn("MCP tool loop reached iteration limit",
+			zap.String("model", modelName),
+			zap.Int("max_iterations", maxIterations),
+		)
 	}
 
+	groundingInfo, generation := p.groundingResult(grounding, generation)
+
 	return &ChatResponse{
 		ID:    fmt.Sprintf("mcp-gemini-%d", time.Now().Unix()),
-		Model: p.geminiModel,
+		Model: modelName,
 		Choices: []Choice{
 			{
 				Index: 0,
@@ -338,82 +688,594 @@ func (p *MCPGeminiProvider) ChatCompletion(ctx context.Context, messages []Messa
 					Role:    "assistant",
 					Content: finalAnswer,
 				},
-				FinishReason: "stop",
+				FinishReason: finishReason,
 			},
 		},
-		Usage: Usage{
-			PromptTokens:     0,
-			CompletionTokens: 0,
-			TotalTokens:      totalTokens,
-		},
+		Usage:      usage,
+		Generation: generation,
+		Grounding:  groundingInfo,
+		Iterations: iterations,
+		ToolCalls:  traces.result(),
 	}, nil
 }
 
+// googleSearchEnabled флаг запроса переопределяет настройку grounding.google_search
+func (p *MCPGeminiProvider) googleSearchEnabled(opts RequestOptions) bool {
+	if opts.GoogleSearch != nil {
+		return *opts.GoogleSearch
+	}
+	return p.googleSearch
+}
+
+// groundingResult возвращает источники поиска Google и добавляет предупреждение,
+// если инструмент поиска был отклонён и ответ получен без него
+func (p *MCPGeminiProvider) groundingResult(collector *groundingCollector, generation *GenerationInfo) (*GroundingInfo, *GenerationInfo) {
+	if collector == nil {
+		return nil, generation
+	}
+
+	info, reason := collector.result()
+	if reason == "" && p.grounding.rejected.Load() {
+		reason = "rejected by the API endpoint earlier"
+	}
+	if reason == "" {
+		return info, generation
+	}
+
+	p.logger.Warn("Google search grounding unavailable, answered without it", zap.String("reason", reason))
+	if generation == nil {
+		generation = &GenerationInfo{}
+	}
+	generation.Warnings = append(generation.Warnings, GroundingUnavailableWarning(p.GetName(), reason))
+	return info, generation
+}
+
+// prefillContinuePrompt реплика пользователя, после которой модель продолжает начатый ответ
+const prefillContinuePrompt = "Continue your previous reply exactly where it stopped. Do not repeat text that is already written."
+
+// SupportsPrefill начало ответа передаётся моделью последней репликой истории
+func (p *MCPGeminiProvider) SupportsPrefill() bool {
+	return true
+}
+
+// sendMessage отправляет реплику потоковым запросом и возвращает объединённый ответ хода
+// и текст, переданный в onText. Ход, первый фрагмент которого содержит вызов функции, считается
+// ходом инструментов, и его текст не передаётся; текст остальных ходов передаётся по мере
+// поступления. Если вызов функции появится позже, переданный текст войдёт в ответ (preamble).
+// SDK при объединении оставляет usage первого фрагмента, поэтому в ответ подставляется usage
+// последнего фрагмента, где он есть.
+func sendMessage(ctx context.Context, chat *genai.ChatSession, onText func(string), parts ...genai.Part) (*genai.GenerateContentResponse, string, error) {
+	iter := chat.SendMessageStream(ctx, parts...)
+
+	var usage *genai.UsageMetadata
+	var sent strings.Builder
+	decided, toolTurn := false, false
+	for {
+		resp, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			// Блокировка фильтрами безопасности возвращается типизированной ошибкой с категориями
+			return nil, sent.String(), contentBlockedError(err)
+		}
+
+		if resp.UsageMetadata != nil {
+			usage = resp.UsageMetadata
+		}
+		if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
+			continue
+		}
+
+		chunkParts := resp.Candidates[0].Content.Parts
+		if !decided {
+			decided, toolTurn = true, hasFunctionCall(chunkParts)
+		}
+		if toolTurn {
+			continue
+		}
+		for _, part := range chunkParts {
+			if t, ok := part.(genai.Text); ok && t != "" {
+				sent.WriteString(string(t))
+				emitText(onText, string(t))
+			}
+		}
+	}
+
+	merged := iter.MergedResponse()
+	if merged != nil && usage != nil {
+		merged.UsageMetadata = usage
+	}
+	return merged, sent.String(), nil
+}
+
+func hasFunctionCall(parts []genai.Part) bool {
+	for _, part := range parts {
+		if _, ok := part.(genai.FunctionCall); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// addGeminiUsage добавляет usage ответа Gemini к сумме запроса
+func addGeminiUsage(total *Usage, meta *genai.UsageMetadata) {
+	if meta == nil {
+		return
+	}
+	total.PromptTokens += int(meta.PromptTokenCount)
+	total.CompletionTokens += int(meta.CandidatesTokenCount)
+	total.TotalTokens += int(meta.TotalTokenCount)
+}
+
+func emitText(onText func(string), text string) {
+	if onText != nil {
+		onText(text)
+	}
+}
+
+// continuationText собирает текст продолжения без обрезки пробелов в начале
+func continuationText(parts []genai.Part) string {
+	var b strings.Builder
+	for _, part := range parts {
+		if t, ok := part.(genai.Text); ok {
+			b.WriteString(string(t))
+		}
+	}
+	return strings.TrimRightFunc(b.String(), unicode.IsSpace)
+}
+
+// generativeModel возвращает копию модели для запроса: переопределённой через RequestOptions
+// или модели из конфигурации. Настройки запроса меняют только копию.
+func (p *MCPGeminiProvider) generativeModel(ctx context.Context) (string, *genai.GenerativeModel) {
+	name, base := p.geminiModel, p.model
+	if opts := RequestOptionsFromContext(ctx); opts.Model != "" && opts.Model != p.geminiModel {
+		name, base = opts.Model, p.genClient.GenerativeModel(opts.Model)
+		p.configureModel(base)
+	}
+
+	p.toolsMu.RLock()
+	model := *base
+	p.toolsMu.RUnlock()
+	return name, &model
+}
+
+// configureModel применяет к модели параметры сэмплирования и пороги безопасности из конфигурации клиента
+func (p *MCPGeminiProvider) configureModel(model *genai.GenerativeModel) {
+	if len(p.safetySettings) > 0 {
+		model.SafetySettings = p.safetySettings
+	}
+
+	g := p.generation
+	if g.Temperature != nil {
+		model.SetTemperature(*g.Temperature)
+	}
+	if g.TopP != nil {
+		model.SetTopP(*g.TopP)
+	}
+	if g.TopK != nil {
+		model.SetTopK(*g.TopK)
+	}
+	if g.MaxOutputTokens > 0 {
+		model.SetMaxOutputTokens(g.MaxOutputTokens)
+	}
+	if len(g.StopSequences) > 0 {
+		model.StopSequences = g.StopSequences
+	}
+}
+
+// applyGeneration задаёт лимит длины ответа и стоп-последовательности и включает жадное декодирование
+// для воспроизводимых запросов. SDK Gemini не передаёт seed, поэтому он заменяется жадным декодированием
+// с предупреждением. Gemini не отличает остановку по последовательности от обычного завершения (STOP),
+// поэтому причина stop_sequence выставляется только клиентом, если последовательность попала в текст.
+func (p *MCPGeminiProvider) applyGeneration(model *genai.GenerativeModel, opts RequestOptions) *GenerationInfo {
+	if len(opts.Stop) > 0 {
+		model.StopSequences = opts.Stop
+	}
+
+	var info *GenerationInfo
+	if opts.MaxOutputTokens > 0 {
+		model.SetMaxOutputTokens(int32(opts.MaxOutputTokens))
+		info = &GenerationInfo{MaxOutputTokens: model.MaxOutputTokens}
+	}
+
+	if !opts.Reproducible() {
+		return info
+	}
+	if info == nil {
+		info = &GenerationInfo{}
+	}
+
+	model.SetTemperature(GreedyTemperature)
+	model.SetTopK(GreedyTopK)
+
+	info.Temperature = model.Temperature
+	info.TopK = model.TopK
+	info.Deterministic = true
+	if opts.Seed != nil {
+		info.Warnings = append(info.Warnings, NotSupportedWarning(p.GetName(), "seed", "temperature 0 and top_k 1"))
+	}
+
+	return info
+}
+
 func (p *MCPGeminiProvider) ChatCompletionStream(ctx context.Context, messages []Message) (<-chan StreamChunk, error) {
-	// Стриминг не поддерживается для MCP, используем обычную реализацию
 	chunks := make(chan StreamChunk, 1)
 
 	go func() {
 		defer close(chunks)
 
-		resp, err := p.ChatCompletion(ctx, messages)
+		// События вызова инструментов и их прогресс отправляются в поток до текстового ответа
+		toolCtx := withToolEventSink(ctx, func(event ToolCallEvent) {
+			select {
+			case chunks <- StreamChunk{ToolCall: &event}:
+			case <-ctx.Done():
+			}
+		}, func(progress ToolProgressInfo) {
+			select {
+			case chunks <- StreamChunk{ToolProgress: &progress}:
+			case <-ctx.Done():
+			}
+		})
+
+		// Ходы модели запрашиваются потоково: текст уходит клиенту по мере генерации,
+		// ходы с вызовами функций обрабатываются циклом инструментов без передачи текста
+		resp, err := p.complete(toolCtx, messages, func(text string) {
+			select {
+			case chunks <- StreamChunk{Content: text}:
+			case <-ctx.Done():
+			}
+		})
 		if err != nil {
 			chunks <- StreamChunk{Error: err}
 			return
 		}
 
+		done := StreamChunk{
+			Done:       true,
+			Generation: resp.Generation,
+			Grounding:  resp.Grounding,
+			Iterations: resp.Iterations,
+			ToolCalls:  resp.ToolCalls,
+			Usage:      &resp.Usage,
+		}
 		if len(resp.Choices) > 0 {
-			content := resp.Choices[0].Message.Content
-			// Разбиваем ответ на чанки для имитации стриминга
-			words := strings.Fields(content)
-			for i, word := range words {
-				if i > 0 {
-					chunks <- StreamChunk{Content: " "}
-				}
-				chunks <- StreamChunk{Content: word}
-			}
+			done.FinishReason = resp.Choices[0].FinishReason
 		}
-
-		chunks <- StreamChunk{Done: true}
+		chunks <- done
 	}()
 
 	return chunks, nil
 }
 
+// Embed вычисляет эмбеддинги текстов одним пакетным запросом к модели эмбеддингов
+func (p *MCPGeminiProvider) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	if p.reinitializing.Load() {
+		return nil, ErrProviderReinitializing
+	}
+
+	p.lifecycleMu.RLock()
+	defer p.lifecycleMu.RUnlock()
+
+	if err := p.ensureInitialized(ctx); err != nil {
+		return nil, fmt.Errorf("initialization failed: %w", err)
+	}
+	if model == "" {
+		model = DefaultGeminiEmbeddingModel
+	}
+
+	em := p.genClient.EmbeddingModel(model)
+	batch := em.NewBatch()
+	for _, text := range texts {
+		batch.AddContent(genai.Text(text))
+	}
+
+	resp, err := em.BatchEmbedContents(ctx, batch)
+	if err != nil {
+		return nil, fmt.Errorf("Gemini embedding error: %w", err)
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("Gemini returned %d embeddings for %d texts", len(resp.Embeddings), len(texts))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for i, embedding := range resp.Embeddings {
+		if embedding != nil {
+			vectors[i] = embedding.Values
+		}
+	}
+	return vectors, nil
+}
+
+// CountTokens считает входные токены сообщений моделью запроса. Системные сообщения передаются
+// системной инструкцией, остальные - частями одной реплики; объявления инструментов
+// и системный промпт из файла не учитываются.
+func (p *MCPGeminiProvider) CountTokens(ctx context.Context, messages []Message) (int, error) {
+	if len(messages) == 0 {
+		return 0, nil
+	}
+	if p.reinitializing.Load() {
+		return 0, ErrProviderReinitializing
+	}
+
+	p.lifecycleMu.RLock()
+	defer p.lifecycleMu.RUnlock()
+
+	if err := p.ensureInitialized(ctx); err != nil {
+		return 0, fmt.Errorf("initialization failed: %w", err)
+	}
+	return p.countTokens(ctx, messages)
+}
+
+func (p *MCPGeminiProvider) countTokens(ctx context.Context, messages []Message) (int, error) {
+	_, model := p.generativeModel(ctx)
+	model.Tools = nil
+	model.SystemInstruction = nil
+
+	var system []string
+	var parts []genai.Part
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			system = append(system, msg.Content)
+			continue
+		}
+		parts = append(parts, genai.Text(msg.Content))
+	}
+	switch {
+	case len(parts) == 0:
+		// Запрос без реплик API не принимает, поэтому системный текст считается репликой
+		parts = append(parts, genai.Text(strings.Join(system, "\n\n")))
+	case len(system) > 0:
+		model.SystemInstruction = &genai.Content{Parts: []genai.Part{genai.Text(strings.Join(system, "\n\n"))}}
+	}
+
+	resp, err := model.CountTokens(ctx, parts...)
+	if err != nil {
+		return 0, fmt.Errorf("Gemini count tokens error: %w", apiError(err))
+	}
+	return int(resp.TotalTokens), nil
+}
+
+// ProbeMCP проверяет доступность MCP серверов через ListTools и возвращает число инструментов, доступных модели
+func (p *MCPGeminiProvider) ProbeMCP(ctx context.Context) (int, error) {
+	counts, err := p.MCPToolCounts(ctx)
+	return counts.Exposed, err
+}
+
+// MCPToolCounts запрашивает инструменты MCP серверов и считает доступные модели и скрытые фильтром.
+// Неподключённые серверы подключаются; ошибка возвращается, только если недоступны все серверы.
+func (p *MCPGeminiProvider) MCPToolCounts(ctx context.Context) (MCPToolCounts, error) {
+	if p.reinitializing.Load() {
+		return MCPToolCounts{}, ErrProviderReinitializing
+	}
+
+	p.lifecycleMu.RLock()
+	defer p.lifecycleMu.RUnlock()
+
+	infos := make([]MCPServerInfo, len(p.servers))
+	errs := make([]error, len(p.servers))
+
+	var wg sync.WaitGroup
+	for i, srv := range p.servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			infos[i], errs[i] = p.serverToolCounts(ctx, srv)
+		}()
+	}
+	wg.Wait()
+
+	var counts MCPToolCounts
+	failed := 0
+	for i, info := range infos {
+		counts.Exposed += info.Exposed
+		counts.Filtered += info.Filtered
+		if errs[i] != nil {
+			failed++
+		}
+	}
+	if p.namedServers() {
+		counts.Servers = infos
+	}
+	if failed == len(p.servers) {
+		return counts, errors.Join(errs...)
+	}
+	return counts, nil
+}
+
+// SetToolCallObserver устанавливает наблюдателя за вызовами MCP инструментов
+func (p *MCPGeminiProvider) SetToolCallObserver(observer ToolCallObserver) {
+	p.toolObserver = observer
+}
+
+// SetToolInvocationRecorder устанавливает журнал аудита вызовов MCP инструментов
+func (p *MCPGeminiProvider) SetToolInvocationRecorder(recorder ToolInvocationRecorder) {
+	p.toolRecorder = recorder
+}
+
+// SetRedactor устанавливает маскирование персональных данных в журнале аудита и логах инструментов
+func (p *MCPGeminiProvider) SetRedactor(redactor *redact.Redactor) {
+	p.redactor = redactor
+}
+
+// recordToolInvocation передаёт выполненный вызов инструмента в журнал аудита.
+// Аргументы и текст ошибки маскируются; хэш считается по исходному результату.
+func (p *MCPGeminiProvider) recordToolInvocation(ctx context.Context, name string, args map[string]any, start time.Time, result any, err error) {
+	if p.toolRecorder == nil {
+		return
+	}
+	redactedArgs, _ := p.redactor.Map(args)
+	invocation := newToolInvocation(ctx, name, redactedArgs, start, result, err)
+	invocation.Error = p.redactor.String(invocation.Error)
+	p.toolRecorder.RecordToolInvocation(ctx, invocation)
+}
+
+// redactedLogValue маскирует аргументы или результат инструмента для записи в лог
+func (p *MCPGeminiProvider) redactedLogValue(value map[string]any) map[string]any {
+	redacted, _ := p.redactor.Map(value)
+	return redacted
+}
+
+// observeToolCall передаёт результат вызова инструмента наблюдателю и в поток ответа
+func (p *MCPGeminiProvider) observeToolCall(ctx context.Context, callID, name string, start time.Time, err error) {
+	duration := time.Since(start)
+	if p.toolObserver != nil {
+		p.toolObserver(name, duration, err)
+	}
+	emitToolCallFinished(ctx, callID, name, duration, err)
+}
+
+// callMCPTools выполняет вызовы функций из одного ответа модели параллельно, не больше maxToolCalls одновременно.
+// Ошибка вызова попадает в его собственный результат и не отменяет остальные;
+// результаты возвращаются в порядке вызовов.
+func (p *MCPGeminiProvider) callMCPTools(ctx context.Context, calls []genai.FunctionCall) []map[string]any {
+	results := make([]map[string]any, len(calls))
+
+	var g errgroup.Group
+	g.SetLimit(p.maxToolCalls)
+	for i, fc := range calls {
+		g.Go(func() error {
+			args := fc.Args
+			if args == nil {
+				args = map[string]any{}
+			}
+			result, err := p.callMCPTool(ctx, fc.Name, args)
+			if err != nil {
+				result = map[string]any{"error": err.Error()}
+			}
+			results[i] = result
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	return results
+}
+
 // callMCPTool вызывает MCP инструмент
 func (p *MCPGeminiProvider) callMCPTool(ctx context.Context, name string, args map[string]any) (map[string]any, error) {
 	if args == nil {
 		args = map[string]any{}
 	}
 
+	// Модель видит только разрешённые инструменты, но имя могло прийти из истории или галлюцинации
+	if !p.toolFilter.Allows(name) {
+		p.logger.Warn("Blocked call to filtered MCP tool", zap.String("tool_name", name))
+		return nil, fmt.Errorf("%w: %s", ErrToolNotAllowed, name)
+	}
+
+	srv, toolName, err := p.resolveTool(name)
+	if err != nil {
+		p.logger.Warn("Call to unknown MCP tool", zap.String("tool_name", name))
+		return nil, err
+	}
+
 	p.logger.Info(
 		"MCP tool request",
 		zap.String("tool_name", name),
-		zap.Any("arguments", args),
+		zap.Any("arguments", p.redactedLogValue(args)),
 	)
 
-	res, err := p.session.CallTool(ctx, &mcp.CallToolParams{
-		Name:      name,
+	callID := emitToolCallStarted(ctx, name, args)
+
+	callCtx := ctx
+	if p.toolCallTimeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, p.toolCallTimeout)
+		defer cancel()
+	}
+
+	params := &mcp.CallToolParams{
+		Name:      toolName,
 		Arguments: args,
-	})
+	}
+	defer p.trackToolProgress(ctx, params, callID, name)()
+
+	MarkToolCalled(ctx)
+	start := time.Now()
+	res, err := p.callSession(callCtx, srv, name, params)
+	if err != nil && ctx.Err() == nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+		// Истёк только лимит инструмента: модель получает ошибку и продолжает ответ
+		err = fmt.Errorf("tool timed out after %s", p.toolCallTimeout)
+		result := map[string]any{"error": err.Error()}
+		p.observeToolCall(ctx, callID, name, start, err)
+		p.recordToolInvocation(ctx, name, args, start, result, err)
+		p.traceToolCall(ctx, callID, name, args, start, result, err)
+		p.logger.Warn("MCP tool call timed out", zap.String("tool_name", name), zap.Duration("timeout", p.toolCallTimeout))
+		return result, nil
+	}
 	if err != nil {
+		p.observeToolCall(ctx, callID, name, start, err)
+		p.recordToolInvocation(ctx, name, args, start, nil, err)
+		p.traceToolCall(ctx, callID, name, args, start, nil, err)
 		p.logger.Error("MCP tool call failed", zap.String("tool_name", name), zap.Error(err))
 		return nil, fmt.Errorf("tool call failed: %w", err)
 	}
 
 	if res.IsError {
-		msg := "tool error"
-		for _, ct := range res.Content {
-			if tc, ok := ct.(*mcp.TextContent); ok && strings.TrimSpace(tc.Text) != "" {
-				msg = tc.Text
-				break
-			}
-		}
+		msg := toolErrorText(res)
 		result := map[string]any{"error": msg}
-		p.logger.Warn("MCP tool returned error", zap.String("tool_name", name), zap.Any("response", result))
+		p.observeToolCall(ctx, callID, name, start, errors.New(msg))
+		p.recordToolInvocation(ctx, name, args, start, result, errors.New(msg))
+		p.traceToolCall(ctx, callID, name, args, start, result, errors.New(msg))
+		p.logger.Warn("MCP tool returned error", zap.String("tool_name", name), zap.Any("response", p.redactedLogValue(result)))
 		return result, nil
 	}
+	p.observeToolCall(ctx, callID, name, start, nil)
+
+	result := toolResultValue(res)
+	p.recordToolInvocation(ctx, name, args, start, result, nil)
+
+	if truncated, omitted := truncateToolResult(result, p.maxResultBytes); omitted > 0 {
+		p.logger.Warn("MCP tool result truncated",
+			zap.String("tool_name", name),
+			zap.Int("limit_bytes", p.maxResultBytes),
+			zap.Int("omitted_bytes", omitted),
+		)
+		result = truncated
+	}
+	p.traceToolCall(ctx, callID, name, args, start, result, nil)
+
+	p.logger.Info("MCP tool response", zap.String("tool_name", name), zap.Any("response", p.redactedLogValue(result)))
+
+	return result, nil
+}
+
+// callSession вызывает инструмент в сессии сервера. Если сервер перезапущен или соединение потеряно,
+// сессия переподключается и вызов повторяется; ошибка возвращается, только если переподключиться не удалось.
+func (p *MCPGeminiProvider) callSession(ctx context.Context, srv *mcpServer, name string, params *mcp.CallToolParams) (*mcp.CallToolResult, error) {
+	session := srv.currentSession()
+	var res *mcp.CallToolResult
+	err := errMCPNotConnected
+	if session != nil {
+		res, err = session.CallTool(ctx, params)
+	}
+	if isMCPConnectionError(err) && ctx.Err() == nil {
+		srv.logger.Warn("MCP connection lost, reconnecting", zap.String("tool_name", name), zap.Error(err))
+		if rerr := p.reconnectMCP(ctx, srv, session); rerr != nil {
+			return nil, rerr
+		}
+		res, err = srv.currentSession().CallTool(ctx, params)
+	}
+	return res, err
+}
 
+// toolErrorText первый непустой текст результата с IsError
+func toolErrorText(res *mcp.CallToolResult) string {
+	for _, ct := range res.Content {
+		if tc, ok := ct.(*mcp.TextContent); ok && strings.TrimSpace(tc.Text) != "" {
+			return tc.Text
+		}
+	}
+	return "tool error"
+}
+
+// toolResultValue переводит результат инструмента в объект для FunctionResponse:
+// структурированный результат как есть, иначе текстовые части в поле result
+func toolResultValue(res *mcp.CallToolResult) map[string]any {
 	var result map[string]any
 
 	if res.StructuredContent != nil {
@@ -423,8 +1285,12 @@ func (p *MCPGeminiProvider) callMCPTool(ctx context.Context, name string, args m
 		default:
 			b, _ := json.Marshal(v)
 			m := map[string]any{}
+			var decoded any
 			if err := json.Unmarshal(b, &m); err == nil {
 				result = m
+			} else if err := json.Unmarshal(b, &decoded); err == nil {
+				// Массив или скаляр: структура сохраняется, чтобы обрезка не резала JSON посередине
+				result = map[string]any{"result": decoded}
 			} else {
 				result = map[string]any{"result": string(b)}
 			}
@@ -446,13 +1312,10 @@ func (p *MCPGeminiProvider) callMCPTool(ctx context.Context, name string, args m
 	if result == nil {
 		result = map[string]any{"result": nil}
 	}
-
-	p.logger.Info("MCP tool response", zap.String("tool_name", name), zap.Any("response", result))
-
-	return result, nil
+	return result
 }
 
-func (p *MCPGeminiProvider) httpClientWithHeaders(headers map[string]string) *http.Client {
+func httpClientWithHeaders(headers map[string]string) *http.Client {
 	if len(headers) == 0 {
 		return nil
 	}
@@ -474,134 +1337,102 @@ func (h *headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error
 	return h.next.RoundTrip(req)
 }
 
-// Закрытие соединений
-func (p *MCPGeminiProvider) Close() {
-	if p.session != nil {
-		p.session.Close()
-	}
-	if p.genClient != nil {
-		p.genClient.Close()
+// Reinitialize закрывает MCP сессию и клиент Gemini и выполняет инициализацию заново.
+// Новые запросы на время переинициализации получают ErrProviderReinitializing,
+// текущие запросы дожидаются завершения (не дольше чем позволяет ctx).
+func (p *MCPGeminiProvider) Reinitialize(ctx context.Context) (int, error) {
+	if !p.reinitializing.CompareAndSwap(false, true) {
+		return 0, ErrProviderReinitializing
 	}
-}
-
-// ===============================
-// JSON Schema → genai конвертация
-// ===============================
+	defer p.reinitializing.Store(false)
 
-func (p *MCPGeminiProvider) schemaTypeToGenai(t string) genai.Type {
-	switch strings.ToLower(strings.TrimSpace(t)) {
-	case "string":
-		return genai.TypeString
-	case "number":
-		return genai.TypeNumber
-	case "integer":
-		return genai.TypeInteger
-	case "boolean":
-		return genai.TypeBoolean
-	case "array":
-		return genai.TypeArray
-	case "object":
-		return genai.TypeObject
-	case "null":
-		return genai.TypeString
-	default:
-		return genai.TypeString
-	}
-}
+	p.logger.Info("Reinitializing MCP Gemini provider, waiting for in-flight requests")
 
-func (p *MCPGeminiProvider) firstNonEmpty(ss ...string) string {
-	for _, s := range ss {
-		if strings.TrimSpace(s) != "" {
-			return s
-		}
-	}
-	return ""
-}
+	locked := make(chan struct{})
+	go func() {
+		p.lifecycleMu.Lock()
+		close(locked)
+	}()
 
-func (p *MCPGeminiProvider) toStringEnum(vals []any) []string {
-	if len(vals) == 0 {
-		return nil
+	select {
+	case <-locked:
+	case <-ctx.Done():
+		// Освобождаем блокировку, когда она всё же будет получена
+		go func() {
+			<-locked
+			p.lifecycleMu.Unlock()
+		}()
+		return 0, fmt.Errorf("timed out waiting for in-flight requests: %w", ctx.Err())
 	}
-	out := make([]string, 0, len(vals))
-	for _, v := range vals {
-		if s, ok := v.(string); ok {
-			out = append(out, s)
-		}
+	defer p.lifecycleMu.Unlock()
+
+	p.closeConnections()
+
+	p.initialized.Store(false)
+	p.genClient = nil
+	p.toolsMu.Lock()
+	p.available = nil
+	p.geminiTools = nil
+	p.model = nil
+	for _, srv := range p.servers {
+		srv.tools = nil
 	}
-	if len(out) == 0 {
-		return nil
+	p.toolsMu.Unlock()
+	p.resourcesMu.Lock()
+	for _, srv := range p.servers {
+		srv.resources = nil
+		srv.prompts = nil
 	}
-	return out
-}
+	p.resourcesMu.Unlock()
+	p.systemPrompt = ""
 
-func (p *MCPGeminiProvider) convertProperty(s *jsonschema.Schema) *genai.Schema {
-	if s == nil {
-		return &genai.Schema{Type: genai.TypeString}
+	if err := p.ensureInitialized(ctx); err != nil {
+		p.recordResult(err)
+		return 0, err
 	}
 
-	if len(s.AnyOf) > 0 {
-		for _, sub := range s.AnyOf {
-			if sub == nil {
-				continue
-			}
-			t := strings.ToLower(strings.TrimSpace(sub.Type))
-			if t == "" && len(sub.Types) > 0 {
-				t = strings.ToLower(strings.TrimSpace(sub.Types[0]))
-			}
-			if t != "null" {
-				return p.convertProperty(sub)
-			}
-		}
-		return &genai.Schema{Type: genai.TypeString}
-	}
+	tools := len(p.toolDeclarations())
+	p.logger.Info("MCP Gemini provider reinitialized", zap.Int("tools_count", tools))
+	return tools, nil
+}
 
-	propType := s.Type
-	if propType == "" && len(s.Types) > 0 {
-		propType = s.Types[0]
-	}
-	gType := p.schemaTypeToGenai(propType)
-	desc := p.firstNonEmpty(s.Description, s.Title)
-	enumVals := p.toStringEnum(s.Enum)
+// Close закрывает сессии MCP серверов и клиент Gemini (io.Closer), дожидаясь текущих запросов
+func (p *MCPGeminiProvider) Close() error {
+	p.lifecycleMu.Lock()
+	defer p.lifecycleMu.Unlock()
 
-	switch gType {
-	case genai.TypeArray:
-		var itemSchema *jsonschema.Schema
-		if s.Items != nil {
-			itemSchema = s.Items
-		}
-		return &genai.Schema{
-			Type:        genai.TypeArray,
-			Items:       p.convertProperty(itemSchema),
-			Description: desc,
-			Enum:        enumVals,
-		}
+	err := p.closeConnections()
+	p.initialized.Store(false)
+	p.genClient = nil
+	return err
+}
 
-	case genai.TypeObject:
-		props := map[string]*genai.Schema{}
-		if s.Properties != nil {
-			for name, sub := range s.Properties {
-				props[name] = p.convertProperty(sub)
-			}
-		}
-		var required []string
-		if len(s.Required) > 0 {
-			required = append(required, s.Required...)
-		}
-		return &genai.Schema{
-			Type:        genai.TypeObject,
-			Properties:  props,
-			Required:    required,
-			Description: desc,
-			Enum:        enumVals,
+func (p *MCPGeminiProvider) closeConnections() error {
+	for _, srv := range p.servers {
+		srv.closeSession()
+	}
+	if p.genClient != nil {
+		if err := p.genClient.Close(); err != nil {
+			p.logger.Warn("Failed to close Gemini client", zap.Error(err))
+			return fmt.Errorf("failed to close Gemini client: %w", err)
 		}
+	}
+	return nil
+}
 
-	default:
-		return &genai.Schema{
-			Type:        gType,
-			Description: desc,
-			Enum:        enumVals,
+// systemInstruction объединяет system-сообщения запроса (промпт чата или резюме, ресурсы, найденная история).
+// Промпт из файла mcp.system_prompt_path используется, только если запрос не передал ни одного.
+func (p *MCPGeminiProvider) systemInstruction(messages []Message) string {
+	var parts []string
+	for _, m := range messages {
+		if m.Role == "system" && strings.TrimSpace(m.Content) != "" {
+			parts = append(parts, m.Content)
 		}
 	}
+	if len(parts) == 0 {
+		return p.systemPrompt
+	}
+	return strings.Join(parts, "\n\n")
 }
 
 func (p *MCPGeminiProvider) toGenaiHistory(messages []Message) (history []*genai.Content, lastUser *genai.Content) {
@@ -647,50 +1478,3 @@ func (p *MCPGeminiProvider) toGenaiHistory(messages []Message) (history []*genai
 	}
 	return
 }
-
-func (p *MCPGeminiProvider) convertMCPToGeminiTools(tools []*mcp.Tool) []*genai.FunctionDeclaration {
-	out := make([]*genai.FunctionDeclaration, 0, len(tools))
-	for _, t := range tools {
-		var root jsonschema.Schema
-		if t.InputSchema != nil {
-			root = *t.InputSchema
-		}
-		if root.Type == "" && len(root.Types) == 0 {
-			root.Type = "object"
-		}
-
-		var params *genai.Schema
-		if strings.EqualFold(root.Type, "object") || (len(root.Types) > 0 && strings.EqualFold(root.Types[0], "object")) {
-			props := map[string]*genai.Schema{}
-			if root.Properties != nil {
-				for name, sub := range root.Properties {
-					props[name] = p.convertProperty(sub)
-				}
-			}
-			params = &genai.Schema{
-				Type:        genai.TypeObject,
-				Properties:  props,
-				Required:    append([]string(nil), root.Required...),
-				Description: p.firstNonEmpty(root.Description, root.Title),
-			}
-		} else {
-			params = &genai.Schema{
-				Type:        genai.TypeObject,
-				Description: p.firstNonEmpty(root.Description, root.Title),
-			}
-		}
-
-		desc := t.Description
-		if desc == "" && t.Annotations != nil {
-			desc = t.Annotations.Title
-		}
-
-		fd := &genai.FunctionDeclaration{
-			Name:        t.Name,
-			Description: desc,
-			Parameters:  params,
-		}
-		out = append(out, fd)
-	}
-	return out
-}
@@ -0,0 +1,110 @@
+package providers
+
+import "fmt"
+
+// Параметры жадного декодирования, используемые для детерминированной генерации
+const (
+	GreedyTemperature float32 = 0
+	GreedyTopK        int32   = 1
+)
+
+// WarningCode тип предупреждения о параметре генерации
+type WarningCode string
+
+// WarningNotSupported провайдер не поддерживает параметр и применил замену (или проигнорировал его)
+const WarningNotSupported WarningCode = "not_supported"
+
+// Warning предупреждение провайдера о параметре, применённом не так, как запрошено
+type Warning struct {
+	Code      WarningCode `json:"code"`
+	Parameter string      `json:"parameter"`
+	Message   string      `json:"message"`
+}
+
+// NotSupportedWarning предупреждение о неподдерживаемом параметре и применённой замене
+func NotSupportedWarning(provider, parameter, fallback string) Warning {
+	return Warning{
+		Code:      WarningNotSupported,
+		Parameter: parameter,
+		Message:   fmt.Sprintf("%s does not support %s, applied %s instead", provider, parameter, fallback),
+	}
+}
+
+// GenerationInfo параметры генерации, фактически применённые провайдером к запросу.
+// Возвращается для воспроизводимых запросов (RequestOptions.Seed, RequestOptions.Deterministic)
+// и запросов с ограничением длины ответа.
+type GenerationInfo struct {
+	Seed            *int64    `json:"seed,omitempty"`
+	Temperature     *float32  `json:"temperature,omitempty"`
+	TopK            *int32    `json:"top_k,omitempty"`
+	MaxOutputTokens *int32    `json:"max_output_tokens,omitempty"` // действующий лимит длины ответа
+	Deterministic   bool      `json:"deterministic"`               // применено жадное декодирование
+	Warnings        []Warning `json:"warnings,omitempty"`
+}
+
+// OutputTokenLimiter опциональный интерфейс провайдеров, знающих предельную длину ответа моделей
+type OutputTokenLimiter interface {
+	// OutputTokenLimit возвращает предел max_output_tokens модели; 0 - предел неизвестен
+	OutputTokenLimit(model string) int
+}
+
+// Допустимые значения параметров сэмплирования (ограничения Gemini API)
+const (
+	MaxTemperature         float32 = 2
+	MaxConfigStopSequences         = 5
+)
+
+// GenerationConfig параметры сэмплирования клиента. Незаданный параметр (nil, 0, пустой список)
+// оставляет значение модели по умолчанию. Параметры запроса (RequestOptions) имеют приоритет.
+type GenerationConfig struct {
+	Temperature     *float32 `mapstructure:"temperature" json:"temperature,omitempty"`
+	TopP            *float32 `mapstructure:"top_p" json:"top_p,omitempty"`
+	TopK            *int32   `mapstructure:"top_k" json:"top_k,omitempty"`
+	MaxOutputTokens int32    `mapstructure:"max_output_tokens" json:"max_output_tokens,omitempty"`
+	StopSequences   []string `mapstructure:"stop_sequences" json:"stop_sequences,omitempty"`
+}
+
+// Validate проверяет диапазоны параметров
+func (g GenerationConfig) Validate() error {
+	if g.Temperature != nil && (*g.Temperature < 0 || *g.Temperature > MaxTemperature) {
+		return fmt.Errorf("temperature must be between 0 and %g: %g", MaxTemperature, *g.Temperature)
+	}
+	if g.TopP != nil && (*g.TopP < 0 || *g.TopP > 1) {
+		return fmt.Errorf("top_p must be between 0 and 1: %g", *g.TopP)
+	}
+	if g.TopK != nil && *g.TopK < 1 {
+		return fmt.Errorf("top_k must be positive: %d", *g.TopK)
+	}
+	if g.MaxOutputTokens < 0 {
+		return fmt.Errorf("max_output_tokens cannot be negative: %d", g.MaxOutputTokens)
+	}
+	if len(g.StopSequences) > MaxConfigStopSequences {
+		return fmt.Errorf("%d stop sequences, limit is %d", len(g.StopSequences), MaxConfigStopSequences)
+	}
+	for i, stop := range g.StopSequences {
+		if stop == "" {
+			return fmt.Errorf("stop sequence %d is empty", i)
+		}
+	}
+	return nil
+}
+
+// Merge возвращает параметры с заданными в override значениями поверх текущих
+func (g GenerationConfig) Merge(override GenerationConfig) GenerationConfig {
+	if override.Temperature != nil {
+		g.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		g.TopP = override.TopP
+	}
+	if override.TopK != nil {
+		g.TopK = override.TopK
+	}
+	if override.MaxOutputTokens > 0 {
+		g.MaxOutputTokens = override.MaxOutputTokens
+	}
+	if len(override.StopSequences) > 0 {
+		g.StopSequences = override.StopSequences
+	}
+	return g
+}
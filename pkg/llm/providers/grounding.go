@@ -0,0 +1,244 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// GroundingParameter имя параметра запроса в предупреждениях о недоступном поиске
+const GroundingParameter = "grounding.google_search"
+
+// WarningUnavailable параметр не применён: API или прокси отклонили его
+const WarningUnavailable WarningCode = "unavailable"
+
+// GroundingInfo результаты поиска Google, на которые опирается ответ модели
+type GroundingInfo struct {
+	SearchQueries []string          `json:"search_queries,omitempty"`
+	Sources       []GroundingSource `json:"sources,omitempty"`
+}
+
+// GroundingSource источник (цитата), найденный поиском Google
+type GroundingSource struct {
+	URI   string `json:"uri"`
+	Title string `json:"title,omitempty"`
+}
+
+// GroundingUnavailableWarning предупреждение о запросе, выполненном без поиска Google
+func GroundingUnavailableWarning(provider, reason string) Warning {
+	return Warning{
+		Code:      WarningUnavailable,
+		Parameter: GroundingParameter,
+		Message:   fmt.Sprintf("%s: google search grounding unavailable (%s), answered without it", provider, reason),
+	}
+}
+
+type groundingKey struct{}
+
+// groundingCollector включает поиск для запросов генерации в рамках одного ChatCompletion
+// и собирает метаданные поиска из всех ответов цикла вызова инструментов
+type groundingCollector struct {
+	mu       sync.Mutex
+	info     GroundingInfo
+	seen     map[string]bool
+	rejected string // причина отказа API от инструмента поиска
+}
+
+func withGroundingCollector(ctx context.Context, c *groundingCollector) context.Context {
+	return context.WithValue(ctx, groundingKey{}, c)
+}
+
+func groundingCollectorFromContext(ctx context.Context) *groundingCollector {
+	c, _ := ctx.Value(groundingKey{}).(*groundingCollector)
+	return c
+}
+
+func (c *groundingCollector) add(meta *groundingMetadata) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.seen == nil {
+		c.seen = make(map[string]bool)
+	}
+	for _, q := range meta.WebSearchQueries {
+		if q != "" && !c.seen["q:"+q] {
+			c.seen["q:"+q] = true
+			c.info.SearchQueries = append(c.info.SearchQueries, q)
+		}
+	}
+	for _, chunk := range meta.GroundingChunks {
+		if chunk.Web == nil || chunk.Web.URI == "" || c.seen["s:"+chunk.Web.URI] {
+			continue
+		}
+		c.seen["s:"+chunk.Web.URI] = true
+		c.info.Sources = append(c.info.Sources, GroundingSource{URI: chunk.Web.URI, Title: chunk.Web.Title})
+	}
+}
+
+func (c *groundingCollector) reject(reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rejected == "" {
+		c.rejected = reason
+	}
+}
+
+// result возвращает собранные метаданные (nil, если поиск не дал источников) и причину отказа
+func (c *groundingCollector) result() (*GroundingInfo, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.info.SearchQueries) == 0 && len(c.info.Sources) == 0 {
+		return nil, c.rejected
+	}
+	info := c.info
+	return &info, c.rejected
+}
+
+// groundingMetadata часть ответа generateContent, которую SDK не разбирает
+type groundingMetadata struct {
+	WebSearchQueries []string `json:"webSearchQueries"`
+	GroundingChunks  []struct {
+		Web *struct {
+			URI   string `json:"uri"`
+			Title string `json:"title"`
+		} `json:"web"`
+	} `json:"groundingChunks"`
+}
+
+// groundingTransport добавляет инструмент поиска Google в тело запроса generateContent
+// и извлекает groundingMetadata из ответа. SDK Gemini не поддерживает ни инструмент, ни метаданные,
+// поэтому работа идёт на уровне REST. Если API (или прокси) отклоняет запрос с инструментом,
+// запрос повторяется без него, а отказ запоминается до переинициализации клиента.
+type groundingTransport struct {
+	next     http.RoundTripper
+	rejected atomic.Bool
+}
+
+func (t *groundingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	collector := groundingCollectorFromContext(req.Context())
+	if collector == nil || t.rejected.Load() || req.Body == nil || !strings.HasSuffix(req.URL.Path, ":generateContent") {
+		return t.next.RoundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	grounded, err := withGoogleSearchTool(body, searchToolField(req.URL.Path))
+	if err != nil {
+		collector.reject("request body is not valid JSON")
+		return t.next.RoundTrip(withBody(req, body))
+	}
+
+	resp, err := t.next.RoundTrip(withBody(req, grounded))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnprocessableEntity {
+		reason := rejectionReason(resp)
+		retry, err := t.next.RoundTrip(withBody(req, body))
+		if err != nil {
+			return nil, err
+		}
+		// Отказ относится к инструменту поиска, только если запрос без него принят
+		if retry.StatusCode < http.StatusBadRequest {
+			t.rejected.Store(true)
+			collector.reject(reason)
+		}
+		return retry, nil
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		if err := extractGrounding(resp, collector); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// searchToolField имя инструмента поиска: модели 1.x используют устаревший googleSearchRetrieval
+func searchToolField(path string) string {
+	if strings.Contains(path, "gemini-1.") {
+		return "googleSearchRetrieval"
+	}
+	return "googleSearch"
+}
+
+// withGoogleSearchTool добавляет инструмент поиска к инструментам запроса (рядом с объявлениями функций MCP)
+func withGoogleSearchTool(body []byte, field string) ([]byte, error) {
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	var tools []json.RawMessage
+	if raw, ok := payload["tools"]; ok {
+		if err := json.Unmarshal(raw, &tools); err != nil {
+			return nil, err
+		}
+	}
+	tools = append(tools, json.RawMessage(`{"`+field+`":{}}`))
+
+	raw, err := json.Marshal(tools)
+	if err != nil {
+		return nil, err
+	}
+	payload["tools"] = raw
+	return json.Marshal(payload)
+}
+
+// extractGrounding читает groundingMetadata первого кандидата и возвращает тело ответа для SDK
+func extractGrounding(resp *http.Response, collector *groundingCollector) error {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	var parsed struct {
+		Candidates []struct {
+			GroundingMetadata *groundingMetadata `json:"groundingMetadata"`
+		} `json:"candidates"`
+	}
+	if json.Unmarshal(body, &parsed) == nil && len(parsed.Candidates) > 0 && parsed.Candidates[0].GroundingMetadata != nil {
+		collector.add(parsed.Candidates[0].GroundingMetadata)
+	}
+	return nil
+}
+
+// rejectionReason текст ошибки отклонённого запроса (тело ответа закрывается)
+func rejectionReason(resp *http.Response) string {
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var apiErr struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Message != "" {
+		return apiErr.Error.Message
+	}
+	return resp.Status
+}
+
+func withBody(req *http.Request, body []byte) *http.Request {
+	clone := req.Clone(req.Context())
+	clone.Body = io.NopCloser(bytes.NewReader(body))
+	clone.ContentLength = int64(len(body))
+	clone.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return clone
+}
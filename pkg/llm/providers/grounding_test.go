@@ -0,0 +1,148 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+	"go.uber.org/zap"
+)
+
+// groundingServer имитирует generateContent: отвечает вызовом функции с метаданными поиска
+// и, если rejectSearch, отклоняет запросы с инструментом поиска, как это делают некоторые прокси
+type groundingServer struct {
+	mu           sync.Mutex
+	bodies       []string
+	rejectSearch bool
+}
+
+func (s *groundingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	s.bodies = append(s.bodies, string(body))
+	reject := s.rejectSearch
+	s.mu.Unlock()
+
+	if reject && strings.Contains(string(body), "googleSearch") {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":400,"message":"Search tool is not supported"}}`))
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"candidates": []any{map[string]any{
+		"content": map[string]any{"role": "model", "parts": []any{
+			map[string]any{"functionCall": map[string]any{"name": "lookup", "args": map[string]any{"q": "x"}}},
+		}},
+		"groundingMetadata": map[string]any{
+			"webSearchQueries": []string{"weather today"},
+			"groundingChunks": []any{
+				map[string]any{"web": map[string]any{"uri": "https://a.example", "title": "A"}},
+				map[string]any{"web": map[string]any{"uri": "https://a.example"}},
+			},
+		},
+	}}})
+}
+
+func (s *groundingServer) body(i int) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bodies[i]
+}
+
+func (s *groundingServer) requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bodies)
+}
+
+// newGroundingModel модель Gemini с объявлением функции, работающая через транспорт поиска
+func newGroundingModel(t *testing.T, server *groundingServer) (*MCPGeminiProvider, *genai.GenerativeModel) {
+	t.Helper()
+
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+
+	p := &MCPGeminiProvider{geminiAPIKey: "key", geminiBaseURL: srv.URL, geminiModel: "gemini-2.0-flash", logger: zap.NewNop()}
+	if err := p.initializeGemini(context.Background()); err != nil {
+		t.Fatalf("initializeGemini: %v", err)
+	}
+	model := p.genClient.GenerativeModel("gemini-2.0-flash")
+	model.Tools = []*genai.Tool{{FunctionDeclarations: []*genai.FunctionDeclaration{{Name: "lookup"}}}}
+	return p, model
+}
+
+func TestGroundingWithFunctionCalling(t *testing.T) {
+	server := &groundingServer{}
+	p, model := newGroundingModel(t, server)
+
+	collector := &groundingCollector{}
+	resp, err := model.GenerateContent(withGroundingCollector(context.Background(), collector), genai.Text("x"))
+	if err != nil {
+		t.Fatalf("GenerateContent: %v", err)
+	}
+
+	// Инструмент поиска отправлен рядом с объявлениями функций
+	body := server.body(0)
+	if !strings.Contains(body, `"googleSearch":{}`) || !strings.Contains(body, `"functionDeclarations"`) {
+		t.Errorf("request tools = %s, want function declarations and google search", body)
+	}
+	// Вызов функции по-прежнему разбирается SDK
+	if calls := resp.Candidates[0].FunctionCalls(); len(calls) != 1 || calls[0].Name != "lookup" {
+		t.Errorf("function calls = %+v, want lookup", calls)
+	}
+
+	info, reason := collector.result()
+	if reason != "" {
+		t.Errorf("rejection reason = %q, want none", reason)
+	}
+	if info == nil || len(info.Sources) != 1 || info.Sources[0].Title != "A" || len(info.SearchQueries) != 1 {
+		t.Errorf("grounding = %+v, want one deduplicated source and query", info)
+	}
+	if _, generation := p.groundingResult(collector, nil); generation != nil {
+		t.Errorf("warnings without rejection: %+v", generation)
+	}
+
+	// Без сборщика (поиск выключен) запрос не меняется
+	if _, err := model.GenerateContent(context.Background(), genai.Text("x")); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(server.body(1), "googleSearch") {
+		t.Error("search tool was added to a request without grounding")
+	}
+}
+
+func TestGroundingRejectedByEndpoint(t *testing.T) {
+	server := &groundingServer{rejectSearch: true}
+	p, model := newGroundingModel(t, server)
+
+	collector := &groundingCollector{}
+	resp, err := model.GenerateContent(withGroundingCollector(context.Background(), collector), genai.Text("x"))
+	if err != nil {
+		t.Fatalf("GenerateContent: %v", err)
+	}
+	if calls := resp.Candidates[0].FunctionCalls(); len(calls) != 1 {
+		t.Errorf("function calls = %+v, want the answer without search", calls)
+	}
+
+	_, generation := p.groundingResult(collector, nil)
+	if generation == nil || len(generation.Warnings) != 1 || generation.Warnings[0].Parameter != GroundingParameter {
+		t.Fatalf("generation = %+v, want a grounding warning", generation)
+	}
+	if !strings.Contains(generation.Warnings[0].Message, "Search tool is not supported") {
+		t.Errorf("warning = %q, want the endpoint's reason", generation.Warnings[0].Message)
+	}
+
+	// Отказ запоминается: следующий запрос идёт без инструмента и без повторной попытки
+	if _, err := model.GenerateContent(withGroundingCollector(context.Background(), &groundingCollector{}), genai.Text("x")); err != nil {
+		t.Fatal(err)
+	}
+	if server.requests() != 3 || strings.Contains(server.body(2), "googleSearch") {
+		t.Errorf("requests = %d, want 3 with the last one without search", server.requests())
+	}
+}
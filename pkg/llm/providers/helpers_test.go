@@ -0,0 +1,249 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"go.uber.org/zap"
+)
+
+// testMCPServer MCP сервер в памяти процесса, доступный по Streamable HTTP
+type testMCPServer struct {
+	server   *mcp.Server
+	http     *httptest.Server
+	sessions atomic.Int32 // запросы initialize: новые подключения клиентов
+
+	mu      sync.Mutex
+	handler http.Handler
+}
+
+// newTestMCPServer запускает сервер с инструментами name -> обработчик
+func newTestMCPServer(t *testing.T, tools map[string]mcp.ToolHandler) *testMCPServer {
+	t.Helper()
+
+	s := &testMCPServer{server: mcp.NewServer(&mcp.Implementation{Name: "test-server", Version: "1.0.0"}, nil)}
+	for name, handler := range tools {
+		s.server.AddTool(&mcp.Tool{Name: name, Description: "Test tool " + name, InputSchema: &jsonschema.Schema{Type: "object"}}, handler)
+	}
+
+	s.restart()
+	s.http = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.Header.Get("Mcp-Session-Id") == "" {
+			s.sessions.Add(1)
+		}
+		s.mu.Lock()
+		handler := s.handler
+		s.mu.Unlock()
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.stop)
+	return s
+}
+
+// stop останавливает сервер, обрывая открытые потоки клиентов
+func (s *testMCPServer) stop() {
+	s.http.CloseClientConnections()
+	s.http.Close()
+}
+
+// restart имитирует перезапуск сервера: новый обработчик не знает открытых сессий
+func (s *testMCPServer) restart() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handler = mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server { return s.server }, nil)
+}
+
+// newTestProvider провайдер Gemini, подключённый к серверу; configure меняет настройки MCP до создания
+func newTestProvider(t *testing.T, server *testMCPServer, configure func(*MCPProviderConfig)) *MCPGeminiProvider {
+	t.Helper()
+
+	p := newUnconnectedProvider(t, MCPProviderConfig{ServerURL: server.http.URL}, configure)
+	for _, srv := range p.servers {
+		if err := p.connectServer(context.Background(), srv); err != nil {
+			t.Fatalf("connectServer: %v", err)
+		}
+		t.Cleanup(srv.closeSession)
+	}
+	return p
+}
+
+// newUnconnectedProvider провайдер Gemini с системным промптом во временном файле, без подключения к серверам
+func newUnconnectedProvider(t *testing.T, mcpConfig MCPProviderConfig, configure func(*MCPProviderConfig)) *MCPGeminiProvider {
+	t.Helper()
+
+	promptPath := filepath.Join(t.TempDir(), "system_prompt.txt")
+	if err := os.WriteFile(promptPath, []byte("You are a test assistant."), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mcpConfig.SystemPromptPath = promptPath
+	mcpConfig.MaxIterations = 5
+	if configure != nil {
+		configure(&mcpConfig)
+	}
+
+	provider, err := NewMCPGeminiProvider(Config{APIKey: "key", Model: "gemini-2.0-flash"}, mcpConfig, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewMCPGeminiProvider: %v", err)
+	}
+	return provider.(*MCPGeminiProvider)
+}
+
+// textResult результат инструмента с одним текстовым блоком
+func textResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: text}}}
+}
+
+// toolArgs аргументы вызова инструмента на стороне сервера
+func toolArgs(req *mcp.CallToolRequest) map[string]any {
+	args := map[string]any{}
+	if raw, ok := req.Params.Arguments.(json.RawMessage); ok {
+		json.Unmarshal(raw, &args)
+	}
+	return args
+}
+
+// fakeGemini имитирует streamGenerateContent: каждый запрос получает следующий ход сценария,
+// ход - элементы потокового JSON-массива ответов. Если задан release, элементы хода после первого
+// отправляются только после его закрытия.
+type fakeGemini struct {
+	http    *httptest.Server
+	release chan struct{}
+
+	mu       sync.Mutex
+	turns    [][]map[string]any
+	requests []map[string]any
+}
+
+func newFakeGemini(t *testing.T, turns ...[]map[string]any) *fakeGemini {
+	t.Helper()
+	requireStreamDecoding(t)
+
+	g := &fakeGemini{turns: turns}
+	g.http = httptest.NewServer(http.HandlerFunc(g.serve))
+	t.Cleanup(func() {
+		g.http.CloseClientConnections()
+		g.http.Close()
+	})
+	return g
+}
+
+func (g *fakeGemini) serve(w http.ResponseWriter, r *http.Request) {
+	var request map[string]any
+	json.NewDecoder(r.Body).Decode(&request)
+
+	g.mu.Lock()
+	g.requests = append(g.requests, request)
+	var events []map[string]any
+	if len(g.turns) > 0 {
+		events, g.turns = g.turns[0], g.turns[1:]
+	}
+	g.mu.Unlock()
+
+	if events == nil {
+		http.Error(w, `{"error":{"code":500,"message":"unexpected request"}}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	for i, event := range events {
+		separator := ","
+		if i == 0 {
+			separator = "["
+		} else if g.release != nil {
+			select {
+			case <-g.release:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		data, _ := json.Marshal(event)
+		w.Write([]byte(separator + string(data) + "\n"))
+		w.(http.Flusher).Flush()
+	}
+	w.Write([]byte("]"))
+}
+
+// requireStreamDecoding пропускает тест, если encoding/json не находит конец массива после
+// неудачного Decode, как ProtoJSONStream из gax при чтении потока ответов Gemini SDK.
+// Так ведёт себя реализация на основе json/v2 (GOEXPERIMENT=jsonv2): поток SDK там не читается.
+func requireStreamDecoding(t *testing.T) {
+	t.Helper()
+
+	dec := json.NewDecoder(strings.NewReader(`[{}]`))
+	var item json.RawMessage
+	dec.Token()
+	dec.Decode(&item)
+	if dec.Decode(&item) != nil {
+		if token, _ := dec.Token(); token == json.Delim(']') {
+			return
+		}
+	}
+	t.Skip("encoding/json cannot find the end of a streamed array; Gemini SDK streams are unreadable with this toolchain")
+}
+
+// attach направляет запросы провайдера к Gemini в имитацию
+func (g *fakeGemini) attach(p *MCPGeminiProvider) {
+	p.geminiBaseURL = g.http.URL
+}
+
+func (g *fakeGemini) requestCount() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.requests)
+}
+
+// geminiText ответ потока с фрагментом текста ответа
+func geminiText(text string) map[string]any {
+	return geminiEvent(map[string]any{"text": text})
+}
+
+// geminiFunctionCall ответ потока с вызовом функции
+func geminiFunctionCall(name string, args map[string]any) map[string]any {
+	return geminiEvent(map[string]any{"functionCall": map[string]any{"name": name, "args": args}})
+}
+
+func geminiEvent(part map[string]any) map[string]any {
+	return map[string]any{"candidates": []any{map[string]any{
+		"content": map[string]any{"role": "model", "parts": []any{part}},
+	}}}
+}
+
+// withUsage добавляет к ответу метаданные использования токенов
+func withUsage(event map[string]any, prompt, completion int) map[string]any {
+	event["usageMetadata"] = map[string]any{
+		"promptTokenCount":     prompt,
+		"candidatesTokenCount": completion,
+		"totalTokenCount":      prompt + completion,
+	}
+	return event
+}
+
+// collectChunks читает поток до закрытия канала
+func collectChunks(t *testing.T, chunks <-chan StreamChunk) []StreamChunk {
+	t.Helper()
+
+	var out []StreamChunk
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				return out
+			}
+			out = append(out, chunk)
+		case <-timeout:
+			t.Fatalf("stream was not closed, got %d chunks", len(out))
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package providers
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestConcurrentFirstRequestsConnectOnce(t *testing.T) {
+	const requests = 50
+
+	server := newTestMCPServer(t, map[string]mcp.ToolHandler{
+		"lookup": func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return textResult("found"), nil
+		},
+	})
+	turns := make([][]map[string]any, requests)
+	for i := range turns {
+		turns[i] = []map[string]any{geminiText("ok")}
+	}
+	gemini := newFakeGemini(t, turns...)
+
+	// Провайдер подключается к MCP лениво, при первом запросе
+	p := newUnconnectedProvider(t, MCPProviderConfig{ServerURL: server.http.URL}, nil)
+	gemini.attach(p)
+	t.Cleanup(func() { p.Close() })
+
+	var wg sync.WaitGroup
+	for range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.ChatCompletion(context.Background(), []Message{{Role: "user", Content: "Привет"}}); err != nil {
+				t.Errorf("ChatCompletion: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := server.sessions.Load(); got != 1 {
+		t.Errorf("MCP sessions = %d, want 1", got)
+	}
+	if got := gemini.requestCount(); got != requests {
+		t.Errorf("Gemini requests = %d, want %d", got, requests)
+	}
+}
@@ -3,9 +3,13 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+// ErrProviderReinitializing провайдер переинициализируется, запрос можно повторить позже
+var ErrProviderReinitializing = errors.New("LLM provider is reinitializing, retry later")
+
 // Message представляет сообщение в диалоге (универсальный формат)
 type Message struct {
 	Role    string `json:"role"`
@@ -18,8 +22,30 @@ type ChatResponse struct {
 	Model   string   `json:"model"`
 	Choices []Choice `json:"choices"`
 	Usage   Usage    `json:"usage"`
+
+	// Generation параметры воспроизводимой генерации, применённые провайдером
+	Generation *GenerationInfo `json:"generation,omitempty"`
+
+	// Grounding источники поиска Google, на которые опирается ответ
+	Grounding *GroundingInfo `json:"grounding,omitempty"`
+
+	// Iterations количество итераций цикла вызова инструментов (для провайдеров с MCP)
+	Iterations int `json:"iterations,omitempty"`
+
+	// ToolCalls выполненные вызовы MCP инструментов в порядке начала (для провайдеров с MCP)
+	ToolCalls []ToolCallTrace `json:"tool_calls,omitempty"`
 }
 
+// Причины завершения генерации, общие для всех провайдеров
+const (
+	// FinishReasonMaxIterations цикл вызова инструментов остановлен по лимиту итераций; ответ без текста
+	FinishReasonMaxIterations = "max_iterations"
+	// FinishReasonMaxTokens ответ обрезан по лимиту max_output_tokens
+	FinishReasonMaxTokens = "max_tokens"
+	// FinishReasonStopSequence генерация остановлена стоп-последовательностью из запроса
+	FinishReasonStopSequence = "stop_sequence"
+)
+
 type Choice struct {
 	Index        int     `json:"index"`
 	Message      Message `json:"message"`
@@ -43,6 +69,28 @@ type StreamChunk struct {
 	Content string
 	Done    bool
 	Error   error
+
+	// ToolCall событие вызова инструмента; чанк с ним не содержит Content
+	ToolCall *ToolCallEvent
+
+	// ToolProgress уведомление о ходе выполнения инструмента; приходит между started и finished его вызова
+	ToolProgress *ToolProgressInfo
+
+	// Generation применённые параметры генерации; передаётся в чанке с Done
+	Generation *GenerationInfo
+
+	// Grounding источники поиска Google; передаётся в чанке с Done
+	Grounding *GroundingInfo
+
+	// FinishReason и Iterations передаются в чанке с Done
+	FinishReason string
+	Iterations   int
+
+	// ToolCalls выполненные вызовы инструментов; передаются в чанке с Done
+	ToolCalls []ToolCallTrace
+
+	// Usage токены запроса; передаётся в чанке с Done, nil - провайдер их не сообщил
+	Usage *Usage
 }
 
 // Provider интерфейс для LLM провайдеров
@@ -63,13 +111,62 @@ type Provider interface {
 	ValidateConfig() error
 }
 
+// ErrMCPNotSupported провайдер работает без MCP серверов и инструментов
+var ErrMCPNotSupported = errors.New("MCP is not supported by provider")
+
+// MCPProber опциональный интерфейс провайдеров с MCP интеграцией для проверки связи с сервером
+type MCPProber interface {
+	// ProbeMCP выполняет лёгкий запрос к MCP серверу и возвращает количество инструментов
+	ProbeMCP(ctx context.Context) (int, error)
+}
+
+// ProviderStatus состояние провайдера для проверок готовности
+type ProviderStatus struct {
+	Initialized   bool
+	LastError     string
+	LastErrorAt   time.Time
+	LastSuccessAt time.Time
+}
+
+// StatusReporter опциональный интерфейс провайдеров, сообщающих своё состояние без обращения к API
+type StatusReporter interface {
+	Status() ProviderStatus
+}
+
+// ToolCallObserver получает сведения о каждом вызове MCP инструмента
+type ToolCallObserver func(tool string, duration time.Duration, err error)
+
+// ToolCallObservable опциональный интерфейс провайдеров, вызывающих MCP инструменты
+type ToolCallObservable interface {
+	SetToolCallObserver(observer ToolCallObserver)
+}
+
+// Reinitializer опциональный интерфейс провайдеров, умеющих пересоздавать соединения без перезапуска сервера
+type Reinitializer interface {
+	// Reinitialize закрывает соединения, сбрасывает кэш и инициализирует провайдер заново,
+	// возвращая количество доступных инструментов
+	Reinitialize(ctx context.Context) (int, error)
+}
+
+// DefaultTimeout предел запроса к модели, если Config.Timeout не задан
+const DefaultTimeout = 60 * time.Second
+
 // Config общая конфигурация для всех провайдеров
 type Config struct {
 	Provider string        `mapstructure:"provider"` // "openrouter", "gemini", etc.
 	BaseURL  string        `mapstructure:"base_url"`
 	APIKey   string        `mapstructure:"api_key"`
 	Model    string        `mapstructure:"model"`
-	Timeout  time.Duration `mapstructure:"timeout"`
+	Timeout  time.Duration `mapstructure:"timeout"` // предел всего запроса, включая цикл инструментов; 0 - DefaultTimeout
+
+	// Generation параметры сэмплирования всех запросов клиента
+	Generation GenerationConfig `mapstructure:"generation"`
+	// Safety пороги фильтров безопасности по категориям (только Gemini), см. SafetyCategoryNames
+	Safety map[string]string `mapstructure:"safety"`
+	// ToolMode режим вызова инструментов MCP: ToolModeAuto (по умолчанию), ToolModeAny или ToolModeNone
+	ToolMode string `mapstructure:"tool_mode"`
+	// Retry повторы запросов при временных ошибках API; нулевые значения заменяются DefaultRetry
+	Retry RetryConfig `mapstructure:"retry"`
 }
 
 // ProviderFactory создает провайдеров
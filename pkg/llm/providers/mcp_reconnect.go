@@ -0,0 +1,116 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"go.uber.org/zap"
+)
+
+// DefaultMCPReconnectAttempts попытки переподключения к MCP серверу по умолчанию
+const DefaultMCPReconnectAttempts = 3
+
+// Задержки между попытками переподключения растут вдвое от начальной до максимальной
+const (
+	mcpReconnectBaseDelay = 500 * time.Millisecond
+	mcpReconnectMaxDelay  = 5 * time.Second
+)
+
+// errMCPNotConnected соединение с MCP сервером потеряно, а переподключение ещё не выполнено
+var errMCPNotConnected = errors.New("MCP session is not connected")
+
+// isMCPConnectionError отличает потерю соединения с MCP сервером (сервер перезапущен,
+// сессия закрыта или забыта сервером) от ошибок самого инструмента
+func isMCPConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, errMCPNotConnected) || errors.Is(err, mcp.ErrConnectionClosed) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+
+	// Streamable транспорт SDK сообщает о забытой сервером сессии только текстом
+	msg := strings.ToLower(err.Error())
+	for _, marker := range []string{"broken session", "session is closed", "connection closed", "connection refused"} {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// currentSession текущая сессия сервера; nil, если соединение ещё не установлено или потеряно
+func (s *mcpServer) currentSession() *mcp.ClientSession {
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+	return s.session
+}
+
+// closeSession останавливает обновление инструментов и закрывает сессию сервера
+func (s *mcpServer) closeSession() {
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+
+	if s.refreshStop != nil {
+		close(s.refreshStop)
+		s.refreshStop = nil
+	}
+	if s.session != nil {
+		if err := s.session.Close(); err != nil {
+			s.logger.Warn("Failed to close MCP session", zap.Error(err))
+		}
+	}
+	s.session = nil
+	s.client = nil
+}
+
+// reconnectMCP заменяет потерянную сессию сервера новой, повторяя подключение с экспоненциальной задержкой.
+// Если сессию уже заменил другой запрос, переподключение не выполняется.
+func (p *MCPGeminiProvider) reconnectMCP(ctx context.Context, srv *mcpServer, stale *mcp.ClientSession) error {
+	srv.connectMu.Lock()
+	defer srv.connectMu.Unlock()
+
+	if current := srv.currentSession(); current != nil && current != stale {
+		return nil
+	}
+	srv.closeSession()
+
+	delay := mcpReconnectBaseDelay
+	var err error
+	for attempt := 1; attempt <= p.reconnectAttempts; attempt++ {
+		if err = p.connectServer(ctx, srv); err == nil {
+			srv.logger.Info("Reconnected to MCP server", zap.Int("attempt", attempt))
+			return nil
+		}
+		srv.logger.Warn("MCP reconnection attempt failed",
+			zap.Int("attempt", attempt),
+			zap.Int("max_attempts", p.reconnectAttempts),
+			zap.Error(err),
+		)
+		if attempt == p.reconnectAttempts {
+			break
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		delay = min(delay*2, mcpReconnectMaxDelay)
+	}
+
+	return fmt.Errorf("%w: failed to reconnect after %d attempts: %w", ErrMCPUnavailable, p.reconnectAttempts, err)
+}
@@ -0,0 +1,68 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestToolCallReconnectsAfterServerRestart(t *testing.T) {
+	server := newTestMCPServer(t, map[string]mcp.ToolHandler{
+		"echo": func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return textResult("pong"), nil
+		},
+	})
+	p := newTestProvider(t, server, nil)
+
+	// Сервер забыл сессию: первый вызов падает, провайдер переподключается и повторяет его
+	server.restart()
+	result, err := p.callMCPTool(context.Background(), "echo", nil)
+	if err != nil || result["result"] != "pong" {
+		t.Fatalf("call after restart = %v, %v; want pong", result, err)
+	}
+	if got := server.sessions.Load(); got != 2 {
+		t.Errorf("sessions = %d, want 2 (initial and reconnect)", got)
+	}
+
+	// Новая сессия используется дальше без переподключений
+	if _, err := p.callMCPTool(context.Background(), "echo", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.sessions.Load(); got != 2 {
+		t.Errorf("sessions = %d, want no extra reconnect", got)
+	}
+}
+
+func TestToolCallFailsWhenReconnectFails(t *testing.T) {
+	server := newTestMCPServer(t, map[string]mcp.ToolHandler{
+		"echo": func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return textResult("pong"), nil
+		},
+	})
+	p := newTestProvider(t, server, func(cfg *MCPProviderConfig) { cfg.ReconnectAttempts = 1 })
+
+	server.stop()
+	if _, err := p.callMCPTool(context.Background(), "echo", nil); !errors.Is(err, ErrMCPUnavailable) {
+		t.Errorf("err = %v, want ErrMCPUnavailable", err)
+	}
+}
+
+func TestIsMCPConnectionError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errMCPNotConnected, true},
+		{mcp.ErrConnectionClosed, true},
+		{errors.New("calling \"tools/call\": broken session: 404 Not Found"), true},
+		{errors.New("invalid arguments"), false},
+	}
+	for _, tt := range tests {
+		if got := isMCPConnectionError(tt.err); got != tt.want {
+			t.Errorf("isMCPConnectionError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
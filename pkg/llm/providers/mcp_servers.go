@@ -0,0 +1,260 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"go.uber.org/zap"
+)
+
+// MCPToolNameSeparator отделяет имя сервера от имени инструмента в именах, которые видит модель
+const MCPToolNameSeparator = "__"
+
+// Состояния соединения с MCP сервером
+const (
+	MCPServerConnected   = "connected"
+	MCPServerUnreachable = "unreachable"
+)
+
+// ErrUnknownTool имя инструмента не относится ни к одному из настроенных серверов
+var ErrUnknownTool = errors.New("unknown tool")
+
+// mcpServerNameRe имя сервера: латиница, цифры, дефис и одиночные подчёркивания внутри имени,
+// чтобы первое "__" в имени инструмента всегда отделяло префикс сервера
+var mcpServerNameRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*(_[A-Za-z0-9-]+)*$`)
+
+// MCPServerConfig подключение к одному MCP серверу
+type MCPServerConfig struct {
+	Name        string // префикс имён инструментов; пусто только у единственного сервера
+	Transport   string // MCPTransportHTTP, MCPTransportStdio или пусто (см. ResolveMCPTransport)
+	ServerURL   string
+	ServerPath  string            // stdio: исполняемый файл или скрипт сервера
+	PythonPath  string            // stdio: интерпретатор для ServerPath (необязательно)
+	ServerArgs  []string          // stdio: аргументы сервера
+	ServerEnv   map[string]string // stdio: дополнительные переменные окружения сервера
+	HTTPHeaders map[string]string
+}
+
+// ServerConfigs серверы провайдера: список Servers или единственный сервер из полей верхнего уровня.
+// Инструменты единственного сервера сохраняют исходные имена.
+func (c MCPProviderConfig) ServerConfigs() []MCPServerConfig {
+	if len(c.Servers) > 0 {
+		return c.Servers
+	}
+	return []MCPServerConfig{{
+		Transport:   c.Transport,
+		ServerURL:   c.ServerURL,
+		ServerPath:  c.ServerPath,
+		PythonPath:  c.PythonPath,
+		ServerArgs:  c.ServerArgs,
+		ServerEnv:   c.ServerEnv,
+		HTTPHeaders: c.HTTPHeaders,
+	}}
+}
+
+// ValidateMCPServerName проверяет имя сервера, которое становится префиксом имён его инструментов
+func ValidateMCPServerName(name string) error {
+	if !mcpServerNameRe.MatchString(name) {
+		return fmt.Errorf("invalid MCP server name %q: use latin letters, digits, '-' and single '_', starting with a letter", name)
+	}
+	return nil
+}
+
+// MCPServerInfo состояние соединения и число инструментов одного из нескольких MCP серверов
+type MCPServerInfo struct {
+	Name      string `json:"name"`
+	Transport string `json:"transport"`
+	Endpoint  string `json:"endpoint"` // server_url или server_path
+	Status    string `json:"status"`   // MCPServerConnected или MCPServerUnreachable
+	Exposed   int    `json:"exposed"`
+	Filtered  int    `json:"filtered"`
+	Error     string `json:"error,omitempty"`
+}
+
+// mcpServer соединение с одним MCP сервером
+type mcpServer struct {
+	cfg    MCPServerConfig
+	logger *zap.Logger
+
+	client      *mcp.Client
+	session     *mcp.ClientSession
+	refreshStop chan struct{} // останавливает периодическое обновление инструментов текущей сессии
+	sessionMu   sync.Mutex    // защищает session, client и refreshStop при переподключении
+	connectMu   sync.Mutex    // одно подключение, переподключение или обновление инструментов сервера за раз
+
+	tools     []*mcp.Tool     // инструменты под именами для модели; защищены toolsMu провайдера
+	resources []*mcp.Resource // защищены resourcesMu провайдера
+	prompts   []*mcp.Prompt   // защищены resourcesMu провайдера
+}
+
+func newMCPServer(cfg MCPServerConfig, logger *zap.Logger) *mcpServer {
+	cfg.Transport = ResolveMCPTransport(cfg)
+	if cfg.Name != "" {
+		logger = logger.With(zap.String("mcp_server", cfg.Name))
+	}
+	return &mcpServer{cfg: cfg, logger: logger}
+}
+
+func (s *mcpServer) validate() error {
+	var err error
+	switch s.cfg.Transport {
+	case MCPTransportHTTP:
+		if s.cfg.ServerURL == "" {
+			err = fmt.Errorf("MCP server URL is required")
+		}
+	case MCPTransportStdio:
+		if s.cfg.ServerPath == "" {
+			err = fmt.Errorf("MCP server path is required for stdio transport")
+		}
+	default:
+		err = fmt.Errorf("unsupported MCP transport: %s", s.cfg.Transport)
+	}
+	return s.wrapError(err)
+}
+
+// wrapError добавляет к ошибке имя сервера, если серверов несколько
+func (s *mcpServer) wrapError(err error) error {
+	if err == nil || s.cfg.Name == "" {
+		return err
+	}
+	return fmt.Errorf("MCP server %s: %w", s.cfg.Name, err)
+}
+
+// toolName имя инструмента сервера, которое видит модель
+func (s *mcpServer) toolName(name string) string {
+	if s.cfg.Name == "" {
+		return name
+	}
+	return s.cfg.Name + MCPToolNameSeparator + name
+}
+
+// exposedTools копии инструментов сервера под именами для модели
+func (s *mcpServer) exposedTools(tools []*mcp.Tool) []*mcp.Tool {
+	if s.cfg.Name == "" {
+		return tools
+	}
+	out := make([]*mcp.Tool, 0, len(tools))
+	for _, t := range tools {
+		named := *t
+		named.Name = s.toolName(t.Name)
+		out = append(out, &named)
+	}
+	return out
+}
+
+// info состояние сервера для /mcp/info
+func (s *mcpServer) info() MCPServerInfo {
+	endpoint := s.cfg.ServerURL
+	if s.cfg.Transport == MCPTransportStdio {
+		endpoint = s.cfg.ServerPath
+	}
+	return MCPServerInfo{Name: s.cfg.Name, Transport: s.cfg.Transport, Endpoint: endpoint}
+}
+
+// namedServers сообщает, настроено ли несколько серверов с префиксами имён инструментов
+func (p *MCPGeminiProvider) namedServers() bool {
+	return len(p.servers) > 1 || p.servers[0].cfg.Name != ""
+}
+
+// mcpConnected сообщает, подключён ли хотя бы один MCP сервер
+func (p *MCPGeminiProvider) mcpConnected() bool {
+	for _, srv := range p.servers {
+		if srv.currentSession() != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// initializeMCP подключает все неподключённые серверы. Недоступный сервер при нескольких серверах
+// только логируется: модель работает с инструментами остальных, а сервер подключается
+// при следующей проверке /mcp/info или переинициализации. Ошибка возвращается, если не подключён ни один.
+func (p *MCPGeminiProvider) initializeMCP(ctx context.Context) error {
+	errs := make([]error, len(p.servers))
+
+	var wg sync.WaitGroup
+	for i, srv := range p.servers {
+		if srv.currentSession() != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = srv.wrapError(p.ensureServerConnected(ctx, srv))
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil && p.namedServers() {
+			p.servers[i].logger.Warn("Failed to connect to MCP server", zap.Error(err))
+		}
+	}
+	if p.mcpConnected() {
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// ensureServerConnected подключает сервер, если он ещё не подключён. Параллельные запросы
+// (первые сообщения, проверка /mcp/info) ждут одного подключения вместо создания своих сессий.
+func (p *MCPGeminiProvider) ensureServerConnected(ctx context.Context, srv *mcpServer) error {
+	srv.connectMu.Lock()
+	defer srv.connectMu.Unlock()
+
+	if srv.currentSession() != nil {
+		return nil
+	}
+	return p.connectServer(ctx, srv)
+}
+
+// resolveTool находит сервер инструмента по имени, которое видит модель, и возвращает исходное имя
+func (p *MCPGeminiProvider) resolveTool(name string) (*mcpServer, string, error) {
+	if !p.namedServers() {
+		return p.servers[0], name, nil
+	}
+
+	serverName, toolName, ok := strings.Cut(name, MCPToolNameSeparator)
+	if ok {
+		for _, srv := range p.servers {
+			if srv.cfg.Name == serverName {
+				return srv, toolName, nil
+			}
+		}
+	}
+	return nil, "", fmt.Errorf("%w: no MCP server for tool %s", ErrUnknownTool, name)
+}
+
+// serverToolCounts запрашивает инструменты сервера, подключая его при необходимости
+func (p *MCPGeminiProvider) serverToolCounts(ctx context.Context, srv *mcpServer) (MCPServerInfo, error) {
+	info := srv.info()
+	info.Status = MCPServerUnreachable
+
+	if err := p.ensureServerConnected(ctx, srv); err != nil {
+		info.Error = err.Error()
+		return info, srv.wrapError(err)
+	}
+
+	session := srv.currentSession()
+	if session == nil {
+		info.Error = errMCPNotConnected.Error()
+		return info, srv.wrapError(errMCPNotConnected)
+	}
+	ltr, err := session.ListTools(ctx, &mcp.ListToolsParams{})
+	if err != nil {
+		err = fmt.Errorf("failed to list MCP tools: %w", err)
+		info.Error = err.Error()
+		return info, srv.wrapError(err)
+	}
+
+	exposed, filtered := p.toolFilter.Apply(srv.exposedTools(ltr.Tools))
+	info.Status = MCPServerConnected
+	info.Exposed = len(exposed)
+	info.Filtered = len(filtered)
+	return info, nil
+}
@@ -0,0 +1,81 @@
+package providers
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Транспорты MCP
+const (
+	MCPTransportHTTP  = "http"  // Streamable HTTP по ServerURL
+	MCPTransportStdio = "stdio" // локальный процесс сервера, обмен через stdin/stdout
+)
+
+// ResolveMCPTransport возвращает транспорт сервера. Без явного значения
+// используется stdio, если задан только путь к серверу, иначе HTTP.
+func ResolveMCPTransport(cfg MCPServerConfig) string {
+	transport := strings.ToLower(strings.TrimSpace(cfg.Transport))
+	if transport != "" {
+		return transport
+	}
+	if strings.TrimSpace(cfg.ServerURL) == "" && strings.TrimSpace(cfg.ServerPath) != "" {
+		return MCPTransportStdio
+	}
+	return MCPTransportHTTP
+}
+
+// mcpTransport создаёт транспорт для нового подключения. Процесс stdio сервера запускается
+// при подключении и останавливается при закрытии сессии (SDK закрывает stdin, затем
+// отправляет SIGTERM и SIGKILL), поэтому процесс не привязан к контексту запроса.
+func (s *mcpServer) mcpTransport() mcp.Transport {
+	if s.cfg.Transport == MCPTransportStdio {
+		return &mcp.CommandTransport{Command: s.stdioCommand()}
+	}
+
+	return &mcp.StreamableClientTransport{
+		Endpoint:   strings.TrimRight(s.cfg.ServerURL, "/"),
+		HTTPClient: httpClientWithHeaders(s.cfg.HTTPHeaders),
+	}
+}
+
+// mcpEndpoint адрес или команда сервера для логов
+func (s *mcpServer) mcpEndpoint() string {
+	if s.cfg.Transport == MCPTransportStdio {
+		return strings.Join(s.stdioCommand().Args, " ")
+	}
+	return s.cfg.ServerURL
+}
+
+// stdioCommand команда запуска локального сервера: ServerPath напрямую или через интерпретатор PythonPath.
+// Переменные ServerEnv дополняют окружение текущего процесса.
+func (s *mcpServer) stdioCommand() *exec.Cmd {
+	name, args := s.cfg.ServerPath, s.cfg.ServerArgs
+	if s.cfg.PythonPath != "" {
+		name, args = s.cfg.PythonPath, append([]string{s.cfg.ServerPath}, s.cfg.ServerArgs...)
+	}
+
+	cmd := exec.Command(name, args...)
+	cmd.Env = append(os.Environ(), flattenEnv(s.cfg.ServerEnv)...)
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+// flattenEnv переводит переменные окружения в формат KEY=VALUE в порядке имён
+func flattenEnv(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make([]string, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, fmt.Sprintf("%s=%s", k, env[k]))
+	}
+	return out
+}
@@ -0,0 +1,95 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// stdioServerEnv переменная, с которой тестовый бинарник работает как stdio MCP сервер
+const stdioServerEnv = "LLM_CHAT_TEST_STDIO_SERVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(stdioServerEnv) == "1" {
+		runStdioServer()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// runStdioServer крошечный MCP сервер на stdin/stdout с инструментом pid
+func runStdioServer() {
+	server := mcp.NewServer(&mcp.Implementation{Name: "stdio-test-server", Version: "1.0.0"}, nil)
+	server.AddTool(&mcp.Tool{Name: "pid", InputSchema: &jsonschema.Schema{Type: "object"}},
+		func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return textResult(strconv.Itoa(os.Getpid())), nil
+		})
+	if err := server.Run(context.Background(), mcp.NewStdioTransport()); err != nil {
+		os.Exit(1)
+	}
+}
+
+func TestResolveMCPTransport(t *testing.T) {
+	tests := []struct {
+		cfg  MCPServerConfig
+		want string
+	}{
+		{MCPServerConfig{ServerURL: "http://localhost:8000/mcp"}, MCPTransportHTTP},
+		{MCPServerConfig{ServerPath: "./server.py"}, MCPTransportStdio},
+		{MCPServerConfig{ServerURL: "http://localhost:8000/mcp", ServerPath: "./server.py"}, MCPTransportHTTP},
+		{MCPServerConfig{Transport: " STDIO ", ServerURL: "http://localhost:8000/mcp"}, MCPTransportStdio},
+		{MCPServerConfig{}, MCPTransportHTTP},
+	}
+	for _, tt := range tests {
+		if got := ResolveMCPTransport(tt.cfg); got != tt.want {
+			t.Errorf("ResolveMCPTransport(%+v) = %q, want %q", tt.cfg, got, tt.want)
+		}
+	}
+}
+
+func TestStdioTransport(t *testing.T) {
+	executable, err := os.Executable()
+	if err != nil {
+		t.Skipf("test binary path is unknown: %v", err)
+	}
+
+	p := newUnconnectedProvider(t, MCPProviderConfig{
+		ServerPath: executable,
+		ServerEnv:  map[string]string{stdioServerEnv: "1"},
+	}, nil)
+	srv := p.servers[0]
+	if srv.cfg.Transport != MCPTransportStdio {
+		t.Fatalf("transport = %q, want stdio", srv.cfg.Transport)
+	}
+	if err := p.connectServer(context.Background(), srv); err != nil {
+		t.Fatalf("connectServer: %v", err)
+	}
+
+	result, err := p.callMCPTool(context.Background(), "pid", nil)
+	if err != nil {
+		srv.closeSession()
+		t.Fatalf("callMCPTool: %v", err)
+	}
+	pid, err := strconv.Atoi(result["result"].(string))
+	if err != nil || pid == os.Getpid() {
+		srv.closeSession()
+		t.Fatalf("pid = %v, want a child process", result["result"])
+	}
+
+	// Закрытие сессии останавливает процесс сервера
+	srv.closeSession()
+	deadline := time.Now().Add(5 * time.Second)
+	for !errors.Is(syscall.Kill(pid, 0), syscall.ESRCH) {
+		if time.Now().After(deadline) {
+			t.Fatalf("stdio server %d is still running after the session was closed", pid)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
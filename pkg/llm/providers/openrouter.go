@@ -9,7 +9,6 @@ import (
 	"io"
 	"net/http"
 	"strings"
-	"time"
 
 	"go.uber.org/zap"
 )
@@ -18,6 +17,7 @@ type OpenRouterProvider struct {
 	baseURL    string
 	apiKey     string
 	model      string
+	generation GenerationConfig
 	httpClient *http.Client
 	logger     *zap.Logger
 }
@@ -28,9 +28,24 @@ type openRouterRequest struct {
 	Messages    []openRouterMessage `json:"messages"`
 	MaxTokens   int                 `json:"max_tokens,omitempty"`
 	Stream      bool                `json:"stream,omitempty"`
-	Temperature float64             `json:"temperature,omitempty"`
+	Temperature *float64            `json:"temperature,omitempty"`
+	TopP        *float32            `json:"top_p,omitempty"`
+	TopK        *int32              `json:"top_k,omitempty"`
+	Seed        *int64              `json:"seed,omitempty"`
+	Stop        []string            `json:"stop,omitempty"`
 }
 
+const (
+	// defaultTemperature температура запросов без воспроизводимой генерации и llm.generation.temperature
+	defaultTemperature = 0.7
+	// defaultMaxTokens лимит длины ответа, если ни запрос, ни llm.generation не задают max_output_tokens
+	defaultMaxTokens = 1000
+	// finishReasonLength причина завершения OpenAI-совместимого API при достижении max_tokens
+	finishReasonLength = "length"
+	// nativeFinishReasonStopSequence исходная причина завершения по стоп-последовательности
+	nativeFinishReasonStopSequence = "stop_sequence"
+)
+
 type openRouterMessage struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
@@ -50,6 +65,9 @@ type openRouterChoice struct {
 	Message      openRouterMessage `json:"message"`
 	Delta        openRouterDelta   `json:"delta,omitempty"`
 	FinishReason string            `json:"finish_reason"`
+
+	// NativeFinishReason причина завершения в терминах исходного провайдера модели
+	NativeFinishReason string `json:"native_finish_reason,omitempty"`
 }
 
 type openRouterDelta struct {
@@ -72,14 +90,15 @@ type openRouterStreamResponse struct {
 }
 
 func NewOpenRouterProvider(config Config, logger *zap.Logger) (Provider, error) {
-	if config.Timeout == 0 {
-		config.Timeout = 60 * time.Second
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
 	}
 
 	provider := &OpenRouterProvider{
-		baseURL: config.BaseURL,
-		apiKey:  config.APIKey,
-		model:   config.Model,
+		baseURL:    config.BaseURL,
+		apiKey:     config.APIKey,
+		model:      config.Model,
+		generation: config.Generation,
 		httpClient: &http.Client{
 			Timeout: config.Timeout,
 		},
@@ -102,15 +121,23 @@ func (p *OpenRouterProvider) ValidateConfig() error {
 		return fmt.Errorf("base URL is required for OpenRouter")
 	}
 	if p.apiKey == "" {
-		return fmt.Errorf("API key is required for OpenRouter")
+		return fmt.Errorf("OpenRouter %w", ErrAPIKeyNotSet)
 	}
 	if p.model == "" {
 		return fmt.Errorf("model is required for OpenRouter")
 	}
+	if err := p.generation.Validate(); err != nil {
+		return fmt.Errorf("invalid generation config: %w", err)
+	}
 	return nil
 }
 
 func (p *OpenRouterProvider) GetSupportedModels() []string {
+	return OpenRouterModels()
+}
+
+// OpenRouterModels модели OpenRouter, которые сервис предлагает в /models
+func OpenRouterModels() []string {
 	return []string{
 		"google/gemma-3-27b-it:free",
 		"anthropic/claude-sonnet-4",
@@ -119,6 +146,20 @@ func (p *OpenRouterProvider) GetSupportedModels() []string {
 	}
 }
 
+// SupportsPrefill начало ответа передаётся завершающим сообщением assistant,
+// модели OpenRouter/OpenAI продолжают его и возвращают только продолжение
+func (p *OpenRouterProvider) SupportsPrefill() bool {
+	return true
+}
+
+// modelFor возвращает модель запроса с учётом RequestOptions
+func (p *OpenRouterProvider) modelFor(ctx context.Context) string {
+	if opts := RequestOptionsFromContext(ctx); opts.Model != "" {
+		return opts.Model
+	}
+	return p.model
+}
+
 func (p *OpenRouterProvider) ChatCompletion(ctx context.Context, messages []Message) (*ChatResponse, error) {
 	// Конвертируем в формат OpenRouter
 	orMessages := make([]openRouterMessage, len(messages))
@@ -130,12 +171,11 @@ func (p *OpenRouterProvider) ChatCompletion(ctx context.Context, messages []Mess
 	}
 
 	req := openRouterRequest{
-		Model:       p.model,
-		Messages:    orMessages,
-		MaxTokens:   1000,
-		Stream:      false,
-		Temperature: 0.7,
+		Model:    p.modelFor(ctx),
+		Messages: orMessages,
+		Stream:   false,
 	}
+	generation := applyGeneration(&req, p.generation, RequestOptionsFromContext(ctx))
 
 	reqBody, err := json.Marshal(req)
 	if err != nil {
@@ -157,7 +197,7 @@ func (p *OpenRouterProvider) ChatCompletion(ctx context.Context, messages []Mess
 
 	resp, err := p.httpClient.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", apiError(err))
 	}
 	defer resp.Body.Close()
 
@@ -167,7 +207,7 @@ func (p *OpenRouterProvider) ChatCompletion(ctx context.Context, messages []Mess
 			zap.Int("status_code", resp.StatusCode),
 			zap.String("response_body", string(body)),
 		)
-		return nil, fmt.Errorf("API error: %d - %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("%w: API error: %d - %s", statusErrorClass(resp.StatusCode), resp.StatusCode, string(body))
 	}
 
 	var orResp openRouterResponse
@@ -176,7 +216,57 @@ func (p *OpenRouterProvider) ChatCompletion(ctx context.Context, messages []Mess
 	}
 
 	// Конвертируем в универсальный формат
-	return p.convertResponse(&orResp), nil
+	chatResp := p.convertResponse(&orResp)
+	chatResp.Generation = generation
+	return chatResp, nil
+}
+
+// applyGeneration задаёт параметры сэмплирования клиента, лимит длины ответа и параметры воспроизводимой генерации.
+// OpenRouter передаёт seed моделям, которые его поддерживают; Deterministic включает жадное декодирование.
+// Поиск Google доступен только провайдеру Gemini - запрос выполняется без него с предупреждением.
+func applyGeneration(req *openRouterRequest, config GenerationConfig, opts RequestOptions) *GenerationInfo {
+	temperature := defaultTemperature
+	if config.Temperature != nil {
+		temperature = float64(*config.Temperature)
+	}
+	req.Temperature = &temperature
+	req.TopP = config.TopP
+	req.TopK = config.TopK
+
+	req.Stop = config.StopSequences
+	if len(opts.Stop) > 0 {
+		req.Stop = opts.Stop
+	}
+	req.MaxTokens = defaultMaxTokens
+	if config.MaxOutputTokens > 0 {
+		req.MaxTokens = int(config.MaxOutputTokens)
+	}
+	if opts.MaxOutputTokens > 0 {
+		req.MaxTokens = opts.MaxOutputTokens
+	}
+	maxTokens := int32(req.MaxTokens)
+
+	info := &GenerationInfo{MaxOutputTokens: &maxTokens}
+	if opts.GoogleSearch != nil && *opts.GoogleSearch {
+		info.Warnings = append(info.Warnings, GroundingUnavailableWarning("openrouter", "not supported by the provider"))
+	}
+	if !opts.Reproducible() {
+		return info
+	}
+
+	req.Seed = opts.Seed
+	if opts.Deterministic {
+		topK := GreedyTopK
+		temperature = float64(GreedyTemperature)
+		req.TopK = &topK
+	}
+
+	applied := float32(temperature)
+	info.Seed = req.Seed
+	info.Temperature = &applied
+	info.TopK = req.TopK
+	info.Deterministic = opts.Deterministic
+	return info
 }
 
 func (p *OpenRouterProvider) ChatCompletionStream(ctx context.Context, messages []Message) (<-chan StreamChunk, error) {
@@ -190,12 +280,11 @@ func (p *OpenRouterProvider) ChatCompletionStream(ctx context.Context, messages
 	}
 
 	req := openRouterRequest{
-		Model:       p.model,
-		Messages:    orMessages,
-		MaxTokens:   1000,
-		Stream:      true,
-		Temperature: 0.7,
+		Model:    p.modelFor(ctx),
+		Messages: orMessages,
+		Stream:   true,
 	}
+	generation := applyGeneration(&req, p.generation, RequestOptionsFromContext(ctx))
 
 	reqBody, err := json.Marshal(req)
 	if err != nil {
@@ -218,7 +307,7 @@ func (p *OpenRouterProvider) ChatCompletionStream(ctx context.Context, messages
 
 	resp, err := p.httpClient.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", apiError(err))
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -228,16 +317,16 @@ func (p *OpenRouterProvider) ChatCompletionStream(ctx context.Context, messages
 			zap.Int("status_code", resp.StatusCode),
 			zap.String("response_body", string(body)),
 		)
-		return nil, fmt.Errorf("API error: %d - %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("%w: API error: %d - %s", statusErrorClass(resp.StatusCode), resp.StatusCode, string(body))
 	}
 
 	chunks := make(chan StreamChunk, 100)
-	go p.handleStreamResponse(ctx, resp.Body, chunks)
+	go p.handleStreamResponse(ctx, resp.Body, chunks, generation)
 
 	return chunks, nil
 }
 
-func (p *OpenRouterProvider) handleStreamResponse(ctx context.Context, body io.ReadCloser, chunks chan<- StreamChunk) {
+func (p *OpenRouterProvider) handleStreamResponse(ctx context.Context, body io.ReadCloser, chunks chan<- StreamChunk, generation *GenerationInfo) {
 	defer close(chunks)
 	defer body.Close()
 
@@ -260,7 +349,7 @@ func (p *OpenRouterProvider) handleStreamResponse(ctx context.Context, body io.R
 		data := strings.TrimPrefix(line, "data: ")
 
 		if data == "[DONE]" {
-			chunks <- StreamChunk{Done: true}
+			chunks <- StreamChunk{Done: true, Generation: generation}
 			return
 		}
 
@@ -277,7 +366,7 @@ func (p *OpenRouterProvider) handleStreamResponse(ctx context.Context, body io.R
 			}
 
 			if choice.FinishReason != "" {
-				chunks <- StreamChunk{Done: true}
+				chunks <- StreamChunk{Done: true, Generation: generation, FinishReason: convertFinishReason(choice)}
 				return
 			}
 		}
@@ -297,7 +386,7 @@ func (p *OpenRouterProvider) convertResponse(orResp *openRouterResponse) *ChatRe
 				Role:    choice.Message.Role,
 				Content: choice.Message.Content,
 			},
-			FinishReason: choice.FinishReason,
+			FinishReason: convertFinishReason(choice),
 		}
 	}
 
@@ -312,3 +401,16 @@ func (p *OpenRouterProvider) convertResponse(orResp *openRouterResponse) *ChatRe
 		},
 	}
 }
+
+// convertFinishReason приводит причину завершения OpenRouter к общим константам провайдеров.
+// Остановку по стоп-последовательности OpenAI-совместимый API сообщает как "stop",
+// поэтому она различается только по native_finish_reason (например, у моделей Anthropic).
+func convertFinishReason(choice openRouterChoice) string {
+	switch {
+	case choice.FinishReason == finishReasonLength:
+		return FinishReasonMaxTokens
+	case choice.NativeFinishReason == nativeFinishReasonStopSequence:
+		return FinishReasonStopSequence
+	}
+	return choice.FinishReason
+}
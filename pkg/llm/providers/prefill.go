@@ -0,0 +1,28 @@
+package providers
+
+import "errors"
+
+// ErrPrefillNotSupported провайдер не умеет продолжать ответ ассистента с заданного начала
+var ErrPrefillNotSupported = errors.New("assistant prefill is not supported by provider")
+
+// PrefillSupporter опциональный интерфейс провайдеров, продолжающих ответ с переданного начала (prefill).
+// Начало ответа передаётся последним сообщением с ролью assistant; провайдер возвращает только продолжение.
+type PrefillSupporter interface {
+	SupportsPrefill() bool
+}
+
+// SplitAssistantPrefix отделяет завершающее сообщение ассистента, с которого должен начинаться ответ.
+// Если диалог заканчивается не репликой ассистента, префикс пустой.
+func SplitAssistantPrefix(messages []Message) ([]Message, string) {
+	if !HasAssistantPrefix(messages) {
+		return messages, ""
+	}
+
+	last := len(messages) - 1
+	return messages[:last], messages[last].Content
+}
+
+// HasAssistantPrefix сообщает, заканчивается ли диалог началом ответа ассистента
+func HasAssistantPrefix(messages []Message) bool {
+	return len(messages) > 0 && messages[len(messages)-1].Role == "assistant"
+}
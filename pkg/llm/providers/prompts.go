@@ -0,0 +1,244 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrPromptsNotSupported провайдер не умеет получать промпты MCP
+	ErrPromptsNotSupported = errors.New("MCP prompts are not supported")
+
+	// ErrPromptNotFound промпта с таким именем нет ни у одного из подключённых серверов
+	ErrPromptNotFound = errors.New("MCP prompt not found")
+
+	// ErrPromptArguments не переданы обязательные аргументы промпта (см. PromptArgumentsError)
+	ErrPromptArguments = errors.New("missing required MCP prompt arguments")
+)
+
+// PromptArgument аргумент шаблона промпта
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required"`
+}
+
+// PromptInfo шаблон промпта MCP сервера (prompts/list)
+type PromptInfo struct {
+	Name        string           `json:"name"` // с префиксом сервера, если их несколько
+	Title       string           `json:"title,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments"`
+	Server      string           `json:"server,omitempty"`
+}
+
+// PromptArgumentsError обязательные аргументы промпта не переданы; Arguments - полная схема аргументов
+type PromptArgumentsError struct {
+	Prompt    string
+	Missing   []string
+	Arguments []PromptArgument
+}
+
+func (e *PromptArgumentsError) Error() string {
+	return fmt.Sprintf("%s: prompt %s requires %s", ErrPromptArguments, e.Prompt, strings.Join(e.Missing, ", "))
+}
+
+func (e *PromptArgumentsError) Unwrap() error {
+	return ErrPromptArguments
+}
+
+// PromptProvider опциональный интерфейс провайдеров, которые получают промпты MCP серверов
+type PromptProvider interface {
+	// ListPrompts возвращает известные промпты; refresh заново запрашивает список у серверов
+	ListPrompts(ctx context.Context, refresh bool) ([]PromptInfo, error)
+	// GetPrompt подставляет аргументы в шаблон и возвращает текст промпта
+	GetPrompt(ctx context.Context, name string, args map[string]string) (string, error)
+}
+
+// supportsPrompts сообщает, объявил ли сервер поддержку промптов при инициализации
+func supportsPrompts(session *mcp.ClientSession) bool {
+	init := session.InitializeResult()
+	return init != nil && init.Capabilities != nil && init.Capabilities.Prompts != nil
+}
+
+// loadServerPrompts запрашивает список промптов сессии и заменяет промпты сервера.
+// Сервер без поддержки промптов получает пустой список.
+func (p *MCPGeminiProvider) loadServerPrompts(ctx context.Context, srv *mcpServer, session *mcp.ClientSession) error {
+	var prompts []*mcp.Prompt
+	if supportsPrompts(session) {
+		for prompt, err := range session.Prompts(ctx, &mcp.ListPromptsParams{}) {
+			if err != nil {
+				return fmt.Errorf("failed to list MCP prompts: %w", err)
+			}
+			prompts = append(prompts, prompt)
+		}
+	}
+
+	p.resourcesMu.Lock()
+	srv.prompts = prompts
+	p.resourcesMu.Unlock()
+	return nil
+}
+
+// ListPrompts возвращает промпты подключённых серверов. Если соединений ещё нет, серверы подключаются.
+// С refresh списки заново запрашиваются у всех подключённых серверов; ошибка возвращается,
+// только если не ответил ни один.
+func (p *MCPGeminiProvider) ListPrompts(ctx context.Context, refresh bool) ([]PromptInfo, error) {
+	if p.reinitializing.Load() {
+		return nil, ErrProviderReinitializing
+	}
+
+	p.lifecycleMu.RLock()
+	defer p.lifecycleMu.RUnlock()
+
+	if !p.mcpConnected() {
+		if err := p.initializeMCP(ctx); err != nil {
+			return nil, err
+		}
+	} else if refresh {
+		if err := p.reloadPrompts(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return p.promptInfos(), nil
+}
+
+// GetPrompt получает промпт по имени из списка (с префиксом сервера, если их несколько).
+// Неизвестное имя сначала ищется в заново запрошенных списках. Обязательные аргументы проверяются
+// до запроса к серверу; текстовые части сообщений промпта объединяются.
+func (p *MCPGeminiProvider) GetPrompt(ctx context.Context, name string, args map[string]string) (string, error) {
+	if p.reinitializing.Load() {
+		return "", ErrProviderReinitializing
+	}
+
+	p.lifecycleMu.RLock()
+	defer p.lifecycleMu.RUnlock()
+
+	if !p.mcpConnected() {
+		if err := p.initializeMCP(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	srv, prompt := p.findPrompt(name)
+	if srv == nil {
+		if err := p.reloadPrompts(ctx); err != nil {
+			return "", err
+		}
+		if srv, prompt = p.findPrompt(name); srv == nil {
+			return "", fmt.Errorf("%w: %s", ErrPromptNotFound, name)
+		}
+	}
+
+	var missing []string
+	for _, arg := range prompt.Arguments {
+		if arg.Required && strings.TrimSpace(args[arg.Name]) == "" {
+			missing = append(missing, arg.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", &PromptArgumentsError{Prompt: name, Missing: missing, Arguments: promptArguments(prompt)}
+	}
+
+	session := srv.currentSession()
+	if session == nil {
+		return "", srv.wrapError(errMCPNotConnected)
+	}
+	res, err := session.GetPrompt(ctx, &mcp.GetPromptParams{Name: prompt.Name, Arguments: args})
+	if err != nil {
+		return "", srv.wrapError(fmt.Errorf("failed to get MCP prompt %s: %w", prompt.Name, err))
+	}
+
+	var texts []string
+	for _, msg := range res.Messages {
+		if tc, ok := msg.Content.(*mcp.TextContent); ok && strings.TrimSpace(tc.Text) != "" {
+			texts = append(texts, tc.Text)
+		}
+	}
+	if len(texts) == 0 {
+		return "", srv.wrapError(fmt.Errorf("MCP prompt %s has no text content", prompt.Name))
+	}
+
+	srv.logger.Debug("MCP prompt resolved", zap.String("prompt", prompt.Name), zap.Int("messages", len(texts)))
+	return strings.Join(texts, "\n\n"), nil
+}
+
+// reloadPrompts заново запрашивает промпты всех подключённых серверов;
+// ошибка возвращается, только если не ответил ни один
+func (p *MCPGeminiProvider) reloadPrompts(ctx context.Context) error {
+	attempted := 0
+	var errs []error
+	for _, srv := range p.servers {
+		session := srv.currentSession()
+		if session == nil {
+			continue
+		}
+		attempted++
+		if err := p.loadServerPrompts(ctx, srv, session); err != nil {
+			errs = append(errs, srv.wrapError(err))
+		}
+	}
+	if attempted > 0 && len(errs) == attempted {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// findPrompt сервер и промпт по имени из списка. Имена промптов получают тот же префикс сервера,
+// что и имена инструментов.
+func (p *MCPGeminiProvider) findPrompt(name string) (*mcpServer, *mcp.Prompt) {
+	p.resourcesMu.RLock()
+	defer p.resourcesMu.RUnlock()
+
+	for _, srv := range p.servers {
+		for _, prompt := range srv.prompts {
+			if srv.toolName(prompt.Name) == name {
+				return srv, prompt
+			}
+		}
+	}
+	return nil, nil
+}
+
+// promptInfos описания известных промптов в порядке серверов
+func (p *MCPGeminiProvider) promptInfos() []PromptInfo {
+	p.resourcesMu.RLock()
+	defer p.resourcesMu.RUnlock()
+
+	var infos []PromptInfo
+	for _, srv := range p.servers {
+		for _, prompt := range srv.prompts {
+			infos = append(infos, PromptInfo{
+				Name:        srv.toolName(prompt.Name),
+				Title:       prompt.Title,
+				Description: prompt.Description,
+				Arguments:   promptArguments(prompt),
+				Server:      srv.cfg.Name,
+			})
+		}
+	}
+	return infos
+}
+
+func promptArguments(prompt *mcp.Prompt) []PromptArgument {
+	args := make([]PromptArgument, 0, len(prompt.Arguments))
+	for _, arg := range prompt.Arguments {
+		if arg == nil {
+			continue
+		}
+		args = append(args, PromptArgument{
+			Name:        arg.Name,
+			Title:       arg.Title,
+			Description: arg.Description,
+			Required:    arg.Required,
+		})
+	}
+	return args
+}
@@ -0,0 +1,10 @@
+package providers
+
+import "LLM_Chat/pkg/redact"
+
+// Redactable опциональный интерфейс провайдеров, маскирующих персональные данные
+// в аргументах и результатах инструментов перед записью в журнал аудита и логи.
+// В сам инструмент и в LLM данные передаются без изменений.
+type Redactable interface {
+	SetRedactor(redactor *redact.Redactor)
+}
@@ -0,0 +1,52 @@
+package providers
+
+import "context"
+
+// RequestOptions параметры генерации, переопределяемые для отдельного запроса
+type RequestOptions struct {
+	// Model имя модели; пустое значение означает модель из конфигурации провайдера
+	Model string
+
+	// Seed зерно генерации для воспроизводимых ответов; nil - не задано
+	Seed *int64
+
+	// Deterministic требует жадного декодирования (temperature 0, top_k 1)
+	Deterministic bool
+
+	// MaxIterations лимит итераций цикла вызова инструментов; 0 - лимит провайдера
+	MaxIterations int
+
+	// MaxOutputTokens лимит длины ответа в токенах; 0 - значение провайдера по умолчанию
+	MaxOutputTokens int
+
+	// Stop стоп-последовательности: генерация останавливается перед ними, сама последовательность в ответ не входит
+	Stop []string
+
+	// GoogleSearch включает или выключает grounding поиском Google; nil - настройка провайдера
+	GoogleSearch *bool
+
+	// ToolMode режим вызова инструментов (ToolModeAuto, ToolModeAny, ToolModeNone); пусто - настройка провайдера
+	ToolMode string
+
+	// SessionID и UserID инициатора запроса для журнала аудита вызовов инструментов
+	SessionID string
+	UserID    string
+}
+
+// Reproducible сообщает, запрошена ли воспроизводимая генерация
+func (o RequestOptions) Reproducible() bool {
+	return o.Seed != nil || o.Deterministic
+}
+
+type requestOptionsKey struct{}
+
+// WithRequestOptions возвращает контекст с параметрами генерации для одного запроса
+func WithRequestOptions(ctx context.Context, opts RequestOptions) context.Context {
+	return context.WithValue(ctx, requestOptionsKey{}, opts)
+}
+
+// RequestOptionsFromContext извлекает параметры запроса (нулевое значение, если не заданы)
+func RequestOptionsFromContext(ctx context.Context) RequestOptions {
+	opts, _ := ctx.Value(requestOptionsKey{}).(RequestOptions)
+	return opts
+}
@@ -0,0 +1,212 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrResourcesNotSupported провайдер не умеет читать ресурсы MCP
+	ErrResourcesNotSupported = errors.New("MCP resources are not supported")
+
+	// ErrResourceNotFound ресурса с таким URI нет ни у одного из подключённых серверов
+	ErrResourceNotFound = errors.New("MCP resource not found")
+
+	// ErrResourceNotText ресурс не содержит текста (только двоичные данные)
+	ErrResourceNotText = errors.New("MCP resource has no text content")
+)
+
+// ResourceInfo ресурс MCP сервера (resources/list)
+type ResourceInfo struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	MIMEType    string `json:"mime_type,omitempty"`
+	Size        int64  `json:"size,omitempty"`   // размер в байтах, если сервер его сообщил
+	Server      string `json:"server,omitempty"` // сервер, если их несколько (см. MCPProviderConfig.Servers)
+}
+
+// ResourceContent текстовое содержимое ресурса (resources/read)
+type ResourceContent struct {
+	URI      string
+	Name     string
+	MIMEType string
+	Text     string
+}
+
+// ResourceReader опциональный интерфейс провайдеров, которые читают ресурсы MCP серверов
+type ResourceReader interface {
+	// ListResources возвращает известные ресурсы; refresh заново запрашивает список у серверов
+	ListResources(ctx context.Context, refresh bool) ([]ResourceInfo, error)
+	// ReadResource читает текстовое содержимое ресурса по URI
+	ReadResource(ctx context.Context, uri string) (*ResourceContent, error)
+}
+
+// supportsResources сообщает, объявил ли сервер поддержку ресурсов при инициализации
+func supportsResources(session *mcp.ClientSession) bool {
+	init := session.InitializeResult()
+	return init != nil && init.Capabilities != nil && init.Capabilities.Resources != nil
+}
+
+// loadServerResources запрашивает список ресурсов сессии и заменяет ресурсы сервера.
+// Сервер без поддержки ресурсов получает пустой список.
+func (p *MCPGeminiProvider) loadServerResources(ctx context.Context, srv *mcpServer, session *mcp.ClientSession) error {
+	var resources []*mcp.Resource
+	if supportsResources(session) {
+		for resource, err := range session.Resources(ctx, &mcp.ListResourcesParams{}) {
+			if err != nil {
+				return fmt.Errorf("failed to list MCP resources: %w", err)
+			}
+			resources = append(resources, resource)
+		}
+	}
+
+	p.resourcesMu.Lock()
+	srv.resources = resources
+	p.resourcesMu.Unlock()
+	return nil
+}
+
+// ListResources возвращает ресурсы подключённых серверов. Если соединений ещё нет, серверы подключаются.
+// С refresh списки заново запрашиваются у всех подключённых серверов; ошибка возвращается,
+// только если не ответил ни один.
+func (p *MCPGeminiProvider) ListResources(ctx context.Context, refresh bool) ([]ResourceInfo, error) {
+	if p.reinitializing.Load() {
+		return nil, ErrProviderReinitializing
+	}
+
+	p.lifecycleMu.RLock()
+	defer p.lifecycleMu.RUnlock()
+
+	if !p.mcpConnected() {
+		if err := p.initializeMCP(ctx); err != nil {
+			return nil, err
+		}
+	} else if refresh {
+		if err := p.reloadResources(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return p.resourceInfos(), nil
+}
+
+// ReadResource читает ресурс с сервера, в списке которого он есть. Неизвестный URI сначала
+// ищется в заново запрошенных списках: ресурс мог появиться после подключения.
+// Текстовые части содержимого объединяются; ресурс только с двоичными данными - ErrResourceNotText.
+func (p *MCPGeminiProvider) ReadResource(ctx context.Context, uri string) (*ResourceContent, error) {
+	if p.reinitializing.Load() {
+		return nil, ErrProviderReinitializing
+	}
+
+	p.lifecycleMu.RLock()
+	defer p.lifecycleMu.RUnlock()
+
+	if !p.mcpConnected() {
+		if err := p.initializeMCP(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	srv, resource := p.findResource(uri)
+	if srv == nil {
+		if err := p.reloadResources(ctx); err != nil {
+			return nil, err
+		}
+		if srv, resource = p.findResource(uri); srv == nil {
+			return nil, fmt.Errorf("%w: %s", ErrResourceNotFound, uri)
+		}
+	}
+
+	session := srv.currentSession()
+	if session == nil {
+		return nil, srv.wrapError(errMCPNotConnected)
+	}
+	res, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: uri})
+	if err != nil {
+		return nil, srv.wrapError(fmt.Errorf("failed to read MCP resource %s: %w", uri, err))
+	}
+
+	content := &ResourceContent{URI: uri, Name: resource.Name, MIMEType: resource.MIMEType}
+	var texts []string
+	for _, c := range res.Contents {
+		if c == nil || c.Text == "" {
+			continue
+		}
+		texts = append(texts, c.Text)
+		if content.MIMEType == "" {
+			content.MIMEType = c.MIMEType
+		}
+	}
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrResourceNotText, uri)
+	}
+	content.Text = strings.Join(texts, "\n\n")
+
+	srv.logger.Debug("MCP resource read", zap.String("uri", uri), zap.Int("bytes", len(content.Text)))
+	return content, nil
+}
+
+// reloadResources заново запрашивает ресурсы всех подключённых серверов;
+// ошибка возвращается, только если не ответил ни один
+func (p *MCPGeminiProvider) reloadResources(ctx context.Context) error {
+	attempted := 0
+	var errs []error
+	for _, srv := range p.servers {
+		session := srv.currentSession()
+		if session == nil {
+			continue
+		}
+		attempted++
+		if err := p.loadServerResources(ctx, srv, session); err != nil {
+			errs = append(errs, srv.wrapError(err))
+		}
+	}
+	if attempted > 0 && len(errs) == attempted {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// findResource сервер и описание ресурса по URI; при совпадении URI у нескольких серверов - первый
+func (p *MCPGeminiProvider) findResource(uri string) (*mcpServer, *mcp.Resource) {
+	p.resourcesMu.RLock()
+	defer p.resourcesMu.RUnlock()
+
+	for _, srv := range p.servers {
+		for _, r := range srv.resources {
+			if r.URI == uri {
+				return srv, r
+			}
+		}
+	}
+	return nil, nil
+}
+
+// resourceInfos описания известных ресурсов в порядке серверов
+func (p *MCPGeminiProvider) resourceInfos() []ResourceInfo {
+	p.resourcesMu.RLock()
+	defer p.resourcesMu.RUnlock()
+
+	var infos []ResourceInfo
+	for _, srv := range p.servers {
+		for _, r := range srv.resources {
+			infos = append(infos, ResourceInfo{
+				URI:         r.URI,
+				Name:        r.Name,
+				Title:       r.Title,
+				Description: r.Description,
+				MIMEType:    r.MIMEType,
+				Size:        r.Size,
+				Server:      srv.cfg.Name,
+			})
+		}
+	}
+	return infos
+}
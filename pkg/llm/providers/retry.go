@@ -0,0 +1,160 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"go.uber.org/zap"
+	"google.golang.org/api/googleapi"
+)
+
+// Значения llm.retry по умолчанию
+const (
+	DefaultRetryMaxAttempts  = 3
+	DefaultRetryInitialDelay = 500 * time.Millisecond
+	DefaultRetryMaxDelay     = 8 * time.Second
+	DefaultRetryMultiplier   = 2.0
+)
+
+// RetryConfig повторы запроса к модели при временных ошибках API (429, 5xx).
+// Повторяется отдельный ход диалога, поэтому уже выполненные вызовы инструментов не повторяются.
+type RetryConfig struct {
+	MaxAttempts  int           `mapstructure:"max_attempts"`  // попытки хода, включая первую; 1 - без повторов
+	InitialDelay time.Duration `mapstructure:"initial_delay"` // пауза перед первым повтором
+	MaxDelay     time.Duration `mapstructure:"max_delay"`     // предел паузы между попытками
+	Multiplier   float64       `mapstructure:"multiplier"`    // рост паузы с каждой попыткой
+}
+
+// DefaultRetry параметры повторов по умолчанию
+func DefaultRetry() RetryConfig {
+	return RetryConfig{
+		MaxAttempts:  DefaultRetryMaxAttempts,
+		InitialDelay: DefaultRetryInitialDelay,
+		MaxDelay:     DefaultRetryMaxDelay,
+		Multiplier:   DefaultRetryMultiplier,
+	}
+}
+
+// Validate проверяет параметры повторов
+func (r RetryConfig) Validate() error {
+	if r.MaxAttempts < 1 {
+		return fmt.Errorf("max_attempts must be at least 1: %d", r.MaxAttempts)
+	}
+	if r.InitialDelay < 0 {
+		return fmt.Errorf("initial_delay cannot be negative: %s", r.InitialDelay)
+	}
+	if r.MaxDelay < r.InitialDelay {
+		return fmt.Errorf("max_delay (%s) cannot be less than initial_delay (%s)", r.MaxDelay, r.InitialDelay)
+	}
+	if r.Multiplier < 1 {
+		return fmt.Errorf("multiplier must be at least 1: %g", r.Multiplier)
+	}
+	return nil
+}
+
+// withDefaults заполняет незаданные параметры значениями по умолчанию
+func (r RetryConfig) withDefaults() RetryConfig {
+	defaults := DefaultRetry()
+	if r.MaxAttempts <= 0 {
+		r.MaxAttempts = defaults.MaxAttempts
+	}
+	if r.InitialDelay <= 0 {
+		r.InitialDelay = defaults.InitialDelay
+	}
+	if r.MaxDelay <= 0 {
+		r.MaxDelay = max(defaults.MaxDelay, r.InitialDelay)
+	}
+	if r.Multiplier < 1 {
+		r.Multiplier = defaults.Multiplier
+	}
+	return r
+}
+
+// backoff пауза перед повтором после неудачной попытки attempt (с 1): экспоненциальный рост
+// с равномерным разбросом во второй половине интервала. Retry-After сервера имеет приоритет,
+// но не превышает MaxDelay.
+func (r RetryConfig) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return min(retryAfter, r.MaxDelay)
+	}
+	delay := float64(r.InitialDelay) * math.Pow(r.Multiplier, float64(attempt-1))
+	delay = min(delay, float64(r.MaxDelay))
+	half := delay / 2
+	return time.Duration(half + rand.Float64()*half)
+}
+
+// retryableStatuses статусы API модели, после которых запрос имеет смысл повторить
+var retryableStatuses = map[int]bool{
+	http.StatusRequestTimeout:      true,
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// retryableStatus возвращает HTTP статус временной ошибки API и паузу из Retry-After
+func retryableStatus(err error) (status int, retryAfter time.Duration, ok bool) {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || !retryableStatuses[apiErr.Code] {
+		return 0, 0, false
+	}
+	if seconds, err := strconv.Atoi(apiErr.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		retryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr.Code, retryAfter, true
+}
+
+// sendWithRetry отправляет ход диалога и повторяет его при временных ошибках API.
+// Неудачная попытка оставляет реплику в истории сессии, поэтому перед повтором история
+// возвращается к исходной длине. Ход, часть текста которого уже передана в onText,
+// не повторяется: иначе клиент получил бы текст дважды. Возвращает и переданный текст хода.
+func (p *MCPGeminiProvider) sendWithRetry(ctx context.Context, chat *genai.ChatSession, onText func(string), parts ...genai.Part) (*genai.GenerateContentResponse, string, error) {
+	historyLen := len(chat.History)
+
+	for attempt := 1; ; attempt++ {
+		resp, sent, err := sendMessage(ctx, chat, onText, parts...)
+		if err == nil {
+			return resp, sent, nil
+		}
+
+		status, retryAfter, retryable := retryableStatus(err)
+		if !retryable || sent != "" || attempt >= p.retry.MaxAttempts {
+			if attempt > 1 {
+				return nil, "", fmt.Errorf("after %d attempts: %w", attempt, err)
+			}
+			return nil, "", err
+		}
+
+		delay := p.retry.backoff(attempt, retryAfter)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			// Повтор не успеет завершиться до истечения времени запроса
+			return nil, "", fmt.Errorf("no time left to retry after attempt %d: %w", attempt, err)
+		}
+
+		p.logger.Warn("Retrying Gemini request after transient error",
+			zap.Int("attempt", attempt+1),
+			zap.Int("max_attempts", p.retry.MaxAttempts),
+			zap.Int("status", status),
+			zap.Duration("delay", delay),
+			zap.Error(err),
+		)
+
+		chat.History = chat.History[:historyLen]
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, "", ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// ErrContentBlocked запрос или ответ заблокирован фильтрами безопасности модели
+var ErrContentBlocked = errors.New("content blocked by safety filters")
+
+// Источник блокировки в ContentBlockedError
+const (
+	BlockedPrompt   = "prompt"
+	BlockedResponse = "response"
+)
+
+// harmCategoryPrefix необязательный префикс категорий в именах Gemini API
+const harmCategoryPrefix = "harm_category_"
+
+// safetyCategories категории llm.safety. Viper приводит ключи к нижнему регистру,
+// поэтому имена сравниваются без учёта регистра и префикса HARM_CATEGORY_.
+var safetyCategories = map[string]genai.HarmCategory{
+	"harassment":        genai.HarmCategoryHarassment,
+	"hate_speech":       genai.HarmCategoryHateSpeech,
+	"sexually_explicit": genai.HarmCategorySexuallyExplicit,
+	"dangerous_content": genai.HarmCategoryDangerousContent,
+}
+
+// safetyThresholds пороги блокировки llm.safety
+var safetyThresholds = map[string]genai.HarmBlockThreshold{
+	"block_none":             genai.HarmBlockNone,
+	"block_only_high":        genai.HarmBlockOnlyHigh,
+	"block_medium_and_above": genai.HarmBlockMediumAndAbove,
+	"block_low_and_above":    genai.HarmBlockLowAndAbove,
+}
+
+// ContentBlockedError блокировка запроса или ответа с заблокированными категориями
+type ContentBlockedError struct {
+	Source     string   // BlockedPrompt или BlockedResponse
+	Reason     string   // причина Gemini: SAFETY, RECITATION, OTHER
+	Categories []string // категории в именах llm.safety; пусто, если модель их не сообщила
+}
+
+func (e *ContentBlockedError) Error() string {
+	msg := fmt.Sprintf("%s: %s blocked (%s)", ErrContentBlocked, e.Source, e.Reason)
+	if len(e.Categories) > 0 {
+		msg += ": " + strings.Join(e.Categories, ", ")
+	}
+	return msg
+}
+
+func (e *ContentBlockedError) Unwrap() error {
+	return ErrContentBlocked
+}
+
+// SafetyCategoryNames допустимые категории llm.safety
+func SafetyCategoryNames() []string {
+	return sortedKeys(safetyCategories)
+}
+
+// SafetyThresholdNames допустимые пороги llm.safety
+func SafetyThresholdNames() []string {
+	return sortedKeys(safetyThresholds)
+}
+
+// ValidateSafetySettings проверяет имена категорий и порогов llm.safety
+func ValidateSafetySettings(settings map[string]string) error {
+	_, err := geminiSafetySettings(settings)
+	return err
+}
+
+// geminiSafetySettings переводит llm.safety в настройки Gemini в порядке категорий
+func geminiSafetySettings(settings map[string]string) ([]*genai.SafetySetting, error) {
+	result := make([]*genai.SafetySetting, 0, len(settings))
+	for _, name := range sortedKeys(settings) {
+		category, ok := safetyCategories[normalizeSafetyCategory(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown safety category %q (supported: %s)", name, strings.Join(SafetyCategoryNames(), ", "))
+		}
+		threshold, ok := safetyThresholds[strings.ToLower(strings.TrimSpace(settings[name]))]
+		if !ok {
+			return nil, fmt.Errorf("unknown safety threshold %q for %s (supported: %s)", settings[name], name, strings.Join(SafetyThresholdNames(), ", "))
+		}
+		result = append(result, &genai.SafetySetting{Category: category, Threshold: threshold})
+	}
+	return result, nil
+}
+
+func normalizeSafetyCategory(name string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), harmCategoryPrefix)
+}
+
+// safetyCategoryName имя категории Gemini в терминах llm.safety
+func safetyCategoryName(category genai.HarmCategory) string {
+	for name, c := range safetyCategories {
+		if c == category {
+			return name
+		}
+	}
+	return category.String()
+}
+
+// contentBlockedError переводит блокировку SDK Gemini в ContentBlockedError; другие ошибки возвращаются без изменений
+func contentBlockedError(err error) error {
+	var blocked *genai.BlockedError
+	if !errors.As(err, &blocked) {
+		return err
+	}
+
+	if blocked.PromptFeedback != nil {
+		return &ContentBlockedError{
+			Source:     BlockedPrompt,
+			Reason:     strings.ToUpper(strings.TrimPrefix(blocked.PromptFeedback.BlockReason.String(), "BlockReason")),
+			Categories: blockedCategories(blocked.PromptFeedback.SafetyRatings),
+		}
+	}
+
+	result := &ContentBlockedError{Source: BlockedResponse}
+	if blocked.Candidate != nil {
+		result.Reason = strings.ToUpper(strings.TrimPrefix(blocked.Candidate.FinishReason.String(), "FinishReason"))
+		result.Categories = blockedCategories(blocked.Candidate.SafetyRatings)
+	}
+	return result
+}
+
+// blockedCategories категории, по которым модель заблокировала содержимое
+func blockedCategories(ratings []*genai.SafetyRating) []string {
+	var categories []string
+	for _, rating := range ratings {
+		if rating != nil && rating.Blocked {
+			categories = append(categories, safetyCategoryName(rating.Category))
+		}
+	}
+	return categories
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
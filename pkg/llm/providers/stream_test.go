@@ -0,0 +1,146 @@
+package providers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func newLookupServer(t *testing.T) *testMCPServer {
+	return newTestMCPServer(t, map[string]mcp.ToolHandler{
+		"lookup": func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return textResult("42"), nil
+		},
+	})
+}
+
+// geminiTextAndCall фрагмент потока, в котором текст идёт вместе с вызовом функции
+func geminiTextAndCall(text, name string) map[string]any {
+	event := geminiText(text)
+	content := event["candidates"].([]any)[0].(map[string]any)["content"].(map[string]any)
+	content["parts"] = append(content["parts"].([]any), map[string]any{"functionCall": map[string]any{"name": name, "args": map[string]any{}}})
+	return event
+}
+
+// streamText читает поток и возвращает текст и финальный фрагмент
+func streamText(t *testing.T, chunks <-chan StreamChunk) (string, *StreamChunk) {
+	t.Helper()
+
+	var text string
+	var done *StreamChunk
+	for _, chunk := range collectChunks(t, chunks) {
+		if chunk.Error != nil {
+			t.Fatalf("stream error: %v", chunk.Error)
+		}
+		text += chunk.Content
+		if chunk.Done {
+			done = &chunk
+		}
+	}
+	return text, done
+}
+
+func TestStreamDeliversChunksIncrementally(t *testing.T) {
+	p := newTestProvider(t, newLookupServer(t), nil)
+	gemini := newFakeGemini(t,
+		[]map[string]any{geminiFunctionCall("lookup", map[string]any{})},
+		[]map[string]any{geminiText("Ответ: "), geminiText("сорок "), withUsage(geminiText("два"), 30, 5)},
+	)
+	gemini.release = make(chan struct{})
+	gemini.attach(p)
+
+	chunks, err := p.ChatCompletionStream(context.Background(), []Message{{Role: "user", Content: "Сколько?"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Первый фрагмент ответа после хода с вызовом функции приходит, пока сервер ещё не отправил остальные
+	var first string
+	timeout := time.After(5 * time.Second)
+	for first == "" {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok || chunk.Error != nil || chunk.Done {
+				t.Fatalf("stream ended before the first text chunk: %+v", chunk)
+			}
+			first = chunk.Content
+		case <-timeout:
+			t.Fatal("first text chunk was held until the whole answer was generated")
+		}
+	}
+	if first != "Ответ: " {
+		t.Errorf("first chunk = %q, want %q", first, "Ответ: ")
+	}
+	close(gemini.release)
+
+	rest, done := streamText(t, chunks)
+	if text := first + rest; text != "Ответ: сорок два" {
+		t.Errorf("text = %q", text)
+	}
+	if done == nil || done.Usage == nil || done.Usage.PromptTokens != 30 || done.Usage.CompletionTokens != 5 {
+		t.Fatalf("done = %+v, want usage of the last stream response", done)
+	}
+	if done.Iterations != 2 || len(done.ToolCalls) != 1 {
+		t.Errorf("done iterations = %d, tool calls = %d; want 2 and 1", done.Iterations, len(done.ToolCalls))
+	}
+	// Ход с вызовом функции и ход с ответом - два потоковых запроса
+	if gemini.requestCount() != 2 {
+		t.Errorf("Gemini requests = %d, want 2", gemini.requestCount())
+	}
+}
+
+func TestStreamMatchesChatCompletion(t *testing.T) {
+	tests := []struct {
+		name     string
+		toolTurn []map[string]any
+		want     string
+	}{
+		{
+			// Первый фрагмент хода содержит вызов функции: текст хода клиенту не передаётся
+			name:     "call in first chunk",
+			toolTurn: []map[string]any{geminiTextAndCall("Сейчас посмотрю. ", "lookup")},
+			want:     "Ответ: сорок два",
+		},
+		{
+			// Вызов функции пришёл после переданного текста: текст входит и в ответ без потока
+			name:     "call after text",
+			toolTurn: []map[string]any{geminiText("Сейчас посмотрю. "), geminiFunctionCall("lookup", map[string]any{})},
+			want:     "Сейчас посмотрю. Ответ: сорок два",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			turns := [][]map[string]any{tt.toolTurn, {geminiText("Ответ: "), geminiText("сорок два")}}
+			messages := []Message{{Role: "user", Content: "Сколько?"}}
+
+			p := newTestProvider(t, newLookupServer(t), nil)
+			newFakeGemini(t, turns...).attach(p)
+			resp, err := p.ChatCompletion(context.Background(), messages)
+			if err != nil {
+				t.Fatal(err)
+			}
+			answer := resp.Choices[0].Message.Content
+
+			p = newTestProvider(t, newLookupServer(t), nil)
+			newFakeGemini(t, turns...).attach(p)
+			chunks, err := p.ChatCompletionStream(context.Background(), messages)
+			if err != nil {
+				t.Fatal(err)
+			}
+			text, done := streamText(t, chunks)
+
+			if answer != tt.want {
+				t.Errorf("ChatCompletion answer = %q, want %q", answer, tt.want)
+			}
+			if text != answer {
+				t.Errorf("streamed text = %q, want the ChatCompletion answer %q", text, answer)
+			}
+			if done == nil || done.Iterations != 2 || len(done.ToolCalls) != 1 {
+				t.Fatalf("done = %+v, want 2 iterations and 1 tool call", done)
+			}
+		})
+	}
+}
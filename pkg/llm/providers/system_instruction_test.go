@@ -0,0 +1,97 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// summaryPrompt системный промпт в духе промптов сервиса резюме
+const summaryPrompt = "Ты эксперт по анализу диалогов. Ответь JSON с полями anchors и summary."
+
+func TestSystemInstruction(t *testing.T) {
+	p := &MCPGeminiProvider{systemPrompt: "file prompt"}
+
+	tests := []struct {
+		name     string
+		messages []Message
+		want     string
+	}{
+		{"no system messages", []Message{{Role: "user", Content: "hi"}}, "file prompt"},
+		{"blank system message", []Message{{Role: "system", Content: "  "}, {Role: "user", Content: "hi"}}, "file prompt"},
+		{"single", []Message{{Role: "system", Content: summaryPrompt}, {Role: "user", Content: "hi"}}, summaryPrompt},
+		{"joined", []Message{
+			{Role: "system", Content: "first"},
+			{Role: "user", Content: "hi"},
+			{Role: "system", Content: "second"},
+		}, "first\n\nsecond"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.systemInstruction(tt.messages); got != tt.want {
+				t.Errorf("systemInstruction = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSystemMessagesReachGemini(t *testing.T) {
+	server := newTestMCPServer(t, map[string]mcp.ToolHandler{
+		"lookup": func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return textResult("found"), nil
+		},
+	})
+	p := newTestProvider(t, server, nil)
+	gemini := newFakeGemini(t,
+		[]map[string]any{geminiText(`{"anchors":["Тема"],"summary":"Резюме"}`)},
+		[]map[string]any{geminiText("Привет")},
+	)
+	gemini.attach(p)
+
+	// Запрос клиента сжатия: системный промпт резюме и диалог
+	_, err := p.ChatCompletion(context.Background(), []Message{
+		{Role: "system", Content: summaryPrompt},
+		{Role: "user", Content: "Пользователь: Как настроить сервер?"},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion: %v", err)
+	}
+	// Без системных сообщений используется промпт из файла
+	if _, err := p.ChatCompletion(context.Background(), []Message{{Role: "user", Content: "Привет"}}); err != nil {
+		t.Fatalf("ChatCompletion: %v", err)
+	}
+
+	gemini.mu.Lock()
+	defer gemini.mu.Unlock()
+	if got := requestSystemInstruction(gemini.requests[0]); got != summaryPrompt {
+		t.Errorf("system instruction = %q, want the summary prompt", got)
+	}
+	if contents, _ := json.Marshal(gemini.requests[0]["contents"]); strings.Contains(string(contents), summaryPrompt) {
+		t.Errorf("system message was sent as a dialog turn: %s", contents)
+	}
+	if got := requestSystemInstruction(gemini.requests[1]); got != "You are a test assistant." {
+		t.Errorf("fallback system instruction = %q, want the file prompt", got)
+	}
+}
+
+// requestSystemInstruction текст systemInstruction запроса generateContent
+func requestSystemInstruction(request map[string]any) string {
+	var body struct {
+		SystemInstruction struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"systemInstruction"`
+	}
+	data, _ := json.Marshal(request)
+	json.Unmarshal(data, &body)
+
+	var texts []string
+	for _, part := range body.SystemInstruction.Parts {
+		texts = append(texts, part.Text)
+	}
+	return strings.Join(texts, "")
+}
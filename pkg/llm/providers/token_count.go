@@ -0,0 +1,15 @@
+package providers
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrTokenCountingNotSupported провайдер не умеет считать токены через API модели
+var ErrTokenCountingNotSupported = errors.New("token counting is not supported by provider")
+
+// TokenCounter опциональный интерфейс провайдеров, считающих входные токены через API модели
+type TokenCounter interface {
+	// CountTokens возвращает число входных токенов сообщений, включая системные
+	CountTokens(ctx context.Context, messages []Message) (int, error)
+}
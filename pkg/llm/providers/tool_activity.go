@@ -0,0 +1,33 @@
+package providers
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type toolActivityKey struct{}
+
+// ToolActivity отмечает, вызывал ли провайдер инструменты за время запроса. Повтор запроса
+// после вызова инструмента выполнил бы инструменты с побочными эффектами ещё раз,
+// поэтому клиент повторяет только запросы, в которых инструменты не вызывались.
+type ToolActivity struct {
+	called atomic.Bool
+}
+
+// WithToolActivity возвращает контекст, в котором провайдер отмечает вызовы инструментов
+func WithToolActivity(ctx context.Context) (context.Context, *ToolActivity) {
+	activity := &ToolActivity{}
+	return context.WithValue(ctx, toolActivityKey{}, activity), activity
+}
+
+// Called сообщает, был ли вызван хотя бы один инструмент
+func (a *ToolActivity) Called() bool {
+	return a.called.Load()
+}
+
+// MarkToolCalled отмечает вызов инструмента; провайдер вызывает его перед обращением к MCP серверу
+func MarkToolCalled(ctx context.Context) {
+	if activity, ok := ctx.Value(toolActivityKey{}).(*ToolActivity); ok {
+		activity.called.Store(true)
+	}
+}
@@ -0,0 +1,69 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// ToolInvocation запись о выполненном вызове MCP инструмента для журнала аудита
+type ToolInvocation struct {
+	SessionID  string // из RequestOptions; пусто для фоновых запросов
+	UserID     string
+	Tool       string
+	Arguments  map[string]any // после RedactToolArguments
+	ResultHash string         // sha256 JSON результата; пусто, если инструмент не вернул результат
+	Success    bool
+	Error      string
+	Duration   time.Duration
+	StartedAt  time.Time
+}
+
+// ToolInvocationRecorder получает запись о каждом выполненном вызове инструмента.
+// Реализация не должна влиять на запрос пользователя: ошибки записи обрабатываются внутри.
+type ToolInvocationRecorder interface {
+	RecordToolInvocation(ctx context.Context, invocation ToolInvocation)
+}
+
+// ToolInvocationRecordable опциональный интерфейс провайдеров, ведущих журнал вызовов инструментов
+type ToolInvocationRecordable interface {
+	SetToolInvocationRecorder(recorder ToolInvocationRecorder)
+}
+
+// HashToolResult возвращает sha256 JSON представления результата инструмента
+func HashToolResult(result any) string {
+	if result == nil {
+		return ""
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// newToolInvocation собирает запись аудита с данными инициатора из RequestOptions
+func newToolInvocation(ctx context.Context, name string, args map[string]any, start time.Time, result any, err error) ToolInvocation {
+	opts := RequestOptionsFromContext(ctx)
+
+	invocation := ToolInvocation{
+		SessionID:  opts.SessionID,
+		UserID:     opts.UserID,
+		Tool:       name,
+		Arguments:  RedactToolArguments(args),
+		ResultHash: HashToolResult(result),
+		Success:    err == nil,
+		Duration:   time.Since(start),
+		StartedAt:  start,
+	}
+	if err != nil {
+		invocation.Error = err.Error()
+	}
+
+	return invocation
+}
@@ -0,0 +1,65 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestCallMCPToolsKeepsOrder(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	echo := func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			max := maxInFlight.Load()
+			if n <= max || maxInFlight.CompareAndSwap(max, n) {
+				break
+			}
+		}
+
+		args := toolArgs(req)
+		// Первые вызовы отвечают последними
+		time.Sleep(time.Duration(args["delay_ms"].(float64)) * time.Millisecond)
+		return textResult(fmt.Sprint(args["id"])), nil
+	}
+	fail := func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return nil, fmt.Errorf("backend unavailable")
+	}
+
+	server := newTestMCPServer(t, map[string]mcp.ToolHandler{"echo": echo, "fail": fail})
+	p := newTestProvider(t, server, func(cfg *MCPProviderConfig) { cfg.MaxConcurrentToolCalls = 3 })
+
+	calls := []genai.FunctionCall{
+		{Name: "echo", Args: map[string]any{"id": "first", "delay_ms": 150}},
+		{Name: "fail", Args: map[string]any{}},
+		{Name: "echo", Args: map[string]any{"id": "second", "delay_ms": 80}},
+		{Name: "echo", Args: map[string]any{"id": "third", "delay_ms": 0}},
+	}
+	results := p.callMCPTools(context.Background(), calls)
+
+	want := []string{"first", "", "second", "third"}
+	for i, result := range results {
+		if want[i] == "" {
+			// Ошибка одного вызова остаётся в его результате и не отменяет остальные
+			if _, ok := result["error"]; !ok {
+				t.Errorf("result %d = %v, want an error", i, result)
+			}
+			continue
+		}
+		if result["result"] != want[i] {
+			t.Errorf("result %d = %v, want %q", i, result, want[i])
+		}
+	}
+	if maxInFlight.Load() < 2 {
+		t.Errorf("max concurrent calls = %d, want the calls to overlap", maxInFlight.Load())
+	}
+	if maxInFlight.Load() > 3 {
+		t.Errorf("max concurrent calls = %d, want at most 3", maxInFlight.Load())
+	}
+}
@@ -0,0 +1,83 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"go.uber.org/zap"
+)
+
+// ToolCallResult результат прямого вызова инструмента. Ошибка инструмента (IsError)
+// возвращается текстом в Error, результат не обрезается
+type ToolCallResult struct {
+	Result  map[string]any
+	IsError bool
+	Error   string
+	Latency time.Duration
+}
+
+// ToolCaller опциональный интерфейс провайдеров, позволяющих вызвать инструмент в обход модели (для отладки)
+type ToolCaller interface {
+	CallTool(ctx context.Context, name string, args map[string]any) (*ToolCallResult, error)
+}
+
+// CallTool вызывает инструмент по имени, которое видит модель, через те же MCP сессии,
+// списки разрешённых и запрещённых инструментов и таймаут, что и вызовы модели.
+// Вызов записывается в журнал аудита.
+func (p *MCPGeminiProvider) CallTool(ctx context.Context, name string, args map[string]any) (*ToolCallResult, error) {
+	if p.reinitializing.Load() {
+		return nil, ErrProviderReinitializing
+	}
+
+	p.lifecycleMu.RLock()
+	defer p.lifecycleMu.RUnlock()
+
+	if args == nil {
+		args = map[string]any{}
+	}
+	if !p.toolFilter.Allows(name) {
+		return nil, fmt.Errorf("%w: %s", ErrToolNotAllowed, name)
+	}
+	if !p.mcpConnected() {
+		if err := p.initializeMCP(ctx); err != nil {
+			return nil, err
+		}
+	}
+	srv, toolName, err := p.resolveTool(name)
+	if err != nil {
+		return nil, err
+	}
+
+	p.logger.Info("MCP tool debug call",
+		zap.String("tool_name", name),
+		zap.Any("arguments", p.redactedLogValue(args)),
+	)
+
+	callCtx := ctx
+	if p.toolCallTimeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, p.toolCallTimeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	res, err := p.callSession(callCtx, srv, name, &mcp.CallToolParams{Name: toolName, Arguments: args})
+	latency := time.Since(start)
+	if err != nil {
+		p.recordToolInvocation(ctx, name, args, start, nil, err)
+		return nil, fmt.Errorf("tool call failed: %w", err)
+	}
+
+	if res.IsError {
+		msg := toolErrorText(res)
+		p.recordToolInvocation(ctx, name, args, start, map[string]any{"error": msg}, errors.New(msg))
+		return &ToolCallResult{IsError: true, Error: msg, Latency: latency}, nil
+	}
+
+	result := toolResultValue(res)
+	p.recordToolInvocation(ctx, name, args, start, result, nil)
+	return &ToolCallResult{Result: result, Latency: latency}, nil
+}
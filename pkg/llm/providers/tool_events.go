@@ -0,0 +1,124 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Фазы жизненного цикла вызова инструмента
+const (
+	ToolCallStarted  = "started"
+	ToolCallFinished = "finished"
+)
+
+// maxToolArgLength максимальная длина строкового аргумента в событии
+const maxToolArgLength = 200
+
+// sensitiveArgKeys подстроки имён аргументов, значения которых не передаются клиенту
+var sensitiveArgKeys = []string{"password", "passwd", "secret", "token", "api_key", "apikey", "auth", "credential"}
+
+// ToolCallEvent событие вызова MCP инструмента во время генерации ответа
+type ToolCallEvent struct {
+	ID         string         `json:"id"`
+	Name       string         `json:"name"`
+	Phase      string         `json:"phase"`
+	Arguments  map[string]any `json:"arguments,omitempty"`   // только для started, с маскированными значениями
+	DurationMs int64          `json:"duration_ms,omitempty"` // только для finished
+	Success    bool           `json:"success"`
+	Error      string         `json:"error,omitempty"`
+}
+
+type toolEventSinkKey struct{}
+
+type toolEventSink struct {
+	send     func(ToolCallEvent)
+	progress func(ToolProgressInfo) // nil - уведомления о прогрессе не запрашиваются
+	seq      atomic.Int64
+}
+
+// withToolEventSink возвращает контекст, через который провайдер сообщает о вызовах инструментов
+// и ходе их выполнения
+func withToolEventSink(ctx context.Context, send func(ToolCallEvent), progress func(ToolProgressInfo)) context.Context {
+	return context.WithValue(ctx, toolEventSinkKey{}, &toolEventSink{send: send, progress: progress})
+}
+
+// emitToolCallStarted сообщает о начале вызова и возвращает его идентификатор
+func emitToolCallStarted(ctx context.Context, name string, args map[string]any) string {
+	sink, ok := ctx.Value(toolEventSinkKey{}).(*toolEventSink)
+	if !ok {
+		return ""
+	}
+
+	id := fmt.Sprintf("call_%d", sink.seq.Add(1))
+	sink.send(ToolCallEvent{
+		ID:        id,
+		Name:      name,
+		Phase:     ToolCallStarted,
+		Arguments: RedactToolArguments(args),
+		Success:   true,
+	})
+	return id
+}
+
+// emitToolCallFinished сообщает о завершении вызова
+func emitToolCallFinished(ctx context.Context, id, name string, duration time.Duration, err error) {
+	sink, ok := ctx.Value(toolEventSinkKey{}).(*toolEventSink)
+	if !ok {
+		return
+	}
+
+	event := ToolCallEvent{
+		ID:         id,
+		Name:       name,
+		Phase:      ToolCallFinished,
+		DurationMs: duration.Milliseconds(),
+		Success:    err == nil,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	sink.send(event)
+}
+
+// RedactToolArguments маскирует чувствительные аргументы и обрезает длинные строки
+func RedactToolArguments(args map[string]any) map[string]any {
+	if len(args) == 0 {
+		return nil
+	}
+
+	redacted := make(map[string]any, len(args))
+	for key, value := range args {
+		redacted[key] = redactToolValue(key, value)
+	}
+	return redacted
+}
+
+func redactToolValue(key string, value any) any {
+	lower := strings.ToLower(key)
+	for _, sensitive := range sensitiveArgKeys {
+		if strings.Contains(lower, sensitive) {
+			return "[REDACTED]"
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		if len(v) > maxToolArgLength {
+			return v[:maxToolArgLength] + "…"
+		}
+		return v
+	case map[string]any:
+		return RedactToolArguments(v)
+	case []any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = redactToolValue(key, item)
+		}
+		return items
+	default:
+		return v
+	}
+}
@@ -0,0 +1,70 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ErrToolNotAllowed инструмент скрыт от модели списками разрешённых или запрещённых инструментов
+var ErrToolNotAllowed = errors.New("tool is not allowed")
+
+// MCPToolCounts число инструментов MCP серверов: доступных модели и скрытых фильтром
+type MCPToolCounts struct {
+	Exposed  int `json:"exposed"`
+	Filtered int `json:"filtered"`
+
+	// Servers состояние каждого сервера, если их несколько (см. MCPProviderConfig.Servers)
+	Servers []MCPServerInfo `json:"-"`
+}
+
+// MCPToolCounter опциональный интерфейс провайдеров, фильтрующих инструменты MCP сервера
+type MCPToolCounter interface {
+	// MCPToolCounts запрашивает списки инструментов серверов и применяет к ним фильтр
+	MCPToolCounts(ctx context.Context) (MCPToolCounts, error)
+}
+
+// ToolFilter решает, какие инструменты MCP сервера доступны модели.
+// Запрещённый инструмент скрыт всегда; непустой список разрешённых скрывает все остальные.
+// Нулевой фильтр пропускает все инструменты.
+type ToolFilter struct {
+	allowed map[string]bool
+	denied  map[string]bool
+}
+
+// NewToolFilter создаёт фильтр по именам инструментов; пустые имена игнорируются
+func NewToolFilter(allowed, denied []string) ToolFilter {
+	return ToolFilter{allowed: nameSet(allowed), denied: nameSet(denied)}
+}
+
+// Allows сообщает, доступен ли инструмент модели
+func (f ToolFilter) Allows(name string) bool {
+	if f.denied[name] {
+		return false
+	}
+	return len(f.allowed) == 0 || f.allowed[name]
+}
+
+// Apply возвращает доступные инструменты и имена скрытых
+func (f ToolFilter) Apply(tools []*mcp.Tool) (exposed []*mcp.Tool, filtered []string) {
+	for _, t := range tools {
+		if f.Allows(t.Name) {
+			exposed = append(exposed, t)
+		} else {
+			filtered = append(filtered, t.Name)
+		}
+	}
+	return exposed, filtered
+}
+
+func nameSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			set[name] = true
+		}
+	}
+	return set
+}
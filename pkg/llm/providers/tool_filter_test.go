@@ -0,0 +1,73 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestToolFilter(t *testing.T) {
+	tools := []*mcp.Tool{{Name: "read"}, {Name: "write"}, {Name: "delete_record"}}
+
+	tests := []struct {
+		name     string
+		allowed  []string
+		denied   []string
+		exposed  string
+		filtered string
+	}{
+		{"no lists", nil, nil, "[read write delete_record]", "[]"},
+		{"allowlist", []string{"read", "write", " "}, nil, "[read write]", "[delete_record]"},
+		{"denylist", nil, []string{"delete_record"}, "[read write]", "[delete_record]"},
+		// Запрет сильнее разрешения
+		{"both", []string{"read", "delete_record"}, []string{"delete_record"}, "[read]", "[write delete_record]"},
+	}
+	for _, tt := range tests {
+		exposed, filtered := NewToolFilter(tt.allowed, tt.denied).Apply(tools)
+		names := make([]string, len(exposed))
+		for i, tool := range exposed {
+			names[i] = tool.Name
+		}
+		if fmt.Sprint(names) != tt.exposed || fmt.Sprint(filtered) != tt.filtered {
+			t.Errorf("%s: exposed %v, filtered %v; want %s, %s", tt.name, names, filtered, tt.exposed, tt.filtered)
+		}
+	}
+}
+
+func TestFilteredToolsHiddenFromModel(t *testing.T) {
+	handler := func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return textResult(req.Params.Name), nil
+	}
+	server := newTestMCPServer(t, map[string]mcp.ToolHandler{"read": handler, "write": handler, "delete_record": handler})
+	p := newTestProvider(t, server, func(cfg *MCPProviderConfig) {
+		cfg.AllowedTools = []string{"read", "delete_record"}
+		cfg.DeniedTools = []string{"delete_record"}
+	})
+
+	declarations := p.toolDeclarations()
+	if len(declarations) != 1 || declarations[0].Name != "read" {
+		t.Errorf("declarations = %d, want only read", len(declarations))
+	}
+
+	counts, err := p.MCPToolCounts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts.Exposed != 1 || counts.Filtered != 2 {
+		t.Errorf("counts = %+v, want 1 exposed and 2 filtered", counts)
+	}
+
+	// Вызов скрытого инструмента отклоняется до обращения к серверу
+	if _, err := p.callMCPTool(context.Background(), "delete_record", nil); !errors.Is(err, ErrToolNotAllowed) {
+		t.Errorf("calling a denied tool: err = %v, want ErrToolNotAllowed", err)
+	}
+	if _, err := p.callMCPTool(context.Background(), "write", nil); !errors.Is(err, ErrToolNotAllowed) {
+		t.Errorf("calling a tool outside the allowlist: err = %v, want ErrToolNotAllowed", err)
+	}
+	if result, err := p.callMCPTool(context.Background(), "read", nil); err != nil || result["result"] != "read" {
+		t.Errorf("allowed tool = %v, %v", result, err)
+	}
+}
@@ -0,0 +1,101 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"LLM_Chat/pkg/mcp/schema"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ToolInfo инструмент, доступный модели: имя, описание и параметры в том виде,
+// в каком их получает Gemini (после конвертации JSON Schema)
+type ToolInfo struct {
+	Name        string         `json:"name"`
+	Server      string         `json:"server,omitempty"` // сервер, если их несколько (см. MCPProviderConfig.Servers)
+	Description string         `json:"description"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+// ToolLister опциональный интерфейс провайдеров, которые могут перечислить инструменты модели
+type ToolLister interface {
+	// ListTools возвращает текущий список инструментов; refresh заново запрашивает его у серверов
+	ListTools(ctx context.Context, refresh bool) ([]ToolInfo, error)
+}
+
+// ListTools возвращает инструменты, которые видит модель, из уже открытых MCP сессий.
+// Если соединений ещё нет, серверы подключаются. С refresh списки заново запрашиваются
+// у всех подключённых серверов; ошибка возвращается, только если не ответил ни один.
+func (p *MCPGeminiProvider) ListTools(ctx context.Context, refresh bool) ([]ToolInfo, error) {
+	if p.reinitializing.Load() {
+		return nil, ErrProviderReinitializing
+	}
+
+	p.lifecycleMu.RLock()
+	defer p.lifecycleMu.RUnlock()
+
+	if !p.mcpConnected() {
+		if err := p.initializeMCP(ctx); err != nil {
+			return nil, err
+		}
+	} else if refresh {
+		attempted := 0
+		var errs []error
+		for _, srv := range p.servers {
+			session := srv.currentSession()
+			if session == nil {
+				continue
+			}
+			attempted++
+			if _, _, err := p.reloadServerTools(ctx, srv, session); err != nil {
+				errs = append(errs, srv.wrapError(err))
+			}
+		}
+		if attempted > 0 && len(errs) == attempted {
+			return nil, errors.Join(errs...)
+		}
+	}
+
+	return p.toolInfos(), nil
+}
+
+// reloadServerTools запрашивает список инструментов сессии и заменяет инструменты сервера;
+// возвращает доступные модели инструменты и имена скрытых фильтром
+func (p *MCPGeminiProvider) reloadServerTools(ctx context.Context, srv *mcpServer, session *mcp.ClientSession) ([]*mcp.Tool, []string, error) {
+	ltr, err := session.ListTools(ctx, &mcp.ListToolsParams{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list MCP tools: %w", err)
+	}
+
+	available, filtered := p.toolFilter.Apply(srv.exposedTools(ltr.Tools))
+	p.setServerTools(srv, available)
+	return available, filtered, nil
+}
+
+// toolInfos описания текущих инструментов вместе с объявлениями функций Gemini
+func (p *MCPGeminiProvider) toolInfos() []ToolInfo {
+	p.toolsMu.RLock()
+	defer p.toolsMu.RUnlock()
+
+	// Объявления функций построены по инструментам серверов в порядке p.servers
+	infos := make([]ToolInfo, 0, len(p.geminiTools))
+	i := 0
+	for _, srv := range p.servers {
+		for range srv.tools {
+			if i >= len(p.geminiTools) {
+				return infos
+			}
+			decl := p.geminiTools[i]
+			infos = append(infos, ToolInfo{
+				Name:        decl.Name,
+				Server:      srv.cfg.Name,
+				Description: decl.Description,
+				Parameters:  schema.ToJSON(decl.Parameters),
+			})
+			i++
+		}
+	}
+	return infos
+}
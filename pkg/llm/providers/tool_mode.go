@@ -0,0 +1,39 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// Режимы вызова функций (tool_mode)
+const (
+	ToolModeAuto = "auto" // модель сама решает, вызывать ли инструменты
+	ToolModeAny  = "any"  // первый ход обязан вызвать инструмент, дальше - как auto
+	ToolModeNone = "none" // инструменты модели не передаются
+)
+
+// ToolModes допустимые режимы вызова функций
+var ToolModes = []string{ToolModeAuto, ToolModeAny, ToolModeNone}
+
+// ValidateToolMode проверяет режим вызова функций; пустое значение означает режим по умолчанию
+func ValidateToolMode(mode string) error {
+	switch mode {
+	case "", ToolModeAuto, ToolModeAny, ToolModeNone:
+		return nil
+	}
+	return fmt.Errorf("unknown tool mode %q (supported: %s)", mode, strings.Join(ToolModes, ", "))
+}
+
+// geminiToolConfig настройка вызова функций Gemini для режима. В режиме none объявления
+// инструментов не передаются вовсе, поэтому настройка для него не нужна.
+func geminiToolConfig(mode string) *genai.ToolConfig {
+	callingMode := genai.FunctionCallingAuto
+	if mode == ToolModeAny {
+		callingMode = genai.FunctionCallingAny
+	}
+	return &genai.ToolConfig{
+		FunctionCallingConfig: &genai.FunctionCallingConfig{Mode: callingMode},
+	}
+}
@@ -0,0 +1,89 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ToolProgressInfo уведомление MCP сервера о ходе выполнения инструмента (notifications/progress)
+type ToolProgressInfo struct {
+	ID       string   `json:"id"` // идентификатор вызова из события tool_call
+	Tool     string   `json:"tool"`
+	Progress float64  `json:"progress"`
+	Total    float64  `json:"total,omitempty"`
+	Percent  *float64 `json:"percent,omitempty"` // только если сервер сообщил total
+	Message  string   `json:"message,omitempty"`
+}
+
+// progressTarget вызов инструмента, которому принадлежит токен прогресса
+type progressTarget struct {
+	sink   *toolEventSink
+	callID string
+	tool   string
+
+	mu   sync.Mutex // не даёт доставить уведомление после завершения вызова
+	done bool
+}
+
+// trackToolProgress запрашивает у сервера уведомления о прогрессе вызова, если вызывающий
+// принимает события инструментов (потоковый ответ). Возвращает функцию снятия регистрации:
+// уведомления, пришедшие после завершения вызова, отбрасываются.
+func (p *MCPGeminiProvider) trackToolProgress(ctx context.Context, params *mcp.CallToolParams, callID, name string) func() {
+	sink, ok := ctx.Value(toolEventSinkKey{}).(*toolEventSink)
+	if !ok || sink.progress == nil {
+		return func() {}
+	}
+
+	token := fmt.Sprintf("progress_%d", p.progressSeq.Add(1))
+	// SetProgressToken в go-sdk v0.3.1 теряет токен, если Meta ещё не создана
+	if params.Meta == nil {
+		params.Meta = mcp.Meta{}
+	}
+	params.SetProgressToken(token)
+	target := &progressTarget{sink: sink, callID: callID, tool: name}
+	p.progressCalls.Store(token, target)
+	return func() {
+		p.progressCalls.Delete(token)
+		target.mu.Lock()
+		target.done = true
+		target.mu.Unlock()
+	}
+}
+
+// handleToolProgress передаёт уведомление о прогрессе в поток ответа, которому принадлежит вызов
+func (p *MCPGeminiProvider) handleToolProgress(params *mcp.ProgressNotificationParams) {
+	if params == nil {
+		return
+	}
+	token, ok := params.ProgressToken.(string)
+	if !ok {
+		return
+	}
+	value, ok := p.progressCalls.Load(token)
+	if !ok {
+		return
+	}
+	target := value.(*progressTarget)
+
+	info := ToolProgressInfo{
+		ID:       target.callID,
+		Tool:     target.tool,
+		Progress: params.Progress,
+		Total:    params.Total,
+		Message:  params.Message,
+	}
+	if params.Total > 0 {
+		percent := min(params.Progress/params.Total*100, 100)
+		info.Percent = &percent
+	}
+
+	// Уведомления обрабатываются клиентом асинхронно и могут прийти уже после результата вызова
+	target.mu.Lock()
+	defer target.mu.Unlock()
+	if !target.done {
+		target.sink.progress(info)
+	}
+}
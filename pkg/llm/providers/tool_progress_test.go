@@ -0,0 +1,92 @@
+package providers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// progressTool сообщает о трёх шагах выполнения, если клиент запросил прогресс
+func progressTool(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if token := req.Params.GetProgressToken(); token != nil {
+		for step := 1; step <= 3; step++ {
+			req.Session.NotifyProgress(ctx, &mcp.ProgressNotificationParams{
+				ProgressToken: token,
+				Progress:      float64(step),
+				Total:         3,
+				Message:       "indexing",
+			})
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	return textResult("indexed"), nil
+}
+
+func TestStreamProgressBeforeContent(t *testing.T) {
+	server := newTestMCPServer(t, map[string]mcp.ToolHandler{"reindex": progressTool})
+	p := newTestProvider(t, server, nil)
+	gemini := newFakeGemini(t,
+		[]map[string]any{geminiFunctionCall("reindex", map[string]any{})},
+		[]map[string]any{geminiText("Индекс "), geminiText("обновлён")},
+	)
+	gemini.attach(p)
+
+	chunks, err := p.ChatCompletionStream(context.Background(), []Message{{Role: "user", Content: "Обнови индекс"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var order []string
+	var percents []float64
+	for _, chunk := range collectChunks(t, chunks) {
+		switch {
+		case chunk.Error != nil:
+			t.Fatalf("stream error: %v", chunk.Error)
+		case chunk.ToolCall != nil:
+			order = append(order, "tool_call:"+chunk.ToolCall.Phase)
+		case chunk.ToolProgress != nil:
+			order = append(order, "tool_progress")
+			if chunk.ToolProgress.Tool != "reindex" || chunk.ToolProgress.Percent == nil {
+				t.Errorf("progress = %+v", chunk.ToolProgress)
+				continue
+			}
+			percents = append(percents, *chunk.ToolProgress.Percent)
+		case chunk.Content != "":
+			order = append(order, "content")
+		case chunk.Done:
+			order = append(order, "done")
+		}
+	}
+
+	want := []string{
+		"tool_call:started", "tool_progress", "tool_progress", "tool_progress", "tool_call:finished",
+		"content", "content", "done",
+	}
+	if len(order) != len(want) {
+		t.Fatalf("chunks = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("chunks = %v, want %v", order, want)
+		}
+	}
+	if len(percents) != 3 || percents[2] != 100 {
+		t.Errorf("percents = %v, want 3 steps up to 100", percents)
+	}
+}
+
+func TestProgressNotRequestedWithoutStream(t *testing.T) {
+	server := newTestMCPServer(t, map[string]mcp.ToolHandler{"reindex": progressTool})
+	p := newTestProvider(t, server, nil)
+
+	if result, err := p.callMCPTool(context.Background(), "reindex", nil); err != nil || result["result"] != "indexed" {
+		t.Fatalf("callMCPTool = %v, %v", result, err)
+	}
+	// Токены прогресса завершённых вызовов не остаются в провайдере
+	p.progressCalls.Range(func(key, value any) bool {
+		t.Errorf("progress token %v was not released", key)
+		return true
+	})
+}
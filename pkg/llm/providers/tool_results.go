@@ -0,0 +1,153 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+// DefaultMaxToolResultBytes предел размера результата инструмента (в байтах JSON),
+// который попадает в историю модели
+const DefaultMaxToolResultBytes = 32 * 1024
+
+// ToolResultTruncatedKey ключ с пометкой об обрезке в структурированном результате инструмента
+const ToolResultTruncatedKey = "_truncated"
+
+// truncationReserve запас под пометку об обрезке
+const truncationReserve = 64
+
+// truncateToolResult уменьшает результат инструмента до limit байт JSON и возвращает число отброшенных байт.
+// Текстовый результат {"result": "..."} обрезается по границе символа с пометкой в конце текста.
+// Структурированный результат сохраняет корректный JSON: отбрасываются хвосты массивов и последние
+// (по алфавиту) ключи объектов, а в корень добавляется ключ _truncated с пометкой.
+func truncateToolResult(result map[string]any, limit int) (map[string]any, int) {
+	if limit <= 0 || result == nil {
+		return result, 0
+	}
+	size := jsonSize(result)
+	if size <= limit {
+		return result, 0
+	}
+
+	budget := limit - truncationReserve
+	if budget < 0 {
+		budget = 0
+	}
+
+	if text, ok := result["result"].(string); ok && len(result) == 1 {
+		// Текст занимает весь результат, кроме обёртки {"result":}
+		cut := truncateString(text, budget-jsonSize(map[string]any{"result": ""}))
+		return map[string]any{"result": cut}, size - jsonSize(map[string]any{"result": cut})
+	}
+
+	shrunk, _ := shrinkValue(result, budget).(map[string]any)
+	if shrunk == nil {
+		shrunk = map[string]any{}
+	}
+	omitted := size - jsonSize(shrunk)
+	shrunk[ToolResultTruncatedKey] = truncationMarker(omitted)
+	return shrunk, omitted
+}
+
+func truncationMarker(omitted int) string {
+	return fmt.Sprintf("...truncated (%d bytes omitted)", omitted)
+}
+
+// truncateString обрезает текст так, чтобы вместе с пометкой он занимал в JSON не больше budget байт
+func truncateString(text string, budget int) string {
+	end := len(text)
+	for {
+		for end > 0 && end < len(text) && !utf8.RuneStart(text[end]) {
+			end--
+		}
+		candidate := text[:end] + truncationMarker(len(text)-end)
+		// Экранирование в JSON может занять больше байт, чем символы, поэтому проверка повторяется
+		over := jsonSize(candidate) - budget
+		if over <= 0 || end == 0 {
+			return candidate
+		}
+		end -= over
+		if end < 0 {
+			end = 0
+		}
+	}
+}
+
+// shrinkValue возвращает значение, JSON которого укладывается в budget байт.
+// Массивы и объекты сохраняют начальные элементы; первый не поместившийся элемент
+// уменьшается рекурсивно, остальные отбрасываются. Не поместившееся скалярное значение - nil.
+func shrinkValue(v any, budget int) any {
+	if jsonSize(v) <= budget {
+		return v
+	}
+
+	switch v := v.(type) {
+	case string:
+		if budget < len(`""`)+truncationReserve {
+			return nil
+		}
+		return truncateString(v, budget)
+
+	case []any:
+		out := []any{}
+		used := len("[]")
+		for _, item := range v {
+			sep := 0
+			if len(out) > 0 {
+				sep = 1
+			}
+			size := jsonSize(item)
+			if used+sep+size <= budget {
+				out = append(out, item)
+				used += sep + size
+				continue
+			}
+			if shrunk := shrinkValue(item, budget-used-sep); shrunk != nil {
+				out = append(out, shrunk)
+			}
+			break
+		}
+		return out
+
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		out := map[string]any{}
+		used := len("{}")
+		for _, k := range keys {
+			sep := 0
+			if len(out) > 0 {
+				sep = 1
+			}
+			keySize := jsonSize(k) + 1 // "key":
+			size := jsonSize(v[k])
+			if used+sep+keySize+size <= budget {
+				out[k] = v[k]
+				used += sep + keySize + size
+				continue
+			}
+			if shrunk := shrinkValue(v[k], budget-used-sep-keySize); shrunk != nil {
+				out[k] = shrunk
+			}
+			break
+		}
+		return out
+
+	default:
+		return nil
+	}
+}
+
+// jsonSize размер значения в JSON; значение, которое не сериализуется, считается пустым
+func jsonSize(v any) int {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return len(b)
+}
@@ -0,0 +1,66 @@
+package providers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateTextToolResult(t *testing.T) {
+	text := strings.Repeat("абв <tag> ", 20000)
+	out, omitted := truncateToolResult(map[string]any{"result": text}, 1000)
+
+	encoded, _ := json.Marshal(out)
+	if len(encoded) > 1000 || omitted <= 0 {
+		t.Fatalf("result = %d bytes, %d omitted; want at most 1000 and a positive count", len(encoded), omitted)
+	}
+	result := out["result"].(string)
+	if !strings.Contains(result, "...truncated (") {
+		t.Errorf("result does not end with the truncation marker: %q", result[len(result)-60:])
+	}
+	// Обрезка идёт по границе символа
+	if !utf8.ValidString(result) {
+		t.Error("text was cut in the middle of a character")
+	}
+}
+
+func TestTruncateStructuredToolResult(t *testing.T) {
+	var items []any
+	for i := 0; i < 5000; i++ {
+		items = append(items, map[string]any{"id": float64(i), "name": "item"})
+	}
+	out, omitted := truncateToolResult(map[string]any{"count": float64(5000), "items": items}, 2000)
+
+	encoded, _ := json.Marshal(out)
+	if len(encoded) > 2000 || omitted <= 0 {
+		t.Fatalf("result = %d bytes, %d omitted; want at most 2000 and a positive count", len(encoded), omitted)
+	}
+
+	// JSON остаётся корректным: отбрасывается хвост массива, остальные поля сохраняются
+	var back map[string]any
+	if err := json.Unmarshal(encoded, &back); err != nil {
+		t.Fatalf("truncated result is not valid JSON: %v", err)
+	}
+	kept, _ := back["items"].([]any)
+	if back["count"] != float64(5000) || len(kept) == 0 || len(kept) >= 5000 {
+		t.Errorf("count = %v, items kept = %d", back["count"], len(kept))
+	}
+	if first, _ := kept[0].(map[string]any); first["id"] != float64(0) {
+		t.Errorf("first item = %v, want the head of the array", kept[0])
+	}
+	if marker, _ := back[ToolResultTruncatedKey].(string); !strings.Contains(marker, "truncated") {
+		t.Errorf("%s = %v, want a truncation marker", ToolResultTruncatedKey, back[ToolResultTruncatedKey])
+	}
+}
+
+func TestTruncateSmallToolResult(t *testing.T) {
+	out, omitted := truncateToolResult(map[string]any{"result": "ok"}, 1000)
+	if omitted != 0 || out["result"] != "ok" {
+		t.Errorf("small result changed: %v, %d omitted", out, omitted)
+	}
+	// Нулевой предел выключает обрезку
+	if _, omitted := truncateToolResult(map[string]any{"result": strings.Repeat("x", 100)}, 0); omitted != 0 {
+		t.Errorf("limit 0 truncated %d bytes", omitted)
+	}
+}
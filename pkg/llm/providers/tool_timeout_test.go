@@ -0,0 +1,66 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// slowTool отвечает через delay или при отмене вызова
+func slowTool(delay time.Duration) mcp.ToolHandler {
+	return func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		select {
+		case <-time.After(delay):
+			return textResult("done"), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func TestToolCallTimeout(t *testing.T) {
+	server := newTestMCPServer(t, map[string]mcp.ToolHandler{
+		"slow": slowTool(5 * time.Second),
+		"fast": slowTool(0),
+	})
+	p := newTestProvider(t, server, func(cfg *MCPProviderConfig) { cfg.ToolCallTimeout = 100 * time.Millisecond })
+
+	start := time.Now()
+	result, err := p.callMCPTool(context.Background(), "slow", nil)
+	if err != nil {
+		t.Fatalf("callMCPTool: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("slow tool blocked for %s", elapsed)
+	}
+	// Модель получает ошибку и может продолжить ответ
+	if msg, _ := result["error"].(string); msg != "tool timed out after 100ms" {
+		t.Errorf("result = %v, want the timeout error", result)
+	}
+
+	result, err = p.callMCPTool(context.Background(), "fast", nil)
+	if err != nil || result["result"] != "done" {
+		t.Errorf("fast tool = %v, %v; want done", result, err)
+	}
+}
+
+func TestToolCallCanceledByRequest(t *testing.T) {
+	server := newTestMCPServer(t, map[string]mcp.ToolHandler{"slow": slowTool(5 * time.Second)})
+	p := newTestProvider(t, server, func(cfg *MCPProviderConfig) { cfg.ToolCallTimeout = time.Minute })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// Отмена запроса не выдаётся за тайм-аут инструмента
+	result, err := p.callMCPTool(ctx, "slow", nil)
+	if err == nil {
+		t.Fatalf("result = %v, want an error", result)
+	}
+	if strings.Contains(err.Error(), "tool timed out") || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the request cancellation", err)
+	}
+}
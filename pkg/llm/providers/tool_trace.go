@@ -0,0 +1,76 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ToolCallTrace выполненный за время ответа вызов MCP инструмента
+type ToolCallTrace struct {
+	ID         string         `json:"id"` // совпадает с идентификатором события tool_call в потоковом ответе
+	Name       string         `json:"name"`
+	Arguments  map[string]any `json:"arguments,omitempty"` // после RedactToolArguments и маскирования персональных данных
+	Result     map[string]any `json:"result,omitempty"`    // результат, переданный модели
+	Error      string         `json:"error,omitempty"`
+	DurationMs int64          `json:"duration_ms"`
+	StartedAt  time.Time      `json:"started_at"`
+}
+
+type toolTraceKey struct{}
+
+// toolTraceCollector собирает вызовы инструментов всех итераций одного ChatCompletion
+type toolTraceCollector struct {
+	mu    sync.Mutex
+	seq   int
+	calls []ToolCallTrace
+}
+
+func withToolTraceCollector(ctx context.Context, c *toolTraceCollector) context.Context {
+	return context.WithValue(ctx, toolTraceKey{}, c)
+}
+
+// result возвращает вызовы в порядке их начала; nil, если инструменты не вызывались
+func (c *toolTraceCollector) result() []ToolCallTrace {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.calls) == 0 {
+		return nil
+	}
+	calls := append([]ToolCallTrace(nil), c.calls...)
+	sort.SliceStable(calls, func(i, j int) bool { return calls[i].StartedAt.Before(calls[j].StartedAt) })
+	return calls
+}
+
+// traceToolCall добавляет выполненный вызов в ответ. Без потока событий идентификатор
+// вызова назначает сборщик.
+func (p *MCPGeminiProvider) traceToolCall(ctx context.Context, callID, name string, args map[string]any, start time.Time, result map[string]any, err error) {
+	c, ok := ctx.Value(toolTraceKey{}).(*toolTraceCollector)
+	if !ok {
+		return
+	}
+
+	redactedArgs, _ := p.redactor.Map(args)
+	trace := ToolCallTrace{
+		ID:         callID,
+		Name:       name,
+		Arguments:  RedactToolArguments(redactedArgs),
+		Result:     p.redactedLogValue(result),
+		DurationMs: time.Since(start).Milliseconds(),
+		StartedAt:  start,
+	}
+	if err != nil {
+		trace.Error = p.redactor.String(err.Error())
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	if trace.ID == "" {
+		trace.ID = fmt.Sprintf("call_%d", c.seq)
+	}
+	c.calls = append(c.calls, trace)
+}
@@ -0,0 +1,48 @@
+package providers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestAddGeminiUsage(t *testing.T) {
+	var usage Usage
+	addGeminiUsage(&usage, &genai.UsageMetadata{PromptTokenCount: 10, CandidatesTokenCount: 2, TotalTokenCount: 12})
+	addGeminiUsage(&usage, nil)
+	addGeminiUsage(&usage, &genai.UsageMetadata{PromptTokenCount: 20, CandidatesTokenCount: 3, TotalTokenCount: 23})
+
+	if usage != (Usage{PromptTokens: 30, CompletionTokens: 5, TotalTokens: 35}) {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestUsageAcrossToolIterations(t *testing.T) {
+	server := newTestMCPServer(t, map[string]mcp.ToolHandler{
+		"lookup": func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return textResult("found"), nil
+		},
+	})
+	p := newTestProvider(t, server, nil)
+	gemini := newFakeGemini(t,
+		[]map[string]any{withUsage(geminiFunctionCall("lookup", map[string]any{"q": "a"}), 10, 2)},
+		[]map[string]any{withUsage(geminiFunctionCall("lookup", map[string]any{"q": "b"}), 20, 3)},
+		// Поток сообщает накопленные значения хода: учитывается последнее
+		[]map[string]any{withUsage(geminiText("Готово, "), 30, 2), withUsage(geminiText("нашёл"), 30, 5)},
+	)
+	gemini.attach(p)
+
+	resp, err := p.ChatCompletion(context.Background(), []Message{{Role: "user", Content: "Найди"}})
+	if err != nil {
+		t.Fatalf("ChatCompletion: %v", err)
+	}
+	if resp.Iterations != 3 || len(resp.ToolCalls) != 2 {
+		t.Errorf("iterations = %d, tool calls = %d; want 3 and 2", resp.Iterations, len(resp.ToolCalls))
+	}
+	want := Usage{PromptTokens: 60, CompletionTokens: 10, TotalTokens: 70}
+	if resp.Usage != want {
+		t.Errorf("usage = %+v, want %+v", resp.Usage, want)
+	}
+}
@@ -1,6 +1,7 @@
 package llm
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
@@ -27,29 +28,51 @@ func NewRegistry(logger *zap.Logger) *Registry {
 func (r *Registry) GetAvailableProviders() []ProviderInfo {
 	return []ProviderInfo{
 		r.getGeminiMCPInfo(),
+		r.getOpenRouterInfo(),
 	}
 }
 
-// ValidateProviderConfig проверяет конфигурацию провайдера
+// GetProviderInfo возвращает описание провайдера по значению llm.provider
+func (r *Registry) GetProviderInfo(providerName string) (ProviderInfo, bool) {
+	for _, info := range r.GetAvailableProviders() {
+		if info.ID == strings.ToLower(providerName) {
+			return info, true
+		}
+	}
+	return ProviderInfo{}, false
+}
+
+// ValidateProviderConfig проверяет конфигурацию провайдера.
+// Ошибки отдельных полей возвращаются как объединённые *ConfigFieldError.
 func (r *Registry) ValidateProviderConfig(providerName string, config map[string]interface{}) error {
-	// Проверяем, что это Gemini
-	if strings.ToLower(providerName) != "gemini" {
-		return fmt.Errorf("unsupported provider: %s (only 'gemini' is supported)", providerName)
+	info, ok := r.GetProviderInfo(providerName)
+	if !ok {
+		return &ConfigFieldError{
+			Field:   "provider",
+			Code:    "unsupported",
+			Message: fmt.Sprintf("unsupported provider: %s (supported: %s)", providerName, strings.Join(providers.SupportedProviders(), ", ")),
+		}
 	}
 
-	// Проверяем обязательные поля для Gemini MCP
+	// MCP параметры берутся из секции mcp, в запросе обязательны только параметры модели
+	var errs []error
 	requiredFields := []string{"api_key", "model"}
 	for _, field := range requiredFields {
 		if _, exists := config[field]; !exists {
-			return fmt.Errorf("missing required field '%s' for Gemini MCP provider", field)
+			errs = append(errs, &ConfigFieldError{
+				Field:   "config." + field,
+				Code:    "required",
+				Message: fmt.Sprintf("missing required field '%s' for %s provider", field, info.Name),
+			})
 		}
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
 func (r *Registry) getGeminiMCPInfo() ProviderInfo {
 	return ProviderInfo{
+		ID:   providers.ProviderGemini,
 		Name: "Gemini (MCP)",
 		SupportedModels: []string{
 			"gemini-2.5-flash",
@@ -59,26 +82,29 @@ func (r *Registry) getGeminiMCPInfo() ProviderInfo {
 		},
 		Description:    "Google's Gemini AI models with MCP (Model Context Protocol) tool support for enhanced capabilities",
 		RequiredConfig: []string{"api_key", "model", "mcp_server_url", "system_prompt_path"},
+		HasMCP:         true,
 	}
 }
 
-// GetProviderByName создает экземпляр провайдера по имени
-func (r *Registry) GetProviderByName(name string, config providers.Config) (providers.Provider, error) {
-	if strings.ToLower(name) != "gemini" {
-		return nil, fmt.Errorf("unsupported provider: %s", name)
+func (r *Registry) getOpenRouterInfo() ProviderInfo {
+	return ProviderInfo{
+		ID:              providers.ProviderOpenRouter,
+		Name:            "OpenRouter",
+		SupportedModels: providers.OpenRouterModels(),
+		Description:     "OpenAI-compatible API with access to models from many vendors; MCP tools are not available",
+		RequiredConfig:  []string{"api_key", "model"},
 	}
+}
 
-	config.Provider = "gemini"
+// GetProviderByName создает экземпляр провайдера по имени
+func (r *Registry) GetProviderByName(name string, config providers.Config) (providers.Provider, error) {
+	config.Provider = strings.ToLower(name)
 	return r.factory.CreateProvider(config)
 }
 
-// GetProviderByNameWithMCP создает экземпляр MCP провайдера
+// GetProviderByNameWithMCP создает экземпляр провайдера с MCP конфигурацией;
+// провайдеры без MCP создаются без неё
 func (r *Registry) GetProviderByNameWithMCP(name string, config providers.Config, mcpConfig providers.MCPProviderConfig) (providers.Provider, error) {
-	if strings.ToLower(name) != "gemini" {
-		return nil, fmt.Errorf("unsupported provider: %s", name)
-	}
-
-	config.Provider = "gemini"
-	factory := r.factory.(*providers.Factory)
-	return factory.CreateProviderWithMCP(config, mcpConfig)
+	config.Provider = strings.ToLower(name)
+	return r.factory.CreateProviderWithMCP(config, mcpConfig)
 }
@@ -2,34 +2,72 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"time"
 
+	"LLM_Chat/pkg/llm/providers"
+
 	"go.uber.org/zap"
 )
 
+// RetryConfig повторы запроса генерации целиком (llm.request_retry). Дополняет повторы отдельного
+// хода в провайдере (llm.retry): покрывает сетевые сбои и недоступность MCP между ходами.
+// Запрос, в котором уже вызывались инструменты, не повторяется (см. providers.ToolActivity).
 type RetryConfig struct {
-	MaxRetries        int
-	InitialDelay      time.Duration
-	MaxDelay          time.Duration
-	BackoffMultiplier float64
-	RetryableErrors   []error
+	MaxRetries        int           `mapstructure:"max_retries"` // 0 - без повторов
+	InitialDelay      time.Duration `mapstructure:"initial_delay"`
+	MaxDelay          time.Duration `mapstructure:"max_delay"`
+	BackoffMultiplier float64       `mapstructure:"backoff_multiplier"`
+	RetryableErrors   []error       `mapstructure:"-"` // пусто - DefaultRetryableErrors
+}
+
+// DefaultRetryableErrors классы ошибок провайдера, после которых запрос повторяется
+var DefaultRetryableErrors = []error{
+	ErrRateLimited,
+	ErrUpstream,
+	ErrMCPUnavailable,
 }
 
 func DefaultRetryConfig() RetryConfig {
 	return RetryConfig{
-		MaxRetries:        3,
+		MaxRetries:        1,
 		InitialDelay:      1 * time.Second,
-		MaxDelay:          30 * time.Second,
+		MaxDelay:          10 * time.Second,
 		BackoffMultiplier: 2.0,
-		RetryableErrors: []error{
-			ErrRateLimited,
-			// Можно добавить другие ретраиабл ошибки
-		},
+		RetryableErrors:   DefaultRetryableErrors,
 	}
 }
 
+// Validate проверяет параметры повторов
+func (r RetryConfig) Validate() error {
+	if r.MaxRetries < 0 {
+		return fmt.Errorf("max_retries cannot be negative: %d", r.MaxRetries)
+	}
+	if r.MaxRetries == 0 {
+		return nil
+	}
+	if r.InitialDelay < 0 {
+		return fmt.Errorf("initial_delay cannot be negative: %s", r.InitialDelay)
+	}
+	if r.MaxDelay < r.InitialDelay {
+		return fmt.Errorf("max_delay (%s) cannot be less than initial_delay (%s)", r.MaxDelay, r.InitialDelay)
+	}
+	if r.BackoffMultiplier < 1 {
+		return fmt.Errorf("backoff_multiplier must be at least 1: %g", r.BackoffMultiplier)
+	}
+	return nil
+}
+
+// SetRetryConfig задаёт повторы, которые ChatCompletion применяет к временным ошибкам провайдера.
+// Вызывается при инициализации, до первых запросов.
+func (c *Client) SetRetryConfig(config RetryConfig) {
+	c.retry = config
+}
+
+// ChatCompletionWithRetry выполняет запрос с заданными повторами вместо настроенных в клиенте.
+// Ошибка после вызова инструмента не повторяется: повтор выполнил бы инструменты ещё раз.
 func (c *Client) ChatCompletionWithRetry(ctx context.Context, messages []Message, retryConfig RetryConfig) (*ChatResponse, error) {
 	var lastErr error
 
@@ -39,6 +77,10 @@ func (c *Client) ChatCompletionWithRetry(ctx context.Context, messages []Message
 			if delay > retryConfig.MaxDelay {
 				delay = retryConfig.MaxDelay
 			}
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+				// Повтор не успеет завершиться до истечения времени запроса
+				return nil, fmt.Errorf("no time left to retry after attempt %d: %w", attempt, lastErr)
+			}
 
 			c.logger.Info("Retrying LLM request",
 				zap.Int("attempt", attempt),
@@ -53,7 +95,8 @@ func (c *Client) ChatCompletionWithRetry(ctx context.Context, messages []Message
 			}
 		}
 
-		resp, err := c.ChatCompletion(ctx, messages)
+		attemptCtx, tools := providers.WithToolActivity(ctx)
+		resp, err := c.chatCompletion(attemptCtx, messages)
 		if err == nil {
 			return resp, nil
 		}
@@ -61,17 +104,32 @@ func (c *Client) ChatCompletionWithRetry(ctx context.Context, messages []Message
 		lastErr = err
 
 		// Проверяем, стоит ли ретраить эту ошибку
-		if !isRetryableError(err, retryConfig.RetryableErrors) {
-			break
+		retryable := retryConfig.RetryableErrors
+		if len(retryable) == 0 {
+			retryable = DefaultRetryableErrors
+		}
+		if tools.Called() && attempt < retryConfig.MaxRetries {
+			c.logger.Warn("Not retrying LLM request: tools were already called", zap.Error(err))
+		}
+		if tools.Called() || !isRetryableError(err, retryable) {
+			if attempt == 0 {
+				return nil, err
+			}
+			return nil, fmt.Errorf("failed after %d attempts: %w", attempt+1, err)
 		}
 	}
 
 	return nil, fmt.Errorf("failed after %d attempts: %w", retryConfig.MaxRetries+1, lastErr)
 }
 
+// isRetryableError проверяет класс ошибки. Отказы самого клиента (разомкнутый выключатель, таймаут
+// очереди слотов) не повторяются: повтор только продлил бы ожидание.
 func isRetryableError(err error, retryableErrors []error) bool {
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrQueueTimeout) {
+		return false
+	}
 	for _, retryableErr := range retryableErrors {
-		if err == retryableErr {
+		if errors.Is(err, retryableErr) {
 			return true
 		}
 	}
@@ -0,0 +1,79 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fastRetry повторы без заметных пауз
+func fastRetry(maxRetries int) RetryConfig {
+	return RetryConfig{
+		MaxRetries:        maxRetries,
+		InitialDelay:      time.Millisecond,
+		MaxDelay:          time.Millisecond,
+		BackoffMultiplier: 2,
+	}
+}
+
+func TestChatCompletionRetriesTransientError(t *testing.T) {
+	provider := &fakeProvider{errs: []error{fmt.Errorf("%w: 503", ErrUpstream)}}
+	client := NewClientWithProvider(provider, zap.NewNop())
+	client.SetRetryConfig(fastRetry(2))
+
+	resp, err := client.ChatCompletion(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatalf("ChatCompletion: %v", err)
+	}
+	if resp.Choices[0].Message.Content != "ok" {
+		t.Errorf("content = %q, want ok", resp.Choices[0].Message.Content)
+	}
+	if provider.calls() != 2 {
+		t.Errorf("provider calls = %d, want 2", provider.calls())
+	}
+}
+
+func TestChatCompletionRetryGivesUp(t *testing.T) {
+	provider := &fakeProvider{errs: []error{ErrUpstream, ErrUpstream, ErrUpstream}}
+	client := NewClientWithProvider(provider, zap.NewNop())
+
+	_, err := client.ChatCompletionWithRetry(context.Background(), []Message{{Role: "user", Content: "hi"}}, fastRetry(1))
+	if !errors.Is(err, ErrUpstream) {
+		t.Fatalf("err = %v, want ErrUpstream", err)
+	}
+	if provider.calls() != 2 {
+		t.Errorf("provider calls = %d, want 2", provider.calls())
+	}
+}
+
+func TestChatCompletionDoesNotRetryPermanentError(t *testing.T) {
+	provider := &fakeProvider{errs: []error{ErrUnauthorized}}
+	client := NewClientWithProvider(provider, zap.NewNop())
+
+	_, err := client.ChatCompletionWithRetry(context.Background(), []Message{{Role: "user", Content: "hi"}}, fastRetry(3))
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("err = %v, want ErrUnauthorized", err)
+	}
+	if provider.calls() != 1 {
+		t.Errorf("provider calls = %d, want 1", provider.calls())
+	}
+}
+
+func TestChatCompletionDoesNotRetryAfterToolCall(t *testing.T) {
+	// Ошибка после вызова инструмента: повтор выполнил бы инструмент ещё раз
+	provider := &fakeProvider{errs: []error{ErrMCPUnavailable}, toolCall: true}
+	client := NewClientWithProvider(provider, zap.NewNop())
+	client.SetRetryConfig(fastRetry(3))
+
+	_, err := client.ChatCompletion(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	if !errors.Is(err, ErrMCPUnavailable) {
+		t.Fatalf("err = %v, want ErrMCPUnavailable", err)
+	}
+	if provider.calls() != 1 {
+		t.Errorf("provider calls = %d, want 1", provider.calls())
+	}
+}
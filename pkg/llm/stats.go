@@ -0,0 +1,180 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"LLM_Chat/pkg/llm/providers"
+)
+
+// Классы ошибок запросов к провайдеру
+const (
+	ErrorTypeRateLimit = "rate_limit"
+	ErrorTypeTimeout   = "timeout"
+	ErrorTypeUpstream  = "upstream"
+	ErrorTypeOther     = "other"
+)
+
+// DefaultModelLabel метка модели, если провайдер не сообщил модель запроса
+const DefaultModelLabel = "default"
+
+// LatencyBuckets верхние границы корзин гистограммы задержек, в секундах
+var LatencyBuckets = []float64{0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120}
+
+// Stats счётчики запросов, ошибок и задержек по провайдеру и модели.
+// Один экземпляр может разделяться несколькими клиентами (main, shrink, failover),
+// чтобы картина по провайдерам оставалась единой.
+type Stats struct {
+	mu      sync.Mutex
+	entries map[statsKey]*providerStats
+}
+
+type statsKey struct {
+	provider string
+	model    string
+}
+
+type providerStats struct {
+	requests     int64
+	errors       map[string]int64
+	bucketCounts []uint64 // по корзинам LatencyBuckets, не накопительно
+	latencySum   float64
+	latencyCount uint64
+}
+
+// ProviderStatsSnapshot снимок счётчиков для пары провайдер/модель
+type ProviderStatsSnapshot struct {
+	Provider string           `json:"provider"`
+	Model    string           `json:"model"`
+	Requests int64            `json:"requests"`
+	Errors   map[string]int64 `json:"errors"`
+
+	// LatencyBuckets накопительные счётчики по верхним границам LatencyBuckets
+	LatencyBuckets map[float64]uint64 `json:"-"`
+	LatencySum     float64            `json:"latency_sum_seconds"`
+	LatencyCount   uint64             `json:"latency_count"`
+}
+
+// NewStats создаёт пустой набор счётчиков
+func NewStats() *Stats {
+	return &Stats{entries: make(map[statsKey]*providerStats)}
+}
+
+// Record учитывает завершённый запрос
+func (s *Stats) Record(provider, model string, duration time.Duration, err error) {
+	if model == "" {
+		model = DefaultModelLabel
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := statsKey{provider: provider, model: model}
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &providerStats{
+			errors:       make(map[string]int64),
+			bucketCounts: make([]uint64, len(LatencyBuckets)),
+		}
+		s.entries[key] = entry
+	}
+
+	entry.requests++
+	if err != nil {
+		entry.errors[ClassifyError(err)]++
+	}
+
+	seconds := duration.Seconds()
+	entry.latencySum += seconds
+	entry.latencyCount++
+	for i, bound := range LatencyBuckets {
+		if seconds <= bound {
+			entry.bucketCounts[i]++
+			break
+		}
+	}
+}
+
+// Snapshot возвращает копию счётчиков, отсортированную по провайдеру и модели
+func (s *Stats) Snapshot() []ProviderStatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshots := make([]ProviderStatsSnapshot, 0, len(s.entries))
+	for key, entry := range s.entries {
+		snapshot := ProviderStatsSnapshot{
+			Provider:       key.provider,
+			Model:          key.model,
+			Requests:       entry.requests,
+			Errors:         make(map[string]int64, len(entry.errors)),
+			LatencyBuckets: make(map[float64]uint64, len(LatencyBuckets)),
+			LatencySum:     entry.latencySum,
+			LatencyCount:   entry.latencyCount,
+		}
+		for errType, count := range entry.errors {
+			snapshot.Errors[errType] = count
+		}
+
+		var cumulative uint64
+		for i, bound := range LatencyBuckets {
+			cumulative += entry.bucketCounts[i]
+			snapshot.LatencyBuckets[bound] = cumulative
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		if snapshots[i].Provider != snapshots[j].Provider {
+			return snapshots[i].Provider < snapshots[j].Provider
+		}
+		return snapshots[i].Model < snapshots[j].Model
+	})
+	return snapshots
+}
+
+// ClassifyError относит ошибку провайдера к одному из классов ErrorType*
+func ClassifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	if errors.Is(err, ErrRateLimited) {
+		return ErrorTypeRateLimit
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorTypeTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorTypeTimeout
+	}
+	if errors.Is(err, ErrUpstream) {
+		return ErrorTypeUpstream
+	}
+
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "429"), strings.Contains(msg, "rate limit"), strings.Contains(msg, "resource_exhausted"), strings.Contains(msg, "quota"):
+		return ErrorTypeRateLimit
+	case strings.Contains(msg, "timeout"), strings.Contains(msg, "deadline exceeded"):
+		return ErrorTypeTimeout
+	case strings.Contains(msg, "status 5"), strings.Contains(msg, "error 5"), strings.Contains(msg, "unavailable"),
+		strings.Contains(msg, "internal error"), strings.Contains(msg, "bad gateway"), strings.Contains(msg, "generate error"):
+		return ErrorTypeUpstream
+	}
+	return ErrorTypeOther
+}
+
+// requestModel возвращает модель запроса: из ответа провайдера или из RequestOptions
+func requestModel(ctx context.Context, resp *ChatResponse) string {
+	if resp != nil && resp.Model != "" {
+		return resp.Model
+	}
+	return providers.RequestOptionsFromContext(ctx).Model
+}
@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestClientRecordsProviderErrors(t *testing.T) {
+	ctx := WithRequestOptions(context.Background(), RequestOptions{Model: "fake-pro"})
+	provider := &fakeProvider{errs: []error{
+		fmt.Errorf("%w: 429", ErrRateLimited),
+		fmt.Errorf("%w: 503", ErrUpstream),
+	}}
+	client := NewClientWithProvider(provider, zap.NewNop())
+	messages := []Message{{Role: "user", Content: "hi"}}
+
+	for i := 0; i < 3; i++ {
+		client.ChatCompletionWithRetry(ctx, messages, RetryConfig{})
+	}
+
+	byModel := make(map[string]ProviderStatsSnapshot)
+	for _, snapshot := range client.Stats().Snapshot() {
+		byModel[snapshot.Model] = snapshot
+	}
+	// Ошибочные запросы учитываются под моделью из RequestOptions, успешный - под моделью ответа
+	failed, succeeded := byModel["fake-pro"], byModel["fake-model"]
+	if failed.Provider != "fake" || failed.Model != "fake-pro" || failed.Requests != 2 {
+		t.Errorf("failed requests = %+v", failed)
+	}
+	if failed.Errors[ErrorTypeRateLimit] != 1 || failed.Errors[ErrorTypeUpstream] != 1 {
+		t.Errorf("error counters = %v, want one rate_limit and one upstream", failed.Errors)
+	}
+	if succeeded.Model != "fake-model" || succeeded.Requests != 1 || len(succeeded.Errors) != 0 {
+		t.Errorf("successful requests = %+v", succeeded)
+	}
+	if failed.LatencyCount != 2 || failed.LatencyBuckets[LatencyBuckets[len(LatencyBuckets)-1]] != 2 {
+		t.Errorf("latency histogram = %+v", failed)
+	}
+}
+
+func TestSharedStats(t *testing.T) {
+	// Клиенты main и shrink с общими счётчиками дают единую картину по провайдеру
+	stats := NewStats()
+	for _, provider := range []*fakeProvider{{}, {errs: []error{ErrUpstream}}} {
+		client := NewClientWithProvider(provider, zap.NewNop())
+		client.SetStats(stats)
+		client.ChatCompletionWithRetry(context.Background(), []Message{{Role: "user", Content: "hi"}}, RetryConfig{})
+	}
+
+	var requests, upstream int64
+	for _, snapshot := range stats.Snapshot() {
+		requests += snapshot.Requests
+		upstream += snapshot.Errors[ErrorTypeUpstream]
+	}
+	if requests != 2 || upstream != 1 {
+		t.Errorf("requests = %d, upstream errors = %d; want 2 and 1", requests, upstream)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{fmt.Errorf("wrapped: %w", ErrRateLimited), ErrorTypeRateLimit},
+		{context.DeadlineExceeded, ErrorTypeTimeout},
+		{fmt.Errorf("%w: 500", ErrUpstream), ErrorTypeUpstream},
+		{errors.New("googleapi: Error 429: RESOURCE_EXHAUSTED"), ErrorTypeRateLimit},
+		{errors.New("request timeout"), ErrorTypeTimeout},
+		{errors.New("service unavailable"), ErrorTypeUpstream},
+		{errors.New("invalid argument"), ErrorTypeOther},
+	}
+	for _, tt := range tests {
+		if got := ClassifyError(tt.err); got != tt.want {
+			t.Errorf("ClassifyError(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestStatsLatencyBuckets(t *testing.T) {
+	stats := NewStats()
+	stats.Record("p", "", 100*time.Millisecond, nil)
+	stats.Record("p", "", 3*time.Second, nil)
+
+	snapshot := stats.Snapshot()[0]
+	if snapshot.Model != DefaultModelLabel {
+		t.Errorf("model = %q, want %q", snapshot.Model, DefaultModelLabel)
+	}
+	if snapshot.LatencyBuckets[0.25] != 1 || snapshot.LatencyBuckets[5] != 2 {
+		t.Errorf("cumulative buckets = %v", snapshot.LatencyBuckets)
+	}
+}
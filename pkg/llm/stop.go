@@ -0,0 +1,154 @@
+package llm
+
+import (
+	"strings"
+
+	"LLM_Chat/pkg/llm/providers"
+)
+
+// FinishReasonStopSequence совместимая константа
+const FinishReasonStopSequence = providers.FinishReasonStopSequence
+
+// truncateAtStop обрезает текст перед первым вхождением любой стоп-последовательности.
+// Провайдеры останавливают генерацию сами, но часть моделей OpenRouter возвращает
+// последовательность в ответе - она удаляется здесь одинаково для всех провайдеров.
+func truncateAtStop(text string, stops []string) (string, bool) {
+	if idx := indexStop(text, stops); idx >= 0 {
+		return text[:idx], true
+	}
+	return text, false
+}
+
+// indexStop возвращает позицию самого раннего вхождения стоп-последовательности или -1
+func indexStop(text string, stops []string) int {
+	first := -1
+	for _, stop := range stops {
+		if stop == "" {
+			continue
+		}
+		if idx := strings.Index(text, stop); idx >= 0 && (first < 0 || idx < first) {
+			first = idx
+		}
+	}
+	return first
+}
+
+// applyStopSequences обрезает ответы по стоп-последовательностям запроса
+func applyStopSequences(resp *ChatResponse, stops []string) {
+	if resp == nil || len(stops) == 0 {
+		return
+	}
+
+	for i := range resp.Choices {
+		content, stopped := truncateAtStop(resp.Choices[i].Message.Content, stops)
+		if stopped {
+			resp.Choices[i].Message.Content = content
+			resp.Choices[i].FinishReason = FinishReasonStopSequence
+		}
+	}
+}
+
+// stopFilter ищет стоп-последовательности в потоке чанков.
+// Хвост текста, который может оказаться началом последовательности, задерживается
+// до следующего чанка, поэтому последовательность на границе чанков тоже отсекается.
+type stopFilter struct {
+	stops   []string
+	pending string
+}
+
+// push добавляет текст чанка и возвращает часть, которую можно отдать клиенту.
+// stopped сообщает, что найдена стоп-последовательность и текст после неё отброшен.
+func (f *stopFilter) push(text string) (emit string, stopped bool) {
+	f.pending += text
+
+	if idx := indexStop(f.pending, f.stops); idx >= 0 {
+		emit = f.pending[:idx]
+		f.pending = ""
+		return emit, true
+	}
+
+	keep := f.partialSuffix()
+	emit = f.pending[:len(f.pending)-keep]
+	f.pending = f.pending[len(f.pending)-keep:]
+	return emit, false
+}
+
+// flush возвращает задержанный хвост по завершении потока
+func (f *stopFilter) flush() string {
+	rest := f.pending
+	f.pending = ""
+	return rest
+}
+
+// partialSuffix длина самого длинного хвоста pending, совпадающего с началом какой-либо последовательности
+func (f *stopFilter) partialSuffix() int {
+	longest := 0
+	for _, stop := range f.stops {
+		n := len(stop) - 1
+		if n > len(f.pending) {
+			n = len(f.pending)
+		}
+		for ; n > longest; n-- {
+			if strings.HasPrefix(stop, f.pending[len(f.pending)-n:]) {
+				longest = n
+				break
+			}
+		}
+	}
+	return longest
+}
+
+// filterStopSequences отсекает поток на первой стоп-последовательности и завершает его
+// чанком Done с причиной stop_sequence. Оставшиеся чанки провайдера дочитываются:
+// из финального берутся параметры генерации и число итераций.
+func filterStopSequences(chunks <-chan StreamChunk, stops []string) <-chan StreamChunk {
+	out := make(chan StreamChunk, cap(chunks))
+
+	go func() {
+		defer close(out)
+
+		filter := &stopFilter{stops: stops}
+		for chunk := range chunks {
+			if chunk.Content == "" {
+				if chunk.Done {
+					if rest := filter.flush(); rest != "" {
+						out <- StreamChunk{Content: rest}
+					}
+				}
+				out <- chunk
+				continue
+			}
+
+			emit, stopped := filter.push(chunk.Content)
+			if emit != "" {
+				out <- StreamChunk{Content: emit}
+			}
+
+			if stopped {
+				out <- stoppedChunk(chunks)
+				return
+			}
+
+			if chunk.Done {
+				chunk.Content = filter.flush()
+				out <- chunk
+			}
+		}
+	}()
+
+	return out
+}
+
+// stoppedChunk дочитывает поток после стоп-последовательности и возвращает финальный чанк
+// провайдера (метаданные, usage, вызовы инструментов) с причиной stop_sequence
+func stoppedChunk(chunks <-chan StreamChunk) StreamChunk {
+	done := StreamChunk{Done: true}
+	for chunk := range chunks {
+		if chunk.Done {
+			done = chunk
+			done.Content = ""
+		}
+	}
+	done.FinishReason = FinishReasonStopSequence
+	return done
+}
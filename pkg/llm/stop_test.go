@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"testing"
+)
+
+// streamThroughStop пропускает части ответа через filterStopSequences и возвращает
+// полученный текст, финальный чанк и число чанков с текстом
+func streamThroughStop(stops []string, parts ...string) (string, StreamChunk, int) {
+	in := make(chan StreamChunk, len(parts)+1)
+	for _, part := range parts {
+		in <- StreamChunk{Content: part}
+	}
+	in <- StreamChunk{Done: true, FinishReason: "stop", Iterations: 2}
+	close(in)
+
+	var text string
+	var done StreamChunk
+	contentChunks := 0
+	for chunk := range filterStopSequences(in, stops) {
+		if chunk.Content != "" {
+			contentChunks++
+		}
+		text += chunk.Content
+		if chunk.Done {
+			done = chunk
+		}
+	}
+	return text, done, contentChunks
+}
+
+func TestStreamStopSequenceSplitAcrossChunks(t *testing.T) {
+	text, done, _ := streamThroughStop([]string{"\n###END"}, "hello wor", "ld\n##", "#EN", "D trailing", " more")
+	if text != "hello world" {
+		t.Errorf("text = %q, want %q", text, "hello world")
+	}
+	// Параметры финального чанка провайдера сохраняются
+	if done.FinishReason != FinishReasonStopSequence || done.Iterations != 2 {
+		t.Errorf("done = %+v, want stop_sequence with 2 iterations", done)
+	}
+}
+
+func TestStreamPartialStopSequenceIsReleased(t *testing.T) {
+	// Начало стоп-последовательности без продолжения отдаётся клиенту как обычный текст
+	text, done, chunks := streamThroughStop([]string{"\n###END"}, "a\n##", "b", " c\n#")
+	if text != "a\n##b c\n#" {
+		t.Errorf("text = %q", text)
+	}
+	if done.FinishReason != "stop" {
+		t.Errorf("finish reason = %q, want stop", done.FinishReason)
+	}
+	if chunks < 2 {
+		t.Errorf("content chunks = %d: the stream was buffered entirely", chunks)
+	}
+}
+
+func TestStreamStopSequences(t *testing.T) {
+	tests := []struct {
+		name  string
+		stops []string
+		parts []string
+		want  string
+	}{
+		{"earliest of several", []string{"XY", "Z"}, []string{"abX", "Zq"}, "abX"},
+		{"multibyte", []string{"ёж"}, []string{"приветё", "ж"}, "привет"},
+		{"in the first chunk", []string{"STOP"}, []string{"okSTOPnot"}, "ok"},
+		{"no stops", nil, []string{"a", "b"}, "ab"},
+	}
+	for _, tt := range tests {
+		if text, _, _ := streamThroughStop(tt.stops, tt.parts...); text != tt.want {
+			t.Errorf("%s: text = %q, want %q", tt.name, text, tt.want)
+		}
+	}
+}
+
+func TestApplyStopSequences(t *testing.T) {
+	resp := &ChatResponse{Choices: []Choice{{Message: Message{Content: "x\n###END y"}, FinishReason: "stop"}}}
+	applyStopSequences(resp, []string{"\n###END"})
+	if resp.Choices[0].Message.Content != "x" || resp.Choices[0].FinishReason != FinishReasonStopSequence {
+		t.Errorf("choice = %+v", resp.Choices[0])
+	}
+
+	// Ответ без стоп-последовательности не меняется
+	resp = &ChatResponse{Choices: []Choice{{Message: Message{Content: "x"}, FinishReason: "stop"}}}
+	applyStopSequences(resp, []string{"\n###END"})
+	if resp.Choices[0].FinishReason != "stop" {
+		t.Errorf("finish reason = %q, want stop", resp.Choices[0].FinishReason)
+	}
+}
@@ -1,23 +1,63 @@
 package llm
 
 import (
+	"context"
+	"fmt"
+
 	"LLM_Chat/internal/storage/models"
+	"LLM_Chat/pkg/tokens"
 )
 
-// ConvertToLLMMessages converts storage models to LLM messages
-func ConvertToLLMMessages(storageMessages []models.Message) []Message {
-	llmMessages := make([]Message, len(storageMessages))
+// ConvertOptions политика преобразования сообщений хранилища в сообщения LLM
+type ConvertOptions struct {
+	// IncludeSummaries включает сообщения типа summary/bulk_summary/session_digest; по умолчанию они
+	// пропускаются, так как резюме передаются в контекст отдельно
+	IncludeSummaries bool
+
+	// DropToolMessages пропускает результаты инструментов; по умолчанию они
+	// передаются как сообщения ассистента с меткой инструмента
+	DropToolMessages bool
+}
+
+// ConvertToLLMMessages converts storage models to LLM messages.
+// Роли, которые провайдеры не принимают, приводятся к поддерживаемым согласно opts.
+func ConvertToLLMMessages(storageMessages []models.Message, opts ConvertOptions) []Message {
+	llmMessages := make([]Message, 0, len(storageMessages))
+
+	for _, msg := range storageMessages {
+		if (msg.IsSummary() || msg.IsBulkSummary() || msg.IsSessionDigest()) && !opts.IncludeSummaries {
+			continue
+		}
+
+		if msg.Role == "tool" {
+			if opts.DropToolMessages {
+				continue
+			}
+			llmMessages = append(llmMessages, Message{
+				Role:    "assistant",
+				Content: formatToolResult(msg),
+			})
+			continue
+		}
 
-	for i, msg := range storageMessages {
-		llmMessages[i] = Message{
+		llmMessages = append(llmMessages, Message{
 			Role:    msg.Role,
 			Content: msg.Content,
-		}
+		})
 	}
 
 	return llmMessages
 }
 
+// formatToolResult представляет результат инструмента как текст ассистента
+func formatToolResult(msg models.Message) string {
+	name := msg.ToolName
+	if name == "" {
+		name = "unknown"
+	}
+	return fmt.Sprintf("[Результат инструмента %s]\n%s", name, msg.Content)
+}
+
 // ConvertFromLLMMessage converts LLM message to storage model
 func ConvertFromLLMMessage(llmMsg Message, sessionID string) models.Message {
 	return models.Message{
@@ -62,3 +102,12 @@ func TrimMessages(messages []Message, limit int) []Message {
 	// Берём последние limit сообщений
 	return messages[len(messages)-limit:]
 }
+
+// EstimateTokens оценивает токены сообщений, включая служебную разметку ролей
+func EstimateTokens(ctx context.Context, estimator tokens.Estimator, messages []Message) (int, error) {
+	converted := make([]tokens.Message, len(messages))
+	for i, msg := range messages {
+		converted[i] = tokens.Message{Role: msg.Role, Content: msg.Content}
+	}
+	return tokens.EstimateMessages(ctx, estimator, converted)
+}
@@ -0,0 +1,74 @@
+package llm
+
+import (
+	"fmt"
+	"testing"
+
+	"LLM_Chat/internal/storage/models"
+)
+
+// mixedHistory диалог со всеми видами сообщений хранилища
+func mixedHistory() []models.Message {
+	return []models.Message{
+		models.NewUserMessage("s1", "Какая погода?"),
+		models.NewToolMessage("s1", `{"temp":21}`, "weather", "call-1"),
+		models.NewToolMessage("s1", `{}`, "", "call-2"),
+		models.NewAssistantMessage("s1", "Сейчас +21"),
+		models.NewSummaryMessage("s1", "резюме", 1),
+		models.NewSummaryMessage("s1", "bulk", 2),
+		models.NewSummaryMessage("s1", "дайджест", 3),
+		models.NewUserMessage("s1", "Спасибо"),
+	}
+}
+
+func TestConvertToLLMMessages(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ConvertOptions
+		want []Message
+	}{
+		{
+			name: "default",
+			want: []Message{
+				{Role: "user", Content: "Какая погода?"},
+				{Role: "assistant", Content: "[Результат инструмента weather]\n{\"temp\":21}"},
+				{Role: "assistant", Content: "[Результат инструмента unknown]\n{}"},
+				{Role: "assistant", Content: "Сейчас +21"},
+				{Role: "user", Content: "Спасибо"},
+			},
+		},
+		{
+			name: "drop tools",
+			opts: ConvertOptions{DropToolMessages: true},
+			want: []Message{
+				{Role: "user", Content: "Какая погода?"},
+				{Role: "assistant", Content: "Сейчас +21"},
+				{Role: "user", Content: "Спасибо"},
+			},
+		},
+		{
+			name: "summaries",
+			opts: ConvertOptions{IncludeSummaries: true, DropToolMessages: true},
+			want: []Message{
+				{Role: "user", Content: "Какая погода?"},
+				{Role: "assistant", Content: "Сейчас +21"},
+				{Role: "assistant", Content: "резюме"},
+				{Role: "assistant", Content: "bulk"},
+				{Role: "assistant", Content: "дайджест"},
+				{Role: "user", Content: "Спасибо"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		got := ConvertToLLMMessages(mixedHistory(), tt.opts)
+		if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.want) {
+			t.Errorf("%s:\n got %q\nwant %q", tt.name, got, tt.want)
+		}
+		for _, msg := range got {
+			if msg.Role == "tool" {
+				t.Errorf("%s: tool role reached the provider", tt.name)
+			}
+		}
+	}
+}
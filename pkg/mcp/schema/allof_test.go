@@ -0,0 +1,84 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// pydanticArgs схема аргументов в том виде, в каком её генерирует Pydantic v1:
+// поля-модели и enum обёрнуты в allOf вокруг $ref, чтобы рядом можно было указать описание
+const pydanticArgs = `{
+	"title": "Args",
+	"type": "object",
+	"properties": {
+		"color": {"allOf": [{"$ref": "#/definitions/Color"}], "description": "Paint color"},
+		"owner": {
+			"allOf": [
+				{"$ref": "#/definitions/Person"},
+				{"type": "object", "properties": {"age": {"type": "integer"}}, "required": ["age"]}
+			],
+			"description": "Owner"
+		},
+		"choice": {"oneOf": [{"type": "null"}, {"type": "integer"}]},
+		"shape": {"oneOf": [{"$ref": "#/definitions/Circle"}, {"type": "string"}]}
+	},
+	"required": ["color"],
+	"definitions": {
+		"Color": {"title": "Color", "description": "An enumeration.", "enum": ["red", "green"], "type": "string"},
+		"Person": {"title": "Person", "description": "A person", "type": "object", "properties": {"name": {"type": "string"}}, "required": ["name"]},
+		"Circle": {"title": "Circle", "type": "object", "properties": {"radius": {"type": "number"}}}
+	}
+}`
+
+func TestAllOfAroundRef(t *testing.T) {
+	params := ToGeminiParameters(parseSchema(t, pydanticArgs))
+
+	color := params.Properties["color"]
+	if color.Type != genai.TypeString || len(color.Enum) != 2 {
+		t.Errorf("color = %+v, want string enum", color)
+	}
+	if color.Description != "Paint color\nAn enumeration." {
+		t.Errorf("color description = %q", color.Description)
+	}
+
+	// Свойства и обязательные поля всех частей объединяются
+	owner := params.Properties["owner"]
+	if owner.Type != genai.TypeObject || owner.Properties["name"] == nil || owner.Properties["age"] == nil {
+		t.Fatalf("owner = %+v, want merged object", owner)
+	}
+	if len(owner.Required) != 2 || owner.Description != "Owner\nA person" {
+		t.Errorf("owner required = %v, description = %q", owner.Required, owner.Description)
+	}
+}
+
+func TestOneOfLikeAnyOf(t *testing.T) {
+	params := ToGeminiParameters(parseSchema(t, pydanticArgs))
+
+	if choice := params.Properties["choice"]; choice.Type != genai.TypeInteger {
+		t.Errorf("choice = %+v, want the first non-null variant", choice)
+	}
+	if shape := params.Properties["shape"]; shape.Type != genai.TypeObject || shape.Properties["radius"] == nil {
+		t.Errorf("shape = %+v, want the referenced object", shape)
+	}
+}
+
+func TestAllOfAtRoot(t *testing.T) {
+	params := ToGeminiParameters(&jsonschema.Schema{AllOf: []*jsonschema.Schema{
+		{Type: "object", Properties: map[string]*jsonschema.Schema{"a": {Type: "string"}}, Required: []string{"a"}},
+		{Type: "object", Properties: map[string]*jsonschema.Schema{"b": {Type: "integer"}}},
+	}})
+	if params.Type != genai.TypeObject || len(params.Properties) != 2 || len(params.Required) != 1 {
+		t.Errorf("parameters = %+v, want merged root object", params)
+	}
+
+	// Более поздняя часть переопределяет свойство с тем же именем
+	merged := ToGeminiSchema(parseSchema(t, `{"allOf": [
+		{"type": "object", "properties": {"x": {"type": "string"}}},
+		{"type": "object", "properties": {"x": {"type": "integer"}}}
+	]}`))
+	if merged.Properties["x"].Type != genai.TypeInteger {
+		t.Errorf("x = %+v, want the later variant", merged.Properties["x"])
+	}
+}
@@ -0,0 +1,51 @@
+package schema
+
+import "testing"
+
+func TestConstraintHints(t *testing.T) {
+	params := ToGeminiParameters(parseSchema(t, `{
+		"type": "object",
+		"properties": {
+			"when": {"type": "string", "format": "date-time", "description": "Start"},
+			"id": {"type": "string", "format": "uuid"},
+			"n": {"type": "integer", "format": "int32", "minimum": 1, "maximum": 100},
+			"code": {"type": "string", "pattern": "^[A-Z]+$", "minLength": 2, "maxLength": 8},
+			"x": {"type": "number", "exclusiveMinimum": 0.5, "exclusiveMaximum": 1.5},
+			"tags": {"type": "array", "items": {"type": "string"}, "minItems": 1, "maxItems": 3},
+			"plain": {"type": "string", "description": "No constraints"}
+		}
+	}`))
+
+	tests := map[string]string{
+		"when":  "Start (format: date-time)",
+		"id":    "(format: uuid)",
+		"n":     "(format: int32, min: 1, max: 100)",
+		"code":  "(min length: 2, max length: 8, pattern: ^[A-Z]+$)",
+		"x":     "(exclusive min: 0.5, exclusive max: 1.5)",
+		"tags":  "(min items: 1, max items: 3)",
+		"plain": "No constraints",
+	}
+	for name, want := range tests {
+		if got := params.Properties[name].Description; got != want {
+			t.Errorf("%s: description = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSupportedFormats(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{`{"type":"integer","format":"int64"}`, "int64"},
+		{`{"type":"number","format":"double"}`, "double"},
+		// Gemini не принимает форматы строк: они остаются только подсказкой
+		{`{"type":"string","format":"date-time"}`, ""},
+		{`{"type":"integer","format":"double"}`, ""},
+	}
+	for _, tt := range tests {
+		if got := ToGeminiSchema(parseSchema(t, tt.raw)).Format; got != tt.want {
+			t.Errorf("%s: format = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+func TestNonStringEnums(t *testing.T) {
+	params := ToGeminiParameters(parseSchema(t, `{
+		"type": "object",
+		"properties": {
+			"level": {"type": "integer", "enum": [1, 2, 3]},
+			"ratio": {"type": "number", "enum": [0.5, 1], "description": "Scale"},
+			"flag": {"type": "boolean", "enum": [true, false]},
+			"mixed": {"type": "string", "enum": ["a", 1, 2.5, true, null]},
+			"untyped": {"enum": [1, "b"]}
+		}
+	}`))
+
+	tests := []struct {
+		name string
+		typ  genai.Type
+		enum []string
+		desc string
+	}{
+		// Числовой тип сохраняется, допустимые значения переходят в описание
+		{"level", genai.TypeInteger, nil, "(allowed values: 1, 2, 3)"},
+		{"ratio", genai.TypeNumber, nil, "Scale (allowed values: 0.5, 1)"},
+		{"flag", genai.TypeBoolean, nil, "(allowed values: true, false)"},
+		// У строк числа и булевы значения приводятся к строкам, null пропускается
+		{"mixed", genai.TypeString, []string{"a", "1", "2.5", "true"}, ""},
+		{"untyped", genai.TypeString, []string{"1", "b"}, ""},
+	}
+	for _, tt := range tests {
+		got := params.Properties[tt.name]
+		if got.Type != tt.typ || !reflect.DeepEqual(got.Enum, tt.enum) || got.Description != tt.desc {
+			t.Errorf("%s = {type %v, enum %q, description %q}, want {type %v, enum %q, description %q}",
+				tt.name, got.Type, got.Enum, got.Description, tt.typ, tt.enum, tt.desc)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package schema
+
+import (
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// ToJSON представляет сконвертированную схему Gemini в виде JSON объекта
+// (type, format, description, nullable, enum, items, properties, required),
+// чтобы клиенты API видели параметры функции в том виде, в каком их получает модель.
+// Типы записываются в нижнем регистре, как в JSON Schema.
+func ToJSON(s *genai.Schema) map[string]any {
+	if s == nil {
+		return nil
+	}
+
+	out := map[string]any{"type": jsonType(s.Type)}
+	if s.Format != "" {
+		out["format"] = s.Format
+	}
+	if s.Description != "" {
+		out["description"] = s.Description
+	}
+	if s.Nullable {
+		out["nullable"] = true
+	}
+	if len(s.Enum) > 0 {
+		out["enum"] = s.Enum
+	}
+	if s.Items != nil {
+		out["items"] = ToJSON(s.Items)
+	}
+	if s.Type == genai.TypeObject || len(s.Properties) > 0 {
+		properties := make(map[string]any, len(s.Properties))
+		for name, prop := range s.Properties {
+			properties[name] = ToJSON(prop)
+		}
+		out["properties"] = properties
+	}
+	if len(s.Required) > 0 {
+		out["required"] = s.Required
+	}
+	return out
+}
+
+// jsonType имя типа Gemini в нижнем регистре: TypeString -> "string"
+func jsonType(t genai.Type) string {
+	return strings.ToLower(strings.TrimPrefix(t.String(), "Type"))
+}
@@ -0,0 +1,57 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+func TestNullableParameters(t *testing.T) {
+	params := ToGeminiParameters(parseSchema(t, `{
+		"type": "object",
+		"properties": {
+			"address": {
+				"anyOf": [
+					{"type": "object", "properties": {"city": {"type": "string"}}, "required": ["city"]},
+					{"type": "null"}
+				],
+				"default": null,
+				"description": "Delivery address"
+			},
+			"inner": {"anyOf": [{"$ref": "#/$defs/Inner"}, {"type": "null"}], "description": "Wrapper"},
+			"count": {"oneOf": [{"type": "null"}, {"type": "integer"}]},
+			"limit": {"type": ["null", "integer"]},
+			"plain": {"anyOf": [{"type": "string"}, {"type": "integer"}]}
+		},
+		"$defs": {"Inner": {"type": "object", "description": "Inner object", "properties": {}}}
+	}`))
+
+	// Описание обёртки переходит на объект без своего описания
+	address := params.Properties["address"]
+	if address.Type != genai.TypeObject || !address.Nullable || len(address.Required) != 1 {
+		t.Errorf("address = %+v, want nullable object", address)
+	}
+	if address.Description != "Delivery address" {
+		t.Errorf("address description = %q, want the wrapper's", address.Description)
+	}
+	// Собственное описание варианта не заменяется
+	if inner := params.Properties["inner"]; !inner.Nullable || inner.Description != "Inner object" {
+		t.Errorf("inner = %+v", inner)
+	}
+
+	tests := []struct {
+		name     string
+		typ      genai.Type
+		nullable bool
+	}{
+		{"count", genai.TypeInteger, true},
+		{"limit", genai.TypeInteger, true},
+		{"plain", genai.TypeString, false},
+	}
+	for _, tt := range tests {
+		got := params.Properties[tt.name]
+		if got.Type != tt.typ || got.Nullable != tt.nullable {
+			t.Errorf("%s = {type %v, nullable %v}, want {type %v, nullable %v}", tt.name, got.Type, got.Nullable, tt.typ, tt.nullable)
+		}
+	}
+}
@@ -0,0 +1,482 @@
+// Package schema переводит JSON Schema входных параметров MCP инструментов
+// в объявления функций Gemini. Конвертация собрана в одном месте, чтобы
+// особенности схем (anyOf/oneOf с null, allOf, $ref, массивы без items, enum) обрабатывались одинаково.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ToGeminiFunctionDeclarations создаёт объявления функций Gemini для инструментов MCP.
+// Параметры функции Gemini всегда объект: схема без типа считается объектом,
+// схема другого типа заменяется объектом без свойств.
+func ToGeminiFunctionDeclarations(tools []*mcp.Tool) []*genai.FunctionDeclaration {
+	out := make([]*genai.FunctionDeclaration, 0, len(tools))
+	for _, t := range tools {
+		desc := t.Description
+		if desc == "" && t.Annotations != nil {
+			desc = t.Annotations.Title
+		}
+
+		out = append(out, &genai.FunctionDeclaration{
+			Name:        t.Name,
+			Description: desc,
+			Parameters:  ToGeminiParameters(t.InputSchema),
+		})
+	}
+	return out
+}
+
+// ToGeminiParameters конвертирует корневую схему входных параметров инструмента.
+// Локальные ссылки $ref разрешаются по $defs и definitions корневой схемы.
+func ToGeminiParameters(root *jsonschema.Schema) *genai.Schema {
+	if root == nil {
+		return &genai.Schema{Type: genai.TypeObject, Properties: map[string]*genai.Schema{}}
+	}
+
+	c := newConverter(root)
+	if target, ok := c.resolve(root.Ref); ok {
+		root = target
+	}
+
+	if len(root.AllOf) > 0 {
+		if merged := c.mergeAllOf(root); merged.Type == genai.TypeObject {
+			if merged.Properties == nil {
+				merged.Properties = map[string]*genai.Schema{}
+			}
+			return merged
+		}
+	}
+
+	rootType := schemaType(root)
+	if rootType != "" && rootType != "object" {
+		return &genai.Schema{
+			Type:        genai.TypeObject,
+			Description: firstNonEmpty(root.Description, root.Title),
+		}
+	}
+
+	props := map[string]*genai.Schema{}
+	for name, sub := range root.Properties {
+		props[name] = c.convert(sub)
+	}
+	return &genai.Schema{
+		Type:        genai.TypeObject,
+		Properties:  props,
+		Required:    append([]string(nil), root.Required...),
+		Description: firstNonEmpty(root.Description, root.Title),
+	}
+}
+
+// ToGeminiSchema конвертирует схему свойства. Из anyOf и oneOf берётся первый вариант, отличный от null
+// (вариант null помечает параметр как Nullable), варианты allOf объединяются в одну схему;
+// неизвестные и отсутствующие типы становятся строкой, в enum остаются только строковые значения
+// (числа и булевы приводятся к строкам). Ограничения, которые Gemini не принимает
+// (format, диапазоны, длина, pattern), дописываются в описание.
+// Ссылки $ref разрешаются по $defs и definitions самой схемы.
+func ToGeminiSchema(s *jsonschema.Schema) *genai.Schema {
+	return newConverter(s).convert(s)
+}
+
+// converter конвертирует схемы одного инструмента, разрешая $ref относительно корня
+type converter struct {
+	root     *jsonschema.Schema
+	visiting map[string]bool // ссылки, раскрываемые сейчас: защита от циклов
+}
+
+func newConverter(root *jsonschema.Schema) *converter {
+	return &converter{root: root, visiting: make(map[string]bool)}
+}
+
+func (c *converter) convert(s *jsonschema.Schema) *genai.Schema {
+	if s == nil {
+		return &genai.Schema{Type: genai.TypeString}
+	}
+
+	if s.Ref != "" {
+		if target, ok := c.resolve(s.Ref); ok {
+			return c.convertRef(s, target)
+		}
+	}
+
+	alternatives := s.AnyOf
+	if len(alternatives) == 0 {
+		alternatives = s.OneOf
+	}
+	if len(alternatives) > 0 {
+		return c.convertAlternatives(s, alternatives)
+	}
+
+	if len(s.AllOf) > 0 {
+		return c.mergeAllOf(s)
+	}
+
+	gType := genaiType(schemaType(s))
+	desc := withConstraints(firstNonEmpty(s.Description, s.Title), s)
+	enumVals := enumStrings(s.Enum)
+	nullable := isNullable(s)
+
+	switch gType {
+	case genai.TypeArray:
+		return &genai.Schema{
+			Type:        genai.TypeArray,
+			Items:       c.convert(s.Items),
+			Description: desc,
+			Enum:        enumVals,
+			Nullable:    nullable,
+		}
+
+	case genai.TypeObject:
+		props := map[string]*genai.Schema{}
+		for name, sub := range s.Properties {
+			props[name] = c.convert(sub)
+		}
+		var required []string
+		if len(s.Required) > 0 {
+			required = append(required, s.Required...)
+		}
+		return &genai.Schema{
+			Type:        genai.TypeObject,
+			Properties:  props,
+			Required:    required,
+			Description: desc,
+			Enum:        enumVals,
+			Nullable:    nullable,
+		}
+
+	case genai.TypeString:
+		return &genai.Schema{
+			Type:        genai.TypeString,
+			Format:      geminiFormat(gType, s.Format),
+			Description: desc,
+			Enum:        enumVals,
+			Nullable:    nullable,
+		}
+
+	default:
+		// Gemini принимает enum только у строк: допустимые числа и булевы значения
+		// остаются в описании (см. withConstraints), а тип параметра не меняется
+		return &genai.Schema{
+			Type:        gType,
+			Format:      geminiFormat(gType, s.Format),
+			Description: desc,
+			Nullable:    nullable,
+		}
+	}
+}
+
+// geminiFormat формат, который Gemini принимает для типа: float/double для чисел, int32/int64 для целых.
+// Остальные форматы передаются только подсказкой в описании.
+func geminiFormat(t genai.Type, format string) string {
+	switch {
+	case t == genai.TypeNumber && (format == "float" || format == "double"):
+		return format
+	case t == genai.TypeInteger && (format == "int32" || format == "int64"):
+		return format
+	default:
+		return ""
+	}
+}
+
+// withConstraints дописывает к описанию ограничения схемы: "Дата (format: date-time, min length: 10)"
+func withConstraints(desc string, s *jsonschema.Schema) string {
+	var hints []string
+	add := func(name, value string) {
+		hints = append(hints, name+": "+value)
+	}
+	num := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	if s.Format != "" {
+		add("format", s.Format)
+	}
+	if s.Minimum != nil {
+		add("min", num(*s.Minimum))
+	}
+	if s.ExclusiveMinimum != nil {
+		add("exclusive min", num(*s.ExclusiveMinimum))
+	}
+	if s.Maximum != nil {
+		add("max", num(*s.Maximum))
+	}
+	if s.ExclusiveMaximum != nil {
+		add("exclusive max", num(*s.ExclusiveMaximum))
+	}
+	if s.MinLength != nil {
+		add("min length", strconv.Itoa(*s.MinLength))
+	}
+	if s.MaxLength != nil {
+		add("max length", strconv.Itoa(*s.MaxLength))
+	}
+	if s.Pattern != "" {
+		add("pattern", s.Pattern)
+	}
+	if s.MinItems != nil {
+		add("min items", strconv.Itoa(*s.MinItems))
+	}
+	if s.MaxItems != nil {
+		add("max items", strconv.Itoa(*s.MaxItems))
+	}
+	switch schemaType(s) {
+	case "integer", "number", "boolean":
+		if vals := enumStrings(s.Enum); len(vals) > 0 {
+			add("allowed values", strings.Join(vals, ", "))
+		}
+	}
+
+	if len(hints) == 0 {
+		return desc
+	}
+	hint := fmt.Sprintf("(%s)", strings.Join(hints, ", "))
+	if desc == "" {
+		return hint
+	}
+	return desc + " " + hint
+}
+
+// convertRef раскрывает ссылку. Описание рядом с $ref важнее описания определения.
+// Повторная ссылка внутри собственного раскрытия (рекурсивная схема) не раскрывается:
+// вместо неё остаётся схема того же типа без вложенных свойств.
+func (c *converter) convertRef(s, target *jsonschema.Schema) *genai.Schema {
+	var out *genai.Schema
+	if c.visiting[s.Ref] {
+		out = &genai.Schema{
+			Type:        genaiType(schemaType(target)),
+			Description: firstNonEmpty(target.Description, target.Title),
+		}
+	} else {
+		c.visiting[s.Ref] = true
+		out = c.convert(target)
+		delete(c.visiting, s.Ref)
+	}
+
+	if desc := firstNonEmpty(s.Description, s.Title); desc != "" {
+		out.Description = desc
+	}
+	return out
+}
+
+// convertAlternatives конвертирует первый вариант anyOf/oneOf, отличный от null.
+// Вариант null делает параметр необязательным (Nullable); описание обёртки
+// используется, если у выбранного варианта своего описания нет.
+func (c *converter) convertAlternatives(s *jsonschema.Schema, alternatives []*jsonschema.Schema) *genai.Schema {
+	var out *genai.Schema
+	nullable := isNullable(s)
+	for _, sub := range alternatives {
+		if sub == nil {
+			continue
+		}
+		if schemaType(sub) == "null" {
+			nullable = true
+			continue
+		}
+		if out == nil {
+			out = c.convert(sub)
+		}
+	}
+	if out == nil {
+		out = &genai.Schema{Type: genai.TypeString}
+	}
+
+	if nullable {
+		out.Nullable = true
+	}
+	if out.Description == "" {
+		out.Description = firstNonEmpty(s.Description, s.Title)
+	}
+	return out
+}
+
+// mergeAllOf объединяет варианты allOf вместе с собственными ключами схемы.
+// Если среди них есть объект, результат - объект со всеми свойствами и обязательными полями
+// (при совпадении имён побеждает более поздний вариант). Иначе берётся первый вариант,
+// как в обёртке Pydantic {"allOf": [{"$ref": ...}], "description": ...} вокруг enum.
+// Описания всех частей склеиваются, чтобы модель видела все ограничения.
+func (c *converter) mergeAllOf(s *jsonschema.Schema) *genai.Schema {
+	parts := make([]*genai.Schema, 0, len(s.AllOf)+1)
+	for _, sub := range s.AllOf {
+		if sub != nil {
+			parts = append(parts, c.convert(sub))
+		}
+	}
+
+	own := *s
+	own.AllOf = nil
+	if schemaType(&own) != "" || len(own.Properties) > 0 {
+		parts = append(parts, c.convert(&own))
+	}
+
+	var descs []string
+	addDesc := func(d string) {
+		d = strings.TrimSpace(d)
+		for _, seen := range descs {
+			if seen == d {
+				return
+			}
+		}
+		if d != "" {
+			descs = append(descs, d)
+		}
+	}
+	addDesc(firstNonEmpty(s.Description, s.Title))
+	for _, part := range parts {
+		addDesc(part.Description)
+	}
+
+	var merged *genai.Schema
+	for _, part := range parts {
+		if part.Type != genai.TypeObject {
+			continue
+		}
+		if merged == nil {
+			merged = &genai.Schema{Type: genai.TypeObject, Properties: map[string]*genai.Schema{}}
+		}
+		for name, prop := range part.Properties {
+			merged.Properties[name] = prop
+		}
+		for _, name := range part.Required {
+			if !contains(merged.Required, name) {
+				merged.Required = append(merged.Required, name)
+			}
+		}
+	}
+	if merged == nil {
+		if len(parts) == 0 {
+			merged = &genai.Schema{Type: genai.TypeString}
+		} else {
+			merged = parts[0]
+		}
+	}
+
+	merged.Description = strings.Join(descs, "\n")
+	return merged
+}
+
+// resolve находит схему по локальной ссылке: "#", "#/$defs/Name" или "#/definitions/Name".
+// Внешние и неизвестные ссылки не разрешаются.
+func (c *converter) resolve(ref string) (*jsonschema.Schema, bool) {
+	if ref == "" || c.root == nil {
+		return nil, false
+	}
+	if ref == "#" {
+		return c.root, true
+	}
+
+	var defs map[string]*jsonschema.Schema
+	var name string
+	switch {
+	case strings.HasPrefix(ref, "#/$defs/"):
+		defs, name = c.root.Defs, strings.TrimPrefix(ref, "#/$defs/")
+	case strings.HasPrefix(ref, "#/definitions/"):
+		defs, name = c.root.Definitions, strings.TrimPrefix(ref, "#/definitions/")
+	default:
+		return nil, false
+	}
+
+	name = unescapePointer(name)
+	target, ok := defs[name]
+	return target, ok && target != nil
+}
+
+// unescapePointer декодирует сегмент JSON Pointer (RFC 6901) из URI фрагмента
+func unescapePointer(segment string) string {
+	if decoded, err := url.PathUnescape(segment); err == nil {
+		segment = decoded
+	}
+	segment = strings.ReplaceAll(segment, "~1", "/")
+	return strings.ReplaceAll(segment, "~0", "~")
+}
+
+// schemaType тип схемы в нижнем регистре: поле type или первый элемент types, отличный от null
+func schemaType(s *jsonschema.Schema) string {
+	t := s.Type
+	if t == "" && len(s.Types) > 0 {
+		t = s.Types[0]
+		for _, candidate := range s.Types {
+			if !strings.EqualFold(strings.TrimSpace(candidate), "null") {
+				t = candidate
+				break
+			}
+		}
+	}
+	return strings.ToLower(strings.TrimSpace(t))
+}
+
+// isNullable сообщает, допускает ли схема null через список types: ["string", "null"]
+func isNullable(s *jsonschema.Schema) bool {
+	if len(s.Types) < 2 {
+		return false
+	}
+	for _, t := range s.Types {
+		if strings.EqualFold(strings.TrimSpace(t), "null") {
+			return true
+		}
+	}
+	return false
+}
+
+func genaiType(t string) genai.Type {
+	switch t {
+	case "number":
+		return genai.TypeNumber
+	case "integer":
+		return genai.TypeInteger
+	case "boolean":
+		return genai.TypeBoolean
+	case "array":
+		return genai.TypeArray
+	case "object":
+		return genai.TypeObject
+	default:
+		// string, null и неизвестные типы
+		return genai.TypeString
+	}
+}
+
+func firstNonEmpty(ss ...string) string {
+	for _, s := range ss {
+		if strings.TrimSpace(s) != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// enumStrings приводит значения enum к строкам: числа и булевы значения записываются так же,
+// как в JSON; null и составные значения пропускаются
+func enumStrings(vals []any) []string {
+	var out []string
+	for _, v := range vals {
+		switch v := v.(type) {
+		case string:
+			out = append(out, v)
+		case bool:
+			out = append(out, strconv.FormatBool(v))
+		case float64:
+			out = append(out, strconv.FormatFloat(v, 'f', -1, 64))
+		case float32:
+			out = append(out, strconv.FormatFloat(float64(v), 'f', -1, 32))
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, json.Number:
+			out = append(out, fmt.Sprint(v))
+		}
+	}
+	return out
+}
@@ -0,0 +1,179 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// parseSchema разбирает JSON Schema так же, как она приходит от MCP сервера
+func parseSchema(t *testing.T, raw string) *jsonschema.Schema {
+	t.Helper()
+
+	var s jsonschema.Schema
+	if err := json.Unmarshal([]byte(raw), &s); err != nil {
+		t.Fatalf("invalid schema: %v", err)
+	}
+	return &s
+}
+
+func TestToGeminiSchemaTypes(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want genai.Type
+	}{
+		{"string", `{"type":"string"}`, genai.TypeString},
+		{"integer", `{"type":"integer"}`, genai.TypeInteger},
+		{"number", `{"type":"number"}`, genai.TypeNumber},
+		{"boolean", `{"type":"boolean"}`, genai.TypeBoolean},
+		{"upper case", `{"type":"Integer"}`, genai.TypeInteger},
+		{"no type", `{}`, genai.TypeString},
+		{"unknown type", `{"type":"date"}`, genai.TypeString},
+		{"types list", `{"type":["null","boolean"]}`, genai.TypeBoolean},
+		{"anyOf", `{"anyOf":[{"type":"integer"},{"type":"string"}]}`, genai.TypeInteger},
+		{"anyOf null first", `{"anyOf":[{"type":"null"},{"type":"number"}]}`, genai.TypeNumber},
+		{"anyOf only null", `{"anyOf":[{"type":"null"}]}`, genai.TypeString},
+		{"array without items", `{"type":"array"}`, genai.TypeArray},
+	}
+	for _, tt := range tests {
+		if got := ToGeminiSchema(parseSchema(t, tt.raw)).Type; got != tt.want {
+			t.Errorf("%s: type = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if got := ToGeminiSchema(nil); got.Type != genai.TypeString {
+		t.Errorf("nil schema: type = %v, want string", got.Type)
+	}
+}
+
+func TestToGeminiSchemaNested(t *testing.T) {
+	s := ToGeminiSchema(parseSchema(t, `{
+		"type": "object",
+		"description": "Filter",
+		"properties": {
+			"matrix": {"type": "array", "items": {"type": "array", "items": {"type": "number"}}},
+			"tags": {"type": "array", "items": {"type": "string", "enum": ["a", "b"]}},
+			"range": {"type": "object", "properties": {"from": {"type": "integer"}}, "required": ["from"]}
+		},
+		"required": ["matrix"]
+	}`))
+
+	if s.Type != genai.TypeObject || s.Description != "Filter" || !reflect.DeepEqual(s.Required, []string{"matrix"}) {
+		t.Fatalf("object = %+v", s)
+	}
+	matrix := s.Properties["matrix"]
+	if matrix.Type != genai.TypeArray || matrix.Items.Type != genai.TypeArray || matrix.Items.Items.Type != genai.TypeNumber {
+		t.Errorf("nested array = %+v", matrix)
+	}
+	if tags := s.Properties["tags"]; !reflect.DeepEqual(tags.Items.Enum, []string{"a", "b"}) {
+		t.Errorf("enum of array items = %v", tags.Items.Enum)
+	}
+	rng := s.Properties["range"]
+	if rng.Type != genai.TypeObject || rng.Properties["from"].Type != genai.TypeInteger || !reflect.DeepEqual(rng.Required, []string{"from"}) {
+		t.Errorf("nested object = %+v", rng)
+	}
+	// Массив без items получает строковые элементы: Gemini требует items у массива
+	if items := ToGeminiSchema(parseSchema(t, `{"type":"array"}`)).Items; items == nil || items.Type != genai.TypeString {
+		t.Errorf("items of an array without items = %+v", items)
+	}
+}
+
+func TestToGeminiParametersRoot(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   *jsonschema.Schema
+		props    int
+		required []string
+	}{
+		{"nil", nil, 0, nil},
+		{"object", parseSchema(t, `{"type":"object","properties":{"a":{"type":"string"}},"required":["a"]}`), 1, []string{"a"}},
+		{"types list", parseSchema(t, `{"type":["object"],"properties":{"a":{"type":"string"}}}`), 1, nil},
+		{"no type", parseSchema(t, `{"properties":{"a":{"type":"string"},"b":{"type":"integer"}}}`), 2, nil},
+		{"not an object", parseSchema(t, `{"type":"string","description":"raw"}`), 0, nil},
+	}
+	for _, tt := range tests {
+		params := ToGeminiParameters(tt.schema)
+		if params.Type != genai.TypeObject || len(params.Properties) != tt.props || !reflect.DeepEqual(params.Required, tt.required) {
+			t.Errorf("%s: parameters = %+v", tt.name, params)
+		}
+	}
+}
+
+func TestToGeminiFunctionDeclarations(t *testing.T) {
+	tools := []*mcp.Tool{
+		{Name: "search", Description: "Search documents", InputSchema: parseSchema(t, `{"type":"object","properties":{"q":{"type":"string"}},"required":["q"]}`)},
+		{Name: "ping", Annotations: &mcp.ToolAnnotations{Title: "Ping the server"}},
+	}
+
+	decls := ToGeminiFunctionDeclarations(tools)
+	if len(decls) != 2 {
+		t.Fatalf("declarations = %d, want 2", len(decls))
+	}
+	if decls[0].Name != "search" || decls[0].Description != "Search documents" || decls[0].Parameters.Properties["q"] == nil {
+		t.Errorf("search = %+v", decls[0])
+	}
+	// Описание из заголовка аннотаций, параметры без схемы - пустой объект
+	if decls[1].Description != "Ping the server" || decls[1].Parameters.Type != genai.TypeObject {
+		t.Errorf("ping = %+v", decls[1])
+	}
+}
+
+func TestToGeminiParametersRefs(t *testing.T) {
+	params := ToGeminiParameters(parseSchema(t, `{
+		"type": "object",
+		"properties": {
+			"tree": {"$ref": "#/$defs/Node"},
+			"address": {"$ref": "#/$defs/Address", "description": "Delivery address"}
+		},
+		"required": ["tree"],
+		"$defs": {
+			"Node": {
+				"type": "object",
+				"description": "Tree node",
+				"properties": {
+					"name": {"type": "string"},
+					"children": {"type": "array", "items": {"$ref": "#/$defs/Node"}}
+				},
+				"required": ["name"]
+			},
+			"Address": {
+				"type": "object",
+				"properties": {
+					"city": {"type": "string"},
+					"zip": {"type": "string", "pattern": "^[0-9]{6}$"}
+				},
+				"required": ["city", "zip"]
+			}
+		}
+	}`))
+
+	tree := params.Properties["tree"]
+	if tree == nil || tree.Type != genai.TypeObject || tree.Description != "Tree node" || !reflect.DeepEqual(tree.Required, []string{"name"}) {
+		t.Fatalf("tree = %+v", tree)
+	}
+	// Ссылка Node внутри собственного раскрытия остаётся объектом без свойств
+	children := tree.Properties["children"]
+	if children == nil || children.Type != genai.TypeArray || children.Items == nil {
+		t.Fatalf("children = %+v", children)
+	}
+	if child := children.Items; child.Type != genai.TypeObject || len(child.Properties) != 0 || child.Description != "Tree node" {
+		t.Errorf("recursive child = %+v", child)
+	}
+
+	// Обязательные поля определения сохраняются, описание рядом с $ref заменяет описание определения
+	address := params.Properties["address"]
+	if address == nil || address.Type != genai.TypeObject || address.Description != "Delivery address" {
+		t.Fatalf("address = %+v", address)
+	}
+	if !reflect.DeepEqual(address.Required, []string{"city", "zip"}) || address.Properties["zip"].Type != genai.TypeString {
+		t.Errorf("address = %+v", address)
+	}
+	if !reflect.DeepEqual(params.Required, []string{"tree"}) {
+		t.Errorf("required = %v", params.Required)
+	}
+}
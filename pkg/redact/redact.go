@@ -0,0 +1,278 @@
+// Package redact заменяет персональные данные (email, телефоны, номера карт) в тексте
+// токенами вида [EMAIL] перед сохранением в базу и записью в логи.
+// Пакет не зависит от остального кода, поэтому его используют и сервисы, и провайдеры LLM.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Встроенные детекторы
+const (
+	DetectorEmail = "email"
+	DetectorPhone = "phone"
+	DetectorCard  = "card"
+)
+
+// DefaultDetectors детекторы, включённые по умолчанию
+var DefaultDetectors = []string{DetectorEmail, DetectorCard, DetectorPhone}
+
+// Pattern пользовательское правило: регулярное выражение (синтаксис RE2) и токен замены.
+// Пустая замена формируется из имени: "iban" -> "[IBAN]".
+type Pattern struct {
+	Name        string
+	Regex       string
+	Replacement string
+}
+
+// Config набор правил редактора. Встроенные детекторы применяются в порядке email, card, phone
+// независимо от порядка перечисления (номер карты не должен распознаваться как телефон),
+// затем пользовательские шаблоны в порядке перечисления.
+type Config struct {
+	Detectors []string
+	Patterns  []Pattern
+}
+
+// rule одно правило: кандидаты ищутся регулярным выражением, accept отсекает ложные совпадения
+type rule struct {
+	name        string
+	re          *regexp.Regexp
+	replacement string
+	accept      func(text string, start, end int) bool
+}
+
+// Redactor заменяет найденные персональные данные токенами.
+// Нулевой указатель - выключенная редакция: текст возвращается без изменений.
+type Redactor struct {
+	rules []rule
+}
+
+var (
+	emailRe = regexp.MustCompile(`(?i)[a-z0-9._%+\-]+@[a-z0-9\-]+(?:\.[a-z0-9\-]+)*\.[a-z]{2,}`)
+	cardRe  = regexp.MustCompile(`\d(?:[ \-]?\d){12,18}`)
+	phoneRe = regexp.MustCompile(`(?:\+\d{1,3}[ .\-]?)?(?:\(\d{1,4}\)[ .\-]?)?\d{2,4}(?:[ .\-]?\d{2,4}){1,4}`)
+	ipv4Re  = regexp.MustCompile(`^\d{1,3}(?:\.\d{1,3}){3}$`)
+)
+
+var builtinRules = map[string]rule{
+	DetectorEmail: {name: DetectorEmail, re: emailRe, replacement: "[EMAIL]", accept: acceptEmail},
+	DetectorCard:  {name: DetectorCard, re: cardRe, replacement: "[CARD]", accept: acceptCard},
+	DetectorPhone: {name: DetectorPhone, re: phoneRe, replacement: "[PHONE]", accept: acceptPhone},
+}
+
+// New собирает редактор из конфигурации; неизвестный детектор или неверное выражение - ошибка
+func New(cfg Config) (*Redactor, error) {
+	r := &Redactor{}
+
+	enabled := make(map[string]bool)
+	for _, name := range cfg.Detectors {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := builtinRules[name]; !ok {
+			return nil, fmt.Errorf("unknown redaction detector %q (supported: %s)", name, strings.Join(DefaultDetectors, ", "))
+		}
+		enabled[name] = true
+	}
+	for _, name := range DefaultDetectors {
+		if enabled[name] {
+			r.rules = append(r.rules, builtinRules[name])
+		}
+	}
+
+	for i, p := range cfg.Patterns {
+		name := strings.TrimSpace(p.Name)
+		if name == "" {
+			return nil, fmt.Errorf("redaction pattern %d: name is required", i)
+		}
+		if p.Regex == "" {
+			return nil, fmt.Errorf("redaction pattern %q: regex is required", name)
+		}
+
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("redaction pattern %q: %w", name, err)
+		}
+
+		replacement := p.Replacement
+		if replacement == "" {
+			replacement = "[" + strings.ToUpper(name) + "]"
+		}
+		r.rules = append(r.rules, rule{name: name, re: re, replacement: replacement})
+	}
+
+	return r, nil
+}
+
+// Enabled сообщает, есть ли у редактора правила
+func (r *Redactor) Enabled() bool {
+	return r != nil && len(r.rules) > 0
+}
+
+// Redact возвращает текст с заменёнными персональными данными и число замен
+func (r *Redactor) Redact(text string) (string, int) {
+	if !r.Enabled() || text == "" {
+		return text, 0
+	}
+
+	total := 0
+	for _, rl := range r.rules {
+		var n int
+		text, n = rl.apply(text)
+		total += n
+	}
+	return text, total
+}
+
+// String возвращает текст с заменёнными персональными данными
+func (r *Redactor) String(text string) string {
+	redacted, _ := r.Redact(text)
+	return redacted
+}
+
+// Value редактирует строки внутри JSON-подобного значения (map[string]any, []any, string)
+// и возвращает копию и число замен; исходное значение не изменяется
+func (r *Redactor) Value(value any) (any, int) {
+	if !r.Enabled() {
+		return value, 0
+	}
+
+	switch v := value.(type) {
+	case string:
+		return r.Redact(v)
+	case map[string]any:
+		redacted, n := r.Map(v)
+		return redacted, n
+	case []any:
+		items := make([]any, len(v))
+		total := 0
+		for i, item := range v {
+			var n int
+			items[i], n = r.Value(item)
+			total += n
+		}
+		return items, total
+	default:
+		return v, 0
+	}
+}
+
+// Map редактирует значения карты аргументов или результата инструмента
+func (r *Redactor) Map(m map[string]any) (map[string]any, int) {
+	if !r.Enabled() || m == nil {
+		return m, 0
+	}
+
+	redacted := make(map[string]any, len(m))
+	total := 0
+	for key, value := range m {
+		var n int
+		redacted[key], n = r.Value(value)
+		total += n
+	}
+	return redacted, total
+}
+
+func (rl rule) apply(text string) (string, int) {
+	matches := rl.re.FindAllStringIndex(text, -1)
+	if len(matches) == 0 {
+		return text, 0
+	}
+
+	var sb strings.Builder
+	last, count := 0, 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if rl.accept != nil && !rl.accept(text, start, end) {
+			continue
+		}
+		sb.WriteString(text[last:start])
+		sb.WriteString(rl.replacement)
+		last = end
+		count++
+	}
+	if count == 0 {
+		return text, 0
+	}
+	sb.WriteString(text[last:])
+	return sb.String(), count
+}
+
+// isolated проверяет, что совпадение не является частью более длинного слова или числа
+// (например, фрагментом UUID или идентификатора)
+func isolated(text string, start, end int, extra string) bool {
+	if start > 0 {
+		prev, _ := utf8.DecodeLastRuneInString(text[:start])
+		if unicode.IsLetter(prev) || unicode.IsDigit(prev) || strings.ContainsRune(extra, prev) {
+			return false
+		}
+	}
+	if end < len(text) {
+		next, _ := utf8.DecodeRuneInString(text[end:])
+		if unicode.IsLetter(next) || unicode.IsDigit(next) || strings.ContainsRune(extra, next) {
+			return false
+		}
+	}
+	return true
+}
+
+func acceptEmail(text string, start, end int) bool {
+	return isolated(text, start, end, "_")
+}
+
+// acceptCard принимает 13-19 цифр с корректной контрольной суммой Луна
+func acceptCard(text string, start, end int) bool {
+	if !isolated(text, start, end, "-_") {
+		return false
+	}
+	digits := onlyDigits(text[start:end])
+	return len(digits) >= 13 && len(digits) <= 19 && luhnValid(digits)
+}
+
+// acceptPhone принимает 10-15 цифр (8-15 с международным префиксом "+"),
+// исключая IPv4 адреса и версии вида 10.0.0.1
+func acceptPhone(text string, start, end int) bool {
+	if !isolated(text, start, end, "-_") {
+		return false
+	}
+	candidate := text[start:end]
+	if ipv4Re.MatchString(candidate) {
+		return false
+	}
+
+	digits := onlyDigits(candidate)
+	minDigits := 10
+	if strings.HasPrefix(candidate, "+") {
+		minDigits = 8
+	}
+	return len(digits) >= minDigits && len(digits) <= 15
+}
+
+func onlyDigits(s string) string {
+	var sb strings.Builder
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
+
+func luhnValid(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,110 @@
+package redact
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newTestRedactor(t *testing.T) *Redactor {
+	t.Helper()
+
+	r, err := New(Config{
+		Detectors: DefaultDetectors,
+		Patterns:  []Pattern{{Name: "iban", Regex: `\bDE\d{20}\b`}},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return r
+}
+
+func TestRedactPositives(t *testing.T) {
+	r := newTestRedactor(t)
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"mail me at john.doe+x@example.co.uk.", "mail me at [EMAIL]."},
+		{"Email: Ivan_Petrov@mail.ru, thanks", "Email: [EMAIL], thanks"},
+		{"call +1 (555) 123-4567 now", "call [PHONE] now"},
+		{"тел. +7 916 123-45-67", "тел. [PHONE]"},
+		{"(555) 123-4567", "[PHONE]"},
+		{"555.123.4567", "[PHONE]"},
+		{"+44 20 7946 0958", "[PHONE]"},
+		{"89161234567", "[PHONE]"},
+		{"card 4111 1111 1111 1111 exp", "card [CARD] exp"},
+		{"4111-1111-1111-1111", "[CARD]"},
+		{"amex 378282246310005", "amex [CARD]"},
+		{"amex 3782 822463 10005.", "amex [CARD]."},
+		{"iban DE89370400440532013000", "iban [IBAN]"},
+		{"a@b.io and +49 30 1234567", "[EMAIL] and [PHONE]"},
+	}
+	for _, tt := range tests {
+		got, n := r.Redact(tt.in)
+		if got != tt.want || n == 0 {
+			t.Errorf("Redact(%q) = %q, %d; want %q", tt.in, got, n, tt.want)
+		}
+	}
+}
+
+func TestRedactNegatives(t *testing.T) {
+	r := newTestRedactor(t)
+
+	tests := []string{
+		"session 550e8400-e29b-41d4-a716-446655440000",
+		"released on 2024-01-15T10:30:00Z",
+		"server 192.168.100.100 is down",
+		"version 1.2.3 and 10.0.0.1",
+		"order 1234567",
+		"price 1 500 000 rub",
+		"numbers 1 2 3 4 5 6 7 8 9 10",
+		// Не проходит проверку Луна
+		"card 4111 1111 1111 1112 fake",
+		"user@localhost",
+		"id 12345678901234567890123",
+		"hash a1b2c3d4e5f6071829304",
+	}
+	for _, in := range tests {
+		if got, n := r.Redact(in); got != in || n != 0 {
+			t.Errorf("Redact(%q) = %q, %d; want unchanged", in, got, n)
+		}
+	}
+}
+
+func TestRedactMap(t *testing.T) {
+	r := newTestRedactor(t)
+
+	args := map[string]any{
+		"to":     "x@y.com",
+		"list":   []any{"4111111111111111", 3},
+		"nested": map[string]any{"phone": "+15551234567"},
+	}
+	redacted, n := r.Map(args)
+	want := map[string]any{
+		"to":     "[EMAIL]",
+		"list":   []any{"[CARD]", 3},
+		"nested": map[string]any{"phone": "[PHONE]"},
+	}
+	if n != 3 || !reflect.DeepEqual(redacted, want) {
+		t.Errorf("Map = %v, %d; want %v, 3", redacted, n, want)
+	}
+	// Исходные аргументы не меняются
+	if args["to"] != "x@y.com" || args["nested"].(map[string]any)["phone"] != "+15551234567" {
+		t.Errorf("source arguments were mutated: %v", args)
+	}
+}
+
+func TestRedactorDisabled(t *testing.T) {
+	var r *Redactor
+	if text, n := r.Redact("x@y.com"); text != "x@y.com" || n != 0 {
+		t.Errorf("nil redactor changed text: %q, %d", text, n)
+	}
+
+	if _, err := New(Config{Detectors: []string{"ssn"}}); err == nil {
+		t.Error("unknown detector: want error")
+	}
+	if _, err := New(Config{Patterns: []Pattern{{Name: "bad", Regex: "("}}}); err == nil {
+		t.Error("invalid pattern: want error")
+	}
+}
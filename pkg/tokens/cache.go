@@ -0,0 +1,126 @@
+package tokens
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"sync"
+)
+
+// DefaultCacheSize размер кэша точного счётчика по умолчанию
+const DefaultCacheSize = 1024
+
+// CountFunc точный подсчёт токенов через API провайдера
+type CountFunc func(ctx context.Context, text string) (int, error)
+
+// CachedCounter точный счётчик токенов с LRU-кэшем по хэшу содержимого.
+// При ошибке провайдера используется fallback (если задан), результат не кэшируется.
+type CachedCounter struct {
+	count    CountFunc
+	fallback Estimator
+	size     int
+
+	mu      sync.Mutex
+	order   *list.List // от недавно использованных к давно использованным
+	entries map[[sha256.Size]byte]*list.Element
+	hits    int64
+	misses  int64
+}
+
+type cacheEntry struct {
+	key    [sha256.Size]byte
+	tokens int
+}
+
+// CacheStats статистика кэша точного счётчика
+type CacheStats struct {
+	Size   int   `json:"size"`
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// NewCachedCounter создаёт счётчик; size <= 0 - DefaultCacheSize, fallback может быть nil
+func NewCachedCounter(count CountFunc, size int, fallback Estimator) *CachedCounter {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+	return &CachedCounter{
+		count:    count,
+		fallback: fallback,
+		size:     size,
+		order:    list.New(),
+		entries:  make(map[[sha256.Size]byte]*list.Element),
+	}
+}
+
+// Count возвращает число токенов из кэша или запрашивает его у провайдера
+func (c *CachedCounter) Count(ctx context.Context, text string) (int, error) {
+	if text == "" {
+		return 0, nil
+	}
+
+	key := sha256.Sum256([]byte(text))
+	if tokens, ok := c.get(key); ok {
+		return tokens, nil
+	}
+
+	// Запрос к провайдеру выполняется без блокировки: параллельные промахи
+	// по одному тексту допустимы и лишь повторно записывают одно значение
+	tokens, err := c.count(ctx, text)
+	if err != nil {
+		if c.fallback != nil {
+			return c.fallback.Count(ctx, text)
+		}
+		return 0, err
+	}
+
+	c.put(key, tokens)
+	return tokens, nil
+}
+
+// Stats возвращает статистику кэша
+func (c *CachedCounter) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{
+		Size:   c.order.Len(),
+		Hits:   c.hits,
+		Misses: c.misses,
+	}
+}
+
+func (c *CachedCounter) get(key [sha256.Size]byte) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return 0, false
+	}
+
+	c.hits++
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).tokens, true
+}
+
+func (c *CachedCounter) put(key [sha256.Size]byte, tokens int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*cacheEntry).tokens = tokens
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, tokens: tokens})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+var _ Estimator = (*CachedCounter)(nil)
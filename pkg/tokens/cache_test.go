@@ -0,0 +1,77 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// countingFunc точный счётчик для тестов: число байт текста, учитывает обращения
+type countingFunc struct {
+	calls int
+	err   error
+}
+
+func (f *countingFunc) count(ctx context.Context, text string) (int, error) {
+	f.calls++
+	if f.err != nil {
+		return 0, f.err
+	}
+	return len(text), nil
+}
+
+func TestCachedCounterCachesByContent(t *testing.T) {
+	ctx := context.Background()
+	provider := &countingFunc{}
+	counter := NewCachedCounter(provider.count, 2, nil)
+
+	for _, text := range []string{"first", "first", "second", "first"} {
+		if got, err := counter.Count(ctx, text); err != nil || got != len(text) {
+			t.Fatalf("Count(%q) = %d, %v", text, got, err)
+		}
+	}
+	if provider.calls != 2 {
+		t.Errorf("provider calls = %d, want 2", provider.calls)
+	}
+	if stats := counter.Stats(); stats.Hits != 2 || stats.Misses != 2 || stats.Size != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	// "second" давно не использовался и вытесняется новым текстом
+	counter.Count(ctx, "third")
+	counter.Count(ctx, "first")
+	counter.Count(ctx, "second")
+	if provider.calls != 4 {
+		t.Errorf("provider calls after eviction = %d, want 4", provider.calls)
+	}
+}
+
+func TestCachedCounterFallback(t *testing.T) {
+	ctx := context.Background()
+	provider := &countingFunc{err: errors.New("quota exceeded")}
+
+	withoutFallback := NewCachedCounter(provider.count, 0, nil)
+	if _, err := withoutFallback.Count(ctx, "text"); err == nil {
+		t.Error("error of the provider was lost without fallback")
+	}
+
+	counter := NewCachedCounter(provider.count, 0, NewHeuristic())
+	got, err := counter.Count(ctx, "abcdabcd")
+	if err != nil || got != 2 {
+		t.Fatalf("Count with fallback = %d, %v; want heuristic 2", got, err)
+	}
+
+	// Результат запасной оценки не кэшируется: после восстановления провайдера счёт точный
+	provider.err = nil
+	if got, _ := counter.Count(ctx, "abcdabcd"); got != 8 {
+		t.Errorf("Count after recovery = %d, want 8", got)
+	}
+}
+
+func TestCachedCounterEmptyText(t *testing.T) {
+	provider := &countingFunc{}
+	counter := NewCachedCounter(provider.count, 0, nil)
+	if got, err := counter.Count(context.Background(), ""); got != 0 || err != nil || provider.calls != 0 {
+		t.Errorf("Count(\"\") = %d, %v with %d provider calls", got, err, provider.calls)
+	}
+}
@@ -0,0 +1,56 @@
+package tokens
+
+import (
+	"context"
+	"math"
+	"unicode"
+)
+
+// Средние затраты токенов на символ для разных алфавитов.
+// Базовое правило - 4 символа латиницы на токен; кириллица и казахский
+// дробятся токенизатором Gemini мельче, иероглифы - почти по токену на символ.
+const (
+	latinTokensPerRune    = 1.0 / 4
+	cyrillicTokensPerRune = 1.0 / 3
+	cjkTokensPerRune      = 1.0 / 1.5
+	otherTokensPerRune    = 1.0 / 3
+)
+
+// Heuristic быстрая оценка по числу символов с поправкой на алфавит.
+// Погрешность для обычного текста - порядка 10-20% относительно CountTokens.
+type Heuristic struct{}
+
+// NewHeuristic создаёт эвристический оценщик
+func NewHeuristic() Heuristic {
+	return Heuristic{}
+}
+
+// Count никогда не возвращает ошибку
+func (Heuristic) Count(_ context.Context, text string) (int, error) {
+	return Estimate(text), nil
+}
+
+// Estimate оценивает токены текста эвристикой
+func Estimate(text string) int {
+	if text == "" {
+		return 0
+	}
+
+	var weight float64
+	for _, r := range text {
+		switch {
+		case r <= unicode.MaxASCII, unicode.Is(unicode.Latin, r):
+			weight += latinTokensPerRune
+		case unicode.Is(unicode.Cyrillic, r):
+			weight += cyrillicTokensPerRune
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			weight += cjkTokensPerRune
+		default:
+			weight += otherTokensPerRune
+		}
+	}
+
+	return int(math.Ceil(weight))
+}
+
+var _ Estimator = Heuristic{}
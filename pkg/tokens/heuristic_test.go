@@ -0,0 +1,53 @@
+package tokens
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEstimateByAlphabet(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{"empty", "", 0},
+		{"latin", strings.Repeat("abcd", 25), 25},
+		{"latin rounds up", "abcde", 2},
+		{"cyrillic", strings.Repeat("абв", 30), 30},
+		{"kazakh", strings.Repeat("әғқ", 10), 10},
+		{"cjk", strings.Repeat("你好吗", 10), 20},
+		{"mixed", "abcd" + "абв", 2},
+	}
+	for _, tt := range tests {
+		if got := Estimate(tt.text); got != tt.want {
+			t.Errorf("%s: Estimate = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEstimateRelativeCost(t *testing.T) {
+	// Кириллический текст той же длины дороже латинского, иероглифы - дороже обоих
+	latin := Estimate(strings.Repeat("x", 300))
+	cyrillic := Estimate(strings.Repeat("ж", 300))
+	cjk := Estimate(strings.Repeat("字", 300))
+	if !(latin < cyrillic && cyrillic < cjk) {
+		t.Errorf("latin = %d, cyrillic = %d, cjk = %d; want increasing", latin, cyrillic, cjk)
+	}
+}
+
+func TestEstimateMessagesAddsOverhead(t *testing.T) {
+	messages := []Message{
+		{Role: "system", Content: strings.Repeat("abcd", 10)},
+		{Role: "user", Content: ""},
+		{Role: "assistant", Content: "abcd"},
+	}
+	got, err := EstimateMessages(context.Background(), NewHeuristic(), messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 10 + 0 + 1 + 3*MessageOverhead; got != want {
+		t.Errorf("EstimateMessages = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,33 @@
+// Package tokens оценивает количество токенов без обращения к API провайдера.
+// Пакет не зависит от остального кода и внешних модулей, поэтому его могут
+// использовать слои хранения и управления контекстом.
+package tokens
+
+import "context"
+
+// MessageOverhead токены служебной разметки одного сообщения (роль, границы)
+const MessageOverhead = 4
+
+// Estimator оценивает количество токенов в тексте
+type Estimator interface {
+	Count(ctx context.Context, text string) (int, error)
+}
+
+// Message сообщение для оценки: роль и содержимое
+type Message struct {
+	Role    string
+	Content string
+}
+
+// EstimateMessages оценивает токены набора сообщений с учётом служебной разметки каждого сообщения
+func EstimateMessages(ctx context.Context, e Estimator, messages []Message) (int, error) {
+	total := 0
+	for _, msg := range messages {
+		n, err := e.Count(ctx, msg.Content)
+		if err != nil {
+			return 0, err
+		}
+		total += n + MessageOverhead
+	}
+	return total, nil
+}